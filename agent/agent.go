@@ -4,13 +4,13 @@
 package agent
 
 import (
+	"context"
 	"crypto/tls"
+	"crypto/x509"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
-	"net/http/httputil"
-	"net/url"
+	"os"
 	"time"
 
 	"github.com/google/go-sev-guest/proto/sevsnp"
@@ -52,81 +52,108 @@ type TLSConfig struct {
 }
 
 type Config struct {
-	BackendURL string    `json:"backend_url"`
-	TLS        TLSConfig `json:"tls"`
+	// BackendURL and TLS describe a single default upstream and are ignored
+	// when Upstreams is set.
+	BackendURL string         `json:"backend_url"`
+	TLS        TLSConfig      `json:"tls"`
+	Upstreams  []Upstream     `json:"upstreams"`
+	Evidence   EvidenceConfig `json:"evidence"`
+	// HealthInterval is how often upstreams are probed for /health.
+	HealthInterval time.Duration `json:"health_interval"`
 }
 
 type agentService struct {
 	config     *Config
 	provider   attestation.Provider
-	transport  *http.Transport
+	router     *router
 	ccPlatform attestation.PlatformType
+	evidence   evidenceCache
 }
 
 type Service interface {
 	Proxy() http.Handler
+	// Health probes every configured upstream and caches the results.
+	Health(ctx context.Context) []UpstreamHealth
+	// CachedHealth returns the results of the latest probe without probing.
+	CachedHealth() []UpstreamHealth
+	// RunHealthChecks probes the upstreams periodically until ctx is done.
+	RunHealthChecks(ctx context.Context) error
 	Attestation(
 		reportData [quoteprovider.Nonce]byte, nonce [vtpm.Nonce]byte, toJSON bool,
 	) ([]byte, error)
+	// Evidence returns fresh evidence bound to nonce, or the cached bundle
+	// when nonce is nil.
+	Evidence(ctx context.Context, nonce []byte) (EvidenceBundle, error)
+	// RefreshEvidence regenerates the cached evidence bundle.
+	RefreshEvidence(ctx context.Context) (EvidenceBundle, error)
+	// RunEvidenceRefresh refreshes the cached bundle periodically until ctx is done.
+	RunEvidenceRefresh(ctx context.Context) error
 }
 
 func New(config *Config, provider attestation.Provider, ccPlatform attestation.PlatformType) (Service, error) {
-	transport := &http.Transport{
-		MaxIdleConns:        100,
-		IdleConnTimeout:     90 * time.Second,
-		TLSHandshakeTimeout: 10 * time.Second,
-	}
-
-	if config.TLS.Enabled {
-		tlsConfig, err := setTLSConfig(config)
-		if err != nil {
-			return nil, fmt.Errorf("failed to set TLS config: %w", err)
-		}
-
-		transport.TLSClientConfig = tlsConfig
+	upstreams, err := upstreamsFromConfig(config)
+	if err != nil {
+		return nil, err
 	}
 
-	return &agentService{
+	a := &agentService{
 		config:     config,
-		transport:  transport,
 		provider:   provider,
 		ccPlatform: ccPlatform,
-	}, nil
+	}
+
+	a.router, err = newRouter(upstreams, a.modifyHeaders)
+	if err != nil {
+		return nil, err
+	}
+
+	return a, nil
 }
 
 func (a *agentService) Proxy() http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		targetURL := a.config.BackendURL
+	return a.router
+}
 
-		log.Printf("Agent received: %s %s", r.Method, r.URL.Path)
+func (a *agentService) Health(ctx context.Context) []UpstreamHealth {
+	return a.router.health(ctx)
+}
 
-		target, err := url.Parse(targetURL)
-		if err != nil {
-			log.Printf("Invalid target URL %s: %v", targetURL, err)
-			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+func (a *agentService) CachedHealth() []UpstreamHealth {
+	return a.router.cachedHealth()
+}
 
-			return
-		}
+func (a *agentService) RunHealthChecks(ctx context.Context) error {
+	interval := a.config.HealthInterval
+	if interval <= 0 {
+		interval = DefaultHealthInterval
+	}
 
-		proxy := &httputil.ReverseProxy{
-			Transport: a.transport,
-			Rewrite: func(req *httputil.ProxyRequest) {
-				req.SetURL(target)
-				a.modifyHeaders(req.Out)
-				log.Printf("Agent forwarding to Backend: %s %s", req.Out.Method, req.Out.URL.Path)
-			},
-			ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
-				log.Printf("Proxy error: %v", err)
-				http.Error(w, "Bad Gateway", http.StatusBadGateway)
-			},
-		}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		a.router.health(ctx)
 
-		proxy.ServeHTTP(w, r)
-	})
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
 }
 
 func (a *agentService) Attestation(
 	reportData [quoteprovider.Nonce]byte, nonce [vtpm.Nonce]byte, toJSON bool,
+) ([]byte, error) {
+	start := time.Now()
+	report, err := a.attestation(reportData, nonce, toJSON)
+	observeQuote(PlatformName(a.ccPlatform), "request", time.Since(start), err)
+
+	return report, err
+}
+
+func (a *agentService) attestation(
+	reportData [quoteprovider.Nonce]byte, nonce [vtpm.Nonce]byte, toJSON bool,
 ) ([]byte, error) {
 	switch a.ccPlatform {
 	case attestation.SNP:
@@ -217,21 +244,21 @@ func (a *agentService) tdxAttestationToJSON(vTPMQuote []byte) ([]byte, error) {
 	}.Marshal(quote)
 }
 
-func setTLSConfig(config *Config) (*tls.Config, error) {
+func setTLSConfig(config TLSConfig) (*tls.Config, error) {
 	tlsConfig := &tls.Config{
-		InsecureSkipVerify: config.TLS.InsecureSkipVerify,
+		InsecureSkipVerify: config.InsecureSkipVerify,
 	}
 
-	if config.TLS.MinVersion != 0 {
-		tlsConfig.MinVersion = config.TLS.MinVersion
+	if config.MinVersion != 0 {
+		tlsConfig.MinVersion = config.MinVersion
 	}
 
-	if config.TLS.MaxVersion != 0 {
-		tlsConfig.MaxVersion = config.TLS.MaxVersion
+	if config.MaxVersion != 0 {
+		tlsConfig.MaxVersion = config.MaxVersion
 	}
 
-	if config.TLS.CertFile != "" && config.TLS.KeyFile != "" {
-		cert, err := tls.LoadX509KeyPair(config.TLS.CertFile, config.TLS.KeyFile)
+	if config.CertFile != "" && config.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(config.CertFile, config.KeyFile)
 		if err != nil {
 			return nil, fmt.Errorf("failed to load client certificate: %w", err)
 		}
@@ -239,5 +266,19 @@ func setTLSConfig(config *Config) (*tls.Config, error) {
 		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
 
+	if config.CAFile != "" {
+		caPEM, err := os.ReadFile(config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("failed to parse CA file %s", config.CAFile)
+		}
+
+		tlsConfig.RootCAs = pool
+	}
+
 	return tlsConfig, nil
 }
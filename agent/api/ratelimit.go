@@ -0,0 +1,188 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ultravioletrs/cube/agent"
+)
+
+// clientIdleTTL is how long an idle client's bucket is kept.
+const clientIdleTTL = 10 * time.Minute
+
+// RateLimit bounds attestation requests per client with a token bucket.
+// A non-positive RPS disables limiting.
+type RateLimit struct {
+	RPS   float64
+	Burst int
+	// ClientHeader names a header a proxy sets to the client's identity, such
+	// as X-Forwarded-For. It is only trusted on requests from TrustedProxies;
+	// other requests are keyed by their remote IP. When the header holds a
+	// list, the last entry, appended by the proxy, is used.
+	ClientHeader   string
+	TrustedProxies []netip.Prefix
+}
+
+// ParseTrustedProxies parses a comma-separated list of IP addresses and CIDR
+// ranges.
+func ParseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q", entry)
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// clientKey returns the identity r is rate limited by.
+func (cfg RateLimit) clientKey(r *http.Request) string {
+	client, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		client = r.RemoteAddr
+	}
+
+	if cfg.ClientHeader == "" || !cfg.trusted(client) {
+		return client
+	}
+
+	values := strings.Split(r.Header.Get(cfg.ClientHeader), ",")
+	if forwarded := strings.TrimSpace(values[len(values)-1]); forwarded != "" {
+		return forwarded
+	}
+
+	return client
+}
+
+func (cfg RateLimit) trusted(client string) bool {
+	addr, err := netip.ParseAddr(client)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+
+	for _, prefix := range cfg.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type limiter struct {
+	cfg       RateLimit
+	mu        sync.Mutex
+	clients   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func newLimiter(cfg RateLimit) *limiter {
+	if cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+
+	return &limiter{cfg: cfg, clients: make(map[string]*bucket), now: time.Now}
+}
+
+// allow takes a token for client, returning how long to wait when none is left.
+func (l *limiter) allow(client string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	b, ok := l.clients[client]
+	if !ok {
+		b = &bucket{tokens: float64(l.cfg.Burst), last: now}
+		l.clients[client] = b
+	}
+
+	b.tokens = math.Min(float64(l.cfg.Burst), b.tokens+now.Sub(b.last).Seconds()*l.cfg.RPS)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+
+		return true, 0
+	}
+
+	wait := time.Duration((1 - b.tokens) / l.cfg.RPS * float64(time.Second))
+
+	return false, wait
+}
+
+func (l *limiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < clientIdleTTL {
+		return
+	}
+
+	l.lastSweep = now
+
+	for client, b := range l.clients {
+		if now.Sub(b.last) > clientIdleTTL {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// rateLimited wraps next with a per-client limiter. Clients are keyed by the
+// connection's remote IP, or by cfg.ClientHeader on requests from trusted
+// proxies.
+func rateLimited(cfg RateLimit, route string, next http.Handler) http.Handler {
+	if cfg.RPS <= 0 {
+		return next
+	}
+
+	l := newLimiter(cfg)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, wait := l.allow(cfg.clientKey(r)); !ok {
+			agent.RateLimited.WithLabelValues(route).Inc()
+
+			w.Header().Set("Content-Type", ContentType)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			w.WriteHeader(http.StatusTooManyRequests)
+			_ = json.NewEncoder(w).Encode(map[string]string{"error": "rate limit exceeded"})
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,93 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterRefillsPerClient(t *testing.T) {
+	t.Parallel()
+
+	now := time.Unix(1_700_000_000, 0)
+	l := newLimiter(RateLimit{RPS: 1, Burst: 2})
+	l.now = func() time.Time { return now }
+
+	ok, _ := l.allow("10.0.0.1")
+	assert.True(t, ok)
+	ok, _ = l.allow("10.0.0.1")
+	assert.True(t, ok)
+
+	ok, wait := l.allow("10.0.0.1")
+	assert.False(t, ok)
+	assert.Equal(t, time.Second, wait)
+
+	ok, _ = l.allow("10.0.0.2")
+	assert.True(t, ok, "other clients have their own bucket")
+
+	now = now.Add(time.Second)
+	ok, _ = l.allow("10.0.0.1")
+	assert.True(t, ok)
+}
+
+func TestRateLimitedRejectsWith429(t *testing.T) {
+	t.Parallel()
+
+	h := rateLimited(RateLimit{RPS: 0.5, Burst: 1}, "test", http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/attestation/evidence", http.NoBody)
+	req.RemoteAddr = "192.0.2.1:1234"
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	req.RemoteAddr = "192.0.2.1:5678"
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, "2", rec.Header().Get("Retry-After"))
+}
+
+func TestRateLimitClientKey(t *testing.T) {
+	t.Parallel()
+
+	proxies, err := ParseTrustedProxies("10.0.0.0/8, 192.0.2.7")
+	assert.NoError(t, err)
+
+	cfg := RateLimit{ClientHeader: "X-Forwarded-For", TrustedProxies: proxies}
+
+	cases := []struct {
+		name   string
+		remote string
+		header string
+		want   string
+	}{
+		{name: "direct client", remote: "198.51.100.1:1234", want: "198.51.100.1"},
+		{name: "untrusted header", remote: "198.51.100.1:1234", header: "203.0.113.9", want: "198.51.100.1"},
+		{name: "trusted proxy", remote: "10.1.2.3:1234", header: "203.0.113.9", want: "203.0.113.9"},
+		{name: "proxy appends last", remote: "192.0.2.7:1234", header: "spoofed, 203.0.113.9", want: "203.0.113.9"},
+		{name: "proxy without header", remote: "10.1.2.3:1234", want: "10.1.2.3"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(http.MethodGet, "/attestation/evidence", http.NoBody)
+		req.RemoteAddr = tc.remote
+
+		if tc.header != "" {
+			req.Header.Set("X-Forwarded-For", tc.header)
+		}
+
+		assert.Equal(t, tc.want, cfg.clientKey(req), tc.name)
+	}
+
+	_, err = ParseTrustedProxies("not-an-ip")
+	assert.Error(t, err)
+}
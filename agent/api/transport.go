@@ -6,6 +6,7 @@ package api
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"net/http"
 
@@ -18,33 +19,58 @@ import (
 
 const ContentType = "application/json"
 
-func MakeHandler(svc agent.Service, instanceID string) http.Handler {
+func MakeHandler(svc agent.Service, instanceID string, limit RateLimit) http.Handler {
 	endpoints := endpoint.MakeEndpoints(svc)
 	mux := chi.NewRouter()
 
-	mux.Get("/health", healthHandler("cube-agent", instanceID))
+	mux.Get("/health", healthHandler(svc, "cube-agent", instanceID))
 	mux.Handle("/metrics", promhttp.Handler())
 
-	mux.Post("/attestation", kithttp.NewServer(
+	// POST /attestation is not rate limited: callers such as cube-proxy
+	// attest on behalf of every tenant and would share one bucket.
+	mux.Method(http.MethodPost, "/attestation", kithttp.NewServer(
 		endpoints.Attestation,
 		decodeAttestationRequest,
 		encodeAttestationResponse,
 		kithttp.ServerErrorEncoder(encodeError),
-	).ServeHTTP)
+	))
+
+	mux.Method(http.MethodGet, "/attestation/evidence", rateLimited(limit, "evidence", kithttp.NewServer(
+		endpoints.Evidence,
+		decodeEvidenceRequest,
+		encodeEvidenceResponse,
+		kithttp.ServerErrorEncoder(encodeError),
+	)))
 
 	mux.Handle("/*", svc.Proxy())
 
 	return mux
 }
 
-func healthHandler(service, instanceID string) http.HandlerFunc {
+// healthHandler reports "warn" when any upstream is unhealthy. The agent
+// itself is still serving, so the status code stays 200. Upstreams are probed
+// in the background by RunHealthChecks, so requests never reach them.
+func healthHandler(svc agent.Service, service, instanceID string) http.HandlerFunc {
 	return func(w http.ResponseWriter, _ *http.Request) {
+		upstreams := svc.CachedHealth()
+
+		status := "pass"
+
+		for _, u := range upstreams {
+			if !u.Healthy {
+				status = "warn"
+
+				break
+			}
+		}
+
 		w.Header().Set("Content-Type", ContentType)
 		w.WriteHeader(http.StatusOK)
-		_ = json.NewEncoder(w).Encode(map[string]string{
-			"status":      "pass",
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"status":      status,
 			"service":     service,
 			"instance_id": instanceID,
+			"upstreams":   upstreams,
 		})
 	}
 }
@@ -58,6 +84,36 @@ func decodeAttestationRequest(_ context.Context, r *http.Request) (any, error) {
 	return req, nil
 }
 
+func decodeEvidenceRequest(_ context.Context, r *http.Request) (any, error) {
+	raw := r.URL.Query().Get("nonce")
+	if raw == "" {
+		return endpoint.EvidenceRequest{}, nil
+	}
+
+	for _, enc := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawURLEncoding} {
+		if nonce, err := enc.DecodeString(raw); err == nil {
+			return endpoint.EvidenceRequest{Nonce: nonce}, nil
+		}
+	}
+
+	return nil, agent.ErrInvalidEvidenceNonce
+}
+
+func encodeEvidenceResponse(_ context.Context, w http.ResponseWriter, response any) error {
+	resp, ok := response.(endpoint.EvidenceResponse)
+	if !ok {
+		w.WriteHeader(http.StatusInternalServerError)
+
+		return json.NewEncoder(w).Encode(map[string]string{"error": "invalid response type"})
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+
+	return json.NewEncoder(w).Encode(resp.Bundle)
+}
+
 func encodeAttestationResponse(ctx context.Context, w http.ResponseWriter, response any) error {
 	resp, ok := response.(endpoint.AttestationResponse)
 	if !ok {
@@ -0,0 +1,50 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ultravioletrs/cocos/pkg/attestation"
+	"github.com/ultravioletrs/cube/agent"
+)
+
+func TestHealthDoesNotProbeUpstreams(t *testing.T) {
+	t.Parallel()
+
+	var probes atomic.Int32
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		probes.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(backend.Close)
+
+	svc, err := agent.New(&agent.Config{Upstreams: []agent.Upstream{
+		{Name: "up", URL: backend.URL},
+	}}, nil, attestation.NoCC)
+	require.NoError(t, err)
+
+	handler := MakeHandler(svc, "instance", RateLimit{})
+
+	for range 3 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health", http.NoBody))
+		require.Equal(t, http.StatusOK, rec.Code)
+
+		var body struct {
+			Status string `json:"status"`
+		}
+		require.NoError(t, json.NewDecoder(rec.Body).Decode(&body))
+		assert.Equal(t, "warn", body.Status)
+	}
+
+	assert.Zero(t, probes.Load())
+}
@@ -22,11 +22,13 @@ var (
 
 type Endpoints struct {
 	Attestation endpoint.Endpoint
+	Evidence    endpoint.Endpoint
 }
 
 func MakeEndpoints(s agent.Service) Endpoints {
 	return Endpoints{
 		Attestation: MakeAttestationEndpoint(s),
+		Evidence:    MakeEvidenceEndpoint(s),
 	}
 }
 
@@ -79,3 +81,29 @@ func MakeAttestationEndpoint(s agent.Service) endpoint.Endpoint {
 		return AttestationResponse{Report: report}, nil
 	}
 }
+
+// EvidenceRequest asks for the cached evidence bundle, or for fresh evidence
+// bound to Nonce when it is set.
+type EvidenceRequest struct {
+	Nonce []byte
+}
+
+type EvidenceResponse struct {
+	Bundle agent.EvidenceBundle
+}
+
+func MakeEvidenceEndpoint(s agent.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (any, error) {
+		req, ok := request.(EvidenceRequest)
+		if !ok {
+			return nil, errInvalidRequestType
+		}
+
+		bundle, err := s.Evidence(ctx, req.Nonce)
+		if err != nil {
+			return nil, err
+		}
+
+		return EvidenceResponse{Bundle: bundle}, nil
+	}
+}
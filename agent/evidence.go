@@ -0,0 +1,258 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ultravioletrs/cocos/pkg/attestation"
+	"github.com/ultravioletrs/cocos/pkg/attestation/quoteprovider"
+	"github.com/ultravioletrs/cocos/pkg/attestation/vtpm"
+)
+
+// DefaultEvidenceRefresh is how often the cached evidence bundle is
+// regenerated when EvidenceConfig.RefreshInterval is zero.
+const DefaultEvidenceRefresh = 5 * time.Minute
+
+// evidenceBindingLabel domain-separates evidence report data from report
+// data supplied directly to POST /attestation.
+const evidenceBindingLabel = "cube-agent-evidence-v1"
+
+var (
+	// ErrInvalidEvidenceNonce indicates that a verifier nonce has the wrong length.
+	ErrInvalidEvidenceNonce = fmt.Errorf("invalid nonce: must be %d bytes", vtpm.Nonce)
+	// ErrInvalidCertificate indicates that the TLS certificate could not be parsed.
+	ErrInvalidCertificate = errors.New("invalid TLS certificate")
+)
+
+// EvidenceConfig configures GET /attestation/evidence.
+type EvidenceConfig struct {
+	// RefreshInterval is how often the cached bundle is regenerated. Cached
+	// bundles are served for up to twice this interval, so one failed refresh
+	// does not take the endpoint down.
+	RefreshInterval time.Duration `json:"refresh_interval"`
+	// TLSKeyHash is the SHA-256 of the DER-encoded public key of the agent's
+	// TLS certificate. It is bound into the report data of every bundle.
+	TLSKeyHash []byte `json:"tls_key_hash"`
+}
+
+// EvidenceBundle is attestation evidence whose report data commits to the
+// agent's TLS key, the nonce and the issue time. Verifiers recompute the
+// report data with EvidenceReportData and compare it to the quote.
+type EvidenceBundle struct {
+	AttestationType string    `json:"attestation_type"`
+	IssuedAt        time.Time `json:"issued_at"`
+	// ExpiresAt is set for cached bundles only.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Cached    bool       `json:"cached"`
+	// Nonce is the verifier nonce, or a random nonce for cached bundles. It is
+	// also passed as the vTPM quote nonce.
+	Nonce        []byte `json:"nonce"`
+	TLSKeySHA256 string `json:"tls_key_sha256,omitempty"`
+	ReportData   []byte `json:"report_data"`
+	Evidence     []byte `json:"evidence"`
+}
+
+// EvidenceReportData derives the TEE report data for an evidence bundle.
+func EvidenceReportData(tlsKeyHash, nonce []byte, issuedAt time.Time) [quoteprovider.Nonce]byte {
+	var ts [8]byte
+	binary.BigEndian.PutUint64(ts[:], uint64(issuedAt.Unix()))
+
+	h := sha512.New()
+	h.Write([]byte(evidenceBindingLabel))
+	h.Write(tlsKeyHash)
+	h.Write(nonce)
+	h.Write(ts[:])
+
+	var out [quoteprovider.Nonce]byte
+	copy(out[:], h.Sum(nil))
+
+	return out
+}
+
+// TLSKeyHashFromCertFile returns the SHA-256 of the public key in the first
+// certificate of a PEM file.
+func TLSKeyHashFromCertFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TLS certificate: %w", err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%w: no PEM block in %s", ErrInvalidCertificate, path)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidCertificate, err)
+	}
+
+	sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+
+	return sum[:], nil
+}
+
+// PlatformName returns the attestation_type name of a platform.
+func PlatformName(p attestation.PlatformType) string {
+	switch p {
+	case attestation.SNP:
+		return "snp"
+	case attestation.VTPM:
+		return "vtpm"
+	case attestation.SNPvTPM:
+		return "snpvtpm"
+	case attestation.Azure:
+		return "azure"
+	case attestation.TDX:
+		return "tdx"
+	case attestation.NoCC:
+		return "nocc"
+	default:
+		return "unknown"
+	}
+}
+
+// evidenceCache holds the periodically refreshed bundle.
+type evidenceCache struct {
+	mu        sync.Mutex
+	refreshMu sync.Mutex
+	bundle    *EvidenceBundle
+}
+
+func (a *agentService) refreshInterval() time.Duration {
+	if a.config.Evidence.RefreshInterval > 0 {
+		return a.config.Evidence.RefreshInterval
+	}
+
+	return DefaultEvidenceRefresh
+}
+
+func (a *agentService) Evidence(ctx context.Context, nonce []byte) (EvidenceBundle, error) {
+	if nonce != nil {
+		if len(nonce) != vtpm.Nonce {
+			return EvidenceBundle{}, ErrInvalidEvidenceNonce
+		}
+
+		return a.generateEvidence(nonce, "nonce")
+	}
+
+	if bundle, ok := a.cachedEvidence(time.Now()); ok {
+		return bundle, nil
+	}
+
+	return a.RefreshEvidence(ctx)
+}
+
+func (a *agentService) cachedEvidence(now time.Time) (EvidenceBundle, bool) {
+	a.evidence.mu.Lock()
+	defer a.evidence.mu.Unlock()
+
+	if a.evidence.bundle == nil || !now.Before(*a.evidence.bundle.ExpiresAt) {
+		return EvidenceBundle{}, false
+	}
+
+	return *a.evidence.bundle, true
+}
+
+func (a *agentService) RefreshEvidence(_ context.Context) (EvidenceBundle, error) {
+	a.evidence.refreshMu.Lock()
+	defer a.evidence.refreshMu.Unlock()
+
+	// Another caller may have refreshed while we waited.
+	if bundle, ok := a.cachedEvidence(time.Now()); ok && time.Since(bundle.IssuedAt) < a.refreshInterval() {
+		return bundle, nil
+	}
+
+	nonce := make([]byte, vtpm.Nonce)
+	if _, err := rand.Read(nonce); err != nil {
+		return EvidenceBundle{}, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	bundle, err := a.generateEvidence(nonce, "cached")
+	if err != nil {
+		return EvidenceBundle{}, err
+	}
+
+	expiresAt := bundle.IssuedAt.Add(2 * a.refreshInterval())
+	bundle.ExpiresAt = &expiresAt
+	bundle.Cached = true
+
+	a.evidence.mu.Lock()
+	a.evidence.bundle = &bundle
+	a.evidence.mu.Unlock()
+
+	return bundle, nil
+}
+
+func (a *agentService) RunEvidenceRefresh(ctx context.Context) error {
+	if a.ccPlatform == attestation.NoCC {
+		return nil
+	}
+
+	ticker := time.NewTicker(a.refreshInterval())
+	defer ticker.Stop()
+
+	for {
+		if _, err := a.RefreshEvidence(ctx); err != nil {
+			log.Printf("Evidence refresh failed: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func (a *agentService) generateEvidence(nonce []byte, source string) (EvidenceBundle, error) {
+	issuedAt := time.Now().UTC().Truncate(time.Second)
+	reportData := EvidenceReportData(a.config.Evidence.TLSKeyHash, nonce, issuedAt)
+
+	var vtpmNonce [vtpm.Nonce]byte
+	copy(vtpmNonce[:], nonce)
+
+	raw, err := a.quote(source, reportData, vtpmNonce)
+	if err != nil {
+		return EvidenceBundle{}, err
+	}
+
+	bundle := EvidenceBundle{
+		AttestationType: PlatformName(a.ccPlatform),
+		IssuedAt:        issuedAt,
+		Nonce:           nonce,
+		ReportData:      reportData[:],
+		Evidence:        raw,
+	}
+	if len(a.config.Evidence.TLSKeyHash) > 0 {
+		bundle.TLSKeySHA256 = hex.EncodeToString(a.config.Evidence.TLSKeyHash)
+	}
+
+	return bundle, nil
+}
+
+// quote fetches a raw quote from the TEE and records its latency.
+func (a *agentService) quote(
+	source string, reportData [quoteprovider.Nonce]byte, nonce [vtpm.Nonce]byte,
+) ([]byte, error) {
+	start := time.Now()
+	raw, err := a.attestation(reportData, nonce, false)
+	observeQuote(PlatformName(a.ccPlatform), source, time.Since(start), err)
+
+	return raw, err
+}
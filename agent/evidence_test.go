@@ -0,0 +1,133 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package agent_test
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ultravioletrs/cocos/pkg/attestation"
+	"github.com/ultravioletrs/cube/agent"
+)
+
+// echoProvider returns the TEE report data as the quote so tests can check
+// what was bound into it.
+type echoProvider struct {
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *echoProvider) TeeAttestation(teeNonce []byte) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls++
+
+	return append([]byte(nil), teeNonce...), nil
+}
+
+func (p *echoProvider) Attestation(teeNonce, _ []byte) ([]byte, error) {
+	return p.TeeAttestation(teeNonce)
+}
+
+func (p *echoProvider) VTpmAttestation([]byte) ([]byte, error) { return nil, nil }
+
+func (p *echoProvider) AzureAttestationToken([]byte) ([]byte, error) { return nil, nil }
+
+func (p *echoProvider) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return p.calls
+}
+
+func newEvidenceAgent(t *testing.T, provider attestation.Provider, keyHash []byte) agent.Service {
+	t.Helper()
+
+	svc, err := agent.New(&agent.Config{
+		BackendURL: "http://localhost:11434",
+		Evidence:   agent.EvidenceConfig{RefreshInterval: time.Hour, TLSKeyHash: keyHash},
+	}, provider, attestation.SNP)
+	require.NoError(t, err)
+
+	return svc
+}
+
+func TestEvidenceIsCachedAndBoundToTLSKey(t *testing.T) {
+	t.Parallel()
+
+	provider := &echoProvider{}
+	keyHash := bytes.Repeat([]byte{0xab}, 32)
+	svc := newEvidenceAgent(t, provider, keyHash)
+
+	first, err := svc.Evidence(context.Background(), nil)
+	require.NoError(t, err)
+
+	second, err := svc.Evidence(context.Background(), nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 1, provider.count())
+	assert.Equal(t, first, second)
+	assert.True(t, first.Cached)
+	assert.Equal(t, "snp", first.AttestationType)
+	assert.Len(t, first.Nonce, 32)
+	require.NotNil(t, first.ExpiresAt)
+	assert.Equal(t, first.IssuedAt.Add(2*time.Hour), *first.ExpiresAt)
+
+	want := agent.EvidenceReportData(keyHash, first.Nonce, first.IssuedAt)
+	assert.Equal(t, want[:], first.ReportData)
+	assert.Equal(t, want[:], first.Evidence)
+	assert.Equal(t, "abababababababababababababababababababababababababababababababab", first.TLSKeySHA256)
+}
+
+func TestEvidenceWithNonceIsFresh(t *testing.T) {
+	t.Parallel()
+
+	provider := &echoProvider{}
+	svc := newEvidenceAgent(t, provider, nil)
+
+	nonce := bytes.Repeat([]byte{0x01}, 32)
+
+	for range 2 {
+		bundle, err := svc.Evidence(context.Background(), nonce)
+		require.NoError(t, err)
+
+		assert.False(t, bundle.Cached)
+		assert.Nil(t, bundle.ExpiresAt)
+		assert.Equal(t, nonce, bundle.Nonce)
+
+		want := agent.EvidenceReportData(nil, nonce, bundle.IssuedAt)
+		assert.Equal(t, want[:], bundle.Evidence)
+	}
+
+	assert.Equal(t, 2, provider.count())
+
+	_, err := svc.Evidence(context.Background(), []byte("short"))
+	assert.ErrorIs(t, err, agent.ErrInvalidEvidenceNonce)
+}
+
+func TestRefreshEvidenceReplacesCachedBundle(t *testing.T) {
+	t.Parallel()
+
+	provider := &echoProvider{}
+	svc, err := agent.New(&agent.Config{
+		BackendURL: "http://localhost:11434",
+		Evidence:   agent.EvidenceConfig{RefreshInterval: time.Nanosecond},
+	}, provider, attestation.SNP)
+	require.NoError(t, err)
+
+	first, err := svc.RefreshEvidence(context.Background())
+	require.NoError(t, err)
+
+	second, err := svc.RefreshEvidence(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, provider.count())
+	assert.NotEqual(t, first.Nonce, second.Nonce)
+}
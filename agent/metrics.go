@@ -0,0 +1,42 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	quoteDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cube_agent_quote_duration_seconds",
+			Help:    "Duration of TEE quote generation in seconds by platform, source and result.",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1, 2, 5, 10},
+		},
+		[]string{"platform", "source", "result"},
+	)
+	// RateLimited counts attestation requests rejected by the per-client limiter.
+	RateLimited = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cube_agent_attestation_rate_limited_total",
+			Help: "Total number of attestation requests rejected by the per-client rate limiter.",
+		},
+		[]string{"route"},
+	)
+)
+
+func init() {
+	prometheus.MustRegister(quoteDuration, RateLimited)
+}
+
+func observeQuote(platform, source string, duration time.Duration, err error) {
+	result := "ok"
+	if err != nil {
+		result = "error"
+	}
+
+	quoteDuration.WithLabelValues(platform, source, result).Observe(duration.Seconds())
+}
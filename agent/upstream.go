@@ -0,0 +1,305 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package agent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultUpstreamName names the upstream built from Config.BackendURL when no
+// explicit upstreams are configured.
+const DefaultUpstreamName = "default"
+
+const healthProbeTimeout = 3 * time.Second
+
+// DefaultHealthInterval is how often upstreams are probed when
+// Config.HealthInterval is zero.
+const DefaultHealthInterval = 15 * time.Second
+
+// errNotProbed is reported for upstreams before their first probe.
+const errNotProbed = "not probed yet"
+
+var (
+	// ErrInvalidUpstream indicates that an upstream definition is invalid.
+	ErrInvalidUpstream = errors.New("invalid upstream")
+	// ErrNoUpstreams indicates that neither upstreams nor a backend URL are configured.
+	ErrNoUpstreams = errors.New("no upstreams configured")
+)
+
+// Upstream is a named backend the agent reverse-proxies to.
+type Upstream struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+	// PathPrefixes selects the requests routed to this upstream. An upstream
+	// without prefixes is the default route for unmatched requests.
+	PathPrefixes []string `json:"path_prefixes"`
+	// StripPrefix removes the matched prefix before forwarding.
+	StripPrefix bool `json:"strip_prefix"`
+	// HealthPath is probed for /health; defaults to "/".
+	HealthPath string    `json:"health_path"`
+	TLS        TLSConfig `json:"tls"`
+}
+
+// UpstreamHealth is the result of probing one upstream.
+type UpstreamHealth struct {
+	Name       string `json:"name"`
+	URL        string `json:"url"`
+	Healthy    bool   `json:"healthy"`
+	StatusCode int    `json:"status_code,omitempty"`
+	LatencyMS  int64  `json:"latency_ms"`
+	Error      string `json:"error,omitempty"`
+	// CheckedAt is when the probe ran; zero before the first probe.
+	CheckedAt time.Time `json:"checked_at,omitzero"`
+}
+
+type upstream struct {
+	Upstream
+
+	target    *url.URL
+	transport *http.Transport
+	proxy     *httputil.ReverseProxy
+}
+
+type route struct {
+	prefix   string
+	upstream *upstream
+}
+
+// router dispatches requests to upstreams by longest matching path prefix.
+type router struct {
+	upstreams []*upstream
+	routes    []route
+	fallback  *upstream
+
+	mu         sync.RWMutex
+	lastHealth []UpstreamHealth
+}
+
+// upstreamsFromConfig returns the configured upstreams, falling back to a
+// single default upstream built from BackendURL and TLS.
+func upstreamsFromConfig(config *Config) ([]Upstream, error) {
+	if len(config.Upstreams) > 0 {
+		return config.Upstreams, nil
+	}
+
+	if config.BackendURL == "" {
+		return nil, ErrNoUpstreams
+	}
+
+	return []Upstream{{
+		Name: DefaultUpstreamName,
+		URL:  config.BackendURL,
+		TLS:  config.TLS,
+	}}, nil
+}
+
+func newRouter(defs []Upstream, modify func(*http.Request)) (*router, error) {
+	rt := &router{}
+	names := make(map[string]struct{}, len(defs))
+
+	for _, def := range defs {
+		def.Name = strings.TrimSpace(def.Name)
+		if def.Name == "" {
+			return nil, fmt.Errorf("%w: name is required", ErrInvalidUpstream)
+		}
+
+		if _, ok := names[def.Name]; ok {
+			return nil, fmt.Errorf("%w: duplicate name %q", ErrInvalidUpstream, def.Name)
+		}
+
+		names[def.Name] = struct{}{}
+
+		u, err := newUpstream(def, modify)
+		if err != nil {
+			return nil, err
+		}
+
+		rt.upstreams = append(rt.upstreams, u)
+
+		if len(def.PathPrefixes) == 0 {
+			if rt.fallback != nil {
+				return nil, fmt.Errorf("%w: %q and %q both have no path prefixes",
+					ErrInvalidUpstream, rt.fallback.Name, def.Name)
+			}
+
+			rt.fallback = u
+
+			continue
+		}
+
+		for _, prefix := range def.PathPrefixes {
+			prefix = normalizePrefix(prefix)
+			for _, existing := range rt.routes {
+				if existing.prefix == prefix {
+					return nil, fmt.Errorf("%w: prefix %q is claimed by %q and %q",
+						ErrInvalidUpstream, prefix, existing.upstream.Name, def.Name)
+				}
+			}
+
+			rt.routes = append(rt.routes, route{prefix: prefix, upstream: u})
+		}
+	}
+
+	sort.SliceStable(rt.routes, func(i, j int) bool {
+		return len(rt.routes[i].prefix) > len(rt.routes[j].prefix)
+	})
+
+	rt.lastHealth = make([]UpstreamHealth, len(rt.upstreams))
+	for i, u := range rt.upstreams {
+		rt.lastHealth[i] = UpstreamHealth{Name: u.Name, URL: u.URL, Error: errNotProbed}
+	}
+
+	return rt, nil
+}
+
+func newUpstream(def Upstream, modify func(*http.Request)) (*upstream, error) {
+	target, err := url.Parse(def.URL)
+	if err != nil || target.Scheme == "" || target.Host == "" {
+		return nil, fmt.Errorf("%w: %q has invalid url %q", ErrInvalidUpstream, def.Name, def.URL)
+	}
+
+	transport := &http.Transport{
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+
+	if def.TLS.Enabled {
+		tlsConfig, err := setTLSConfig(def.TLS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set TLS config for upstream %q: %w", def.Name, err)
+		}
+
+		transport.TLSClientConfig = tlsConfig
+	}
+
+	u := &upstream{Upstream: def, target: target, transport: transport}
+	u.proxy = &httputil.ReverseProxy{
+		Transport: transport,
+		Rewrite: func(req *httputil.ProxyRequest) {
+			req.SetURL(target)
+			modify(req.Out)
+			log.Printf("Agent forwarding to %s: %s %s", def.Name, req.Out.Method, req.Out.URL.Path)
+		},
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			log.Printf("Proxy error for upstream %s: %v", def.Name, err)
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+		},
+	}
+
+	return u, nil
+}
+
+// match returns the upstream for path and the prefix that selected it.
+func (rt *router) match(path string) (*upstream, string) {
+	for _, r := range rt.routes {
+		if r.prefix == "/" || path == r.prefix || strings.HasPrefix(path, r.prefix+"/") {
+			return r.upstream, r.prefix
+		}
+	}
+
+	return rt.fallback, ""
+}
+
+func (rt *router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	log.Printf("Agent received: %s %s", r.Method, r.URL.Path)
+
+	u, prefix := rt.match(r.URL.Path)
+	if u == nil {
+		http.Error(w, "no upstream for path", http.StatusNotFound)
+
+		return
+	}
+
+	if u.StripPrefix && prefix != "" && prefix != "/" {
+		r = r.Clone(r.Context())
+		r.URL.Path = "/" + strings.TrimLeft(strings.TrimPrefix(r.URL.Path, prefix), "/")
+		r.URL.RawPath = ""
+	}
+
+	u.proxy.ServeHTTP(w, r)
+}
+
+// health probes every upstream concurrently.
+func (rt *router) health(ctx context.Context) []UpstreamHealth {
+	results := make([]UpstreamHealth, len(rt.upstreams))
+
+	var wg sync.WaitGroup
+	for i, u := range rt.upstreams {
+		wg.Add(1)
+
+		go func(i int, u *upstream) {
+			defer wg.Done()
+
+			results[i] = u.probe(ctx)
+		}(i, u)
+	}
+
+	wg.Wait()
+
+	rt.mu.Lock()
+	rt.lastHealth = results
+	rt.mu.Unlock()
+
+	return results
+}
+
+// cachedHealth returns the results of the latest probe.
+func (rt *router) cachedHealth() []UpstreamHealth {
+	rt.mu.RLock()
+	defer rt.mu.RUnlock()
+
+	return append([]UpstreamHealth(nil), rt.lastHealth...)
+}
+
+func (u *upstream) probe(ctx context.Context) UpstreamHealth {
+	res := UpstreamHealth{Name: u.Name, URL: u.URL, CheckedAt: time.Now().UTC()}
+
+	ctx, cancel := context.WithTimeout(ctx, healthProbeTimeout)
+	defer cancel()
+
+	path := u.HealthPath
+	if path == "" {
+		path = "/"
+	}
+
+	probeURL := u.target.JoinPath(path)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, probeURL.String(), http.NoBody)
+	if err != nil {
+		res.Error = err.Error()
+
+		return res
+	}
+
+	start := time.Now()
+	resp, err := (&http.Client{Transport: u.transport}).Do(req)
+	res.LatencyMS = time.Since(start).Milliseconds()
+
+	if err != nil {
+		res.Error = err.Error()
+
+		return res
+	}
+	defer resp.Body.Close()
+
+	res.StatusCode = resp.StatusCode
+	res.Healthy = resp.StatusCode < http.StatusInternalServerError
+
+	return res
+}
+
+func normalizePrefix(prefix string) string {
+	return "/" + strings.Trim(strings.TrimSpace(prefix), "/")
+}
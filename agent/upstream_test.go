@@ -0,0 +1,196 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package agent_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ultravioletrs/cocos/pkg/attestation"
+	"github.com/ultravioletrs/cube/agent"
+)
+
+func newBackend(t *testing.T, name string, status int) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Backend", name)
+		w.Header().Set("X-Seen-Authorization", r.Header.Get("Authorization"))
+		w.WriteHeader(status)
+		_, _ = io.WriteString(w, r.URL.Path)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestProxyRoutesByLongestPrefix(t *testing.T) {
+	t.Parallel()
+
+	ollama := newBackend(t, "ollama", http.StatusOK)
+	vllm := newBackend(t, "vllm", http.StatusOK)
+	embed := newBackend(t, "embed", http.StatusOK)
+
+	svc, err := agent.New(&agent.Config{Upstreams: []agent.Upstream{
+		{Name: "ollama", URL: ollama.URL},
+		{Name: "vllm", URL: vllm.URL, PathPrefixes: []string{"/v1"}},
+		{Name: "embed", URL: embed.URL, PathPrefixes: []string{"/v1/embed/"}, StripPrefix: true},
+	}}, nil, attestation.NoCC)
+	require.NoError(t, err)
+
+	tests := []struct {
+		path        string
+		wantBackend string
+		wantPath    string
+	}{
+		{path: "/api/chat", wantBackend: "ollama", wantPath: "/api/chat"},
+		{path: "/v1/chat/completions", wantBackend: "vllm", wantPath: "/v1/chat/completions"},
+		{path: "/v1beta/models", wantBackend: "ollama", wantPath: "/v1beta/models"},
+		{path: "/v1/embed/batch", wantBackend: "embed", wantPath: "/batch"},
+		{path: "/v1/embed", wantBackend: "embed", wantPath: "/"},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPost, tt.path, http.NoBody)
+		req.Header.Set("Authorization", "Bearer secret")
+
+		rec := httptest.NewRecorder()
+		svc.Proxy().ServeHTTP(rec, req)
+
+		assert.Equal(t, http.StatusOK, rec.Code, tt.path)
+		assert.Equal(t, tt.wantBackend, rec.Header().Get("X-Backend"), tt.path)
+		assert.Equal(t, tt.wantPath, rec.Body.String(), tt.path)
+		assert.Empty(t, rec.Header().Get("X-Seen-Authorization"), tt.path)
+	}
+}
+
+func TestProxyWithoutDefaultUpstreamReturnsNotFound(t *testing.T) {
+	t.Parallel()
+
+	vllm := newBackend(t, "vllm", http.StatusOK)
+
+	svc, err := agent.New(&agent.Config{Upstreams: []agent.Upstream{
+		{Name: "vllm", URL: vllm.URL, PathPrefixes: []string{"/v1"}},
+	}}, nil, attestation.NoCC)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	svc.Proxy().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tags", http.NoBody))
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestNewFallsBackToBackendURL(t *testing.T) {
+	t.Parallel()
+
+	backend := newBackend(t, "legacy", http.StatusOK)
+
+	svc, err := agent.New(&agent.Config{BackendURL: backend.URL}, nil, attestation.NoCC)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	svc.Proxy().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/tags", http.NoBody))
+
+	assert.Equal(t, "legacy", rec.Header().Get("X-Backend"))
+
+	health := svc.Health(context.Background())
+	require.Len(t, health, 1)
+	assert.Equal(t, agent.DefaultUpstreamName, health[0].Name)
+}
+
+func TestNewRejectsInvalidUpstreams(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		upstreams []agent.Upstream
+	}{
+		{name: "missing name", upstreams: []agent.Upstream{{URL: "http://a"}}},
+		{name: "bad url", upstreams: []agent.Upstream{{Name: "a", URL: "localhost"}}},
+		{name: "duplicate name", upstreams: []agent.Upstream{
+			{Name: "a", URL: "http://a"},
+			{Name: "a", URL: "http://b", PathPrefixes: []string{"/b"}},
+		}},
+		{name: "two defaults", upstreams: []agent.Upstream{
+			{Name: "a", URL: "http://a"},
+			{Name: "b", URL: "http://b"},
+		}},
+		{name: "shared prefix", upstreams: []agent.Upstream{
+			{Name: "a", URL: "http://a", PathPrefixes: []string{"/v1"}},
+			{Name: "b", URL: "http://b", PathPrefixes: []string{"/v1/"}},
+		}},
+	}
+
+	for _, tt := range tests {
+		_, err := agent.New(&agent.Config{Upstreams: tt.upstreams}, nil, attestation.NoCC)
+		assert.ErrorIs(t, err, agent.ErrInvalidUpstream, tt.name)
+	}
+
+	_, err := agent.New(&agent.Config{}, nil, attestation.NoCC)
+	assert.ErrorIs(t, err, agent.ErrNoUpstreams)
+}
+
+func TestHealthProbesEveryUpstream(t *testing.T) {
+	t.Parallel()
+
+	up := newBackend(t, "up", http.StatusOK)
+	down := newBackend(t, "down", http.StatusServiceUnavailable)
+
+	svc, err := agent.New(&agent.Config{Upstreams: []agent.Upstream{
+		{Name: "up", URL: up.URL, HealthPath: "/api/version"},
+		{Name: "down", URL: down.URL, PathPrefixes: []string{"/v1"}},
+	}}, nil, attestation.NoCC)
+	require.NoError(t, err)
+
+	health := svc.Health(context.Background())
+	require.Len(t, health, 2)
+
+	assert.Equal(t, "up", health[0].Name)
+	assert.True(t, health[0].Healthy)
+	assert.Equal(t, http.StatusOK, health[0].StatusCode)
+
+	assert.Equal(t, "down", health[1].Name)
+	assert.False(t, health[1].Healthy)
+	assert.Equal(t, http.StatusServiceUnavailable, health[1].StatusCode)
+}
+
+func TestCachedHealthReportsLatestProbe(t *testing.T) {
+	t.Parallel()
+
+	var probes atomic.Int32
+
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		probes.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(backend.Close)
+
+	svc, err := agent.New(&agent.Config{Upstreams: []agent.Upstream{
+		{Name: "up", URL: backend.URL},
+	}}, nil, attestation.NoCC)
+	require.NoError(t, err)
+
+	cached := svc.CachedHealth()
+	require.Len(t, cached, 1)
+	assert.False(t, cached[0].Healthy)
+	assert.NotEmpty(t, cached[0].Error)
+	assert.Zero(t, probes.Load())
+
+	svc.Health(context.Background())
+
+	cached = svc.CachedHealth()
+	require.Len(t, cached, 1)
+	assert.True(t, cached[0].Healthy)
+	assert.False(t, cached[0].CheckedAt.IsZero())
+	assert.Equal(t, int32(1), probes.Load())
+
+	svc.CachedHealth()
+	assert.Equal(t, int32(1), probes.Load())
+}
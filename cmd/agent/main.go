@@ -5,6 +5,7 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"log"
 	"log/slog"
@@ -35,21 +36,28 @@ const (
 )
 
 type Config struct {
-	LogLevel       string        `env:"UV_CUBE_AGENT_LOG_LEVEL"   envDefault:"info"`
-	InstanceID     string        `env:"UV_CUBE_AGENT_INSTANCE_ID" envDefault:""`
-	AgentMaaURL    string        `env:"AGENT_MAA_URL"             envDefault:"https://sharedeus2.eus2.attest.azure.net"`
-	AgentOSBuild   string        `env:"AGENT_OS_BUILD"            envDefault:"UVC"`
-	AgentOSDistro  string        `env:"AGENT_OS_DISTRO"           envDefault:"UVC"`
-	AgentOSType    string        `env:"AGENT_OS_TYPE"             envDefault:"UVC"`
-	Vmpl           uint          `env:"AGENT_VMPL"                envDefault:"2"`
-	CAUrl          string        `env:"UV_CUBE_AGENT_CA_URL"      envDefault:""`
-	TargetURL      string        `env:"UV_CUBE_AGENT_TARGET_URL"  envDefault:"http://localhost:11434"`
-	CertsToken     string        `env:"UV_CUBE_AGENT_CERTS_TOKEN" envDefault:""`
-	CVMId          string        `env:"UV_CUBE_AGENT_CVM_ID"      envDefault:""`
-	EntityID       string        `env:"UV_CUBE_AGENT_ENTITY_ID"   envDefault:""`
-	AtomGRPCURL    string        `env:"ATOM_GRPC_URL"             envDefault:"atom:8081"`
-	AtomGraphQLURL string        `env:"ATOM_GRAPHQL_URL"          envDefault:"http://atom:8080/graphql"`
-	AtomTimeout    time.Duration `env:"ATOM_TIMEOUT"              envDefault:"15s"`
+	LogLevel         string        `env:"UV_CUBE_AGENT_LOG_LEVEL"         envDefault:"info"`
+	InstanceID       string        `env:"UV_CUBE_AGENT_INSTANCE_ID"       envDefault:""`
+	AgentMaaURL      string        `env:"AGENT_MAA_URL"                   envDefault:"https://sharedeus2.eus2.attest.azure.net"`
+	AgentOSBuild     string        `env:"AGENT_OS_BUILD"                  envDefault:"UVC"`
+	AgentOSDistro    string        `env:"AGENT_OS_DISTRO"                 envDefault:"UVC"`
+	AgentOSType      string        `env:"AGENT_OS_TYPE"                   envDefault:"UVC"`
+	Vmpl             uint          `env:"AGENT_VMPL"                      envDefault:"2"`
+	CAUrl            string        `env:"UV_CUBE_AGENT_CA_URL"            envDefault:""`
+	TargetURL        string        `env:"UV_CUBE_AGENT_TARGET_URL"        envDefault:"http://localhost:11434"`
+	Upstreams        string        `env:"UV_CUBE_AGENT_UPSTREAMS"         envDefault:""`
+	EvidenceRefresh  time.Duration `env:"UV_CUBE_AGENT_EVIDENCE_REFRESH"  envDefault:"5m"`
+	HealthInterval   time.Duration `env:"UV_CUBE_AGENT_HEALTH_INTERVAL"   envDefault:"15s"`
+	AttestationRPS   float64       `env:"UV_CUBE_AGENT_ATTESTATION_RPS"   envDefault:"1"`
+	AttestationBurst int           `env:"UV_CUBE_AGENT_ATTESTATION_BURST" envDefault:"5"`
+	ClientHeader     string        `env:"UV_CUBE_AGENT_CLIENT_HEADER"     envDefault:""`
+	TrustedProxies   string        `env:"UV_CUBE_AGENT_TRUSTED_PROXIES"   envDefault:""`
+	CertsToken       string        `env:"UV_CUBE_AGENT_CERTS_TOKEN"       envDefault:""`
+	CVMId            string        `env:"UV_CUBE_AGENT_CVM_ID"            envDefault:""`
+	EntityID         string        `env:"UV_CUBE_AGENT_ENTITY_ID"         envDefault:""`
+	AtomGRPCURL      string        `env:"ATOM_GRPC_URL"                   envDefault:"atom:8081"`
+	AtomGraphQLURL   string        `env:"ATOM_GRAPHQL_URL"                envDefault:"http://atom:8080/graphql"`
+	AtomTimeout      time.Duration `env:"ATOM_TIMEOUT"                    envDefault:"15s"`
 }
 
 func main() {
@@ -112,8 +120,34 @@ func main() {
 		return
 	}
 
+	upstreams, err := parseUpstreams(cfg.Upstreams)
+	if err != nil {
+		logger.Error(err.Error())
+		os.Exit(1)
+
+		return
+	}
+
 	config := agent.Config{
 		BackendURL: cfg.TargetURL,
+		Upstreams:  upstreams,
+		Evidence:   agent.EvidenceConfig{RefreshInterval: cfg.EvidenceRefresh},
+
+		HealthInterval: cfg.HealthInterval,
+	}
+
+	// Evidence binds to the static server certificate. With aTLS the
+	// certificates are per-connection and already carry their own evidence.
+	if certFile := httpServerConfig.CertFile; certFile != "" {
+		keyHash, err := agent.TLSKeyHashFromCertFile(certFile)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+
+			return
+		}
+
+		config.Evidence.TLSKeyHash = keyHash
 	}
 
 	svc, err := agent.New(&config, provider, ccPlatform)
@@ -127,7 +161,20 @@ func main() {
 	ctx, cancel := context.WithCancel(ctx)
 	g, ctx := errgroup.WithContext(ctx)
 
-	handler := api.MakeHandler(svc, cfg.InstanceID)
+	trustedProxies, err := api.ParseTrustedProxies(cfg.TrustedProxies)
+	if err != nil {
+		logger.Error(fmt.Sprintf("failed to parse trusted proxies: %s", err))
+		os.Exit(1)
+
+		return
+	}
+
+	handler := api.MakeHandler(svc, cfg.InstanceID, api.RateLimit{
+		RPS:            cfg.AttestationRPS,
+		Burst:          cfg.AttestationBurst,
+		ClientHeader:   cfg.ClientHeader,
+		TrustedProxies: trustedProxies,
+	})
 
 	certProvider, atomClient, err := setupCertProvider(provider, ccPlatform, cfg)
 	if err != nil {
@@ -149,6 +196,14 @@ func main() {
 		return httpSvr.Start()
 	})
 
+	g.Go(func() error {
+		return svc.RunEvidenceRefresh(ctx)
+	})
+
+	g.Go(func() error {
+		return svc.RunHealthChecks(ctx)
+	})
+
 	g.Go(func() error {
 		return server.StopHandler(ctx, cancel, logger, svcName, httpSvr)
 	})
@@ -192,6 +247,21 @@ func setupCertProvider(
 	return certProvider, atomClient, nil
 }
 
+// parseUpstreams decodes UV_CUBE_AGENT_UPSTREAMS, a JSON array of
+// agent.Upstream. An empty value keeps the single UV_CUBE_AGENT_TARGET_URL backend.
+func parseUpstreams(raw string) ([]agent.Upstream, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var upstreams []agent.Upstream
+	if err := json.Unmarshal([]byte(raw), &upstreams); err != nil {
+		return nil, fmt.Errorf("failed to parse UV_CUBE_AGENT_UPSTREAMS: %w", err)
+	}
+
+	return upstreams, nil
+}
+
 func newLogger(level string) *slog.Logger {
 	var slogLevel slog.Level
 	if err := slogLevel.UnmarshalText([]byte(strings.ToLower(level))); err != nil {
@@ -24,15 +24,23 @@ import (
 	"github.com/ultravioletrs/cube/internal/embedder/embedding"
 	"github.com/ultravioletrs/cube/internal/embedder/imageembedding"
 	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/confluence"
+	gitsource "github.com/ultravioletrs/cube/internal/embedder/ingest/sources/git"
 	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/google"
+	imapsource "github.com/ultravioletrs/cube/internal/embedder/ingest/sources/imap"
 	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/localfs"
 	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/microsoft"
 	s3source "github.com/ultravioletrs/cube/internal/embedder/ingest/sources/s3"
+	sqlsource "github.com/ultravioletrs/cube/internal/embedder/ingest/sources/sql"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/web"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/webhook"
 	"github.com/ultravioletrs/cube/internal/embedder/llm"
+	"github.com/ultravioletrs/cube/internal/embedder/llm/fallback"
 	"github.com/ultravioletrs/cube/internal/embedder/llm/guardrails"
 	"github.com/ultravioletrs/cube/internal/embedder/llm/ollama"
 	llmopenai "github.com/ultravioletrs/cube/internal/embedder/llm/openai"
 	"github.com/ultravioletrs/cube/internal/embedder/postgres"
+	"github.com/ultravioletrs/cube/internal/embedder/secrets"
 	"github.com/ultravioletrs/cube/internal/embedder/service"
 	objstore "github.com/ultravioletrs/cube/internal/embedder/storage"
 )
@@ -48,8 +56,18 @@ type config struct {
 	extractionConfig        ingest.ExtractionConfig
 	imageEmbeddingConfig    imageEmbeddingConfig
 	embeddingConfig         embedding.Config
+	vectorIndexConfig       postgres.VectorIndexConfig
 	llmConfig               llm.Config
+	llmProviders            []fallback.ProviderConfig
 	ollamaBaseURL           string
+	modelAllowedHosts       []string
+	sourceAllowedHosts      []string
+	sourceKEKFile           string
+	sourcePreviousKEKFiles  []string
+	gitCacheDir             string
+	publicURL               string
+	s3NotificationToken     string
+	subscriptionInterval    time.Duration
 	chatTopK                int
 	guardrailsURL           string
 	rerankerModel           string
@@ -63,6 +81,17 @@ type config struct {
 	ingestEmbedBatchSize    int
 	ingestRecordTimeout     time.Duration
 	ingestMaxChunks         int
+	ingestStreamThreshold   int
+	ingestLeaseDuration     time.Duration
+	ingestRetryPolicies     map[domain.IngestErrorClass]ingest.RetryPolicy
+	ingestDomainLimits      domain.IngestConcurrencyLimits
+	embeddingCache          bool
+	embeddingCacheTTL       time.Duration
+	enrichment              bool
+	enrichmentModel         string
+	enrichmentMaxInput      int
+	enrichmentTimeout       time.Duration
+	searchLanguages         domain.LanguageDefaults
 }
 
 type imageEmbeddingConfig struct {
@@ -82,7 +111,7 @@ func loadConfig() config {
 
 	embeddingConfig := embedding.Config{
 		Profiles: map[string]embedding.ProfileConfig{
-			// All local default profiles use 768-dim vectors to match the current pgvector schema.
+			// The local default profiles share one model, so a query is embedded once.
 			"text":  defaultProfile,
 			"code":  defaultProfile,
 			"image": defaultProfile,
@@ -94,6 +123,11 @@ func loadConfig() config {
 				domain.RecordFormatMD:    "text",
 				domain.RecordFormatPDF:   "text",
 				domain.RecordFormatDOCX:  "text",
+				domain.RecordFormatXLSX:  "text",
+				domain.RecordFormatPPTX:  "text",
+				domain.RecordFormatODT:   "text",
+				domain.RecordFormatODS:   "text",
+				domain.RecordFormatRTF:   "text",
 				domain.RecordFormatLink:  "text",
 				domain.RecordFormatCode:  "code",
 				domain.RecordFormatImage: "image",
@@ -103,6 +137,13 @@ func loadConfig() config {
 
 	loadEmbeddingConfigFromEnv(&embeddingConfig)
 
+	llmConfig := llm.Config{
+		Provider: env("EMBEDDER_LLM_PROVIDER", "ollama"),
+		BaseURL:  env("EMBEDDER_LLM_BASE_URL", "http://ollama:11434"),
+		Model:    env("EMBEDDER_LLM_MODEL", "llama3.2:3b"),
+		APIKey:   env("EMBEDDER_LLM_API_KEY", ""),
+	}
+
 	return config{
 		httpAddr:                env("EMBEDDER_HTTP_ADDR", ":8080"),
 		dbURL:                   mustEnv("EMBEDDER_DB_URL"),
@@ -124,6 +165,12 @@ func loadConfig() config {
 				ImageOCROnlyMinTextChars: envInt("EMBEDDER_OCR_IMAGE_OCR_ONLY_MIN_TEXT_CHARS", 1200),
 				MaxPDFPages:              envInt("EMBEDDER_OCR_MAX_PDF_PAGES", 20),
 			},
+			Archive: ingest.ArchiveConfig{
+				MaxDepth:      envInt("EMBEDDER_ARCHIVE_MAX_DEPTH", 3),
+				MaxEntries:    envInt("EMBEDDER_ARCHIVE_MAX_ENTRIES", 1000),
+				MaxEntryBytes: int64(envInt("EMBEDDER_ARCHIVE_MAX_ENTRY_MB", 64)) << 20,
+				MaxTotalBytes: int64(envInt("EMBEDDER_ARCHIVE_MAX_TOTAL_MB", 512)) << 20,
+			},
 		},
 		imageEmbeddingConfig: imageEmbeddingConfig{
 			URL:        env("EMBEDDER_IMAGE_EMBEDDING_URL", ""),
@@ -132,6 +179,15 @@ func loadConfig() config {
 			Timeout:    envDuration("EMBEDDER_IMAGE_EMBEDDING_TIMEOUT", 2*time.Minute),
 		},
 		embeddingConfig: embeddingConfig,
+		vectorIndexConfig: postgres.VectorIndexConfig{
+			Method:             env("EMBEDDER_VECTOR_INDEX", postgres.VectorIndexHNSW),
+			HNSWM:              envInt("EMBEDDER_VECTOR_HNSW_M", 16),
+			HNSWEfConstruction: envInt("EMBEDDER_VECTOR_HNSW_EF_CONSTRUCTION", 64),
+			IVFFlatLists:       envInt("EMBEDDER_VECTOR_IVFFLAT_LISTS", 100),
+			EfSearch:           envInt("EMBEDDER_VECTOR_EF_SEARCH", 100),
+			Probes:             envInt("EMBEDDER_VECTOR_IVFFLAT_PROBES", 10),
+			IterativeScan:      env("EMBEDDER_VECTOR_ITERATIVE_SCAN", "relaxed_order"),
+		},
 		storageConfig: objstore.Config{
 			Provider:          env("EMBEDDER_OBJECT_STORAGE_PROVIDER", objstore.ProviderLocal),
 			LocalDir:          env("EMBEDDER_UPLOAD_DIR", "/tmp/embedder/uploads"),
@@ -144,26 +200,62 @@ func loadConfig() config {
 			S3PathStyle:       envBool("EMBEDDER_S3_PATH_STYLE", true),
 			S3EnsureBucket:    envBool("EMBEDDER_S3_ENSURE_BUCKET", true),
 		},
-		llmConfig: llm.Config{
-			Provider: env("EMBEDDER_LLM_PROVIDER", "ollama"),
-			BaseURL:  env("EMBEDDER_LLM_BASE_URL", "http://ollama:11434"),
-			Model:    env("EMBEDDER_LLM_MODEL", "llama3.2:3b"),
-			APIKey:   env("EMBEDDER_LLM_API_KEY", ""),
+		llmConfig:              llmConfig,
+		llmProviders:           loadLLMProvidersFromEnv(llmConfig),
+		ollamaBaseURL:          env("EMBEDDER_OLLAMA_BASE_URL", "http://ollama:11434"),
+		modelAllowedHosts:      envList("EMBEDDER_MODEL_ALLOWED_HOSTS", domain.DefaultModelHosts),
+		sourceAllowedHosts:     envList("EMBEDDER_SOURCE_ALLOWED_HOSTS", nil),
+		sourceKEKFile:          env("EMBEDDER_SOURCE_KEK_FILE", ""),
+		sourcePreviousKEKFiles: envList("EMBEDDER_SOURCE_PREVIOUS_KEK_FILES", nil),
+		gitCacheDir:            env("EMBEDDER_GIT_CACHE_DIR", "/tmp/embedder/git"),
+		publicURL:              env("EMBEDDER_PUBLIC_URL", ""),
+		s3NotificationToken:    env("EMBEDDER_S3_NOTIFICATION_TOKEN", ""),
+		subscriptionInterval:   envDuration("EMBEDDER_SUBSCRIPTION_RECONCILE_INTERVAL", service.DefaultSubscriptionReconcileInterval),
+		chatTopK:               envInt("EMBEDDER_CHAT_TOP_K", 15),
+		guardrailsURL:          env("EMBEDDER_GUARDRAILS_URL", ""),
+		rerankerModel:          env("EMBEDDER_RERANKER_MODEL", ""),
+		rerankerBaseURL:        env("EMBEDDER_RERANKER_BASE_URL", ""),
+		chunkSize:              envInt("EMBEDDER_CHUNK_SIZE", 512),
+		chunkOverlap:           envInt("EMBEDDER_CHUNK_OVERLAP", 64),
+		ingestBatchSize:        envInt("EMBEDDER_INGEST_BATCH_SIZE", 20),
+		ingestMaxConcurrency:   envInt("EMBEDDER_INGEST_MAX_CONCURRENCY", 4),
+		ingestPollInterval:     envDuration("EMBEDDER_INGEST_POLL_INTERVAL", 3*time.Second),
+		ingestEmbedBatchSize:   envInt("EMBEDDER_INGEST_EMBED_BATCH_SIZE", 16),
+		ingestRecordTimeout:    envDuration("EMBEDDER_INGEST_RECORD_TIMEOUT", 2*time.Hour),
+		ingestMaxChunks:        envInt("EMBEDDER_INGEST_MAX_CHUNKS", 0),
+		ingestStreamThreshold:  envInt("EMBEDDER_INGEST_STREAM_THRESHOLD_BYTES", 64<<20),
+		ingestLeaseDuration:    envDuration("EMBEDDER_INGEST_LEASE_DURATION", 2*time.Minute),
+		ingestRetryPolicies:    loadRetryPolicies(),
+		embeddingCache:         envBool("EMBEDDER_EMBEDDING_CACHE", true),
+		embeddingCacheTTL:      envDuration("EMBEDDER_EMBEDDING_CACHE_TTL", 30*24*time.Hour),
+		enrichment:             envBool("EMBEDDER_ENRICHMENT", false),
+		enrichmentModel:        env("EMBEDDER_ENRICHMENT_MODEL", ""),
+		enrichmentMaxInput:     envInt("EMBEDDER_ENRICHMENT_MAX_INPUT_CHARS", 8000),
+		enrichmentTimeout:      envDuration("EMBEDDER_ENRICHMENT_TIMEOUT", 2*time.Minute),
+		ingestDomainLimits: domain.IngestConcurrencyLimits{
+			Default: envInt("EMBEDDER_INGEST_DOMAIN_MAX_CONCURRENCY", 0),
+			Domains: envIntMap("EMBEDDER_INGEST_DOMAIN_MAX_CONCURRENCY_OVERRIDES"),
 		},
-		ollamaBaseURL:        env("EMBEDDER_OLLAMA_BASE_URL", "http://ollama:11434"),
-		chatTopK:             envInt("EMBEDDER_CHAT_TOP_K", 15),
-		guardrailsURL:        env("EMBEDDER_GUARDRAILS_URL", ""),
-		rerankerModel:        env("EMBEDDER_RERANKER_MODEL", ""),
-		rerankerBaseURL:      env("EMBEDDER_RERANKER_BASE_URL", ""),
-		chunkSize:            envInt("EMBEDDER_CHUNK_SIZE", 512),
-		chunkOverlap:         envInt("EMBEDDER_CHUNK_OVERLAP", 64),
-		ingestBatchSize:      envInt("EMBEDDER_INGEST_BATCH_SIZE", 20),
-		ingestMaxConcurrency: envInt("EMBEDDER_INGEST_MAX_CONCURRENCY", 4),
-		ingestPollInterval:   envDuration("EMBEDDER_INGEST_POLL_INTERVAL", 3*time.Second),
-		ingestEmbedBatchSize: envInt("EMBEDDER_INGEST_EMBED_BATCH_SIZE", 16),
-		ingestRecordTimeout:  envDuration("EMBEDDER_INGEST_RECORD_TIMEOUT", 2*time.Hour),
-		ingestMaxChunks:      envInt("EMBEDDER_INGEST_MAX_CHUNKS", 0),
+		searchLanguages: domain.LanguageDefaults{
+			Default: strings.ToLower(env("EMBEDDER_SEARCH_DEFAULT_LANGUAGE", "en")),
+			Domains: envStringMap("EMBEDDER_SEARCH_DOMAIN_LANGUAGES"),
+		},
+	}
+}
+
+// loadRetryPolicies overrides the default retry policy of each ingest error
+// class from EMBEDDER_INGEST_RETRY_<CLASS>_{MAX_ATTEMPTS,BASE_DELAY,MAX_DELAY}.
+func loadRetryPolicies() map[domain.IngestErrorClass]ingest.RetryPolicy {
+	policies := ingest.DefaultRetryPolicies()
+	for class, policy := range policies {
+		prefix := "EMBEDDER_INGEST_RETRY_" + strings.ToUpper(string(class))
+		policies[class] = ingest.RetryPolicy{
+			MaxAttempts: envInt(prefix+"_MAX_ATTEMPTS", policy.MaxAttempts),
+			BaseDelay:   envDuration(prefix+"_BASE_DELAY", policy.BaseDelay),
+			MaxDelay:    envDuration(prefix+"_MAX_DELAY", policy.MaxDelay),
+		}
 	}
+	return policies
 }
 
 func profileEnvPrefix(name string) string {
@@ -178,6 +270,20 @@ func loadProfileFromEnv(name string, fallback embedding.ProfileConfig) embedding
 	profile.Model = env(prefix+"_MODEL", profile.Model)
 	profile.Dimensions = envInt(prefix+"_DIMENSIONS", profile.Dimensions)
 	profile.APIKey = env(prefix+"_API_KEY", profile.APIKey)
+	profile.Distance = domain.VectorDistance(env(prefix+"_DISTANCE", string(profile.Distance)))
+	// The previous model defaults to the current settings, so switching
+	// models usually only needs _PREVIOUS_MODEL and _PREVIOUS_DIMENSIONS.
+	if model := os.Getenv(prefix + "_PREVIOUS_MODEL"); model != "" {
+		previous := profile
+		previous.Previous = nil
+		previous.Provider = env(prefix+"_PREVIOUS_PROVIDER", profile.Provider)
+		previous.BaseURL = env(prefix+"_PREVIOUS_BASE_URL", profile.BaseURL)
+		previous.Model = model
+		previous.Dimensions = envInt(prefix+"_PREVIOUS_DIMENSIONS", profile.Dimensions)
+		previous.APIKey = env(prefix+"_PREVIOUS_API_KEY", profile.APIKey)
+		previous.Distance = domain.VectorDistance(env(prefix+"_PREVIOUS_DISTANCE", string(profile.Distance)))
+		profile.Previous = &previous
+	}
 	return profile
 }
 
@@ -220,6 +326,33 @@ func main() {
 	}
 	slog.Info("migrations applied")
 
+	sourceEnvelope, err := loadSourceEnvelope(cfg.sourceKEKFile, cfg.sourcePreviousKEKFiles)
+	if err != nil {
+		slog.Error("configure source credential encryption", "err", err)
+		os.Exit(1)
+	}
+	if sourceEnvelope == nil {
+		slog.Warn("source credentials are stored unencrypted and model providers cannot hold API keys; set EMBEDDER_SOURCE_KEK_FILE")
+	}
+
+	// `embedder reencrypt-sources` seals every source config and model
+	// provider API key under the current KEK and exits. Run it after adding
+	// a KEK or rotating one.
+	if len(os.Args) > 1 && os.Args[1] == "reencrypt-sources" {
+		n, err := postgres.ReencryptSourceConfigs(ctx, pool, sourceEnvelope)
+		if err != nil {
+			slog.Error("re-encrypt source credentials", "err", err)
+			os.Exit(1)
+		}
+		providers, err := postgres.ReencryptModelProviderKeys(ctx, pool, sourceEnvelope)
+		if err != nil {
+			slog.Error("re-encrypt model provider keys", "err", err)
+			os.Exit(1)
+		}
+		slog.Info("credentials re-encrypted", "sources", n, "model_providers", providers, "kek", sourceEnvelope.CurrentKEK())
+		return
+	}
+
 	// ── Auth (gRPC) ───────────────────────────────────────────────────────────
 
 	authenticator, authConn, err := auth.NewAuthenticator(cfg.authGRPCAddr)
@@ -232,9 +365,10 @@ func main() {
 
 	// ── Repositories & services ───────────────────────────────────────────────
 
-	sourcesRepo := postgres.NewSourcesRepository(pool)
+	sourcesRepo := postgres.NewSourcesRepository(pool, sourceEnvelope)
 	recordsRepo := postgres.NewRecordsRepository(pool)
 	chunksRepo := postgres.NewChunksRepository(pool)
+	chunksRepo.SetLanguageDefaults(cfg.searchLanguages)
 	imageEmbeddingsRepo := postgres.NewImageEmbeddingsRepository(pool)
 	conversationsRepo := postgres.NewConversationsRepository(pool)
 	uploadStore, err := objstore.NewStore(cfg.storageConfig)
@@ -242,28 +376,121 @@ func main() {
 		slog.Error("configure object storage", "provider", cfg.storageConfig.Provider, "err", err)
 		os.Exit(1)
 	}
+	// Deleting a record or source also deletes the files unpacked from its
+	// archives.
+	sourcesRepo = ingest.WithSourceArchiveCleanup(sourcesRepo, recordsRepo, uploadStore, cfg.objectKeyPrefix)
+	recordsRepo = ingest.WithArchiveCleanup(recordsRepo, uploadStore, cfg.objectKeyPrefix)
 
+	// Sources must not reach the embedder's own database.
+	sourceNetworkPolicy := domain.SourceNetworkPolicy{
+		AllowedHosts: cfg.sourceAllowedHosts,
+		DeniedHosts:  []string{pool.Config().ConnConfig.Host},
+	}
 	sourceProviders := ingest.NewSourceProviderRegistry(
 		google.NewSourceProvider(),
 		s3source.NewSourceProvider(),
 		microsoft.NewSourceProvider(),
 		localfs.NewSourceProvider(uploadStore),
+		web.NewSourceProvider(sourceNetworkPolicy),
+		gitsource.NewSourceProvider(cfg.gitCacheDir, sourceNetworkPolicy),
+		confluence.NewSourceProvider(sourceNetworkPolicy),
+		imapsource.NewSourceProvider(sourceNetworkPolicy),
+		sqlsource.NewSourceProvider(sourceNetworkPolicy),
+		webhook.NewSourceProvider(uploadStore),
 	)
 	for alias, target := range domain.SourceProviderAliases() {
 		sourceProviders.RegisterAlias(alias, target)
 	}
+	defer sourceProviders.Close()
+	sourcesRepo = ingest.WithSourceRelease(sourcesRepo, sourceProviders)
 
-	sourcesSvc := service.NewSourcesService(sourcesRepo)
+	sourcesSvc := service.NewSourcesService(sourcesRepo, sourceNetworkPolicy)
 	sourceSyncSvc := service.NewSourceSyncService(sourcesRepo, recordsRepo, sourceProviders)
+	webhookSvc := service.NewWebhookService(sourcesRepo, recordsRepo, uploadStore)
 	recordsSvc := service.NewRecordsService(recordsRepo)
 	embeddingRegistry, err := embedding.NewRegistry(cfg.embeddingConfig)
 	if err != nil {
 		slog.Error("configure embeddings", "err", err)
 		os.Exit(1)
 	}
+	defaultProfile := embeddingRegistry.Default()
+	adopted, unprofiled, err := chunksRepo.AdoptUnprofiledEmbeddings(ctx, defaultProfile.Name, defaultProfile.Dimensions())
+	if err != nil {
+		slog.Error("adopt unprofiled embeddings", "err", err)
+		os.Exit(1)
+	}
+	if adopted > 0 {
+		slog.Info("assigned legacy chunks to default embedding profile", "profile", defaultProfile.Name, "chunks", adopted)
+	}
+	if unprofiled > 0 {
+		slog.Warn("legacy chunks do not match the default embedding profile dimensions and are not searchable until re-ingested",
+			"profile", defaultProfile.Name, "dimensions", defaultProfile.Dimensions(), "chunks", unprofiled)
+	}
+	// Chunks stored before chunks recorded their model were embedded with
+	// the previous model when one is configured, otherwise the current one.
+	for _, name := range embeddingRegistry.ProfileNames() {
+		var models []embedding.Profile
+		if previous, ok := embeddingRegistry.Previous(name); ok {
+			models = append(models, previous)
+		}
+		current, err := embeddingRegistry.Profile(name)
+		if err != nil {
+			slog.Error("configure embeddings", "err", err)
+			os.Exit(1)
+		}
+		for _, profile := range append(models, current) {
+			labelled, err := chunksRepo.LabelEmbeddingModels(ctx, profile.Name, profile.Model, profile.Dimensions())
+			if err != nil {
+				slog.Error("label embedding models", "err", err)
+				os.Exit(1)
+			}
+			if labelled > 0 {
+				slog.Info("recorded embedding model of legacy chunks", "profile", profile.Name, "model", profile.Model, "chunks", labelled)
+			}
+		}
+	}
+	if err := chunksRepo.ConfigureVectorIndex(ctx, cfg.vectorIndexConfig); err != nil {
+		slog.Error("configure vector index", "err", err)
+		os.Exit(1)
+	}
+	var vectorIndexSpecs []postgres.VectorIndexSpec
+	for _, group := range embeddingRegistry.SearchGroups() {
+		for _, profile := range group.Profiles {
+			vectorIndexSpecs = append(vectorIndexSpecs, postgres.VectorIndexSpec{
+				Profile:    profile,
+				Model:      group.Model,
+				Dimensions: group.Dimensions(),
+				Distance:   group.Distance,
+			})
+		}
+	}
+	// Index builds can take minutes on a large table; searches fall back to
+	// exact scans until they finish.
+	go func() {
+		if err := chunksRepo.EnsureVectorIndexes(ctx, vectorIndexSpecs); err != nil {
+			slog.Error("ensure vector indexes", "err", err)
+			return
+		}
+		slog.Info("vector indexes ready", "method", cfg.vectorIndexConfig.Method, "profiles", len(vectorIndexSpecs))
+	}()
+	// Chunks stored before languages were detected are keyword-searched as
+	// English until they are backfilled.
+	go func() {
+		backfilled, err := chunksRepo.BackfillChunkLanguages(ctx)
+		if err != nil {
+			slog.Error("backfill chunk languages", "err", err)
+			return
+		}
+		if backfilled > 0 {
+			slog.Info("detected language of legacy chunks", "chunks", backfilled)
+		}
+	}()
 
+	ingestJobsRepo := postgres.NewIngestJobsRepository(pool)
 	worker := ingest.NewWorker(
 		recordsRepo,
+		ingestJobsRepo,
+		workerID(),
 		sourcesRepo,
 		chunksRepo,
 		embeddingRegistry,
@@ -277,6 +504,16 @@ func main() {
 	worker.SetEmbedBatchSize(cfg.ingestEmbedBatchSize)
 	worker.SetRecordTimeout(cfg.ingestRecordTimeout)
 	worker.SetMaxChunks(cfg.ingestMaxChunks)
+	worker.SetStreamThreshold(int64(cfg.ingestStreamThreshold))
+	worker.SetLeaseDuration(cfg.ingestLeaseDuration)
+	worker.SetDomainConcurrency(cfg.ingestDomainLimits)
+	if cfg.embeddingCache {
+		worker.SetEmbeddingCache(postgres.NewEmbeddingCache(pool), cfg.embeddingCacheTTL)
+	}
+	for class, policy := range cfg.ingestRetryPolicies {
+		worker.SetRetryPolicy(class, policy)
+	}
+	worker.SetArchiveStore(uploadStore, cfg.objectKeyPrefix)
 	var imageEmbeddingClient *imageembedding.Client
 	if strings.TrimSpace(cfg.imageEmbeddingConfig.URL) != "" {
 		imageEmbeddingClient = imageembedding.New(
@@ -296,18 +533,59 @@ func main() {
 			"dimensions", cfg.imageEmbeddingConfig.Dimensions,
 		)
 	}
-	go worker.Run(ctx)
+
+	// Change notifications turn provider pushes into targeted syncs.
+	// Subscriptions are only registered when the embedder has a public URL
+	// for providers to call back.
+	subscriptionsRepo := postgres.NewSourceSubscriptionsRepository(pool)
+	notificationSvc := service.NewNotificationService(
+		subscriptionsRepo,
+		sourceSyncSvc,
+		sourceProviders,
+		worker.Trigger,
+		cfg.s3NotificationToken,
+	)
+	go notificationSvc.Run(ctx)
+	if cfg.publicURL != "" {
+		subscriptionManager := service.NewSubscriptionManager(sourcesRepo, subscriptionsRepo, sourceProviders, cfg.publicURL)
+		subscriptionManager.SetInterval(cfg.subscriptionInterval)
+		go subscriptionManager.Run(ctx)
+		slog.Info("change notification subscriptions enabled", "public_url", cfg.publicURL)
+	}
+
+	reembedManager := service.NewReembedManager(postgres.NewReembedJobsRepository(pool), chunksRepo, embeddingRegistry)
+	go reembedManager.Run(ctx)
 
 	retrieveSvc := service.NewMultimodalRetrieveService(chunksRepo, imageEmbeddingsRepo, embeddingRegistry, imageEmbeddingClient)
 
 	// ── LLM client & chat service ─────────────────────────────────────────────
 
+	// providerFactory builds an unguarded client for a single provider.
+	providerFactory := llm.ClientFactory(func(llmCfg llm.Config) llm.Client {
+		if llmCfg.Provider == "openai" {
+			return llmopenai.NewFromConfig(llmCfg)
+		}
+		if llmCfg.BaseURL == "" {
+			llmCfg.BaseURL = cfg.ollamaBaseURL
+		}
+		return ollama.NewFromConfig(llmCfg)
+	})
+
+	// The default client tries each configured provider in order, retrying
+	// transient failures before the first token is streamed.
 	var llmClient llm.Client
-	switch cfg.llmConfig.Provider {
-	case "openai":
-		llmClient = llmopenai.New(cfg.llmConfig.BaseURL, cfg.llmConfig.Model, cfg.llmConfig.APIKey)
-	default:
-		llmClient = ollama.New(cfg.llmConfig.BaseURL, cfg.llmConfig.Model)
+	llmChain, err := fallback.New(providerFactory, cfg.llmProviders)
+	if err != nil {
+		slog.Error("configure llm providers", "err", err)
+		os.Exit(1)
+	}
+	llmClient = llmChain
+	if len(cfg.llmProviders) > 1 {
+		names := make([]string, 0, len(cfg.llmProviders))
+		for _, p := range cfg.llmProviders {
+			names = append(names, p.DisplayName())
+		}
+		slog.Info("llm fallback chain enabled", "providers", names)
 	}
 
 	var guardrailsCtrl *guardrails.Controller
@@ -317,17 +595,26 @@ func main() {
 		slog.Info("guardrails enabled", "url", cfg.guardrailsURL)
 	}
 
+	// Enrichment reads indexed documents, not user prompts, so it uses the
+	// provider chain without guardrails.
+	if cfg.enrichment {
+		enrichClient, enrichModel := llm.Client(llmChain), cfg.llmConfig.Model
+		if cfg.enrichmentModel != "" {
+			enrichCfg := cfg.llmConfig
+			enrichCfg.Model = cfg.enrichmentModel
+			enrichClient, enrichModel = providerFactory(enrichCfg), cfg.enrichmentModel
+		}
+		enricher := ingest.NewEnricher(enrichClient, enrichModel)
+		enricher.SetMaxInputChars(cfg.enrichmentMaxInput)
+		enricher.SetTimeout(cfg.enrichmentTimeout)
+		worker.SetEnricher(enricher)
+		slog.Info("ingest enrichment enabled", "model", enrichModel)
+	}
+	go worker.Run(ctx)
+
 	// clientFactory builds a per-request LLM client when the caller overrides the model.
 	clientFactory := llm.ClientFactory(func(llmCfg llm.Config) llm.Client {
-		var client llm.Client
-		if llmCfg.Provider == "openai" {
-			client = llmopenai.NewFromConfig(llmCfg)
-		} else {
-			if llmCfg.BaseURL == "" {
-				llmCfg.BaseURL = cfg.ollamaBaseURL
-			}
-			client = ollama.NewFromConfig(llmCfg)
-		}
+		client := providerFactory(llmCfg)
 		if guardrailsCtrl != nil {
 			return guardrailsCtrl.Wrap(client)
 		}
@@ -344,7 +631,10 @@ func main() {
 		slog.Info("reranker enabled", "model", cfg.rerankerModel, "base_url", rerankerBase)
 	}
 
-	chatSvc := service.NewChatService(retrieveSvc, llmClient, reranker, cfg.chatTopK, cfg.llmConfig, cfg.ollamaBaseURL, clientFactory)
+	modelURLPolicy := domain.ModelURLPolicy{AllowedHosts: cfg.modelAllowedHosts, OllamaBaseURL: cfg.ollamaBaseURL}
+	modelProvidersSvc := service.NewModelProvidersService(postgres.NewModelProvidersRepository(pool), sourceEnvelope, modelURLPolicy)
+
+	chatSvc := service.NewChatService(retrieveSvc, llmClient, reranker, cfg.chatTopK, cfg.llmConfig, cfg.ollamaBaseURL, clientFactory, modelProvidersSvc, modelURLPolicy)
 
 	// ── HTTP server ───────────────────────────────────────────────────────────
 
@@ -352,16 +642,22 @@ func main() {
 		authenticator,
 		sourcesSvc,
 		sourceSyncSvc,
+		webhookSvc,
+		notificationSvc,
 		recordsSvc,
 		retrieveSvc,
 		chatSvc,
+		modelProvidersSvc,
+		reembedManager,
+		service.NewIngestJobsService(ingestJobsRepo),
 		conversationsRepo,
 		uploadStore,
 		cfg.objectKeyPrefix,
 		worker.Trigger,
 		cfg.googleOAuthClientID,
 		cfg.googleOAuthClientSecret,
-		cfg.ollamaBaseURL,
+		modelURLPolicy,
+		sourceNetworkPolicy,
 		guardrailsCtrl,
 	)
 	srv := &http.Server{
@@ -392,6 +688,28 @@ func main() {
 	}
 }
 
+// loadSourceEnvelope builds the envelope that seals source credentials and
+// model provider API keys from KEK files. Without a current KEK file source
+// credentials are stored as plain JSON.
+func loadSourceEnvelope(current string, previous []string) (*secrets.Envelope, error) {
+	if strings.TrimSpace(current) == "" {
+		return nil, nil
+	}
+	currentKEK, err := secrets.LoadKEKFile(current)
+	if err != nil {
+		return nil, err
+	}
+	previousKEKs := make([]secrets.KEK, 0, len(previous))
+	for _, path := range previous {
+		kek, err := secrets.LoadKEKFile(path)
+		if err != nil {
+			return nil, err
+		}
+		previousKEKs = append(previousKEKs, kek)
+	}
+	return secrets.NewEnvelope(currentKEK, previousKEKs...)
+}
+
 func envInt(key string, fallback int) int {
 	if v := os.Getenv(key); v != "" {
 		n, err := strconv.Atoi(v)
@@ -404,6 +722,15 @@ func envInt(key string, fallback int) int {
 	return fallback
 }
 
+// workerID names this replica as the owner of the ingest jobs it leases.
+func workerID() string {
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "embedder"
+	}
+	return host + "-" + strconv.Itoa(os.Getpid())
+}
+
 func envDuration(key string, fallback time.Duration) time.Duration {
 	if v := os.Getenv(key); v != "" {
 		d, err := time.ParseDuration(v)
@@ -431,6 +758,50 @@ func envBool(key string, fallback bool) bool {
 	return fallback
 }
 
+func envList(key string, fallback []string) []string {
+	v := os.Getenv(key)
+	if strings.TrimSpace(v) == "" {
+		return fallback
+	}
+	var out []string
+	for _, item := range strings.Split(v, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
+// envIntMap parses a comma-separated list of key=integer pairs.
+func envIntMap(key string) map[string]int {
+	out := make(map[string]int)
+	for _, item := range envList(key, nil) {
+		k, v, ok := strings.Cut(item, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(v))
+		if !ok || strings.TrimSpace(k) == "" || err != nil {
+			fmt.Fprintf(os.Stderr, "invalid key=integer pair for %s: %q\n", key, item)
+			os.Exit(1)
+		}
+		out[strings.TrimSpace(k)] = n
+	}
+	return out
+}
+
+// envStringMap parses a comma-separated list of key=value pairs.
+func envStringMap(key string) map[string]string {
+	out := make(map[string]string)
+	for _, item := range envList(key, nil) {
+		k, v, ok := strings.Cut(item, "=")
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		if !ok || k == "" || v == "" {
+			fmt.Fprintf(os.Stderr, "invalid key=value pair for %s: %q\n", key, item)
+			os.Exit(1)
+		}
+		out[k] = strings.ToLower(v)
+	}
+	return out
+}
+
 func env(key, fallback string) string {
 	if v := os.Getenv(key); v != "" {
 		return v
@@ -487,6 +858,31 @@ func runOCRPreflight(ctx context.Context, cfg ingest.ExtractionConfig) error {
 	return nil
 }
 
+// loadLLMProvidersFromEnv returns the ordered LLM fallback chain. The
+// EMBEDDER_LLM_* settings are always the primary provider; entries from
+// EMBEDDER_LLM_FALLBACKS (a JSON array) are tried after it.
+func loadLLMProvidersFromEnv(primary llm.Config) []fallback.ProviderConfig {
+	providers := []fallback.ProviderConfig{{
+		Name:       env("EMBEDDER_LLM_NAME", ""),
+		Provider:   primary.Provider,
+		BaseURL:    primary.BaseURL,
+		Model:      primary.Model,
+		APIKey:     primary.APIKey,
+		Timeout:    fallback.Duration(envDuration("EMBEDDER_LLM_TIMEOUT", 0)),
+		MaxRetries: envInt("EMBEDDER_LLM_MAX_RETRIES", 1),
+	}}
+
+	if raw := os.Getenv("EMBEDDER_LLM_FALLBACKS"); raw != "" {
+		var fallbacks []fallback.ProviderConfig
+		if err := json.Unmarshal([]byte(raw), &fallbacks); err != nil {
+			fmt.Fprintf(os.Stderr, "invalid EMBEDDER_LLM_FALLBACKS: %v\n", err)
+			os.Exit(1)
+		}
+		providers = append(providers, fallbacks...)
+	}
+	return providers
+}
+
 func loadEmbeddingConfigFromEnv(cfg *embedding.Config) {
 	for name, profile := range cfg.Profiles {
 		cfg.Profiles[name] = loadProfileFromEnv(name, profile)
@@ -20,16 +20,22 @@ func NewRouter(
 	authenticator *auth.Authenticator,
 	sourcesSvc domain.SourceService,
 	sourceSyncSvc domain.SourceSyncService,
+	webhookSvc domain.WebhookService,
+	notificationSvc domain.SourceNotificationService,
 	recordsSvc domain.RecordService,
 	retrieveSvc domain.VectorRetrieveService,
 	chatSvc domain.ChatService,
+	modelProvidersSvc domain.ModelProviderService,
+	reembedSvc domain.ReembedService,
+	ingestJobsSvc domain.IngestJobService,
 	conversationsRepo domain.ConversationRepository,
 	store objstore.Store,
 	objectKeyPrefix string,
 	trigger func(),
 	googleOAuthClientID string,
 	googleOAuthClientSecret string,
-	ollamaBaseURL string,
+	modelURLPolicy domain.ModelURLPolicy,
+	sourceNetworkPolicy domain.SourceNetworkPolicy,
 	guardrailsCtrl transport.GuardrailsController,
 ) http.Handler {
 	r := chi.NewRouter()
@@ -41,17 +47,27 @@ func NewRouter(
 	r.Get("/health", healthHandler)
 	r.Handle("/metrics", promhttp.Handler())
 
-	transport.MountModels(r, ollamaBaseURL)
+	transport.MountModels(r, modelURLPolicy.OllamaBaseURL)
+	transport.MountWebhooks(r, webhookSvc, trigger)
+	if notificationSvc != nil {
+		transport.MountNotifications(r, notificationSvc)
+	}
 
 	r.Group(func(r chi.Router) {
 		r.Use(auth.Middleware(authenticator))
-		transport.MountSources(r, sourcesSvc, sourceSyncSvc, trigger, googleOAuthClientID, googleOAuthClientSecret)
+		transport.MountSources(
+			r, sourcesSvc, sourceSyncSvc, trigger, googleOAuthClientID, googleOAuthClientSecret,
+			auth.RequireAction(authenticator, auth.ActionManage), sourceNetworkPolicy,
+		)
 		transport.MountRecords(r, recordsSvc, sourcesSvc, store, objectKeyPrefix, trigger)
 		transport.MountRetrieve(r, retrieveSvc)
 		transport.MountChat(r, chatSvc, conversationsRepo)
 		transport.MountConversations(r, conversationsRepo)
 		transport.MountGuardrails(r, guardrailsCtrl)
-		transport.MountModelConnection(r, ollamaBaseURL)
+		transport.MountModelConnection(r, modelURLPolicy)
+		transport.MountModelCatalogue(r, modelProvidersSvc, modelURLPolicy.OllamaBaseURL, auth.RequireAction(authenticator, auth.ActionManage))
+		transport.MountReembedJobs(r, reembedSvc, auth.RequireAction(authenticator, auth.ActionManage))
+		transport.MountIngestJobs(r, ingestJobsSvc, trigger, auth.RequireAction(authenticator, auth.ActionManage))
 	})
 
 	return r
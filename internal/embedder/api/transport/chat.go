@@ -5,6 +5,7 @@ package transport
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -72,7 +73,14 @@ func chatHandler(svc domain.ChatService, conversations domain.ConversationReposi
 
 		events, err := svc.Chat(r.Context(), domainID, req.Messages, req.RecordIDs, req.Model, req.Debug)
 		if err != nil {
-			writeJSON(w, http.StatusInternalServerError, errBody("chat failed: "+err.Error()))
+			switch {
+			case errors.Is(err, domain.ErrModelNotAllowed):
+				writeJSON(w, http.StatusBadRequest, errBody(err.Error()))
+			case errors.Is(err, domain.ErrNotFound):
+				writeJSON(w, http.StatusNotFound, errBody("model provider not found"))
+			default:
+				writeJSON(w, http.StatusInternalServerError, errBody("chat failed: "+err.Error()))
+			}
 			return
 		}
 
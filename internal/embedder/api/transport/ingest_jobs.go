@@ -0,0 +1,138 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package transport
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ultravioletrs/cube/internal/embedder/auth"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+// MountIngestJobs registers the ingest queue endpoints. All of them are
+// wrapped with admin, the domain-admin permission check.
+func MountIngestJobs(r chi.Router, svc domain.IngestJobService, trigger func(), admin func(http.Handler) http.Handler) {
+	r.Group(func(r chi.Router) {
+		r.Use(admin)
+		r.Get("/api/v1/ingest-jobs", listIngestJobs(svc))
+		r.Get("/api/v1/ingest-jobs/{id}", getIngestJob(svc))
+		r.Post("/api/v1/ingest-jobs/{id}/requeue", requeueIngestJob(svc, trigger))
+	})
+}
+
+// ingestJobResponse is the JSON shape returned by ingest job endpoints.
+type ingestJobResponse struct {
+	ID             string `json:"id"`
+	RecordID       string `json:"record_id"`
+	Status         string `json:"status"`
+	Priority       string `json:"priority"`
+	Attempts       int    `json:"attempts"`
+	RunAfter       string `json:"run_after"`
+	LeaseOwner     string `json:"lease_owner,omitempty"`
+	LeaseExpiresAt string `json:"lease_expires_at,omitempty"`
+	HeartbeatAt    string `json:"heartbeat_at,omitempty"`
+	LastError      string `json:"last_error,omitempty"`
+	ErrorClass     string `json:"error_class,omitempty"`
+	CreatedAt      string `json:"created_at"`
+	UpdatedAt      string `json:"updated_at"`
+}
+
+type ingestJobListResponse struct {
+	Jobs   []ingestJobResponse `json:"jobs"`
+	Total  uint64              `json:"total"`
+	Offset uint64              `json:"offset"`
+	Limit  uint64              `json:"limit"`
+}
+
+func toIngestJobResponse(j domain.IngestJob) ingestJobResponse {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return ingestJobResponse{
+		ID:             j.ID,
+		RecordID:       j.RecordID,
+		Status:         string(j.Status),
+		Priority:       j.Priority.String(),
+		Attempts:       j.Attempts,
+		RunAfter:       formatTime(&j.RunAfter),
+		LeaseOwner:     j.LeaseOwner,
+		LeaseExpiresAt: formatTime(j.LeaseExpiresAt),
+		HeartbeatAt:    formatTime(j.HeartbeatAt),
+		LastError:      j.LastError,
+		ErrorClass:     string(j.ErrorClass),
+		CreatedAt:      formatTime(&j.CreatedAt),
+		UpdatedAt:      formatTime(&j.UpdatedAt),
+	}
+}
+
+func listIngestJobs(svc domain.IngestJobService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		p := parsePage(r)
+		var f domain.IngestJobFilter
+		if v := r.URL.Query().Get("status"); v != "" {
+			status := domain.IngestJobStatus(v)
+			if !status.Valid() {
+				writeJSON(w, http.StatusBadRequest, errBody("invalid status"))
+				return
+			}
+			f.Status = &status
+		}
+
+		page, err := svc.List(r.Context(), auth.DomainID(r.Context()), f, p)
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errBody("internal error"))
+			return
+		}
+		resp := ingestJobListResponse{
+			Jobs:   make([]ingestJobResponse, 0, len(page.Jobs)),
+			Total:  page.Total,
+			Offset: p.Offset,
+			Limit:  p.Limit,
+		}
+		for _, j := range page.Jobs {
+			resp.Jobs = append(resp.Jobs, toIngestJobResponse(j))
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+func getIngestJob(svc domain.IngestJobService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := svc.Get(r.Context(), chi.URLParam(r, "id"), auth.DomainID(r.Context()))
+		if err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				writeJSON(w, http.StatusNotFound, errBody("ingest job not found"))
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, errBody("internal error"))
+			return
+		}
+		writeJSON(w, http.StatusOK, toIngestJobResponse(job))
+	}
+}
+
+func requeueIngestJob(svc domain.IngestJobService, trigger func()) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := svc.Requeue(r.Context(), chi.URLParam(r, "id"), auth.DomainID(r.Context()))
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrNotFound):
+				writeJSON(w, http.StatusNotFound, errBody("ingest job not found"))
+			case errors.Is(err, domain.ErrConflict):
+				writeJSON(w, http.StatusConflict, errBody("ingest job is running"))
+			default:
+				writeJSON(w, http.StatusInternalServerError, errBody("internal error"))
+			}
+			return
+		}
+		trigger()
+		writeJSON(w, http.StatusAccepted, toIngestJobResponse(job))
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ultravioletrs/cube/internal/embedder/auth"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+// MountModelCatalogue registers the domain model catalogue endpoints.
+// Reads are open to any authenticated member; admin wraps the mutating
+// routes with the domain-admin permission check.
+func MountModelCatalogue(r chi.Router, svc domain.ModelProviderService, ollamaBaseURL string, admin func(http.Handler) http.Handler) {
+	r.Get("/api/v1/models", listCatalogueModels(svc, ollamaBaseURL))
+	r.Get("/api/v1/model-providers", listModelProviders(svc))
+	r.Group(func(r chi.Router) {
+		r.Use(admin)
+		r.Post("/api/v1/model-providers", createModelProvider(svc))
+		r.Delete("/api/v1/model-providers/{id}", deleteModelProvider(svc))
+	})
+}
+
+// modelProviderResponse is the JSON shape returned by model provider
+// endpoints. API keys are never echoed back.
+type modelProviderResponse struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Provider  string   `json:"provider"`
+	BaseURL   string   `json:"base_url,omitempty"`
+	Models    []string `json:"models"`
+	HasAPIKey bool     `json:"has_api_key"`
+	CreatedAt string   `json:"created_at"`
+	UpdatedAt string   `json:"updated_at"`
+}
+
+func toModelProviderResponse(p domain.ModelProvider) modelProviderResponse {
+	models := p.Models
+	if models == nil {
+		models = []string{}
+	}
+	return modelProviderResponse{
+		ID:        p.ID,
+		Name:      p.Name,
+		Provider:  p.Provider,
+		BaseURL:   p.BaseURL,
+		Models:    models,
+		HasAPIKey: p.HasAPIKey(),
+		CreatedAt: p.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+		UpdatedAt: p.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z"),
+	}
+}
+
+func listModelProviders(svc domain.ModelProviderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		providers, err := svc.List(r.Context(), auth.DomainID(r.Context()))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errBody("internal error"))
+			return
+		}
+		out := make([]modelProviderResponse, 0, len(providers))
+		for _, p := range providers {
+			out = append(out, toModelProviderResponse(p))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"providers": out})
+	}
+}
+
+func createModelProvider(svc domain.ModelProviderService) http.HandlerFunc {
+	type request struct {
+		Name     string   `json:"name"`
+		Provider string   `json:"provider"`
+		BaseURL  string   `json:"base_url"`
+		Models   []string `json:"models"`
+		APIKey   string   `json:"api_key"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errBody("invalid request body"))
+			return
+		}
+
+		created, err := svc.Create(r.Context(), domain.ModelProvider{
+			DomainID: auth.DomainID(r.Context()),
+			UserID:   auth.UserID(r.Context()),
+			Name:     req.Name,
+			Provider: req.Provider,
+			BaseURL:  req.BaseURL,
+			Models:   req.Models,
+			APIKey:   req.APIKey,
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrConflict):
+				writeJSON(w, http.StatusConflict, errBody("a model provider with this name already exists"))
+			default:
+				writeJSON(w, http.StatusUnprocessableEntity, errBody(err.Error()))
+			}
+			return
+		}
+		writeJSON(w, http.StatusCreated, toModelProviderResponse(created))
+	}
+}
+
+func deleteModelProvider(svc domain.ModelProviderService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := svc.Delete(r.Context(), chi.URLParam(r, "id"), auth.DomainID(r.Context())); err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				writeJSON(w, http.StatusNotFound, errBody("model provider not found"))
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, errBody("internal error"))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// listCatalogueModels merges the domain's registered providers with the chat
+// models installed on the server's Ollama. Ollama being unreachable is not
+// fatal; the registered catalogue is still returned.
+func listCatalogueModels(svc domain.ModelProviderService, ollamaBaseURL string) http.HandlerFunc {
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		providers, err := svc.List(r.Context(), auth.DomainID(r.Context()))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errBody("internal error"))
+			return
+		}
+
+		models := make([]domain.CatalogueModel, 0)
+		for _, p := range providers {
+			for _, m := range p.Models {
+				models = append(models, domain.CatalogueModel{
+					ProviderID:   p.ID,
+					ProviderName: p.Name,
+					Provider:     p.Provider,
+					Model:        m,
+				})
+			}
+		}
+
+		if ollamaBaseURL != "" {
+			names, err := ollamaChatModels(r.Context(), client, ollamaBaseURL)
+			if err != nil {
+				slog.Warn("model catalogue: ollama discovery failed", "err", err)
+			}
+			for _, name := range names {
+				models = append(models, domain.CatalogueModel{
+					ProviderName: "Local (Ollama)",
+					Provider:     "ollama",
+					Model:        name,
+				})
+			}
+		}
+
+		writeJSON(w, http.StatusOK, map[string]any{"models": models})
+	}
+}
@@ -7,12 +7,13 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
-	"net/url"
 	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
 )
 
 // MountModels registers model-listing endpoints.
@@ -21,8 +22,8 @@ func MountModels(r chi.Router, ollamaBaseURL string) {
 }
 
 // MountModelConnection registers the authenticated provider connection test.
-func MountModelConnection(r chi.Router, ollamaBaseURL string) {
-	r.Post("/api/v1/models/test-connection", testModelConnectionHandler(ollamaBaseURL))
+func MountModelConnection(r chi.Router, policy domain.ModelURLPolicy) {
+	r.Post("/api/v1/models/test-connection", testModelConnectionHandler(policy))
 }
 
 type ollamaTagsResponse struct {
@@ -33,31 +34,42 @@ type ollamaTagsResponse struct {
 
 func listOllamaModelsHandler(baseURL string) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		resp, err := http.Get(baseURL + "/api/tags") //nolint:noctx
+		names, err := ollamaChatModels(r.Context(), http.DefaultClient, baseURL)
 		if err != nil {
-			writeJSON(w, http.StatusBadGateway, errBody("ollama unreachable: "+err.Error()))
+			writeJSON(w, http.StatusBadGateway, errBody(err.Error()))
 			return
 		}
-		defer resp.Body.Close()
+		writeJSON(w, http.StatusOK, map[string]any{"models": names})
+	}
+}
 
-		var tags ollamaTagsResponse
-		if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
-			writeJSON(w, http.StatusBadGateway, errBody("ollama response invalid: "+err.Error()))
-			return
-		}
+// ollamaChatModels lists the chat-capable models installed on an Ollama server.
+func ollamaChatModels(ctx context.Context, client *http.Client, baseURL string) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/api/tags", nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, errors.New("ollama unreachable: " + err.Error())
+	}
+	defer resp.Body.Close()
 
-		names := make([]string, 0, len(tags.Models))
-		for _, m := range tags.Models {
-			// Skip models that don't support chat (embeddings, code-completion).
-			name := strings.ToLower(m.Name)
-			if strings.Contains(name, "embed") || strings.Contains(name, "starcoder") {
-				continue
-			}
-			names = append(names, m.Name)
-		}
+	var tags ollamaTagsResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tags); err != nil {
+		return nil, errors.New("ollama response invalid: " + err.Error())
+	}
 
-		writeJSON(w, http.StatusOK, map[string]any{"models": names})
+	names := make([]string, 0, len(tags.Models))
+	for _, m := range tags.Models {
+		// Skip models that don't support chat (embeddings, code-completion).
+		name := strings.ToLower(m.Name)
+		if strings.Contains(name, "embed") || strings.Contains(name, "starcoder") {
+			continue
+		}
+		names = append(names, m.Name)
 	}
+	return names, nil
 }
 
 type modelConnectionRequest struct {
@@ -72,7 +84,7 @@ type modelConnectionResponse struct {
 	Message   string `json:"message"`
 }
 
-func testModelConnectionHandler(ollamaBaseURL string) http.HandlerFunc {
+func testModelConnectionHandler(policy domain.ModelURLPolicy) http.HandlerFunc {
 	client := &http.Client{Timeout: 20 * time.Second}
 
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -92,9 +104,9 @@ func testModelConnectionHandler(ollamaBaseURL string) http.HandlerFunc {
 		var err error
 		switch req.Provider {
 		case "ollama":
-			err = testOllamaConnection(r.Context(), client, ollamaBaseURL, req.Model)
+			err = testOllamaConnection(r.Context(), client, policy.OllamaBaseURL, req.Model)
 		case "openai":
-			err = testOpenAICompatibleConnection(r.Context(), client, req.BaseURL, req.Model, req.APIKey)
+			err = testOpenAICompatibleConnection(r.Context(), client, policy, req.BaseURL, req.Model, req.APIKey)
 		default:
 			writeJSON(w, http.StatusBadRequest, errBody("unsupported provider"))
 			return
@@ -133,9 +145,9 @@ func testOllamaConnection(ctx context.Context, client *http.Client, baseURL, mod
 	return &connectionError{message: "Model is not available in Ollama"}
 }
 
-func testOpenAICompatibleConnection(ctx context.Context, client *http.Client, baseURL, model, apiKey string) error {
+func testOpenAICompatibleConnection(ctx context.Context, client *http.Client, policy domain.ModelURLPolicy, baseURL, model, apiKey string) error {
 	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
-	if !allowedExternalModelURL(baseURL) {
+	if !policy.AllowsExternal(baseURL) {
 		return &connectionError{message: "Provider URL is not allowed"}
 	}
 	if strings.TrimSpace(apiKey) == "" {
@@ -175,19 +187,6 @@ func testOpenAICompatibleConnection(ctx context.Context, client *http.Client, ba
 	return nil
 }
 
-func allowedExternalModelURL(raw string) bool {
-	parsed, err := url.Parse(raw)
-	if err != nil || parsed.Scheme != "https" || parsed.Path != "" || parsed.RawQuery != "" || parsed.Fragment != "" {
-		return false
-	}
-	switch parsed.Host {
-	case "api.openai.com", "api.anthropic.com":
-		return true
-	default:
-		return false
-	}
-}
-
 type connectionError struct {
 	message string
 }
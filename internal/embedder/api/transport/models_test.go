@@ -10,6 +10,8 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
 )
 
 func TestOllamaConnectionChecksSelectedModel(t *testing.T) {
@@ -26,24 +28,6 @@ func TestOllamaConnectionChecksSelectedModel(t *testing.T) {
 	}
 }
 
-func TestAllowedExternalModelURL(t *testing.T) {
-	for _, allowed := range []string{"https://api.openai.com", "https://api.anthropic.com"} {
-		if !allowedExternalModelURL(allowed) {
-			t.Fatalf("expected %q to be allowed", allowed)
-		}
-	}
-	for _, blocked := range []string{
-		"http://api.openai.com",
-		"https://api.openai.com/v1",
-		"https://example.com",
-		"https://api.openai.com?key=secret",
-	} {
-		if allowedExternalModelURL(blocked) {
-			t.Fatalf("expected %q to be blocked", blocked)
-		}
-	}
-}
-
 func TestOpenAIConnectionDoesNotExposeProviderResponse(t *testing.T) {
 	client := &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
 		if got := req.Header.Get("Authorization"); got != "Bearer secret-key" {
@@ -57,7 +41,7 @@ func TestOpenAIConnectionDoesNotExposeProviderResponse(t *testing.T) {
 		}, nil
 	})}
 
-	err := testOpenAICompatibleConnection(context.Background(), client, "https://api.openai.com", "gpt-4o-mini", "secret-key")
+	err := testOpenAICompatibleConnection(context.Background(), client, domain.ModelURLPolicy{}, "https://api.openai.com", "gpt-4o-mini", "secret-key")
 	if err == nil {
 		t.Fatal("expected connection test to fail")
 	}
@@ -0,0 +1,84 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package transport
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+const maxNotificationBodyBytes = 1 << 20
+
+// MountNotifications registers the change-notification callback routes.
+// Notifications are authenticated by their subscription's client state or,
+// for S3, by a shared token, so the routes must be mounted outside
+// auth.Middleware.
+func MountNotifications(r chi.Router, svc domain.SourceNotificationService) {
+	r.Post("/api/v1/notifications/s3", notifyProvider(svc, domain.SourceTypeS3))
+	r.Post("/api/v1/notifications/{id}", notifySubscription(svc))
+}
+
+func notifySubscription(svc domain.SourceNotificationService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+
+		// Graph validates a notification URL by posting a validationToken
+		// that must be echoed back as plain text.
+		if token := r.URL.Query().Get("validationToken"); token != "" {
+			if err := svc.Validate(r.Context(), id); err != nil {
+				writeNotificationError(w, err)
+				return
+			}
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+			w.WriteHeader(http.StatusOK)
+			_, _ = io.WriteString(w, token)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxNotificationBodyBytes))
+		if err != nil {
+			writeJSON(w, http.StatusRequestEntityTooLarge, errBody("request body is too large (max 1MB)"))
+			return
+		}
+		err = svc.Notify(r.Context(), id, domain.ChangeNotification{Header: r.Header, Body: body})
+		if err != nil {
+			writeNotificationError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func notifyProvider(svc domain.SourceNotificationService, provider domain.SourceType) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxNotificationBodyBytes))
+		if err != nil {
+			writeJSON(w, http.StatusRequestEntityTooLarge, errBody("request body is too large (max 1MB)"))
+			return
+		}
+		err = svc.NotifyProvider(r.Context(), provider, domain.ChangeNotification{Header: r.Header, Body: body})
+		if err != nil {
+			writeNotificationError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+	}
+}
+
+func writeNotificationError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrNotFound):
+		writeJSON(w, http.StatusNotFound, errBody("subscription not found"))
+	case errors.Is(err, domain.ErrInvalidNotification):
+		writeJSON(w, http.StatusUnauthorized, errBody(domain.ErrInvalidNotification.Error()))
+	default:
+		slog.Error("change notification", "err", err)
+		writeJSON(w, http.StatusInternalServerError, errBody("internal error"))
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package transport
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+type notificationServiceStub struct {
+	notified []string
+	provider domain.SourceType
+}
+
+func (s *notificationServiceStub) Validate(_ context.Context, id string) error {
+	if id != "sub-1" {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (s *notificationServiceStub) Notify(_ context.Context, id string, n domain.ChangeNotification) error {
+	if id != "sub-1" {
+		return domain.ErrNotFound
+	}
+	if string(n.Body) == "forged" {
+		return domain.ErrInvalidNotification
+	}
+	s.notified = append(s.notified, string(n.Body))
+	return nil
+}
+
+func (s *notificationServiceStub) NotifyProvider(_ context.Context, provider domain.SourceType, _ domain.ChangeNotification) error {
+	s.provider = provider
+	return nil
+}
+
+func TestNotificationRoutes(t *testing.T) {
+	svc := &notificationServiceStub{}
+	r := chi.NewRouter()
+	MountNotifications(r, svc)
+
+	cases := []struct {
+		name   string
+		target string
+		body   string
+		code   int
+		reply  string
+	}{
+		{name: "validation handshake", target: "/api/v1/notifications/sub-1?validationToken=abc%20123", code: http.StatusOK, reply: "abc 123"},
+		{name: "validation of unknown subscription", target: "/api/v1/notifications/nope?validationToken=abc", code: http.StatusNotFound},
+		{name: "notification", target: "/api/v1/notifications/sub-1", body: "changed", code: http.StatusAccepted},
+		{name: "forged notification", target: "/api/v1/notifications/sub-1", body: "forged", code: http.StatusUnauthorized},
+		{name: "s3 events", target: "/api/v1/notifications/s3", body: "{}", code: http.StatusAccepted},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tc.target, strings.NewReader(tc.body)))
+			if rec.Code != tc.code {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tc.code, rec.Body.String())
+			}
+			if tc.reply != "" && rec.Body.String() != tc.reply {
+				t.Fatalf("body = %q, want %q", rec.Body.String(), tc.reply)
+			}
+		})
+	}
+	if len(svc.notified) != 1 || svc.notified[0] != "changed" {
+		t.Fatalf("unexpected notifications: %v", svc.notified)
+	}
+	if svc.provider != domain.SourceTypeS3 {
+		t.Fatalf("s3 events were not routed to the provider endpoint")
+	}
+}
@@ -49,19 +49,33 @@ type recordResponse struct {
 	MimeType            string                `json:"mime_type,omitempty"`
 	FolderPath          *string               `json:"folder_path,omitempty"`
 	FolderID            *string               `json:"folder_id,omitempty"`
+	ParentID            *string               `json:"parent_id,omitempty"`
 	Description         string                `json:"description,omitempty"`
 	ChunkCount          *int                  `json:"chunks,omitempty"`
 	IngestTotalChunks   *int                  `json:"ingest_total_chunks,omitempty"`
 	IngestIndexedChunks *int                  `json:"ingest_indexed_chunks,omitempty"`
+	IngestReusedChunks  *int                  `json:"ingest_reused_chunks,omitempty"`
 	IngestStage         *string               `json:"ingest_stage,omitempty"`
 	SizeBytes           *int64                `json:"size_bytes,omitempty"`
 	PageCount           *int                  `json:"pages,omitempty"`
+	ContentHash         string                `json:"content_hash,omitempty"`
+	Enrichment          *recordEnrichment     `json:"enrichment,omitempty"`
 	Error               *string               `json:"error,omitempty"`
 	CreatedAt           string                `json:"created_at"`
 	UpdatedAt           string                `json:"updated_at"`
 	Source              *recordSourceResponse `json:"source,omitempty"`
 }
 
+type recordEnrichment struct {
+	Title      string   `json:"title,omitempty"`
+	Summary    string   `json:"summary,omitempty"`
+	Language   string   `json:"language,omitempty"`
+	Entities   []string `json:"entities,omitempty"`
+	Keywords   []string `json:"keywords,omitempty"`
+	Model      string   `json:"model,omitempty"`
+	EnrichedAt string   `json:"enriched_at"`
+}
+
 type recordSourceResponse struct {
 	ID         string `json:"id"`
 	Name       string `json:"name"`
@@ -83,17 +97,31 @@ func toRecordResponse(rec domain.Record) recordResponse {
 		MimeType:            rec.MimeType,
 		FolderPath:          rec.FolderPath,
 		FolderID:            rec.FolderID,
+		ParentID:            rec.ParentID,
 		Description:         rec.Description,
 		ChunkCount:          rec.ChunkCount,
 		IngestTotalChunks:   rec.IngestTotalChunks,
 		IngestIndexedChunks: rec.IngestIndexedChunks,
+		IngestReusedChunks:  rec.IngestReusedChunks,
 		IngestStage:         rec.IngestStage,
 		SizeBytes:           rec.SizeBytes,
 		PageCount:           rec.PageCount,
+		ContentHash:         rec.ContentHash,
 		Error:               rec.Error,
 		CreatedAt:           rec.CreatedAt.UTC().Format("2006-01-02T15:04:05Z"),
 		UpdatedAt:           rec.UpdatedAt.UTC().Format("2006-01-02T15:04:05Z"),
 	}
+	if e := rec.Enrichment; e != nil {
+		resp.Enrichment = &recordEnrichment{
+			Title:      e.Title,
+			Summary:    e.Summary,
+			Language:   e.Language,
+			Entities:   e.Entities,
+			Keywords:   e.Keywords,
+			Model:      e.Model,
+			EnrichedAt: e.EnrichedAt.UTC().Format("2006-01-02T15:04:05Z"),
+		}
+	}
 	if rec.Source != nil {
 		resp.Source = &recordSourceResponse{
 			ID:         rec.Source.ID,
@@ -235,6 +263,9 @@ func parseRecordFilter(r *http.Request) domain.RecordFilter {
 	if s := strings.TrimSpace(r.URL.Query().Get("folder")); s != "" {
 		f.FolderPrefix = &s
 	}
+	if s := strings.TrimSpace(r.URL.Query().Get("parent_id")); s != "" {
+		f.ParentID = &s
+	}
 	return f
 }
 
@@ -21,7 +21,7 @@ import (
 
 	"github.com/ultravioletrs/cube/internal/embedder/auth"
 	"github.com/ultravioletrs/cube/internal/embedder/domain"
-	"github.com/ultravioletrs/cube/internal/embedder/service"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
 	objstore "github.com/ultravioletrs/cube/internal/embedder/storage"
 )
 
@@ -83,9 +83,11 @@ func uploadRecord(
 			return
 		}
 
-		recordFormat := service.DetectRecordFormat(header.Filename, mimeType)
+		recordFormat := ingest.DetectRecordFormat(header.Filename, mimeType)
 		switch recordFormat {
-		case domain.RecordFormatText, domain.RecordFormatMD, domain.RecordFormatPDF, domain.RecordFormatDOCX, domain.RecordFormatCode, domain.RecordFormatImage:
+		case domain.RecordFormatText, domain.RecordFormatMD, domain.RecordFormatPDF, domain.RecordFormatDOCX,
+			domain.RecordFormatXLSX, domain.RecordFormatPPTX, domain.RecordFormatODT, domain.RecordFormatODS,
+			domain.RecordFormatRTF, domain.RecordFormatCode, domain.RecordFormatImage, domain.RecordFormatArchive:
 		default:
 			writeJSON(w, http.StatusUnsupportedMediaType, errBody("uploaded file type is not supported for direct upload"))
 			return
@@ -265,9 +267,6 @@ func isDirectUploadSource(src domain.Source) bool {
 	if src.Type != domain.SourceTypeLocalFS {
 		return false
 	}
-	if src.Name == directUploadSourceName {
-		return true
-	}
 
 	var cfg directUploadSourceConfig
 	if err := json.Unmarshal(src.Config, &cfg); err != nil {
@@ -282,6 +281,16 @@ func normalizeUploadMIME(format domain.RecordFormat, detected string) string {
 		return "application/pdf"
 	case domain.RecordFormatDOCX:
 		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	case domain.RecordFormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	case domain.RecordFormatPPTX:
+		return "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	case domain.RecordFormatODT:
+		return "application/vnd.oasis.opendocument.text"
+	case domain.RecordFormatODS:
+		return "application/vnd.oasis.opendocument.spreadsheet"
+	case domain.RecordFormatRTF:
+		return "application/rtf"
 	case domain.RecordFormatMD:
 		return "text/markdown"
 	case domain.RecordFormatCode, domain.RecordFormatText:
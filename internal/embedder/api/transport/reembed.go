@@ -0,0 +1,152 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ultravioletrs/cube/internal/embedder/auth"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+// MountReembedJobs registers the re-embedding job endpoints. All of them
+// are wrapped with admin, the domain-admin permission check.
+func MountReembedJobs(r chi.Router, svc domain.ReembedService, admin func(http.Handler) http.Handler) {
+	r.Group(func(r chi.Router) {
+		r.Use(admin)
+		r.Post("/api/v1/reembed-jobs", startReembedJob(svc))
+		r.Get("/api/v1/reembed-jobs", listReembedJobs(svc))
+		r.Get("/api/v1/reembed-jobs/{id}", getReembedJob(svc))
+		r.Post("/api/v1/reembed-jobs/{id}/cancel", cancelReembedJob(svc))
+	})
+}
+
+// reembedJobResponse is the JSON shape returned by re-embedding job
+// endpoints.
+type reembedJobResponse struct {
+	ID             string  `json:"id"`
+	SourceID       string  `json:"source_id,omitempty"`
+	Format         string  `json:"format,omitempty"`
+	Profile        string  `json:"profile,omitempty"`
+	Status         string  `json:"status"`
+	TotalChunks    int64   `json:"total_chunks"`
+	EmbeddedChunks int64   `json:"embedded_chunks"`
+	Progress       float64 `json:"progress"`
+	Error          string  `json:"error,omitempty"`
+	CreatedAt      string  `json:"created_at"`
+	UpdatedAt      string  `json:"updated_at"`
+	StartedAt      string  `json:"started_at,omitempty"`
+	CompletedAt    string  `json:"completed_at,omitempty"`
+}
+
+func toReembedJobResponse(j domain.ReembedJob) reembedJobResponse {
+	formatTime := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return reembedJobResponse{
+		ID:             j.ID,
+		SourceID:       j.SourceID,
+		Format:         string(j.Format),
+		Profile:        j.Profile,
+		Status:         string(j.Status),
+		TotalChunks:    j.TotalChunks,
+		EmbeddedChunks: j.EmbeddedChunks,
+		Progress:       j.Progress(),
+		Error:          j.Error,
+		CreatedAt:      formatTime(&j.CreatedAt),
+		UpdatedAt:      formatTime(&j.UpdatedAt),
+		StartedAt:      formatTime(j.StartedAt),
+		CompletedAt:    formatTime(j.CompletedAt),
+	}
+}
+
+func startReembedJob(svc domain.ReembedService) http.HandlerFunc {
+	type request struct {
+		SourceID string `json:"source_id"`
+		Format   string `json:"format"`
+		Profile  string `json:"profile"`
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errBody("invalid request body"))
+			return
+		}
+
+		job, err := svc.Start(r.Context(), domain.ReembedJob{
+			UserID: auth.UserID(r.Context()),
+			ReembedScope: domain.ReembedScope{
+				DomainID: auth.DomainID(r.Context()),
+				SourceID: req.SourceID,
+				Format:   domain.RecordFormat(req.Format),
+				Profile:  req.Profile,
+			},
+		})
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrConflict):
+				writeJSON(w, http.StatusConflict, errBody("a re-embedding job is already running for this domain"))
+			default:
+				writeJSON(w, http.StatusUnprocessableEntity, errBody(err.Error()))
+			}
+			return
+		}
+		writeJSON(w, http.StatusAccepted, toReembedJobResponse(job))
+	}
+}
+
+func listReembedJobs(svc domain.ReembedService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		jobs, err := svc.List(r.Context(), auth.DomainID(r.Context()))
+		if err != nil {
+			writeJSON(w, http.StatusInternalServerError, errBody("internal error"))
+			return
+		}
+		out := make([]reembedJobResponse, 0, len(jobs))
+		for _, j := range jobs {
+			out = append(out, toReembedJobResponse(j))
+		}
+		writeJSON(w, http.StatusOK, map[string]any{"jobs": out})
+	}
+}
+
+func getReembedJob(svc domain.ReembedService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := svc.Get(r.Context(), chi.URLParam(r, "id"), auth.DomainID(r.Context()))
+		if err != nil {
+			if errors.Is(err, domain.ErrNotFound) {
+				writeJSON(w, http.StatusNotFound, errBody("re-embedding job not found"))
+				return
+			}
+			writeJSON(w, http.StatusInternalServerError, errBody("internal error"))
+			return
+		}
+		writeJSON(w, http.StatusOK, toReembedJobResponse(job))
+	}
+}
+
+func cancelReembedJob(svc domain.ReembedService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		job, err := svc.Cancel(r.Context(), chi.URLParam(r, "id"), auth.DomainID(r.Context()))
+		if err != nil {
+			switch {
+			case errors.Is(err, domain.ErrNotFound):
+				writeJSON(w, http.StatusNotFound, errBody("re-embedding job not found"))
+			case errors.Is(err, domain.ErrConflict):
+				writeJSON(w, http.StatusConflict, errBody("re-embedding job has already finished"))
+			default:
+				writeJSON(w, http.StatusInternalServerError, errBody("internal error"))
+			}
+			return
+		}
+		writeJSON(w, http.StatusOK, toReembedJobResponse(job))
+	}
+}
@@ -23,6 +23,7 @@ import (
 	"github.com/ultravioletrs/cube/internal/embedder/auth"
 	"github.com/ultravioletrs/cube/internal/embedder/domain"
 	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/confluence"
 	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/microsoft"
 	s3source "github.com/ultravioletrs/cube/internal/embedder/ingest/sources/s3"
 )
@@ -36,22 +37,26 @@ func MountSources(
 	trigger func(),
 	googleOAuthClientID string,
 	googleOAuthClientSecret string,
+	admin func(http.Handler) http.Handler,
+	sourcePolicy domain.SourceNetworkPolicy,
 ) {
 	oauth := newGoogleOAuth(googleOAuthClientID, googleOAuthClientSecret)
 
 	r.Get("/api/v1/sources", listSources(svc))
-	r.Post("/api/v1/sources", createSource(svc, oauth))
+	r.Post("/api/v1/sources", createSource(svc, oauth, admin))
 	r.Post("/api/v1/sources/google/oauth/url", googleOAuthURL(oauth))
 	r.Post("/api/v1/sources/google/oauth/exchange", googleOAuthExchange(oauth))
-	r.Post("/api/v1/sources/google/files", listDriveFiles(oauth))
+	r.Post("/api/v1/sources/google/files", listDriveFiles(svc, oauth))
 	r.Post("/api/v1/sources/s3/browse", browseS3Path())
 	r.Post("/api/v1/sources/s3/files", listS3Files())
 	r.Post("/api/v1/sources/microsoft/browse", browseMicrosoftPath())
 	r.Post("/api/v1/sources/microsoft/files", listMicrosoftFiles())
+	r.Post("/api/v1/sources/confluence/browse", browseConfluence(svc, confluence.NewHTTPClient(sourcePolicy)))
 	r.Post("/api/v1/sources/{id}/sync", syncSource(syncSvc, trigger))
 	r.Get("/api/v1/sources/{id}", getSource(svc))
 	r.Put("/api/v1/sources/{id}/credentials", updateSourceCredentials(svc))
 	r.Put("/api/v1/sources/{id}/selection", updateSourceSelection(svc))
+	r.Post("/api/v1/sources/{id}/webhook/secret", rotateWebhookSecret(svc))
 	r.Delete("/api/v1/sources/{id}", deleteSource(svc))
 }
 
@@ -138,8 +143,11 @@ func (g *googleOAuth) cleanupLocked() {
 	}
 }
 
-func listDriveFiles(oauth *googleOAuth) http.HandlerFunc {
+func listDriveFiles(svc domain.SourceService, oauth *googleOAuth) http.HandlerFunc {
 	type request struct {
+		// SourceID browses with the stored credentials of an existing source;
+		// credentials in the request take precedence unless redacted.
+		SourceID     string `json:"source_id,omitempty"`
 		FolderID     string `json:"folder_id,omitempty"`
 		FolderLink   string `json:"folder_link,omitempty"`
 		BrowseFolder string `json:"browse_folder_id,omitempty"`
@@ -195,6 +203,16 @@ func listDriveFiles(oauth *googleOAuth) http.HandlerFunc {
 			ClientID:     strings.TrimSpace(req.ClientID),
 			ClientSecret: strings.TrimSpace(req.ClientSecret),
 		}
+		if req.SourceID != "" {
+			if err := applyStoredDriveCredentials(r.Context(), svc, req.SourceID, &cfg); err != nil {
+				if errors.Is(err, domain.ErrNotFound) {
+					writeJSON(w, http.StatusNotFound, errBody("source not found"))
+					return
+				}
+				writeJSON(w, http.StatusUnprocessableEntity, errBody(err.Error()))
+				return
+			}
+		}
 		oauth.applyServerCredentials(&cfg)
 		if cfg.AccessToken == "" {
 			writeJSON(w, http.StatusBadRequest, errBody("access_token is required"))
@@ -257,6 +275,37 @@ func listDriveFiles(oauth *googleOAuth) http.HandlerFunc {
 	}
 }
 
+// applyStoredDriveCredentials fills missing or redacted credentials in cfg
+// from a stored Google Drive source in the caller's domain.
+func applyStoredDriveCredentials(
+	ctx context.Context,
+	svc domain.SourceService,
+	sourceID string,
+	cfg *domain.GoogleDriveConfig,
+) error {
+	src, err := svc.GetByID(ctx, sourceID, auth.DomainID(ctx))
+	if err != nil {
+		return err
+	}
+	if src.Type != domain.SourceTypeGoogleDrive {
+		return fmt.Errorf("source %s is not a google_drive source", sourceID)
+	}
+	var stored domain.GoogleDriveConfig
+	if err := json.Unmarshal(src.Config, &stored); err != nil {
+		return fmt.Errorf("decode source config: %w", err)
+	}
+	fill := func(dst *string, value string) {
+		if *dst == "" || *dst == domain.RedactedSecret {
+			*dst = value
+		}
+	}
+	fill(&cfg.AccessToken, stored.AccessToken)
+	fill(&cfg.RefreshToken, stored.RefreshToken)
+	fill(&cfg.ClientID, stored.ClientID)
+	fill(&cfg.ClientSecret, stored.ClientSecret)
+	return nil
+}
+
 func listS3Files() http.HandlerFunc {
 	type request struct {
 		Endpoint        string   `json:"endpoint,omitempty"`
@@ -601,6 +650,133 @@ func browseMicrosoftPath() http.HandlerFunc {
 	}
 }
 
+func browseConfluence(svc domain.SourceService, httpClient *http.Client) http.HandlerFunc {
+	type request struct {
+		// SourceID browses with the stored settings of an existing source;
+		// credentials given in the request take precedence.
+		SourceID    string `json:"source_id,omitempty"`
+		BaseURL     string `json:"base_url,omitempty"`
+		Email       string `json:"email,omitempty"`
+		APIToken    string `json:"api_token,omitempty"`
+		AccessToken string `json:"access_token,omitempty"`
+		SpaceKey    string `json:"space_key,omitempty"`
+		PageID      string `json:"page_id,omitempty"`
+	}
+	type spaceResponse struct {
+		Key  string `json:"key"`
+		Name string `json:"name"`
+		URL  string `json:"url,omitempty"`
+	}
+	type pageResponse struct {
+		ID       string `json:"id"`
+		Title    string `json:"title"`
+		SpaceKey string `json:"space_key"`
+		URL      string `json:"url,omitempty"`
+	}
+	type response struct {
+		SpaceKey string          `json:"space_key,omitempty"`
+		PageID   string          `json:"page_id,omitempty"`
+		Spaces   []spaceResponse `json:"spaces"`
+		Pages    []pageResponse  `json:"pages"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errBody("invalid request body"))
+			return
+		}
+
+		cfg := domain.ConfluenceConfig{
+			BaseURL:     strings.TrimSuffix(strings.TrimSpace(req.BaseURL), "/"),
+			Email:       strings.TrimSpace(req.Email),
+			APIToken:    strings.TrimSpace(req.APIToken),
+			AccessToken: strings.TrimSpace(req.AccessToken),
+		}
+		if req.SourceID != "" {
+			if err := applyStoredConfluenceConfig(r.Context(), svc, req.SourceID, &cfg); err != nil {
+				if errors.Is(err, domain.ErrNotFound) {
+					writeJSON(w, http.StatusNotFound, errBody("source not found"))
+					return
+				}
+				writeJSON(w, http.StatusUnprocessableEntity, errBody(err.Error()))
+				return
+			}
+		}
+		u, err := url.Parse(cfg.BaseURL)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			writeJSON(w, http.StatusBadRequest, errBody("base_url must be an absolute http(s) URL"))
+			return
+		}
+		if cfg.AccessToken == "" && (cfg.Email == "" || cfg.APIToken == "") {
+			writeJSON(w, http.StatusBadRequest, errBody("access_token or email and api_token are required"))
+			return
+		}
+
+		entries, err := confluence.Browse(r.Context(), httpClient, cfg, req.SpaceKey, req.PageID)
+		if err != nil {
+			writeJSON(w, http.StatusUnprocessableEntity, errBody(err.Error()))
+			return
+		}
+
+		resp := response{
+			SpaceKey: strings.TrimSpace(req.SpaceKey),
+			PageID:   strings.TrimSpace(req.PageID),
+			Spaces:   []spaceResponse{},
+			Pages:    []pageResponse{},
+		}
+		for _, entry := range entries {
+			if entry.IsSpace {
+				resp.Spaces = append(resp.Spaces, spaceResponse{Key: entry.ID, Name: entry.Title, URL: entry.URL})
+				continue
+			}
+			resp.Pages = append(resp.Pages, pageResponse{
+				ID:       entry.ID,
+				Title:    entry.Title,
+				SpaceKey: entry.SpaceKey,
+				URL:      entry.URL,
+			})
+		}
+		writeJSON(w, http.StatusOK, resp)
+	}
+}
+
+// applyStoredConfluenceConfig fills cfg from a stored Confluence source in the
+// caller's domain. The stored base URL always wins so stored credentials are
+// only ever sent to the wiki they belong to.
+func applyStoredConfluenceConfig(
+	ctx context.Context,
+	svc domain.SourceService,
+	sourceID string,
+	cfg *domain.ConfluenceConfig,
+) error {
+	src, err := svc.GetByID(ctx, sourceID, auth.DomainID(ctx))
+	if err != nil {
+		return err
+	}
+	if src.Type != domain.SourceTypeConfluence {
+		return fmt.Errorf("source %s is not a confluence source", sourceID)
+	}
+	var stored domain.ConfluenceConfig
+	if err := json.Unmarshal(src.Config, &stored); err != nil {
+		return fmt.Errorf("decode source config: %w", err)
+	}
+	if cfg.BaseURL != "" && cfg.BaseURL != stored.BaseURL {
+		return fmt.Errorf("base_url does not match source %s", sourceID)
+	}
+	cfg.BaseURL = stored.BaseURL
+
+	provided := func(value string) bool {
+		return value != "" && value != domain.RedactedSecret
+	}
+	if !provided(cfg.APIToken) && !provided(cfg.AccessToken) {
+		cfg.Email = stored.Email
+		cfg.APIToken = stored.APIToken
+		cfg.AccessToken = stored.AccessToken
+	}
+	return nil
+}
+
 func normalizeBrowsePath(value string) string {
 	value = strings.TrimSpace(value)
 	if value == "" || value == "." || value == "/" {
@@ -893,6 +1069,14 @@ type sourceResponse struct {
 	NextSyncAt       *string         `json:"next_sync_at,omitempty"`
 	CreatedAt        string          `json:"created_at"`
 	UpdatedAt        string          `json:"updated_at"`
+	Webhook          *webhookInfo    `json:"webhook,omitempty"`
+}
+
+// webhookInfo tells senders where to push documents. The secret is only
+// returned when it is created or rotated.
+type webhookInfo struct {
+	IngestPath string `json:"ingest_path"`
+	Secret     string `json:"secret,omitempty"`
 }
 
 func toSourceResponse(s domain.Source) sourceResponse {
@@ -901,7 +1085,7 @@ func toSourceResponse(s domain.Source) sourceResponse {
 		UserID:           s.UserID,
 		SourceType:       string(s.Type),
 		Name:             s.Name,
-		Config:           s.Config,
+		Config:           domain.RedactSourceConfig(s.Type, s.Config),
 		Status:           string(s.Status),
 		SyncEnabled:      s.SyncEnabled,
 		AutoSyncInterval: s.AutoSyncInterval,
@@ -917,10 +1101,15 @@ func toSourceResponse(s domain.Source) sourceResponse {
 		t := s.NextSyncAt.UTC().Format("2006-01-02T15:04:05Z")
 		r.NextSyncAt = &t
 	}
+	if s.Type == domain.SourceTypeWebhook {
+		r.Webhook = &webhookInfo{IngestPath: webhookIngestPath(s)}
+	}
 	return r
 }
 
-func createSource(svc domain.SourceService, oauth *googleOAuth) http.HandlerFunc {
+// createSource creates a source. SQL sources connect to databases with
+// operator-supplied credentials, so creating one also passes through admin.
+func createSource(svc domain.SourceService, oauth *googleOAuth, admin func(http.Handler) http.Handler) http.HandlerFunc {
 	type request struct {
 		SourceType       string          `json:"source_type"`
 		Name             string          `json:"name"`
@@ -959,28 +1148,60 @@ func createSource(svc domain.SourceService, oauth *googleOAuth) http.HandlerFunc
 			}
 		}
 
-		src := domain.Source{
-			DomainID:         auth.DomainID(r.Context()),
-			UserID:           auth.UserID(r.Context()),
-			Type:             domain.SourceType(req.SourceType),
-			Name:             req.Name,
-			Config:           req.Config,
-			SyncEnabled:      req.SyncEnabled,
-			AutoSyncInterval: req.AutoSyncInterval,
-			Status:           domain.SourceStatusActive,
+		create := func(w http.ResponseWriter, r *http.Request) {
+			src := domain.Source{
+				DomainID:         auth.DomainID(r.Context()),
+				UserID:           auth.UserID(r.Context()),
+				Type:             domain.SourceType(req.SourceType),
+				Name:             req.Name,
+				Config:           req.Config,
+				SyncEnabled:      req.SyncEnabled,
+				AutoSyncInterval: req.AutoSyncInterval,
+				Status:           domain.SourceStatusActive,
+			}
+
+			created, err := svc.Create(r.Context(), src)
+			if err != nil {
+				switch {
+				case errors.Is(err, domain.ErrConflict):
+					writeJSON(w, http.StatusConflict, errBody(err.Error()))
+				default:
+					writeJSON(w, http.StatusUnprocessableEntity, errBody(err.Error()))
+				}
+				return
+			}
+			resp := toSourceResponse(created)
+			if created.Type == domain.SourceTypeWebhook {
+				var cfg domain.WebhookConfig
+				if err := json.Unmarshal(created.Config, &cfg); err == nil {
+					resp.Webhook.Secret = cfg.Secret
+				}
+			}
+			writeJSON(w, http.StatusCreated, resp)
+		}
+
+		if domain.SourceType(req.SourceType) == domain.SourceTypeSQL {
+			admin(http.HandlerFunc(create)).ServeHTTP(w, r)
+			return
 		}
+		create(w, r)
+	}
+}
 
-		created, err := svc.Create(r.Context(), src)
+func rotateWebhookSecret(svc domain.SourceService) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		src, secret, err := svc.RotateWebhookSecret(r.Context(), chi.URLParam(r, "id"), auth.DomainID(r.Context()))
 		if err != nil {
-			switch {
-			case errors.Is(err, domain.ErrConflict):
-				writeJSON(w, http.StatusConflict, errBody(err.Error()))
-			default:
-				writeJSON(w, http.StatusUnprocessableEntity, errBody(err.Error()))
+			if errors.Is(err, domain.ErrNotFound) {
+				writeJSON(w, http.StatusNotFound, errBody("source not found"))
+				return
 			}
+			writeJSON(w, http.StatusUnprocessableEntity, errBody(err.Error()))
 			return
 		}
-		writeJSON(w, http.StatusCreated, toSourceResponse(created))
+		resp := toSourceResponse(src)
+		resp.Webhook.Secret = secret
+		writeJSON(w, http.StatusOK, resp)
 	}
 }
 
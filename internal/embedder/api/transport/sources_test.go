@@ -3,7 +3,15 @@
 
 package transport
 
-import "testing"
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
 
 func TestParseDriveFolderID(t *testing.T) {
 	tests := []struct {
@@ -39,3 +47,41 @@ func TestParseDriveFolderID(t *testing.T) {
 		})
 	}
 }
+
+type sourceServiceStub struct {
+	domain.SourceService
+	created []domain.Source
+}
+
+func (s *sourceServiceStub) Create(_ context.Context, src domain.Source) (domain.Source, error) {
+	s.created = append(s.created, src)
+	return src, nil
+}
+
+func TestCreateSourceRequiresAdminForSQL(t *testing.T) {
+	deny := func(http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusForbidden)
+		})
+	}
+	svc := &sourceServiceStub{}
+	handler := createSource(svc, newGoogleOAuth("", ""), deny)
+
+	for _, tc := range []struct {
+		sourceType string
+		want       int
+	}{
+		{sourceType: string(domain.SourceTypeSQL), want: http.StatusForbidden},
+		{sourceType: string(domain.SourceTypeWeb), want: http.StatusCreated},
+	} {
+		body := `{"source_type":"` + tc.sourceType + `","name":"src","config":{}}`
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodPost, "/api/v1/sources", strings.NewReader(body)))
+		if rec.Code != tc.want {
+			t.Fatalf("%s: expected status %d, got %d", tc.sourceType, tc.want, rec.Code)
+		}
+	}
+	if len(svc.created) != 1 || svc.created[0].Type != domain.SourceTypeWeb {
+		t.Fatalf("expected only the web source to be created, got %+v", svc.created)
+	}
+}
@@ -0,0 +1,144 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package transport
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+const (
+	maxWebhookBodyBytes = 64 << 20
+
+	// WebhookTimestampHeader carries the Unix time the request was signed at.
+	WebhookTimestampHeader = "X-Cube-Timestamp"
+	// WebhookSignatureHeader carries "sha256=<hex HMAC>" of
+	// "<timestamp>.<body>" keyed with the source's secret.
+	WebhookSignatureHeader = "X-Cube-Signature"
+)
+
+// MountWebhooks registers the ingest route of webhook sources. Requests are
+// authenticated by their HMAC signature, so the route must be mounted
+// outside auth.Middleware.
+func MountWebhooks(r chi.Router, svc domain.WebhookService, trigger func()) {
+	r.Post("/api/v1/webhooks/{domainID}/{id}", ingestWebhook(svc, trigger))
+}
+
+// webhookIngestPath is the ingest route of a webhook source.
+func webhookIngestPath(src domain.Source) string {
+	return "/api/v1/webhooks/" + src.DomainID + "/" + src.ID
+}
+
+func ingestWebhook(svc domain.WebhookService, trigger func()) http.HandlerFunc {
+	type document struct {
+		ID    string `json:"id"`
+		Title string `json:"title,omitempty"`
+		Text  string `json:"text,omitempty"`
+		// Content is base64 encoded.
+		Content    []byte            `json:"content,omitempty"`
+		MimeType   string            `json:"mime_type,omitempty"`
+		URL        string            `json:"url,omitempty"`
+		Metadata   map[string]string `json:"metadata,omitempty"`
+		FolderPath string            `json:"folder_path,omitempty"`
+		Version    string            `json:"version,omitempty"`
+		ModifiedAt *time.Time        `json:"modified_at,omitempty"`
+	}
+	type request struct {
+		Documents []document `json:"documents"`
+		Deleted   []string   `json:"deleted"`
+	}
+	type response struct {
+		Created   uint64 `json:"created"`
+		Updated   uint64 `json:"updated"`
+		Unchanged uint64 `json:"unchanged"`
+		Deleted   uint64 `json:"deleted"`
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		// Requests for unknown sources or with stale timestamps are refused
+		// before the body is read.
+		timestamp := r.Header.Get(WebhookTimestampHeader)
+		src, err := svc.Lookup(r.Context(), chi.URLParam(r, "id"), chi.URLParam(r, "domainID"), timestamp)
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookBodyBytes))
+		if err != nil {
+			writeJSON(w, http.StatusRequestEntityTooLarge, errBody("request body is too large (max 64MB)"))
+			return
+		}
+		if err := svc.Verify(src, timestamp, r.Header.Get(WebhookSignatureHeader), body); err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+
+		var req request
+		if err := json.Unmarshal(body, &req); err != nil {
+			writeJSON(w, http.StatusBadRequest, errBody("invalid request body"))
+			return
+		}
+		batch := domain.WebhookBatch{Deleted: req.Deleted}
+		for _, doc := range req.Documents {
+			batch.Documents = append(batch.Documents, domain.WebhookDocument{
+				ID:         doc.ID,
+				Title:      doc.Title,
+				Text:       doc.Text,
+				Content:    doc.Content,
+				MimeType:   doc.MimeType,
+				URL:        doc.URL,
+				Metadata:   doc.Metadata,
+				FolderPath: doc.FolderPath,
+				Version:    doc.Version,
+				ModifiedAt: doc.ModifiedAt,
+			})
+		}
+
+		res, err := svc.Ingest(r.Context(), src, batch)
+		if err != nil {
+			writeWebhookError(w, err)
+			return
+		}
+		if res.Created+res.Updated > 0 && trigger != nil {
+			trigger()
+		}
+
+		slog.Info("webhook batch ingested",
+			"source_id", src.ID,
+			"created", res.Created,
+			"updated", res.Updated,
+			"unchanged", res.Unchanged,
+			"deleted", res.Deleted,
+		)
+		writeJSON(w, http.StatusOK, response{
+			Created:   res.Created,
+			Updated:   res.Updated,
+			Unchanged: res.Unchanged,
+			Deleted:   res.Deleted,
+		})
+	}
+}
+
+// writeWebhookError reports validation and signature errors to the sender.
+// Anything else is a server fault the sender should retry, and its details
+// stay in the log.
+func writeWebhookError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, domain.ErrInvalidSignature):
+		writeJSON(w, http.StatusUnauthorized, errBody(domain.ErrInvalidSignature.Error()))
+	case errors.Is(err, domain.ErrInvalidWebhookBatch):
+		writeJSON(w, http.StatusUnprocessableEntity, errBody(err.Error()))
+	default:
+		slog.Error("webhook ingest failed", "err", err)
+		writeJSON(w, http.StatusInternalServerError, errBody("internal error"))
+	}
+}
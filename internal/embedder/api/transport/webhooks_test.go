@@ -0,0 +1,97 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package transport
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+type webhookServiceStub struct {
+	ingestErr error
+}
+
+func (s *webhookServiceStub) Lookup(_ context.Context, id, _, _ string) (domain.Source, error) {
+	if id != "src-1" {
+		return domain.Source{}, domain.ErrInvalidSignature
+	}
+	return domain.Source{ID: id}, nil
+}
+
+func (s *webhookServiceStub) Verify(_ domain.Source, _, signature string, _ []byte) error {
+	if signature != "valid" {
+		return domain.ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *webhookServiceStub) Ingest(context.Context, domain.Source, domain.WebhookBatch) (domain.WebhookIngestResult, error) {
+	return domain.WebhookIngestResult{Created: 1}, s.ingestErr
+}
+
+// unreadBody fails the test if the handler reads it.
+type unreadBody struct{ t *testing.T }
+
+func (b unreadBody) Read([]byte) (int, error) {
+	b.t.Error("body read before the source was authenticated")
+	return 0, io.EOF
+}
+
+func TestIngestWebhookStatuses(t *testing.T) {
+	body := `{"documents":[{"id":"a","text":"x"}]}`
+	cases := []struct {
+		name      string
+		id        string
+		signature string
+		ingestErr error
+		code      int
+		reply     string
+	}{
+		{name: "ingested", id: "src-1", signature: "valid", code: http.StatusOK},
+		{name: "unknown source", id: "src-2", signature: "valid", code: http.StatusUnauthorized},
+		{name: "bad signature", id: "src-1", signature: "forged", code: http.StatusUnauthorized},
+		{
+			name: "invalid batch", id: "src-1", signature: "valid", code: http.StatusUnprocessableEntity,
+			ingestErr: fmt.Errorf("%w: document 0: no text", domain.ErrInvalidWebhookBatch), reply: "document 0: no text",
+		},
+		{
+			name: "storage failure", id: "src-1", signature: "valid", code: http.StatusInternalServerError,
+			ingestErr: errors.New("store document: s3://internal-bucket unreachable"), reply: "internal error",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			r := chi.NewRouter()
+			MountWebhooks(r, &webhookServiceStub{ingestErr: tc.ingestErr}, nil)
+
+			var reqBody io.Reader = strings.NewReader(body)
+			if tc.id != "src-1" {
+				reqBody = unreadBody{t}
+			}
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/webhooks/domain-1/"+tc.id, reqBody)
+			req.Header.Set(WebhookSignatureHeader, tc.signature)
+			rec := httptest.NewRecorder()
+			r.ServeHTTP(rec, req)
+
+			if rec.Code != tc.code {
+				t.Fatalf("expected %d, got %d: %s", tc.code, rec.Code, rec.Body)
+			}
+			if !strings.Contains(rec.Body.String(), tc.reply) {
+				t.Fatalf("expected reply to contain %q, got %s", tc.reply, rec.Body)
+			}
+			if strings.Contains(rec.Body.String(), "internal-bucket") {
+				t.Fatalf("reply leaks internal detail: %s", rec.Body)
+			}
+		})
+	}
+}
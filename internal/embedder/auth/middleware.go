@@ -7,6 +7,7 @@ package auth
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
@@ -16,6 +17,7 @@ import (
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
 )
 
@@ -24,16 +26,24 @@ type contextKey string
 const (
 	userIDKey   contextKey = "user_id"
 	domainIDKey contextKey = "domain_id"
+	tokenKey    contextKey = "token"
 )
 
+// ActionManage is the ATOM action required for domain administration.
+const ActionManage = "manage"
+
+// ErrForbidden is returned by Authorize when ATOM denies the action.
+var ErrForbidden = errors.New("forbidden")
+
 type Identity struct {
 	EntityID string
 	TenantID string
 }
 
-// Authenticator wraps the ATOM AuthService gRPC client.
+// Authenticator wraps the ATOM AuthService and AuthzService gRPC clients.
 type Authenticator struct {
 	client  atomv1.AuthServiceClient
+	authz   atomv1.AuthzServiceClient
 	timeout time.Duration
 }
 
@@ -47,6 +57,7 @@ func NewAuthenticator(addr string) (*Authenticator, *grpc.ClientConn, error) {
 	timeout := 15 * time.Second
 	return &Authenticator{
 		client:  atomv1.NewAuthServiceClient(conn),
+		authz:   atomv1.NewAuthzServiceClient(conn),
 		timeout: timeout,
 	}, conn, nil
 }
@@ -77,6 +88,54 @@ func (a *Authenticator) Identify(ctx context.Context, token string) (Identity, e
 	return identity, nil
 }
 
+// Authorize asks ATOM whether the caller may perform action on the domain.
+func (a *Authenticator) Authorize(ctx context.Context, token, entityID, domainID, action string) error {
+	ctx, cancel := context.WithTimeout(ctx, a.timeout)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+
+	req := &atomv1.CheckRequest{
+		SubjectId: entityID,
+		Action:    action,
+		Context:   map[string]string{"cube_service": "embedder"},
+	}
+	if domainID != "" {
+		req.ObjectKind = "tenant"
+		req.ObjectId = domainID
+	}
+	res, err := a.authz.Check(ctx, req)
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.PermissionDenied {
+			return fmt.Errorf("%w: %w", ErrForbidden, err)
+		}
+		return fmt.Errorf("auth check: %w", err)
+	}
+	if !res.GetAllowed() {
+		return fmt.Errorf("%w: %s", ErrForbidden, res.GetReason())
+	}
+	return nil
+}
+
+// RequireAction returns an HTTP middleware that rejects callers who may not
+// perform action on their domain. It must run after Middleware.
+func RequireAction(auth *Authenticator, action string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			token, _ := ctx.Value(tokenKey).(string)
+			if err := auth.Authorize(ctx, token, UserID(ctx), DomainID(ctx), action); err != nil {
+				if errors.Is(err, ErrForbidden) {
+					writeError(w, http.StatusForbidden, "insufficient permissions")
+					return
+				}
+				writeError(w, http.StatusServiceUnavailable, "authorization service unavailable")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // Middleware returns an HTTP middleware that extracts the Bearer token,
 // calls the auth gRPC Authenticate RPC, and stores the user ID in the context.
 func Middleware(auth *Authenticator) func(http.Handler) http.Handler {
@@ -99,6 +158,7 @@ func Middleware(auth *Authenticator) func(http.Handler) http.Handler {
 			}
 
 			ctx := context.WithValue(r.Context(), userIDKey, identity.EntityID)
+			ctx = context.WithValue(ctx, tokenKey, raw)
 			if domainID := firstNonEmpty(r.Header.Get("X-Domain-Id"), identity.TenantID); domainID != "" {
 				ctx = context.WithValue(ctx, domainIDKey, domainID)
 			}
@@ -0,0 +1,57 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	atomv1 "github.com/ultravioletrs/cube/proto/atom/v1"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type authzStub struct {
+	res *atomv1.CheckResponse
+	err error
+}
+
+func (s authzStub) Check(context.Context, *atomv1.CheckRequest, ...grpc.CallOption) (*atomv1.CheckResponse, error) {
+	return s.res, s.err
+}
+
+func TestAuthorizeErrors(t *testing.T) {
+	cases := []struct {
+		name      string
+		stub      authzStub
+		forbidden bool
+		status    int
+	}{
+		{"allowed", authzStub{res: &atomv1.CheckResponse{Allowed: true}}, false, http.StatusOK},
+		{"denied", authzStub{res: &atomv1.CheckResponse{Reason: "not an admin"}}, true, http.StatusForbidden},
+		{"permission denied", authzStub{err: status.Error(codes.PermissionDenied, "no")}, true, http.StatusForbidden},
+		{"unavailable", authzStub{err: status.Error(codes.Unavailable, "down")}, false, http.StatusServiceUnavailable},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			a := &Authenticator{authz: tc.stub, timeout: time.Second}
+			err := a.Authorize(context.Background(), "token", "user", "domain", ActionManage)
+			if got := errors.Is(err, ErrForbidden); got != tc.forbidden {
+				t.Fatalf("errors.Is(%v, ErrForbidden) = %v, want %v", err, got, tc.forbidden)
+			}
+
+			rec := httptest.NewRecorder()
+			next := http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) })
+			RequireAction(a, ActionManage)(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+			if rec.Code != tc.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tc.status)
+			}
+		})
+	}
+}
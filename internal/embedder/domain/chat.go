@@ -55,6 +55,11 @@ type ChatDebug struct {
 	SkippedReason    string           `json:"skipped_reason,omitempty"`
 	RecordIDs        []string         `json:"record_ids,omitempty"`
 	PromptChunks     []ChatDebugChunk `json:"prompt_chunks"`
+	// Provider and Model identify the LLM backend that served the answer.
+	// Attempts is greater than one when retries or fallbacks were needed.
+	Provider string `json:"provider,omitempty"`
+	Model    string `json:"model,omitempty"`
+	Attempts int    `json:"attempts,omitempty"`
 }
 
 // ChatDebugChunk is one retrieved chunk that was sent to the model.
@@ -71,6 +76,10 @@ type ChatDebugChunk struct {
 // ModelConfig carries per-request LLM overrides sent by the client.
 // Zero/empty values mean "use the server default".
 type ModelConfig struct {
+	// ProviderID references a server-side ModelProvider in the caller's
+	// domain. When set, Provider, BaseURL and APIKey are taken from the
+	// stored provider and the request values are ignored.
+	ProviderID string `json:"provider_id,omitempty"`
 	// Provider selects the LLM backend: "ollama" or "openai".
 	// Use "openai" for any OpenAI-compatible API (OpenAI, Anthropic, etc.).
 	Provider string `json:"provider"`
@@ -0,0 +1,189 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package domain
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// IngestJobStatus tracks an ingest job through the queue.
+type IngestJobStatus string
+
+const (
+	// IngestJobQueued jobs wait for a worker, after RunAfter when retrying.
+	IngestJobQueued IngestJobStatus = "queued"
+	// IngestJobLeased jobs are being ingested by the worker holding the lease.
+	IngestJobLeased IngestJobStatus = "leased"
+	// IngestJobDead jobs failed for good and wait to be requeued by an admin.
+	IngestJobDead IngestJobStatus = "dead"
+)
+
+// Valid reports whether s is a known job status.
+func (s IngestJobStatus) Valid() bool {
+	switch s {
+	case IngestJobQueued, IngestJobLeased, IngestJobDead:
+		return true
+	}
+	return false
+}
+
+// IngestErrorClass groups ingest failures that share a retry policy.
+type IngestErrorClass string
+
+const (
+	// IngestErrorTransient covers network failures, timeouts, server errors
+	// and workers that stopped while holding a lease.
+	IngestErrorTransient IngestErrorClass = "transient"
+	// IngestErrorRateLimited covers providers asking the embedder to slow down.
+	IngestErrorRateLimited IngestErrorClass = "rate_limited"
+	// IngestErrorPermanent covers content that cannot be ingested as is, such
+	// as documents that fail to extract or produce no chunks.
+	IngestErrorPermanent IngestErrorClass = "permanent"
+	// IngestErrorUnknown is every other failure.
+	IngestErrorUnknown IngestErrorClass = "unknown"
+)
+
+// StatusError is returned by source and embedding clients when an HTTP
+// upstream answers with an unexpected status code. Ingest failures are
+// classified by its status code.
+type StatusError struct {
+	// Op names the failed request, such as "ollama embed".
+	Op         string
+	StatusCode int
+	// Message is the upstream's error text, if any.
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s status %d", e.Op, e.StatusCode)
+	}
+	return fmt.Sprintf("%s status %d: %s", e.Op, e.StatusCode, e.Message)
+}
+
+// IngestJob is one record's pass through the ingest pipeline. A record has at
+// most one job; the job is removed once the record is indexed or cancelled.
+type IngestJob struct {
+	ID       string
+	RecordID string
+	DomainID string
+	Status   IngestJobStatus
+	Priority IngestPriority
+	// Attempts counts the leases taken on the job, including the current one.
+	Attempts int
+	// RunAfter is when a queued job may next be claimed.
+	RunAfter time.Time
+	// LeaseOwner identifies the worker holding the lease; the lease lapses at
+	// LeaseExpiresAt unless the worker renews it.
+	LeaseOwner     string
+	LeaseExpiresAt *time.Time
+	HeartbeatAt    *time.Time
+	LastError      string
+	ErrorClass     IngestErrorClass
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// IngestJobFilter constrains a job list. All fields are optional.
+type IngestJobFilter struct {
+	Status *IngestJobStatus
+}
+
+// IngestJobPage is a paginated result set of ingest jobs.
+type IngestJobPage struct {
+	Jobs  []IngestJob
+	Total uint64
+}
+
+// IngestPriority orders the jobs of one domain: higher priorities are
+// claimed first.
+type IngestPriority int
+
+const (
+	// IngestPriorityBulk is the priority of records found by source syncs.
+	IngestPriorityBulk IngestPriority = 0
+	// IngestPriorityInteractive is the priority of direct uploads, which a
+	// user is usually waiting on.
+	IngestPriorityInteractive IngestPriority = 100
+)
+
+func (p IngestPriority) String() string {
+	if p >= IngestPriorityInteractive {
+		return "interactive"
+	}
+	return "bulk"
+}
+
+// IngestConcurrencyLimits caps how many jobs of one domain are leased at
+// once across all workers. Zero means no cap.
+type IngestConcurrencyLimits struct {
+	Default int
+	// Domains overrides Default for individual domains.
+	Domains map[string]int
+}
+
+// For returns the cap of domainID.
+func (l IngestConcurrencyLimits) For(domainID string) int {
+	if n, ok := l.Domains[domainID]; ok {
+		return n
+	}
+	return l.Default
+}
+
+// IngestQueueStats summarises one domain's jobs.
+type IngestQueueStats struct {
+	DomainID string
+	// Runnable jobs may be claimed now; Waiting jobs wait out a retry backoff.
+	Runnable int64
+	Waiting  int64
+	Leased   int64
+	Dead     int64
+	// OldestRunnable is the RunAfter of the longest-waiting runnable job.
+	OldestRunnable *time.Time
+}
+
+// IngestJobRepository defines the persistence contract for the ingest queue.
+// Leases make it safe for several embedder replicas to share one queue.
+type IngestJobRepository interface {
+	// Enqueue creates jobs for queued records that have none and revives dead
+	// jobs of records queued again, e.g. by a retry or a re-sync. Records of
+	// direct upload sources get IngestPriorityInteractive. It returns how
+	// many jobs it queued.
+	Enqueue(ctx context.Context) (int64, error)
+	// Claim leases up to limit runnable jobs to owner for lease. Queued jobs
+	// past their RunAfter are runnable, and so are leased jobs whose lease
+	// expired; those are reclaimed with a transient error recorded.
+	//
+	// Jobs are shared fairly: each domain's next job, by priority then age,
+	// goes before any domain's following one, and domains already running
+	// jobs wait their turn. Domains at their cap in limits are skipped.
+	Claim(ctx context.Context, owner string, limit int, lease time.Duration, limits IngestConcurrencyLimits) ([]IngestJob, error)
+	// Heartbeat extends owner's lease on a job. It returns ErrNotFound when
+	// owner no longer holds the lease.
+	Heartbeat(ctx context.Context, id, owner string, lease time.Duration) error
+	// Complete removes a job whose record was indexed or cancelled.
+	Complete(ctx context.Context, id, owner string) error
+	// Retry releases a failed job to be claimed again after runAfter.
+	Retry(ctx context.Context, id, owner string, runAfter time.Time, class IngestErrorClass, errMsg string) error
+	// DeadLetter parks a job that will not be retried.
+	DeadLetter(ctx context.Context, id, owner string, class IngestErrorClass, errMsg string) error
+
+	// Stats summarises the queue of every domain that has jobs.
+	Stats(ctx context.Context) ([]IngestQueueStats, error)
+
+	Get(ctx context.Context, id, domainID string) (IngestJob, error)
+	List(ctx context.Context, domainID string, f IngestJobFilter, p Page) (IngestJobPage, error)
+	// Requeue makes a dead or waiting job runnable now with its attempts
+	// reset. It returns ErrConflict for a leased job.
+	Requeue(ctx context.Context, id, domainID string) (IngestJob, error)
+}
+
+// IngestJobService lets admins inspect and requeue ingest jobs.
+type IngestJobService interface {
+	Get(ctx context.Context, id, domainID string) (IngestJob, error)
+	List(ctx context.Context, domainID string, f IngestJobFilter, p Page) (IngestJobPage, error)
+	Requeue(ctx context.Context, id, domainID string) (IngestJob, error)
+}
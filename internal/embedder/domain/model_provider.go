@@ -0,0 +1,123 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package domain
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ErrModelNotAllowed is returned when a chat request references a model or
+// provider endpoint the server is not permitted to call.
+var ErrModelNotAllowed = errors.New("model not allowed")
+
+// ModelProvider is a named LLM backend registered server-side for a domain.
+// Chat requests reference it by ID instead of sending credentials.
+type ModelProvider struct {
+	ID       string
+	DomainID string
+	// UserID records the admin who registered the provider.
+	UserID string
+	Name   string
+	// Provider is the client protocol: "openai" or "ollama".
+	Provider string
+	BaseURL  string
+	// Models lists the model identifiers chat requests may select.
+	Models []string
+	// APIKey is the decrypted key. It is only populated by
+	// ModelProviderService.Resolve and is never returned by the API.
+	APIKey string
+	// APIKeyCiphertext is the sealed key as stored in the database.
+	APIKeyCiphertext []byte
+	CreatedAt        time.Time
+	UpdatedAt        time.Time
+}
+
+// HasAPIKey reports whether the provider has a stored credential.
+func (p ModelProvider) HasAPIKey() bool {
+	return len(p.APIKeyCiphertext) > 0
+}
+
+// AllowsModel reports whether model is in the provider's model list.
+func (p ModelProvider) AllowsModel(model string) bool {
+	for _, m := range p.Models {
+		if m == model {
+			return true
+		}
+	}
+	return false
+}
+
+// CatalogueModel is one selectable chat model in a domain's catalogue.
+type CatalogueModel struct {
+	// ProviderID is empty for models discovered on the server's Ollama.
+	ProviderID   string `json:"provider_id,omitempty"`
+	ProviderName string `json:"provider_name"`
+	Provider     string `json:"provider"`
+	Model        string `json:"model"`
+}
+
+// ModelURLPolicy decides which provider base URLs the server may call.
+type ModelURLPolicy struct {
+	// AllowedHosts lists hosts reachable by OpenAI-compatible providers over HTTPS.
+	AllowedHosts []string
+	// OllamaBaseURL is the only endpoint Ollama providers may use.
+	OllamaBaseURL string
+}
+
+// DefaultModelHosts are the external provider hosts allowed out of the box.
+var DefaultModelHosts = []string{"api.openai.com", "api.anthropic.com"}
+
+// Allows reports whether baseURL is acceptable for provider. Empty base URLs
+// are allowed and resolve to server defaults.
+func (p ModelURLPolicy) Allows(provider, baseURL string) bool {
+	baseURL = strings.TrimRight(strings.TrimSpace(baseURL), "/")
+	if baseURL == "" {
+		return true
+	}
+	if strings.EqualFold(provider, "ollama") || strings.EqualFold(provider, "local") {
+		return baseURL == strings.TrimRight(p.OllamaBaseURL, "/")
+	}
+	return p.AllowsExternal(baseURL)
+}
+
+// AllowsExternal reports whether raw is an allowlisted HTTPS origin with no
+// path, query or fragment.
+func (p ModelURLPolicy) AllowsExternal(raw string) bool {
+	parsed, err := url.Parse(raw)
+	if err != nil || parsed.Scheme != "https" || parsed.Path != "" || parsed.RawQuery != "" || parsed.Fragment != "" || parsed.User != nil {
+		return false
+	}
+	hosts := p.AllowedHosts
+	if hosts == nil {
+		hosts = DefaultModelHosts
+	}
+	for _, host := range hosts {
+		if strings.EqualFold(parsed.Host, strings.TrimSpace(host)) {
+			return true
+		}
+	}
+	return false
+}
+
+// ModelProviderRepository defines the persistence contract for model providers.
+type ModelProviderRepository interface {
+	Create(ctx context.Context, p ModelProvider) (ModelProvider, error)
+	GetByID(ctx context.Context, id, domainID string) (ModelProvider, error)
+	List(ctx context.Context, domainID string) ([]ModelProvider, error)
+	Delete(ctx context.Context, id, domainID string) error
+}
+
+// ModelProviderService manages a domain's model catalogue.
+type ModelProviderService interface {
+	// Create validates and stores a provider, sealing its API key.
+	Create(ctx context.Context, p ModelProvider) (ModelProvider, error)
+	List(ctx context.Context, domainID string) ([]ModelProvider, error)
+	Delete(ctx context.Context, id, domainID string) error
+	// Resolve returns the provider with its API key decrypted.
+	Resolve(ctx context.Context, id, domainID string) (ModelProvider, error)
+}
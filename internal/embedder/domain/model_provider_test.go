@@ -0,0 +1,29 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package domain
+
+import "testing"
+
+func TestModelURLPolicyAllowsExternal(t *testing.T) {
+	for _, allowed := range []string{"https://api.openai.com", "https://api.anthropic.com"} {
+		if !(ModelURLPolicy{}).AllowsExternal(allowed) {
+			t.Fatalf("expected %q to be allowed", allowed)
+		}
+	}
+	for _, blocked := range []string{
+		"http://api.openai.com",
+		"https://api.openai.com/v1",
+		"https://example.com",
+		"https://api.openai.com?key=secret",
+	} {
+		if (ModelURLPolicy{}).AllowsExternal(blocked) {
+			t.Fatalf("expected %q to be blocked", blocked)
+		}
+	}
+
+	custom := ModelURLPolicy{AllowedHosts: []string{"llm.internal.example"}}
+	if !custom.AllowsExternal("https://llm.internal.example") || custom.AllowsExternal("https://api.openai.com") {
+		t.Fatal("expected AllowedHosts to replace the default hosts")
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package domain
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+)
+
+// ErrAddressNotAllowed is returned when a source connector would connect to
+// an address its network policy refuses.
+var ErrAddressNotAllowed = errors.New("address not allowed")
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), which is
+// not publicly routable.
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// SourceNetworkPolicy decides which addresses source connectors that take
+// user-supplied hosts, such as web and SQL sources, may connect to.
+// Non-public addresses and DeniedHosts are refused unless allowlisted.
+type SourceNetworkPolicy struct {
+	// AllowedHosts lists host names, IP addresses and CIDR ranges that may
+	// be reached whatever they resolve to.
+	AllowedHosts []string
+	// DeniedHosts lists hosts refused even at public addresses, such as the
+	// embedder's own database.
+	DeniedHosts []string
+}
+
+// Allowlisted reports whether host, connected to at ip, is in AllowedHosts.
+func (p SourceNetworkPolicy) Allowlisted(host string, ip netip.Addr) bool {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	ip = ip.Unmap()
+	for _, entry := range p.AllowedHosts {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if prefix, err := netip.ParsePrefix(entry); err == nil {
+			if ip.IsValid() && prefix.Contains(ip) {
+				return true
+			}
+			continue
+		}
+		if addr, err := netip.ParseAddr(entry); err == nil {
+			if ip.IsValid() && addr.Unmap() == ip {
+				return true
+			}
+			continue
+		}
+		if host != "" && strings.TrimSuffix(entry, ".") == host {
+			return true
+		}
+	}
+	return false
+}
+
+// Denied reports whether host is one of DeniedHosts.
+func (p SourceNetworkPolicy) Denied(host string) bool {
+	host = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+	for _, entry := range p.DeniedHosts {
+		if strings.TrimSuffix(strings.ToLower(strings.TrimSpace(entry)), ".") == host {
+			return true
+		}
+	}
+	return false
+}
+
+// PublicAddr reports whether ip is a publicly routable unicast address, as
+// opposed to a loopback, private, link-local, shared, multicast or
+// unspecified one.
+func PublicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	switch {
+	case !ip.IsValid(),
+		ip.IsLoopback(),
+		ip.IsPrivate(),
+		ip.IsLinkLocalUnicast(),
+		ip.IsLinkLocalMulticast(),
+		ip.IsInterfaceLocalMulticast(),
+		ip.IsMulticast(),
+		ip.IsUnspecified(),
+		sharedAddressSpace.Contains(ip):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,49 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package domain
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestPublicAddr(t *testing.T) {
+	for _, raw := range []string{"8.8.8.8", "2606:4700:4700::1111"} {
+		if !PublicAddr(netip.MustParseAddr(raw)) {
+			t.Fatalf("expected %s to be public", raw)
+		}
+	}
+	for _, raw := range []string{
+		"127.0.0.1", "::1", "10.1.2.3", "172.17.0.2", "192.168.1.1",
+		"169.254.169.254", "fe80::1", "100.64.0.1", "0.0.0.0", "::ffff:127.0.0.1",
+	} {
+		if PublicAddr(netip.MustParseAddr(raw)) {
+			t.Fatalf("expected %s not to be public", raw)
+		}
+	}
+}
+
+func TestSourceNetworkPolicyAllowlisted(t *testing.T) {
+	policy := SourceNetworkPolicy{AllowedHosts: []string{"wiki.internal", "10.20.0.0/16", "192.168.1.5"}}
+	cases := []struct {
+		host string
+		ip   string
+		want bool
+	}{
+		{"wiki.internal", "10.9.9.9", true},
+		{"WIKI.internal.", "10.9.9.9", true},
+		{"other.internal", "10.20.3.4", true},
+		{"other.internal", "192.168.1.5", true},
+		{"other.internal", "10.21.0.1", false},
+		{"169.254.169.254", "169.254.169.254", false},
+	}
+	for _, tc := range cases {
+		if got := policy.Allowlisted(tc.host, netip.MustParseAddr(tc.ip)); got != tc.want {
+			t.Fatalf("Allowlisted(%q, %s) = %v, want %v", tc.host, tc.ip, got, tc.want)
+		}
+	}
+	if !(SourceNetworkPolicy{DeniedHosts: []string{"Postgres"}}).Denied("postgres") {
+		t.Fatal("expected denied hosts to match case-insensitively")
+	}
+}
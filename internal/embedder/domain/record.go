@@ -5,6 +5,8 @@ package domain
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 )
 
@@ -19,6 +21,14 @@ const (
 	RecordFormatCode  RecordFormat = "code"
 	RecordFormatImage RecordFormat = "image"
 	RecordFormatLink  RecordFormat = "link"
+	RecordFormatXLSX  RecordFormat = "xlsx"
+	RecordFormatPPTX  RecordFormat = "pptx"
+	RecordFormatODT   RecordFormat = "odt"
+	RecordFormatODS   RecordFormat = "ods"
+	RecordFormatRTF   RecordFormat = "rtf"
+	// RecordFormatArchive is a ZIP/TAR archive or an email container. It is
+	// expanded into child records instead of being indexed itself.
+	RecordFormatArchive RecordFormat = "archive"
 )
 
 // RecordStatus represents the processing state of a record.
@@ -62,6 +72,10 @@ type Record struct {
 	// populated for folder-tree ingests (Google Drive); nil otherwise.
 	FolderPath *string
 	FolderID   *string
+	// ParentID is the archive or email record this record was unpacked from,
+	// or the record of the page or message an attachment belongs to; nil for
+	// other records.
+	ParentID *string
 
 	// Content metadata populated after successful ingestion.
 	Description         string
@@ -70,8 +84,11 @@ type Record struct {
 	PageCount           *int
 	IngestTotalChunks   *int
 	IngestIndexedChunks *int
+	// IngestReusedChunks counts the indexed chunks kept from the previous
+	// ingest because their text did not change.
+	IngestReusedChunks *int
 	// IngestStage is the current (or last) ingest phase: extracting, chunking,
-	// embedding. Nil once queued or after a successful index; on failure it
+	// embedding, enriching. Nil once queued or after a successful index; on failure it
 	// persists so the UI can show where ingest stopped.
 	IngestStage *string
 
@@ -80,6 +97,13 @@ type Record struct {
 	SourceVersion    string
 	SourceModifiedAt *time.Time
 
+	// ContentHash is the SHA-256 of the text extracted at the last ingest;
+	// records with the same hash hold the same document.
+	ContentHash string
+	// Enrichment is the LLM summary and metadata of the record's text; nil
+	// until the record has been enriched.
+	Enrichment *RecordEnrichment
+
 	Error     *string
 	CreatedAt time.Time
 	UpdatedAt time.Time
@@ -110,6 +134,8 @@ type RecordFilter struct {
 	// FolderPrefix matches records whose folder_path equals or is nested under
 	// the given path (prefix match), e.g. "/Docs/2024".
 	FolderPrefix *string
+	// ParentID lists the records unpacked from one archive record.
+	ParentID *string
 }
 
 // IngestResult holds post-ingestion metadata written back to the record.
@@ -120,6 +146,39 @@ type IngestResult struct {
 	Description string
 	Format      *RecordFormat
 	MimeType    string
+	// ContentHash is the ContentHash of the extracted text.
+	ContentHash string
+}
+
+// RecordEnrichment is document-level metadata an LLM derived from a
+// record's text during ingest.
+type RecordEnrichment struct {
+	Title   string `json:"title,omitempty"`
+	Summary string `json:"summary,omitempty"`
+	// Language is the ISO 639-1 code of the text's main language.
+	Language string   `json:"language,omitempty"`
+	Entities []string `json:"entities,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+	Model    string   `json:"model,omitempty"`
+	// ContentHash is the record content hash the enrichment was made from.
+	// A record is enriched again only when its content hash changes.
+	ContentHash string    `json:"content_hash"`
+	EnrichedAt  time.Time `json:"enriched_at"`
+}
+
+// ContentHash returns the hex SHA-256 of text, the key under which records
+// and chunks with identical content are recognised.
+func ContentHash(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return hex.EncodeToString(sum[:])
+}
+
+// IngestProgress is how far the embedding stage of an ingest has got.
+type IngestProgress struct {
+	IndexedChunks int
+	TotalChunks   int
+	// ReusedChunks are the indexed chunks kept from the previous ingest.
+	ReusedChunks int
 }
 
 // RecordUpsertState describes what a sync operation changed.
@@ -144,16 +203,23 @@ type RecordRepository interface {
 	List(ctx context.Context, domainID string, f RecordFilter, p Page) (RecordPage, error)
 	Delete(ctx context.Context, id, domainID string) error
 	DeleteBySourceExternalIDs(ctx context.Context, domainID, sourceID string, externalIDs []string) (int, error)
+	// SourceVersions returns the source version of each listed external ID
+	// the source has a record for.
+	SourceVersions(ctx context.Context, domainID, sourceID string, externalIDs []string) (map[string]string, error)
+	// ArchiveIDs returns the IDs of the archive records files were unpacked from,
+	// among the listed external IDs of the source (all of its records when
+	// externalIDs is nil) and the records unpacked from them.
+	ArchiveIDs(ctx context.Context, domainID, sourceID string, externalIDs []string) ([]string, error)
 	UpsertFromSource(ctx context.Context, r Record) (RecordUpsertResult, error)
-	// ListQueued returns up to limit records in "queued" status, across all domains.
-	ListQueued(ctx context.Context, limit int) ([]Record, error)
 	// UpdateStatus transitions a record to the given status (and clears/sets error).
 	UpdateStatus(ctx context.Context, id string, s RecordStatus, errMsg string) error
-	UpdateIngestProgress(ctx context.Context, id string, indexedChunks, totalChunks int) error
+	UpdateIngestProgress(ctx context.Context, id string, p IngestProgress) error
 	// UpdateIngestStage records the current ingest phase (extracting/chunking/embedding).
 	UpdateIngestStage(ctx context.Context, id, stage string) error
 	// UpdateAfterIngest writes chunk_count and size_bytes and marks the record indexed.
 	UpdateAfterIngest(ctx context.Context, id string, res IngestResult) error
+	// UpdateEnrichment stores the record's enrichment; nil clears it.
+	UpdateEnrichment(ctx context.Context, id string, e *RecordEnrichment) error
 }
 
 // RecordService defines the business-logic contract for records.
@@ -0,0 +1,94 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// ReembedJobStatus tracks a re-embedding job through its lifecycle.
+type ReembedJobStatus string
+
+const (
+	ReembedJobQueued    ReembedJobStatus = "queued"
+	ReembedJobRunning   ReembedJobStatus = "running"
+	ReembedJobCompleted ReembedJobStatus = "completed"
+	ReembedJobFailed    ReembedJobStatus = "failed"
+	ReembedJobCancelled ReembedJobStatus = "cancelled"
+)
+
+// ReembedScope selects the chunks a re-embedding job covers. Empty fields
+// other than DomainID match everything.
+type ReembedScope struct {
+	DomainID string
+	SourceID string
+	Format   RecordFormat
+	// Profile limits the job to one embedding profile.
+	Profile string
+}
+
+// ReembedJob re-embeds the stored text of chunks whose vectors were produced
+// by a model other than their profile's current one. New vectors are staged
+// next to the live ones, which keep serving searches, and swapped in
+// together once every chunk in scope has one.
+type ReembedJob struct {
+	ID     string
+	UserID string
+	ReembedScope
+	Status ReembedJobStatus
+	// TotalChunks is the number of chunks that needed a new vector when the
+	// job started; EmbeddedChunks counts those staged so far.
+	TotalChunks    int64
+	EmbeddedChunks int64
+	Error          string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	StartedAt      *time.Time
+	CompletedAt    *time.Time
+}
+
+// Active reports whether the job is still queued or running.
+func (j ReembedJob) Active() bool {
+	return j.Status == ReembedJobQueued || j.Status == ReembedJobRunning
+}
+
+// Progress returns the fraction of chunks staged, from 0 to 1.
+func (j ReembedJob) Progress() float64 {
+	if j.Status == ReembedJobCompleted {
+		return 1
+	}
+	if j.TotalChunks <= 0 {
+		return 0
+	}
+	return min(float64(j.EmbeddedChunks)/float64(j.TotalChunks), 1)
+}
+
+// ReembedJobRepository defines the persistence contract for re-embedding jobs.
+type ReembedJobRepository interface {
+	// Create stores a queued job. It returns ErrConflict when the domain
+	// already has an active job.
+	Create(ctx context.Context, job ReembedJob) (ReembedJob, error)
+	Get(ctx context.Context, id, domainID string) (ReembedJob, error)
+	List(ctx context.Context, domainID string, limit int) ([]ReembedJob, error)
+	// ClaimNext marks the oldest queued job running and returns it, also
+	// taking over running jobs with no progress for staleAfter, whose worker
+	// is assumed gone. It returns ErrNotFound when there is nothing to run.
+	ClaimNext(ctx context.Context, staleAfter time.Duration) (ReembedJob, error)
+	// UpdateProgress records progress on a running job and returns its
+	// current status, so the worker notices cancellation.
+	UpdateProgress(ctx context.Context, id string, total, embedded int64) (ReembedJobStatus, error)
+	Finish(ctx context.Context, id string, status ReembedJobStatus, errMsg string) error
+	// Cancel cancels an active job. It returns ErrConflict when the job has
+	// already finished.
+	Cancel(ctx context.Context, id, domainID string) (ReembedJob, error)
+}
+
+// ReembedService starts and reports on re-embedding jobs.
+type ReembedService interface {
+	Start(ctx context.Context, job ReembedJob) (ReembedJob, error)
+	Get(ctx context.Context, id, domainID string) (ReembedJob, error)
+	List(ctx context.Context, domainID string) ([]ReembedJob, error)
+	Cancel(ctx context.Context, id, domainID string) (ReembedJob, error)
+}
@@ -13,6 +13,42 @@ type RetrievalQuery struct {
 	TopK      int
 }
 
+// VectorDistance is the metric used to compare embedding vectors.
+type VectorDistance string
+
+// Supported vector distances. An embedding profile with no distance set uses
+// VectorDistanceL2.
+const (
+	VectorDistanceL2           VectorDistance = "l2"
+	VectorDistanceCosine       VectorDistance = "cosine"
+	VectorDistanceInnerProduct VectorDistance = "inner_product"
+)
+
+// Valid reports whether d is a supported distance.
+func (d VectorDistance) Valid() bool {
+	switch d {
+	case VectorDistanceL2, VectorDistanceCosine, VectorDistanceInnerProduct:
+		return true
+	}
+	return false
+}
+
+// LanguageDefaults chooses the language, as an ISO 639-1 code, of chunks
+// and queries whose language cannot be detected.
+type LanguageDefaults struct {
+	Default string
+	// Domains overrides Default for individual domains.
+	Domains map[string]string
+}
+
+// For returns the default language of domainID.
+func (l LanguageDefaults) For(domainID string) string {
+	if lang, ok := l.Domains[domainID]; ok {
+		return lang
+	}
+	return l.Default
+}
+
 // ChunkMatch is a single retrieved chunk with source metadata.
 type ChunkMatch struct {
 	ChunkID      string
@@ -27,6 +27,12 @@ const (
 	SourceTypeOneDrive    SourceType = "onedrive"
 	SourceTypeSharePoint  SourceType = "sharepoint"
 	SourceTypeS3          SourceType = "s3"
+	SourceTypeWeb         SourceType = "web"
+	SourceTypeGit         SourceType = "git"
+	SourceTypeConfluence  SourceType = "confluence"
+	SourceTypeIMAP        SourceType = "imap"
+	SourceTypeSQL         SourceType = "sql"
+	SourceTypeWebhook     SourceType = "webhook"
 )
 
 var supportedSourceTypes = []SourceType{
@@ -36,6 +42,12 @@ var supportedSourceTypes = []SourceType{
 	SourceTypeMicrosoft,
 	SourceTypeOneDrive,
 	SourceTypeSharePoint,
+	SourceTypeWeb,
+	SourceTypeGit,
+	SourceTypeConfluence,
+	SourceTypeIMAP,
+	SourceTypeSQL,
+	SourceTypeWebhook,
 }
 
 var userCreatableSourceTypes = []SourceType{
@@ -44,6 +56,12 @@ var userCreatableSourceTypes = []SourceType{
 	SourceTypeMicrosoft,
 	SourceTypeOneDrive,
 	SourceTypeSharePoint,
+	SourceTypeWeb,
+	SourceTypeGit,
+	SourceTypeConfluence,
+	SourceTypeIMAP,
+	SourceTypeSQL,
+	SourceTypeWebhook,
 }
 
 var sourceProviderAliases = map[SourceType]SourceType{
@@ -170,6 +188,11 @@ type S3Config struct {
 	ScopePaths      []string `json:"scope_paths,omitempty"`
 	SelectedPaths   []string `json:"selected_paths,omitempty"`
 	ConfigRef       string   `json:"config_ref,omitempty"`
+	// NotificationARN is the bucket notification target (e.g. a MinIO
+	// webhook, arn:minio:sqs::primary:webhook) that object events are
+	// published to. When set, the embedder adds it to the bucket's
+	// notification configuration.
+	NotificationARN string `json:"notification_arn,omitempty"`
 }
 
 // MicrosoftConfig is the expected shape of Source.Config for SourceTypeMicrosoft.
@@ -188,6 +211,164 @@ type MicrosoftConfig struct {
 	ConfigRef     string   `json:"config_ref,omitempty"`
 }
 
+// WebConfig is the expected shape of Source.Config for SourceTypeWeb.
+// Pages are discovered from seed URLs and/or a sitemap and crawled within the
+// allowed hosts and path scopes.
+type WebConfig struct {
+	SeedURLs   []string `json:"seed_urls,omitempty"`
+	SitemapURL string   `json:"sitemap_url,omitempty"`
+	// AllowedHosts defaults to the hosts of the seed and sitemap URLs.
+	AllowedHosts []string `json:"allowed_hosts,omitempty"`
+	// ScopePaths restricts crawling to URL path prefixes; empty allows all.
+	ScopePaths   []string `json:"scope_paths,omitempty"`
+	ExcludePaths []string `json:"exclude_paths,omitempty"`
+	// MaxDepth is the number of links followed from a seed; 0 indexes seeds only.
+	MaxDepth *int `json:"max_depth,omitempty"`
+	MaxPages int  `json:"max_pages,omitempty"`
+	// RequestsPerSecond caps requests per host; robots.txt Crawl-delay wins
+	// when it is slower.
+	RequestsPerSecond float64 `json:"requests_per_second,omitempty"`
+	UserAgent         string  `json:"user_agent,omitempty"`
+}
+
+// GitConfig is the expected shape of Source.Config for SourceTypeGit.
+type GitConfig struct {
+	// RepoURL is an https:// or ssh:// URL, or an scp-like git@host:path.
+	RepoURL string `json:"repo_url"`
+	// Branch defaults to the remote HEAD.
+	Branch string `json:"branch,omitempty"`
+	// Include and Exclude are path globs; "**" crosses directories and
+	// patterns without a slash match file names at any depth.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+	// Username and Token authenticate HTTPS remotes.
+	Username string `json:"username,omitempty"`
+	Token    string `json:"token,omitempty"`
+	// SSHPrivateKey authenticates SSH remotes. Without SSHKnownHosts the host
+	// key is trusted on first use and pinned in the cache directory.
+	SSHPrivateKey string `json:"ssh_private_key,omitempty"`
+	SSHKnownHosts string `json:"ssh_known_hosts,omitempty"`
+	// WebURL and Forge build citation links; both are derived from RepoURL
+	// when empty. Forge is github, gitlab, bitbucket or gitea.
+	WebURL      string `json:"web_url,omitempty"`
+	Forge       string `json:"forge,omitempty"`
+	MaxFileSize int64  `json:"max_file_size,omitempty"`
+}
+
+// ConfluenceConfig is the expected shape of Source.Config for
+// SourceTypeConfluence.
+type ConfluenceConfig struct {
+	// BaseURL is the wiki root, e.g. https://acme.atlassian.net/wiki.
+	BaseURL string `json:"base_url"`
+	// Email and APIToken authenticate against Confluence Cloud; AccessToken
+	// is a Data Center personal access token sent as a bearer token.
+	Email       string `json:"email,omitempty"`
+	APIToken    string `json:"api_token,omitempty"`
+	AccessToken string `json:"access_token,omitempty"`
+	// SpaceKeys limits the sync to spaces; empty syncs every visible space.
+	SpaceKeys []string `json:"space_keys,omitempty"`
+	// PageIDs limits the sync to the given pages and their descendants.
+	PageIDs         []string `json:"page_ids,omitempty"`
+	SkipAttachments bool     `json:"skip_attachments,omitempty"`
+}
+
+// IMAPConfig is the expected shape of Source.Config for SourceTypeIMAP.
+type IMAPConfig struct {
+	Host string `json:"host"`
+	// Port defaults to 993 for "tls" and 143 for "starttls".
+	Port int `json:"port,omitempty"`
+	// Security is "tls" (default) or "starttls".
+	Security string `json:"security,omitempty"`
+	Username string `json:"username"`
+	Password string `json:"password,omitempty"`
+	// Folders defaults to INBOX.
+	Folders []string `json:"folders,omitempty"`
+	// SinceDays limits the sync to messages received in the last days;
+	// older messages are pruned. 0 syncs the whole folder.
+	SinceDays         int   `json:"since_days,omitempty"`
+	SkipAttachments   bool  `json:"skip_attachments,omitempty"`
+	MaxAttachmentSize int64 `json:"max_attachment_size,omitempty"`
+}
+
+// SQLConfig is the expected shape of Source.Config for SourceTypeSQL.
+type SQLConfig struct {
+	// Driver is "postgres" or "mysql".
+	Driver string `json:"driver"`
+	// DSN is the driver's connection string. It usually embeds a password
+	// and is stored as a credential.
+	DSN     string     `json:"dsn,omitempty"`
+	Queries []SQLQuery `json:"queries"`
+}
+
+// SQLQuery maps the rows of one query to records.
+type SQLQuery struct {
+	// Name identifies the query; it prefixes record IDs and is the folder
+	// the rows are listed under.
+	Name  string `json:"name"`
+	Query string `json:"query"`
+	// IDColumn holds each row's primary key.
+	IDColumn string `json:"id_column"`
+	// UpdatedAtColumn holds the row's last modification; when empty a hash of
+	// the rendered content is used as the version.
+	UpdatedAtColumn string `json:"updated_at_column,omitempty"`
+	TitleColumn     string `json:"title_column,omitempty"`
+	// ContentTemplate is a text/template executed with the row's columns;
+	// empty renders every column as "name: value".
+	ContentTemplate string `json:"content_template,omitempty"`
+	// URLTemplate renders the row's citation link.
+	URLTemplate string `json:"url_template,omitempty"`
+	MaxRows     int    `json:"max_rows,omitempty"`
+}
+
+// RedactedSecret replaces credential values in API responses. Clients may send
+// it back unchanged; it is never stored.
+const RedactedSecret = "__redacted__"
+
+var sourceCredentialFields = map[SourceType][]string{
+	SourceTypeGoogleDrive: {"service_account_json", "client_secret", "access_token", "refresh_token"},
+	SourceTypeS3:          {"secret_access_key", "session_token"},
+	SourceTypeMicrosoft:   {"client_secret", "access_token", "refresh_token"},
+	SourceTypeGit:         {"token", "ssh_private_key"},
+	SourceTypeConfluence:  {"api_token", "access_token"},
+	SourceTypeIMAP:        {"password"},
+	SourceTypeSQL:         {"dsn"},
+	SourceTypeWebhook:     {"secret"},
+}
+
+// SourceCredentialFields returns the Source.Config keys that hold credentials
+// for a source type. They are encrypted at rest and redacted in responses.
+func SourceCredentialFields(t SourceType) []string {
+	if target, ok := sourceProviderAliases[t]; ok {
+		t = target
+	}
+	return sourceCredentialFields[t]
+}
+
+// RedactSourceConfig replaces non-empty credential values in raw with
+// RedactedSecret. Configs that are not JSON objects are returned unchanged.
+func RedactSourceConfig(t SourceType, raw json.RawMessage) json.RawMessage {
+	fields := SourceCredentialFields(t)
+	if len(fields) == 0 || len(raw) == 0 {
+		return raw
+	}
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil || doc == nil {
+		return raw
+	}
+	redacted, _ := json.Marshal(RedactedSecret)
+	for _, field := range fields {
+		var value string
+		if err := json.Unmarshal(doc[field], &value); err == nil && value != "" {
+			doc[field] = redacted
+		}
+	}
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return raw
+	}
+	return out
+}
+
 // GoogleDriveCredentialUpdate carries mutable OAuth fields for an existing source.
 type GoogleDriveCredentialUpdate struct {
 	AccessToken  string
@@ -222,6 +403,8 @@ type SourceSyncResult struct {
 	Updated    uint64
 	Unchanged  uint64
 	Deleted    uint64
+	// Cursor is the change-feed position after a SyncChanges pass.
+	Cursor string
 }
 
 // SourceRepository defines the persistence contract for sources.
@@ -248,9 +431,16 @@ type SourceService interface {
 	Delete(ctx context.Context, id, domainID string) error
 	UpdateGoogleDriveCredentials(ctx context.Context, id, domainID string, update GoogleDriveCredentialUpdate) (Source, error)
 	UpdateGoogleDriveSelection(ctx context.Context, id, domainID string, update GoogleDriveSelectionUpdate) (Source, error)
+	// RotateWebhookSecret replaces a webhook source's signing secret and
+	// returns the new one; it is not retrievable afterwards.
+	RotateWebhookSecret(ctx context.Context, id, domainID string) (Source, string, error)
 }
 
 // SourceSyncService defines source-specific synchronization behavior.
 type SourceSyncService interface {
 	Sync(ctx context.Context, id, domainID string) (SourceSyncResult, error)
+	// SyncChanges syncs only the items changed since sub.Cursor, or those
+	// named by hint, falling back to a full sync when the change feed cannot
+	// be resumed.
+	SyncChanges(ctx context.Context, sub SourceSubscription, hint SourceChangeHint) (SourceSyncResult, error)
 }
@@ -3,7 +3,10 @@
 
 package domain
 
-import "testing"
+import (
+	"encoding/json"
+	"testing"
+)
 
 func TestSourceTypeHelpers(t *testing.T) {
 	if !IsSupportedSourceType(SourceTypeLocalFS) {
@@ -33,3 +36,21 @@ func TestHumanSourceTypeList(t *testing.T) {
 		t.Fatalf("unexpected list format: %q", got)
 	}
 }
+
+func TestRedactSourceConfig(t *testing.T) {
+	raw := json.RawMessage(`{"bucket":"docs","access_key_id":"AKIA","secret_access_key":"s3cr3t","session_token":""}`)
+	var got map[string]string
+	if err := json.Unmarshal(RedactSourceConfig(SourceTypeS3, raw), &got); err != nil {
+		t.Fatalf("decode redacted config: %v", err)
+	}
+	if got["secret_access_key"] != RedactedSecret {
+		t.Fatalf("expected secret_access_key to be redacted, got %q", got["secret_access_key"])
+	}
+	if got["session_token"] != "" || got["bucket"] != "docs" || got["access_key_id"] != "AKIA" {
+		t.Fatalf("unexpected redacted config: %v", got)
+	}
+
+	if fields := SourceCredentialFields(SourceTypeSharePoint); len(fields) == 0 {
+		t.Fatal("expected sharepoint to inherit microsoft credential fields")
+	}
+}
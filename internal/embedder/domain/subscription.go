@@ -0,0 +1,93 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package domain
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrInvalidNotification is returned when a change notification does not
+// carry the token of the subscription it was delivered to.
+var ErrInvalidNotification = errors.New("invalid change notification")
+
+// SourceSubscription is a change-notification subscription registered with
+// the system behind a source (a Graph subscription, a Drive changes channel
+// or an S3 bucket notification).
+type SourceSubscription struct {
+	ID       string
+	SourceID string
+	DomainID string
+	// Provider is the provider-backed source type, e.g. microsoft for
+	// onedrive and sharepoint sources.
+	Provider SourceType
+	// ExternalID is the provider's subscription or channel ID. It is empty
+	// for S3, where notifications are configured on the bucket.
+	ExternalID string
+	// Resource is what the provider watches: the Graph drive, the Drive
+	// channel's resource ID or the S3 bucket.
+	Resource string
+	// ClientState is the token the provider sends back with every
+	// notification.
+	ClientState string
+	// Cursor is the change-feed position targeted syncs resume from: a Graph
+	// delta link or a Drive page token.
+	Cursor    string
+	ExpiresAt *time.Time
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// SourceRef identifies a source across domains.
+type SourceRef struct {
+	ID       string
+	DomainID string
+}
+
+// ChangeNotification is a notification as delivered to a callback URL.
+type ChangeNotification struct {
+	Header http.Header
+	Body   []byte
+}
+
+// SourceChangeHint carries the items a notification names. Graph and Drive
+// notifications name none; their changes are read from the change feed.
+type SourceChangeHint struct {
+	ExternalIDs []string
+}
+
+// SourceSubscriptionRepository defines the persistence contract for
+// change-notification subscriptions.
+type SourceSubscriptionRepository interface {
+	Create(ctx context.Context, sub SourceSubscription) (SourceSubscription, error)
+	GetByID(ctx context.Context, id string) (SourceSubscription, error)
+	ListByProvider(ctx context.Context, provider SourceType) ([]SourceSubscription, error)
+	// ListExpiring returns subscriptions that expire before the given time.
+	ListExpiring(ctx context.Context, before time.Time) ([]SourceSubscription, error)
+	// ListUnsubscribedSources returns sync-enabled sources of the given types
+	// that have no subscription.
+	ListUnsubscribedSources(ctx context.Context, types []SourceType) ([]SourceRef, error)
+	// Update stores the provider-assigned fields of a subscription: its
+	// external ID, resource and expiry. The cursor is only moved by
+	// UpdateCursor, so a renewal cannot rewind it.
+	Update(ctx context.Context, sub SourceSubscription) (SourceSubscription, error)
+	UpdateCursor(ctx context.Context, id, cursor string) error
+	Delete(ctx context.Context, id string) error
+}
+
+// SourceNotificationService receives change notifications and turns them
+// into targeted syncs.
+type SourceNotificationService interface {
+	// Validate checks that a subscription exists; providers probe callback
+	// URLs before they deliver to them.
+	Validate(ctx context.Context, subscriptionID string) error
+	// Notify handles a notification delivered to one subscription's
+	// callback URL.
+	Notify(ctx context.Context, subscriptionID string, n ChangeNotification) error
+	// NotifyProvider handles notifications a provider delivers to one
+	// endpoint for all of its subscriptions, such as S3 bucket events.
+	NotifyProvider(ctx context.Context, provider SourceType, n ChangeNotification) error
+}
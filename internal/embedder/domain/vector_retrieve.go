@@ -13,6 +13,17 @@ type VectorChunk struct {
 	ChunkIndex  int      `json:"chunk_index"`
 	Content     string   `json:"content"`
 	Score       *float64 `json:"score,omitempty"`
+	// Duplicates are the other records holding the same passage, collapsed
+	// into this chunk so it is returned once.
+	Duplicates []ChunkRef `json:"duplicates,omitempty"`
+}
+
+// ChunkRef locates a chunk of a record.
+type ChunkRef struct {
+	RecordID    string `json:"record_id"`
+	RecordName  string `json:"record_name"`
+	ExternalURL string `json:"external_url,omitempty"`
+	ChunkIndex  int    `json:"chunk_index"`
 }
 
 // VectorRetrieveService retrieves relevant chunks via embedding similarity.
@@ -0,0 +1,69 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package domain
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrInvalidSignature is returned when a webhook request is not signed with
+// the source's secret or its timestamp is outside the accepted window.
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// ErrInvalidWebhookBatch is returned when a pushed batch fails validation.
+var ErrInvalidWebhookBatch = errors.New("invalid webhook batch")
+
+// WebhookConfig is the expected shape of Source.Config for SourceTypeWebhook.
+type WebhookConfig struct {
+	// Secret signs pushed batches with HMAC-SHA256. It is generated when the
+	// source is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+// WebhookDocument is one document pushed to a webhook source. Exactly one
+// of Text and Content is set.
+type WebhookDocument struct {
+	ID    string
+	Title string
+	Text  string
+	// Content holds binary documents such as PDFs, decoded from base64.
+	Content  []byte
+	MimeType string
+	URL      string
+	// Metadata is rendered as "key: value" lines ahead of the text.
+	Metadata   map[string]string
+	FolderPath string
+	// Version identifies the document's revision; resending a document
+	// with the same ID and version is a no-op. Empty uses a content hash.
+	Version    string
+	ModifiedAt *time.Time
+}
+
+// WebhookBatch is the body of one webhook request.
+type WebhookBatch struct {
+	Documents []WebhookDocument
+	// Deleted lists document IDs to remove.
+	Deleted []string
+}
+
+// WebhookIngestResult counts what a batch changed.
+type WebhookIngestResult struct {
+	Created   uint64
+	Updated   uint64
+	Unchanged uint64
+	Deleted   uint64
+}
+
+// WebhookService accepts documents pushed to webhook sources.
+type WebhookService interface {
+	// Lookup returns the webhook source a request is addressed to, checking
+	// everything but the signature, so bad requests are refused before
+	// their body is read.
+	Lookup(ctx context.Context, id, domainID, timestamp string) (Source, error)
+	// Verify checks a request's HMAC signature with src's secret.
+	Verify(src Source, timestamp, signature string, body []byte) error
+	Ingest(ctx context.Context, src Source, batch WebhookBatch) (WebhookIngestResult, error)
+}
@@ -12,6 +12,8 @@ import (
 	"net/http"
 	"strings"
 	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
 )
 
 // Client calls an Ollama embedding endpoint.
@@ -60,11 +62,7 @@ func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error)
 
 	if resp.StatusCode != http.StatusOK {
 		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 2048))
-		detail := strings.TrimSpace(string(msg))
-		if detail == "" {
-			return nil, fmt.Errorf("ollama embed status %d", resp.StatusCode)
-		}
-		return nil, fmt.Errorf("ollama embed status %d: %s", resp.StatusCode, detail)
+		return nil, &domain.StatusError{Op: "ollama embed", StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
 	}
 
 	var res response
@@ -10,6 +10,8 @@ import (
 	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
 )
 
 // Client calls the OpenAI embeddings endpoint.
@@ -73,7 +75,7 @@ func (c *Client) Embed(ctx context.Context, texts []string) ([][]float32, error)
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("openai embed status %d", resp.StatusCode)
+		return nil, &domain.StatusError{Op: "openai embed", StatusCode: resp.StatusCode}
 	}
 
 	var res response
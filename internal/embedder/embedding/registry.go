@@ -6,6 +6,7 @@ package embedding
 import (
 	"context"
 	"fmt"
+	"sort"
 
 	"github.com/ultravioletrs/cube/internal/embedder/domain"
 	"github.com/ultravioletrs/cube/internal/embedder/embedding/ollama"
@@ -25,6 +26,32 @@ type ProfileConfig struct {
 	Model      string `json:"model"`
 	Dimensions int    `json:"dimensions"`
 	APIKey     string `json:"api_key,omitempty"`
+	// Distance is the metric vectors from this profile are compared with.
+	// Use cosine or inner_product when the model documents it; defaults to l2.
+	Distance domain.VectorDistance `json:"distance,omitempty"`
+	// Previous is the model the profile used before its current one. Chunks
+	// it embedded stay searchable with it until a re-embedding job moves them
+	// to the current model; remove it once that is done.
+	Previous *ProfileConfig `json:"previous,omitempty"`
+}
+
+// ModelID identifies the vector space a profile's embeddings live in. It is
+// stored with every chunk, so vectors from another model are never compared
+// with it.
+func (c ProfileConfig) ModelID() string {
+	return fmt.Sprintf("%s/%s/%d", c.Provider, c.Model, c.Dimensions)
+}
+
+// searchKey is the part of a profile configuration that decides which query
+// embedding its vectors can be compared with.
+type searchKey struct {
+	provider, baseURL, model string
+	dimensions               int
+	distance                 domain.VectorDistance
+}
+
+func (c ProfileConfig) searchKey() searchKey {
+	return searchKey{c.Provider, c.BaseURL, c.Model, c.Dimensions, c.Distance}
 }
 
 // SelectionConfig maps records to embedding profiles.
@@ -41,9 +68,33 @@ type Config struct {
 	Selection SelectionConfig          `json:"selection"`
 }
 
+// Profile is a configured embedding client together with its profile name
+// and model. Both are stored with every chunk the profile embeds, so vectors
+// from different models are never compared with each other.
+type Profile struct {
+	Name     string
+	Model    string
+	Distance domain.VectorDistance
+	Embedder
+}
+
+// SearchGroup is one embedding space to search: a client to embed the query
+// with and the profiles whose stored vectors of Model it is comparable to.
+// Profiles configured with the same provider, model, dimensions and distance
+// share a group, so a query is embedded once per distinct model rather than
+// once per profile.
+type SearchGroup struct {
+	Profiles []string
+	Model    string
+	Distance domain.VectorDistance
+	Embedder
+}
+
 // Registry resolves a record to the embedding client configured for it.
 type Registry struct {
 	clients   map[string]Embedder
+	previous  map[string]Embedder
+	profiles  map[string]ProfileConfig
 	selection SelectionConfig
 }
 
@@ -57,12 +108,28 @@ func NewRegistry(cfg Config) (*Registry, error) {
 	}
 
 	clients := make(map[string]Embedder, len(cfg.Profiles))
+	previous := make(map[string]Embedder)
+	profiles := make(map[string]ProfileConfig, len(cfg.Profiles))
 	for name, profile := range cfg.Profiles {
-		client, err := newClient(profile)
+		client, err := newProfileClient(&profile)
 		if err != nil {
 			return nil, fmt.Errorf("configure embedding profile %q: %w", name, err)
 		}
 		clients[name] = client
+		if profile.Previous != nil {
+			prev := *profile.Previous
+			prev.Previous = nil
+			prevClient, err := newProfileClient(&prev)
+			if err != nil {
+				return nil, fmt.Errorf("configure previous model of embedding profile %q: %w", name, err)
+			}
+			if prev.ModelID() == profile.ModelID() {
+				return nil, fmt.Errorf("embedding profile %q: previous model is the current model", name)
+			}
+			profile.Previous = &prev
+			previous[name] = prevClient
+		}
+		profiles[name] = profile
 	}
 
 	if _, ok := clients[cfg.Selection.DefaultProfile]; !ok {
@@ -81,12 +148,14 @@ func NewRegistry(cfg Config) (*Registry, error) {
 
 	return &Registry{
 		clients:   clients,
+		previous:  previous,
+		profiles:  profiles,
 		selection: cfg.Selection,
 	}, nil
 }
 
-// ForRecord returns the configured embedding client for a record.
-func (r *Registry) ForRecord(rec domain.Record) (Embedder, error) {
+// ForRecord returns the configured embedding profile for a record.
+func (r *Registry) ForRecord(rec domain.Record) (Profile, error) {
 	profileName := r.selection.DefaultProfile
 	if name, ok := r.selection.ByRecordFormat[rec.Format]; ok {
 		profileName = name
@@ -95,12 +164,104 @@ func (r *Registry) ForRecord(rec domain.Record) (Embedder, error) {
 			profileName = name
 		}
 	}
+	return r.Profile(profileName)
+}
 
-	client, ok := r.clients[profileName]
+// Profile returns the named profile with its current model.
+func (r *Registry) Profile(name string) (Profile, error) {
+	client, ok := r.clients[name]
 	if !ok {
-		return nil, fmt.Errorf("embedding profile %q is not configured", profileName)
+		return Profile{}, fmt.Errorf("embedding profile %q is not configured", name)
+	}
+	cfg := r.profiles[name]
+	return Profile{Name: name, Model: cfg.ModelID(), Distance: cfg.Distance, Embedder: client}, nil
+}
+
+// Previous returns the model the named profile used before its current one,
+// when one is configured.
+func (r *Registry) Previous(name string) (Profile, bool) {
+	client, ok := r.previous[name]
+	if !ok {
+		return Profile{}, false
+	}
+	cfg := *r.profiles[name].Previous
+	return Profile{Name: name, Model: cfg.ModelID(), Distance: cfg.Distance, Embedder: client}, true
+}
+
+// Default returns the default profile.
+func (r *Registry) Default() Profile {
+	profile, _ := r.Profile(r.selection.DefaultProfile)
+	return profile
+}
+
+// ProfileNames returns the profiles the selection can route records to, in
+// name order.
+func (r *Registry) ProfileNames() []string {
+	selected := map[string]struct{}{r.selection.DefaultProfile: {}}
+	for _, name := range r.selection.ByRecordFormat {
+		selected[name] = struct{}{}
+	}
+	for _, name := range r.selection.BySourceType {
+		selected[name] = struct{}{}
+	}
+	names := make([]string, 0, len(selected))
+	for name := range selected {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SearchGroups returns the embedding spaces retrieval has to cover: one per
+// distinct model among the profiles the selection can route records to,
+// including their previous models while those are configured. The default
+// profile's current model comes first, then the others by profile name.
+func (r *Registry) SearchGroups() []SearchGroup {
+	var groups []SearchGroup
+	byModel := make(map[searchKey]int)
+	add := func(name string, cfg ProfileConfig, client Embedder) {
+		key := cfg.searchKey()
+		if i, ok := byModel[key]; ok {
+			groups[i].Profiles = append(groups[i].Profiles, name)
+			return
+		}
+		byModel[key] = len(groups)
+		groups = append(groups, SearchGroup{
+			Profiles: []string{name},
+			Model:    cfg.ModelID(),
+			Distance: cfg.Distance,
+			Embedder: client,
+		})
+	}
+
+	def := r.selection.DefaultProfile
+	add(def, r.profiles[def], r.clients[def])
+	names := r.ProfileNames()
+	for _, name := range names {
+		if name != def {
+			add(name, r.profiles[name], r.clients[name])
+		}
+	}
+	for _, name := range names {
+		if prev := r.profiles[name].Previous; prev != nil {
+			add(name, *prev, r.previous[name])
+		}
+	}
+	for i := range groups {
+		sort.Strings(groups[i].Profiles)
+	}
+	return groups
+}
+
+// newProfileClient fills in defaults on cfg and builds its client.
+func newProfileClient(cfg *ProfileConfig) (Embedder, error) {
+	if cfg.Distance == "" {
+		cfg.Distance = domain.VectorDistanceL2
+	}
+	if !cfg.Distance.Valid() {
+		return nil, fmt.Errorf("unknown distance %q", cfg.Distance)
 	}
-	return client, nil
+	return newClient(*cfg)
 }
 
 func newClient(cfg ProfileConfig) (Embedder, error) {
@@ -74,3 +74,113 @@ func TestRegistryForRecordByFormat(t *testing.T) {
 		})
 	}
 }
+
+func TestRegistrySearchGroupsSharesQueryEmbeddingPerModel(t *testing.T) {
+	text := ProfileConfig{Provider: "ollama", BaseURL: "http://localhost:11434", Model: "text-model", Dimensions: 768}
+	reg, err := NewRegistry(Config{
+		Profiles: map[string]ProfileConfig{
+			"text":   text,
+			"mail":   text,
+			"code":   {Provider: "ollama", BaseURL: "http://localhost:11434", Model: "code-model", Dimensions: 1024},
+			"unused": {Provider: "ollama", BaseURL: "http://localhost:11434", Model: "other-model", Dimensions: 384},
+		},
+		Selection: SelectionConfig{
+			DefaultProfile: "text",
+			BySourceType:   map[domain.SourceType]string{domain.SourceTypeIMAP: "mail"},
+			ByRecordFormat: map[domain.RecordFormat]string{domain.RecordFormatCode: "code"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+
+	groups := reg.SearchGroups()
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 search groups, got %d: %+v", len(groups), groups)
+	}
+	if got := groups[0].Profiles; len(got) != 2 || got[0] != "mail" || got[1] != "text" {
+		t.Fatalf("expected default group [mail text] first, got %v", got)
+	}
+	if groups[0].Dimensions() != 768 {
+		t.Fatalf("expected default group dimensions 768, got %d", groups[0].Dimensions())
+	}
+	if got := groups[1].Profiles; len(got) != 1 || got[0] != "code" {
+		t.Fatalf("expected code group second, got %v", got)
+	}
+
+	if def := reg.Default(); def.Name != "text" {
+		t.Fatalf("expected default profile text, got %q", def.Name)
+	}
+	profile, err := reg.ForRecord(domain.Record{Format: domain.RecordFormatCode})
+	if err != nil {
+		t.Fatalf("for record: %v", err)
+	}
+	if profile.Name != "code" {
+		t.Fatalf("expected code profile, got %q", profile.Name)
+	}
+}
+
+func TestRegistryRejectsUnknownDistance(t *testing.T) {
+	_, err := NewRegistry(Config{
+		Profiles: map[string]ProfileConfig{
+			"text": {Provider: "ollama", BaseURL: "http://localhost:11434", Model: "text-model", Dimensions: 768, Distance: "manhattan"},
+		},
+		Selection: SelectionConfig{DefaultProfile: "text"},
+	})
+	if err == nil {
+		t.Fatal("expected unknown distance to be rejected")
+	}
+
+	reg, err := NewRegistry(Config{
+		Profiles: map[string]ProfileConfig{
+			"text": {Provider: "ollama", BaseURL: "http://localhost:11434", Model: "text-model", Dimensions: 768},
+		},
+		Selection: SelectionConfig{DefaultProfile: "text"},
+	})
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+	if got := reg.Default().Distance; got != domain.VectorDistanceL2 {
+		t.Fatalf("expected default distance l2, got %q", got)
+	}
+}
+
+func TestRegistrySearchesPreviousModelDuringMigration(t *testing.T) {
+	old := ProfileConfig{Provider: "ollama", BaseURL: "http://localhost:11434", Model: "old-model", Dimensions: 768}
+	current := ProfileConfig{Provider: "ollama", BaseURL: "http://localhost:11434", Model: "new-model", Dimensions: 1024, Previous: &old}
+	reg, err := NewRegistry(Config{
+		Profiles:  map[string]ProfileConfig{"text": current, "mail": current},
+		Selection: SelectionConfig{DefaultProfile: "text", BySourceType: map[domain.SourceType]string{domain.SourceTypeIMAP: "mail"}},
+	})
+	if err != nil {
+		t.Fatalf("new registry: %v", err)
+	}
+
+	if got := reg.Default().Model; got != "ollama/new-model/1024" {
+		t.Fatalf("expected current model id, got %q", got)
+	}
+	previous, ok := reg.Previous("text")
+	if !ok || previous.Model != "ollama/old-model/768" || previous.Dimensions() != 768 {
+		t.Fatalf("unexpected previous profile %+v (ok=%v)", previous, ok)
+	}
+
+	groups := reg.SearchGroups()
+	if len(groups) != 2 {
+		t.Fatalf("expected current and previous search groups, got %+v", groups)
+	}
+	if groups[0].Model != "ollama/new-model/1024" || groups[1].Model != "ollama/old-model/768" {
+		t.Fatalf("expected current model first, got %q then %q", groups[0].Model, groups[1].Model)
+	}
+	if got := groups[1].Profiles; len(got) != 2 || got[0] != "mail" || got[1] != "text" {
+		t.Fatalf("expected previous group [mail text], got %v", got)
+	}
+
+	same := current
+	same.Previous = &ProfileConfig{Provider: "ollama", BaseURL: "http://other:11434", Model: "new-model", Dimensions: 1024}
+	if _, err := NewRegistry(Config{
+		Profiles:  map[string]ProfileConfig{"text": same},
+		Selection: SelectionConfig{DefaultProfile: "text"},
+	}); err == nil {
+		t.Fatal("expected a previous model equal to the current one to be rejected")
+	}
+}
@@ -13,6 +13,8 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
 )
 
 // Result is the image vector returned by an image embedding service.
@@ -88,7 +90,7 @@ func (c *Client) EmbedImage(ctx context.Context, name, mimeType string, image []
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return Result{}, fmt.Errorf("image embedding status %d", resp.StatusCode)
+		return Result{}, &domain.StatusError{Op: "image embedding", StatusCode: resp.StatusCode}
 	}
 
 	var result Result
@@ -140,7 +142,7 @@ func (c *Client) EmbedText(ctx context.Context, text string) (Result, error) {
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return Result{}, fmt.Errorf("image text embedding status %d", resp.StatusCode)
+		return Result{}, &domain.StatusError{Op: "image text embedding", StatusCode: resp.StatusCode}
 	}
 
 	var result Result
@@ -0,0 +1,157 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	objstore "github.com/ultravioletrs/cube/internal/embedder/storage"
+)
+
+// archiveObjectPrefix is the key prefix of the files unpacked from the
+// archive record parentID.
+func archiveObjectPrefix(keyPrefix, parentID string) string {
+	return path.Join(keyPrefix, "archives", parentID)
+}
+
+// archiveObjects removes the files unpacked from archive records. Deleting
+// an archive record cascades to its children through records.parent_id, but
+// their content stays in object storage until it is removed here.
+type archiveObjects struct {
+	records domain.RecordRepository
+	store   objstore.Store
+	prefix  string
+}
+
+func (a archiveObjects) remove(ctx context.Context, archiveIDs []string) {
+	for _, id := range archiveIDs {
+		prefix := archiveObjectPrefix(a.prefix, id)
+		if err := a.store.DeletePrefix(ctx, prefix); err != nil {
+			slog.Warn("ingest: delete archive entries content", "prefix", prefix, "err", err)
+		}
+	}
+}
+
+// descendantArchives returns the IDs of rec, when it is an archive with
+// unpacked files, and of every such archive below it.
+func (a archiveObjects) descendantArchives(ctx context.Context, rec domain.Record) ([]string, error) {
+	const pageLimit = uint64(200)
+	var ids []string
+	queue := []domain.Record{rec}
+	for len(queue) > 0 {
+		next := queue[0]
+		queue = queue[1:]
+		filter := domain.RecordFilter{ParentID: &next.ID}
+		var offset uint64
+		for {
+			page, err := a.records.List(ctx, rec.DomainID, filter, domain.Page{Offset: offset, Limit: pageLimit})
+			if err != nil {
+				return nil, err
+			}
+			if offset == 0 && len(page.Records) > 0 && next.Format == domain.RecordFormatArchive {
+				ids = append(ids, next.ID)
+			}
+			queue = append(queue, page.Records...)
+			offset += uint64(len(page.Records))
+			if offset >= page.Total || len(page.Records) == 0 {
+				break
+			}
+		}
+	}
+	return ids, nil
+}
+
+type archiveCleanupRecords struct {
+	domain.RecordRepository
+	objects archiveObjects
+}
+
+// WithArchiveCleanup wraps records so that deleting a record also removes
+// the files unpacked from it, and from archives unpacked below it, from
+// store. keyPrefix must match the one given to Worker.SetArchiveStore.
+func WithArchiveCleanup(records domain.RecordRepository, store objstore.Store, keyPrefix string) domain.RecordRepository {
+	return &archiveCleanupRecords{
+		RecordRepository: records,
+		objects: archiveObjects{
+			records: records,
+			store:   store,
+			prefix:  strings.Trim(strings.TrimSpace(keyPrefix), "/"),
+		},
+	}
+}
+
+func (r *archiveCleanupRecords) Delete(ctx context.Context, id, domainID string) error {
+	rec, err := r.RecordRepository.GetByID(ctx, id, domainID)
+	if err != nil {
+		return err
+	}
+	archives, err := r.objects.descendantArchives(ctx, rec)
+	if err != nil {
+		return err
+	}
+	if err := r.RecordRepository.Delete(ctx, id, domainID); err != nil {
+		return err
+	}
+	r.objects.remove(ctx, archives)
+	return nil
+}
+
+func (r *archiveCleanupRecords) DeleteBySourceExternalIDs(
+	ctx context.Context,
+	domainID, sourceID string,
+	externalIDs []string,
+) (int, error) {
+	if len(externalIDs) == 0 {
+		return 0, nil
+	}
+	archives, err := r.RecordRepository.ArchiveIDs(ctx, domainID, sourceID, externalIDs)
+	if err != nil {
+		return 0, err
+	}
+	n, err := r.RecordRepository.DeleteBySourceExternalIDs(ctx, domainID, sourceID, externalIDs)
+	if err != nil {
+		return n, err
+	}
+	r.objects.remove(ctx, archives)
+	return n, nil
+}
+
+type archiveCleanupSources struct {
+	domain.SourceRepository
+	objects archiveObjects
+}
+
+// WithSourceArchiveCleanup wraps sources so that deleting a source also
+// removes the files unpacked from its archive records from store.
+func WithSourceArchiveCleanup(
+	sources domain.SourceRepository,
+	records domain.RecordRepository,
+	store objstore.Store,
+	keyPrefix string,
+) domain.SourceRepository {
+	return &archiveCleanupSources{
+		SourceRepository: sources,
+		objects: archiveObjects{
+			records: records,
+			store:   store,
+			prefix:  strings.Trim(strings.TrimSpace(keyPrefix), "/"),
+		},
+	}
+}
+
+func (s *archiveCleanupSources) Delete(ctx context.Context, id, domainID string) error {
+	archives, err := s.objects.records.ArchiveIDs(ctx, domainID, id, nil)
+	if err != nil {
+		return err
+	}
+	if err := s.SourceRepository.Delete(ctx, id, domainID); err != nil {
+		return err
+	}
+	s.objects.remove(ctx, archives)
+	return nil
+}
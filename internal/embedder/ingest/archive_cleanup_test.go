@@ -0,0 +1,89 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	objstore "github.com/ultravioletrs/cube/internal/embedder/storage"
+)
+
+type archiveRecordsStub struct {
+	domain.RecordRepository
+	records map[string]domain.Record
+	deleted []string
+}
+
+func (s *archiveRecordsStub) GetByID(_ context.Context, id, _ string) (domain.Record, error) {
+	rec, ok := s.records[id]
+	if !ok {
+		return domain.Record{}, domain.ErrNotFound
+	}
+	return rec, nil
+}
+
+func (s *archiveRecordsStub) List(_ context.Context, _ string, f domain.RecordFilter, _ domain.Page) (domain.RecordPage, error) {
+	var page domain.RecordPage
+	for _, rec := range s.records {
+		if rec.ParentID != nil && f.ParentID != nil && *rec.ParentID == *f.ParentID {
+			page.Records = append(page.Records, rec)
+		}
+	}
+	page.Total = uint64(len(page.Records))
+	return page, nil
+}
+
+func (s *archiveRecordsStub) Delete(_ context.Context, id, _ string) error {
+	s.deleted = append(s.deleted, id)
+	return nil
+}
+
+func TestArchiveCleanupDeletesUnpackedFiles(t *testing.T) {
+	ctx := context.Background()
+	store, err := objstore.NewStore(objstore.Config{Provider: objstore.ProviderLocal, LocalDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("NewStore: %v", err)
+	}
+	parent := func(id string) *string { return &id }
+	records := &archiveRecordsStub{records: map[string]domain.Record{
+		"outer":   {ID: "outer", Format: domain.RecordFormatArchive},
+		"inner":   {ID: "inner", Format: domain.RecordFormatArchive, ParentID: parent("outer")},
+		"file":    {ID: "file", Format: domain.RecordFormatText, ParentID: parent("inner")},
+		"sibling": {ID: "sibling", Format: domain.RecordFormatArchive},
+	}}
+	keys := []string{
+		path.Join("uploads", "archives", "outer", "a"),
+		path.Join("uploads", "archives", "inner", "b"),
+		path.Join("uploads", "archives", "sibling", "c"),
+	}
+	for _, key := range keys {
+		if err := store.Put(ctx, key, "text/plain", 1, strings.NewReader("x")); err != nil {
+			t.Fatalf("Put %s: %v", key, err)
+		}
+	}
+
+	repo := WithArchiveCleanup(records, store, "/uploads/")
+	if err := repo.Delete(ctx, "outer", "domain-1"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if len(records.deleted) != 1 || records.deleted[0] != "outer" {
+		t.Fatalf("deleted records = %v, want [outer]", records.deleted)
+	}
+	for _, key := range keys[:2] {
+		if _, err := store.Get(ctx, key); err == nil {
+			t.Fatalf("%s still stored after its archive was deleted", key)
+		}
+	}
+	if _, err := store.Get(ctx, keys[2]); err != nil {
+		t.Fatalf("unrelated archive entry removed: %v", err)
+	}
+
+	if err := repo.Delete(ctx, "missing", "domain-1"); err != domain.ErrNotFound {
+		t.Fatalf("Delete(missing) = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,237 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Compound File Binary (MS-CFB) reader, just enough to read the storages and
+// streams of an Outlook .msg file held in memory.
+
+const (
+	cfbHeaderSize     = 512
+	cfbDirEntrySize   = 128
+	cfbMaxRegSect     = 0xFFFFFFFA
+	cfbEndOfChain     = 0xFFFFFFFE
+	cfbNoStream       = 0xFFFFFFFF
+	cfbHeaderDIFATLen = 109
+)
+
+type cfbEntryKind byte
+
+const (
+	cfbStorage cfbEntryKind = 1
+	cfbStream  cfbEntryKind = 2
+	cfbRoot    cfbEntryKind = 5
+)
+
+var errCFBCorrupt = errors.New("corrupt compound file")
+
+type cfbEntry struct {
+	name               string
+	kind               cfbEntryKind
+	left, right, child uint32
+	start              uint32
+	size               uint64
+}
+
+type cfbFile struct {
+	data           []byte
+	sectorSize     int
+	miniSectorSize int
+	miniCutoff     uint64
+	fat            []uint32
+	miniFAT        []uint32
+	miniStream     []byte
+	entries        []cfbEntry
+}
+
+func openCFB(data []byte) (*cfbFile, error) {
+	if len(data) < cfbHeaderSize || !bytes.HasPrefix(data, cfbMagic) {
+		return nil, errCFBCorrupt
+	}
+	le := binary.LittleEndian
+	sectorShift := le.Uint16(data[0x1E:])
+	miniShift := le.Uint16(data[0x20:])
+	if (sectorShift != 9 && sectorShift != 12) || miniShift != 6 {
+		return nil, errCFBCorrupt
+	}
+	f := &cfbFile{
+		data:           data,
+		sectorSize:     1 << sectorShift,
+		miniSectorSize: 1 << miniShift,
+		miniCutoff:     uint64(le.Uint32(data[0x38:])),
+	}
+
+	// The FAT sectors are listed in the header, then in a chain of DIFAT
+	// sectors whose last slot points at the next one.
+	numFAT := int(le.Uint32(data[0x2C:]))
+	var fatSectors []uint32
+	for i := 0; i < cfbHeaderDIFATLen && len(fatSectors) < numFAT; i++ {
+		fatSectors = append(fatSectors, le.Uint32(data[0x4C+4*i:]))
+	}
+	perSector := f.sectorSize/4 - 1
+	for next, hops := le.Uint32(data[0x44:]), 0; next <= cfbMaxRegSect && len(fatSectors) < numFAT; hops++ {
+		sector, ok := f.sector(next)
+		if !ok || hops > len(data)/f.sectorSize {
+			return nil, errCFBCorrupt
+		}
+		for i := 0; i < perSector && len(fatSectors) < numFAT; i++ {
+			fatSectors = append(fatSectors, le.Uint32(sector[4*i:]))
+		}
+		next = le.Uint32(sector[4*perSector:])
+	}
+	for _, id := range fatSectors {
+		sector, ok := f.sector(id)
+		if !ok {
+			return nil, errCFBCorrupt
+		}
+		for i := 0; i+4 <= len(sector); i += 4 {
+			f.fat = append(f.fat, le.Uint32(sector[i:]))
+		}
+	}
+
+	dir, err := f.chain(le.Uint32(data[0x30:]), -1)
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i+cfbDirEntrySize <= len(dir); i += cfbDirEntrySize {
+		f.entries = append(f.entries, parseCFBEntry(dir[i:i+cfbDirEntrySize]))
+	}
+	if len(f.entries) == 0 || f.entries[0].kind != cfbRoot {
+		return nil, errCFBCorrupt
+	}
+
+	if miniFAT, err := f.chain(le.Uint32(data[0x3C:]), -1); err == nil {
+		for i := 0; i+4 <= len(miniFAT); i += 4 {
+			f.miniFAT = append(f.miniFAT, le.Uint32(miniFAT[i:]))
+		}
+	}
+	root := f.entries[0]
+	if root.start <= cfbMaxRegSect {
+		if f.miniStream, err = f.chain(root.start, int64(f.entrySize(root))); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+func parseCFBEntry(b []byte) cfbEntry {
+	le := binary.LittleEndian
+	nameLen := int(le.Uint16(b[0x40:]))
+	if nameLen > 64 {
+		nameLen = 64
+	}
+	name := decodeUTF16LE(b[:nameLen])
+	if i := strings.IndexByte(name, 0); i >= 0 {
+		name = name[:i]
+	}
+	return cfbEntry{
+		name:  name,
+		kind:  cfbEntryKind(b[0x42]),
+		left:  le.Uint32(b[0x44:]),
+		right: le.Uint32(b[0x48:]),
+		child: le.Uint32(b[0x4C:]),
+		start: le.Uint32(b[0x74:]),
+		size:  le.Uint64(b[0x78:]),
+	}
+}
+
+func (f *cfbFile) sector(id uint32) ([]byte, bool) {
+	off := (int64(id) + 1) * int64(f.sectorSize)
+	if id > cfbMaxRegSect || off+int64(f.sectorSize) > int64(len(f.data)) {
+		return nil, false
+	}
+	return f.data[off : off+int64(f.sectorSize)], true
+}
+
+// chain concatenates the sectors of a FAT chain, truncated to size when size
+// is not negative.
+func (f *cfbFile) chain(start uint32, size int64) ([]byte, error) {
+	var out []byte
+	for id, hops := start, 0; id != cfbEndOfChain; hops++ {
+		sector, ok := f.sector(id)
+		if !ok || hops > len(f.fat) || int(id) >= len(f.fat) {
+			return nil, errCFBCorrupt
+		}
+		out = append(out, sector...)
+		if size >= 0 && int64(len(out)) >= size {
+			break
+		}
+		id = f.fat[id]
+	}
+	if size >= 0 {
+		if int64(len(out)) < size {
+			return nil, errCFBCorrupt
+		}
+		out = out[:size]
+	}
+	return out, nil
+}
+
+func (f *cfbFile) miniChain(start uint32, size int64) ([]byte, error) {
+	var out []byte
+	for id, hops := start, 0; int64(len(out)) < size; hops++ {
+		off := int64(id) * int64(f.miniSectorSize)
+		if id > cfbMaxRegSect || hops > len(f.miniFAT) || int(id) >= len(f.miniFAT) ||
+			off+int64(f.miniSectorSize) > int64(len(f.miniStream)) {
+			return nil, errCFBCorrupt
+		}
+		out = append(out, f.miniStream[off:off+int64(f.miniSectorSize)]...)
+		id = f.miniFAT[id]
+	}
+	return out[:size], nil
+}
+
+func (f *cfbFile) entrySize(e cfbEntry) uint64 {
+	if f.sectorSize == 512 {
+		// Version 3 files may leave garbage in the high half.
+		return e.size & 0xFFFFFFFF
+	}
+	return e.size
+}
+
+func (f *cfbFile) root() cfbEntry {
+	return f.entries[0]
+}
+
+// stream returns the content of a stream entry. Streams below the mini
+// stream cutoff live in the root entry's mini stream.
+func (f *cfbFile) stream(e cfbEntry) ([]byte, error) {
+	size := f.entrySize(e)
+	if size > uint64(len(f.data)) {
+		return nil, fmt.Errorf("%w: stream %q larger than file", errCFBCorrupt, e.name)
+	}
+	if size == 0 {
+		return nil, nil
+	}
+	if size < f.miniCutoff {
+		return f.miniChain(e.start, int64(size))
+	}
+	return f.chain(e.start, int64(size))
+}
+
+// children lists the entries of a storage by walking its red-black tree.
+func (f *cfbFile) children(parent cfbEntry) []cfbEntry {
+	var out []cfbEntry
+	seen := make(map[uint32]bool)
+	var walk func(id uint32)
+	walk = func(id uint32) {
+		if id == cfbNoStream || int(id) >= len(f.entries) || seen[id] {
+			return
+		}
+		seen[id] = true
+		e := f.entries[id]
+		walk(e.left)
+		out = append(out, e)
+		walk(e.right)
+	}
+	walk(parent.child)
+	return out
+}
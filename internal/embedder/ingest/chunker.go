@@ -87,3 +87,43 @@ func normalizeChunkOptions(chunkSize, overlap int) (int, int) {
 	}
 	return chunkSize, overlap
 }
+
+// streamChunker cuts text that arrives in segments into the same word
+// windows chunkWords would produce for the whole text, holding at most one
+// window of words.
+type streamChunker struct {
+	size    int
+	overlap int
+	words   []string
+	emitted bool
+}
+
+func newStreamChunker(chunkSize, overlap int) *streamChunker {
+	size, overlap := normalizeChunkOptions(chunkSize, overlap)
+	return &streamChunker{size: size, overlap: overlap}
+}
+
+// Add appends a segment and returns the chunks it completed.
+func (c *streamChunker) Add(text string) []string {
+	var chunks []string
+	for _, word := range strings.Fields(text) {
+		c.words = append(c.words, word)
+		if len(c.words) < c.size {
+			continue
+		}
+		chunks = append(chunks, strings.Join(c.words, " "))
+		c.emitted = true
+		c.words = append(c.words[:0], c.words[c.size-c.overlap:]...)
+	}
+	return chunks
+}
+
+// Flush returns the final partial chunk, if any.
+func (c *streamChunker) Flush() []string {
+	words := c.words
+	c.words = nil
+	if len(words) == 0 || (c.emitted && len(words) <= c.overlap) {
+		return nil
+	}
+	return []string{strings.Join(words, " ")}
+}
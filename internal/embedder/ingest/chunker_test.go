@@ -3,7 +3,12 @@
 
 package ingest
 
-import "testing"
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+)
 
 func TestAdaptiveChunkSize(t *testing.T) {
 	cases := []struct {
@@ -55,3 +60,36 @@ func TestAdaptiveOverlap(t *testing.T) {
 		t.Fatalf("expected overlap to be disabled for large document, got %d", got)
 	}
 }
+
+func TestStreamChunkerMatchesChunkWords(t *testing.T) {
+	cases := []struct {
+		words, size, overlap int
+	}{
+		{words: 3, size: 4, overlap: 1},
+		{words: 10, size: 4, overlap: 0},
+		{words: 10, size: 4, overlap: 2},
+		{words: 11, size: 4, overlap: 1},
+		{words: 7, size: 4, overlap: 1},
+	}
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("%d words size %d overlap %d", tc.words, tc.size, tc.overlap), func(t *testing.T) {
+			words := make([]string, tc.words)
+			for i := range words {
+				words[i] = fmt.Sprintf("w%d", i)
+			}
+			want, _ := chunkWords(words, tc.size, tc.overlap)
+
+			c := newStreamChunker(tc.size, tc.overlap)
+			var got []string
+			// Feed uneven segments so windows span segment boundaries.
+			for i := 0; i < len(words); i += 3 {
+				got = append(got, c.Add(strings.Join(words[i:min(i+3, len(words))], " "))...)
+			}
+			got = append(got, c.Flush()...)
+
+			if !slices.Equal(got, want) {
+				t.Fatalf("stream chunks = %q, want %q", got, want)
+			}
+		})
+	}
+}
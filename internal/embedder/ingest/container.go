@@ -0,0 +1,351 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// ErrContainerLimit is returned when an archive exceeds the configured
+// expansion limits.
+var ErrContainerLimit = errors.New("archive exceeds expansion limits")
+
+// ContainerEntry is one document unpacked from an archive or email.
+type ContainerEntry struct {
+	// Path is the slash-separated location inside the top-level container,
+	// including the names of nested containers (reports/q3.zip/summary.pdf).
+	Path     string
+	MimeType string
+	Content  []byte
+}
+
+type containerKind int
+
+const (
+	containerNone containerKind = iota
+	containerZip
+	containerTar
+	containerGzip
+	containerEML
+	containerMSG
+)
+
+var (
+	zipMagic  = []byte("PK\x03\x04")
+	gzipMagic = []byte{0x1f, 0x8b}
+	cfbMagic  = []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1}
+)
+
+func containerKindForMIME(mime string) containerKind {
+	switch mime {
+	case "application/zip", "application/x-zip-compressed":
+		return containerZip
+	case "application/x-tar":
+		return containerTar
+	case "application/gzip", "application/x-gzip", "application/x-gtar", "application/x-compressed-tar":
+		return containerGzip
+	case "message/rfc822":
+		return containerEML
+	case "application/vnd.ms-outlook":
+		return containerMSG
+	default:
+		return containerNone
+	}
+}
+
+func isContainerMIME(mime string) bool {
+	return containerKindForMIME(mime) != containerNone
+}
+
+// detectContainer picks the container kind from the file extension, then the
+// declared MIME type, then the content's magic bytes. A known non-container
+// extension wins, so a DOCX is never unpacked as a ZIP.
+func detectContainer(name, mimeType string, content []byte) containerKind {
+	if byExt := mimeTypeFromExtension(name); byExt != "" {
+		return containerKindForMIME(byExt)
+	}
+	mime := normalizeMediaType(mimeType)
+	if kind := containerKindForMIME(mime); kind != containerNone {
+		return kind
+	}
+	if mime != "" && !isGenericBinaryMIME(mime) {
+		return containerNone
+	}
+	switch {
+	case officeMIMEFromZip(content) != "":
+		return containerNone
+	case bytes.HasPrefix(content, zipMagic):
+		return containerZip
+	case bytes.HasPrefix(content, gzipMagic):
+		return containerGzip
+	case bytes.HasPrefix(content, cfbMagic):
+		return containerMSG
+	case isTarContent(content):
+		return containerTar
+	default:
+		return containerNone
+	}
+}
+
+func isTarContent(content []byte) bool {
+	return len(content) >= 262 && string(content[257:262]) == "ustar"
+}
+
+// ExpandContainer unpacks an archive (ZIP, TAR, gzip) or an email (EML, MSG)
+// and calls visit for every document inside it. Nested containers are
+// expanded in place down to the configured depth. Entries the pipeline
+// cannot extract, and entries above the per-entry size limit, are skipped.
+// Exceeding the entry count or total size returns ErrContainerLimit, so a
+// partial expansion is never mistaken for a complete one.
+func ExpandContainer(f FileMeta, content []byte, visit func(ContainerEntry) error) error {
+	kind := detectContainer(f.Name, f.MimeType, content)
+	if kind == containerNone {
+		return fmt.Errorf("unsupported archive type %q", f.MimeType)
+	}
+	x := &containerExpander{cfg: GetExtractionConfig().Archive, visit: visit}
+	return x.expand("", path.Base(f.Name), kind, content, 1)
+}
+
+// containerExpander carries the limits shared by a container and everything
+// nested in it.
+type containerExpander struct {
+	cfg     ArchiveConfig
+	visit   func(ContainerEntry) error
+	entries int
+	bytes   int64
+}
+
+func (x *containerExpander) expand(prefix, name string, kind containerKind, content []byte, depth int) error {
+	switch kind {
+	case containerZip:
+		return x.expandZip(prefix, content, depth)
+	case containerTar:
+		return x.expandTar(prefix, bytes.NewReader(content), depth)
+	case containerGzip:
+		return x.expandGzip(prefix, name, content, depth)
+	case containerEML:
+		return x.expandEML(prefix, content, depth)
+	case containerMSG:
+		return x.expandMSG(prefix, content, depth)
+	default:
+		return fmt.Errorf("unsupported archive %q", name)
+	}
+}
+
+// entry hands one unpacked file to visit, or expands it when it is itself a
+// container.
+func (x *containerExpander) entry(entryPath, mimeHint string, content []byte, depth int) error {
+	name := path.Base(entryPath)
+	if kind := detectContainer(name, mimeHint, content); kind != containerNone {
+		if depth >= x.cfg.MaxDepth {
+			return nil
+		}
+		return x.expand(entryPath, name, kind, content, depth+1)
+	}
+	mime := normalizeFileMetaMIMEType(FileMeta{Name: name, MimeType: mimeHint}, content)
+	if !isExtractableMIME(name, mime) {
+		return nil
+	}
+	return x.visit(ContainerEntry{Path: entryPath, MimeType: mime, Content: content})
+}
+
+// count charges one archive member against the entry limit. Directories and
+// skipped members count too, so an archive of empty headers cannot spin.
+func (x *containerExpander) count() error {
+	x.entries++
+	if x.entries > x.cfg.MaxEntries {
+		return fmt.Errorf("%w: more than %d entries", ErrContainerLimit, x.cfg.MaxEntries)
+	}
+	return nil
+}
+
+// read consumes one member. ok is false for members above the per-entry
+// limit; the total limit is enforced on the bytes actually decompressed,
+// not on sizes declared in headers.
+func (x *containerExpander) read(r io.Reader) (data []byte, ok bool, err error) {
+	limit := x.cfg.MaxEntryBytes
+	if remaining := x.cfg.MaxTotalBytes - x.bytes; remaining < limit {
+		limit = remaining
+	}
+	data, err = io.ReadAll(io.LimitReader(r, limit+1))
+	x.bytes += int64(len(data))
+	if err != nil {
+		return nil, false, err
+	}
+	if x.bytes > x.cfg.MaxTotalBytes {
+		return nil, false, x.totalLimitError()
+	}
+	if int64(len(data)) > limit {
+		return nil, false, nil
+	}
+	return data, true, nil
+}
+
+func (x *containerExpander) totalLimitError() error {
+	return fmt.Errorf("%w: more than %d bytes", ErrContainerLimit, x.cfg.MaxTotalBytes)
+}
+
+// stream caps a decompressing reader at the remaining total budget, so
+// members that are skipped without being read still cannot inflate forever.
+func (x *containerExpander) stream(r io.Reader) io.Reader {
+	return &budgetReader{r: r, n: x.cfg.MaxTotalBytes - x.bytes + 1, limit: x.totalLimitError}
+}
+
+type budgetReader struct {
+	r     io.Reader
+	n     int64
+	limit func() error
+}
+
+func (b *budgetReader) Read(p []byte) (int, error) {
+	if b.n <= 0 {
+		return 0, b.limit()
+	}
+	if int64(len(p)) > b.n {
+		p = p[:b.n]
+	}
+	n, err := b.r.Read(p)
+	b.n -= int64(n)
+	return n, err
+}
+
+func (x *containerExpander) expandZip(prefix string, content []byte, depth int) error {
+	zr, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil && !errors.Is(err, zip.ErrInsecurePath) {
+		return fmt.Errorf("open zip archive: %w", err)
+	}
+	for _, f := range zr.File {
+		if err := x.count(); err != nil {
+			return err
+		}
+		if !f.Mode().IsRegular() {
+			continue
+		}
+		name, ok := cleanEntryPath(f.Name)
+		if !ok {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			// Encrypted members and unknown compression methods are skipped.
+			continue
+		}
+		data, ok, err := x.read(rc)
+		rc.Close()
+		if errors.Is(err, ErrContainerLimit) {
+			return err
+		}
+		if err != nil || !ok {
+			continue
+		}
+		if err := x.entry(path.Join(prefix, name), "", data, depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *containerExpander) expandTar(prefix string, r io.Reader, depth int) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			if errors.Is(err, ErrContainerLimit) {
+				return err
+			}
+			return fmt.Errorf("read tar archive: %w", err)
+		}
+		if err := x.count(); err != nil {
+			return err
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		name, ok := cleanEntryPath(hdr.Name)
+		if !ok {
+			continue
+		}
+		data, ok, err := x.read(tr)
+		if err != nil {
+			if errors.Is(err, ErrContainerLimit) {
+				return err
+			}
+			return fmt.Errorf("read tar member %q: %w", name, err)
+		}
+		if !ok {
+			continue
+		}
+		if err := x.entry(path.Join(prefix, name), "", data, depth); err != nil {
+			return err
+		}
+	}
+}
+
+// expandGzip unpacks a .tar.gz as a TAR archive and anything else as the
+// single file it compresses.
+func (x *containerExpander) expandGzip(prefix, name string, content []byte, depth int) error {
+	gz, err := gzip.NewReader(bytes.NewReader(content))
+	if err != nil {
+		return fmt.Errorf("open gzip stream: %w", err)
+	}
+	defer gz.Close()
+
+	r := bufio.NewReader(x.stream(gz))
+	if head, _ := r.Peek(262); isTarContent(head) {
+		return x.expandTar(prefix, r, depth)
+	}
+
+	if err := x.count(); err != nil {
+		return err
+	}
+	data, ok, err := x.read(r)
+	if err != nil {
+		if errors.Is(err, ErrContainerLimit) {
+			return err
+		}
+		return fmt.Errorf("read gzip stream: %w", err)
+	}
+	if !ok {
+		return nil
+	}
+	inner, ok := cleanEntryPath(path.Base(gz.Name))
+	if !ok {
+		inner = strings.TrimSuffix(strings.TrimSuffix(name, ".gz"), ".GZ")
+	}
+	if inner == "" || inner == name {
+		inner = "content"
+	}
+	return x.entry(path.Join(prefix, inner), "", data, depth)
+}
+
+// cleanEntryPath turns a member name into a relative slash path. Members
+// that climb out of the archive and OS metadata files are rejected.
+func cleanEntryPath(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	for _, seg := range strings.Split(name, "/") {
+		if seg == ".." {
+			return "", false
+		}
+	}
+	name = strings.TrimLeft(path.Clean("/"+name), "/")
+	if name == "" {
+		return "", false
+	}
+	if strings.HasPrefix(name, "__MACOSX/") || path.Base(name) == ".DS_Store" {
+		return "", false
+	}
+	return name, true
+}
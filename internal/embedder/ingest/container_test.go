@@ -0,0 +1,255 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestExpandContainerZip(t *testing.T) {
+	inner := buildZip(t, map[string]string{"deep/notes.md": "# nested"})
+	archive := buildZip(t, map[string]string{
+		"reports/q3.txt":         "quarterly numbers",
+		"reports/inner.zip":      string(inner),
+		"../escape.txt":          "outside",
+		"__MACOSX/._q3.txt":      "resource fork",
+		"tool.exe":               "MZ\x90\x00binary",
+		"/absolute/readme.txt":   "rooted",
+		"reports/legacy.docx":    string(buildDOCX(t, "docx body")),
+		"reports/empty-dir/":     "",
+		"reports/photo.png":      "\x89PNG\r\n\x1a\n",
+		"reports/data.json":      `{"a":1}`,
+		"reports/unknown.binary": "\x00\x01\x02",
+	})
+
+	entries := expandForTest(t, "bundle.zip", "application/zip", archive)
+	got := entryPaths(entries)
+	want := []string{
+		"absolute/readme.txt",
+		"reports/data.json",
+		"reports/inner.zip/deep/notes.md",
+		"reports/legacy.docx",
+		"reports/photo.png",
+		"reports/q3.txt",
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("entries = %v, want %v", got, want)
+	}
+	if mime := entries["reports/legacy.docx"].MimeType; mime != "application/vnd.openxmlformats-officedocument.wordprocessingml.document" {
+		t.Fatalf("docx entry mime = %q", mime)
+	}
+	if content := string(entries["reports/inner.zip/deep/notes.md"].Content); content != "# nested" {
+		t.Fatalf("nested entry content = %q", content)
+	}
+}
+
+func TestExpandContainerTarGz(t *testing.T) {
+	archive := gzipBytes(t, buildTar(t, map[string]string{
+		"docs/a.txt": "alpha",
+		"docs/b.md":  "beta",
+	}))
+
+	entries := expandForTest(t, "export.tgz", "", archive)
+	if got := strings.Join(entryPaths(entries), ","); got != "docs/a.txt,docs/b.md" {
+		t.Fatalf("entries = %s", got)
+	}
+
+	// A gzip without a tar inside holds a single file named after it.
+	entries = expandForTest(t, "server.log.gz", "application/octet-stream", gzipBytes(t, []byte("log line")))
+	if got := strings.Join(entryPaths(entries), ","); got != "server.log" {
+		t.Fatalf("entries = %s", got)
+	}
+}
+
+func TestExpandContainerDetectsByContent(t *testing.T) {
+	archive := buildZip(t, map[string]string{"a.txt": "alpha"})
+	entries := expandForTest(t, "download", "application/octet-stream", archive)
+	if _, ok := entries["a.txt"]; !ok {
+		t.Fatalf("expected zip detected from content, got %v", entryPaths(entries))
+	}
+}
+
+func TestExpandContainerDepthLimit(t *testing.T) {
+	restore := setTestExtractionConfig(ExtractionConfig{Archive: ArchiveConfig{MaxDepth: 2}})
+	defer restore()
+
+	level3 := buildZip(t, map[string]string{"deepest.txt": "three"})
+	level2 := buildZip(t, map[string]string{"two.txt": "two", "level3.zip": string(level3)})
+	level1 := buildZip(t, map[string]string{"one.txt": "one", "level2.zip": string(level2)})
+
+	entries := expandForTest(t, "level1.zip", "", level1)
+	if got := strings.Join(entryPaths(entries), ","); got != "level2.zip/two.txt,one.txt" {
+		t.Fatalf("entries = %s", got)
+	}
+}
+
+func TestExpandContainerEntryLimit(t *testing.T) {
+	restore := setTestExtractionConfig(ExtractionConfig{Archive: ArchiveConfig{MaxEntries: 3}})
+	defer restore()
+
+	files := map[string]string{}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		files[name] = name
+	}
+	err := ExpandContainer(FileMeta{Name: "many.zip"}, buildZip(t, files), func(ContainerEntry) error { return nil })
+	if !errors.Is(err, ErrContainerLimit) {
+		t.Fatalf("expected ErrContainerLimit, got %v", err)
+	}
+}
+
+func TestExpandContainerRejectsZipBomb(t *testing.T) {
+	restore := setTestExtractionConfig(ExtractionConfig{Archive: ArchiveConfig{
+		MaxEntryBytes: 1 << 20,
+		MaxTotalBytes: 2 << 20,
+	}})
+	defer restore()
+
+	// Each member inflates from a few KB to 1MB of zeros; together they
+	// exceed the total budget long before their declared sizes matter.
+	zeros := strings.Repeat("\x00", 1<<20)
+	files := map[string]string{}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt", "d.txt"} {
+		files[name] = zeros
+	}
+	var visited int
+	err := ExpandContainer(FileMeta{Name: "bomb.zip"}, buildZip(t, files), func(ContainerEntry) error {
+		visited++
+		return nil
+	})
+	if !errors.Is(err, ErrContainerLimit) {
+		t.Fatalf("expected ErrContainerLimit, got %v (visited %d)", err, visited)
+	}
+
+	// A single gzip stream inflating past the budget stops at the budget.
+	bomb := gzipBytes(t, bytes.Repeat([]byte{0}, 8<<20))
+	err = ExpandContainer(FileMeta{Name: "bomb.gz"}, bomb, func(ContainerEntry) error { return nil })
+	if err != nil {
+		t.Fatalf("oversized single file should be skipped, got %v", err)
+	}
+	tarBomb := gzipBytes(t, buildTar(t, map[string]string{"zeros.txt": strings.Repeat("\x00", 8<<20)}))
+	err = ExpandContainer(FileMeta{Name: "bomb.tar.gz"}, tarBomb, func(ContainerEntry) error { return nil })
+	if !errors.Is(err, ErrContainerLimit) {
+		t.Fatalf("expected ErrContainerLimit for tar bomb, got %v", err)
+	}
+}
+
+func TestExpandContainerSkipsOversizedEntries(t *testing.T) {
+	restore := setTestExtractionConfig(ExtractionConfig{Archive: ArchiveConfig{MaxEntryBytes: 8}})
+	defer restore()
+
+	archive := buildZip(t, map[string]string{"small.txt": "tiny", "large.txt": "much larger than eight bytes"})
+	entries := expandForTest(t, "mixed.zip", "", archive)
+	if got := strings.Join(entryPaths(entries), ","); got != "small.txt" {
+		t.Fatalf("entries = %s", got)
+	}
+}
+
+func TestExpandContainerRejectsNonArchive(t *testing.T) {
+	err := ExpandContainer(FileMeta{Name: "notes.txt", MimeType: "text/plain"}, []byte("hello"), func(ContainerEntry) error { return nil })
+	if err == nil {
+		t.Fatal("expected error for non-archive content")
+	}
+}
+
+func expandForTest(t *testing.T, name, mimeType string, content []byte) map[string]ContainerEntry {
+	t.Helper()
+	entries := make(map[string]ContainerEntry)
+	err := ExpandContainer(FileMeta{Name: name, MimeType: mimeType}, content, func(e ContainerEntry) error {
+		if _, dup := entries[e.Path]; dup {
+			t.Fatalf("duplicate entry %q", e.Path)
+		}
+		entries[e.Path] = e
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("ExpandContainer(%s): %v", name, err)
+	}
+	return entries
+}
+
+func entryPaths(entries map[string]ContainerEntry) []string {
+	paths := make([]string, 0, len(entries))
+	for p := range entries {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range names {
+		w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate})
+		if err != nil {
+			t.Fatalf("create zip entry: %v", err)
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			t.Fatalf("write zip entry: %v", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatalf("close zip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func buildTar(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range names {
+		body := files[name]
+		if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(body)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatalf("write tar header: %v", err)
+		}
+		if _, err := tw.Write([]byte(body)); err != nil {
+			t.Fatalf("write tar entry: %v", err)
+		}
+	}
+	if err := tw.Close(); err != nil {
+		t.Fatalf("close tar: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	if _, err := gw.Write(data); err != nil {
+		t.Fatalf("write gzip: %v", err)
+	}
+	if err := gw.Close(); err != nil {
+		t.Fatalf("close gzip: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func buildDOCX(t *testing.T, text string) []byte {
+	t.Helper()
+	return buildZip(t, map[string]string{
+		"word/document.xml": `<w:document xmlns:w="w"><w:body><w:p><w:r><w:t>` + text + `</w:t></w:r></w:p></w:body></w:document>`,
+	})
+}
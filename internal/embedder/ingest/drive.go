@@ -4,6 +4,7 @@
 package ingest
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/json"
@@ -16,6 +17,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ultravioletrs/cube/internal/embedder/domain"
 	"golang.org/x/oauth2"
@@ -31,6 +33,8 @@ var (
 	driveFilesURL     = "https://www.googleapis.com/drive/v3/files"
 	driveExportURLFmt = "https://www.googleapis.com/drive/v3/files/%s/export"
 	driveDownloadURL  = "https://www.googleapis.com/drive/v3/files/%s?alt=media"
+	driveChangesURL   = "https://www.googleapis.com/drive/v3/changes"
+	driveChannelsURL  = "https://www.googleapis.com/drive/v3/channels"
 )
 
 var (
@@ -39,6 +43,9 @@ var (
 	ErrDriveNotFound = errors.New("drive file not found")
 	// ErrUnsupportedDriveFile is returned when a file's MIME type cannot be ingested.
 	ErrUnsupportedDriveFile = errors.New("drive file has unsupported MIME type")
+	// ErrTooManyDriveChanges is returned by ListChanges when more changes are
+	// pending than the caller accepts.
+	ErrTooManyDriveChanges = errors.New("too many drive changes")
 )
 
 // SetDriveAPIEndpoints overrides Drive API endpoints and returns a restore function.
@@ -59,6 +66,21 @@ func SetDriveAPIEndpoints(filesURL, exportURLFmt, downloadURLFmt string) func()
 	}
 }
 
+// SetDriveChangesEndpoints overrides the Drive changes and channels endpoints
+// and returns a restore function. Intended for tests.
+func SetDriveChangesEndpoints(changesURL, channelsURL string) func() {
+	prevChangesURL := driveChangesURL
+	prevChannelsURL := driveChannelsURL
+
+	driveChangesURL = changesURL
+	driveChannelsURL = channelsURL
+
+	return func() {
+		driveChangesURL = prevChangesURL
+		driveChannelsURL = prevChannelsURL
+	}
+}
+
 // DriveFile is a single file entry from the Drive files.list API.
 type DriveFile struct {
 	ID           string   `json:"id"`
@@ -68,6 +90,7 @@ type DriveFile struct {
 	ModifiedTime string   `json:"modifiedTime"`
 	WebViewLink  string   `json:"webViewLink"`
 	Parents      []string `json:"parents"`
+	Trashed      bool     `json:"trashed,omitempty"`
 
 	// FolderPath and FolderID are populated by ListFilesRecursive while walking
 	// the folder tree. FolderPath is the human-readable path of the containing
@@ -182,7 +205,7 @@ func (d *DriveReader) listQuery(ctx context.Context, query string, pageSize int)
 		if resp.StatusCode != http.StatusOK {
 			body, _ := io.ReadAll(resp.Body)
 			_ = resp.Body.Close()
-			return nil, fmt.Errorf("drive list status %d: %s", resp.StatusCode, body)
+			return nil, &domain.StatusError{Op: "drive list", StatusCode: resp.StatusCode, Message: string(body)}
 		}
 
 		var page struct {
@@ -256,7 +279,7 @@ func (d *DriveReader) GetFile(ctx context.Context, fileID string) (DriveFile, er
 	}
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return DriveFile{}, fmt.Errorf("drive get file status %d: %s", resp.StatusCode, body)
+		return DriveFile{}, &domain.StatusError{Op: "drive get file", StatusCode: resp.StatusCode, Message: string(body)}
 	}
 
 	var file DriveFile
@@ -415,6 +438,26 @@ func (d *DriveReader) SearchFolders(ctx context.Context, scopeFolderID, nameQuer
 
 // DownloadFile downloads or exports a file as raw bytes.
 func (d *DriveReader) DownloadFile(ctx context.Context, f DriveFile) ([]byte, error) {
+	body, err := d.OpenFile(ctx, f)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	lim := &io.LimitedReader{R: body, N: driveFileMaxBytes + 1}
+	content, err := io.ReadAll(lim)
+	if err != nil {
+		return nil, fmt.Errorf("drive read %s: %w", f.ID, err)
+	}
+	if int64(len(content)) > driveFileMaxBytes {
+		return nil, fmt.Errorf("drive file %s too large: %d bytes (max %d)", f.ID, len(content), driveFileMaxBytes)
+	}
+	return content, nil
+}
+
+// OpenFile downloads or exports a file, returning its body unread and
+// without DownloadFile's size limit. The caller closes it.
+func (d *DriveReader) OpenFile(ctx context.Context, f DriveFile) (io.ReadCloser, error) {
 	var reqURL string
 	switch {
 	case strings.HasPrefix(f.MimeType, "application/vnd.google-apps."):
@@ -431,22 +474,13 @@ func (d *DriveReader) DownloadFile(ctx context.Context, f DriveFile) ([]byte, er
 	if err != nil {
 		return nil, fmt.Errorf("drive download %s: %w", f.ID, err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("drive download %s status %d: %s", f.ID, resp.StatusCode, body)
-	}
-
-	lim := &io.LimitedReader{R: resp.Body, N: driveFileMaxBytes + 1}
-	body, err := io.ReadAll(lim)
-	if err != nil {
-		return nil, fmt.Errorf("drive read %s: %w", f.ID, err)
+		return nil, &domain.StatusError{Op: "drive download " + f.ID, StatusCode: resp.StatusCode, Message: string(body)}
 	}
-	if int64(len(body)) > driveFileMaxBytes {
-		return nil, fmt.Errorf("drive file %s too large: %d bytes (max %d)", f.ID, len(body), driveFileMaxBytes)
-	}
-	return body, nil
+	return resp.Body, nil
 }
 
 func googleAppsExportMIME(mimeType string) string {
@@ -479,3 +513,177 @@ func supportsDriveFile(f DriveFile) bool {
 	}
 	return isTextFileExt(filepath.Ext(strings.TrimSpace(f.Name)))
 }
+
+// DriveChange is one entry of the Drive changes.list API.
+type DriveChange struct {
+	FileID  string     `json:"fileId"`
+	Removed bool       `json:"removed"`
+	File    *DriveFile `json:"file"`
+}
+
+// DriveChannel is a push-notification channel watching Drive changes.
+type DriveChannel struct {
+	ID         string
+	ResourceID string
+	Address    string
+	Token      string
+	Expiration time.Time
+}
+
+// StartPageToken returns the changes page token for the current state of the
+// Drive; changes made afterwards are listed from it.
+func (d *DriveReader) StartPageToken(ctx context.Context) (string, error) {
+	params := url.Values{"supportsAllDrives": {"true"}}
+	var out struct {
+		StartPageToken string `json:"startPageToken"`
+	}
+	if err := d.doJSON(ctx, http.MethodGet, driveChangesURL+"/startPageToken?"+params.Encode(), nil, &out); err != nil {
+		return "", fmt.Errorf("drive start page token: %w", err)
+	}
+	if out.StartPageToken == "" {
+		return "", fmt.Errorf("drive start page token is empty")
+	}
+	return out.StartPageToken, nil
+}
+
+// ListChanges returns the changes made since pageToken and the page token to
+// resume from. It returns ErrTooManyDriveChanges when more than limit changes
+// are pending and ErrDriveNotFound when the page token is no longer valid.
+func (d *DriveReader) ListChanges(ctx context.Context, pageToken string, limit int) ([]DriveChange, string, error) {
+	var changes []DriveChange
+	for {
+		params := url.Values{
+			"pageToken":                 {pageToken},
+			"pageSize":                  {"1000"},
+			"includeRemoved":            {"true"},
+			"includeItemsFromAllDrives": {"true"},
+			"supportsAllDrives":         {"true"},
+			"fields": {"nextPageToken,newStartPageToken," +
+				"changes(fileId,removed,file(id,name,mimeType,version,modifiedTime,webViewLink,parents,trashed))"},
+		}
+		var page struct {
+			Changes           []DriveChange `json:"changes"`
+			NextPageToken     string        `json:"nextPageToken"`
+			NewStartPageToken string        `json:"newStartPageToken"`
+		}
+		if err := d.doJSON(ctx, http.MethodGet, driveChangesURL+"?"+params.Encode(), nil, &page); err != nil {
+			return nil, "", fmt.Errorf("drive list changes: %w", err)
+		}
+		changes = append(changes, page.Changes...)
+		if limit > 0 && len(changes) > limit {
+			return nil, "", ErrTooManyDriveChanges
+		}
+		if page.NewStartPageToken != "" {
+			return changes, page.NewStartPageToken, nil
+		}
+		if page.NextPageToken == "" {
+			return nil, "", fmt.Errorf("drive list changes: response has no page token")
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// WatchChanges opens a channel that notifies ch.Address of changes made after
+// pageToken. The returned channel carries the resource ID and expiration
+// assigned by Drive.
+func (d *DriveReader) WatchChanges(ctx context.Context, pageToken string, ch DriveChannel) (DriveChannel, error) {
+	params := url.Values{
+		"pageToken":                 {pageToken},
+		"includeRemoved":            {"true"},
+		"includeItemsFromAllDrives": {"true"},
+		"supportsAllDrives":         {"true"},
+	}
+	in := map[string]any{
+		"id":      ch.ID,
+		"type":    "web_hook",
+		"address": ch.Address,
+		"token":   ch.Token,
+	}
+	if !ch.Expiration.IsZero() {
+		in["expiration"] = strconv.FormatInt(ch.Expiration.UnixMilli(), 10)
+	}
+	var out struct {
+		ID         string `json:"id"`
+		ResourceID string `json:"resourceId"`
+		Expiration string `json:"expiration"`
+	}
+	if err := d.doJSON(ctx, http.MethodPost, driveChangesURL+"/watch?"+params.Encode(), in, &out); err != nil {
+		return DriveChannel{}, fmt.Errorf("drive watch changes: %w", err)
+	}
+	ch.ResourceID = out.ResourceID
+	if ms, err := strconv.ParseInt(out.Expiration, 10, 64); err == nil {
+		ch.Expiration = time.UnixMilli(ms).UTC()
+	}
+	return ch, nil
+}
+
+// StopChannel stops a push-notification channel. Channels that are already
+// gone are not an error.
+func (d *DriveReader) StopChannel(ctx context.Context, id, resourceID string) error {
+	in := map[string]string{"id": id, "resourceId": resourceID}
+	if err := d.doJSON(ctx, http.MethodPost, driveChannelsURL+"/stop", in, nil); err != nil && !errors.Is(err, ErrDriveNotFound) {
+		return fmt.Errorf("drive stop channel: %w", err)
+	}
+	return nil
+}
+
+// GetFolder returns a folder's name and parents.
+func (d *DriveReader) GetFolder(ctx context.Context, folderID string) (DriveFile, error) {
+	params := url.Values{"fields": {"id,name,mimeType,parents"}, "supportsAllDrives": {"true"}}
+	reqURL := strings.TrimRight(driveFilesURL, "/") + "/" + url.PathEscape(folderID) + "?" + params.Encode()
+	var folder DriveFile
+	if err := d.doJSON(ctx, http.MethodGet, reqURL, nil, &folder); err != nil {
+		return DriveFile{}, fmt.Errorf("drive get folder %s: %w", folderID, err)
+	}
+	return folder, nil
+}
+
+// doJSON sends in as a JSON body, when set, and decodes the response into
+// out, when set. 404 and 410 responses map to ErrDriveNotFound.
+func (d *DriveReader) doJSON(ctx context.Context, method, reqURL string, in, out any) error {
+	var body io.Reader = http.NoBody
+	if in != nil {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, body)
+	if err != nil {
+		return err
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return ErrDriveNotFound
+	}
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(resp.Body)
+		return &domain.StatusError{Op: "drive request", StatusCode: resp.StatusCode, Message: string(msg)}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("decode: %w", err)
+	}
+	return nil
+}
+
+// SupportsDriveFile reports whether a Drive file's type can be ingested.
+func SupportsDriveFile(f DriveFile) bool {
+	return !isDriveFolder(f.MimeType) && supportsDriveFile(f)
+}
+
+// IsDriveFolder reports whether a Drive file is a folder.
+func IsDriveFolder(f DriveFile) bool {
+	return isDriveFolder(f.MimeType)
+}
@@ -0,0 +1,330 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+
+	"github.com/emersion/go-message"
+	_ "github.com/emersion/go-message/charset"
+	"github.com/emersion/go-message/mail"
+)
+
+// emailMessage is the part of an email that is indexed as its own document:
+// the headers a reader scans plus the body.
+type emailMessage struct {
+	Subject string
+	From    string
+	To      string
+	Cc      string
+	Date    time.Time
+	Body    string
+}
+
+// text renders the message the way the IMAP provider renders mailbox
+// messages, so both are searched alike.
+func (m emailMessage) text() string {
+	var b strings.Builder
+	writeEmailHeader(&b, "Subject", m.Subject)
+	writeEmailHeader(&b, "From", m.From)
+	writeEmailHeader(&b, "To", m.To)
+	writeEmailHeader(&b, "Cc", m.Cc)
+	if !m.Date.IsZero() {
+		writeEmailHeader(&b, "Date", m.Date.UTC().Format(time.RFC1123Z))
+	}
+	b.WriteString("\n")
+	b.WriteString(strings.TrimSpace(m.Body))
+	return strings.TrimSpace(b.String())
+}
+
+// fileName names the message document after its subject.
+func (m emailMessage) fileName() string {
+	name := strings.TrimSpace(strings.NewReplacer("/", "-", "\\", "-").Replace(m.Subject))
+	if name == "" {
+		name = "message"
+	}
+	return name + ".txt"
+}
+
+func writeEmailHeader(b *strings.Builder, name, value string) {
+	if value = strings.TrimSpace(value); value != "" {
+		b.WriteString(name + ": " + value + "\n")
+	}
+}
+
+// expandEML emits the message text and every attachment of an RFC 822
+// message. Attached messages are expanded like any nested container.
+func (x *containerExpander) expandEML(prefix string, content []byte, depth int) error {
+	mr, err := mail.CreateReader(bytes.NewReader(content))
+	if err != nil && !message.IsUnknownCharset(err) {
+		return fmt.Errorf("parse email: %w", err)
+	}
+	defer mr.Close()
+
+	msg := emailMessage{}
+	msg.Subject, _ = mr.Header.Subject()
+	msg.From = formatMailAddresses(mr.Header, "From")
+	msg.To = formatMailAddresses(mr.Header, "To")
+	msg.Cc = formatMailAddresses(mr.Header, "Cc")
+	msg.Date, _ = mr.Header.Date()
+
+	var plain, html string
+	attachments := 0
+	for {
+		part, err := mr.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil && !message.IsUnknownCharset(err) {
+			return fmt.Errorf("read email part: %w", err)
+		}
+
+		switch h := part.Header.(type) {
+		case *mail.InlineHeader:
+			mediaType, _, _ := h.ContentType()
+			wantPlain := mediaType == "text/plain" && plain == ""
+			wantHTML := mediaType == "text/html" && html == ""
+			if !wantPlain && !wantHTML {
+				continue
+			}
+			body, ok, err := x.read(part.Body)
+			if err != nil || !ok {
+				if errors.Is(err, ErrContainerLimit) {
+					return err
+				}
+				continue
+			}
+			if wantPlain {
+				plain = string(body)
+			} else {
+				_, html = HTMLToText(body)
+			}
+		case *mail.AttachmentHeader:
+			attachments++
+			if err := x.count(); err != nil {
+				return err
+			}
+			mediaType, _, _ := h.ContentType()
+			name, _ := h.Filename()
+			data, ok, err := x.read(part.Body)
+			if err != nil || !ok {
+				if errors.Is(err, ErrContainerLimit) {
+					return err
+				}
+				continue
+			}
+			name = attachmentName(name, mediaType, attachments)
+			if err := x.entry(path.Join(prefix, name), mediaType, data, depth); err != nil {
+				return err
+			}
+		}
+	}
+
+	msg.Body = plain
+	if strings.TrimSpace(msg.Body) == "" {
+		msg.Body = html
+	}
+	return x.emitMessage(prefix, msg)
+}
+
+// emitMessage hands the message text to visit as a plain-text document.
+func (x *containerExpander) emitMessage(prefix string, msg emailMessage) error {
+	if err := x.count(); err != nil {
+		return err
+	}
+	text := msg.text()
+	if text == "" {
+		return nil
+	}
+	x.bytes += int64(len(text))
+	if x.bytes > x.cfg.MaxTotalBytes {
+		return x.totalLimitError()
+	}
+	return x.visit(ContainerEntry{
+		Path:     path.Join(prefix, msg.fileName()),
+		MimeType: "text/plain",
+		Content:  []byte(text),
+	})
+}
+
+// attachmentName keeps an attachment's file name usable as one path segment
+// and names unnamed attachments by position.
+func attachmentName(name, mediaType string, n int) string {
+	name = strings.TrimSpace(strings.NewReplacer("/", "-", "\\", "-").Replace(name))
+	if name != "" && name != "." && name != ".." {
+		return name
+	}
+	name = "attachment-" + strconv.Itoa(n)
+	if strings.EqualFold(mediaType, "message/rfc822") {
+		name += ".eml"
+	}
+	return name
+}
+
+func formatMailAddresses(h mail.Header, key string) string {
+	addrs, err := h.AddressList(key)
+	if err != nil || len(addrs) == 0 {
+		return strings.TrimSpace(h.Get(key))
+	}
+	out := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		out = append(out, a.String())
+	}
+	return strings.Join(out, ", ")
+}
+
+// Outlook .msg property IDs (MS-OXPROPS) read from a message and its
+// attachments.
+const (
+	msgPropSubject         = "0037"
+	msgPropSenderName      = "0C1A"
+	msgPropSenderEmail     = "0C1F"
+	msgPropDisplayTo       = "0E04"
+	msgPropDisplayCc       = "0E03"
+	msgPropBody            = "1000"
+	msgPropHTML            = "1013"
+	msgPropAttachData      = "3701"
+	msgPropAttachFilename  = "3704"
+	msgPropAttachLongName  = "3707"
+	msgPropAttachMIMETag   = "370E"
+	msgAttachStoragePrefix = "__attach_version1.0_"
+)
+
+// expandMSG emits the message text and the attachments of an Outlook .msg
+// file. Messages embedded as attachments are skipped.
+func (x *containerExpander) expandMSG(prefix string, content []byte, depth int) error {
+	doc, err := openCFB(content)
+	if err != nil {
+		return fmt.Errorf("parse outlook message: %w", err)
+	}
+	root := doc.root()
+	props := msgProperties(doc, root)
+	if len(props) == 0 {
+		return fmt.Errorf("parse outlook message: no message properties")
+	}
+
+	msg := emailMessage{
+		Subject: msgString(props, msgPropSubject),
+		From:    msgSender(props),
+		To:      msgString(props, msgPropDisplayTo),
+		Cc:      msgString(props, msgPropDisplayCc),
+		Body:    msgString(props, msgPropBody),
+	}
+	if strings.TrimSpace(msg.Body) == "" {
+		if html := msgBytes(props, msgPropHTML); len(html) > 0 {
+			_, msg.Body = HTMLToText(html)
+		}
+	}
+
+	attachments := 0
+	for _, child := range doc.children(root) {
+		if child.kind != cfbStorage || !strings.HasPrefix(child.name, msgAttachStoragePrefix) {
+			continue
+		}
+		attachments++
+		if err := x.count(); err != nil {
+			return err
+		}
+		attach := msgProperties(doc, child)
+		data, ok := attach[msgPropAttachData+"0102"]
+		if !ok {
+			continue
+		}
+		if int64(len(data)) > x.cfg.MaxEntryBytes {
+			continue
+		}
+		x.bytes += int64(len(data))
+		if x.bytes > x.cfg.MaxTotalBytes {
+			return x.totalLimitError()
+		}
+		name := msgString(attach, msgPropAttachLongName)
+		if strings.TrimSpace(name) == "" {
+			name = msgString(attach, msgPropAttachFilename)
+		}
+		mediaType := msgString(attach, msgPropAttachMIMETag)
+		name = attachmentName(name, mediaType, attachments)
+		if err := x.entry(path.Join(prefix, name), mediaType, data, depth); err != nil {
+			return err
+		}
+	}
+	return x.emitMessage(prefix, msg)
+}
+
+// msgProperties reads the property streams directly under a storage, keyed
+// by property ID and type (e.g. "0037001F").
+func msgProperties(doc *cfbFile, parent cfbEntry) map[string][]byte {
+	const streamPrefix = "__substg1.0_"
+	props := make(map[string][]byte)
+	for _, e := range doc.children(parent) {
+		if e.kind != cfbStream || !strings.HasPrefix(e.name, streamPrefix) {
+			continue
+		}
+		tag := strings.ToUpper(strings.TrimPrefix(e.name, streamPrefix))
+		if len(tag) != 8 {
+			continue
+		}
+		data, err := doc.stream(e)
+		if err != nil {
+			continue
+		}
+		props[tag] = data
+	}
+	return props
+}
+
+// msgString decodes a string property stored as Unicode (001F) or in the
+// message code page (001E, read as Latin-1 when not ASCII).
+func msgString(props map[string][]byte, id string) string {
+	if data, ok := props[id+"001F"]; ok {
+		return strings.TrimRight(decodeUTF16LE(data), "\x00")
+	}
+	if data, ok := props[id+"001E"]; ok {
+		data = bytes.TrimRight(data, "\x00")
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	}
+	return ""
+}
+
+func msgBytes(props map[string][]byte, id string) []byte {
+	if data, ok := props[id+"0102"]; ok {
+		return data
+	}
+	if s := msgString(props, id); s != "" {
+		return []byte(s)
+	}
+	return nil
+}
+
+func msgSender(props map[string][]byte) string {
+	name := strings.TrimSpace(msgString(props, msgPropSenderName))
+	addr := strings.TrimSpace(msgString(props, msgPropSenderEmail))
+	switch {
+	case name != "" && addr != "" && name != addr:
+		return name + " <" + addr + ">"
+	case name != "":
+		return name
+	default:
+		return addr
+	}
+}
+
+func decodeUTF16LE(data []byte) string {
+	units := make([]uint16, len(data)/2)
+	for i := range units {
+		units[i] = uint16(data[2*i]) | uint16(data[2*i+1])<<8
+	}
+	return string(utf16.Decode(units))
+}
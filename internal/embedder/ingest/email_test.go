@@ -0,0 +1,303 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+const testEML = "From: Ana Lopez <ana@example.com>\r\n" +
+	"To: team@example.com\r\n" +
+	"Subject: Q3 report\r\n" +
+	"Date: Mon, 02 Sep 2024 10:00:00 +0000\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: multipart/mixed; boundary=outer\r\n" +
+	"\r\n" +
+	"--outer\r\n" +
+	"Content-Type: multipart/alternative; boundary=alt\r\n" +
+	"\r\n" +
+	"--alt\r\n" +
+	"Content-Type: text/plain; charset=utf-8\r\n" +
+	"\r\n" +
+	"Numbers are attached.\r\n" +
+	"--alt\r\n" +
+	"Content-Type: text/html; charset=utf-8\r\n" +
+	"\r\n" +
+	"<p>Numbers are <b>attached</b>.</p>\r\n" +
+	"--alt--\r\n" +
+	"--outer\r\n" +
+	"Content-Type: text/csv\r\n" +
+	"Content-Disposition: attachment; filename=\"numbers.csv\"\r\n" +
+	"Content-Transfer-Encoding: base64\r\n" +
+	"\r\n" +
+	"cmV2ZW51ZSwxMDAK\r\n" +
+	"--outer\r\n" +
+	"Content-Type: message/rfc822\r\n" +
+	"Content-Disposition: attachment\r\n" +
+	"\r\n" +
+	"From: bob@example.com\r\n" +
+	"Subject: Earlier thread\r\n" +
+	"Content-Type: text/plain\r\n" +
+	"\r\n" +
+	"Forwarded body.\r\n" +
+	"--outer--\r\n"
+
+func TestExpandContainerEML(t *testing.T) {
+	entries := expandForTest(t, "report.eml", "text/plain", []byte(testEML))
+	got := strings.Join(entryPaths(entries), ",")
+	want := "Q3 report.txt,attachment-2.eml/Earlier thread.txt,numbers.csv"
+	if got != want {
+		t.Fatalf("entries = %s, want %s", got, want)
+	}
+
+	body := string(entries["Q3 report.txt"].Content)
+	for _, wantLine := range []string{
+		"Subject: Q3 report",
+		"From: \"Ana Lopez\" <ana@example.com>",
+		"To: <team@example.com>",
+		"Date: Mon, 02 Sep 2024 10:00:00 +0000",
+		"Numbers are attached.",
+	} {
+		if !strings.Contains(body, wantLine) {
+			t.Fatalf("message text missing %q:\n%s", wantLine, body)
+		}
+	}
+	if csv := string(entries["numbers.csv"].Content); csv != "revenue,100\n" {
+		t.Fatalf("attachment content = %q", csv)
+	}
+	if forwarded := string(entries["attachment-2.eml/Earlier thread.txt"].Content); !strings.Contains(forwarded, "Forwarded body.") {
+		t.Fatalf("forwarded message = %q", forwarded)
+	}
+}
+
+func TestExpandContainerMSG(t *testing.T) {
+	// The report is larger than the mini stream cutoff, so it is read from
+	// regular sectors while the properties come from the mini stream.
+	report := strings.Repeat("quarterly revenue grew ", 300)
+	msg := buildCFB(t, []*cfbTestNode{
+		msgStringProp("0037", "Board minutes"),
+		msgStringProp("0C1A", "Ana Lopez"),
+		msgStringProp("0C1F", "ana@example.com"),
+		msgStringProp("0E04", "Board"),
+		msgStringProp("1000", "Minutes attached."),
+		{name: "__attach_version1.0_#00000000", children: []*cfbTestNode{
+			msgStringProp("3707", "minutes.txt"),
+			{name: "__substg1.0_37010102", data: []byte(report)},
+		}},
+		{name: "__attach_version1.0_#00000001", children: []*cfbTestNode{
+			{name: "__substg1.0_37010102", data: []byte("no name")},
+		}},
+		{name: "__nameid_version1.0", children: []*cfbTestNode{}},
+	})
+
+	entries := expandForTest(t, "minutes.msg", "", msg)
+	if got := strings.Join(entryPaths(entries), ","); got != "Board minutes.txt,attachment-2,minutes.txt" {
+		t.Fatalf("entries = %s", got)
+	}
+	body := string(entries["Board minutes.txt"].Content)
+	for _, wantLine := range []string{"Subject: Board minutes", "From: Ana Lopez <ana@example.com>", "To: Board", "Minutes attached."} {
+		if !strings.Contains(body, wantLine) {
+			t.Fatalf("message text missing %q:\n%s", wantLine, body)
+		}
+	}
+	if got := string(entries["minutes.txt"].Content); got != report {
+		t.Fatalf("attachment content has %d bytes, want %d", len(got), len(report))
+	}
+}
+
+func TestExpandContainerMSGRejectsCorruptFile(t *testing.T) {
+	msg := buildCFB(t, []*cfbTestNode{msgStringProp("0037", "x")})
+	err := ExpandContainer(FileMeta{Name: "broken.msg"}, msg[:600], func(ContainerEntry) error { return nil })
+	if err == nil {
+		t.Fatal("expected error for truncated message")
+	}
+}
+
+type cfbTestNode struct {
+	name     string
+	data     []byte
+	children []*cfbTestNode
+}
+
+func msgStringProp(id, value string) *cfbTestNode {
+	units := utf16.Encode([]rune(value))
+	data := make([]byte, 2*len(units))
+	for i, u := range units {
+		binary.LittleEndian.PutUint16(data[2*i:], u)
+	}
+	return &cfbTestNode{name: "__substg1.0_" + id + "001F", data: data}
+}
+
+// buildCFB writes a version 3 compound file holding the given root children.
+// Streams below 4096 bytes go to the mini stream, as the format requires.
+func buildCFB(t *testing.T, rootChildren []*cfbTestNode) []byte {
+	t.Helper()
+	const (
+		sectorSize = 512
+		miniSize   = 64
+		cutoff     = 4096
+		endChain   = 0xFFFFFFFE
+		noStream   = 0xFFFFFFFF
+		fatSect    = 0xFFFFFFFD
+		freeSect   = 0xFFFFFFFF
+	)
+	le := binary.LittleEndian
+
+	type dirEntry struct {
+		name               string
+		kind               byte
+		left, right, child uint32
+		start              uint32
+		size               uint64
+		data               []byte
+	}
+	entries := []*dirEntry{{name: "Root Entry", kind: 5, left: noStream, right: noStream, child: noStream}}
+	var add func(parent *dirEntry, nodes []*cfbTestNode)
+	add = func(parent *dirEntry, nodes []*cfbTestNode) {
+		var prev *dirEntry
+		for _, n := range nodes {
+			e := &dirEntry{name: n.name, kind: 2, left: noStream, right: noStream, child: noStream, data: n.data}
+			if n.children != nil {
+				e.kind = 1
+			}
+			id := uint32(len(entries))
+			entries = append(entries, e)
+			if prev == nil {
+				parent.child = id
+			} else {
+				prev.right = id
+			}
+			prev = e
+			add(e, n.children)
+		}
+	}
+	add(entries[0], rootChildren)
+
+	var mini, big []byte
+	var miniFAT []uint32
+	var bigStreams []*dirEntry
+	for _, e := range entries[1:] {
+		if e.kind != 2 {
+			continue
+		}
+		e.size = uint64(len(e.data))
+		if len(e.data) == 0 {
+			e.start = endChain
+			continue
+		}
+		if len(e.data) >= cutoff {
+			bigStreams = append(bigStreams, e)
+			continue
+		}
+		e.start = uint32(len(mini) / miniSize)
+		n := (len(e.data) + miniSize - 1) / miniSize
+		for i := 0; i < n; i++ {
+			next := uint32(len(miniFAT) + 1)
+			if i == n-1 {
+				next = endChain
+			}
+			miniFAT = append(miniFAT, next)
+		}
+		padded := make([]byte, n*miniSize)
+		copy(padded, e.data)
+		mini = append(mini, padded...)
+	}
+
+	sectors := func(n int) int { return (n + sectorSize - 1) / sectorSize }
+	var fat []uint32
+	alloc := func(n int) uint32 {
+		if n == 0 {
+			return endChain
+		}
+		start := uint32(len(fat))
+		for i := 0; i < n; i++ {
+			next := uint32(len(fat) + 1)
+			if i == n-1 {
+				next = endChain
+			}
+			fat = append(fat, next)
+		}
+		return start
+	}
+	dirStart := alloc(sectors(len(entries) * 128))
+	miniFATStart := alloc(sectors(len(miniFAT) * 4))
+	miniStart := alloc(sectors(len(mini)))
+	for _, e := range bigStreams {
+		e.start = alloc(sectors(len(e.data)))
+		big = append(big, padSector(e.data, sectorSize)...)
+	}
+	fatStart := uint32(len(fat))
+	fat = append(fat, fatSect)
+	if len(fat) > sectorSize/4 {
+		t.Fatalf("test compound file needs more than one FAT sector")
+	}
+	for len(fat) < sectorSize/4 {
+		fat = append(fat, freeSect)
+	}
+
+	entries[0].start = miniStart
+	entries[0].size = uint64(len(mini))
+
+	header := make([]byte, sectorSize)
+	copy(header, []byte{0xd0, 0xcf, 0x11, 0xe0, 0xa1, 0xb1, 0x1a, 0xe1})
+	le.PutUint16(header[0x18:], 0x3E)
+	le.PutUint16(header[0x1A:], 3)
+	le.PutUint16(header[0x1C:], 0xFFFE)
+	le.PutUint16(header[0x1E:], 9)
+	le.PutUint16(header[0x20:], 6)
+	le.PutUint32(header[0x2C:], 1)
+	le.PutUint32(header[0x30:], dirStart)
+	le.PutUint32(header[0x38:], cutoff)
+	le.PutUint32(header[0x3C:], miniFATStart)
+	le.PutUint32(header[0x40:], uint32(sectors(len(miniFAT)*4)))
+	le.PutUint32(header[0x44:], endChain)
+	for i := 0; i < 109; i++ {
+		le.PutUint32(header[0x4C+4*i:], freeSect)
+	}
+	le.PutUint32(header[0x4C:], fatStart)
+
+	dir := make([]byte, 0, len(entries)*128)
+	for _, e := range entries {
+		b := make([]byte, 128)
+		units := utf16.Encode([]rune(e.name))
+		for i, u := range units {
+			le.PutUint16(b[2*i:], u)
+		}
+		le.PutUint16(b[0x40:], uint16(2*(len(units)+1)))
+		b[0x42] = e.kind
+		b[0x43] = 1
+		le.PutUint32(b[0x44:], e.left)
+		le.PutUint32(b[0x48:], e.right)
+		le.PutUint32(b[0x4C:], e.child)
+		le.PutUint32(b[0x74:], e.start)
+		le.PutUint64(b[0x78:], e.size)
+		dir = append(dir, b...)
+	}
+	miniFATBytes := make([]byte, 4*len(miniFAT))
+	for i, v := range miniFAT {
+		le.PutUint32(miniFATBytes[4*i:], v)
+	}
+	fatBytes := make([]byte, 4*len(fat))
+	for i, v := range fat {
+		le.PutUint32(fatBytes[4*i:], v)
+	}
+
+	out := append([]byte{}, header...)
+	out = append(out, padSector(dir, sectorSize)...)
+	out = append(out, padSector(miniFATBytes, sectorSize)...)
+	out = append(out, padSector(mini, sectorSize)...)
+	out = append(out, big...)
+	out = append(out, fatBytes...)
+	return out
+}
+
+func padSector(data []byte, size int) []byte {
+	n := (len(data) + size - 1) / size * size
+	out := make([]byte, n)
+	copy(out, data)
+	return out
+}
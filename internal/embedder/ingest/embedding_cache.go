@@ -0,0 +1,101 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/embedding"
+)
+
+// embeddingCachePruneInterval is how often Run prunes the embedding cache.
+const embeddingCachePruneInterval = time.Hour
+
+// embeddingCache is the part of postgres.EmbeddingCache the worker uses.
+type embeddingCache interface {
+	Lookup(ctx context.Context, domainID, profile, model string, hashes []string) (map[string][]float32, error)
+	Store(ctx context.Context, domainID, profile, model string, hashes []string, embeddings [][]float32) error
+	Prune(ctx context.Context, unusedFor time.Duration) (int64, error)
+}
+
+// embedCached embeds texts with profile, taking the vectors of texts seen
+// before in domainID from the embedding cache. Only texts missing from the cache are
+// sent to the model, each once however often it repeats. Cache errors are
+// logged and the texts embedded as if they were missing.
+func (w *Worker) embedCached(
+	ctx context.Context,
+	domainID string,
+	profile embedding.Profile,
+	texts []string,
+) ([][]float32, error) {
+	if w.embedCache == nil {
+		return profile.Embed(ctx, texts)
+	}
+
+	hashes := make([]string, len(texts))
+	for i, text := range texts {
+		hashes[i] = domain.ContentHash(text)
+	}
+	cached, err := w.embedCache.Lookup(ctx, domainID, profile.Name, profile.Model, hashes)
+	if err != nil {
+		slog.Warn("ingest: embedding cache lookup", "profile", profile.Name, "err", err)
+		cached = make(map[string][]float32, len(texts))
+	}
+
+	var missTexts, missHashes []string
+	queued := make(map[string]struct{})
+	for i, hash := range hashes {
+		if _, ok := cached[hash]; ok {
+			continue
+		}
+		if _, ok := queued[hash]; ok {
+			continue
+		}
+		queued[hash] = struct{}{}
+		missTexts = append(missTexts, texts[i])
+		missHashes = append(missHashes, hash)
+	}
+	if len(missTexts) > 0 {
+		vecs, err := profile.Embed(ctx, missTexts)
+		if err != nil {
+			return nil, err
+		}
+		if len(vecs) != len(missTexts) {
+			return nil, fmt.Errorf("embed: got %d embeddings for %d chunks", len(vecs), len(missTexts))
+		}
+		for i, hash := range missHashes {
+			cached[hash] = vecs[i]
+		}
+		if err := w.embedCache.Store(ctx, domainID, profile.Name, profile.Model, missHashes, vecs); err != nil {
+			slog.Warn("ingest: embedding cache store", "profile", profile.Name, "err", err)
+		}
+	}
+
+	out := make([][]float32, len(texts))
+	for i, hash := range hashes {
+		out[i] = cached[hash]
+	}
+	return out, nil
+}
+
+// pruneEmbeddingCache drops cache entries unused for the cache TTL, at most
+// once per embeddingCachePruneInterval.
+func (w *Worker) pruneEmbeddingCache(ctx context.Context) {
+	if w.embedCache == nil || w.embedCacheTTL <= 0 || time.Since(w.cachePrunedAt) < embeddingCachePruneInterval {
+		return
+	}
+	w.cachePrunedAt = time.Now()
+	n, err := w.embedCache.Prune(ctx, w.embedCacheTTL)
+	if err != nil {
+		slog.Warn("ingest: prune embedding cache", "err", err)
+		return
+	}
+	if n > 0 {
+		slog.Info("ingest: pruned embedding cache", "entries", n)
+	}
+}
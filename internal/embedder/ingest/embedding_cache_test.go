@@ -0,0 +1,128 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/embedding"
+)
+
+// countingEmbedder embeds each text as its length and records the texts it
+// was asked to embed.
+type countingEmbedder struct {
+	calls [][]string
+}
+
+func (e *countingEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	e.calls = append(e.calls, texts)
+	out := make([][]float32, len(texts))
+	for i, t := range texts {
+		out[i] = []float32{float32(len(t))}
+	}
+	return out, nil
+}
+
+func (e *countingEmbedder) Dimensions() int { return 1 }
+
+type embeddingCacheStub struct {
+	entries   map[string][]float32
+	lookupErr error
+}
+
+func (c *embeddingCacheStub) Lookup(
+	_ context.Context,
+	domainID, profile, model string,
+	hashes []string,
+) (map[string][]float32, error) {
+	if c.lookupErr != nil {
+		return nil, c.lookupErr
+	}
+	out := make(map[string][]float32)
+	for _, h := range hashes {
+		if v, ok := c.entries[domainID+"/"+profile+"/"+model+"/"+h]; ok {
+			out[h] = v
+		}
+	}
+	return out, nil
+}
+
+func (c *embeddingCacheStub) Store(
+	_ context.Context,
+	domainID, profile, model string,
+	hashes []string,
+	embeddings [][]float32,
+) error {
+	for i, h := range hashes {
+		c.entries[domainID+"/"+profile+"/"+model+"/"+h] = embeddings[i]
+	}
+	return nil
+}
+
+func (c *embeddingCacheStub) Prune(context.Context, time.Duration) (int64, error) { return 0, nil }
+
+func TestWorkerEmbedCachedSkipsKnownChunks(t *testing.T) {
+	cache := &embeddingCacheStub{entries: map[string][]float32{
+		"domain-1/text/m1/" + domain.ContentHash("cached"): {42},
+	}}
+	emb := &countingEmbedder{}
+	profile := embedding.Profile{Name: "text", Model: "m1", Embedder: emb}
+	w := &Worker{embedCache: cache}
+
+	vecs, err := w.embedCached(context.Background(), "domain-1", profile, []string{"cached", "new", "new", "other"})
+	if err != nil {
+		t.Fatalf("embedCached: %v", err)
+	}
+	want := []float32{42, 3, 3, 5}
+	for i, v := range vecs {
+		if v[0] != want[i] {
+			t.Fatalf("vector %d = %v, want %v", i, v, want[i])
+		}
+	}
+	if len(emb.calls) != 1 || len(emb.calls[0]) != 2 {
+		t.Fatalf("embedder calls = %v, want one call with the two unseen texts", emb.calls)
+	}
+
+	// Everything is cached now; a different model is not.
+	if _, err := w.embedCached(context.Background(), "domain-1", profile, []string{"new", "other"}); err != nil {
+		t.Fatalf("embedCached: %v", err)
+	}
+	if len(emb.calls) != 1 {
+		t.Fatalf("embedder called for cached texts: %v", emb.calls)
+	}
+	profile.Model = "m2"
+	if _, err := w.embedCached(context.Background(), "domain-1", profile, []string{"new"}); err != nil {
+		t.Fatalf("embedCached: %v", err)
+	}
+	if len(emb.calls) != 2 {
+		t.Fatalf("embedder not called for another model: %v", emb.calls)
+	}
+
+	// Another domain does not see this domain's entries.
+	profile.Model = "m1"
+	if _, err := w.embedCached(context.Background(), "domain-2", profile, []string{"cached"}); err != nil {
+		t.Fatalf("embedCached: %v", err)
+	}
+	if len(emb.calls) != 3 {
+		t.Fatalf("embedder not called for another domain: %v", emb.calls)
+	}
+}
+
+func TestWorkerEmbedCachedFallsBackOnCacheError(t *testing.T) {
+	cache := &embeddingCacheStub{entries: map[string][]float32{}, lookupErr: errors.New("db down")}
+	emb := &countingEmbedder{}
+	w := &Worker{embedCache: cache}
+
+	vecs, err := w.embedCached(context.Background(), "domain-1", embedding.Profile{Name: "text", Model: "m1", Embedder: emb}, []string{"a", "bb"})
+	if err != nil {
+		t.Fatalf("embedCached: %v", err)
+	}
+	if len(vecs) != 2 || vecs[1][0] != 2 {
+		t.Fatalf("vectors = %v", vecs)
+	}
+}
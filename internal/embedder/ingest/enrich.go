@@ -0,0 +1,194 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/llm"
+)
+
+const (
+	defaultEnrichMaxInputChars = 8000
+	defaultEnrichTimeout       = 2 * time.Minute
+	maxEnrichListItems         = 10
+	maxEnrichItemChars         = 100
+	maxEnrichTitleChars        = 200
+	maxEnrichSummaryChars      = 2000
+)
+
+const enrichSystemPrompt = `You extract metadata from documents. Reply with only a JSON object with these fields:
+"title": a short descriptive title of the document,
+"summary": a summary of the document in at most three sentences,
+"language": the ISO 639-1 code of the document's main language,
+"entities": up to 10 people, organisations, places or products the document names,
+"keywords": up to 10 keywords someone would search for to find the document.
+Write the title and summary in the document's language.`
+
+// Enricher asks an LLM for the summary, title, language, key entities and
+// keywords of a record's text.
+type Enricher struct {
+	client        llm.Client
+	model         string
+	maxInputChars int
+	timeout       time.Duration
+}
+
+// NewEnricher creates an enricher that sends the start of each document to
+// client. model is recorded on enrichments when client does not report
+// which model served the request.
+func NewEnricher(client llm.Client, model string) *Enricher {
+	return &Enricher{
+		client:        client,
+		model:         model,
+		maxInputChars: defaultEnrichMaxInputChars,
+		timeout:       defaultEnrichTimeout,
+	}
+}
+
+// SetMaxInputChars adjusts how much of a document's text is sent to the LLM.
+func (e *Enricher) SetMaxInputChars(n int) {
+	if n > 0 {
+		e.maxInputChars = n
+	}
+}
+
+// SetTimeout adjusts how long one enrichment may take.
+func (e *Enricher) SetTimeout(d time.Duration) {
+	if d > 0 {
+		e.timeout = d
+	}
+}
+
+// Enrich returns the enrichment of a document named name with the given
+// text.
+func (e *Enricher) Enrich(ctx context.Context, name, text string) (domain.RecordEnrichment, error) {
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	ctx, report := llm.WithServedReport(ctx)
+
+	messages := []llm.Message{
+		{Role: "system", Content: enrichSystemPrompt},
+		{Role: "user", Content: "Document name: " + name + "\n\n" + truncateRunes(text, e.maxInputChars)},
+	}
+	tokens := make(chan string, 64)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- e.client.StreamChat(ctx, messages, tokens)
+	}()
+	var answer strings.Builder
+	for tok := range tokens {
+		answer.WriteString(tok)
+	}
+	if err := <-errCh; err != nil {
+		return domain.RecordEnrichment{}, fmt.Errorf("enrich: %w", err)
+	}
+
+	enrichment, err := parseEnrichment(answer.String())
+	if err != nil {
+		return domain.RecordEnrichment{}, err
+	}
+	enrichment.Model = e.model
+	if served, ok := report.Get(); ok && served.Model != "" {
+		enrichment.Model = served.Model
+	}
+	enrichment.EnrichedAt = time.Now().UTC()
+	return enrichment, nil
+}
+
+// parseEnrichment reads the JSON object in a model's answer, tolerating
+// text or code fences around it, and normalizes its fields.
+func parseEnrichment(answer string) (domain.RecordEnrichment, error) {
+	start := strings.Index(answer, "{")
+	end := strings.LastIndex(answer, "}")
+	if start < 0 || end < start {
+		return domain.RecordEnrichment{}, errors.New("enrich: no JSON object in model answer")
+	}
+	var raw struct {
+		Title    string   `json:"title"`
+		Summary  string   `json:"summary"`
+		Language string   `json:"language"`
+		Entities []string `json:"entities"`
+		Keywords []string `json:"keywords"`
+	}
+	if err := json.Unmarshal([]byte(answer[start:end+1]), &raw); err != nil {
+		return domain.RecordEnrichment{}, fmt.Errorf("enrich: decode model answer: %w", err)
+	}
+
+	enrichment := domain.RecordEnrichment{
+		Title:    truncateRunes(strings.TrimSpace(raw.Title), maxEnrichTitleChars),
+		Summary:  truncateRunes(strings.TrimSpace(raw.Summary), maxEnrichSummaryChars),
+		Language: normalizeLanguageCode(raw.Language),
+		Entities: normalizeEnrichList(raw.Entities),
+		Keywords: normalizeEnrichList(raw.Keywords),
+	}
+	if enrichment.Summary == "" && len(enrichment.Keywords) == 0 {
+		return domain.RecordEnrichment{}, errors.New("enrich: model answer has no summary or keywords")
+	}
+	return enrichment, nil
+}
+
+// normalizeLanguageCode returns code as a lower-case ISO 639-1 code, or ""
+// when it is not one.
+func normalizeLanguageCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	if len(code) != 2 || code[0] < 'a' || code[0] > 'z' || code[1] < 'a' || code[1] > 'z' {
+		return ""
+	}
+	return code
+}
+
+// normalizeEnrichList trims items, drops empty and repeated ones and keeps
+// at most maxEnrichListItems.
+func normalizeEnrichList(items []string) []string {
+	seen := make(map[string]struct{}, len(items))
+	var out []string
+	for _, item := range items {
+		item = truncateRunes(strings.TrimSpace(item), maxEnrichItemChars)
+		key := strings.ToLower(item)
+		if item == "" {
+			continue
+		}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		out = append(out, item)
+		if len(out) == maxEnrichListItems {
+			break
+		}
+	}
+	return out
+}
+
+// truncateRunes returns at most n runes of s, cut at a word boundary when
+// one is close to the limit.
+func truncateRunes(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	end, count := len(s), 0
+	for i := range s {
+		if count == n {
+			end = i
+			break
+		}
+		count++
+	}
+	if end == len(s) {
+		return s
+	}
+	cut := s[:end]
+	if i := strings.LastIndexFunc(cut, unicode.IsSpace); i > len(cut)*9/10 {
+		cut = cut[:i]
+	}
+	return strings.TrimSpace(cut)
+}
@@ -0,0 +1,139 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/llm"
+)
+
+type enrichLLMStub struct {
+	answer string
+	calls  int
+	input  string
+}
+
+func (s *enrichLLMStub) StreamChat(ctx context.Context, messages []llm.Message, out chan<- string) error {
+	defer close(out)
+	s.calls++
+	s.input = messages[len(messages)-1].Content
+	llm.ReportServed(ctx, llm.Served{Provider: "stub", Model: "served-model", Attempts: 1})
+	for _, tok := range strings.SplitAfter(s.answer, " ") {
+		out <- tok
+	}
+	return nil
+}
+
+type enrichmentRecordsStub struct {
+	domain.RecordRepository
+	stored  []*domain.RecordEnrichment
+	updates int
+}
+
+func (s *enrichmentRecordsStub) UpdateIngestStage(context.Context, string, string) error {
+	return nil
+}
+
+func (s *enrichmentRecordsStub) UpdateEnrichment(_ context.Context, _ string, e *domain.RecordEnrichment) error {
+	s.updates++
+	s.stored = append(s.stored, e)
+	return nil
+}
+
+func TestParseEnrichment(t *testing.T) {
+	answer := "Here is the metadata:\n```json\n" + `{
+		"title": " Quarterly report ",
+		"summary": "Revenue grew in Q3.",
+		"language": "EN",
+		"entities": ["Acme Corp", "acme corp", "", "Berlin"],
+		"keywords": ["revenue", "q3"]
+	}` + "\n```"
+
+	got, err := parseEnrichment(answer)
+	if err != nil {
+		t.Fatalf("parseEnrichment returned error: %v", err)
+	}
+	if got.Title != "Quarterly report" || got.Summary != "Revenue grew in Q3." || got.Language != "en" {
+		t.Fatalf("unexpected enrichment: %+v", got)
+	}
+	if want := []string{"Acme Corp", "Berlin"}; !slices.Equal(got.Entities, want) {
+		t.Fatalf("entities = %q, want %q", got.Entities, want)
+	}
+	if want := []string{"revenue", "q3"}; !slices.Equal(got.Keywords, want) {
+		t.Fatalf("keywords = %q, want %q", got.Keywords, want)
+	}
+}
+
+func TestParseEnrichmentRejectsUnusableAnswers(t *testing.T) {
+	for _, answer := range []string{
+		"I cannot help with that.",
+		`{"title": "x", "summary": ""}`,
+		`{"summary": }`,
+	} {
+		if _, err := parseEnrichment(answer); err == nil {
+			t.Fatalf("expected error for %q", answer)
+		}
+	}
+	got, err := parseEnrichment(`{"summary": "ok", "language": "english"}`)
+	if err != nil {
+		t.Fatalf("parseEnrichment returned error: %v", err)
+	}
+	if got.Language != "" {
+		t.Fatalf("expected non ISO 639-1 language to be dropped, got %q", got.Language)
+	}
+}
+
+func TestEnricherTruncatesInputAndRecordsServedModel(t *testing.T) {
+	client := &enrichLLMStub{answer: `{"summary": "A short note.", "keywords": ["note"]}`}
+	enricher := NewEnricher(client, "configured-model")
+	enricher.SetMaxInputChars(20)
+
+	got, err := enricher.Enrich(context.Background(), "note.txt", strings.Repeat("word ", 100))
+	if err != nil {
+		t.Fatalf("Enrich returned error: %v", err)
+	}
+	if got.Model != "served-model" {
+		t.Fatalf("model = %q, want served-model", got.Model)
+	}
+	if got.EnrichedAt.IsZero() {
+		t.Fatal("expected EnrichedAt to be set")
+	}
+	if text := strings.TrimPrefix(client.input, "Document name: note.txt\n\n"); len(text) > 20 {
+		t.Fatalf("expected input truncated to 20 chars, got %d", len(text))
+	}
+}
+
+func TestWorkerEnrichRecordOnlyWhenContentChanged(t *testing.T) {
+	client := &enrichLLMStub{answer: `{"summary": "A short note.", "keywords": ["note"]}`}
+	records := &enrichmentRecordsStub{}
+	w := NewWorker(records, nil, "worker-1", nil, nil, nil, nil, 512, 0)
+	w.SetEnricher(NewEnricher(client, "model"))
+	ctx := context.Background()
+
+	rec := domain.Record{ID: "r1", Name: "note.txt", Enrichment: &domain.RecordEnrichment{ContentHash: "h1"}}
+	w.enrichRecord(ctx, ctx, rec, "text", "h1", slog.Default())
+	if client.calls != 0 || records.updates != 0 {
+		t.Fatalf("expected unchanged content to skip enrichment, got %d calls", client.calls)
+	}
+
+	w.enrichRecord(ctx, ctx, rec, "new text", "h2", slog.Default())
+	if client.calls != 1 || records.updates != 1 {
+		t.Fatalf("expected changed content to be enriched once, got %d calls", client.calls)
+	}
+	if stored := records.stored[0]; stored == nil || stored.ContentHash != "h2" || stored.Summary != "A short note." {
+		t.Fatalf("unexpected stored enrichment: %+v", stored)
+	}
+
+	client.answer = "no json"
+	w.enrichRecord(ctx, ctx, rec, "newer text", "h3", slog.Default())
+	if records.updates != 2 || records.stored[1] != nil {
+		t.Fatal("expected a failed enrichment to clear the stale one")
+	}
+}
@@ -62,12 +62,14 @@ func ExtractText(f FileMeta, content []byte) (ExtractedDocument, error) {
 		if err != nil {
 			return ExtractedDocument{}, err
 		}
-	case mime == "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+	case isOfficeMIME(mime):
 		var err error
-		doc, err = extractDOCX(content)
+		doc, err = extractOffice(mime, content)
 		if err != nil {
 			return ExtractedDocument{}, err
 		}
+	case mime == "text/html", mime == "application/xhtml+xml":
+		doc = extractHTML(content)
 	case mime == "image/svg+xml":
 		doc = ExtractedDocument{Text: string(content)}
 	case strings.HasPrefix(mime, "image/"):
@@ -81,6 +83,20 @@ func ExtractText(f FileMeta, content []byte) (ExtractedDocument, error) {
 	return doc, nil
 }
 
+// isExtractableMIME reports whether ExtractText handles a file of this type.
+func isExtractableMIME(fileName, mime string) bool {
+	switch {
+	case strings.HasPrefix(mime, "application/vnd.google-apps."),
+		mime == "application/pdf",
+		isOfficeMIME(mime),
+		mime == "application/xhtml+xml",
+		strings.HasPrefix(mime, "image/"):
+		return true
+	default:
+		return isPlainTextLike(fileName, mime)
+	}
+}
+
 func normalizeFileMetaMIMEType(f FileMeta, content []byte) string {
 	mimeType := NormalizeFileMIMEType(f.Name, f.MimeType)
 	mimeType = NormalizeFileMIMEType(f.ID, mimeType)
@@ -131,7 +147,17 @@ func mimeTypeFromExtension(fileName string) string {
 	case ".pdf":
 		return "application/pdf"
 	case ".docx":
-		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+		return mimeDOCX
+	case ".xlsx":
+		return mimeXLSX
+	case ".pptx":
+		return mimePPTX
+	case ".odt":
+		return mimeODT
+	case ".ods":
+		return mimeODS
+	case ".rtf":
+		return mimeRTF
 	case ".md", ".markdown":
 		return "text/markdown"
 	case ".html", ".htm":
@@ -152,6 +178,16 @@ func mimeTypeFromExtension(fileName string) string {
 		return "image/bmp"
 	case ".tif", ".tiff":
 		return "image/tiff"
+	case ".zip":
+		return "application/zip"
+	case ".tar":
+		return "application/x-tar"
+	case ".tgz", ".gz":
+		return "application/gzip"
+	case ".eml":
+		return "message/rfc822"
+	case ".msg":
+		return "application/vnd.ms-outlook"
 	}
 
 	if ext == "" {
@@ -170,8 +206,11 @@ func mimeTypeFromContent(content []byte) string {
 	if len(content) == 0 {
 		return ""
 	}
-	if isDOCXContent(content) {
-		return "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	if office := officeMIMEFromZip(content); office != "" {
+		return office
+	}
+	if bytes.HasPrefix(content, []byte(`{\rtf`)) {
+		return mimeRTF
 	}
 
 	sniff := content
@@ -185,24 +224,6 @@ func mimeTypeFromContent(content []byte) string {
 	return detected
 }
 
-func isDOCXContent(content []byte) bool {
-	if len(content) < 4 || string(content[:2]) != "PK" {
-		return false
-	}
-
-	reader := bytes.NewReader(content)
-	archive, err := zip.NewReader(reader, int64(len(content)))
-	if err != nil {
-		return false
-	}
-	for _, file := range archive.File {
-		if file.Name == "word/document.xml" {
-			return true
-		}
-	}
-	return false
-}
-
 func extractImageText(f FileMeta, content []byte) ExtractedDocument {
 	if text, ok := maybeImageOCR(f, content); ok {
 		cfg := GetExtractionConfig().OCR
@@ -284,6 +305,16 @@ func pdfPageCount(content []byte) *int {
 	return &n
 }
 
+// extractHTML keeps the main content of a page (see HTMLToText) under its
+// title.
+func extractHTML(content []byte) ExtractedDocument {
+	title, text := HTMLToText(content)
+	if title != "" && !strings.HasPrefix(text, title) {
+		text = strings.TrimSpace(title + "\n\n" + text)
+	}
+	return ExtractedDocument{Text: text}
+}
+
 func extractDOCX(content []byte) (ExtractedDocument, error) {
 	readerAt := bytes.NewReader(content)
 	archive, err := zip.NewReader(readerAt, int64(len(content)))
@@ -0,0 +1,221 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"unicode/utf8"
+)
+
+// streamSegmentBytes bounds one segment of streamed text. Segments end at a
+// page break or, failing that, at the last whitespace before this size.
+const streamSegmentBytes = 1 << 20
+
+// TextStream yields a document's text one segment at a time. PDF text is
+// yielded page by page.
+type TextStream struct {
+	scanner   *bufio.Scanner
+	pdf       bool
+	pages     int
+	pageBreak bool
+	wait      func() error
+	cleanup   func()
+	body      io.Closer
+}
+
+// OpenDocument reads body into memory when it is at most threshold bytes
+// and extracts it like ExtractText. Larger PDFs and text files are returned
+// as a TextStream instead, so their size does not bound memory; other
+// formats need the whole file and are still read in full. A threshold of
+// zero or less never streams. body is closed when the document has been
+// read, or when the returned stream is closed.
+func OpenDocument(ctx context.Context, f FileMeta, body io.ReadCloser, threshold int64) (ExtractedDocument, *TextStream, error) {
+	doc, stream, err := openDocument(ctx, f, body, threshold)
+	if stream == nil {
+		_ = body.Close()
+		return doc, nil, err
+	}
+	stream.body = body
+	return doc, stream, nil
+}
+
+func openDocument(ctx context.Context, f FileMeta, body io.Reader, threshold int64) (ExtractedDocument, *TextStream, error) {
+	if threshold <= 0 {
+		content, err := io.ReadAll(body)
+		if err != nil {
+			return ExtractedDocument{}, nil, err
+		}
+		doc, err := ExtractText(f, content)
+		return doc, nil, err
+	}
+
+	head, err := io.ReadAll(io.LimitReader(body, threshold+1))
+	if err != nil {
+		return ExtractedDocument{}, nil, err
+	}
+	if int64(len(head)) <= threshold {
+		doc, err := ExtractText(f, head)
+		return doc, nil, err
+	}
+
+	mime := strings.ToLower(strings.TrimSpace(normalizeFileMetaMIMEType(f, head)))
+	rest := io.MultiReader(bytes.NewReader(head), body)
+	switch {
+	case mime == "application/pdf":
+		stream, err := openPDFStream(ctx, rest)
+		if err != nil {
+			return ExtractedDocument{}, nil, err
+		}
+		return ExtractedDocument{MimeType: mime}, stream, nil
+	case strings.HasPrefix(mime, "application/vnd.google-apps."), isPlainTextLike(f.Name, mime):
+		return ExtractedDocument{MimeType: mime}, newTextStream(rest), nil
+	default:
+		remaining, err := io.ReadAll(body)
+		if err != nil {
+			return ExtractedDocument{}, nil, err
+		}
+		doc, err := ExtractText(f, append(head, remaining...))
+		return doc, nil, err
+	}
+}
+
+func newTextStream(r io.Reader) *TextStream {
+	s := &TextStream{scanner: bufio.NewScanner(r)}
+	s.scanner.Buffer(make([]byte, 0, 64<<10), streamSegmentBytes)
+	s.scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := splitTextSegments(data, atEOF)
+		s.pageBreak = token != nil && advance > len(token) && data[len(token)] == '\f'
+		return advance, token, err
+	})
+	return s
+}
+
+// openPDFStream spools the PDF to a temporary file, which pdftotext needs
+// for random access, and streams its output.
+func openPDFStream(ctx context.Context, r io.Reader) (*TextStream, error) {
+	file, err := os.CreateTemp("", "cube-ingest-*.pdf")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}
+	if _, err := io.Copy(file, r); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("spool pdf: %w", err)
+	}
+
+	cmd := exec.CommandContext(ctx, "pdftotext", file.Name(), "-")
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		cleanup()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cleanup()
+		return nil, fmt.Errorf("pdftotext: %w", err)
+	}
+
+	stream := newTextStream(out)
+	stream.pdf = true
+	stream.wait = cmd.Wait
+	stream.cleanup = func() {
+		if stream.wait != nil {
+			_ = cmd.Process.Kill()
+			_ = cmd.Wait()
+		}
+		cleanup()
+	}
+	return stream, nil
+}
+
+// Next returns the next non-empty segment, or io.EOF after the last one.
+func (s *TextStream) Next() (string, error) {
+	for s.scanner.Scan() {
+		if s.pdf && s.pageBreak {
+			s.pages++
+		}
+		text := strings.ReplaceAll(s.scanner.Text(), "\x00", "")
+		if strings.TrimSpace(text) != "" {
+			return text, nil
+		}
+	}
+	if err := s.scanner.Err(); err != nil {
+		return "", fmt.Errorf("extract text: %w", err)
+	}
+	if s.wait != nil {
+		wait := s.wait
+		s.wait = nil
+		if err := wait(); err != nil {
+			return "", permanentf("pdftotext: %v", err)
+		}
+	}
+	return "", io.EOF
+}
+
+// PageCount reports the number of pages read so far for PDFs, or nil for
+// other documents.
+func (s *TextStream) PageCount() *int {
+	if !s.pdf {
+		return nil
+	}
+	n := s.pages
+	return &n
+}
+
+// Close releases the extractor. It is safe to call more than once.
+func (s *TextStream) Close() error {
+	if s.cleanup != nil {
+		s.cleanup()
+		s.cleanup = nil
+	}
+	if s.body != nil {
+		err := s.body.Close()
+		s.body = nil
+		return err
+	}
+	return nil
+}
+
+// splitTextSegments is a bufio.SplitFunc that cuts at form feeds, which
+// pdftotext writes after every page, and otherwise at the last whitespace
+// once streamSegmentBytes are buffered, so no word is split.
+func splitTextSegments(data []byte, atEOF bool) (int, []byte, error) {
+	if i := bytes.IndexByte(data, '\f'); i >= 0 {
+		return i + 1, data[:i], nil
+	}
+	if atEOF {
+		if len(data) == 0 {
+			return 0, nil, nil
+		}
+		return len(data), data, nil
+	}
+	if len(data) < streamSegmentBytes {
+		return 0, nil, nil
+	}
+	if i := bytes.LastIndexAny(data, " \t\r\n\v"); i > 0 {
+		return i + 1, data[:i+1], nil
+	}
+	// One word longer than a segment: cut it at a rune boundary.
+	i := len(data) - 1
+	for i > 0 && len(data)-i < utf8.UTFMax && !utf8.RuneStart(data[i]) {
+		i--
+	}
+	if utf8.FullRune(data[i:]) {
+		i = len(data)
+	}
+	if i == 0 {
+		return 0, nil, errors.New("text segment has no rune boundary")
+	}
+	return i, data[:i], nil
+}
@@ -0,0 +1,99 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+type closeTracker struct {
+	io.Reader
+	closed bool
+}
+
+func (c *closeTracker) Close() error {
+	c.closed = true
+	return nil
+}
+
+func TestOpenDocumentBelowThresholdExtractsInMemory(t *testing.T) {
+	body := &closeTracker{Reader: strings.NewReader("small text file")}
+	doc, stream, err := OpenDocument(context.Background(), FileMeta{Name: "notes.txt"}, body, 1024)
+	if err != nil {
+		t.Fatalf("OpenDocument returned error: %v", err)
+	}
+	if stream != nil {
+		t.Fatal("expected no stream below the threshold")
+	}
+	if doc.Text != "small text file" {
+		t.Fatalf("unexpected text: %q", doc.Text)
+	}
+	if !body.closed {
+		t.Fatal("expected body to be closed")
+	}
+}
+
+func TestOpenDocumentStreamsLargeText(t *testing.T) {
+	text := strings.Repeat("lorem ipsum dolor sit amet\n", 100) + "page two\fpage three"
+	body := &closeTracker{Reader: strings.NewReader(text)}
+	doc, stream, err := OpenDocument(context.Background(), FileMeta{Name: "dump.txt"}, body, 64)
+	if err != nil {
+		t.Fatalf("OpenDocument returned error: %v", err)
+	}
+	if stream == nil {
+		t.Fatal("expected a stream above the threshold")
+	}
+	if doc.MimeType != "text/plain" {
+		t.Fatalf("unexpected mime type: %q", doc.MimeType)
+	}
+
+	var segments []string
+	for {
+		segment, err := stream.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Next returned error: %v", err)
+		}
+		segments = append(segments, segment)
+	}
+	if strings.Join(segments, "\f") != text {
+		t.Fatalf("streamed text differs from input")
+	}
+	if stream.PageCount() != nil {
+		t.Fatal("expected no page count for text")
+	}
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	if !body.closed {
+		t.Fatal("expected body to be closed with the stream")
+	}
+}
+
+func TestSplitTextSegmentsKeepsWordsAndRunes(t *testing.T) {
+	full := strings.Repeat("a", streamSegmentBytes-5) + " bcd" + "é"
+	advance, token, err := splitTextSegments([]byte(full), false)
+	if err != nil {
+		t.Fatalf("split returned error: %v", err)
+	}
+	if advance != streamSegmentBytes-4 || !strings.HasSuffix(string(token), "a ") {
+		t.Fatalf("expected a cut after the last space, got advance %d", advance)
+	}
+
+	word := strings.Repeat("a", streamSegmentBytes-1) + "é"
+	advance, token, err = splitTextSegments([]byte(word)[:streamSegmentBytes], false)
+	if err != nil {
+		t.Fatalf("split returned error: %v", err)
+	}
+	if advance != streamSegmentBytes-1 || !utf8.Valid(token) {
+		t.Fatalf("expected a cut before the partial rune, got advance %d", advance)
+	}
+}
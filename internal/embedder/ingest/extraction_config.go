@@ -23,9 +23,22 @@ type OCRConfig struct {
 	MaxPDFPages              int
 }
 
+// ArchiveConfig bounds how far archives and email containers are expanded.
+// The limits apply to one top-level container, including everything nested
+// in it, and are measured on decompressed bytes so zip bombs stop early.
+type ArchiveConfig struct {
+	// MaxDepth is the deepest container nesting that is expanded; the
+	// top-level container is depth 1.
+	MaxDepth      int
+	MaxEntries    int
+	MaxEntryBytes int64
+	MaxTotalBytes int64
+}
+
 // ExtractionConfig controls extraction-level behavior shared by all providers.
 type ExtractionConfig struct {
-	OCR OCRConfig
+	OCR     OCRConfig
+	Archive ArchiveConfig
 }
 
 var extractionConfig atomic.Value
@@ -48,6 +61,12 @@ func defaultExtractionConfig() ExtractionConfig {
 			ImageOCROnlyMinTextChars: 1200,
 			MaxPDFPages:              20,
 		},
+		Archive: ArchiveConfig{
+			MaxDepth:      3,
+			MaxEntries:    1000,
+			MaxEntryBytes: 64 << 20,
+			MaxTotalBytes: 512 << 20,
+		},
 	}
 }
 
@@ -89,5 +108,19 @@ func normalizeExtractionConfig(cfg ExtractionConfig) ExtractionConfig {
 	if ocr.MaxPDFPages <= 0 {
 		ocr.MaxPDFPages = def.OCR.MaxPDFPages
 	}
-	return ExtractionConfig{OCR: ocr}
+
+	archive := cfg.Archive
+	if archive.MaxDepth <= 0 {
+		archive.MaxDepth = def.Archive.MaxDepth
+	}
+	if archive.MaxEntries <= 0 {
+		archive.MaxEntries = def.Archive.MaxEntries
+	}
+	if archive.MaxEntryBytes <= 0 {
+		archive.MaxEntryBytes = def.Archive.MaxEntryBytes
+	}
+	if archive.MaxTotalBytes <= 0 {
+		archive.MaxTotalBytes = def.Archive.MaxTotalBytes
+	}
+	return ExtractionConfig{OCR: ocr, Archive: archive}
 }
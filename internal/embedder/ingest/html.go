@@ -0,0 +1,337 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bytes"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// boilerplateElements are dropped with their subtree when converting HTML to text.
+var boilerplateElements = map[atom.Atom]struct{}{
+	atom.Script: {}, atom.Style: {}, atom.Noscript: {}, atom.Template: {},
+	atom.Svg: {}, atom.Nav: {}, atom.Header: {}, atom.Footer: {},
+	atom.Aside: {}, atom.Form: {}, atom.Button: {}, atom.Iframe: {},
+	atom.Select: {}, atom.Head: {},
+}
+
+var boilerplateRoles = map[string]struct{}{
+	"navigation": {}, "banner": {}, "contentinfo": {}, "search": {}, "complementary": {},
+}
+
+var blockElements = map[atom.Atom]struct{}{
+	atom.P: {}, atom.Div: {}, atom.Section: {}, atom.Article: {}, atom.Main: {},
+	atom.H1: {}, atom.H2: {}, atom.H3: {}, atom.H4: {}, atom.H5: {}, atom.H6: {},
+	atom.Ul: {}, atom.Ol: {}, atom.Li: {}, atom.Table: {}, atom.Tr: {},
+	atom.Pre: {}, atom.Blockquote: {}, atom.Dl: {}, atom.Dt: {}, atom.Dd: {},
+	atom.Hr: {}, atom.Br: {}, atom.Figure: {}, atom.Figcaption: {}, atom.Details: {},
+	atom.Summary: {}, atom.Body: {},
+}
+
+// paragraphElements are followed by a blank line.
+var paragraphElements = map[atom.Atom]struct{}{
+	atom.P: {}, atom.H1: {}, atom.H2: {}, atom.H3: {}, atom.H4: {}, atom.H5: {}, atom.H6: {},
+	atom.Ul: {}, atom.Ol: {}, atom.Table: {}, atom.Pre: {}, atom.Blockquote: {}, atom.Dl: {},
+}
+
+// HTMLToText converts an HTML page to plain text. Navigation, headers,
+// footers, scripts and similar boilerplate are dropped, and the <main> or
+// <article> element is preferred over the whole body when present. Without
+// either, the container holding most of the page's prose is used (see
+// contentRoot).
+func HTMLToText(content []byte) (title, text string) {
+	doc, err := html.Parse(bytes.NewReader(content))
+	if err != nil {
+		return "", strings.TrimSpace(string(content))
+	}
+
+	if t := findElement(doc, func(n *html.Node) bool { return n.DataAtom == atom.Title }); t != nil {
+		title = strings.TrimSpace(collapseSpace(nodeText(t)))
+	}
+
+	root := findElement(doc, func(n *html.Node) bool {
+		return n.DataAtom == atom.Main || attr(n, "role") == "main"
+	})
+	if root == nil {
+		root = findElement(doc, func(n *html.Node) bool { return n.DataAtom == atom.Article })
+	}
+	if root == nil {
+		if body := findElement(doc, func(n *html.Node) bool { return n.DataAtom == atom.Body }); body != nil {
+			root = contentRoot(body)
+		}
+	}
+	if root == nil {
+		root = doc
+	}
+
+	var w htmlTextWriter
+	w.walk(root)
+	return title, w.String()
+}
+
+type htmlTextWriter struct {
+	lines []string
+	line  strings.Builder
+	pre   int
+}
+
+// Thresholds for contentRoot. A paragraph counts as prose when it has at
+// least minProseChars of text and at most maxLinkDensity of it is link text.
+const (
+	minProseChars  = 25
+	maxLinkDensity = 0.5
+	// mainContentShare is the share of all prose a container must hold to be
+	// taken as the page's main content.
+	mainContentShare = 0.6
+)
+
+// contentRoot picks the main content of a page that marks none up, in the
+// manner of readability: paragraphs of prose score their parent and, at half
+// weight, their grandparent; the best-scoring container wins, widened to an
+// ancestor until it holds most of the page's prose. Pages with no prose, or
+// whose prose is spread evenly, fall back to body.
+func contentRoot(body *html.Node) *html.Node {
+	scores := make(map[*html.Node]float64)
+	prose := make(map[*html.Node]int)
+	var candidates []*html.Node // in document order, so ties break the same way every time
+	addScore := func(n *html.Node, score float64) {
+		if _, ok := scores[n]; !ok {
+			candidates = append(candidates, n)
+		}
+		scores[n] += score
+	}
+	total := 0
+	var visit func(n *html.Node)
+	visit = func(n *html.Node) {
+		if n.Type == html.ElementNode && isBoilerplate(n) {
+			return
+		}
+		if n.Type == html.ElementNode && (n.DataAtom == atom.P || n.DataAtom == atom.Pre || n.DataAtom == atom.Td) {
+			text := strings.TrimSpace(collapseSpace(nodeText(n)))
+			length := len(text)
+			if length >= minProseChars && linkDensity(n, length) <= maxLinkDensity {
+				total += length
+				score := 1 + float64(strings.Count(text, ",")) + min(float64(length)/100, 3)
+				if parent := n.Parent; parent != nil {
+					addScore(parent, score)
+					if grand := parent.Parent; grand != nil {
+						addScore(grand, score/2)
+					}
+				}
+				for a := n.Parent; a != nil; a = a.Parent {
+					prose[a] += length
+					if a == body {
+						break
+					}
+				}
+			}
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			visit(c)
+		}
+	}
+	visit(body)
+	if total == 0 {
+		return body
+	}
+
+	var best *html.Node
+	for _, n := range candidates {
+		if best == nil || scores[n] > scores[best] {
+			best = n
+		}
+	}
+	for best != nil && best != body && float64(prose[best]) < mainContentShare*float64(total) {
+		best = best.Parent
+	}
+	if best == nil {
+		return body
+	}
+	return best
+}
+
+// linkDensity is the share of a node's text that sits inside links.
+func linkDensity(n *html.Node, length int) float64 {
+	if length == 0 {
+		return 0
+	}
+	links := 0
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.DataAtom == atom.A {
+			links += len(strings.TrimSpace(collapseSpace(nodeText(n))))
+			return
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return float64(links) / float64(length)
+}
+
+func (w *htmlTextWriter) walk(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		w.text(n.Data)
+		return
+	case html.CommentNode, html.DoctypeNode:
+		return
+	case html.ElementNode:
+		if isBoilerplate(n) {
+			return
+		}
+	}
+
+	_, block := blockElements[n.DataAtom]
+	if block {
+		w.breakLine()
+	}
+	switch n.DataAtom {
+	case atom.Li:
+		w.line.WriteString("- ")
+	case atom.Td, atom.Th:
+		if w.line.Len() > 0 {
+			w.line.WriteString(" | ")
+		}
+	case atom.Pre:
+		w.pre++
+	}
+
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		w.walk(c)
+	}
+
+	if block {
+		w.breakLine()
+	}
+	if _, ok := paragraphElements[n.DataAtom]; ok {
+		w.blankLine()
+	}
+	if n.DataAtom == atom.Pre {
+		w.pre--
+	}
+}
+
+func (w *htmlTextWriter) text(s string) {
+	if w.pre > 0 {
+		parts := strings.Split(s, "\n")
+		for i, part := range parts {
+			if i > 0 {
+				w.breakLine()
+			}
+			w.line.WriteString(part)
+		}
+		return
+	}
+	s = collapseSpace(s)
+	if strings.HasPrefix(s, " ") && (w.line.Len() == 0 || strings.HasSuffix(w.line.String(), " ")) {
+		s = s[1:]
+	}
+	w.line.WriteString(s)
+}
+
+func (w *htmlTextWriter) breakLine() {
+	line := strings.TrimRight(w.line.String(), " \t")
+	w.line.Reset()
+	if w.pre == 0 {
+		line = strings.TrimSpace(line)
+	}
+	if strings.TrimSpace(line) == "" || line == "-" {
+		return
+	}
+	w.lines = append(w.lines, line)
+}
+
+// blankLine separates paragraphs so the chunker can split on them.
+func (w *htmlTextWriter) blankLine() {
+	if len(w.lines) > 0 && w.lines[len(w.lines)-1] != "" {
+		w.lines = append(w.lines, "")
+	}
+}
+
+func (w *htmlTextWriter) String() string {
+	w.breakLine()
+	return strings.TrimSpace(strings.Join(w.lines, "\n"))
+}
+
+func isBoilerplate(n *html.Node) bool {
+	if _, ok := boilerplateElements[n.DataAtom]; ok {
+		return true
+	}
+	if _, ok := boilerplateRoles[attr(n, "role")]; ok {
+		return true
+	}
+	if attr(n, "aria-hidden") == "true" {
+		return true
+	}
+	for _, a := range n.Attr {
+		if a.Key == "hidden" {
+			return true
+		}
+	}
+	return false
+}
+
+func findElement(n *html.Node, match func(*html.Node) bool) *html.Node {
+	if n.Type == html.ElementNode && match(n) {
+		return n
+	}
+	for c := n.FirstChild; c != nil; c = c.NextSibling {
+		if found := findElement(c, match); found != nil {
+			return found
+		}
+	}
+	return nil
+}
+
+func nodeText(n *html.Node) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	walk(n)
+	return b.String()
+}
+
+func attr(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return strings.ToLower(strings.TrimSpace(a.Val))
+		}
+	}
+	return ""
+}
+
+func collapseSpace(s string) string {
+	fields := strings.Fields(s)
+	if len(fields) == 0 {
+		if s != "" {
+			return " "
+		}
+		return ""
+	}
+	out := strings.Join(fields, " ")
+	if isSpace(s[0]) {
+		out = " " + out
+	}
+	if isSpace(s[len(s)-1]) {
+		out += " "
+	}
+	return out
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\n' || b == '\t' || b == '\r' || b == '\f'
+}
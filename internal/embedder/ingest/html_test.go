@@ -0,0 +1,44 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLToTextStripsBoilerplate(t *testing.T) {
+	page := `<!doctype html>
+<html><head><title> Install  guide </title><style>body{}</style></head>
+<body>
+  <header><a href="/">Home</a></header>
+  <nav><ul><li>Docs</li><li>Blog</li></ul></nav>
+  <main>
+    <h1>Installing <em>Cube</em></h1>
+    <p>Run the   installer,
+       then restart.</p>
+    <ul><li>Linux</li><li>macOS</li></ul>
+    <pre>make all
+  make run</pre>
+    <div hidden>secret draft</div>
+    <script>alert(1)</script>
+  </main>
+  <footer>Copyright</footer>
+</body></html>`
+
+	title, text := HTMLToText([]byte(page))
+	if title != "Install guide" {
+		t.Fatalf("unexpected title %q", title)
+	}
+	for _, want := range []string{"Installing Cube", "Run the installer, then restart.", "- Linux", "- macOS", "make all\n  make run"} {
+		if !strings.Contains(text, want) {
+			t.Fatalf("expected %q in text:\n%s", want, text)
+		}
+	}
+	for _, unwanted := range []string{"Home", "Docs", "Blog", "Copyright", "alert", "secret draft", "body{}"} {
+		if strings.Contains(text, unwanted) {
+			t.Fatalf("expected %q to be stripped from text:\n%s", unwanted, text)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+// NetworkGuard enforces a SourceNetworkPolicy on the connections of source
+// connectors. Addresses are checked when each connection is dialed, after
+// DNS resolution, so redirects and DNS rebinding cannot reach refused
+// addresses.
+type NetworkGuard struct {
+	policy   domain.SourceNetworkPolicy
+	resolver *net.Resolver
+	dialer   net.Dialer
+}
+
+// NewNetworkGuard creates a guard for policy.
+func NewNetworkGuard(policy domain.SourceNetworkPolicy) *NetworkGuard {
+	return &NetworkGuard{
+		policy:   policy,
+		resolver: net.DefaultResolver,
+		dialer:   net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second},
+	}
+}
+
+// DialContext connects to addr like net.Dialer.DialContext, refusing
+// addresses the policy does not allow. Only TCP is supported.
+func (g *NetworkGuard) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("%w: %s connections are not allowed", domain.ErrAddressNotAllowed, network)
+	}
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	dialer := g.dialer
+	dialer.Control = func(_, address string, _ syscall.RawConn) error {
+		ipHost, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		ip, err := netip.ParseAddr(ipHost)
+		if err != nil {
+			return err
+		}
+		return g.check(ctx, host, ip)
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// HTTPClient returns a client whose connections are checked by the guard.
+// It ignores proxy environment variables, since a proxy would dial the
+// destination on the client's behalf.
+func (g *NetworkGuard) HTTPClient(timeout time.Duration) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = g.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+// CheckHost resolves host and returns an error when the policy refuses any
+// of its addresses.
+func (g *NetworkGuard) CheckHost(ctx context.Context, host string) error {
+	if ip, err := netip.ParseAddr(host); err == nil {
+		return g.check(ctx, host, ip)
+	}
+	ips, err := g.resolver.LookupNetIP(ctx, "ip", host)
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", host, err)
+	}
+	for _, ip := range ips {
+		if err := g.check(ctx, host, ip); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (g *NetworkGuard) check(ctx context.Context, host string, ip netip.Addr) error {
+	if g.policy.Allowlisted(host, ip) {
+		return nil
+	}
+	if !domain.PublicAddr(ip) {
+		return fmt.Errorf("%w: %s resolves to non-public address %s", domain.ErrAddressNotAllowed, host, ip)
+	}
+	if g.policy.Denied(host) || g.deniedAddr(ctx, ip) {
+		return fmt.Errorf("%w: %s is reserved for the embedder", domain.ErrAddressNotAllowed, host)
+	}
+	return nil
+}
+
+// deniedAddr reports whether ip is an address of one of the denied hosts.
+func (g *NetworkGuard) deniedAddr(ctx context.Context, ip netip.Addr) bool {
+	ip = ip.Unmap()
+	for _, host := range g.policy.DeniedHosts {
+		if addr, err := netip.ParseAddr(host); err == nil {
+			if addr.Unmap() == ip {
+				return true
+			}
+			continue
+		}
+		addrs, err := g.resolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if addr.Unmap() == ip {
+				return true
+			}
+		}
+	}
+	return false
+}
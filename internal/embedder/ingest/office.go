@@ -0,0 +1,855 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Office document media types handled by the pure-Go extractors below.
+const (
+	mimeDOCX = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	mimeXLSX = "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	mimePPTX = "application/vnd.openxmlformats-officedocument.presentationml.presentation"
+	mimeODT  = "application/vnd.oasis.opendocument.text"
+	mimeODS  = "application/vnd.oasis.opendocument.spreadsheet"
+	mimeRTF  = "application/rtf"
+)
+
+// maxOfficePartBytes caps a single decompressed XML part so a crafted
+// document cannot inflate without bound.
+const maxOfficePartBytes = 256 << 20
+
+// maxRepeatedCells caps how far an OpenDocument repeat count expands one
+// cell or row. Spreadsheets pad rows with thousands of repeated empty cells.
+const maxRepeatedCells = 256
+
+// maxExpandedSheetBytes caps the text an OpenDocument spreadsheet expands
+// to. Repeat counts are capped per element, but a small document can repeat
+// many large rows.
+const maxExpandedSheetBytes = 64 << 20
+
+var errOfficePartTooLarge = errors.New("document part too large")
+
+var errSheetTooLarge = fmt.Errorf("spreadsheet expands to more than %d bytes of text", maxExpandedSheetBytes)
+
+// officeMIMEFromZip identifies an Office Open XML or OpenDocument file from
+// the parts inside its ZIP container, or returns "" for any other content.
+func officeMIMEFromZip(content []byte) string {
+	if len(content) < 4 || !bytes.HasPrefix(content, zipMagic) {
+		return ""
+	}
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return ""
+	}
+	for _, file := range archive.File {
+		switch file.Name {
+		case "word/document.xml":
+			return mimeDOCX
+		case "xl/workbook.xml":
+			return mimeXLSX
+		case "ppt/presentation.xml":
+			return mimePPTX
+		case "mimetype":
+			// OpenDocument stores its media type uncompressed as the first member.
+			data, err := readZipFile(file)
+			if err != nil {
+				return ""
+			}
+			switch mime := strings.TrimSpace(string(data)); mime {
+			case mimeODT, mimeODS:
+				return mime
+			}
+		}
+	}
+	return ""
+}
+
+// isOfficeMIME reports whether mime is a document handled by extractOffice.
+func isOfficeMIME(mime string) bool {
+	switch mime {
+	case mimeDOCX, mimeXLSX, mimePPTX, mimeODT, mimeODS, mimeRTF, "text/rtf", "application/x-rtf":
+		return true
+	default:
+		return false
+	}
+}
+
+func extractOffice(mime string, content []byte) (ExtractedDocument, error) {
+	switch mime {
+	case mimeDOCX:
+		return extractDOCX(content)
+	case mimeXLSX:
+		return extractXLSX(content)
+	case mimePPTX:
+		return extractPPTX(content)
+	case mimeODT:
+		return extractODT(content)
+	case mimeODS:
+		return extractODS(content)
+	default:
+		return extractRTF(content)
+	}
+}
+
+type officePackage struct {
+	files map[string]*zip.File
+}
+
+func openOfficePackage(kind string, content []byte) (*officePackage, error) {
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return nil, fmt.Errorf("open %s zip: %w", kind, err)
+	}
+	pkg := &officePackage{files: make(map[string]*zip.File, len(archive.File))}
+	for _, file := range archive.File {
+		pkg.files[strings.TrimPrefix(file.Name, "/")] = file
+	}
+	return pkg, nil
+}
+
+// part returns a member of the package, or nil when it is absent.
+func (p *officePackage) part(name string) ([]byte, error) {
+	file, ok := p.files[strings.TrimPrefix(name, "/")]
+	if !ok {
+		return nil, nil
+	}
+	data, err := readZipFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// relationships maps relationship IDs to their resolved part names for the
+// given part, e.g. "rId2" -> "ppt/slides/slide1.xml".
+func (p *officePackage) relationships(partName string) (map[string]officeRelationship, error) {
+	relsName := path.Join(path.Dir(partName), "_rels", path.Base(partName)+".rels")
+	data, err := p.part(relsName)
+	if err != nil || data == nil {
+		return nil, err
+	}
+	var doc struct {
+		Relationships []struct {
+			ID         string `xml:"Id,attr"`
+			Type       string `xml:"Type,attr"`
+			Target     string `xml:"Target,attr"`
+			TargetMode string `xml:"TargetMode,attr"`
+		} `xml:"Relationship"`
+	}
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", relsName, err)
+	}
+	rels := make(map[string]officeRelationship, len(doc.Relationships))
+	for _, r := range doc.Relationships {
+		if strings.EqualFold(r.TargetMode, "External") {
+			continue
+		}
+		target := r.Target
+		if strings.HasPrefix(target, "/") {
+			target = strings.TrimPrefix(target, "/")
+		} else {
+			target = path.Join(path.Dir(partName), target)
+		}
+		rels[r.ID] = officeRelationship{Type: r.Type, Target: target}
+	}
+	return rels, nil
+}
+
+type officeRelationship struct {
+	Type   string
+	Target string
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, maxOfficePartBytes+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxOfficePartBytes {
+		return nil, errOfficePartTooLarge
+	}
+	return data, nil
+}
+
+// extractXLSX renders every worksheet in workbook order. Rows are written
+// against the sheet's header row (see formatSheet) so each chunk keeps the
+// column names its values belong to.
+func extractXLSX(content []byte) (ExtractedDocument, error) {
+	pkg, err := openOfficePackage("xlsx", content)
+	if err != nil {
+		return ExtractedDocument{}, err
+	}
+	const workbookPart = "xl/workbook.xml"
+	workbook, err := pkg.part(workbookPart)
+	if err != nil {
+		return ExtractedDocument{}, err
+	}
+	if workbook == nil {
+		return ExtractedDocument{}, fmt.Errorf("%s not found in xlsx", workbookPart)
+	}
+	var wb struct {
+		Sheets []struct {
+			Name  string     `xml:"name,attr"`
+			State string     `xml:"state,attr"`
+			Attrs []xml.Attr `xml:",any,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(workbook, &wb); err != nil {
+		return ExtractedDocument{}, fmt.Errorf("decode xlsx workbook: %w", err)
+	}
+	rels, err := pkg.relationships(workbookPart)
+	if err != nil {
+		return ExtractedDocument{}, err
+	}
+	shared, err := xlsxSharedStrings(pkg)
+	if err != nil {
+		return ExtractedDocument{}, err
+	}
+
+	var sheets []string
+	for _, sheet := range wb.Sheets {
+		if sheet.State == "veryHidden" {
+			continue
+		}
+		var relID string
+		for _, a := range sheet.Attrs {
+			// r:id lives in the relationships namespace.
+			if a.Name.Local == "id" && a.Name.Space != "" {
+				relID = a.Value
+			}
+		}
+		rel, ok := rels[relID]
+		if !ok {
+			continue
+		}
+		data, err := pkg.part(rel.Target)
+		if err != nil {
+			return ExtractedDocument{}, err
+		}
+		if data == nil {
+			continue
+		}
+		rows, err := xlsxRows(data, shared)
+		if err != nil {
+			return ExtractedDocument{}, fmt.Errorf("decode xlsx sheet %q: %w", sheet.Name, err)
+		}
+		if text := formatSheet(sheet.Name, rows); text != "" {
+			sheets = append(sheets, text)
+		}
+	}
+	return ExtractedDocument{Text: strings.Join(sheets, "\n\n")}, nil
+}
+
+// xlsxSharedStrings reads the workbook's shared string table. Rich-text runs
+// are concatenated; phonetic hints are dropped.
+func xlsxSharedStrings(pkg *officePackage) ([]string, error) {
+	data, err := pkg.part("xl/sharedStrings.xml")
+	if err != nil || data == nil {
+		return nil, err
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		out      []string
+		builder  strings.Builder
+		inText   bool
+		phonetic int
+	)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return out, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("decode xlsx shared strings: %w", err)
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "si":
+				builder.Reset()
+			case "rPh":
+				phonetic++
+			case "t":
+				inText = phonetic == 0
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "si":
+				out = append(out, builder.String())
+			case "rPh":
+				phonetic--
+			case "t":
+				inText = false
+			}
+		case xml.CharData:
+			if inText {
+				builder.Write(tok)
+			}
+		}
+	}
+}
+
+// xlsxRows reads a worksheet into rows of cell text, placing each cell in
+// the column named by its reference so sparse rows keep their alignment.
+func xlsxRows(data []byte, shared []string) ([][]string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		rows     [][]string
+		row      []string
+		cellType string
+		cellCol  int
+		nextCol  int
+		value    strings.Builder
+		inValue  bool
+		inCell   bool
+	)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "row":
+				row = nil
+				nextCol = 0
+			case "c":
+				inCell = true
+				cellType = xmlAttr(tok, "t")
+				cellCol = nextCol
+				if col, ok := cellColumn(xmlAttr(tok, "r")); ok {
+					cellCol = col
+				}
+				value.Reset()
+			case "v", "t":
+				inValue = inCell
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "v", "t":
+				inValue = false
+			case "c":
+				inCell = false
+				text := xlsxCellText(cellType, value.String(), shared)
+				if text != "" {
+					for len(row) <= cellCol {
+						row = append(row, "")
+					}
+					row[cellCol] = text
+				}
+				nextCol = cellCol + 1
+			case "row":
+				rows = append(rows, row)
+			}
+		case xml.CharData:
+			if inValue {
+				value.Write(tok)
+			}
+		}
+	}
+}
+
+func xlsxCellText(cellType, raw string, shared []string) string {
+	switch cellType {
+	case "s":
+		i, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || i < 0 || i >= len(shared) {
+			return ""
+		}
+		return strings.TrimSpace(shared[i])
+	case "b":
+		if strings.TrimSpace(raw) == "1" {
+			return "TRUE"
+		}
+		return "FALSE"
+	default:
+		return strings.TrimSpace(raw)
+	}
+}
+
+// cellColumn converts the letters of a cell reference such as "C12" into a
+// zero-based column index.
+func cellColumn(ref string) (int, bool) {
+	col := 0
+	n := 0
+	for _, r := range ref {
+		if r < 'A' || r > 'Z' {
+			break
+		}
+		col = col*26 + int(r-'A'+1)
+		n++
+	}
+	if n == 0 || n > 3 {
+		return 0, false
+	}
+	return col - 1, true
+}
+
+func xmlAttr(el xml.StartElement, local string) string {
+	for _, a := range el.Attr {
+		if a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// formatSheet serialises a sheet under a "Sheet: <name>" heading. The first
+// non-empty row is treated as the header; every later row is written as
+// "Header: value" pairs so a chunk cut from the middle of the sheet still
+// says what each value is. Columns without a header use their letter.
+func formatSheet(name string, rows [][]string) string {
+	var body []string
+	var header []string
+	for _, row := range rows {
+		if isEmptyRow(row) {
+			continue
+		}
+		if header == nil {
+			header = row
+			continue
+		}
+		var pairs []string
+		for i, cell := range row {
+			if cell == "" {
+				continue
+			}
+			label := ""
+			if i < len(header) {
+				label = header[i]
+			}
+			if label == "" {
+				label = columnName(i)
+			}
+			pairs = append(pairs, label+": "+cell)
+		}
+		body = append(body, strings.Join(pairs, "; "))
+	}
+	if header == nil {
+		return ""
+	}
+
+	var b strings.Builder
+	if name = strings.TrimSpace(name); name != "" {
+		b.WriteString("Sheet: " + name + "\n")
+	}
+	if len(body) == 0 {
+		// A single row is data, not a header.
+		b.WriteString(strings.Join(nonEmpty(header), " | "))
+		return b.String()
+	}
+	b.WriteString("Columns: " + strings.Join(nonEmpty(header), " | ") + "\n")
+	b.WriteString(strings.Join(body, "\n"))
+	return b.String()
+}
+
+func isEmptyRow(row []string) bool {
+	for _, cell := range row {
+		if cell != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func nonEmpty(values []string) []string {
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// columnName returns the spreadsheet letter of a zero-based column index.
+func columnName(i int) string {
+	name := ""
+	for i++; i > 0; i = (i - 1) / 26 {
+		name = string(rune('A'+(i-1)%26)) + name
+	}
+	return name
+}
+
+// extractPPTX renders each slide in presentation order under a "Slide N"
+// heading, followed by its speaker notes. PageCount is the slide count, so
+// slide numbers line up with page numbers elsewhere.
+func extractPPTX(content []byte) (ExtractedDocument, error) {
+	pkg, err := openOfficePackage("pptx", content)
+	if err != nil {
+		return ExtractedDocument{}, err
+	}
+	const presentationPart = "ppt/presentation.xml"
+	presentation, err := pkg.part(presentationPart)
+	if err != nil {
+		return ExtractedDocument{}, err
+	}
+	if presentation == nil {
+		return ExtractedDocument{}, fmt.Errorf("%s not found in pptx", presentationPart)
+	}
+	var pres struct {
+		Slides []struct {
+			Attrs []xml.Attr `xml:",any,attr"`
+		} `xml:"sldIdLst>sldId"`
+	}
+	if err := xml.Unmarshal(presentation, &pres); err != nil {
+		return ExtractedDocument{}, fmt.Errorf("decode pptx presentation: %w", err)
+	}
+	rels, err := pkg.relationships(presentationPart)
+	if err != nil {
+		return ExtractedDocument{}, err
+	}
+
+	var slides []string
+	count := 0
+	for _, s := range pres.Slides {
+		var relID string
+		for _, a := range s.Attrs {
+			if a.Name.Local == "id" && a.Name.Space != "" {
+				relID = a.Value
+			}
+		}
+		rel, ok := rels[relID]
+		if !ok {
+			continue
+		}
+		data, err := pkg.part(rel.Target)
+		if err != nil {
+			return ExtractedDocument{}, err
+		}
+		if data == nil {
+			continue
+		}
+		count++
+		text, err := drawingMLText(data)
+		if err != nil {
+			return ExtractedDocument{}, fmt.Errorf("decode pptx slide %d: %w", count, err)
+		}
+		notes, err := pptxNotes(pkg, rel.Target)
+		if err != nil {
+			return ExtractedDocument{}, err
+		}
+
+		var b strings.Builder
+		b.WriteString("Slide " + strconv.Itoa(count))
+		if text != "" {
+			b.WriteString("\n" + text)
+		}
+		if notes != "" {
+			b.WriteString("\n\nNotes:\n" + notes)
+		}
+		slides = append(slides, b.String())
+	}
+	return ExtractedDocument{Text: strings.Join(slides, "\n\n"), PageCount: &count}, nil
+}
+
+func pptxNotes(pkg *officePackage, slidePart string) (string, error) {
+	rels, err := pkg.relationships(slidePart)
+	if err != nil {
+		return "", err
+	}
+	// Relationship IDs are unordered; sort for a stable pick.
+	ids := make([]string, 0, len(rels))
+	for id := range rels {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	for _, id := range ids {
+		rel := rels[id]
+		if !strings.HasSuffix(rel.Type, "/notesSlide") {
+			continue
+		}
+		data, err := pkg.part(rel.Target)
+		if err != nil || data == nil {
+			return "", err
+		}
+		text, err := drawingMLText(data)
+		if err != nil {
+			return "", fmt.Errorf("decode pptx notes %s: %w", rel.Target, err)
+		}
+		return text, nil
+	}
+	return "", nil
+}
+
+// drawingPlaceholdersSkipped are the placeholders whose text is generated by
+// PowerPoint (slide numbers, dates, footers) rather than written by the author.
+var drawingPlaceholdersSkipped = map[string]struct{}{
+	"sldNum": {}, "dt": {}, "ftr": {}, "hdr": {}, "sldImg": {},
+}
+
+// drawingMLText collects the paragraphs of a slide or notes part, one line
+// per paragraph, skipping generated placeholders.
+func drawingMLText(data []byte) (string, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		lines   []string
+		line    strings.Builder
+		inText  bool
+		skip    bool
+		inShape int
+	)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return strings.Join(lines, "\n"), nil
+		}
+		if err != nil {
+			return "", err
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "sp":
+				inShape++
+				skip = false
+			case "ph":
+				if _, ok := drawingPlaceholdersSkipped[xmlAttr(tok, "type")]; ok && inShape > 0 {
+					skip = true
+				}
+			case "t":
+				inText = true
+			case "br":
+				line.WriteByte('\n')
+			case "tab":
+				line.WriteByte('\t')
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "sp":
+				inShape--
+				skip = false
+			case "t":
+				inText = false
+			case "p":
+				if text := strings.TrimSpace(line.String()); text != "" && !skip {
+					lines = append(lines, text)
+				}
+				line.Reset()
+			}
+		case xml.CharData:
+			if inText {
+				line.Write(tok)
+			}
+		}
+	}
+}
+
+// extractODT renders the headings and paragraphs of an OpenDocument text.
+func extractODT(content []byte) (ExtractedDocument, error) {
+	data, err := odfContent("odt", content)
+	if err != nil {
+		return ExtractedDocument{}, err
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		paragraphs []string
+		builder    strings.Builder
+		depth      int
+		skip       int
+	)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ExtractedDocument{}, fmt.Errorf("decode odt content: %w", err)
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "p", "h":
+				depth++
+			case "annotation", "tracked-changes":
+				skip++
+			default:
+				writeODFSpacing(&builder, tok)
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "p", "h":
+				depth--
+				if depth == 0 {
+					if paragraph := strings.TrimSpace(builder.String()); paragraph != "" && skip == 0 {
+						paragraphs = append(paragraphs, paragraph)
+					}
+					builder.Reset()
+				}
+			case "annotation", "tracked-changes":
+				skip--
+			}
+		case xml.CharData:
+			if depth > 0 && skip == 0 {
+				builder.Write(tok)
+			}
+		}
+	}
+	return ExtractedDocument{Text: strings.Join(paragraphs, "\n\n")}, nil
+}
+
+// extractODS renders every table of an OpenDocument spreadsheet the same way
+// as an XLSX sheet.
+func extractODS(content []byte) (ExtractedDocument, error) {
+	data, err := odfContent("ods", content)
+	if err != nil {
+		return ExtractedDocument{}, err
+	}
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+	var (
+		sheets     []string
+		sheetName  string
+		rows       [][]string
+		row        []string
+		rowRepeat  int
+		cell       strings.Builder
+		cellRepeat int
+		inCell     bool
+		paragraphs int
+		skip       int
+		rowBytes   int
+		expanded   int
+	)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return ExtractedDocument{}, fmt.Errorf("decode ods content: %w", err)
+		}
+		switch tok := token.(type) {
+		case xml.StartElement:
+			switch tok.Name.Local {
+			case "table":
+				sheetName = xmlAttr(tok, "name")
+				rows = nil
+			case "table-row":
+				row = nil
+				rowBytes = 0
+				rowRepeat = odfRepeat(tok, "number-rows-repeated")
+			case "table-cell", "covered-table-cell":
+				inCell = true
+				paragraphs = 0
+				cell.Reset()
+				cellRepeat = odfRepeat(tok, "number-columns-repeated")
+			case "p":
+				if inCell && paragraphs > 0 {
+					cell.WriteByte(' ')
+				}
+				paragraphs++
+			case "annotation":
+				skip++
+			default:
+				writeODFSpacing(&cell, tok)
+			}
+		case xml.EndElement:
+			switch tok.Name.Local {
+			case "table-cell", "covered-table-cell":
+				inCell = false
+				text := strings.TrimSpace(cell.String())
+				if text == "" {
+					// Trailing padding cells repeat thousands of times;
+					// they only matter when a value follows them.
+					for i := 0; i < cellRepeat && len(row) < maxRepeatedCells; i++ {
+						row = append(row, "")
+					}
+					continue
+				}
+				for i := 0; i < cellRepeat && len(row) < maxRepeatedCells; i++ {
+					row = append(row, text)
+					rowBytes += len(text)
+					expanded += len(text)
+				}
+				if expanded > maxExpandedSheetBytes {
+					return ExtractedDocument{}, errSheetTooLarge
+				}
+			case "table-row":
+				if isEmptyRow(row) {
+					continue
+				}
+				for i := 0; i < rowRepeat && i < maxRepeatedCells; i++ {
+					rows = append(rows, row)
+					if i > 0 {
+						expanded += rowBytes
+					}
+				}
+				if expanded > maxExpandedSheetBytes {
+					return ExtractedDocument{}, errSheetTooLarge
+				}
+			case "table":
+				if text := formatSheet(sheetName, rows); text != "" {
+					sheets = append(sheets, text)
+				}
+			case "annotation":
+				skip--
+			}
+		case xml.CharData:
+			if inCell && skip == 0 {
+				cell.Write(tok)
+			}
+		}
+	}
+	return ExtractedDocument{Text: strings.Join(sheets, "\n\n")}, nil
+}
+
+func odfContent(kind string, content []byte) ([]byte, error) {
+	pkg, err := openOfficePackage(kind, content)
+	if err != nil {
+		return nil, err
+	}
+	data, err := pkg.part("content.xml")
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("content.xml not found in %s", kind)
+	}
+	return data, nil
+}
+
+// writeODFSpacing expands the elements OpenDocument uses for whitespace that
+// XML would otherwise collapse.
+func writeODFSpacing(b *strings.Builder, el xml.StartElement) {
+	switch el.Name.Local {
+	case "s":
+		n := 1
+		if c, err := strconv.Atoi(xmlAttr(el, "c")); err == nil && c > 0 && c < 100 {
+			n = c
+		}
+		b.WriteString(strings.Repeat(" ", n))
+	case "tab":
+		b.WriteByte('\t')
+	case "line-break":
+		b.WriteByte('\n')
+	}
+}
+
+func odfRepeat(el xml.StartElement, attr string) int {
+	n, err := strconv.Atoi(xmlAttr(el, attr))
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
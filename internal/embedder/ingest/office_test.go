@@ -0,0 +1,191 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const relsNS = `xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"`
+
+func TestExtractTextXLSX(t *testing.T) {
+	xlsx := buildZip(t, map[string]string{
+		"xl/workbook.xml": `<workbook ` + relsNS + `><sheets>
+			<sheet name="Budget" sheetId="1" r:id="rId1"/>
+			<sheet name="Secret" sheetId="2" state="veryHidden" r:id="rId2"/>
+			<sheet name="Notes" sheetId="3" r:id="rId3"/></sheets></workbook>`,
+		"xl/_rels/workbook.xml.rels": `<Relationships>
+			<Relationship Id="rId1" Target="worksheets/sheet1.xml"/>
+			<Relationship Id="rId2" Target="worksheets/sheet2.xml"/>
+			<Relationship Id="rId3" Target="/xl/worksheets/sheet3.xml"/></Relationships>`,
+		"xl/sharedStrings.xml": `<sst>
+			<si><t>Team</t></si>
+			<si><t>Spend</t></si>
+			<si><r><t>Plat</t></r><r><t>form</t></r><rPh><t>ignored</t></rPh></si></sst>`,
+		"xl/worksheets/sheet1.xml": `<worksheet><sheetData>
+			<row r="1"><c r="A1" t="s"><v>0</v></c><c r="B1" t="s"><v>1</v></c></row>
+			<row r="2"><c r="A2" t="s"><v>2</v></c><c r="B2"><v>1200</v></c><c r="D2" t="inlineStr"><is><t>over plan</t></is></c></row>
+			<row r="3"/>
+			<row r="4"><c r="B4" t="b"><v>1</v></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet2.xml": `<worksheet><sheetData><row><c t="inlineStr"><is><t>hidden</t></is></c></row></sheetData></worksheet>`,
+		"xl/worksheets/sheet3.xml": `<worksheet><sheetData><row><c t="inlineStr"><is><t>Only row</t></is></c><c><v>7</v></c></row></sheetData></worksheet>`,
+	})
+
+	doc, err := ExtractText(FileMeta{Name: "budget.xlsx"}, xlsx)
+	if err != nil {
+		t.Fatalf("ExtractText: %v", err)
+	}
+	want := "Sheet: Budget\n" +
+		"Columns: Team | Spend\n" +
+		"Team: Platform; Spend: 1200; D: over plan\n" +
+		"Spend: TRUE\n\n" +
+		"Sheet: Notes\n" +
+		"Only row | 7"
+	if doc.Text != want {
+		t.Fatalf("unexpected text:\n%s\nwant:\n%s", doc.Text, want)
+	}
+	if doc.MimeType != mimeXLSX {
+		t.Fatalf("mime = %q", doc.MimeType)
+	}
+}
+
+func TestExtractTextPPTX(t *testing.T) {
+	slide := func(body string) string {
+		return `<p:sld xmlns:p="p" xmlns:a="a"><p:cSld><p:spTree>` + body + `</p:spTree></p:cSld></p:sld>`
+	}
+	shape := func(ph string, paragraphs ...string) string {
+		var b strings.Builder
+		b.WriteString(`<p:sp><p:nvSpPr><p:nvPr>`)
+		if ph != "" {
+			b.WriteString(`<p:ph type="` + ph + `"/>`)
+		}
+		b.WriteString(`</p:nvPr></p:nvSpPr><p:txBody>`)
+		for _, p := range paragraphs {
+			b.WriteString(`<a:p><a:r><a:t>` + p + `</a:t></a:r></a:p>`)
+		}
+		b.WriteString(`</p:txBody></p:sp>`)
+		return b.String()
+	}
+	pptx := buildZip(t, map[string]string{
+		"ppt/presentation.xml": `<p:presentation xmlns:p="p" ` + relsNS + `><p:sldIdLst>
+			<p:sldId id="257" r:id="rId7"/><p:sldId id="256" r:id="rId2"/></p:sldIdLst></p:presentation>`,
+		"ppt/_rels/presentation.xml.rels": `<Relationships>
+			<Relationship Id="rId2" Target="slides/slide1.xml"/>
+			<Relationship Id="rId7" Target="slides/slide2.xml"/></Relationships>`,
+		"ppt/slides/slide1.xml": slide(shape("title", "Roadmap") + shape("", "Ship search", "Ship chat") + shape("sldNum", "2")),
+		"ppt/slides/slide2.xml": slide(shape("title", "Welcome")),
+		"ppt/slides/_rels/slide1.xml.rels": `<Relationships>
+			<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/notesSlide" Target="../notesSlides/notesSlide1.xml"/></Relationships>`,
+		"ppt/notesSlides/notesSlide1.xml": slide(shape("sldImg") + shape("body", "Mention the beta date.") + shape("sldNum", "2")),
+	})
+
+	doc, err := ExtractText(FileMeta{Name: "deck.pptx"}, pptx)
+	if err != nil {
+		t.Fatalf("ExtractText: %v", err)
+	}
+	want := "Slide 1\nWelcome\n\n" +
+		"Slide 2\nRoadmap\nShip search\nShip chat\n\nNotes:\nMention the beta date."
+	if doc.Text != want {
+		t.Fatalf("unexpected text:\n%s\nwant:\n%s", doc.Text, want)
+	}
+	if doc.PageCount == nil || *doc.PageCount != 2 {
+		t.Fatalf("page count = %v, want 2", doc.PageCount)
+	}
+}
+
+func TestExtractTextOpenDocument(t *testing.T) {
+	odt := buildZip(t, map[string]string{
+		"mimetype": mimeODT,
+		"content.xml": `<office:document-content xmlns:office="o" xmlns:text="t"><office:body><office:text>
+			<text:h>Minutes</text:h>
+			<text:p>Agreed<text:s text:c="2"/>to ship.<office:annotation><text:p>draft note</text:p></office:annotation></text:p>
+			<text:p>Line one<text:line-break/>line two</text:p>
+			<text:p/></office:text></office:body></office:document-content>`,
+	})
+	// Detected from content alone.
+	doc, err := ExtractText(FileMeta{Name: "download"}, odt)
+	if err != nil {
+		t.Fatalf("ExtractText(odt): %v", err)
+	}
+	if want := "Minutes\n\nAgreed  to ship.\n\nLine one\nline two"; doc.Text != want {
+		t.Fatalf("odt text = %q, want %q", doc.Text, want)
+	}
+
+	ods := buildZip(t, map[string]string{
+		"mimetype": mimeODS,
+		"content.xml": `<office:document-content xmlns:office="o" xmlns:table="tb" xmlns:text="t"><office:body><office:spreadsheet>
+			<table:table table:name="Staff">
+				<table:table-row><table:table-cell><text:p>Name</text:p></table:table-cell><table:table-cell><text:p>Role</text:p></table:table-cell><table:table-cell table:number-columns-repeated="1000"/></table:table-row>
+				<table:table-row><table:table-cell><text:p>Ana</text:p></table:table-cell><table:table-cell><text:p>Lead</text:p></table:table-cell></table:table-row>
+				<table:table-row table:number-rows-repeated="1048000"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>
+			</table:table></office:spreadsheet></office:body></office:document-content>`,
+	})
+	doc, err = ExtractText(FileMeta{Name: "staff.ods"}, ods)
+	if err != nil {
+		t.Fatalf("ExtractText(ods): %v", err)
+	}
+	if want := "Sheet: Staff\nColumns: Name | Role\nName: Ana; Role: Lead"; doc.Text != want {
+		t.Fatalf("ods text = %q, want %q", doc.Text, want)
+	}
+}
+
+func TestExtractTextHTMLKeepsMainContent(t *testing.T) {
+	page := `<html><head><title>Release notes</title></head><body>
+		<div class="menu"><p><a href="/a">Products and pricing for teams</a> <a href="/b">Docs</a></p></div>
+		<div class="content">
+			<p>Version 2 adds spreadsheet, slide and OpenDocument extraction, among other things.</p>
+			<p>Upgrading needs no migration, and existing records are re-indexed on their next sync.</p>
+		</div>
+		<div class="sidebar"><p>Sign up for our newsletter to hear about releases.</p></div>
+	</body></html>`
+
+	doc, err := ExtractText(FileMeta{Name: "notes.html"}, []byte(page))
+	if err != nil {
+		t.Fatalf("ExtractText: %v", err)
+	}
+	if !strings.HasPrefix(doc.Text, "Release notes\n\nVersion 2 adds") {
+		t.Fatalf("expected title then content, got:\n%s", doc.Text)
+	}
+	for _, unwanted := range []string{"Products and pricing", "newsletter", "<p>"} {
+		if strings.Contains(doc.Text, unwanted) {
+			t.Fatalf("expected %q to be dropped:\n%s", unwanted, doc.Text)
+		}
+	}
+}
+
+func TestOfficeMIMEFromZip(t *testing.T) {
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{name: "docx", files: map[string]string{"word/document.xml": ""}, want: mimeDOCX},
+		{name: "xlsx", files: map[string]string{"xl/workbook.xml": ""}, want: mimeXLSX},
+		{name: "pptx", files: map[string]string{"ppt/presentation.xml": ""}, want: mimePPTX},
+		{name: "ods", files: map[string]string{"mimetype": mimeODS}, want: mimeODS},
+		{name: "plain zip", files: map[string]string{"a.txt": "alpha"}, want: ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := officeMIMEFromZip(buildZip(t, tc.files)); got != tc.want {
+				t.Fatalf("officeMIMEFromZip = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestExtractTextODSRejectsRepeatBombs(t *testing.T) {
+	cell := `<table:table-cell table:number-columns-repeated="256"><text:p>` + strings.Repeat("x", 4096) + `</text:p></table:table-cell>`
+	row := `<table:table-row table:number-rows-repeated="256">` + cell + `</table:table-row>`
+	ods := buildZip(t, map[string]string{
+		"mimetype": mimeODS,
+		"content.xml": `<office:document-content xmlns:office="o" xmlns:table="tb" xmlns:text="t"><office:body><office:spreadsheet>
+			<table:table table:name="Bomb">` + strings.Repeat(row, 4) + `</table:table></office:spreadsheet></office:body></office:document-content>`,
+	})
+	if _, err := ExtractText(FileMeta{Name: "bomb.ods"}, ods); !errors.Is(err, errSheetTooLarge) {
+		t.Fatalf("expected errSheetTooLarge, got %v", err)
+	}
+}
@@ -1,7 +1,7 @@
 // Copyright (c) Ultraviolet
 // SPDX-License-Identifier: Apache-2.0
 
-package service
+package ingest
 
 import (
 	"path/filepath"
@@ -23,6 +23,11 @@ var textFileFormats = map[string]domain.RecordFormat{
 	".txt":  domain.RecordFormatText,
 	".pdf":  domain.RecordFormatPDF,
 	".docx": domain.RecordFormatDOCX,
+	".xlsx": domain.RecordFormatXLSX,
+	".pptx": domain.RecordFormatPPTX,
+	".odt":  domain.RecordFormatODT,
+	".ods":  domain.RecordFormatODS,
+	".rtf":  domain.RecordFormatRTF,
 	".html": domain.RecordFormatText,
 	".htm":  domain.RecordFormatText,
 }
@@ -43,23 +48,39 @@ func DetectRecordFormat(fileName, mimeType string) domain.RecordFormat {
 	if format, ok := textFileFormats[ext]; ok {
 		return format
 	}
+	if isContainerMIME(mimeTypeFromExtension(fileName)) {
+		return domain.RecordFormatArchive
+	}
 
 	switch strings.ToLower(strings.TrimSpace(mimeType)) {
 	case "application/pdf":
 		return domain.RecordFormatPDF
-	case "application/vnd.openxmlformats-officedocument.wordprocessingml.document":
+	case mimeDOCX:
 		return domain.RecordFormatDOCX
+	case mimeXLSX:
+		return domain.RecordFormatXLSX
+	case mimePPTX:
+		return domain.RecordFormatPPTX
+	case mimeODT:
+		return domain.RecordFormatODT
+	case mimeODS:
+		return domain.RecordFormatODS
+	case mimeRTF, "text/rtf", "application/x-rtf":
+		return domain.RecordFormatRTF
 	case "text/markdown":
 		return domain.RecordFormatMD
 	case "text/plain", "application/vnd.google-apps.document",
 		"application/vnd.google-apps.spreadsheet", "application/vnd.google-apps.presentation":
 		return domain.RecordFormatText
-	case "text/html":
+	case "text/html", "application/xhtml+xml":
 		return domain.RecordFormatText
 	default:
 		if strings.HasPrefix(strings.ToLower(strings.TrimSpace(mimeType)), "image/") {
 			return domain.RecordFormatImage
 		}
+		if isContainerMIME(normalizeMediaType(mimeType)) {
+			return domain.RecordFormatArchive
+		}
 		return domain.RecordFormatLink
 	}
 }
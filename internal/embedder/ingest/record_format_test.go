@@ -1,7 +1,7 @@
 // Copyright (c) Ultraviolet
 // SPDX-License-Identifier: Apache-2.0
 
-package service
+package ingest
 
 import (
 	"testing"
@@ -76,6 +76,47 @@ func TestDetectRecordFormat(t *testing.T) {
 			mimeType: "application/octet-stream",
 			want:     domain.RecordFormatImage,
 		},
+		{
+			name:     "xlsx extension",
+			fileName: "budget.xlsx",
+			mimeType: "application/octet-stream",
+			want:     domain.RecordFormatXLSX,
+		},
+		{
+			name:     "pptx mime",
+			fileName: "deck",
+			mimeType: "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+			want:     domain.RecordFormatPPTX,
+		},
+		{
+			name:     "opendocument extensions",
+			fileName: "minutes.odt",
+			want:     domain.RecordFormatODT,
+		},
+		{
+			name:     "opendocument spreadsheet mime",
+			fileName: "sheet",
+			mimeType: "application/vnd.oasis.opendocument.spreadsheet",
+			want:     domain.RecordFormatODS,
+		},
+		{
+			name:     "rtf text mime",
+			fileName: "letter",
+			mimeType: "text/rtf",
+			want:     domain.RecordFormatRTF,
+		},
+		{
+			name:     "eml extension maps to archive",
+			fileName: "thread.eml",
+			mimeType: "text/plain",
+			want:     domain.RecordFormatArchive,
+		},
+		{
+			name:     "zip mime maps to archive",
+			fileName: "bundle",
+			mimeType: "application/zip",
+			want:     domain.RecordFormatArchive,
+		},
 		{
 			name:     "fallback link",
 			fileName: "blob.bin",
@@ -0,0 +1,133 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+// RetryPolicy decides how often and how soon a failed ingest is retried.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts, including the first; a
+	// job that fails its last attempt is dead-lettered.
+	MaxAttempts int
+	// BaseDelay is the wait before the second attempt. It doubles with every
+	// further attempt, up to MaxDelay.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// DefaultRetryPolicies returns the retry policy of each error class.
+func DefaultRetryPolicies() map[domain.IngestErrorClass]RetryPolicy {
+	return map[domain.IngestErrorClass]RetryPolicy{
+		domain.IngestErrorTransient:   {MaxAttempts: 5, BaseDelay: 30 * time.Second, MaxDelay: 30 * time.Minute},
+		domain.IngestErrorRateLimited: {MaxAttempts: 8, BaseDelay: time.Minute, MaxDelay: time.Hour},
+		domain.IngestErrorPermanent:   {MaxAttempts: 1},
+		domain.IngestErrorUnknown:     {MaxAttempts: 3, BaseDelay: time.Minute, MaxDelay: 30 * time.Minute},
+	}
+}
+
+// Backoff returns the wait after the given failed attempt, with up to 10%
+// jitter added so jobs that failed together do not retry together.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	delay := p.BaseDelay
+	for i := 1; i < attempt && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 {
+		delay = min(delay, p.MaxDelay)
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay + rand.N(delay/10+1)
+}
+
+// permanentError marks a failure that retrying cannot fix.
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string { return e.err.Error() }
+
+func (e permanentError) Unwrap() error { return e.err }
+
+// permanent marks err as not worth retrying.
+func permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return permanentError{err: err}
+}
+
+// permanentf formats a failure that retrying cannot fix.
+func permanentf(format string, args ...any) error {
+	return permanentError{err: fmt.Errorf(format, args...)}
+}
+
+// httpStatusPattern matches the "status 503" of errors from clients that do
+// not return a domain.StatusError.
+var httpStatusPattern = regexp.MustCompile(`\bstatus (\d{3})\b`)
+
+// classifyIngestError picks the retry policy for an ingest failure.
+func classifyIngestError(err error) domain.IngestErrorClass {
+	var perm permanentError
+	switch {
+	case errors.As(err, &perm), errors.Is(err, domain.ErrNotFound):
+		return domain.IngestErrorPermanent
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, io.ErrUnexpectedEOF),
+		errors.Is(err, syscall.ECONNREFUSED), errors.Is(err, syscall.ECONNRESET):
+		return domain.IngestErrorTransient
+	}
+	var statusErr *domain.StatusError
+	if errors.As(err, &statusErr) {
+		if class, ok := classifyHTTPStatus(statusErr.StatusCode); ok {
+			return class
+		}
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return domain.IngestErrorTransient
+	}
+
+	msg := strings.ToLower(err.Error())
+	if statusErr == nil {
+		if m := httpStatusPattern.FindStringSubmatch(msg); m != nil {
+			code, _ := strconv.Atoi(m[1])
+			if class, ok := classifyHTTPStatus(code); ok {
+				return class
+			}
+		}
+	}
+	if strings.Contains(msg, "rate limit") || strings.Contains(msg, "too many requests") {
+		return domain.IngestErrorRateLimited
+	}
+	return domain.IngestErrorUnknown
+}
+
+// classifyHTTPStatus picks the retry policy for an HTTP error status.
+func classifyHTTPStatus(code int) (domain.IngestErrorClass, bool) {
+	switch {
+	case code == http.StatusTooManyRequests:
+		return domain.IngestErrorRateLimited, true
+	case code == http.StatusRequestTimeout || code >= http.StatusInternalServerError:
+		return domain.IngestErrorTransient, true
+	case code >= http.StatusBadRequest:
+		return domain.IngestErrorPermanent, true
+	}
+	return "", false
+}
@@ -0,0 +1,142 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+func TestClassifyIngestError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want domain.IngestErrorClass
+	}{
+		{"marked permanent", permanentf("unsupported source type %q", "ftp"), domain.IngestErrorPermanent},
+		{"wrapped permanent", fmt.Errorf("extract: %w", permanent(errors.New("bad pdf"))), domain.IngestErrorPermanent},
+		{"not found", fmt.Errorf("get source: %w", domain.ErrNotFound), domain.IngestErrorPermanent},
+		{"deadline", fmt.Errorf("embed: %w", context.DeadlineExceeded), domain.IngestErrorTransient},
+		{"connection refused", fmt.Errorf("dial: %w", syscall.ECONNREFUSED), domain.IngestErrorTransient},
+		{"server error", errors.New("embedding request failed: status 503: unavailable"), domain.IngestErrorTransient},
+		{"request timeout", errors.New("download: status 408"), domain.IngestErrorTransient},
+		{"rate limited status", errors.New("embedding request failed: status 429"), domain.IngestErrorRateLimited},
+		{"rate limited text", errors.New("provider: Rate limit exceeded"), domain.IngestErrorRateLimited},
+		{"client error", errors.New("download: status 403: forbidden"), domain.IngestErrorPermanent},
+		{"other", errors.New("something odd"), domain.IngestErrorUnknown},
+		{"typed server error", fmt.Errorf("embed: %w", &domain.StatusError{Op: "ollama embed", StatusCode: 502}), domain.IngestErrorTransient},
+		{"typed rate limit", fmt.Errorf("download: %w", &domain.StatusError{Op: "microsoft graph", StatusCode: 429}), domain.IngestErrorRateLimited},
+		{"typed client error", &domain.StatusError{Op: "drive download f1", StatusCode: 404, Message: "status 503 in body"}, domain.IngestErrorPermanent},
+		{"typed status wins over text", &domain.StatusError{Op: "fetch", StatusCode: 401, Message: "rate limit"}, domain.IngestErrorPermanent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyIngestError(tt.err); got != tt.want {
+				t.Fatalf("classifyIngestError(%v) = %q, want %q", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	p := RetryPolicy{MaxAttempts: 5, BaseDelay: time.Second, MaxDelay: 10 * time.Second}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{1, time.Second},
+		{2, 2 * time.Second},
+		{3, 4 * time.Second},
+		{4, 8 * time.Second},
+		{5, 10 * time.Second},
+		{20, 10 * time.Second},
+	}
+	for _, tt := range tests {
+		got := p.Backoff(tt.attempt)
+		if got < tt.want || got > tt.want+tt.want/10 {
+			t.Fatalf("Backoff(%d) = %s, want %s plus at most 10%%", tt.attempt, got, tt.want)
+		}
+	}
+	if got := (RetryPolicy{MaxAttempts: 1}).Backoff(1); got != 0 {
+		t.Fatalf("Backoff without delay = %s, want 0", got)
+	}
+}
+
+// ingestJobsStub records how the worker released its job.
+type ingestJobsStub struct {
+	domain.IngestJobRepository
+	retried, dead bool
+	class         domain.IngestErrorClass
+}
+
+func (s *ingestJobsStub) Retry(_ context.Context, _, _ string, _ time.Time, class domain.IngestErrorClass, _ string) error {
+	s.retried, s.class = true, class
+	return nil
+}
+
+func (s *ingestJobsStub) DeadLetter(_ context.Context, _, _ string, class domain.IngestErrorClass, _ string) error {
+	s.dead, s.class = true, class
+	return nil
+}
+
+type recordStatusStub struct {
+	domain.RecordRepository
+	status domain.RecordStatus
+}
+
+func (s *recordStatusStub) GetByID(_ context.Context, _, _ string) (domain.Record, error) {
+	return domain.Record{}, domain.ErrNotFound
+}
+
+func (s *recordStatusStub) UpdateStatus(_ context.Context, _ string, status domain.RecordStatus, _ string) error {
+	s.status = status
+	return nil
+}
+
+func TestWorkerFail(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		attempts   int
+		leaseEnded bool
+		wantRetry  bool
+		wantDead   bool
+		wantStatus domain.RecordStatus
+	}{
+		{name: "transient retried", err: errors.New("status 502"), attempts: 1, wantRetry: true, wantStatus: domain.RecordStatusQueued},
+		{name: "transient exhausted", err: errors.New("status 502"), attempts: 5, wantDead: true, wantStatus: domain.RecordStatusFailed},
+		{name: "permanent dead-lettered", err: permanentf("unsupported"), attempts: 1, wantDead: true, wantStatus: domain.RecordStatusFailed},
+		{name: "lease ended", err: context.Canceled, attempts: 1, leaseEnded: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			jobs := &ingestJobsStub{}
+			records := &recordStatusStub{}
+			w := NewWorker(records, jobs, "worker-1", nil, nil, nil, nil, 512, 0)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.leaseEnded {
+				cancel()
+			}
+			rec := domain.Record{ID: "rec-1"}
+			w.leases[rec.ID] = &ingestLease{job: domain.IngestJob{ID: "job-1", Attempts: tt.attempts}, ctx: ctx}
+
+			w.fail(context.Background(), rec, tt.err)
+
+			if jobs.retried != tt.wantRetry || jobs.dead != tt.wantDead {
+				t.Fatalf("retried=%v dead=%v, want retried=%v dead=%v", jobs.retried, jobs.dead, tt.wantRetry, tt.wantDead)
+			}
+			if records.status != tt.wantStatus {
+				t.Fatalf("record status = %q, want %q", records.status, tt.wantStatus)
+			}
+		})
+	}
+}
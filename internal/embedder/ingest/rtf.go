@@ -0,0 +1,219 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"golang.org/x/text/encoding/charmap"
+)
+
+// rtfSkippedDestinations are groups holding tables, metadata or binary data
+// rather than document text.
+var rtfSkippedDestinations = map[string]struct{}{
+	"fonttbl": {}, "colortbl": {}, "stylesheet": {}, "info": {}, "pict": {},
+	"object": {}, "themedata": {}, "colorschememapping": {}, "datastore": {},
+	"latentstyles": {}, "listtable": {}, "listoverridetable": {}, "rsidtbl": {},
+	"generator": {}, "xmlnstbl": {}, "mmathPr": {}, "fldinst": {}, "filetbl": {},
+	"revtbl": {}, "pgdsctbl": {}, "listtext": {}, "pntext": {}, "bkmkstart": {},
+	"bkmkend": {}, "header": {}, "headerl": {}, "headerr": {}, "headerf": {},
+	"footer": {}, "footerl": {}, "footerr": {}, "footerf": {}, "sp": {},
+	"nonshppict": {}, "datafield": {}, "xe": {}, "tc": {},
+}
+
+// rtfControlText maps control words that stand for a character.
+var rtfControlText = map[string]string{
+	"par": "\n", "sect": "\n\n", "page": "\n\n", "line": "\n", "row": "\n",
+	"tab": "\t", "cell": " | ", "emdash": "—", "endash": "–",
+	"bullet": "•", "lquote": "‘", "rquote": "’",
+	"ldblquote": "“", "rdblquote": "”", "emspace": " ", "enspace": " ",
+	"qmspace": " ",
+}
+
+var rtfCodePages = map[int]*charmap.Charmap{
+	437: charmap.CodePage437, 850: charmap.CodePage850, 1250: charmap.Windows1250,
+	1251: charmap.Windows1251, 1252: charmap.Windows1252, 1253: charmap.Windows1253,
+	1254: charmap.Windows1254, 1255: charmap.Windows1255, 1256: charmap.Windows1256,
+	1257: charmap.Windows1257, 1258: charmap.Windows1258, 10000: charmap.Macintosh,
+}
+
+var rtfExtraBlankLines = regexp.MustCompile(`\n{3,}`)
+
+type rtfGroup struct {
+	skip bool
+	uc   int
+}
+
+// extractRTF strips RTF control words and groups down to the document text.
+// Unicode escapes (\u) and code page escapes (\'hh) are decoded; fonts,
+// styles, pictures and other non-text destinations are dropped.
+func extractRTF(content []byte) (ExtractedDocument, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(content, " \t\r\n"), []byte(`{\rtf`)) {
+		return ExtractedDocument{}, fmt.Errorf("not an rtf document")
+	}
+
+	var (
+		out        strings.Builder
+		stack      []rtfGroup
+		state      = rtfGroup{uc: 1}
+		codePage   = charmap.Windows1252
+		groupStart bool
+		ucSkip     int
+		surrogate  rune
+	)
+	emit := func(s string) {
+		if !state.skip {
+			out.WriteString(s)
+		}
+	}
+	emitByte := func(b byte) {
+		if ucSkip > 0 {
+			ucSkip--
+			return
+		}
+		if b < 0x80 {
+			emit(string(rune(b)))
+			return
+		}
+		emit(string(codePage.DecodeByte(b)))
+	}
+
+	for i := 0; i < len(content); i++ {
+		c := content[i]
+		switch c {
+		case '{':
+			stack = append(stack, state)
+			groupStart = true
+			continue
+		case '}':
+			if len(stack) == 0 {
+				i = len(content)
+				continue
+			}
+			state = stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			ucSkip = 0
+		case '\r', '\n':
+		case '\\':
+			if i+1 >= len(content) {
+				continue
+			}
+			next := content[i+1]
+			if !isASCIILetter(next) {
+				i++
+				switch next {
+				case '\'':
+					if i+2 < len(content) {
+						if b, err := strconv.ParseUint(string(content[i+1:i+3]), 16, 8); err == nil {
+							emitByte(byte(b))
+						}
+						i += 2
+					}
+				case '*':
+					if groupStart {
+						state.skip = true
+					}
+				case '{', '}', '\\':
+					emitByte(next)
+				case '~':
+					emit(" ")
+				case '_':
+					emit("-")
+				case '\r', '\n':
+					emit("\n")
+				}
+				break
+			}
+
+			j := i + 1
+			for j < len(content) && isASCIILetter(content[j]) {
+				j++
+			}
+			word := string(content[i+1 : j])
+			k := j
+			if k < len(content) && content[k] == '-' {
+				k++
+			}
+			for k < len(content) && content[k] >= '0' && content[k] <= '9' {
+				k++
+			}
+			param, hasParam := 0, false
+			if k > j {
+				if n, err := strconv.Atoi(string(content[j:k])); err == nil {
+					param, hasParam = n, true
+				}
+			}
+			if k < len(content) && content[k] == ' ' {
+				k++
+			}
+			i = k - 1
+
+			if groupStart {
+				if _, ok := rtfSkippedDestinations[word]; ok {
+					state.skip = true
+				}
+			}
+			switch word {
+			case "u":
+				if hasParam {
+					if param < 0 {
+						param += 65536
+					}
+					r := rune(param)
+					switch {
+					case utf16.IsSurrogate(r) && surrogate == 0:
+						surrogate = r
+					case surrogate != 0:
+						emit(string(utf16.DecodeRune(surrogate, r)))
+						surrogate = 0
+					default:
+						emit(string(r))
+					}
+					ucSkip = state.uc
+				}
+			case "uc":
+				if hasParam && param >= 0 {
+					state.uc = param
+				}
+			case "ansicpg":
+				if cm, ok := rtfCodePages[param]; ok {
+					codePage = cm
+				}
+			case "mac":
+				codePage = charmap.Macintosh
+			case "pc":
+				codePage = charmap.CodePage437
+			case "pca":
+				codePage = charmap.CodePage850
+			case "bin":
+				if hasParam && param > 0 {
+					i += param
+				}
+			default:
+				if text, ok := rtfControlText[word]; ok {
+					emit(text)
+				}
+			}
+		default:
+			emitByte(c)
+		}
+		groupStart = false
+	}
+
+	lines := strings.Split(out.String(), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	text := rtfExtraBlankLines.ReplaceAllString(strings.Join(lines, "\n"), "\n\n")
+	return ExtractedDocument{Text: strings.TrimSpace(text)}, nil
+}
+
+func isASCIILetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
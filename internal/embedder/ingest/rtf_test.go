@@ -0,0 +1,49 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import "testing"
+
+func TestExtractRTF(t *testing.T) {
+	rtf := `{\rtf1\ansi\ansicpg1252\deff0` +
+		`{\fonttbl{\f0\fswiss Helvetica;}{\f1 Symbol;}}` +
+		`{\colortbl;\red255\green0\blue0;}` +
+		`{\*\generator Riched20 10.0;}` +
+		`{\info{\title Hidden title}{\author Ana}}` +
+		`\pard\f0\fs24 Quarterly {\b review}\par` + "\n" +
+		`Caf\'e9 na\u239?ve \u8212? done\par ` +
+		`{\field{\*\fldinst HYPERLINK "https://example.com"}{\fldrslt example site}}\par ` +
+		`Braces \{kept\} and a back\\slash\line next line\tab tabbed\par` +
+		`{\pict\pngblip 89504e470d0a}` +
+		`}`
+
+	doc, err := ExtractText(FileMeta{Name: "letter.rtf"}, []byte(rtf))
+	if err != nil {
+		t.Fatalf("ExtractText: %v", err)
+	}
+	want := "Quarterly review\n" +
+		"Café naïve — done\n" +
+		"example site\n" +
+		"Braces {kept} and a back\\slash\nnext line\ttabbed"
+	if doc.Text != want {
+		t.Fatalf("unexpected text:\n%q\nwant:\n%q", doc.Text, want)
+	}
+	if doc.MimeType != mimeRTF {
+		t.Fatalf("mime = %q", doc.MimeType)
+	}
+}
+
+func TestExtractRTFDetectsContentAndRejectsOtherText(t *testing.T) {
+	doc, err := ExtractText(FileMeta{Name: "download"}, []byte(`{\rtf1 plain \uc0\u8364 body}`))
+	if err != nil {
+		t.Fatalf("ExtractText: %v", err)
+	}
+	if doc.Text != "plain €body" {
+		t.Fatalf("unexpected text %q", doc.Text)
+	}
+
+	if _, err := extractRTF([]byte("not rtf")); err == nil {
+		t.Fatal("expected error for non-rtf content")
+	}
+}
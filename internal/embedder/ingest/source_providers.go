@@ -5,6 +5,8 @@ package ingest
 
 import (
 	"context"
+	"errors"
+	"io"
 	"time"
 
 	"github.com/ultravioletrs/cube/internal/embedder/domain"
@@ -23,6 +25,11 @@ type SourceFile struct {
 	// FolderID is the immediate parent folder ID. Both optional / best-effort.
 	FolderPath string
 	FolderID   string
+	// ParentExternalID is the external ID of the file this one belongs to,
+	// e.g. the page an attachment is on. The parent's record becomes the
+	// record's parent, so it is deleted with it. The parent must be listed
+	// before the file.
+	ParentExternalID string
 }
 
 // SourceProviderCapabilities describes what integration operations are supported.
@@ -41,12 +48,65 @@ type SourceProvider interface {
 	PrunesStaleRecords() bool
 }
 
+// SourceReleaser is optionally implemented by providers that keep resources
+// per source, such as connection pools. ReleaseSource frees them once the
+// source is deleted or its config changes.
+type SourceReleaser interface {
+	ReleaseSource(sourceID string)
+}
+
 // RawContentProvider is optionally implemented by providers that can return
 // original bytes for non-text processing such as visual image embeddings.
 type RawContentProvider interface {
 	DownloadRecordContent(ctx context.Context, rec domain.Record, src domain.Source) ([]byte, error)
 }
 
+// StreamContentProvider is optionally implemented by providers that can
+// stream original bytes, so files too large to hold in memory can still be
+// ingested.
+type StreamContentProvider interface {
+	OpenRecordContent(ctx context.Context, rec domain.Record, src domain.Source) (io.ReadCloser, error)
+}
+
+// ErrNotStreamable is returned by OpenRecordContent for records that can
+// only be downloaded whole, which are then read with DownloadRecord.
+var ErrNotStreamable = errors.New("record content cannot be streamed")
+
+// ErrFullSyncRequired is returned by ChangeNotifier.Changes when the change
+// feed cannot be resumed from the subscription's cursor, or when too much
+// changed for a targeted sync to be worthwhile.
+var ErrFullSyncRequired = errors.New("full sync required")
+
+// SourceChanges are the items affected by change notifications.
+type SourceChanges struct {
+	// Files are changed files within the source's scope.
+	Files []SourceFile
+	// Removed are external IDs of items that were deleted or left the scope.
+	Removed []string
+	// Cursor resumes the change feed after these changes.
+	Cursor string
+}
+
+// ChangeNotifier is optionally implemented by providers whose systems push
+// change notifications.
+type ChangeNotifier interface {
+	// Subscribe registers sub so notifications are delivered to callbackURL.
+	// It returns sub with the provider's ID, resource, initial cursor and
+	// expiry filled in.
+	Subscribe(ctx context.Context, src domain.Source, sub domain.SourceSubscription, callbackURL string) (domain.SourceSubscription, error)
+	// Renew extends a subscription before it expires.
+	Renew(ctx context.Context, src domain.Source, sub domain.SourceSubscription, callbackURL string) (domain.SourceSubscription, error)
+	Unsubscribe(ctx context.Context, src domain.Source, sub domain.SourceSubscription) error
+	// ParseNotification checks that n belongs to sub and returns the items it
+	// names. It reports false for notifications that carry no changes, such
+	// as handshakes.
+	ParseNotification(sub domain.SourceSubscription, n domain.ChangeNotification) (domain.SourceChangeHint, bool, error)
+	// Changes returns what changed since sub.Cursor, or the items named by
+	// hint. With an empty cursor it returns no changes and the current
+	// position of the feed.
+	Changes(ctx context.Context, src domain.Source, sub domain.SourceSubscription, hint domain.SourceChangeHint) (SourceChanges, error)
+}
+
 // SourceProviderRegistry stores providers keyed by source type.
 type SourceProviderRegistry struct {
 	providers map[domain.SourceType]SourceProvider
@@ -103,6 +163,33 @@ func (r *SourceProviderRegistry) Provider(t domain.SourceType) (SourceProvider,
 	return nil, false
 }
 
+// ReleaseSource lets every provider that keeps resources per source free
+// those of sourceID.
+func (r *SourceProviderRegistry) ReleaseSource(sourceID string) {
+	if r == nil {
+		return
+	}
+	for _, provider := range r.providers {
+		if releaser, ok := provider.(SourceReleaser); ok {
+			releaser.ReleaseSource(sourceID)
+		}
+	}
+}
+
+// Close closes the providers that hold resources, such as connection pools.
+func (r *SourceProviderRegistry) Close() error {
+	if r == nil {
+		return nil
+	}
+	var errs []error
+	for _, provider := range r.providers {
+		if closer, ok := provider.(io.Closer); ok {
+			errs = append(errs, closer.Close())
+		}
+	}
+	return errors.Join(errs...)
+}
+
 // RegisterAlias maps one source type to another provider-backed type.
 func (r *SourceProviderRegistry) RegisterAlias(alias, target domain.SourceType) {
 	if r == nil || alias == "" || target == "" {
@@ -0,0 +1,44 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+type releasingSources struct {
+	domain.SourceRepository
+	providers *SourceProviderRegistry
+}
+
+// WithSourceRelease wraps sources so that providers free what they keep for
+// a source, such as its connection pool, once it is deleted or its config
+// is updated.
+func WithSourceRelease(sources domain.SourceRepository, providers *SourceProviderRegistry) domain.SourceRepository {
+	return &releasingSources{SourceRepository: sources, providers: providers}
+}
+
+func (s *releasingSources) Delete(ctx context.Context, id, domainID string) error {
+	if err := s.SourceRepository.Delete(ctx, id, domainID); err != nil {
+		return err
+	}
+	s.providers.ReleaseSource(id)
+	return nil
+}
+
+func (s *releasingSources) UpdateConfig(
+	ctx context.Context,
+	id, domainID string,
+	config json.RawMessage,
+) (domain.Source, error) {
+	src, err := s.SourceRepository.UpdateConfig(ctx, id, domainID, config)
+	if err != nil {
+		return domain.Source{}, err
+	}
+	s.providers.ReleaseSource(id)
+	return src, nil
+}
@@ -0,0 +1,574 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+// Package confluence indexes Confluence pages and their attachments through
+// the Confluence REST API. It works with both Confluence Cloud and Data
+// Center.
+package confluence
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+)
+
+const (
+	pageMimeType       = "text/html"
+	pageLimit          = 100
+	maxAttachmentBytes = 200 << 20
+	maxPageBytes       = 20 << 20
+	requestTimeout     = 30 * time.Second
+)
+
+type sourceProvider struct {
+	httpClient *http.Client
+}
+
+// NewSourceProvider creates the Confluence provider. Wikis must be hosts
+// policy allows.
+func NewSourceProvider(policy domain.SourceNetworkPolicy) ingest.SourceProvider {
+	return &sourceProvider{httpClient: NewHTTPClient(policy)}
+}
+
+// NewHTTPClient returns the client the provider and Browse use to reach
+// wikis allowed by policy.
+func NewHTTPClient(policy domain.SourceNetworkPolicy) *http.Client {
+	return ingest.NewNetworkGuard(policy).HTTPClient(requestTimeout)
+}
+
+// NewSourceProviderWithHTTPClient creates the Confluence provider with a
+// custom HTTP client.
+func NewSourceProviderWithHTTPClient(httpClient *http.Client) ingest.SourceProvider {
+	if httpClient == nil {
+		httpClient = &http.Client{Timeout: requestTimeout}
+	}
+	return &sourceProvider{httpClient: httpClient}
+}
+
+func (p *sourceProvider) Type() domain.SourceType {
+	return domain.SourceTypeConfluence
+}
+
+func (p *sourceProvider) Capabilities() ingest.SourceProviderCapabilities {
+	return ingest.SourceProviderCapabilities{
+		SupportsList:     true,
+		SupportsDownload: true,
+		SupportsBrowse:   true,
+	}
+}
+
+func (p *sourceProvider) PrunesStaleRecords() bool {
+	return true
+}
+
+// ListFiles returns every current page in scope, followed by its
+// attachments. A page's folder is its ancestry below the space; an
+// attachment's folder is the page it is attached to.
+func (p *sourceProvider) ListFiles(
+	ctx context.Context,
+	_ string,
+	src domain.Source,
+) ([]ingest.SourceFile, error) {
+	cfg, err := decodeConfig(src.Config)
+	if err != nil {
+		return nil, err
+	}
+	client := newClient(p.httpClient, cfg)
+
+	pages, err := client.searchPages(ctx, pageQuery(cfg))
+	if err != nil {
+		return nil, err
+	}
+
+	var files []ingest.SourceFile
+	for _, page := range pages {
+		folder := page.folderPath()
+		files = append(files, ingest.SourceFile{
+			ExternalID:       page.ID,
+			Name:             page.Title,
+			ExternalURL:      client.webURL(page.Links.WebUI),
+			ExternalRef:      page.Space.Key,
+			MimeType:         pageMimeType,
+			SourceVersion:    strconv.Itoa(page.Version.Number),
+			SourceModifiedAt: page.Version.modifiedAt(),
+			FolderPath:       folder,
+			FolderID:         page.parentID(),
+		})
+		if cfg.SkipAttachments {
+			continue
+		}
+
+		attachments, err := client.attachments(ctx, page.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, att := range attachments {
+			if att.Extensions.FileSize > maxAttachmentBytes {
+				continue
+			}
+			files = append(files, ingest.SourceFile{
+				ExternalID:       att.ID,
+				Name:             att.Title,
+				ExternalURL:      client.webURL(att.Links.WebUI),
+				ExternalRef:      page.ID,
+				MimeType:         ingest.NormalizeFileMIMEType(att.Title, att.Extensions.MediaType),
+				SourceVersion:    strconv.Itoa(att.Version.Number),
+				SourceModifiedAt: att.Version.modifiedAt(),
+				FolderPath:       folder + "/" + folderName(page.Title),
+				FolderID:         page.ID,
+				ParentExternalID: page.ID,
+			})
+		}
+	}
+	return files, nil
+}
+
+func (p *sourceProvider) DownloadRecord(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) (string, *int, error) {
+	if !isAttachment(rec.ExternalID) {
+		cfg, err := decodeConfig(src.Config)
+		if err != nil {
+			return "", nil, err
+		}
+		page, err := newClient(p.httpClient, cfg).page(ctx, rec.ExternalID)
+		if err != nil {
+			return "", nil, err
+		}
+		text := StorageToText(page.Body.Storage.Value)
+		if page.Title != "" {
+			text = strings.TrimSpace(page.Title + "\n\n" + text)
+		}
+		return text, nil, nil
+	}
+
+	body, err := p.DownloadRecordContent(ctx, rec, src)
+	if err != nil {
+		return "", nil, err
+	}
+	doc, err := ingest.ExtractText(ingest.FileMeta{
+		ID:       rec.ExternalID,
+		Name:     rec.Name,
+		MimeType: rec.MimeType,
+	}, body)
+	if err != nil {
+		return "", nil, err
+	}
+	return doc.Text, doc.PageCount, nil
+}
+
+// DownloadRecordContent returns an attachment's bytes, or a page's storage
+// format markup.
+func (p *sourceProvider) DownloadRecordContent(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) ([]byte, error) {
+	if strings.TrimSpace(rec.ExternalID) == "" {
+		return nil, fmt.Errorf("record %s is missing external_id", rec.ID)
+	}
+	cfg, err := decodeConfig(src.Config)
+	if err != nil {
+		return nil, err
+	}
+	client := newClient(p.httpClient, cfg)
+
+	if !isAttachment(rec.ExternalID) {
+		page, err := client.page(ctx, rec.ExternalID)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(page.Body.Storage.Value), nil
+	}
+	return client.downloadAttachment(ctx, rec.ExternalID)
+}
+
+// OpenRecordContent streams an attachment's bytes. Pages are rendered from
+// their storage format markup, so they return ingest.ErrNotStreamable.
+func (p *sourceProvider) OpenRecordContent(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) (io.ReadCloser, error) {
+	if !isAttachment(rec.ExternalID) {
+		return nil, ingest.ErrNotStreamable
+	}
+	cfg, err := decodeConfig(src.Config)
+	if err != nil {
+		return nil, err
+	}
+	return newClient(p.httpClient, cfg).openAttachment(ctx, rec.ExternalID)
+}
+
+// BrowseEntry is a space or page returned by browse previews.
+type BrowseEntry struct {
+	ID       string
+	Title    string
+	IsSpace  bool
+	SpaceKey string
+	URL      string
+}
+
+// Browse lists the spaces visible to cfg's credentials when spaceKey and
+// pageID are empty, the top-level pages of a space when only spaceKey is
+// set, and the child pages of pageID otherwise. Requests are sent with
+// httpClient, normally one from NewHTTPClient.
+func Browse(
+	ctx context.Context,
+	httpClient *http.Client,
+	cfg domain.ConfluenceConfig,
+	spaceKey, pageID string,
+) ([]BrowseEntry, error) {
+	client := newClient(httpClient, cfg)
+	spaceKey = strings.TrimSpace(spaceKey)
+	pageID = strings.TrimSpace(pageID)
+
+	var (
+		entries []BrowseEntry
+		err     error
+	)
+	switch {
+	case pageID != "":
+		entries, err = client.childPages(ctx, "/rest/api/content/"+url.PathEscape(pageID)+"/child/page")
+	case spaceKey != "":
+		entries, err = client.childPages(ctx, "/rest/api/space/"+url.PathEscape(spaceKey)+"/content/page?depth=root")
+	default:
+		entries, err = client.spaces(ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return strings.ToLower(entries[i].Title) < strings.ToLower(entries[j].Title)
+	})
+	return entries, nil
+}
+
+func decodeConfig(raw json.RawMessage) (domain.ConfluenceConfig, error) {
+	var cfg domain.ConfluenceConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return domain.ConfluenceConfig{}, fmt.Errorf("decode confluence config: %w", err)
+	}
+	if strings.TrimSpace(cfg.BaseURL) == "" {
+		return domain.ConfluenceConfig{}, fmt.Errorf("confluence base_url is required")
+	}
+	return cfg, nil
+}
+
+// pageQuery builds the CQL selecting the pages in scope.
+func pageQuery(cfg domain.ConfluenceConfig) string {
+	clauses := []string{"type = page"}
+	if len(cfg.SpaceKeys) > 0 {
+		clauses = append(clauses, "space in ("+cqlList(cfg.SpaceKeys)+")")
+	}
+	if len(cfg.PageIDs) > 0 {
+		ids := cqlList(cfg.PageIDs)
+		clauses = append(clauses, "(id in ("+ids+") or ancestor in ("+ids+"))")
+	}
+	return strings.Join(clauses, " and ") + " order by id"
+}
+
+func cqlList(values []string) string {
+	quoted := make([]string, len(values))
+	for i, v := range values {
+		quoted[i] = strconv.Quote(v)
+	}
+	return strings.Join(quoted, ",")
+}
+
+// Confluence attachment IDs carry an "att" prefix; page IDs are numeric.
+func isAttachment(externalID string) bool {
+	return strings.HasPrefix(externalID, "att")
+}
+
+// folderName keeps a page title usable as one folder path segment.
+func folderName(title string) string {
+	return strings.ReplaceAll(strings.TrimSpace(title), "/", "-")
+}
+
+type content struct {
+	ID        string  `json:"id"`
+	Type      string  `json:"type"`
+	Title     string  `json:"title"`
+	Space     space   `json:"space"`
+	Version   version `json:"version"`
+	Ancestors []struct {
+		ID    string `json:"id"`
+		Title string `json:"title"`
+	} `json:"ancestors"`
+	Body struct {
+		Storage struct {
+			Value string `json:"value"`
+		} `json:"storage"`
+	} `json:"body"`
+	Extensions struct {
+		MediaType string `json:"mediaType"`
+		FileSize  int64  `json:"fileSize"`
+	} `json:"extensions"`
+	Links struct {
+		WebUI    string `json:"webui"`
+		Download string `json:"download"`
+	} `json:"_links"`
+}
+
+type space struct {
+	Key   string `json:"key"`
+	Name  string `json:"name"`
+	Links struct {
+		WebUI string `json:"webui"`
+	} `json:"_links"`
+}
+
+type version struct {
+	Number int    `json:"number"`
+	When   string `json:"when"`
+}
+
+func (v version) modifiedAt() *time.Time {
+	if v.When == "" {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339, v.When)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// folderPath is /<space>/<ancestor>/... for the page.
+func (c content) folderPath() string {
+	segments := []string{c.Space.Key}
+	if c.Space.Name != "" {
+		segments[0] = folderName(c.Space.Name)
+	}
+	for _, ancestor := range c.Ancestors {
+		segments = append(segments, folderName(ancestor.Title))
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// parentID is the closest ancestor page, or the space for top-level pages.
+func (c content) parentID() string {
+	if n := len(c.Ancestors); n > 0 {
+		return c.Ancestors[n-1].ID
+	}
+	return c.Space.Key
+}
+
+type resultPage[T any] struct {
+	Results []T `json:"results"`
+	Links   struct {
+		Next string `json:"next"`
+	} `json:"_links"`
+}
+
+type client struct {
+	http    *http.Client
+	baseURL *url.URL
+	cfg     domain.ConfluenceConfig
+}
+
+func newClient(httpClient *http.Client, cfg domain.ConfluenceConfig) *client {
+	base, err := url.Parse(strings.TrimSuffix(strings.TrimSpace(cfg.BaseURL), "/"))
+	if err != nil {
+		base = &url.URL{}
+	}
+	return &client{http: httpClient, baseURL: base, cfg: cfg}
+}
+
+func (c *client) searchPages(ctx context.Context, cql string) ([]content, error) {
+	q := url.Values{}
+	q.Set("cql", cql)
+	q.Set("expand", "space,version,ancestors")
+	q.Set("limit", strconv.Itoa(pageLimit))
+	return list[content](ctx, c, "/rest/api/content/search?"+q.Encode())
+}
+
+func (c *client) attachments(ctx context.Context, pageID string) ([]content, error) {
+	return list[content](ctx, c, "/rest/api/content/"+url.PathEscape(pageID)+
+		"/child/attachment?expand=version&limit="+strconv.Itoa(pageLimit))
+}
+
+func (c *client) page(ctx context.Context, id string) (content, error) {
+	var page content
+	err := c.getJSON(ctx, "/rest/api/content/"+url.PathEscape(id)+"?expand=body.storage,version", &page)
+	return page, err
+}
+
+func (c *client) downloadAttachment(ctx context.Context, id string) ([]byte, error) {
+	body, err := c.openAttachment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return readLimited(body, maxAttachmentBytes, "attachment "+id)
+}
+
+// openAttachment returns the body of an attachment's download unread. The
+// caller closes it.
+func (c *client) openAttachment(ctx context.Context, id string) (io.ReadCloser, error) {
+	var att content
+	if err := c.getJSON(ctx, "/rest/api/content/"+url.PathEscape(id)+"?expand=version", &att); err != nil {
+		return nil, err
+	}
+	if att.Links.Download == "" {
+		return nil, fmt.Errorf("confluence attachment %s has no download link", id)
+	}
+	resp, err := c.get(ctx, att.Links.Download)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func (c *client) spaces(ctx context.Context) ([]BrowseEntry, error) {
+	spaces, err := list[space](ctx, c, "/rest/api/space?limit="+strconv.Itoa(pageLimit))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]BrowseEntry, 0, len(spaces))
+	for _, s := range spaces {
+		entries = append(entries, BrowseEntry{
+			ID:       s.Key,
+			Title:    s.Name,
+			IsSpace:  true,
+			SpaceKey: s.Key,
+			URL:      c.webURL(s.Links.WebUI),
+		})
+	}
+	return entries, nil
+}
+
+func (c *client) childPages(ctx context.Context, ref string) ([]BrowseEntry, error) {
+	sep := "?"
+	if strings.Contains(ref, "?") {
+		sep = "&"
+	}
+	pages, err := list[content](ctx, c, ref+sep+"expand=space&limit="+strconv.Itoa(pageLimit))
+	if err != nil {
+		return nil, err
+	}
+	entries := make([]BrowseEntry, 0, len(pages))
+	for _, page := range pages {
+		entries = append(entries, BrowseEntry{
+			ID:       page.ID,
+			Title:    page.Title,
+			SpaceKey: page.Space.Key,
+			URL:      c.webURL(page.Links.WebUI),
+		})
+	}
+	return entries, nil
+}
+
+// list follows the _links.next cursor of a paginated collection.
+func list[T any](ctx context.Context, c *client, ref string) ([]T, error) {
+	var all []T
+	for ref != "" {
+		var page resultPage[T]
+		if err := c.getJSON(ctx, ref, &page); err != nil {
+			return nil, err
+		}
+		all = append(all, page.Results...)
+		ref = page.Links.Next
+	}
+	return all, nil
+}
+
+func (c *client) getJSON(ctx context.Context, ref string, out any) error {
+	resp, err := c.get(ctx, ref)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := readLimited(resp.Body, maxPageBytes, ref)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("decode confluence response: %w", err)
+	}
+	return nil
+}
+
+func (c *client) get(ctx context.Context, ref string) (*http.Response, error) {
+	target, err := c.resolve(ref)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	switch {
+	case c.cfg.AccessToken != "":
+		req.Header.Set("Authorization", "Bearer "+c.cfg.AccessToken)
+	case c.cfg.APIToken != "":
+		req.SetBasicAuth(c.cfg.Email, c.cfg.APIToken)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("confluence request: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		return nil, &domain.StatusError{Op: "confluence request " + req.URL.Path, StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(msg))}
+	}
+	return resp, nil
+}
+
+// resolve turns a path relative to the wiki root, as used by API links,
+// into an absolute URL. Absolute links must stay on the configured host so
+// credentials are never sent elsewhere.
+func (c *client) resolve(ref string) (string, error) {
+	if c.baseURL.Host == "" {
+		return "", fmt.Errorf("confluence base_url is invalid")
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid confluence link %q: %w", ref, err)
+	}
+	if u.IsAbs() || u.Host != "" {
+		if u.Host != c.baseURL.Host {
+			return "", fmt.Errorf("confluence link %q leaves %s", ref, c.baseURL.Host)
+		}
+		return u.String(), nil
+	}
+	return c.baseURL.String() + "/" + strings.TrimPrefix(ref, "/"), nil
+}
+
+func (c *client) webURL(ref string) string {
+	if ref == "" {
+		return ""
+	}
+	target, err := c.resolve(ref)
+	if err != nil {
+		return ""
+	}
+	return target
+}
+
+func readLimited(r io.Reader, limit int64, what string) ([]byte, error) {
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("read confluence %s: %w", what, err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("confluence %s exceeds %d bytes", what, limit)
+	}
+	return body, nil
+}
@@ -0,0 +1,341 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package confluence_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/confluence"
+)
+
+type fakePage struct {
+	id        string
+	title     string
+	version   int
+	ancestors []string
+	storage   string
+}
+
+// fakeConfluence serves the subset of the Confluence REST API the provider
+// uses, rooted at /wiki like Confluence Cloud.
+func fakeConfluence(t *testing.T) *httptest.Server {
+	t.Helper()
+	pages := map[string]fakePage{
+		"100": {id: "100", title: "Engineering", version: 3, storage: "<p>Welcome</p>"},
+		"200": {id: "200", title: "Runbooks", version: 1, ancestors: []string{"100"}, storage: "<p>Index</p>"},
+		"300": {id: "300", title: "Deploys", version: 7, ancestors: []string{"100", "200"},
+			storage: `<p>Deploy with <ac:link><ri:page ri:content-title="Release Checklist" /></ac:link>.</p>` +
+				`<ac:structured-macro ac:name="code"><ac:parameter ac:name="language">bash</ac:parameter>` +
+				`<ac:plain-text-body><![CDATA[make deploy]]></ac:plain-text-body></ac:structured-macro>`},
+	}
+	order := []string{"100", "200", "300"}
+
+	pageJSON := func(p fakePage, withBody bool) map[string]any {
+		ancestors := make([]map[string]string, 0, len(p.ancestors))
+		for _, id := range p.ancestors {
+			ancestors = append(ancestors, map[string]string{"id": id, "title": pages[id].title})
+		}
+		out := map[string]any{
+			"id":        p.id,
+			"type":      "page",
+			"title":     p.title,
+			"space":     map[string]string{"key": "ENG", "name": "Engineering Space"},
+			"version":   map[string]any{"number": p.version, "when": "2026-03-01T10:00:00.000Z"},
+			"ancestors": ancestors,
+			"_links":    map[string]string{"webui": "/spaces/ENG/pages/" + p.id},
+		}
+		if withBody {
+			out["body"] = map[string]any{"storage": map[string]string{"value": p.storage}}
+		}
+		return out
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/wiki/rest/api/content/search", func(w http.ResponseWriter, r *http.Request) {
+		if !strings.Contains(r.URL.Query().Get("cql"), `space in ("ENG")`) {
+			http.Error(w, "unexpected cql "+r.URL.Query().Get("cql"), http.StatusBadRequest)
+			return
+		}
+		// Two results per page, linked by a relative cursor.
+		start := 0
+		if r.URL.Query().Get("cursor") == "next" {
+			start = 2
+		}
+		end := min(start+2, len(order))
+		results := make([]map[string]any, 0, 2)
+		for _, id := range order[start:end] {
+			results = append(results, pageJSON(pages[id], false))
+		}
+		links := map[string]string{}
+		if end < len(order) {
+			links["next"] = "/rest/api/content/search?cql=" + url.QueryEscape(r.URL.Query().Get("cql")) + "&cursor=next"
+		}
+		writeJSON(w, map[string]any{"results": results, "_links": links})
+	})
+	mux.HandleFunc("/wiki/rest/api/content/300/child/attachment", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, map[string]any{"results": []map[string]any{{
+			"id":         "att900",
+			"type":       "attachment",
+			"title":      "topology.txt",
+			"version":    map[string]any{"number": 2},
+			"extensions": map[string]any{"mediaType": "text/plain", "fileSize": 18},
+			"_links":     map[string]string{"webui": "/pages/viewpageattachments.action?pageId=300"},
+		}}})
+	})
+	mux.HandleFunc("/wiki/rest/api/content/", func(w http.ResponseWriter, r *http.Request) {
+		id := strings.TrimPrefix(r.URL.Path, "/wiki/rest/api/content/")
+		switch {
+		case strings.HasSuffix(id, "/child/attachment"):
+			writeJSON(w, map[string]any{"results": []any{}})
+		case id == "100/child/page":
+			writeJSON(w, map[string]any{"results": []any{pageJSON(pages["200"], false)}})
+		case id == "att900":
+			writeJSON(w, map[string]any{
+				"id":     "att900",
+				"_links": map[string]string{"download": "/download/attachments/300/topology.txt?version=2"},
+			})
+		default:
+			p, ok := pages[id]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			writeJSON(w, pageJSON(p, true))
+		}
+	})
+	mux.HandleFunc("/wiki/download/attachments/300/topology.txt", func(w http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(w, "lb -> api -> db\n")
+	})
+	mux.HandleFunc("/wiki/rest/api/space", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, map[string]any{"results": []map[string]any{
+			{"key": "OPS", "name": "Operations"},
+			{"key": "ENG", "name": "Engineering Space"},
+		}})
+	})
+	mux.HandleFunc("/wiki/rest/api/space/ENG/content/page", func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, map[string]any{"results": []any{pageJSON(pages["100"], false)}})
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "bot@example.com" || pass != "api-token" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func testConfig(srv *httptest.Server) domain.ConfluenceConfig {
+	return domain.ConfluenceConfig{
+		BaseURL:   srv.URL + "/wiki",
+		Email:     "bot@example.com",
+		APIToken:  "api-token",
+		SpaceKeys: []string{"ENG"},
+	}
+}
+
+func testSource(t *testing.T, cfg domain.ConfluenceConfig) domain.Source {
+	t.Helper()
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("marshal config: %v", err)
+	}
+	return domain.Source{ID: "src-1", Type: domain.SourceTypeConfluence, Config: raw}
+}
+
+func TestListFilesMapsPageTreeAndAttachments(t *testing.T) {
+	srv := fakeConfluence(t)
+	provider := confluence.NewSourceProviderWithHTTPClient(srv.Client())
+
+	files, err := provider.ListFiles(context.Background(), "user-1", testSource(t, testConfig(srv)))
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	byID := make(map[string]ingest.SourceFile, len(files))
+	for _, f := range files {
+		byID[f.ExternalID] = f
+	}
+	if len(byID) != 4 {
+		t.Fatalf("expected 3 pages and 1 attachment across both result pages, got %+v", files)
+	}
+
+	root := byID["100"]
+	if root.FolderPath != "/Engineering Space" || root.FolderID != "ENG" || root.SourceVersion != "3" {
+		t.Fatalf("unexpected root page: %+v", root)
+	}
+	deploys := byID["300"]
+	if deploys.FolderPath != "/Engineering Space/Engineering/Runbooks" || deploys.FolderID != "200" {
+		t.Fatalf("expected ancestry in folder path, got %+v", deploys)
+	}
+	if deploys.SourceVersion != "7" || deploys.ExternalURL != srv.URL+"/wiki/spaces/ENG/pages/300" {
+		t.Fatalf("unexpected page version or url: %+v", deploys)
+	}
+	if deploys.SourceModifiedAt == nil {
+		t.Fatal("expected page modification time")
+	}
+
+	att := byID["att900"]
+	if att.FolderID != "300" || att.ParentExternalID != "300" || att.ExternalRef != "300" || att.FolderPath != "/Engineering Space/Engineering/Runbooks/Deploys" {
+		t.Fatalf("expected attachment to be a child of its page, got %+v", att)
+	}
+	if att.SourceVersion != "2" || att.MimeType != "text/plain" {
+		t.Fatalf("unexpected attachment metadata: %+v", att)
+	}
+}
+
+func TestListFilesSkipsAttachments(t *testing.T) {
+	srv := fakeConfluence(t)
+	cfg := testConfig(srv)
+	cfg.SkipAttachments = true
+
+	files, err := confluence.NewSourceProviderWithHTTPClient(srv.Client()).
+		ListFiles(context.Background(), "user-1", testSource(t, cfg))
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	for _, f := range files {
+		if strings.HasPrefix(f.ExternalID, "att") {
+			t.Fatalf("expected attachments to be skipped, got %+v", f)
+		}
+	}
+}
+
+func TestDownloadRecord(t *testing.T) {
+	srv := fakeConfluence(t)
+	provider := confluence.NewSourceProviderWithHTTPClient(srv.Client())
+	src := testSource(t, testConfig(srv))
+
+	text, _, err := provider.DownloadRecord(context.Background(), domain.Record{ExternalID: "300", Name: "Deploys", MimeType: "text/html"}, src)
+	if err != nil {
+		t.Fatalf("download page: %v", err)
+	}
+	want := "Deploys\n\nDeploy with Release Checklist.\n\nmake deploy"
+	if text != want {
+		t.Fatalf("expected %q, got %q", want, text)
+	}
+
+	text, _, err = provider.DownloadRecord(context.Background(), domain.Record{ExternalID: "att900", Name: "topology.txt", MimeType: "text/plain"}, src)
+	if err != nil {
+		t.Fatalf("download attachment: %v", err)
+	}
+	if text != "lb -> api -> db\n" {
+		t.Fatalf("unexpected attachment text %q", text)
+	}
+}
+
+func TestOpenRecordContent(t *testing.T) {
+	srv := fakeConfluence(t)
+	provider, ok := confluence.NewSourceProviderWithHTTPClient(srv.Client()).(ingest.StreamContentProvider)
+	if !ok {
+		t.Fatal("expected provider to stream content")
+	}
+	src := testSource(t, testConfig(srv))
+
+	body, err := provider.OpenRecordContent(context.Background(), domain.Record{ExternalID: "att900"}, src)
+	if err != nil {
+		t.Fatalf("open attachment: %v", err)
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read attachment: %v", err)
+	}
+	if string(content) != "lb -> api -> db\n" {
+		t.Fatalf("unexpected attachment content %q", content)
+	}
+
+	if _, err := provider.OpenRecordContent(context.Background(), domain.Record{ExternalID: "300"}, src); !errors.Is(err, ingest.ErrNotStreamable) {
+		t.Fatalf("expected pages to be downloaded whole, got %v", err)
+	}
+}
+
+func TestDownloadRecordRejectsForeignLinks(t *testing.T) {
+	foreign := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			t.Error("credentials were sent to a foreign host")
+		}
+	}))
+	defer foreign.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		writeJSON(w, map[string]any{"id": "att1", "_links": map[string]string{"download": foreign.URL + "/steal"}})
+	}))
+	defer srv.Close()
+
+	provider, ok := confluence.NewSourceProviderWithHTTPClient(srv.Client()).(ingest.RawContentProvider)
+	if !ok {
+		t.Fatal("expected provider to return raw content")
+	}
+	cfg := domain.ConfluenceConfig{BaseURL: srv.URL, AccessToken: "pat"}
+	if _, err := provider.DownloadRecordContent(context.Background(), domain.Record{ExternalID: "att1"}, testSource(t, cfg)); err == nil {
+		t.Fatal("expected a download link on another host to be refused")
+	}
+}
+
+func TestBrowse(t *testing.T) {
+	srv := fakeConfluence(t)
+	cfg := testConfig(srv)
+
+	spaces, err := confluence.Browse(context.Background(), srv.Client(), cfg, "", "")
+	if err != nil {
+		t.Fatalf("browse spaces: %v", err)
+	}
+	if len(spaces) != 2 || spaces[0].ID != "ENG" || !spaces[0].IsSpace {
+		t.Fatalf("expected spaces sorted by name, got %+v", spaces)
+	}
+
+	roots, err := confluence.Browse(context.Background(), srv.Client(), cfg, "ENG", "")
+	if err != nil {
+		t.Fatalf("browse space: %v", err)
+	}
+	if len(roots) != 1 || roots[0].ID != "100" || roots[0].IsSpace {
+		t.Fatalf("unexpected root pages: %+v", roots)
+	}
+
+	children, err := confluence.Browse(context.Background(), srv.Client(), cfg, "ENG", "100")
+	if err != nil {
+		t.Fatalf("browse page: %v", err)
+	}
+	if len(children) != 1 || children[0].ID != "200" || children[0].Title != "Runbooks" {
+		t.Fatalf("unexpected child pages: %+v", children)
+	}
+}
+
+func TestNetworkPolicy(t *testing.T) {
+	srv := fakeConfluence(t)
+	cfg := testConfig(srv)
+
+	if _, err := confluence.NewSourceProvider(domain.SourceNetworkPolicy{}).
+		ListFiles(context.Background(), "user-1", testSource(t, cfg)); !errors.Is(err, domain.ErrAddressNotAllowed) {
+		t.Fatalf("expected loopback wiki to be refused, got %v", err)
+	}
+	guarded := confluence.NewHTTPClient(domain.SourceNetworkPolicy{})
+	if _, err := confluence.Browse(context.Background(), guarded, cfg, "", ""); !errors.Is(err, domain.ErrAddressNotAllowed) {
+		t.Fatalf("expected loopback browse to be refused, got %v", err)
+	}
+
+	allowed := domain.SourceNetworkPolicy{AllowedHosts: []string{"127.0.0.1"}}
+	if _, err := confluence.NewSourceProvider(allowed).ListFiles(context.Background(), "user-1", testSource(t, cfg)); err != nil {
+		t.Fatalf("expected allowlisted wiki to be reached: %v", err)
+	}
+}
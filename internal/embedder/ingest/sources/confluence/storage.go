@@ -0,0 +1,128 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package confluence
+
+import (
+	"html"
+	"strings"
+
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	xhtml "golang.org/x/net/html"
+)
+
+// skippedStorageElements hold macro parameters and decorations, not content.
+var skippedStorageElements = map[string]struct{}{
+	"ac:parameter": {}, "ac:emoticon": {}, "ac:image": {}, "ac:placeholder": {},
+	"ac:task-id": {}, "ac:task-status": {}, "ac:inline-comment-marker-ref": {},
+}
+
+// storageBlocks map storage format elements to the HTML block they render as.
+var storageBlocks = map[string]string{
+	"ac:structured-macro": "div",
+	"ac:rich-text-body":   "div",
+	"ac:plain-text-body":  "pre",
+	"ac:layout-section":   "div",
+	"ac:layout-cell":      "div",
+	"ac:task-list":        "ul",
+	"ac:task":             "li",
+}
+
+// StorageToText converts Confluence storage format, the XHTML dialect pages
+// are stored in, to plain text. Macro bodies such as code blocks, panels and
+// task lists are kept; macro parameters and images are dropped, and links
+// without a label fall back to the title of the page or file they point to.
+func StorageToText(storage string) string {
+	var (
+		out  strings.Builder
+		skip int
+		// links tracks open <ac:link> elements: the target's title and
+		// whether the link had a label of its own.
+		links []storageLink
+	)
+
+	z := xhtml.NewTokenizer(strings.NewReader(storage))
+	z.AllowCDATA(true)
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			break
+		}
+		tok := z.Token()
+		name := tok.Data
+
+		if tt == xhtml.StartTagToken || tt == xhtml.EndTagToken || tt == xhtml.SelfClosingTagToken {
+			if _, ok := skippedStorageElements[name]; ok {
+				switch tt {
+				case xhtml.StartTagToken:
+					skip++
+				case xhtml.EndTagToken:
+					if skip > 0 {
+						skip--
+					}
+				}
+				continue
+			}
+			if skip > 0 {
+				continue
+			}
+
+			switch {
+			case name == "ac:link":
+				if tt == xhtml.StartTagToken {
+					links = append(links, storageLink{})
+				} else if tt == xhtml.EndTagToken && len(links) > 0 {
+					link := links[len(links)-1]
+					links = links[:len(links)-1]
+					if !link.labelled && link.title != "" {
+						out.WriteString(html.EscapeString(link.title))
+					}
+				}
+			case strings.HasPrefix(name, "ri:"):
+				if len(links) > 0 {
+					links[len(links)-1].title = resourceTitle(tok)
+				}
+			case strings.HasPrefix(name, "ac:"):
+				if block, ok := storageBlocks[name]; ok {
+					if tt == xhtml.EndTagToken {
+						out.WriteString("</" + block + ">")
+					} else if tt == xhtml.StartTagToken {
+						out.WriteString("<" + block + ">")
+					}
+				}
+			default:
+				out.WriteString(tok.String())
+			}
+			continue
+		}
+
+		if skip > 0 {
+			continue
+		}
+		if tt == xhtml.TextToken {
+			if len(links) > 0 && strings.TrimSpace(tok.Data) != "" {
+				links[len(links)-1].labelled = true
+			}
+			out.WriteString(html.EscapeString(tok.Data))
+		}
+	}
+
+	_, text := ingest.HTMLToText([]byte("<html><body>" + out.String() + "</body></html>"))
+	return text
+}
+
+type storageLink struct {
+	title    string
+	labelled bool
+}
+
+func resourceTitle(tok xhtml.Token) string {
+	for _, key := range []string{"ri:content-title", "ri:filename", "ri:space-key", "ri:value"} {
+		for _, a := range tok.Attr {
+			if a.Key == key && a.Val != "" {
+				return a.Val
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,49 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package confluence_test
+
+import (
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/confluence"
+)
+
+func TestStorageToText(t *testing.T) {
+	cases := map[string]struct {
+		storage string
+		want    string
+	}{
+		"paragraphs and lists": {
+			storage: `<h2>Setup</h2><p>Run the <strong>installer</strong> &amp; reboot.</p><ul><li>one</li><li>two</li></ul>`,
+			want:    "Setup\n\nRun the installer & reboot.\n\n- one\n- two",
+		},
+		"code macro keeps body and drops parameters": {
+			storage: `<ac:structured-macro ac:name="code"><ac:parameter ac:name="language">go</ac:parameter>` +
+				"<ac:plain-text-body><![CDATA[if a < b {\n\treturn\n}]]></ac:plain-text-body></ac:structured-macro>",
+			want: "if a < b {\n\treturn\n}",
+		},
+		"links fall back to the target title": {
+			storage: `<p>See <ac:link><ri:page ri:content-title="Runbook" /></ac:link> and ` +
+				`<ac:link><ri:attachment ri:filename="spec.pdf" /><ac:plain-text-link-body><![CDATA[the spec]]></ac:plain-text-link-body></ac:link>.</p>`,
+			want: "See Runbook and the spec.",
+		},
+		"task lists and panels": {
+			storage: `<ac:task-list><ac:task><ac:task-id>1</ac:task-id><ac:task-status>complete</ac:task-status>` +
+				`<ac:task-body>Ship it</ac:task-body></ac:task></ac:task-list>` +
+				`<ac:structured-macro ac:name="info"><ac:rich-text-body><p>Heads up</p></ac:rich-text-body></ac:structured-macro>`,
+			want: "- Ship it\n\nHeads up",
+		},
+		"images and emoticons are dropped": {
+			storage: `<p>Done <ac:emoticon ac:name="tick" /></p><ac:image><ri:attachment ri:filename="diagram.png" /></ac:image>`,
+			want:    "Done",
+		},
+	}
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			if got := confluence.StorageToText(tc.storage); got != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, got)
+			}
+		})
+	}
+}
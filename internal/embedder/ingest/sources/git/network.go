@@ -0,0 +1,42 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package git
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+)
+
+// CheckRemote returns an error when guard refuses the host a remote URL
+// connects to. Only network transports are accepted.
+func CheckRemote(ctx context.Context, guard *ingest.NetworkGuard, remote string) error {
+	host, err := remoteHost(remote)
+	if err != nil {
+		return err
+	}
+	return guard.CheckHost(ctx, host)
+}
+
+// remoteHost returns the host of an https://, http://, ssh:// or scp-like
+// user@host:path remote.
+func remoteHost(remote string) (string, error) {
+	remote = strings.TrimSpace(remote)
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		switch u.Scheme {
+		case "https", "http", "ssh":
+		default:
+			return "", fmt.Errorf("%w: %s remotes are not allowed", domain.ErrAddressNotAllowed, u.Scheme)
+		}
+		return u.Hostname(), nil
+	}
+	if m := scpLikeURL.FindStringSubmatch(remote); m != nil {
+		return m[1], nil
+	}
+	return "", fmt.Errorf("%w: unsupported git remote", domain.ErrAddressNotAllowed)
+}
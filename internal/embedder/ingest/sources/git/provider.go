@@ -0,0 +1,547 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+// Package git indexes the files of a git repository. Repositories are fetched
+// with the git CLI into a bare cache repository per source; every sync fetches
+// only the tip of the configured branch.
+package git
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+)
+
+const (
+	// headRef is where the fetched tip of the configured branch is stored.
+	headRef = "refs/cube/head"
+
+	defaultMaxFileSize = 1 << 20
+	defaultUsername    = "x-access-token"
+)
+
+// DefaultProtocols are the transports git may use to reach a repository.
+var DefaultProtocols = []string{"https", "http", "ssh"}
+
+var scpLikeURL = regexp.MustCompile(`^(?:[^@/]+@)?([^:/]+):(.+)$`)
+
+type sourceProvider struct {
+	cacheDir  string
+	protocols string
+	guard     *ingest.NetworkGuard
+	locks     sync.Map
+}
+
+// NewSourceProvider creates the git provider. Repositories are cached under
+// cacheDir, and remotes must be hosts policy allows.
+func NewSourceProvider(cacheDir string, policy domain.SourceNetworkPolicy) ingest.SourceProvider {
+	return &sourceProvider{
+		cacheDir:  cacheDir,
+		protocols: strings.Join(DefaultProtocols, ":"),
+		guard:     ingest.NewNetworkGuard(policy),
+	}
+}
+
+// NewSourceProviderWithProtocols creates the git provider restricted to the
+// given transports, e.g. adding "file" for local repositories. Remote hosts
+// are not checked against a network policy.
+func NewSourceProviderWithProtocols(cacheDir string, protocols []string) ingest.SourceProvider {
+	return &sourceProvider{
+		cacheDir:  cacheDir,
+		protocols: strings.Join(protocols, ":"),
+	}
+}
+
+func (p *sourceProvider) Type() domain.SourceType {
+	return domain.SourceTypeGit
+}
+
+func (p *sourceProvider) Capabilities() ingest.SourceProviderCapabilities {
+	return ingest.SourceProviderCapabilities{
+		SupportsList:     true,
+		SupportsDownload: true,
+		SupportsBrowse:   false,
+	}
+}
+
+func (p *sourceProvider) PrunesStaleRecords() bool {
+	return true
+}
+
+func (p *sourceProvider) ListFiles(
+	ctx context.Context,
+	_ string,
+	src domain.Source,
+) ([]ingest.SourceFile, error) {
+	cfg, err := decodeConfig(src)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := p.repoDir(src)
+	if err != nil {
+		return nil, err
+	}
+	if err := p.fetch(ctx, repo, src.ID, cfg); err != nil {
+		return nil, err
+	}
+
+	commit, err := p.git(ctx, repo, nil, "rev-parse", "--verify", headRef+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("resolve fetched commit: %w", err)
+	}
+	commitSHA := strings.TrimSpace(string(commit))
+
+	tree, err := p.git(ctx, repo, nil, "ls-tree", "-r", "-l", "-z", commitSHA)
+	if err != nil {
+		return nil, fmt.Errorf("list repository tree: %w", err)
+	}
+
+	include, err := compileGlobs(cfg.Include)
+	if err != nil {
+		return nil, err
+	}
+	exclude, err := compileGlobs(cfg.Exclude)
+	if err != nil {
+		return nil, err
+	}
+	maxSize := cfg.MaxFileSize
+	if maxSize <= 0 {
+		maxSize = defaultMaxFileSize
+	}
+	web, forge := webBase(cfg)
+
+	var files []ingest.SourceFile
+	for _, entry := range bytes.Split(tree, []byte{0}) {
+		e, ok := parseTreeEntry(string(entry))
+		// Symlinks and submodules have no content of their own to index.
+		if !ok || e.kind != "blob" || e.mode == "120000" || e.size > maxSize {
+			continue
+		}
+		if len(include) > 0 && !matchAny(include, e.path) {
+			continue
+		}
+		if matchAny(exclude, e.path) {
+			continue
+		}
+
+		name := path.Base(e.path)
+		file := ingest.SourceFile{
+			ExternalID:    e.path,
+			Name:          name,
+			ExternalURL:   blobURL(web, forge, commitSHA, e.path),
+			ExternalRef:   commitSHA,
+			MimeType:      ingest.NormalizeFileMIMEType(name, ""),
+			SourceVersion: e.object,
+		}
+		if dir := path.Dir(e.path); dir != "." {
+			file.FolderPath = "/" + dir
+			file.FolderID = dir
+		}
+		files = append(files, file)
+	}
+	return files, nil
+}
+
+func (p *sourceProvider) DownloadRecord(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) (string, *int, error) {
+	body, err := p.DownloadRecordContent(ctx, rec, src)
+	if err != nil {
+		return "", nil, err
+	}
+
+	doc, err := ingest.ExtractText(ingest.FileMeta{
+		ID:       rec.ExternalID,
+		Name:     rec.Name,
+		MimeType: rec.MimeType,
+	}, body)
+	if err != nil {
+		return "", nil, err
+	}
+	return doc.Text, doc.PageCount, nil
+}
+
+// DownloadRecordContent reads the blob recorded at sync time. When the cache
+// no longer has it (e.g. it was wiped), the branch is fetched again and the
+// current version of the file is returned instead.
+func (p *sourceProvider) DownloadRecordContent(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) ([]byte, error) {
+	if rec.ExternalID == "" {
+		return nil, fmt.Errorf("record %s is missing external_id", rec.ID)
+	}
+	cfg, err := decodeConfig(src)
+	if err != nil {
+		return nil, err
+	}
+	repo, err := p.repoDir(src)
+	if err != nil {
+		return nil, err
+	}
+
+	if blob := rec.SourceVersion; blob != "" {
+		if body, err := p.git(ctx, repo, nil, "cat-file", "blob", blob); err == nil {
+			return body, nil
+		}
+		if err := p.fetch(ctx, repo, src.ID, cfg); err != nil {
+			return nil, err
+		}
+		if body, err := p.git(ctx, repo, nil, "cat-file", "blob", blob); err == nil {
+			return body, nil
+		}
+	} else if err := p.fetch(ctx, repo, src.ID, cfg); err != nil {
+		return nil, err
+	}
+
+	body, err := p.git(ctx, repo, nil, "cat-file", "blob", headRef+":"+rec.ExternalID)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", rec.ExternalID, err)
+	}
+	return body, nil
+}
+
+func decodeConfig(src domain.Source) (domain.GitConfig, error) {
+	var cfg domain.GitConfig
+	if len(src.Config) > 0 {
+		if err := json.Unmarshal(src.Config, &cfg); err != nil {
+			return domain.GitConfig{}, fmt.Errorf("decode git config: %w", err)
+		}
+	}
+	if strings.TrimSpace(cfg.RepoURL) == "" {
+		return domain.GitConfig{}, fmt.Errorf("git source %s is missing repo_url", src.ID)
+	}
+	return cfg, nil
+}
+
+func (p *sourceProvider) repoDir(src domain.Source) (string, error) {
+	if p.cacheDir == "" {
+		return "", fmt.Errorf("git cache directory is not configured")
+	}
+	if src.ID == "" || filepath.Base(src.ID) != src.ID || strings.HasPrefix(src.ID, ".") {
+		return "", fmt.Errorf("invalid git source id %q", src.ID)
+	}
+	return filepath.Join(p.cacheDir, src.ID), nil
+}
+
+func (p *sourceProvider) lock(sourceID string) *sync.Mutex {
+	mu, _ := p.locks.LoadOrStore(sourceID, &sync.Mutex{})
+	return mu.(*sync.Mutex)
+}
+
+// fetch updates headRef to the tip of the configured branch, initialising
+// the cache repository on first use. Fetches are shallow, so only the
+// current tree is transferred.
+func (p *sourceProvider) fetch(ctx context.Context, repo, sourceID string, cfg domain.GitConfig) error {
+	mu := p.lock(sourceID)
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, err := os.Stat(filepath.Join(repo, "HEAD")); errors.Is(err, os.ErrNotExist) {
+		if err := os.MkdirAll(repo, 0o700); err != nil {
+			return fmt.Errorf("create git cache: %w", err)
+		}
+		if _, err := p.git(ctx, "", nil, "init", "--bare", "--quiet", repo); err != nil {
+			return fmt.Errorf("init git cache: %w", err)
+		}
+	} else if err != nil {
+		return fmt.Errorf("stat git cache: %w", err)
+	}
+
+	env, cleanup, err := p.authEnv(repo, cfg)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+
+	// The git CLI dials on its own, so the remote is checked before every
+	// fetch; git() refuses HTTP redirects, which would skip the check.
+	if p.guard != nil {
+		if err := CheckRemote(ctx, p.guard, cfg.RepoURL); err != nil {
+			return fmt.Errorf("fetch %s: %w", redactURL(cfg.RepoURL), err)
+		}
+	}
+
+	ref := cfg.Branch
+	if ref == "" {
+		ref = "HEAD"
+	}
+	if _, err := p.git(ctx, repo, env,
+		"fetch", "--quiet", "--depth=1", "--force", "--no-tags", "--",
+		cfg.RepoURL, "+"+ref+":"+headRef,
+	); err != nil {
+		return fmt.Errorf("fetch %s: %w", redactURL(cfg.RepoURL), err)
+	}
+	return nil
+}
+
+// authEnv passes credentials to git through the environment so they never
+// appear in process arguments. HTTPS tokens are sent as a Basic auth header;
+// SSH keys are written to a private temporary file for the duration of the
+// fetch.
+func (p *sourceProvider) authEnv(repo string, cfg domain.GitConfig) ([]string, func(), error) {
+	var env []string
+	cleanup := func() {}
+
+	if cfg.Token != "" {
+		username := cfg.Username
+		if username == "" {
+			username = defaultUsername
+		}
+		basic := base64.StdEncoding.EncodeToString([]byte(username + ":" + cfg.Token))
+		env = append(env,
+			"GIT_CONFIG_COUNT=1",
+			"GIT_CONFIG_KEY_0=http.extraHeader",
+			"GIT_CONFIG_VALUE_0=Authorization: Basic "+basic,
+		)
+	}
+
+	if cfg.SSHPrivateKey != "" {
+		dir, err := os.MkdirTemp("", "cube-git-ssh-")
+		if err != nil {
+			return nil, cleanup, fmt.Errorf("create ssh key directory: %w", err)
+		}
+		cleanup = func() { _ = os.RemoveAll(dir) }
+
+		keyFile := filepath.Join(dir, "id")
+		if err := os.WriteFile(keyFile, []byte(cfg.SSHPrivateKey), 0o600); err != nil {
+			cleanup()
+			return nil, func() {}, fmt.Errorf("write ssh key: %w", err)
+		}
+
+		// Without pinned host keys the first key seen is trusted and
+		// remembered next to the cache, so later changes are still caught.
+		knownHosts := repo + ".known_hosts"
+		strict := "accept-new"
+		if cfg.SSHKnownHosts != "" {
+			knownHosts = filepath.Join(dir, "known_hosts")
+			if err := os.WriteFile(knownHosts, []byte(cfg.SSHKnownHosts+"\n"), 0o600); err != nil {
+				cleanup()
+				return nil, func() {}, fmt.Errorf("write ssh known hosts: %w", err)
+			}
+			strict = "yes"
+		}
+		env = append(env, "GIT_SSH_COMMAND=ssh -F /dev/null -o BatchMode=yes -o IdentitiesOnly=yes"+
+			" -o StrictHostKeyChecking="+strict+
+			" -o UserKnownHostsFile="+shellQuote(knownHosts)+
+			" -i "+shellQuote(keyFile))
+	}
+	return env, cleanup, nil
+}
+
+func (p *sourceProvider) git(ctx context.Context, repo string, extraEnv []string, args ...string) ([]byte, error) {
+	subcommand := args[0]
+	if repo != "" {
+		args = append([]string{"-C", repo}, args...)
+	}
+	args = append([]string{"-c", "credential.helper=", "-c", "core.askPass="}, args...)
+	if p.guard != nil {
+		args = append([]string{"-c", "http.followRedirects=false"}, args...)
+	}
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(),
+		"GIT_TERMINAL_PROMPT=0",
+		"GIT_ALLOW_PROTOCOL="+p.protocols,
+		"LC_ALL=C",
+	)
+	cmd.Env = append(cmd.Env, extraEnv...)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", subcommand, msg)
+		}
+		return nil, fmt.Errorf("git %s: %w", subcommand, err)
+	}
+	return stdout.Bytes(), nil
+}
+
+type treeEntry struct {
+	mode   string
+	kind   string
+	object string
+	size   int64
+	path   string
+}
+
+// parseTreeEntry parses one `git ls-tree -l -z` entry:
+// "<mode> <type> <object> <size>\t<path>".
+func parseTreeEntry(line string) (treeEntry, bool) {
+	meta, p, ok := strings.Cut(line, "\t")
+	if !ok {
+		return treeEntry{}, false
+	}
+	fields := strings.Fields(meta)
+	if len(fields) != 4 {
+		return treeEntry{}, false
+	}
+	size, _ := strconv.ParseInt(fields[3], 10, 64)
+	return treeEntry{mode: fields[0], kind: fields[1], object: fields[2], size: size, path: p}, true
+}
+
+// compileGlobs turns gitignore-like patterns into regular expressions. "*"
+// and "?" do not cross directories, "**" does, and a pattern without a slash
+// matches the file name at any depth.
+func compileGlobs(patterns []string) ([]*regexp.Regexp, error) {
+	globs := make([]*regexp.Regexp, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimPrefix(strings.TrimSpace(pattern), "/")
+		if pattern == "" {
+			continue
+		}
+		var expr strings.Builder
+		expr.WriteString("^")
+		if !strings.Contains(strings.TrimSuffix(pattern, "/"), "/") {
+			expr.WriteString("(.*/)?")
+		}
+		for i := 0; i < len(pattern); i++ {
+			switch c := pattern[i]; {
+			case strings.HasPrefix(pattern[i:], "**/"):
+				expr.WriteString("(.*/)?")
+				i += 2
+			case strings.HasPrefix(pattern[i:], "**"):
+				expr.WriteString(".*")
+				i++
+			case c == '*':
+				expr.WriteString("[^/]*")
+			case c == '?':
+				expr.WriteString("[^/]")
+			default:
+				expr.WriteString(regexp.QuoteMeta(string(c)))
+			}
+		}
+		// A directory pattern matches everything below it.
+		if strings.HasSuffix(pattern, "/") {
+			expr.WriteString(".*")
+		} else {
+			expr.WriteString("(/.*)?")
+		}
+		expr.WriteString("$")
+
+		re, err := regexp.Compile(expr.String())
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob %q: %w", pattern, err)
+		}
+		globs = append(globs, re)
+	}
+	return globs, nil
+}
+
+func matchAny(globs []*regexp.Regexp, p string) bool {
+	for _, glob := range globs {
+		if glob.MatchString(p) {
+			return true
+		}
+	}
+	return false
+}
+
+// webBase returns the browsable repository URL and the forge hosting it.
+// Unless configured explicitly both are derived from the clone URL.
+func webBase(cfg domain.GitConfig) (string, string) {
+	base := strings.TrimSuffix(cfg.WebURL, "/")
+	host := ""
+	if base == "" {
+		base, host = webURLFromRemote(cfg.RepoURL)
+	} else if u, err := url.Parse(base); err == nil {
+		host = u.Hostname()
+	}
+	if base == "" {
+		return "", ""
+	}
+
+	forge := cfg.Forge
+	if forge == "" {
+		switch host = strings.ToLower(host); {
+		case strings.Contains(host, "gitlab"):
+			forge = "gitlab"
+		case strings.Contains(host, "bitbucket"):
+			forge = "bitbucket"
+		default:
+			forge = "github"
+		}
+	}
+	return base, forge
+}
+
+func webURLFromRemote(remote string) (string, string) {
+	remote = strings.TrimSpace(remote)
+	var host, repoPath string
+	if u, err := url.Parse(remote); err == nil && u.Host != "" {
+		switch u.Scheme {
+		case "https", "http", "ssh":
+		default:
+			return "", ""
+		}
+		host, repoPath = u.Host, u.Path
+		if u.Scheme == "ssh" {
+			host = u.Hostname()
+		}
+	} else if m := scpLikeURL.FindStringSubmatch(remote); m != nil {
+		host, repoPath = m[1], m[2]
+	} else {
+		return "", ""
+	}
+	repoPath = strings.TrimSuffix(strings.Trim(repoPath, "/"), ".git")
+	if host == "" || repoPath == "" {
+		return "", ""
+	}
+	return "https://" + host + "/" + repoPath, host
+}
+
+func blobURL(base, forge, commit, filePath string) string {
+	if base == "" {
+		return ""
+	}
+	segments := strings.Split(filePath, "/")
+	for i, segment := range segments {
+		segments[i] = url.PathEscape(segment)
+	}
+	escaped := strings.Join(segments, "/")
+
+	switch forge {
+	case "gitlab":
+		return base + "/-/blob/" + commit + "/" + escaped
+	case "bitbucket":
+		return base + "/src/" + commit + "/" + escaped
+	case "gitea":
+		return base + "/src/commit/" + commit + "/" + escaped
+	default:
+		return base + "/blob/" + commit + "/" + escaped
+	}
+}
+
+// redactURL drops credentials embedded in a remote URL before it is logged.
+func redactURL(remote string) string {
+	u, err := url.Parse(remote)
+	if err != nil || u.User == nil {
+		return remote
+	}
+	u.User = url.User(u.User.Username())
+	return u.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
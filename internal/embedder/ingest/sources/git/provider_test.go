@@ -0,0 +1,258 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package git_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	gitsource "github.com/ultravioletrs/cube/internal/embedder/ingest/sources/git"
+)
+
+type testRepo struct {
+	t        *testing.T
+	bare     string
+	worktree string
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	t.Helper()
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	r := &testRepo{t: t, bare: filepath.Join(dir, "origin.git"), worktree: filepath.Join(dir, "work")}
+	r.run("", "init", "--bare", "--quiet", "--initial-branch=main", r.bare)
+	r.run("", "init", "--quiet", "--initial-branch=main", r.worktree)
+	return r
+}
+
+func (r *testRepo) run(dir string, args ...string) string {
+	r.t.Helper()
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	cmd := exec.Command("git", args...)
+	cmd.Env = append(os.Environ(),
+		"GIT_AUTHOR_NAME=test", "GIT_AUTHOR_EMAIL=test@example.com",
+		"GIT_COMMITTER_NAME=test", "GIT_COMMITTER_EMAIL=test@example.com",
+		"GIT_CONFIG_GLOBAL=/dev/null",
+	)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		r.t.Fatalf("git %s: %v\n%s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func (r *testRepo) commit(files map[string]string) string {
+	r.t.Helper()
+	for name, content := range files {
+		path := filepath.Join(r.worktree, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			r.t.Fatalf("mkdir: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			r.t.Fatalf("write %s: %v", name, err)
+		}
+	}
+	r.run(r.worktree, "add", "-A")
+	r.run(r.worktree, "commit", "--quiet", "-m", "update")
+	r.run(r.worktree, "push", "--quiet", r.bare, "main")
+	return r.run(r.worktree, "rev-parse", "HEAD")
+}
+
+func newProvider(t *testing.T) ingest.SourceProvider {
+	t.Helper()
+	return gitsource.NewSourceProviderWithProtocols(t.TempDir(), append(gitsource.DefaultProtocols, "file"))
+}
+
+func testSource(t *testing.T, cfg domain.GitConfig) domain.Source {
+	t.Helper()
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("marshal config: %v", err)
+	}
+	return domain.Source{ID: "src-1", Type: domain.SourceTypeGit, Config: raw}
+}
+
+func filesByID(files []ingest.SourceFile) map[string]ingest.SourceFile {
+	byID := make(map[string]ingest.SourceFile, len(files))
+	for _, f := range files {
+		byID[f.ExternalID] = f
+	}
+	return byID
+}
+
+func TestListFilesTracksBlobVersions(t *testing.T) {
+	repo := newTestRepo(t)
+	first := repo.commit(map[string]string{
+		"README.md":            "# Project\n",
+		"docs/guide.md":        "Install the thing.\n",
+		"docs/api/ref.txt":     "GET /things\n",
+		"vendor/lib/lib.md":    "vendored\n",
+		"assets/big.txt":       strings.Repeat("x", 2048),
+		"docs/notes.generated": "skip me\n",
+	})
+
+	provider := newProvider(t)
+	src := testSource(t, domain.GitConfig{
+		RepoURL:     repo.bare,
+		Branch:      "main",
+		Include:     []string{"*.md", "docs/**"},
+		Exclude:     []string{"vendor/", "*.generated"},
+		MaxFileSize: 1024,
+	})
+
+	files, err := provider.ListFiles(context.Background(), "user-1", src)
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	byID := filesByID(files)
+	for _, want := range []string{"README.md", "docs/guide.md", "docs/api/ref.txt"} {
+		f, ok := byID[want]
+		if !ok {
+			t.Fatalf("expected %s to be listed, got %v", want, byID)
+		}
+		if f.ExternalRef != first {
+			t.Fatalf("expected %s ref %s, got %s", want, first, f.ExternalRef)
+		}
+	}
+	if len(byID) != 3 {
+		t.Fatalf("expected excluded, vendored and oversized files to be skipped, got %v", byID)
+	}
+	if got := byID["docs/api/ref.txt"]; got.FolderPath != "/docs/api" || got.Name != "ref.txt" || got.MimeType != "text/plain" {
+		t.Fatalf("unexpected file metadata: %+v", got)
+	}
+
+	second := repo.commit(map[string]string{"docs/guide.md": "Install the thing, then configure it.\n"})
+	files, err = provider.ListFiles(context.Background(), "user-1", src)
+	if err != nil {
+		t.Fatalf("list files after update: %v", err)
+	}
+	updated := filesByID(files)
+	if updated["docs/guide.md"].SourceVersion == byID["docs/guide.md"].SourceVersion {
+		t.Fatal("expected the modified file to get a new blob version")
+	}
+	for _, unchanged := range []string{"README.md", "docs/api/ref.txt"} {
+		if updated[unchanged].SourceVersion != byID[unchanged].SourceVersion {
+			t.Fatalf("expected %s to keep its blob version", unchanged)
+		}
+		if updated[unchanged].ExternalRef != second {
+			t.Fatalf("expected %s ref to move to %s", unchanged, second)
+		}
+	}
+
+	text, _, err := provider.DownloadRecord(context.Background(), domain.Record{
+		ExternalID:    "docs/guide.md",
+		Name:          "guide.md",
+		MimeType:      "text/markdown",
+		SourceVersion: byID["docs/guide.md"].SourceVersion,
+	}, src)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if text != "Install the thing.\n" {
+		t.Fatalf("expected the recorded blob content, got %q", text)
+	}
+}
+
+func TestDownloadRecordRefetchesMissingCache(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commit(map[string]string{"README.md": "hello\n"})
+	src := testSource(t, domain.GitConfig{RepoURL: repo.bare})
+
+	files, err := newProvider(t).ListFiles(context.Background(), "user-1", src)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("list files: %v %v", files, err)
+	}
+
+	// A fresh provider has an empty cache, as after a restart.
+	text, _, err := newProvider(t).DownloadRecord(context.Background(), domain.Record{
+		ExternalID:    "README.md",
+		Name:          "README.md",
+		SourceVersion: files[0].SourceVersion,
+	}, src)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if text != "hello\n" {
+		t.Fatalf("unexpected content %q", text)
+	}
+}
+
+func TestListFilesBuildsForgeLinks(t *testing.T) {
+	repo := newTestRepo(t)
+	commit := repo.commit(map[string]string{"docs/My Guide.md": "text\n"})
+
+	cases := []struct {
+		name string
+		cfg  domain.GitConfig
+		want string
+	}{
+		{
+			name: "github",
+			cfg:  domain.GitConfig{WebURL: "https://github.com/acme/handbook"},
+			want: "https://github.com/acme/handbook/blob/" + commit + "/docs/My%20Guide.md",
+		},
+		{
+			name: "gitlab",
+			cfg:  domain.GitConfig{WebURL: "https://gitlab.example.com/acme/handbook"},
+			want: "https://gitlab.example.com/acme/handbook/-/blob/" + commit + "/docs/My%20Guide.md",
+		},
+		{
+			name: "bitbucket",
+			cfg:  domain.GitConfig{WebURL: "https://code.example.com/acme/handbook", Forge: "bitbucket"},
+			want: "https://code.example.com/acme/handbook/src/" + commit + "/docs/My%20Guide.md",
+		},
+		{
+			name: "local",
+			want: "",
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.cfg.RepoURL = repo.bare
+			files, err := newProvider(t).ListFiles(context.Background(), "user-1", testSource(t, tc.cfg))
+			if err != nil || len(files) != 1 {
+				t.Fatalf("list files: %v %v", files, err)
+			}
+			if files[0].ExternalURL != tc.want {
+				t.Fatalf("expected %q, got %q", tc.want, files[0].ExternalURL)
+			}
+		})
+	}
+}
+
+func TestDefaultProtocolsRejectLocalRepositories(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.commit(map[string]string{"README.md": "hello\n"})
+
+	provider := gitsource.NewSourceProvider(t.TempDir(), domain.SourceNetworkPolicy{})
+	if _, err := provider.ListFiles(context.Background(), "user-1", testSource(t, domain.GitConfig{RepoURL: repo.bare})); err == nil {
+		t.Fatal("expected local repositories to be refused")
+	}
+}
+
+func TestListFilesRefusesInternalRemotes(t *testing.T) {
+	provider := gitsource.NewSourceProvider(t.TempDir(), domain.SourceNetworkPolicy{})
+	for _, remote := range []string{
+		"http://169.254.169.254/latest/meta-data.git",
+		"https://127.0.0.1/org/repo.git",
+		"git@10.0.0.5:org/repo.git",
+	} {
+		_, err := provider.ListFiles(context.Background(), "user-1", testSource(t, domain.GitConfig{RepoURL: remote}))
+		if !errors.Is(err, domain.ErrAddressNotAllowed) {
+			t.Fatalf("%s: expected ErrAddressNotAllowed, got %v", remote, err)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package google
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+)
+
+const (
+	// Drive keeps changes channels for at most a week.
+	driveChannelTTL = 6 * 24 * time.Hour
+	// maxDriveChanges bounds a targeted sync; larger bursts fall back to a
+	// full sync.
+	maxDriveChanges = 1000
+	// maxDriveFolderDepth bounds the parent walk that places a changed file
+	// under a selected folder.
+	maxDriveFolderDepth = 64
+)
+
+var _ ingest.ChangeNotifier = (*sourceProvider)(nil)
+
+// Subscribe opens a changes.watch channel for the Drive behind src.
+func (p *sourceProvider) Subscribe(
+	ctx context.Context,
+	src domain.Source,
+	sub domain.SourceSubscription,
+	callbackURL string,
+) (domain.SourceSubscription, error) {
+	reader, _, err := driveReader(ctx, src)
+	if err != nil {
+		return sub, err
+	}
+	cursor, err := reader.StartPageToken(ctx)
+	if err != nil {
+		return sub, err
+	}
+	sub.Cursor = cursor
+	return p.watch(ctx, reader, sub, callbackURL)
+}
+
+// Renew replaces the channel: Drive channels cannot be extended, so a new
+// one is opened and the old one stopped.
+func (p *sourceProvider) Renew(
+	ctx context.Context,
+	src domain.Source,
+	sub domain.SourceSubscription,
+	callbackURL string,
+) (domain.SourceSubscription, error) {
+	reader, _, err := driveReader(ctx, src)
+	if err != nil {
+		return sub, err
+	}
+	renewed, err := p.watch(ctx, reader, sub, callbackURL)
+	if err != nil {
+		return sub, err
+	}
+	if err := reader.StopChannel(ctx, sub.ExternalID, sub.Resource); err != nil {
+		return renewed, err
+	}
+	return renewed, nil
+}
+
+func (p *sourceProvider) watch(
+	ctx context.Context,
+	reader *ingest.DriveReader,
+	sub domain.SourceSubscription,
+	callbackURL string,
+) (domain.SourceSubscription, error) {
+	pageToken := sub.Cursor
+	if pageToken == "" {
+		token, err := reader.StartPageToken(ctx)
+		if err != nil {
+			return sub, err
+		}
+		pageToken = token
+	}
+	// Channel IDs must be unique, so renewals get a new suffix.
+	ch, err := reader.WatchChanges(ctx, pageToken, ingest.DriveChannel{
+		ID:         sub.ID + "-" + strconv.FormatInt(time.Now().Unix(), 36),
+		Address:    callbackURL,
+		Token:      sub.ClientState,
+		Expiration: time.Now().Add(driveChannelTTL),
+	})
+	if err != nil {
+		return sub, err
+	}
+	sub.ExternalID = ch.ID
+	sub.Resource = ch.ResourceID
+	if !ch.Expiration.IsZero() {
+		expires := ch.Expiration
+		sub.ExpiresAt = &expires
+	}
+	return sub, nil
+}
+
+// Unsubscribe stops the subscription's channel.
+func (p *sourceProvider) Unsubscribe(ctx context.Context, src domain.Source, sub domain.SourceSubscription) error {
+	if sub.ExternalID == "" {
+		return nil
+	}
+	reader, _, err := driveReader(ctx, src)
+	if err != nil {
+		return err
+	}
+	return reader.StopChannel(ctx, sub.ExternalID, sub.Resource)
+}
+
+// ParseNotification checks the channel token Drive sends with every
+// notification. The initial "sync" message carries no changes.
+func (p *sourceProvider) ParseNotification(
+	sub domain.SourceSubscription,
+	n domain.ChangeNotification,
+) (domain.SourceChangeHint, bool, error) {
+	token := n.Header.Get("X-Goog-Channel-Token")
+	if sub.ClientState == "" || subtle.ConstantTimeCompare([]byte(token), []byte(sub.ClientState)) != 1 {
+		return domain.SourceChangeHint{}, false, domain.ErrInvalidNotification
+	}
+	if strings.EqualFold(n.Header.Get("X-Goog-Resource-State"), "sync") {
+		return domain.SourceChangeHint{}, false, nil
+	}
+	return domain.SourceChangeHint{}, true, nil
+}
+
+// Changes lists the Drive changes since the subscription's page token and
+// keeps the files the source's folder or selection covers.
+func (p *sourceProvider) Changes(
+	ctx context.Context,
+	src domain.Source,
+	sub domain.SourceSubscription,
+	_ domain.SourceChangeHint,
+) (ingest.SourceChanges, error) {
+	reader, cfg, err := driveReader(ctx, src)
+	if err != nil {
+		return ingest.SourceChanges{}, err
+	}
+	if sub.Cursor == "" {
+		cursor, err := reader.StartPageToken(ctx)
+		if err != nil {
+			return ingest.SourceChanges{}, err
+		}
+		return ingest.SourceChanges{Cursor: cursor}, nil
+	}
+
+	changes, cursor, err := reader.ListChanges(ctx, sub.Cursor, maxDriveChanges)
+	if err != nil {
+		if errors.Is(err, ingest.ErrTooManyDriveChanges) || errors.Is(err, ingest.ErrDriveNotFound) {
+			return ingest.SourceChanges{}, fmt.Errorf("%w: %v", ingest.ErrFullSyncRequired, err)
+		}
+		return ingest.SourceChanges{}, err
+	}
+
+	scope := newDriveScope(reader, cfg)
+	out := ingest.SourceChanges{Cursor: cursor}
+	for _, change := range changes {
+		if change.FileID == "" {
+			continue
+		}
+		if change.Removed || change.File == nil || change.File.Trashed || ingest.IsDriveFolder(*change.File) {
+			out.Removed = append(out.Removed, change.FileID)
+			continue
+		}
+		file := *change.File
+		if !ingest.SupportsDriveFile(file) {
+			out.Removed = append(out.Removed, change.FileID)
+			continue
+		}
+		ok, err := scope.place(ctx, &file)
+		if err != nil {
+			return ingest.SourceChanges{}, err
+		}
+		if !ok {
+			out.Removed = append(out.Removed, change.FileID)
+			continue
+		}
+		out.Files = append(out.Files, driveSourceFile(file))
+	}
+	return out, nil
+}
+
+func driveReader(ctx context.Context, src domain.Source) (*ingest.DriveReader, domain.GoogleDriveConfig, error) {
+	var cfg domain.GoogleDriveConfig
+	if err := json.Unmarshal(src.Config, &cfg); err != nil {
+		return nil, cfg, fmt.Errorf("decode source config: %w", err)
+	}
+	reader, err := ingest.NewDriveReaderFromConfig(ctx, cfg)
+	if err != nil {
+		return nil, cfg, err
+	}
+	return reader, cfg, nil
+}
+
+// driveScope decides whether a changed file is covered by a source, and
+// fills in the folder fields a full sync would have given it.
+type driveScope struct {
+	reader          *ingest.DriveReader
+	folderID        string
+	selectedFiles   map[string]struct{}
+	selectedFolders map[string]struct{}
+	folders         map[string]ingest.DriveFile
+}
+
+func newDriveScope(reader *ingest.DriveReader, cfg domain.GoogleDriveConfig) *driveScope {
+	s := &driveScope{
+		reader:          reader,
+		folderID:        strings.TrimSpace(cfg.FolderID),
+		selectedFiles:   make(map[string]struct{}),
+		selectedFolders: make(map[string]struct{}),
+		folders:         make(map[string]ingest.DriveFile),
+	}
+	for _, id := range normalizeSelectionIDs(cfg.SelectedFileIDs) {
+		s.selectedFiles[id] = struct{}{}
+	}
+	for _, id := range normalizeSelectionIDs(cfg.SelectedFolderIDs) {
+		s.selectedFolders[id] = struct{}{}
+	}
+	return s
+}
+
+// place reports whether file is in scope, mirroring ListFiles: a selection
+// replaces the folder, selected folders include their subtrees, and a folder
+// includes its direct children only.
+func (s *driveScope) place(ctx context.Context, file *ingest.DriveFile) (bool, error) {
+	if len(s.selectedFiles) == 0 && len(s.selectedFolders) == 0 {
+		if s.folderID == "" {
+			return true, nil
+		}
+		for _, parent := range file.Parents {
+			if parent == s.folderID {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	for _, parent := range file.Parents {
+		folderPath, ok, err := s.pathUnderSelection(ctx, parent)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			file.FolderPath = folderPath
+			file.FolderID = parent
+			return true, nil
+		}
+	}
+	_, selected := s.selectedFiles[file.ID]
+	return selected, nil
+}
+
+// pathUnderSelection walks up from folderID to a selected folder and returns
+// the folder path ListFilesRecursive would have built, "/<selected>/<sub>".
+func (s *driveScope) pathUnderSelection(ctx context.Context, folderID string) (string, bool, error) {
+	var names []string
+	id := folderID
+	for depth := 0; depth < maxDriveFolderDepth && id != ""; depth++ {
+		folder, err := s.folder(ctx, id)
+		if err != nil {
+			if errors.Is(err, ingest.ErrDriveNotFound) {
+				return "", false, nil
+			}
+			return "", false, err
+		}
+		names = append(names, folder.Name)
+		if _, ok := s.selectedFolders[id]; ok {
+			for i, j := 0, len(names)-1; i < j; i, j = i+1, j-1 {
+				names[i], names[j] = names[j], names[i]
+			}
+			return "/" + strings.Join(names, "/"), true, nil
+		}
+		if len(folder.Parents) == 0 {
+			break
+		}
+		id = folder.Parents[0]
+	}
+	return "", false, nil
+}
+
+func (s *driveScope) folder(ctx context.Context, id string) (ingest.DriveFile, error) {
+	if folder, ok := s.folders[id]; ok {
+		return folder, nil
+	}
+	folder, err := s.reader.GetFolder(ctx, id)
+	if err != nil {
+		return ingest.DriveFile{}, err
+	}
+	if strings.TrimSpace(folder.Name) == "" {
+		folder.Name = id
+	}
+	s.folders[id] = folder
+	return folder, nil
+}
@@ -0,0 +1,138 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package google_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/google"
+)
+
+func TestGoogleChanges_PlacesFilesUnderSelectedFolders(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/drive/v3/changes":
+			if got := r.URL.Query().Get("pageToken"); got != "41" {
+				t.Errorf("unexpected page token: %q", got)
+			}
+			_, _ = io.WriteString(w, `{
+				"newStartPageToken":"42",
+				"changes":[
+					{"fileId":"file-a","file":{"id":"file-a","name":"a.txt","mimeType":"text/plain","version":"7","parents":["sub"]}},
+					{"fileId":"file-b","file":{"id":"file-b","name":"b.txt","mimeType":"text/plain","parents":["other"]}},
+					{"fileId":"file-c","removed":true},
+					{"fileId":"file-d","file":{"id":"file-d","name":"d.txt","mimeType":"text/plain","trashed":true,"parents":["sub"]}}
+				]
+			}`)
+		case r.URL.Path == "/drive/v3/files/sub":
+			_, _ = io.WriteString(w, `{"id":"sub","name":"Sub","parents":["sel"]}`)
+		case r.URL.Path == "/drive/v3/files/sel":
+			_, _ = io.WriteString(w, `{"id":"sel","name":"Selected","parents":["root"]}`)
+		case r.URL.Path == "/drive/v3/files/other":
+			_, _ = io.WriteString(w, `{"id":"other","name":"Other"}`)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.String())
+		}
+	}))
+	defer srv.Close()
+
+	restoreFiles := ingest.SetDriveAPIEndpoints(
+		srv.URL+"/drive/v3/files",
+		srv.URL+"/drive/v3/files/%s/export",
+		srv.URL+"/drive/v3/files/%s?alt=media",
+	)
+	defer restoreFiles()
+	restoreChanges := ingest.SetDriveChangesEndpoints(srv.URL+"/drive/v3/changes", srv.URL+"/drive/v3/channels")
+	defer restoreChanges()
+
+	cfgRaw, err := json.Marshal(domain.GoogleDriveConfig{
+		AccessToken:       "token",
+		SelectedFolderIDs: []string{"sel"},
+	})
+	if err != nil {
+		t.Fatalf("marshal config: %v", err)
+	}
+	provider := google.NewSourceProvider()
+	notifier, ok := provider.(ingest.ChangeNotifier)
+	if !ok {
+		t.Fatalf("google provider does not implement ChangeNotifier")
+	}
+
+	changes, err := notifier.Changes(
+		context.Background(),
+		domain.Source{ID: "src-1", Type: domain.SourceTypeGoogleDrive, Config: cfgRaw},
+		domain.SourceSubscription{ID: "sub-1", Cursor: "41"},
+		domain.SourceChangeHint{},
+	)
+	if err != nil {
+		t.Fatalf("Changes returned error: %v", err)
+	}
+	if changes.Cursor != "42" {
+		t.Fatalf("unexpected cursor: %q", changes.Cursor)
+	}
+	if len(changes.Files) != 1 {
+		t.Fatalf("expected 1 changed file, got %#v", changes.Files)
+	}
+	file := changes.Files[0]
+	if file.ExternalID != "file-a" || file.FolderPath != "/Selected/Sub" || file.FolderID != "sub" {
+		t.Fatalf("unexpected changed file: %#v", file)
+	}
+	if want := []string{"file-b", "file-c", "file-d"}; !reflect.DeepEqual(changes.Removed, want) {
+		t.Fatalf("removed = %v, want %v", changes.Removed, want)
+	}
+}
+
+func TestGoogleChanges_ExpiredPageTokenRequiresFullSync(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, "gone", http.StatusGone)
+	}))
+	defer srv.Close()
+	restore := ingest.SetDriveChangesEndpoints(srv.URL+"/drive/v3/changes", srv.URL+"/drive/v3/channels")
+	defer restore()
+
+	cfgRaw, _ := json.Marshal(domain.GoogleDriveConfig{AccessToken: "token"})
+	notifier := google.NewSourceProvider().(ingest.ChangeNotifier)
+	_, err := notifier.Changes(
+		context.Background(),
+		domain.Source{ID: "src-1", Type: domain.SourceTypeGoogleDrive, Config: cfgRaw},
+		domain.SourceSubscription{ID: "sub-1", Cursor: "7"},
+		domain.SourceChangeHint{},
+	)
+	if !errors.Is(err, ingest.ErrFullSyncRequired) {
+		t.Fatalf("expected ErrFullSyncRequired, got %v", err)
+	}
+}
+
+func TestGoogleParseNotification_ChecksChannelToken(t *testing.T) {
+	notifier := google.NewSourceProvider().(ingest.ChangeNotifier)
+	sub := domain.SourceSubscription{ID: "sub-1", ClientState: "secret"}
+
+	header := http.Header{}
+	header.Set("X-Goog-Channel-Token", "wrong")
+	header.Set("X-Goog-Resource-State", "change")
+	if _, _, err := notifier.ParseNotification(sub, domain.ChangeNotification{Header: header}); !errors.Is(err, domain.ErrInvalidNotification) {
+		t.Fatalf("expected ErrInvalidNotification for a wrong token, got %v", err)
+	}
+
+	header.Set("X-Goog-Channel-Token", "secret")
+	if _, changed, err := notifier.ParseNotification(sub, domain.ChangeNotification{Header: header}); err != nil || !changed {
+		t.Fatalf("expected a change, got changed=%v err=%v", changed, err)
+	}
+
+	header.Set("X-Goog-Resource-State", strings.ToUpper("sync"))
+	if _, changed, err := notifier.ParseNotification(sub, domain.ChangeNotification{Header: header}); err != nil || changed {
+		t.Fatalf("expected the sync message to carry no changes, got changed=%v err=%v", changed, err)
+	}
+}
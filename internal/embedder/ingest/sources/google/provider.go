@@ -8,6 +8,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"strings"
 	"time"
 
@@ -65,25 +66,29 @@ func (p *sourceProvider) ListFiles(
 
 	out := make([]ingest.SourceFile, 0, len(files))
 	for _, file := range files {
-		folderID := file.FolderID
-		if folderID == "" && len(file.Parents) > 0 {
-			folderID = file.Parents[0]
-		}
-		out = append(out, ingest.SourceFile{
-			ExternalID:       file.ID,
-			Name:             file.Name,
-			ExternalURL:      file.WebViewLink,
-			ExternalRef:      strings.Join(file.Parents, ","),
-			MimeType:         file.MimeType,
-			SourceVersion:    file.Version,
-			SourceModifiedAt: parseRFC3339Ptr(file.ModifiedTime),
-			FolderPath:       file.FolderPath,
-			FolderID:         folderID,
-		})
+		out = append(out, driveSourceFile(file))
 	}
 	return out, nil
 }
 
+func driveSourceFile(file ingest.DriveFile) ingest.SourceFile {
+	folderID := file.FolderID
+	if folderID == "" && len(file.Parents) > 0 {
+		folderID = file.Parents[0]
+	}
+	return ingest.SourceFile{
+		ExternalID:       file.ID,
+		Name:             file.Name,
+		ExternalURL:      file.WebViewLink,
+		ExternalRef:      strings.Join(file.Parents, ","),
+		MimeType:         file.MimeType,
+		SourceVersion:    file.Version,
+		SourceModifiedAt: parseRFC3339Ptr(file.ModifiedTime),
+		FolderPath:       file.FolderPath,
+		FolderID:         folderID,
+	}
+}
+
 func (p *sourceProvider) DownloadRecord(
 	ctx context.Context,
 	rec domain.Record,
@@ -110,28 +115,38 @@ func (p *sourceProvider) DownloadRecordContent(
 	rec domain.Record,
 	src domain.Source,
 ) ([]byte, error) {
-	if rec.ExternalID == "" {
-		return nil, fmt.Errorf("record %s is missing external_id", rec.ID)
-	}
-
-	var cfg domain.GoogleDriveConfig
-	if err := json.Unmarshal(src.Config, &cfg); err != nil {
+	reader, err := recordDriveReader(ctx, rec, src)
+	if err != nil {
 		return nil, err
 	}
+	return reader.DownloadFile(ctx, ingest.DriveFile{
+		ID:       rec.ExternalID,
+		MimeType: rec.MimeType,
+	})
+}
 
-	reader, err := ingest.NewDriveReaderFromConfig(ctx, cfg)
+func (p *sourceProvider) OpenRecordContent(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) (io.ReadCloser, error) {
+	reader, err := recordDriveReader(ctx, rec, src)
 	if err != nil {
 		return nil, err
 	}
-
-	body, err := reader.DownloadFile(ctx, ingest.DriveFile{
+	return reader.OpenFile(ctx, ingest.DriveFile{
 		ID:       rec.ExternalID,
 		MimeType: rec.MimeType,
 	})
-	if err != nil {
-		return nil, err
+}
+
+// recordDriveReader returns a reader for the Drive account rec belongs to.
+func recordDriveReader(ctx context.Context, rec domain.Record, src domain.Source) (*ingest.DriveReader, error) {
+	if rec.ExternalID == "" {
+		return nil, fmt.Errorf("record %s is missing external_id", rec.ID)
 	}
-	return body, nil
+	reader, _, err := driveReader(ctx, src)
+	return reader, err
 }
 
 func parseRFC3339Ptr(value string) *time.Time {
@@ -107,6 +107,23 @@ func TestGoogleSourceProvider_ListAndDownload_Smoke(t *testing.T) {
 	if pageCount != nil {
 		t.Fatalf("expected nil page count, got %d", *pageCount)
 	}
+
+	streamer, ok := provider.(ingest.StreamContentProvider)
+	if !ok {
+		t.Fatal("expected provider to stream content")
+	}
+	body, err := streamer.OpenRecordContent(context.Background(), domain.Record{ID: "rec-g1", ExternalID: "file-1", MimeType: "text/plain"}, src)
+	if err != nil {
+		t.Fatalf("OpenRecordContent returned error: %v", err)
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read streamed content: %v", err)
+	}
+	if string(content) != "hello from google drive" {
+		t.Fatalf("unexpected streamed content: %q", content)
+	}
 }
 
 func TestGoogleSourceProvider_SelectedFileOutsideConfiguredFolder(t *testing.T) {
@@ -0,0 +1,776 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+// Package imap indexes email from IMAP mailboxes. Each message becomes a
+// record grouped by thread, and its attachments become child records.
+package imap
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	goimap "github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-message/charset"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+)
+
+const (
+	messageMimeType          = "text/plain"
+	defaultMaxAttachmentSize = 25 << 20
+	maxBodySize              = 10 << 20
+	maxAttachmentLimit       = 100 << 20
+	fetchBatchSize           = 200
+	commandTimeout           = 2 * time.Minute
+)
+
+// ErrMessageGone is returned when a message was expunged or its folder's
+// UIDVALIDITY changed since it was listed.
+var ErrMessageGone = errors.New("imap message no longer exists")
+
+var (
+	messageIDPattern = regexp.MustCompile(`<[^<>\s]+>`)
+	replyPrefix      = regexp.MustCompile(`(?i)^\s*((re|fw|fwd|aw|sv|wg)(\[\d+\])?\s*:\s*)+`)
+	referencesFetch  = mustSection("BODY.PEEK[HEADER.FIELDS (REFERENCES)]")
+)
+
+func init() {
+	// Decode non-UTF-8 subjects and names in envelopes and body structures.
+	goimap.CharsetReader = charset.Reader
+}
+
+type sourceProvider struct {
+	guard *ingest.NetworkGuard
+	mu    sync.Mutex
+	state map[string]*folderState
+}
+
+// folderState caches message metadata per folder so each sync only fetches
+// messages it has not seen. It is reset when the folder's UIDVALIDITY
+// changes.
+type folderState struct {
+	uidValidity uint32
+	messages    map[uint32]messageMeta
+}
+
+type messageMeta struct {
+	messageID   string
+	threadID    string
+	subject     string
+	date        time.Time
+	attachments []attachmentMeta
+}
+
+type attachmentMeta struct {
+	part     string
+	name     string
+	mimeType string
+	size     uint32
+}
+
+// NewSourceProvider creates the IMAP provider. Mail servers must be hosts
+// policy allows.
+func NewSourceProvider(policy domain.SourceNetworkPolicy) ingest.SourceProvider {
+	return &sourceProvider{
+		guard: ingest.NewNetworkGuard(policy),
+		state: make(map[string]*folderState),
+	}
+}
+
+func (p *sourceProvider) Type() domain.SourceType {
+	return domain.SourceTypeIMAP
+}
+
+func (p *sourceProvider) Capabilities() ingest.SourceProviderCapabilities {
+	return ingest.SourceProviderCapabilities{
+		SupportsList:     true,
+		SupportsDownload: true,
+		SupportsBrowse:   false,
+	}
+}
+
+func (p *sourceProvider) PrunesStaleRecords() bool {
+	return true
+}
+
+func (p *sourceProvider) ListFiles(
+	ctx context.Context,
+	_ string,
+	src domain.Source,
+) ([]ingest.SourceFile, error) {
+	cfg, err := decodeConfig(src.Config)
+	if err != nil {
+		return nil, err
+	}
+	s, err := p.connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	maxAttachment := cfg.MaxAttachmentSize
+	if maxAttachment <= 0 {
+		maxAttachment = defaultMaxAttachmentSize
+	}
+
+	var files []ingest.SourceFile
+	for _, folder := range folders(cfg) {
+		uidValidity, uids, messages, err := p.syncFolder(s, src.ID, cfg, folder)
+		if err != nil {
+			return nil, err
+		}
+		for _, uid := range uids {
+			meta, ok := messages[uid]
+			if !ok {
+				continue
+			}
+			id := messageExternalID(folder, uidValidity, uid)
+			version := fmt.Sprintf("%d/%d", uidValidity, uid)
+			folderPath := "/" + folder + "/" + folderName(threadSubject(meta.subject))
+			date := meta.date
+
+			files = append(files, ingest.SourceFile{
+				ExternalID:       id,
+				Name:             displaySubject(meta.subject),
+				ExternalRef:      meta.messageID,
+				MimeType:         messageMimeType,
+				SourceVersion:    version,
+				SourceModifiedAt: &date,
+				FolderPath:       folderPath,
+				FolderID:         meta.threadID,
+			})
+			if cfg.SkipAttachments {
+				continue
+			}
+			for _, att := range meta.attachments {
+				if int64(att.size) > maxAttachment {
+					continue
+				}
+				files = append(files, ingest.SourceFile{
+					ExternalID:       id + "#" + att.part,
+					Name:             att.name,
+					ExternalRef:      id,
+					MimeType:         ingest.NormalizeFileMIMEType(att.name, att.mimeType),
+					SourceVersion:    version,
+					SourceModifiedAt: &date,
+					FolderPath:       folderPath,
+					FolderID:         id,
+					ParentExternalID: id,
+				})
+			}
+		}
+	}
+	return files, nil
+}
+
+// syncFolder returns the folder's UIDVALIDITY, its current message UIDs in
+// ascending order and their metadata, fetching metadata only for new UIDs.
+func (p *sourceProvider) syncFolder(
+	s *session,
+	sourceID string,
+	cfg domain.IMAPConfig,
+	folder string,
+) (uint32, []uint32, map[uint32]messageMeta, error) {
+	status, err := s.c.Select(folder, true)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("select imap folder %q: %w", folder, err)
+	}
+
+	criteria := goimap.NewSearchCriteria()
+	criteria.WithoutFlags = []string{goimap.DeletedFlag}
+	if cfg.SinceDays > 0 {
+		criteria.Since = time.Now().AddDate(0, 0, -cfg.SinceDays)
+	}
+	uids, err := s.c.UidSearch(criteria)
+	if err != nil {
+		return 0, nil, nil, fmt.Errorf("search imap folder %q: %w", folder, err)
+	}
+	sort.Slice(uids, func(i, j int) bool { return uids[i] < uids[j] })
+
+	key := strings.Join([]string{sourceID, cfg.Host, cfg.Username, folder}, "\x00")
+	p.mu.Lock()
+	state := p.state[key]
+	if state == nil || state.uidValidity != status.UidValidity {
+		state = &folderState{uidValidity: status.UidValidity, messages: make(map[uint32]messageMeta)}
+		p.state[key] = state
+	}
+	known := make(map[uint32]messageMeta, len(state.messages))
+	for uid, meta := range state.messages {
+		known[uid] = meta
+	}
+	p.mu.Unlock()
+
+	var missing []uint32
+	live := make(map[uint32]messageMeta, len(uids))
+	for _, uid := range uids {
+		if meta, ok := known[uid]; ok {
+			live[uid] = meta
+			continue
+		}
+		missing = append(missing, uid)
+	}
+	for start := 0; start < len(missing); start += fetchBatchSize {
+		batch := missing[start:min(start+fetchBatchSize, len(missing))]
+		fetched, err := s.fetchMeta(batch)
+		if err != nil {
+			return 0, nil, nil, fmt.Errorf("fetch imap folder %q: %w", folder, err)
+		}
+		for uid, meta := range fetched {
+			live[uid] = meta
+		}
+	}
+
+	p.mu.Lock()
+	if current := p.state[key]; current == state {
+		state.messages = live
+	}
+	p.mu.Unlock()
+	return status.UidValidity, uids, live, nil
+}
+
+func (p *sourceProvider) DownloadRecord(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) (string, *int, error) {
+	ref, err := parseExternalID(rec.ExternalID)
+	if err != nil {
+		return "", nil, err
+	}
+	if ref.part == "" {
+		cfg, err := decodeConfig(src.Config)
+		if err != nil {
+			return "", nil, err
+		}
+		s, err := p.connect(ctx, cfg)
+		if err != nil {
+			return "", nil, err
+		}
+		defer s.close()
+		text, err := s.messageText(ref)
+		return text, nil, err
+	}
+
+	body, err := p.DownloadRecordContent(ctx, rec, src)
+	if err != nil {
+		return "", nil, err
+	}
+	doc, err := ingest.ExtractText(ingest.FileMeta{
+		ID:       rec.ExternalID,
+		Name:     rec.Name,
+		MimeType: rec.MimeType,
+	}, body)
+	if err != nil {
+		return "", nil, err
+	}
+	return doc.Text, doc.PageCount, nil
+}
+
+// DownloadRecordContent returns an attachment's decoded bytes, or the
+// message's text.
+func (p *sourceProvider) DownloadRecordContent(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) ([]byte, error) {
+	ref, err := parseExternalID(rec.ExternalID)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := decodeConfig(src.Config)
+	if err != nil {
+		return nil, err
+	}
+	s, err := p.connect(ctx, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer s.close()
+
+	if ref.part == "" {
+		text, err := s.messageText(ref)
+		return []byte(text), err
+	}
+	return s.attachment(ref)
+}
+
+func decodeConfig(raw json.RawMessage) (domain.IMAPConfig, error) {
+	var cfg domain.IMAPConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return domain.IMAPConfig{}, fmt.Errorf("decode imap config: %w", err)
+	}
+	if strings.TrimSpace(cfg.Host) == "" {
+		return domain.IMAPConfig{}, fmt.Errorf("imap host is required")
+	}
+	return cfg, nil
+}
+
+func folders(cfg domain.IMAPConfig) []string {
+	if len(cfg.Folders) == 0 {
+		return []string{"INBOX"}
+	}
+	return cfg.Folders
+}
+
+type messageRef struct {
+	folder      string
+	uidValidity uint32
+	uid         uint32
+	part        string
+}
+
+// messageExternalID identifies a message as <folder>/<uidvalidity>/<uid>;
+// attachments append #<part>. UIDs are only meaningful together with the
+// folder's UIDVALIDITY, so a reset yields new IDs and the old records are
+// pruned.
+func messageExternalID(folder string, uidValidity, uid uint32) string {
+	return fmt.Sprintf("%s/%d/%d", folder, uidValidity, uid)
+}
+
+func parseExternalID(id string) (messageRef, error) {
+	invalid := fmt.Errorf("invalid imap record id %q", id)
+	head, tail, ok := cutLast(id, "/")
+	if !ok {
+		return messageRef{}, invalid
+	}
+	uidPart, part, _ := strings.Cut(tail, "#")
+	folder, validityPart, ok := cutLast(head, "/")
+	if !ok || folder == "" {
+		return messageRef{}, invalid
+	}
+	uid, err := strconv.ParseUint(uidPart, 10, 32)
+	if err != nil {
+		return messageRef{}, invalid
+	}
+	uidValidity, err := strconv.ParseUint(validityPart, 10, 32)
+	if err != nil {
+		return messageRef{}, invalid
+	}
+	return messageRef{folder: folder, uidValidity: uint32(uidValidity), uid: uint32(uid), part: part}, nil
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return "", "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+type session struct {
+	c    *client.Client
+	stop func() bool
+}
+
+func (p *sourceProvider) connect(ctx context.Context, cfg domain.IMAPConfig) (*session, error) {
+	security := strings.ToLower(strings.TrimSpace(cfg.Security))
+	if security == "" {
+		security = "tls"
+	}
+	port := cfg.Port
+	if port == 0 {
+		port = 993
+		if security != "tls" {
+			port = 143
+		}
+	}
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(port))
+	tlsConfig := &tls.Config{ServerName: cfg.Host, MinVersion: tls.VersionTLS12}
+
+	conn, err := p.guard.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("connect to imap server %s: %w", addr, err)
+	}
+	if security == "tls" {
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("imap tls handshake with %s: %w", addr, err)
+		}
+		conn = tlsConn
+	}
+
+	c, err := client.New(conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("imap greeting from %s: %w", addr, err)
+	}
+	c.Timeout = commandTimeout
+	// The client has no context support; closing the connection aborts any
+	// command in flight.
+	stop := context.AfterFunc(ctx, func() { _ = c.Terminate() })
+	s := &session{c: c, stop: stop}
+
+	switch security {
+	case "tls":
+	case "starttls":
+		if err := c.StartTLS(tlsConfig); err != nil {
+			s.close()
+			return nil, fmt.Errorf("imap starttls with %s: %w", addr, err)
+		}
+	case "none":
+	default:
+		s.close()
+		return nil, fmt.Errorf("unsupported imap security %q", cfg.Security)
+	}
+
+	if err := c.Login(cfg.Username, cfg.Password); err != nil {
+		s.close()
+		return nil, fmt.Errorf("imap login: %w", err)
+	}
+	return s, nil
+}
+
+func (s *session) close() {
+	s.stop()
+	_ = s.c.Logout()
+}
+
+func (s *session) fetch(uid uint32, items []goimap.FetchItem) (*goimap.Message, error) {
+	var set goimap.SeqSet
+	set.AddNum(uid)
+	messages, err := s.fetchAll(&set, items)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, ErrMessageGone
+	}
+	return messages[0], nil
+}
+
+func (s *session) fetchAll(set *goimap.SeqSet, items []goimap.FetchItem) ([]*goimap.Message, error) {
+	ch := make(chan *goimap.Message, 16)
+	done := make(chan error, 1)
+	go func() { done <- s.c.UidFetch(set, items, ch) }()
+
+	var messages []*goimap.Message
+	for msg := range ch {
+		messages = append(messages, msg)
+	}
+	return messages, <-done
+}
+
+func (s *session) fetchMeta(uids []uint32) (map[uint32]messageMeta, error) {
+	var set goimap.SeqSet
+	set.AddNum(uids...)
+	messages, err := s.fetchAll(&set, []goimap.FetchItem{
+		goimap.FetchUid, goimap.FetchEnvelope, goimap.FetchBodyStructure, referencesFetch.FetchItem(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	metas := make(map[uint32]messageMeta, len(messages))
+	for _, msg := range messages {
+		if msg.Uid == 0 || msg.Envelope == nil {
+			continue
+		}
+		meta := messageMeta{
+			messageID: strings.TrimSpace(msg.Envelope.MessageId),
+			subject:   strings.TrimSpace(msg.Envelope.Subject),
+			date:      msg.Envelope.Date.UTC(),
+		}
+		meta.threadID = threadID(meta.messageID, msg.Envelope.InReplyTo, references(msg.GetBody(referencesFetch)))
+		if meta.threadID == "" {
+			meta.threadID = fmt.Sprintf("uid:%d", msg.Uid)
+		}
+		if msg.BodyStructure != nil {
+			meta.attachments = attachments(msg.BodyStructure)
+		}
+		metas[msg.Uid] = meta
+	}
+	return metas, nil
+}
+
+// selectMessage opens ref's folder and checks that its UIDs still refer to
+// the same messages.
+func (s *session) selectMessage(ref messageRef) error {
+	status, err := s.c.Select(ref.folder, true)
+	if err != nil {
+		return fmt.Errorf("select imap folder %q: %w", ref.folder, err)
+	}
+	if status.UidValidity != ref.uidValidity {
+		return ErrMessageGone
+	}
+	return nil
+}
+
+// messageText renders a message as its sender, recipients, date and
+// subject followed by its body, preferring the plain-text alternative.
+func (s *session) messageText(ref messageRef) (string, error) {
+	if err := s.selectMessage(ref); err != nil {
+		return "", err
+	}
+	msg, err := s.fetch(ref.uid, []goimap.FetchItem{goimap.FetchEnvelope, goimap.FetchBodyStructure})
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	if env := msg.Envelope; env != nil {
+		writeHeader(&b, "Subject", env.Subject)
+		writeHeader(&b, "From", formatAddresses(env.From))
+		writeHeader(&b, "To", formatAddresses(env.To))
+		writeHeader(&b, "Cc", formatAddresses(env.Cc))
+		if !env.Date.IsZero() {
+			writeHeader(&b, "Date", env.Date.UTC().Format(time.RFC1123Z))
+		}
+	}
+
+	if msg.BodyStructure != nil {
+		if path, part := bodyPart(msg.BodyStructure); part != nil {
+			content, err := s.part(ref.uid, path, part, maxBodySize)
+			if err != nil {
+				return "", err
+			}
+			text := string(content)
+			if strings.EqualFold(part.MIMESubType, "html") {
+				_, text = ingest.HTMLToText(content)
+			}
+			b.WriteString("\n")
+			b.WriteString(strings.TrimSpace(text))
+		}
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func (s *session) attachment(ref messageRef) ([]byte, error) {
+	if err := s.selectMessage(ref); err != nil {
+		return nil, err
+	}
+	msg, err := s.fetch(ref.uid, []goimap.FetchItem{goimap.FetchBodyStructure})
+	if err != nil {
+		return nil, err
+	}
+	if msg.BodyStructure == nil {
+		return nil, ErrMessageGone
+	}
+
+	var (
+		path []int
+		part *goimap.BodyStructure
+	)
+	msg.BodyStructure.Walk(func(p []int, bs *goimap.BodyStructure) bool {
+		if partName(p) == ref.part {
+			path, part = p, bs
+			return false
+		}
+		return true
+	})
+	if part == nil {
+		return nil, fmt.Errorf("imap message %d has no part %s", ref.uid, ref.part)
+	}
+	return s.part(ref.uid, path, part, maxAttachmentLimit)
+}
+
+// part fetches one body part and undoes its transfer encoding. Text parts
+// are also converted to UTF-8.
+func (s *session) part(uid uint32, path []int, bs *goimap.BodyStructure, limit int64) ([]byte, error) {
+	section := &goimap.BodySectionName{BodyPartName: goimap.BodyPartName{Path: path}, Peek: true}
+	msg, err := s.fetch(uid, []goimap.FetchItem{section.FetchItem()})
+	if err != nil {
+		return nil, err
+	}
+	literal := msg.GetBody(section)
+	if literal == nil {
+		return nil, fmt.Errorf("imap server returned no content for part %s", partName(path))
+	}
+
+	var r io.Reader = literal
+	switch strings.ToLower(bs.Encoding) {
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	}
+	if strings.EqualFold(bs.MIMEType, "text") {
+		if cs := bs.Params["charset"]; cs != "" && !strings.EqualFold(cs, "utf-8") && !strings.EqualFold(cs, "us-ascii") {
+			decoded, err := charset.Reader(cs, r)
+			if err == nil {
+				r = decoded
+			}
+		}
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("decode imap part %s: %w", partName(path), err)
+	}
+	if int64(len(body)) > limit {
+		return nil, fmt.Errorf("imap part %s exceeds %d bytes", partName(path), limit)
+	}
+	return body, nil
+}
+
+// bodyPart picks the part holding the message text: the first inline
+// text/plain part, else the first inline text/html part.
+func bodyPart(root *goimap.BodyStructure) ([]int, *goimap.BodyStructure) {
+	var (
+		htmlPath, plainPath []int
+		htmlPart, plainPart *goimap.BodyStructure
+	)
+	root.Walk(func(path []int, bs *goimap.BodyStructure) bool {
+		if isMessagePart(bs) {
+			return false
+		}
+		if !strings.EqualFold(bs.MIMEType, "text") || isAttachment(bs) {
+			return true
+		}
+		switch strings.ToLower(bs.MIMESubType) {
+		case "plain":
+			if plainPart == nil {
+				plainPath, plainPart = path, bs
+			}
+		case "html":
+			if htmlPart == nil {
+				htmlPath, htmlPart = path, bs
+			}
+		}
+		return true
+	})
+	if plainPart != nil {
+		return plainPath, plainPart
+	}
+	return htmlPath, htmlPart
+}
+
+// attachments lists the named, non-multipart parts of a message. Forwarded
+// messages are treated as a single attachment.
+func attachments(root *goimap.BodyStructure) []attachmentMeta {
+	var out []attachmentMeta
+	root.Walk(func(path []int, bs *goimap.BodyStructure) bool {
+		if len(bs.Parts) > 0 {
+			return true
+		}
+		if !isAttachment(bs) {
+			return !isMessagePart(bs)
+		}
+		name, _ := bs.Filename()
+		if name == "" && isMessagePart(bs) {
+			name = "forwarded.eml"
+			if bs.Envelope != nil && bs.Envelope.Subject != "" {
+				name = folderName(bs.Envelope.Subject) + ".eml"
+			}
+		}
+		out = append(out, attachmentMeta{
+			part:     partName(path),
+			name:     name,
+			mimeType: strings.ToLower(bs.MIMEType + "/" + bs.MIMESubType),
+			size:     bs.Size,
+		})
+		return false
+	})
+	return out
+}
+
+func isAttachment(bs *goimap.BodyStructure) bool {
+	if strings.EqualFold(bs.Disposition, "attachment") {
+		return true
+	}
+	if isMessagePart(bs) {
+		return true
+	}
+	name, _ := bs.Filename()
+	return name != ""
+}
+
+func isMessagePart(bs *goimap.BodyStructure) bool {
+	return strings.EqualFold(bs.MIMEType, "message") && strings.EqualFold(bs.MIMESubType, "rfc822")
+}
+
+func partName(path []int) string {
+	parts := make([]string, len(path))
+	for i, n := range path {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ".")
+}
+
+// threadID is the Message-ID of the first message in the thread, taken from
+// References, then In-Reply-To, then the message itself.
+func threadID(messageID, inReplyTo string, refs []string) string {
+	if len(refs) > 0 {
+		return refs[0]
+	}
+	if id := messageIDPattern.FindString(inReplyTo); id != "" {
+		return id
+	}
+	return messageID
+}
+
+func references(literal goimap.Literal) []string {
+	if literal == nil {
+		return nil
+	}
+	raw, err := io.ReadAll(literal)
+	if err != nil {
+		return nil
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(append(raw, "\r\n"...)))
+	if err != nil {
+		return nil
+	}
+	return messageIDPattern.FindAllString(msg.Header.Get("References"), -1)
+}
+
+func threadSubject(subject string) string {
+	return displaySubject(strings.TrimSpace(replyPrefix.ReplaceAllString(subject, "")))
+}
+
+func displaySubject(subject string) string {
+	if subject == "" {
+		return "(no subject)"
+	}
+	return subject
+}
+
+// folderName keeps a subject usable as one folder path segment.
+func folderName(s string) string {
+	return strings.ReplaceAll(strings.TrimSpace(s), "/", "-")
+}
+
+func formatAddresses(addrs []*goimap.Address) string {
+	out := make([]string, 0, len(addrs))
+	for _, a := range addrs {
+		if a == nil {
+			continue
+		}
+		addr := a.Address()
+		if a.PersonalName != "" {
+			addr = a.PersonalName + " <" + a.Address() + ">"
+		}
+		out = append(out, addr)
+	}
+	return strings.Join(out, ", ")
+}
+
+func writeHeader(b *strings.Builder, name, value string) {
+	if value = strings.TrimSpace(value); value != "" {
+		b.WriteString(name + ": " + value + "\n")
+	}
+}
+
+func mustSection(item string) *goimap.BodySectionName {
+	section, err := goimap.ParseBodySectionName(goimap.FetchItem(item))
+	if err != nil {
+		panic(err)
+	}
+	return section
+}
@@ -0,0 +1,278 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package imap_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	goimap "github.com/emersion/go-imap"
+	"github.com/emersion/go-imap/backend/memory"
+	"github.com/emersion/go-imap/client"
+	"github.com/emersion/go-imap/server"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	imapsource "github.com/ultravioletrs/cube/internal/embedder/ingest/sources/imap"
+)
+
+const (
+	rootMessage = "From: Alice <alice@example.com>\r\n" +
+		"To: team@example.com\r\n" +
+		"Subject: Release plan\r\n" +
+		"Date: Mon, 02 Mar 2026 10:00:00 +0000\r\n" +
+		"Message-ID: <root@example.com>\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		"We ship on Friday.\r\n"
+
+	replyMessage = "From: Bob <bob@example.com>\r\n" +
+		"To: team@example.com\r\n" +
+		"Subject: Re: Release plan\r\n" +
+		"Date: Mon, 02 Mar 2026 11:00:00 +0000\r\n" +
+		"Message-ID: <reply@example.com>\r\n" +
+		"In-Reply-To: <root@example.com>\r\n" +
+		"References: <root@example.com>\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=\"b1\"\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain; charset=iso-8859-1\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n" +
+		"\r\n" +
+		"Agreed, caf=E9 after.\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain; name=\"notes.txt\"\r\n" +
+		"Content-Disposition: attachment; filename=\"notes.txt\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n" +
+		"\r\n" +
+		"cm9sbGJhY2sgaXMgbWFrZSByZXZlcnQK\r\n" +
+		"--b1--\r\n"
+
+	htmlMessage = "From: ops@example.com\r\n" +
+		"To: team@example.com\r\n" +
+		"Subject: Status\r\n" +
+		"Date: Tue, 03 Mar 2026 09:00:00 +0000\r\n" +
+		"Message-ID: <status@example.com>\r\n" +
+		"Content-Type: text/html; charset=utf-8\r\n" +
+		"\r\n" +
+		"<html><body><h1>All green</h1><p>No incidents.</p></body></html>\r\n"
+)
+
+// startServer runs an in-memory IMAP server holding the backend's default
+// message (UID 6) plus the given messages, appended in order.
+func startServer(t *testing.T, messages ...string) string {
+	t.Helper()
+	srv := server.New(memory.New())
+	srv.AllowInsecureAuth = true
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	go func() { _ = srv.Serve(ln) }()
+	t.Cleanup(func() { _ = srv.Close() })
+
+	c := dialTest(t, ln.Addr().String())
+	for _, msg := range messages {
+		if err := c.Append("INBOX", nil, time.Now(), bytes.NewBufferString(msg)); err != nil {
+			t.Fatalf("append message: %v", err)
+		}
+	}
+	return ln.Addr().String()
+}
+
+func dialTest(t *testing.T, addr string) *client.Client {
+	t.Helper()
+	c, err := client.Dial(addr)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = c.Logout() })
+	if err := c.Login("username", "password"); err != nil {
+		t.Fatalf("login: %v", err)
+	}
+	return c
+}
+
+func testSource(t *testing.T, addr string, mutate func(*domain.IMAPConfig)) domain.Source {
+	t.Helper()
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		t.Fatalf("split addr: %v", err)
+	}
+	port, _ := strconv.Atoi(portStr)
+	cfg := domain.IMAPConfig{
+		Host:     host,
+		Port:     port,
+		Security: "none",
+		Username: "username",
+		Password: "password",
+	}
+	if mutate != nil {
+		mutate(&cfg)
+	}
+	raw, err := json.Marshal(cfg)
+	if err != nil {
+		t.Fatalf("marshal config: %v", err)
+	}
+	return domain.Source{ID: "src-1", Type: domain.SourceTypeIMAP, Config: raw}
+}
+
+// newProvider creates a provider allowed to reach the local test server.
+func newProvider() ingest.SourceProvider {
+	return imapsource.NewSourceProvider(domain.SourceNetworkPolicy{AllowedHosts: []string{"127.0.0.1"}})
+}
+
+func listByID(t *testing.T, provider ingest.SourceProvider, src domain.Source) map[string]ingest.SourceFile {
+	t.Helper()
+	files, err := provider.ListFiles(context.Background(), "user-1", src)
+	if err != nil {
+		t.Fatalf("list files: %v", err)
+	}
+	byID := make(map[string]ingest.SourceFile, len(files))
+	for _, f := range files {
+		byID[f.ExternalID] = f
+	}
+	return byID
+}
+
+func TestListFilesGroupsThreadsAndAttachments(t *testing.T) {
+	addr := startServer(t, rootMessage, replyMessage)
+	provider := newProvider()
+	byID := listByID(t, provider, testSource(t, addr, nil))
+
+	if len(byID) != 4 {
+		t.Fatalf("expected 3 messages and 1 attachment, got %+v", byID)
+	}
+	root, reply := byID["INBOX/1/7"], byID["INBOX/1/8"]
+	if root.Name != "Release plan" || root.ExternalRef != "<root@example.com>" || root.SourceVersion != "1/7" {
+		t.Fatalf("unexpected root message: %+v", root)
+	}
+	if root.FolderID != "<root@example.com>" || reply.FolderID != root.FolderID {
+		t.Fatalf("expected the reply to share the root's thread, got %q and %q", root.FolderID, reply.FolderID)
+	}
+	if reply.FolderPath != "/INBOX/Release plan" || reply.FolderPath != root.FolderPath {
+		t.Fatalf("expected thread folder path, got %q and %q", root.FolderPath, reply.FolderPath)
+	}
+	if root.SourceModifiedAt == nil || !root.SourceModifiedAt.Equal(time.Date(2026, 3, 2, 10, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected message date, got %v", root.SourceModifiedAt)
+	}
+
+	att, ok := byID["INBOX/1/8#2"]
+	if !ok {
+		t.Fatalf("expected attachment record, got %+v", byID)
+	}
+	if att.Name != "notes.txt" || att.MimeType != "text/plain" || att.FolderID != "INBOX/1/8" || att.ExternalRef != "INBOX/1/8" || att.ParentExternalID != "INBOX/1/8" {
+		t.Fatalf("expected attachment to be a child of its message, got %+v", att)
+	}
+}
+
+func TestListFilesSkipsAttachments(t *testing.T) {
+	addr := startServer(t, replyMessage)
+	src := testSource(t, addr, func(cfg *domain.IMAPConfig) { cfg.SkipAttachments = true })
+
+	for id := range listByID(t, newProvider(), src) {
+		if strings.Contains(id, "#") {
+			t.Fatalf("expected attachments to be skipped, got %q", id)
+		}
+	}
+}
+
+func TestListFilesPrunesDeletedMessages(t *testing.T) {
+	addr := startServer(t, rootMessage, replyMessage)
+	provider := newProvider()
+	src := testSource(t, addr, nil)
+
+	if byID := listByID(t, provider, src); len(byID) != 4 {
+		t.Fatalf("expected 4 records before deletion, got %+v", byID)
+	}
+
+	c := dialTest(t, addr)
+	if _, err := c.Select("INBOX", false); err != nil {
+		t.Fatalf("select: %v", err)
+	}
+	var set goimap.SeqSet
+	set.AddNum(8)
+	if err := c.UidStore(&set, goimap.FormatFlagsOp(goimap.AddFlags, true), []any{goimap.DeletedFlag}, nil); err != nil {
+		t.Fatalf("flag deleted: %v", err)
+	}
+	if err := c.Expunge(nil); err != nil {
+		t.Fatalf("expunge: %v", err)
+	}
+
+	byID := listByID(t, provider, src)
+	if _, ok := byID["INBOX/1/8"]; ok {
+		t.Fatalf("expected expunged message to be gone, got %+v", byID)
+	}
+	if _, ok := byID["INBOX/1/8#2"]; ok {
+		t.Fatalf("expected expunged attachment to be gone, got %+v", byID)
+	}
+	if _, ok := byID["INBOX/1/7"]; !ok || len(byID) != 2 {
+		t.Fatalf("expected remaining messages to be listed, got %+v", byID)
+	}
+}
+
+func TestDownloadRecord(t *testing.T) {
+	addr := startServer(t, rootMessage, replyMessage, htmlMessage)
+	provider := newProvider()
+	src := testSource(t, addr, nil)
+
+	text, _, err := provider.DownloadRecord(context.Background(), domain.Record{ExternalID: "INBOX/1/8"}, src)
+	if err != nil {
+		t.Fatalf("download reply: %v", err)
+	}
+	want := "Subject: Re: Release plan\n" +
+		"From: Bob <bob@example.com>\n" +
+		"To: team@example.com\n" +
+		"Date: Mon, 02 Mar 2026 11:00:00 +0000\n" +
+		"\n" +
+		"Agreed, café after."
+	if text != want {
+		t.Fatalf("expected %q, got %q", want, text)
+	}
+
+	text, _, err = provider.DownloadRecord(context.Background(), domain.Record{ExternalID: "INBOX/1/9"}, src)
+	if err != nil {
+		t.Fatalf("download html message: %v", err)
+	}
+	if !strings.HasSuffix(text, "All green\n\nNo incidents.") {
+		t.Fatalf("expected html body as text, got %q", text)
+	}
+
+	text, _, err = provider.DownloadRecord(context.Background(), domain.Record{
+		ExternalID: "INBOX/1/8#2",
+		Name:       "notes.txt",
+		MimeType:   "text/plain",
+	}, src)
+	if err != nil {
+		t.Fatalf("download attachment: %v", err)
+	}
+	if text != "rollback is make revert\n" {
+		t.Fatalf("unexpected attachment text %q", text)
+	}
+}
+
+func TestDownloadRecordRejectsStaleUIDValidity(t *testing.T) {
+	addr := startServer(t, rootMessage)
+	_, _, err := newProvider().
+		DownloadRecord(context.Background(), domain.Record{ExternalID: "INBOX/99/7"}, testSource(t, addr, nil))
+	if !errors.Is(err, imapsource.ErrMessageGone) {
+		t.Fatalf("expected ErrMessageGone, got %v", err)
+	}
+}
+
+func TestListFilesRefusesInternalHosts(t *testing.T) {
+	addr := startServer(t, rootMessage)
+	_, err := imapsource.NewSourceProvider(domain.SourceNetworkPolicy{}).
+		ListFiles(context.Background(), "user-1", testSource(t, addr, nil))
+	if !errors.Is(err, domain.ErrAddressNotAllowed) {
+		t.Fatalf("expected ErrAddressNotAllowed, got %v", err)
+	}
+}
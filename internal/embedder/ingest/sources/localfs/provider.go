@@ -11,6 +11,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -86,6 +87,19 @@ func (p *sourceProvider) DownloadRecordContent(
 	rec domain.Record,
 	src domain.Source,
 ) ([]byte, error) {
+	body, err := p.OpenRecordContent(ctx, rec, src)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	return io.ReadAll(body)
+}
+
+func (p *sourceProvider) OpenRecordContent(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) (io.ReadCloser, error) {
 	if rec.ExternalID == "" {
 		return nil, fmt.Errorf("record %s is missing external_id", rec.ID)
 	}
@@ -95,7 +109,7 @@ func (p *sourceProvider) DownloadRecordContent(
 		if p.store == nil {
 			return nil, fmt.Errorf("object storage is not configured")
 		}
-		return p.store.Get(ctx, rec.ExternalID)
+		return p.store.Open(ctx, rec.ExternalID)
 	}
 
 	// Legacy local_fs records might still point to upload_dir + file name.
@@ -113,5 +127,5 @@ func (p *sourceProvider) DownloadRecordContent(
 	}
 
 	path := filepath.Join(cfg.UploadDir, rec.UserID, fileName)
-	return os.ReadFile(path)
+	return os.Open(path)
 }
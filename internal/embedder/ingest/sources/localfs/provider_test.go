@@ -4,6 +4,7 @@
 package localfs
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"io"
@@ -20,6 +21,14 @@ type fakeStore struct {
 
 func (f *fakeStore) Put(context.Context, string, string, int64, io.Reader) error { return nil }
 func (f *fakeStore) Delete(context.Context, string) error                        { return nil }
+func (f *fakeStore) DeletePrefix(context.Context, string) error                  { return nil }
+func (f *fakeStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	body, err := f.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(body)), nil
+}
 func (f *fakeStore) Get(_ context.Context, key string) ([]byte, error) {
 	body, ok := f.objects[key]
 	if !ok {
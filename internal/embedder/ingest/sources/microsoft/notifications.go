@@ -0,0 +1,318 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package microsoft
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest/sourcepath"
+)
+
+const (
+	// Graph keeps driveItem subscriptions for at most 42300 minutes.
+	graphSubscriptionTTL = 29 * 24 * time.Hour
+	// maxGraphChanges bounds a targeted sync; larger bursts fall back to a
+	// full sync.
+	maxGraphChanges = 500
+)
+
+var _ ingest.ChangeNotifier = (*sourceProvider)(nil)
+
+type graphSubscription struct {
+	ID                 string `json:"id,omitempty"`
+	ChangeType         string `json:"changeType,omitempty"`
+	NotificationURL    string `json:"notificationUrl,omitempty"`
+	Resource           string `json:"resource,omitempty"`
+	ExpirationDateTime string `json:"expirationDateTime"`
+	ClientState        string `json:"clientState,omitempty"`
+}
+
+// Subscribe creates a Graph subscription on the root of the source's drive
+// and starts its delta feed at the current state. Graph validates
+// callbackURL before it answers, so the callback must already resolve sub.
+func (p *sourceProvider) Subscribe(
+	ctx context.Context,
+	src domain.Source,
+	sub domain.SourceSubscription,
+	callbackURL string,
+) (domain.SourceSubscription, error) {
+	graph, _, err := p.graphClient(ctx, src)
+	if err != nil {
+		return sub, err
+	}
+	cursor, err := graph.latestDeltaLink(ctx)
+	if err != nil {
+		return sub, err
+	}
+
+	var created graphSubscription
+	err = graph.doJSON(ctx, http.MethodPost, microsoftGraphBaseURL+"/subscriptions", graphSubscription{
+		ChangeType:         "updated",
+		NotificationURL:    callbackURL,
+		Resource:           "drives/" + graph.driveID + "/root",
+		ExpirationDateTime: time.Now().Add(graphSubscriptionTTL).UTC().Format(time.RFC3339),
+		ClientState:        sub.ClientState,
+	}, &created)
+	if err != nil {
+		return sub, fmt.Errorf("create microsoft graph subscription: %w", err)
+	}
+	sub.ExternalID = created.ID
+	sub.Resource = graph.driveID
+	sub.Cursor = cursor
+	sub.ExpiresAt = parseRFC3339Ptr(created.ExpirationDateTime)
+	return sub, nil
+}
+
+// Renew extends the subscription's expiration.
+func (p *sourceProvider) Renew(
+	ctx context.Context,
+	src domain.Source,
+	sub domain.SourceSubscription,
+	_ string,
+) (domain.SourceSubscription, error) {
+	graph, _, err := p.graphClient(ctx, src)
+	if err != nil {
+		return sub, err
+	}
+	var renewed graphSubscription
+	err = graph.doJSON(ctx, http.MethodPatch, microsoftGraphBaseURL+"/subscriptions/"+url.PathEscape(sub.ExternalID), graphSubscription{
+		ExpirationDateTime: time.Now().Add(graphSubscriptionTTL).UTC().Format(time.RFC3339),
+	}, &renewed)
+	if err != nil {
+		return sub, fmt.Errorf("renew microsoft graph subscription: %w", err)
+	}
+	sub.ExpiresAt = parseRFC3339Ptr(renewed.ExpirationDateTime)
+	return sub, nil
+}
+
+// Unsubscribe deletes the Graph subscription. Subscriptions that are already
+// gone are not an error.
+func (p *sourceProvider) Unsubscribe(ctx context.Context, src domain.Source, sub domain.SourceSubscription) error {
+	if sub.ExternalID == "" {
+		return nil
+	}
+	graph, _, err := p.graphClient(ctx, src)
+	if err != nil {
+		return err
+	}
+	err = graph.doJSON(ctx, http.MethodDelete, microsoftGraphBaseURL+"/subscriptions/"+url.PathEscape(sub.ExternalID), nil, nil)
+	if err != nil && !isGraphStatus(err, http.StatusNotFound) {
+		return fmt.Errorf("delete microsoft graph subscription: %w", err)
+	}
+	return nil
+}
+
+// ParseNotification checks the clientState of every notification in the
+// batch. Drive item notifications do not say what changed; the delta feed
+// is read instead.
+func (p *sourceProvider) ParseNotification(
+	sub domain.SourceSubscription,
+	n domain.ChangeNotification,
+) (domain.SourceChangeHint, bool, error) {
+	var batch struct {
+		Value []struct {
+			SubscriptionID string `json:"subscriptionId"`
+			ClientState    string `json:"clientState"`
+		} `json:"value"`
+	}
+	if err := json.Unmarshal(n.Body, &batch); err != nil || len(batch.Value) == 0 {
+		return domain.SourceChangeHint{}, false, domain.ErrInvalidNotification
+	}
+	for _, v := range batch.Value {
+		if sub.ClientState == "" ||
+			subtle.ConstantTimeCompare([]byte(v.ClientState), []byte(sub.ClientState)) != 1 ||
+			v.SubscriptionID != sub.ExternalID {
+			return domain.SourceChangeHint{}, false, domain.ErrInvalidNotification
+		}
+	}
+	return domain.SourceChangeHint{}, true, nil
+}
+
+// Changes reads the drive's delta feed from the subscription's delta link and
+// keeps the files within the source's scopes and selection.
+func (p *sourceProvider) Changes(
+	ctx context.Context,
+	src domain.Source,
+	sub domain.SourceSubscription,
+	_ domain.SourceChangeHint,
+) (ingest.SourceChanges, error) {
+	graph, cfg, err := p.graphClient(ctx, src)
+	if err != nil {
+		return ingest.SourceChanges{}, err
+	}
+	if sub.Cursor == "" {
+		cursor, err := graph.latestDeltaLink(ctx)
+		if err != nil {
+			return ingest.SourceChanges{}, err
+		}
+		return ingest.SourceChanges{Cursor: cursor}, nil
+	}
+	if !strings.HasPrefix(sub.Cursor, microsoftGraphBaseURL+"/") {
+		return ingest.SourceChanges{}, fmt.Errorf("%w: delta link is not a Graph URL", ingest.ErrFullSyncRequired)
+	}
+
+	// An item can appear more than once in the feed; its last entry wins.
+	var (
+		order []string
+		out   ingest.SourceChanges
+	)
+	deleted := make(map[string]bool)
+	endpoint := sub.Cursor
+	for endpoint != "" {
+		var page struct {
+			Value     []graphDeltaItem `json:"value"`
+			NextLink  string           `json:"@odata.nextLink"`
+			DeltaLink string           `json:"@odata.deltaLink"`
+		}
+		if err := graph.getJSON(ctx, endpoint, &page); err != nil {
+			if isGraphStatus(err, http.StatusGone) {
+				return ingest.SourceChanges{}, fmt.Errorf("%w: %v", ingest.ErrFullSyncRequired, err)
+			}
+			return ingest.SourceChanges{}, err
+		}
+		for _, item := range page.Value {
+			id := strings.TrimSpace(item.ID)
+			if item.Folder != nil && item.Deleted != nil {
+				// The feed may not list a deleted folder's children.
+				return ingest.SourceChanges{}, fmt.Errorf("%w: a folder was deleted", ingest.ErrFullSyncRequired)
+			}
+			if id == "" || item.Folder != nil {
+				continue
+			}
+			if _, ok := deleted[id]; !ok {
+				order = append(order, id)
+			}
+			deleted[id] = item.Deleted != nil
+		}
+		if len(order) > maxGraphChanges {
+			return ingest.SourceChanges{}, fmt.Errorf("%w: more than %d changed items", ingest.ErrFullSyncRequired, maxGraphChanges)
+		}
+		out.Cursor = strings.TrimSpace(page.DeltaLink)
+		endpoint = strings.TrimSpace(page.NextLink)
+	}
+	if out.Cursor == "" {
+		return ingest.SourceChanges{}, fmt.Errorf("microsoft graph delta response has no delta link")
+	}
+
+	scopes, err := sourcepath.NormalizeScopes(cfg.RootPath, cfg.ScopePaths)
+	if err != nil {
+		return ingest.SourceChanges{}, err
+	}
+	for _, id := range order {
+		if deleted[id] {
+			out.Removed = append(out.Removed, id)
+			continue
+		}
+		// Delta responses omit parentReference.path, so each changed item is
+		// fetched to place it.
+		item, err := graph.getItem(ctx, id)
+		if err != nil {
+			if isGraphStatus(err, http.StatusNotFound) {
+				out.Removed = append(out.Removed, id)
+				continue
+			}
+			return ingest.SourceChanges{}, err
+		}
+		itemPath, ok := item.drivePath()
+		if !ok || item.Folder != nil || !withinScopes(scopes, itemPath) {
+			out.Removed = append(out.Removed, id)
+			continue
+		}
+		file := microsoftItemToSourceFile(item.microsoftDriveItem, itemPath)
+		if len(filterSourceFilesBySelectedPaths([]ingest.SourceFile{file}, cfg.SelectedPaths)) == 0 {
+			out.Removed = append(out.Removed, id)
+			continue
+		}
+		out.Files = append(out.Files, file)
+	}
+	return out, nil
+}
+
+func (p *sourceProvider) graphClient(ctx context.Context, src domain.Source) (*microsoftGraphClient, domain.MicrosoftConfig, error) {
+	var cfg domain.MicrosoftConfig
+	if err := json.Unmarshal(src.Config, &cfg); err != nil {
+		return nil, cfg, fmt.Errorf("decode microsoft config: %w", err)
+	}
+	cfg = normalizeMicrosoftConfig(cfg)
+	graph, err := newMicrosoftGraphClient(ctx, p.httpClient, cfg)
+	if err != nil {
+		return nil, cfg, err
+	}
+	return graph, cfg, nil
+}
+
+// latestDeltaLink returns a delta link for the current state of the drive
+// without enumerating it.
+func (g *microsoftGraphClient) latestDeltaLink(ctx context.Context) (string, error) {
+	endpoint := fmt.Sprintf("%s/drives/%s/root/delta?token=latest", microsoftGraphBaseURL, url.PathEscape(g.driveID))
+	var page struct {
+		DeltaLink string `json:"@odata.deltaLink"`
+	}
+	if err := g.getJSON(ctx, endpoint, &page); err != nil {
+		return "", err
+	}
+	if strings.TrimSpace(page.DeltaLink) == "" {
+		return "", fmt.Errorf("microsoft graph delta response has no delta link")
+	}
+	return strings.TrimSpace(page.DeltaLink), nil
+}
+
+func (g *microsoftGraphClient) getItem(ctx context.Context, itemID string) (graphDeltaItem, error) {
+	endpoint := fmt.Sprintf(
+		"%s/drives/%s/items/%s?$select=id,name,webUrl,lastModifiedDateTime,eTag,size,file,folder,parentReference",
+		microsoftGraphBaseURL,
+		url.PathEscape(g.driveID),
+		url.PathEscape(itemID),
+	)
+	var item graphDeltaItem
+	if err := g.getJSON(ctx, endpoint, &item); err != nil {
+		return graphDeltaItem{}, err
+	}
+	return item, nil
+}
+
+type graphDeltaItem struct {
+	microsoftDriveItem
+	Deleted         *struct{} `json:"deleted"`
+	ParentReference *struct {
+		Path string `json:"path"`
+	} `json:"parentReference"`
+}
+
+// drivePath returns the item's path relative to the drive root, the form
+// ListFiles uses as ExternalRef.
+func (i graphDeltaItem) drivePath() (string, bool) {
+	if i.ParentReference == nil {
+		return "", false
+	}
+	parent := i.ParentReference.Path
+	idx := strings.Index(parent, "root:")
+	if idx < 0 {
+		return "", false
+	}
+	parent = parent[idx+len("root:"):]
+	if unescaped, err := url.PathUnescape(parent); err == nil {
+		parent = unescaped
+	}
+	return sourcepath.Normalize(path.Join(parent, i.Name)), true
+}
+
+func withinScopes(scopes []string, itemPath string) bool {
+	for _, scope := range scopes {
+		if sourcepath.IsWithinRoot(scope, itemPath) {
+			return true
+		}
+	}
+	return false
+}
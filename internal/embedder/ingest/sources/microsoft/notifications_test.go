@@ -0,0 +1,170 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package microsoft_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/microsoft"
+)
+
+const graphDeltaPath = "/v1.0/drives/drv1/root/delta"
+
+func TestMicrosoftSubscribe_RegistersSubscriptionAtLatestDelta(t *testing.T) {
+	var created map[string]any
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == graphDeltaPath && r.URL.Query().Get("token") == "latest":
+			_, _ = io.WriteString(w, `{"value":[],"@odata.deltaLink":"https://graph.microsoft.com/v1.0/drives/drv1/root/delta?token=t1"}`)
+		case r.Method == http.MethodPost && r.URL.Path == "/v1.0/subscriptions":
+			if err := json.NewDecoder(r.Body).Decode(&created); err != nil {
+				t.Errorf("decode subscription: %v", err)
+			}
+			_, _ = io.WriteString(w, `{"id":"graph-sub-1","expirationDateTime":"2026-11-15T00:00:00Z"}`)
+		default:
+			http.Error(w, `{"error":{"message":"unexpected"}}`, http.StatusNotFound)
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.String())
+		}
+	}))
+	defer srv.Close()
+
+	notifier := newMicrosoftNotifier(t, srv.URL)
+	sub, err := notifier.Subscribe(context.Background(), microsoftNotifierSource(t, nil), domain.SourceSubscription{
+		ID:          "sub-1",
+		ClientState: "secret",
+	}, "https://cube.example/api/v1/notifications/sub-1")
+	if err != nil {
+		t.Fatalf("Subscribe returned error: %v", err)
+	}
+	if sub.ExternalID != "graph-sub-1" || sub.Resource != "drv1" || sub.ExpiresAt == nil {
+		t.Fatalf("unexpected subscription: %#v", sub)
+	}
+	if sub.Cursor != "https://graph.microsoft.com/v1.0/drives/drv1/root/delta?token=t1" {
+		t.Fatalf("unexpected cursor: %q", sub.Cursor)
+	}
+	if created["resource"] != "drives/drv1/root" || created["clientState"] != "secret" ||
+		created["notificationUrl"] != "https://cube.example/api/v1/notifications/sub-1" {
+		t.Fatalf("unexpected subscription request: %#v", created)
+	}
+}
+
+func TestMicrosoftChanges_ReadsDeltaWithinScope(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == graphDeltaPath && r.URL.Query().Get("token") == "t1":
+			_, _ = io.WriteString(w, `{
+				"value":[
+					{"id":"file-in","name":"a.txt","file":{"mimeType":"text/plain"}},
+					{"id":"file-out","name":"b.txt","file":{"mimeType":"text/plain"}},
+					{"id":"file-gone","name":"c.txt","deleted":{},"file":{}}
+				],
+				"@odata.nextLink":"https://graph.microsoft.com/v1.0/drives/drv1/root/delta?token=t1b"
+			}`)
+		case r.URL.Path == graphDeltaPath && r.URL.Query().Get("token") == "t1b":
+			_, _ = io.WriteString(w, `{
+				"value":[{"id":"file-in","name":"a.txt","eTag":"v2","file":{"mimeType":"text/plain"}}],
+				"@odata.deltaLink":"https://graph.microsoft.com/v1.0/drives/drv1/root/delta?token=t2"
+			}`)
+		case r.URL.Path == "/v1.0/drives/drv1/items/file-in":
+			_, _ = io.WriteString(w, `{
+				"id":"file-in","name":"a.txt","eTag":"v2","file":{"mimeType":"text/plain"},
+				"parentReference":{"path":"/drives/drv1/root:/team/docs"}
+			}`)
+		case r.URL.Path == "/v1.0/drives/drv1/items/file-out":
+			_, _ = io.WriteString(w, `{
+				"id":"file-out","name":"b.txt","file":{"mimeType":"text/plain"},
+				"parentReference":{"path":"/drives/drv1/root:/private"}
+			}`)
+		default:
+			http.Error(w, `{"error":{"message":"unexpected"}}`, http.StatusNotFound)
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.String())
+		}
+	}))
+	defer srv.Close()
+
+	notifier := newMicrosoftNotifier(t, srv.URL)
+	changes, err := notifier.Changes(
+		context.Background(),
+		microsoftNotifierSource(t, []string{"team/docs"}),
+		domain.SourceSubscription{ID: "sub-1", Cursor: "https://graph.microsoft.com/v1.0/drives/drv1/root/delta?token=t1"},
+		domain.SourceChangeHint{},
+	)
+	if err != nil {
+		t.Fatalf("Changes returned error: %v", err)
+	}
+	if changes.Cursor != "https://graph.microsoft.com/v1.0/drives/drv1/root/delta?token=t2" {
+		t.Fatalf("unexpected cursor: %q", changes.Cursor)
+	}
+	if len(changes.Files) != 1 || changes.Files[0].ExternalID != "file-in" ||
+		changes.Files[0].ExternalRef != "team/docs/a.txt" || changes.Files[0].SourceVersion != "v2" {
+		t.Fatalf("unexpected changed files: %#v", changes.Files)
+	}
+	if want := []string{"file-out", "file-gone"}; !reflect.DeepEqual(changes.Removed, want) {
+		t.Fatalf("removed = %v, want %v", changes.Removed, want)
+	}
+}
+
+func TestMicrosoftChanges_ExpiredDeltaRequiresFullSync(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"error":{"code":"resyncRequired","message":"resync"}}`, http.StatusGone)
+	}))
+	defer srv.Close()
+
+	notifier := newMicrosoftNotifier(t, srv.URL)
+	_, err := notifier.Changes(
+		context.Background(),
+		microsoftNotifierSource(t, nil),
+		domain.SourceSubscription{ID: "sub-1", Cursor: "https://graph.microsoft.com/v1.0/drives/drv1/root/delta?token=old"},
+		domain.SourceChangeHint{},
+	)
+	if !errors.Is(err, ingest.ErrFullSyncRequired) {
+		t.Fatalf("expected ErrFullSyncRequired, got %v", err)
+	}
+}
+
+func TestMicrosoftParseNotification_ChecksClientState(t *testing.T) {
+	notifier := newMicrosoftNotifier(t, "http://127.0.0.1")
+	sub := domain.SourceSubscription{ID: "sub-1", ExternalID: "graph-sub-1", ClientState: "secret"}
+
+	body := []byte(`{"value":[{"subscriptionId":"graph-sub-1","clientState":"secret"}]}`)
+	if _, changed, err := notifier.ParseNotification(sub, domain.ChangeNotification{Body: body}); err != nil || !changed {
+		t.Fatalf("expected a change, got changed=%v err=%v", changed, err)
+	}
+
+	forged := []byte(`{"value":[{"subscriptionId":"graph-sub-1","clientState":"guess"}]}`)
+	if _, _, err := notifier.ParseNotification(sub, domain.ChangeNotification{Body: forged}); !errors.Is(err, domain.ErrInvalidNotification) {
+		t.Fatalf("expected ErrInvalidNotification, got %v", err)
+	}
+}
+
+func newMicrosoftNotifier(t *testing.T, serverURL string) ingest.ChangeNotifier {
+	t.Helper()
+	notifier, ok := microsoft.NewSourceProviderWithHTTPClient(newGraphRedirectHTTPClient(t, serverURL)).(ingest.ChangeNotifier)
+	if !ok {
+		t.Fatalf("microsoft provider does not implement ChangeNotifier")
+	}
+	return notifier
+}
+
+func microsoftNotifierSource(t *testing.T, scopes []string) domain.Source {
+	t.Helper()
+	raw, err := json.Marshal(domain.MicrosoftConfig{
+		AccessToken: "token",
+		DriveID:     "drv1",
+		ScopePaths:  scopes,
+	})
+	if err != nil {
+		t.Fatalf("marshal microsoft config: %v", err)
+	}
+	return domain.Source{ID: "src-1", Type: domain.SourceTypeMicrosoft, Config: raw}
+}
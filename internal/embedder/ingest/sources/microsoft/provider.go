@@ -4,8 +4,10 @@
 package microsoft
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -101,6 +103,31 @@ func (p *sourceProvider) DownloadRecordContent(
 	rec domain.Record,
 	src domain.Source,
 ) ([]byte, error) {
+	graph, err := p.recordGraphClient(ctx, rec, src)
+	if err != nil {
+		return nil, err
+	}
+	return graph.DownloadContent(ctx, strings.TrimSpace(rec.ExternalID))
+}
+
+func (p *sourceProvider) OpenRecordContent(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) (io.ReadCloser, error) {
+	graph, err := p.recordGraphClient(ctx, rec, src)
+	if err != nil {
+		return nil, err
+	}
+	return graph.OpenContent(ctx, strings.TrimSpace(rec.ExternalID))
+}
+
+// recordGraphClient returns a Graph client for the drive rec belongs to.
+func (p *sourceProvider) recordGraphClient(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) (*microsoftGraphClient, error) {
 	if strings.TrimSpace(rec.ExternalID) == "" {
 		return nil, fmt.Errorf("record %s is missing external_id", rec.ID)
 	}
@@ -109,17 +136,7 @@ func (p *sourceProvider) DownloadRecordContent(
 	if err := json.Unmarshal(src.Config, &cfg); err != nil {
 		return nil, fmt.Errorf("decode microsoft config: %w", err)
 	}
-
-	graph, err := newMicrosoftGraphClient(ctx, p.httpClient, cfg)
-	if err != nil {
-		return nil, err
-	}
-
-	body, err := graph.DownloadContent(ctx, strings.TrimSpace(rec.ExternalID))
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return newMicrosoftGraphClient(ctx, p.httpClient, cfg)
 }
 
 // MicrosoftBrowseEntry is a normalized folder/file entry returned by browse previews.
@@ -432,6 +449,26 @@ func (g *microsoftGraphClient) ListChildren(ctx context.Context, parentID string
 }
 
 func (g *microsoftGraphClient) DownloadContent(ctx context.Context, itemID string) ([]byte, error) {
+	body, err := g.OpenContent(ctx, itemID)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+
+	lim := &io.LimitedReader{R: body, N: driveFileMaxBytes + 1}
+	content, err := io.ReadAll(lim)
+	if err != nil {
+		return nil, fmt.Errorf("microsoft download read: %w", err)
+	}
+	if int64(len(content)) > driveFileMaxBytes {
+		return nil, fmt.Errorf("microsoft file too large: %d bytes (max %d)", len(content), driveFileMaxBytes)
+	}
+	return content, nil
+}
+
+// OpenContent returns the body of an item's content unread and without
+// DownloadContent's size limit. The caller closes it.
+func (g *microsoftGraphClient) OpenContent(ctx context.Context, itemID string) (io.ReadCloser, error) {
 	itemID = strings.TrimSpace(itemID)
 	if itemID == "" {
 		return nil, fmt.Errorf("microsoft item id is required")
@@ -452,29 +489,37 @@ func (g *microsoftGraphClient) DownloadContent(ctx context.Context, itemID strin
 	if err != nil {
 		return nil, fmt.Errorf("microsoft download: %w", err)
 	}
-	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("microsoft download status %d: %s", resp.StatusCode, body)
+		return nil, &domain.StatusError{Op: "microsoft download", StatusCode: resp.StatusCode, Message: string(body)}
 	}
-
-	lim := &io.LimitedReader{R: resp.Body, N: driveFileMaxBytes + 1}
-	body, err := io.ReadAll(lim)
-	if err != nil {
-		return nil, fmt.Errorf("microsoft download read: %w", err)
-	}
-	if int64(len(body)) > driveFileMaxBytes {
-		return nil, fmt.Errorf("microsoft file too large: %d bytes (max %d)", len(body), driveFileMaxBytes)
-	}
-	return body, nil
+	return resp.Body, nil
 }
 
 func (g *microsoftGraphClient) getJSON(ctx context.Context, reqURL string, out interface{}) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, http.NoBody)
+	return g.doJSON(ctx, http.MethodGet, reqURL, nil, out)
+}
+
+// doJSON sends in as a JSON body, when set, and decodes the response into
+// out, when set. Non-2xx responses return a *domain.StatusError.
+func (g *microsoftGraphClient) doJSON(ctx context.Context, method, reqURL string, in, out interface{}) error {
+	var reqBody io.Reader = http.NoBody
+	if in != nil {
+		raw, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		reqBody = bytes.NewReader(raw)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, reqURL, reqBody)
 	if err != nil {
 		return err
 	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
 	resp, err := g.httpClient.Do(req)
 	if err != nil {
 		return err
@@ -486,7 +531,10 @@ func (g *microsoftGraphClient) getJSON(ctx context.Context, reqURL string, out i
 		return err
 	}
 	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
-		return fmt.Errorf("microsoft graph status %d: %s", resp.StatusCode, microsoftGraphErrorMessage(body))
+		return &domain.StatusError{Op: "microsoft graph", StatusCode: resp.StatusCode, Message: microsoftGraphErrorMessage(body)}
+	}
+	if out == nil {
+		return nil
 	}
 	if err := json.Unmarshal(body, out); err != nil {
 		return fmt.Errorf("decode microsoft graph response: %w", err)
@@ -494,6 +542,11 @@ func (g *microsoftGraphClient) getJSON(ctx context.Context, reqURL string, out i
 	return nil
 }
 
+func isGraphStatus(err error, status int) bool {
+	var statusErr *domain.StatusError
+	return errors.As(err, &statusErr) && statusErr.StatusCode == status
+}
+
 func microsoftGraphErrorMessage(body []byte) string {
 	msg := strings.TrimSpace(string(body))
 	var parsed struct {
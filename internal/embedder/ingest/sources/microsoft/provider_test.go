@@ -14,6 +14,7 @@ import (
 	"testing"
 
 	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
 	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/microsoft"
 )
 
@@ -136,6 +137,23 @@ func TestMicrosoftSourceProvider_ListAndDownload_Smoke(t *testing.T) {
 	if pageCount != nil {
 		t.Fatalf("expected nil page count for text file, got %v", *pageCount)
 	}
+
+	streamer, ok := provider.(ingest.StreamContentProvider)
+	if !ok {
+		t.Fatal("expected provider to stream content")
+	}
+	body, err := streamer.OpenRecordContent(context.Background(), domain.Record{ID: "rec-1", ExternalID: "file-sub"}, src)
+	if err != nil {
+		t.Fatalf("OpenRecordContent returned error: %v", err)
+	}
+	defer body.Close()
+	content, err := io.ReadAll(body)
+	if err != nil {
+		t.Fatalf("read streamed content: %v", err)
+	}
+	if string(content) != "hello microsoft source" {
+		t.Fatalf("unexpected streamed content: %q", content)
+	}
 }
 
 func newGraphRedirectHTTPClient(t *testing.T, serverURL string) *http.Client {
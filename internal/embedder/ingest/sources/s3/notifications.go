@@ -0,0 +1,224 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/notification"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest/sourcepath"
+)
+
+// maxS3ChangedKeys bounds a targeted sync; larger event batches fall back to
+// a full sync.
+const maxS3ChangedKeys = 1000
+
+var _ ingest.ChangeNotifier = (*sourceProvider)(nil)
+
+// Subscribe records the source's bucket as the subscription resource. Bucket
+// events are delivered to the shared S3 notification endpoint, so the
+// callback URL is not used. When notification_arn is set it is added to the
+// bucket's notification configuration for object created and removed events.
+func (p *sourceProvider) Subscribe(
+	ctx context.Context,
+	src domain.Source,
+	sub domain.SourceSubscription,
+	_ string,
+) (domain.SourceSubscription, error) {
+	var cfg domain.S3Config
+	if err := json.Unmarshal(src.Config, &cfg); err != nil {
+		return sub, fmt.Errorf("decode s3 config: %w", err)
+	}
+	bucket := strings.TrimSpace(cfg.Bucket)
+	if bucket == "" {
+		return sub, fmt.Errorf("s3 bucket is required")
+	}
+	if arn := strings.TrimSpace(cfg.NotificationARN); arn != "" {
+		if err := ensureBucketNotification(ctx, cfg, bucket, arn); err != nil {
+			return sub, err
+		}
+	}
+	sub.Resource = bucket
+	return sub, nil
+}
+
+func ensureBucketNotification(ctx context.Context, cfg domain.S3Config, bucket, rawARN string) error {
+	arn, err := notification.NewArnFromString(rawARN)
+	if err != nil {
+		return fmt.Errorf("s3 notification_arn: %w", err)
+	}
+	client, err := newS3Client(cfg)
+	if err != nil {
+		return err
+	}
+	current, err := client.GetBucketNotification(ctx, bucket)
+	if err != nil {
+		return fmt.Errorf("get s3 bucket notification: %w", err)
+	}
+	target := arn.String()
+	for _, q := range current.QueueConfigs {
+		if q.Queue == target {
+			return nil
+		}
+	}
+	for _, t := range current.TopicConfigs {
+		if t.Topic == target {
+			return nil
+		}
+	}
+
+	events := notification.NewConfig(arn)
+	events.AddEvents(notification.ObjectCreatedAll, notification.ObjectRemovedAll)
+	switch arn.Service {
+	case "sqs":
+		current.AddQueue(events)
+	case "sns":
+		current.AddTopic(events)
+	default:
+		return fmt.Errorf("s3 notification_arn must name an sqs or sns target, got %q", arn.Service)
+	}
+	if err := client.SetBucketNotification(ctx, bucket, current); err != nil {
+		return fmt.Errorf("set s3 bucket notification: %w", err)
+	}
+	return nil
+}
+
+// Renew is a no-op: bucket notifications do not expire.
+func (p *sourceProvider) Renew(
+	_ context.Context,
+	_ domain.Source,
+	sub domain.SourceSubscription,
+	_ string,
+) (domain.SourceSubscription, error) {
+	return sub, nil
+}
+
+// Unsubscribe leaves the bucket's notification configuration alone; other
+// sources may share the bucket.
+func (p *sourceProvider) Unsubscribe(context.Context, domain.Source, domain.SourceSubscription) error {
+	return nil
+}
+
+// ParseNotification returns the object keys of sub's bucket named by an S3
+// event notification. The shared endpoint authenticates the request, so no
+// subscription token is checked here.
+func (p *sourceProvider) ParseNotification(
+	sub domain.SourceSubscription,
+	n domain.ChangeNotification,
+) (domain.SourceChangeHint, bool, error) {
+	var event struct {
+		Records []struct {
+			S3 struct {
+				Bucket struct {
+					Name string `json:"name"`
+				} `json:"bucket"`
+				Object struct {
+					Key string `json:"key"`
+				} `json:"object"`
+			} `json:"s3"`
+		} `json:"Records"`
+	}
+	if err := json.Unmarshal(n.Body, &event); err != nil {
+		return domain.SourceChangeHint{}, false, fmt.Errorf("%w: decode s3 event: %v", domain.ErrInvalidNotification, err)
+	}
+
+	var hint domain.SourceChangeHint
+	for _, rec := range event.Records {
+		if rec.S3.Bucket.Name != sub.Resource {
+			continue
+		}
+		// Keys are URL-encoded in event payloads.
+		key, err := url.QueryUnescape(rec.S3.Object.Key)
+		if err != nil {
+			key = rec.S3.Object.Key
+		}
+		if key = sourcepath.Normalize(key); key != "" {
+			hint.ExternalIDs = append(hint.ExternalIDs, key)
+		}
+	}
+	return hint, len(hint.ExternalIDs) > 0, nil
+}
+
+// Changes stats the keys named by hint. Keys outside the source's scopes and
+// selection are ignored; keys that no longer exist are removed. The event
+// type is not trusted, so a late "removed" event cannot drop an object that
+// was written again.
+func (p *sourceProvider) Changes(
+	ctx context.Context,
+	src domain.Source,
+	_ domain.SourceSubscription,
+	hint domain.SourceChangeHint,
+) (ingest.SourceChanges, error) {
+	if len(hint.ExternalIDs) > maxS3ChangedKeys {
+		return ingest.SourceChanges{}, fmt.Errorf("%w: more than %d changed keys", ingest.ErrFullSyncRequired, maxS3ChangedKeys)
+	}
+	var cfg domain.S3Config
+	if err := json.Unmarshal(src.Config, &cfg); err != nil {
+		return ingest.SourceChanges{}, fmt.Errorf("decode s3 config: %w", err)
+	}
+	scopes, err := sourcepath.NormalizeScopes(cfg.RootPath, cfg.ScopePaths)
+	if err != nil {
+		return ingest.SourceChanges{}, err
+	}
+	selected := sourcepath.NormalizeList(cfg.SelectedPaths)
+
+	var out ingest.SourceChanges
+	if len(hint.ExternalIDs) == 0 {
+		return out, nil
+	}
+	client, err := newS3Client(cfg)
+	if err != nil {
+		return ingest.SourceChanges{}, err
+	}
+	bucket := strings.TrimSpace(cfg.Bucket)
+	seen := make(map[string]struct{}, len(hint.ExternalIDs))
+	for _, key := range hint.ExternalIDs {
+		key = sourcepath.Normalize(key)
+		if _, ok := seen[key]; ok || key == "" {
+			continue
+		}
+		seen[key] = struct{}{}
+		if !withinScopes(scopes, key) || (len(selected) > 0 && !sourcepath.SelectionContains(selected, key)) {
+			continue
+		}
+
+		stat, err := client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+		if err != nil {
+			if minio.ToErrorResponse(err).StatusCode == http.StatusNotFound {
+				out.Removed = append(out.Removed, key)
+				continue
+			}
+			return ingest.SourceChanges{}, fmt.Errorf("stat s3 object %q: %w", key, err)
+		}
+		modified := stat.LastModified.UTC()
+		name := path.Base(key)
+		out.Files = append(out.Files, ingest.SourceFile{
+			ExternalID:       key,
+			Name:             name,
+			ExternalRef:      key,
+			MimeType:         s3ObjectMIMEType(ctx, client, bucket, key, name, ""),
+			SourceVersion:    strings.TrimSpace(stat.ETag),
+			SourceModifiedAt: &modified,
+		})
+	}
+	return out, nil
+}
+
+func withinScopes(scopes []string, key string) bool {
+	for _, scope := range scopes {
+		if sourcepath.IsWithinRoot(scope, key) {
+			return true
+		}
+	}
+	return false
+}
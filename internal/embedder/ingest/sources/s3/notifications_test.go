@@ -0,0 +1,99 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package s3_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	s3source "github.com/ultravioletrs/cube/internal/embedder/ingest/sources/s3"
+)
+
+func TestS3ParseNotification_KeepsKeysOfSubscribedBucket(t *testing.T) {
+	notifier := s3source.NewSourceProvider().(ingest.ChangeNotifier)
+	body := []byte(`{"Records":[
+		{"s3":{"bucket":{"name":"docs"},"object":{"key":"team/docs/q3+report.txt"}}},
+		{"s3":{"bucket":{"name":"other"},"object":{"key":"team/docs/x.txt"}}},
+		{"s3":{"bucket":{"name":"docs"},"object":{"key":"team/docs/sub%2Fb.txt"}}}
+	]}`)
+
+	hint, changed, err := notifier.ParseNotification(
+		domain.SourceSubscription{ID: "sub-1", Resource: "docs"},
+		domain.ChangeNotification{Body: body},
+	)
+	if err != nil || !changed {
+		t.Fatalf("expected a change, got changed=%v err=%v", changed, err)
+	}
+	if want := []string{"team/docs/q3 report.txt", "team/docs/sub/b.txt"}; !reflect.DeepEqual(hint.ExternalIDs, want) {
+		t.Fatalf("keys = %v, want %v", hint.ExternalIDs, want)
+	}
+
+	_, changed, err = notifier.ParseNotification(
+		domain.SourceSubscription{ID: "sub-2", Resource: "unrelated"},
+		domain.ChangeNotification{Body: body},
+	)
+	if err != nil || changed {
+		t.Fatalf("expected no change for another bucket, got changed=%v err=%v", changed, err)
+	}
+}
+
+func TestS3Changes_StatsNamedKeys(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead && r.URL.Path == "/docs/team/docs/a.txt":
+			w.Header().Set("Last-Modified", "Tue, 12 May 2026 11:00:00 GMT")
+			w.Header().Set("ETag", `"etag-a"`)
+			w.Header().Set("Content-Type", "text/plain")
+			w.Header().Set("Content-Length", "5")
+		case r.Method == http.MethodHead && r.URL.Path == "/docs/team/docs/gone.txt":
+			w.WriteHeader(http.StatusNotFound)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.String())
+		}
+	}))
+	defer srv.Close()
+
+	srvURL, err := url.Parse(srv.URL)
+	if err != nil {
+		t.Fatalf("parse httptest server url: %v", err)
+	}
+	cfgRaw, err := json.Marshal(domain.S3Config{
+		Endpoint:        srvURL.Host,
+		Region:          "us-east-1",
+		Bucket:          "docs",
+		AccessKeyID:     "test-access",
+		SecretAccessKey: "test-secret",
+		UseSSL:          testBoolPtr(false),
+		PathStyle:       testBoolPtr(true),
+		RootPath:        "team/docs",
+	})
+	if err != nil {
+		t.Fatalf("marshal config: %v", err)
+	}
+
+	notifier := s3source.NewSourceProvider().(ingest.ChangeNotifier)
+	changes, err := notifier.Changes(
+		context.Background(),
+		domain.Source{ID: "src-s3-1", Type: domain.SourceTypeS3, Config: cfgRaw},
+		domain.SourceSubscription{ID: "sub-1", Resource: "docs"},
+		domain.SourceChangeHint{ExternalIDs: []string{"team/docs/a.txt", "private/secret.txt", "team/docs/gone.txt", "team/docs/a.txt"}},
+	)
+	if err != nil {
+		t.Fatalf("Changes returned error: %v", err)
+	}
+	if len(changes.Files) != 1 || changes.Files[0].ExternalID != "team/docs/a.txt" || changes.Files[0].SourceVersion != "etag-a" {
+		t.Fatalf("unexpected changed files: %#v", changes.Files)
+	}
+	if want := []string{"team/docs/gone.txt"}; !reflect.DeepEqual(changes.Removed, want) {
+		t.Fatalf("removed = %v, want %v", changes.Removed, want)
+	}
+}
@@ -81,6 +81,19 @@ func (p *sourceProvider) DownloadRecordContent(
 	rec domain.Record,
 	src domain.Source,
 ) ([]byte, error) {
+	obj, err := p.OpenRecordContent(ctx, rec, src)
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
+
+func (p *sourceProvider) OpenRecordContent(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) (io.ReadCloser, error) {
 	if rec.ExternalID == "" {
 		return nil, fmt.Errorf("record %s is missing external_id", rec.ID)
 	}
@@ -95,17 +108,7 @@ func (p *sourceProvider) DownloadRecordContent(
 		return nil, err
 	}
 	bucket := strings.TrimSpace(cfg.Bucket)
-	obj, err := client.GetObject(ctx, bucket, sourcepath.Normalize(rec.ExternalID), minio.GetObjectOptions{})
-	if err != nil {
-		return nil, err
-	}
-	defer obj.Close()
-
-	body, err := io.ReadAll(obj)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return client.GetObject(ctx, bucket, sourcepath.Normalize(rec.ExternalID), minio.GetObjectOptions{})
 }
 
 // S3BrowseEntry is a normalized object/prefix entry returned by browse previews.
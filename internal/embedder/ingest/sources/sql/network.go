@@ -0,0 +1,70 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+)
+
+// CheckDSN returns an error when guard refuses a host the DSN of the given
+// driver connects to.
+func CheckDSN(ctx context.Context, guard *ingest.NetworkGuard, driver, dsn string) error {
+	hosts, err := dsnHosts(driver, dsn)
+	if err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		if err := guard.CheckHost(ctx, host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dsnHosts returns the hosts a DSN connects to. Unix sockets are refused,
+// since they only reach the embedder's own machine.
+func dsnHosts(driver, dsn string) ([]string, error) {
+	switch driver {
+	case "postgres":
+		cfg, err := pgconn.ParseConfig(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("parse sql dsn: %w", err)
+		}
+		hosts := []string{cfg.Host}
+		for _, fb := range cfg.Fallbacks {
+			hosts = append(hosts, fb.Host)
+		}
+		for _, host := range hosts {
+			if strings.HasPrefix(host, "/") {
+				return nil, fmt.Errorf("%w: unix socket %s", domain.ErrAddressNotAllowed, host)
+			}
+		}
+		return hosts, nil
+	case "mysql":
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, fmt.Errorf("parse sql dsn: %w", err)
+		}
+		switch cfg.Net {
+		case "tcp", "tcp4", "tcp6":
+		default:
+			return nil, fmt.Errorf("%w: %s connections are not allowed", domain.ErrAddressNotAllowed, cfg.Net)
+		}
+		host, _, err := net.SplitHostPort(cfg.Addr)
+		if err != nil {
+			return nil, fmt.Errorf("parse sql dsn: %w", err)
+		}
+		return []string{host}, nil
+	default:
+		return nil, fmt.Errorf("unsupported sql driver %q", driver)
+	}
+}
@@ -0,0 +1,531 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+// Package sql indexes the rows of SQL queries. Each row becomes a record
+// rendered from a template over its columns and versioned by its
+// updated-at column.
+package sql
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+)
+
+const (
+	recordMimeType  = "text/plain"
+	defaultMaxRows  = 100000
+	maxOpenConns    = 2
+	connMaxIdleTime = 5 * time.Minute
+	// poolMaxIdleTime is how long an unused source pool is kept. Pools of
+	// sources deleted through another replica are closed this way.
+	poolMaxIdleTime = 30 * time.Minute
+)
+
+// ErrRowGone is returned when a record's row no longer matches its query.
+var ErrRowGone = errors.New("sql row no longer exists")
+
+// Dialect describes how to reach one kind of database.
+type Dialect struct {
+	// DriverName is the database/sql driver to open.
+	DriverName string
+	// Placeholder is the bind parameter syntax for a single argument.
+	Placeholder string
+}
+
+// DefaultDialects returns the databases a source's driver may name.
+func DefaultDialects() map[string]Dialect {
+	return map[string]Dialect{
+		"postgres": {DriverName: "pgx", Placeholder: "$1"},
+		"mysql":    {DriverName: "mysql", Placeholder: "?"},
+	}
+}
+
+var columnName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+type sourceProvider struct {
+	dialects map[string]Dialect
+	// guard restricts which hosts the default dialects may connect to. It
+	// is nil for custom dialects.
+	guard *ingest.NetworkGuard
+
+	mu    sync.Mutex
+	pools map[string]*pool
+}
+
+// pool is a source's connection pool, reopened when its DSN changes.
+type pool struct {
+	driver string
+	dsn    string
+	db     *sql.DB
+	// users counts the calls using db; lastUsed is when the last one ended.
+	users    int
+	lastUsed time.Time
+}
+
+// NewSourceProvider creates the SQL provider for Postgres and MySQL. Its
+// connections are refused when policy does not allow the database host.
+func NewSourceProvider(policy domain.SourceNetworkPolicy) ingest.SourceProvider {
+	return &sourceProvider{
+		dialects: DefaultDialects(),
+		guard:    ingest.NewNetworkGuard(policy),
+		pools:    make(map[string]*pool),
+	}
+}
+
+// NewSourceProviderWithDialects creates the SQL provider for the given
+// drivers, keyed by the name sources use in their config.
+func NewSourceProviderWithDialects(dialects map[string]Dialect) ingest.SourceProvider {
+	return &sourceProvider{dialects: dialects, pools: make(map[string]*pool)}
+}
+
+func (p *sourceProvider) Type() domain.SourceType {
+	return domain.SourceTypeSQL
+}
+
+func (p *sourceProvider) Capabilities() ingest.SourceProviderCapabilities {
+	return ingest.SourceProviderCapabilities{
+		SupportsList:     true,
+		SupportsDownload: true,
+		SupportsBrowse:   false,
+	}
+}
+
+func (p *sourceProvider) PrunesStaleRecords() bool {
+	return true
+}
+
+func (p *sourceProvider) ListFiles(
+	ctx context.Context,
+	_ string,
+	src domain.Source,
+) ([]ingest.SourceFile, error) {
+	cfg, err := decodeConfig(src.Config)
+	if err != nil {
+		return nil, err
+	}
+	db, _, done, err := p.open(src.ID, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	var files []ingest.SourceFile
+	for _, q := range cfg.Queries {
+		tmpl, err := parseTemplates(q)
+		if err != nil {
+			return nil, err
+		}
+		maxRows := q.MaxRows
+		if maxRows <= 0 {
+			maxRows = defaultMaxRows
+		}
+
+		seen := make(map[string]struct{})
+		err = query(ctx, db, q.Query, nil, maxRows, func(r row) error {
+			file, err := r.sourceFile(q, tmpl)
+			if err != nil {
+				return err
+			}
+			if _, ok := seen[file.ExternalID]; ok {
+				return fmt.Errorf("sql query %q returned id %q more than once", q.Name, r.string(q.IDColumn))
+			}
+			seen[file.ExternalID] = struct{}{}
+			files = append(files, file)
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("run sql query %q: %w", q.Name, err)
+		}
+	}
+	return files, nil
+}
+
+func (p *sourceProvider) DownloadRecord(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) (string, *int, error) {
+	content, err := p.DownloadRecordContent(ctx, rec, src)
+	if err != nil {
+		return "", nil, err
+	}
+	return string(content), nil, nil
+}
+
+// DownloadRecordContent renders the record's row, looked up by primary key.
+func (p *sourceProvider) DownloadRecordContent(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) ([]byte, error) {
+	cfg, err := decodeConfig(src.Config)
+	if err != nil {
+		return nil, err
+	}
+	name, id, ok := strings.Cut(rec.ExternalID, "/")
+	if !ok {
+		return nil, fmt.Errorf("invalid sql record id %q", rec.ExternalID)
+	}
+	var q *domain.SQLQuery
+	for i := range cfg.Queries {
+		if cfg.Queries[i].Name == name {
+			q = &cfg.Queries[i]
+			break
+		}
+	}
+	if q == nil {
+		return nil, ErrRowGone
+	}
+	tmpl, err := parseTemplates(*q)
+	if err != nil {
+		return nil, err
+	}
+	db, dialect, done, err := p.open(src.ID, cfg)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+
+	// Filtering the query as a subquery lets the database push the
+	// primary key lookup down to its index.
+	lookup := fmt.Sprintf("SELECT * FROM (%s) cube_rows WHERE %s = %s", q.Query, q.IDColumn, dialect.Placeholder)
+	var content string
+	found := false
+	err = query(ctx, db, lookup, []any{id}, 1, func(r row) error {
+		found = true
+		content, err = r.render(tmpl.content)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("run sql query %q: %w", q.Name, err)
+	}
+	if !found {
+		return nil, ErrRowGone
+	}
+	return []byte(content), nil
+}
+
+func decodeConfig(raw json.RawMessage) (domain.SQLConfig, error) {
+	var cfg domain.SQLConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return domain.SQLConfig{}, fmt.Errorf("decode sql config: %w", err)
+	}
+	if cfg.DSN == "" {
+		return domain.SQLConfig{}, fmt.Errorf("sql dsn is required")
+	}
+	for _, q := range cfg.Queries {
+		// Column names are interpolated into lookups; the service validates
+		// them too, but the provider must not trust stored configs.
+		for _, col := range []string{q.IDColumn, q.UpdatedAtColumn, q.TitleColumn} {
+			if col != "" && !columnName.MatchString(col) {
+				return domain.SQLConfig{}, fmt.Errorf("invalid column %q in sql query %q", col, q.Name)
+			}
+		}
+		if q.IDColumn == "" || q.Name == "" || strings.Contains(q.Name, "/") {
+			return domain.SQLConfig{}, fmt.Errorf("invalid sql query %q", q.Name)
+		}
+	}
+	return cfg, nil
+}
+
+// open returns the source's connection pool, replacing it when the driver
+// or DSN changed. The caller calls done once it no longer uses the pool.
+func (p *sourceProvider) open(sourceID string, cfg domain.SQLConfig) (*sql.DB, Dialect, func(), error) {
+	dialect, ok := p.dialects[cfg.Driver]
+	if !ok {
+		return nil, Dialect{}, nil, fmt.Errorf("unsupported sql driver %q", cfg.Driver)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.closeIdle(time.Now())
+	existing, ok := p.pools[sourceID]
+	if ok && (existing.driver != cfg.Driver || existing.dsn != cfg.DSN) {
+		existing.db.Close()
+		delete(p.pools, sourceID)
+		ok = false
+	}
+	if !ok {
+		db, err := p.connect(dialect, cfg.DSN)
+		if err != nil {
+			return nil, Dialect{}, nil, fmt.Errorf("open sql database: %w", err)
+		}
+		db.SetMaxOpenConns(maxOpenConns)
+		db.SetConnMaxIdleTime(connMaxIdleTime)
+		existing = &pool{driver: cfg.Driver, dsn: cfg.DSN, db: db}
+		p.pools[sourceID] = existing
+	}
+
+	existing.users++
+	done := func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		existing.users--
+		existing.lastUsed = time.Now()
+	}
+	return existing.db, dialect, done, nil
+}
+
+// closeIdle closes the pools nobody has used for poolMaxIdleTime. The
+// caller holds p.mu.
+func (p *sourceProvider) closeIdle(now time.Time) {
+	for id, pl := range p.pools {
+		if pl.users == 0 && now.Sub(pl.lastUsed) > poolMaxIdleTime {
+			pl.db.Close()
+			delete(p.pools, id)
+		}
+	}
+}
+
+// ReleaseSource closes the source's connection pool.
+func (p *sourceProvider) ReleaseSource(sourceID string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if pl, ok := p.pools[sourceID]; ok {
+		pl.db.Close()
+		delete(p.pools, sourceID)
+	}
+}
+
+// Close closes every connection pool.
+func (p *sourceProvider) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var errs []error
+	for id, pl := range p.pools {
+		errs = append(errs, pl.db.Close())
+		delete(p.pools, id)
+	}
+	return errors.Join(errs...)
+}
+
+// connect opens dsn, dialing through the guard when there is one.
+func (p *sourceProvider) connect(dialect Dialect, dsn string) (*sql.DB, error) {
+	if p.guard == nil {
+		return sql.Open(dialect.DriverName, dsn)
+	}
+	switch dialect.DriverName {
+	case "pgx":
+		cfg, err := pgx.ParseConfig(dsn)
+		if err != nil {
+			return nil, err
+		}
+		// Pass host names through to the dialer so the guard sees them.
+		cfg.LookupFunc = func(_ context.Context, host string) ([]string, error) {
+			return []string{host}, nil
+		}
+		cfg.DialFunc = p.guard.DialContext
+		return stdlib.OpenDB(*cfg), nil
+	case "mysql":
+		cfg, err := mysql.ParseDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		cfg.DialFunc = p.guard.DialContext
+		connector, err := mysql.NewConnector(cfg)
+		if err != nil {
+			return nil, err
+		}
+		return sql.OpenDB(connector), nil
+	default:
+		return nil, fmt.Errorf("sql driver %q does not support network policies", dialect.DriverName)
+	}
+}
+
+// query runs stmt in a read-only transaction and calls fn for each row,
+// failing once more than limit rows are returned.
+func query(ctx context.Context, db *sql.DB, stmt string, args []any, limit int, fn func(row) error) error {
+	tx, err := db.BeginTx(ctx, &sql.TxOptions{ReadOnly: true})
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() //nolint:errcheck // read-only
+
+	rows, err := tx.QueryContext(ctx, stmt, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	cols, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+	n := 0
+	for rows.Next() {
+		if n++; n > limit {
+			return fmt.Errorf("query returned more than %d rows", limit)
+		}
+		values := make([]any, len(cols))
+		ptrs := make([]any, len(cols))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return err
+		}
+		r := row{columns: cols, values: make(map[string]any, len(cols))}
+		for i, col := range cols {
+			if b, ok := values[i].([]byte); ok {
+				values[i] = string(b)
+			}
+			r.values[col] = values[i]
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+type templates struct {
+	content *template.Template
+	url     *template.Template
+}
+
+func parseTemplates(q domain.SQLQuery) (templates, error) {
+	var (
+		t   templates
+		err error
+	)
+	if q.ContentTemplate != "" {
+		if t.content, err = template.New("content").Option("missingkey=error").Parse(q.ContentTemplate); err != nil {
+			return templates{}, fmt.Errorf("parse content_template of sql query %q: %w", q.Name, err)
+		}
+	}
+	if q.URLTemplate != "" {
+		if t.url, err = template.New("url").Option("missingkey=error").Parse(q.URLTemplate); err != nil {
+			return templates{}, fmt.Errorf("parse url_template of sql query %q: %w", q.Name, err)
+		}
+	}
+	return t, nil
+}
+
+type row struct {
+	columns []string
+	values  map[string]any
+}
+
+// value looks a column up by name. Databases differ in how they case
+// unquoted names, so the match falls back to being case-insensitive.
+func (r row) value(name string) (any, bool) {
+	if v, ok := r.values[name]; ok {
+		return v, true
+	}
+	for _, col := range r.columns {
+		if strings.EqualFold(col, name) {
+			return r.values[col], true
+		}
+	}
+	return nil, false
+}
+
+func (r row) string(name string) string {
+	v, _ := r.value(name)
+	return formatValue(v)
+}
+
+func (r row) sourceFile(q domain.SQLQuery, tmpl templates) (ingest.SourceFile, error) {
+	id, ok := r.value(q.IDColumn)
+	if !ok {
+		return ingest.SourceFile{}, fmt.Errorf("id column %q is not in the result", q.IDColumn)
+	}
+	if id == nil {
+		return ingest.SourceFile{}, fmt.Errorf("id column %q is null", q.IDColumn)
+	}
+	pk := formatValue(id)
+
+	file := ingest.SourceFile{
+		ExternalID:  q.Name + "/" + pk,
+		Name:        q.Name + " " + pk,
+		ExternalRef: pk,
+		MimeType:    recordMimeType,
+		FolderPath:  "/" + q.Name,
+		FolderID:    q.Name,
+	}
+	if q.TitleColumn != "" {
+		if title := strings.TrimSpace(r.string(q.TitleColumn)); title != "" {
+			file.Name = title
+		}
+	}
+
+	if q.UpdatedAtColumn != "" {
+		updated, ok := r.value(q.UpdatedAtColumn)
+		if !ok {
+			return ingest.SourceFile{}, fmt.Errorf("updated_at column %q is not in the result", q.UpdatedAtColumn)
+		}
+		file.SourceVersion = formatValue(updated)
+		if t, ok := updated.(time.Time); ok {
+			t = t.UTC()
+			file.SourceModifiedAt = &t
+		}
+	}
+	if file.SourceVersion == "" {
+		content, err := r.render(tmpl.content)
+		if err != nil {
+			return ingest.SourceFile{}, err
+		}
+		sum := sha256.Sum256([]byte(content))
+		file.SourceVersion = hex.EncodeToString(sum[:16])
+	}
+
+	if tmpl.url != nil {
+		link, err := r.execute(tmpl.url)
+		if err != nil {
+			return ingest.SourceFile{}, err
+		}
+		file.ExternalURL = strings.TrimSpace(link)
+	}
+	return file, nil
+}
+
+// render executes the content template, or lists every column as
+// "name: value" when there is none.
+func (r row) render(tmpl *template.Template) (string, error) {
+	if tmpl != nil {
+		text, err := r.execute(tmpl)
+		return strings.TrimSpace(text), err
+	}
+	var b strings.Builder
+	for _, col := range r.columns {
+		if v := r.values[col]; v != nil {
+			fmt.Fprintf(&b, "%s: %s\n", col, formatValue(v))
+		}
+	}
+	return strings.TrimSpace(b.String()), nil
+}
+
+func (r row) execute(tmpl *template.Template) (string, error) {
+	var b strings.Builder
+	if err := tmpl.Execute(&b, r.values); err != nil {
+		return "", fmt.Errorf("render %s template: %w", tmpl.Name(), err)
+	}
+	return b.String(), nil
+}
+
+func formatValue(v any) string {
+	switch x := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return x.UTC().Format(time.RFC3339Nano)
+	default:
+		return fmt.Sprint(x)
+	}
+}
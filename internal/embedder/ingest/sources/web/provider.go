@@ -0,0 +1,570 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+// Package web crawls websites such as internal wikis and documentation sites.
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+const (
+	// DefaultUserAgent identifies the crawler to sites and to robots.txt.
+	DefaultUserAgent = "CubeEmbedder/1.0 (+https://github.com/ultravioletrs/cube)"
+
+	defaultMaxDepth = 3
+	defaultMaxPages = 500
+	defaultRPS      = 2
+	maxPageSize     = 20 << 20
+	requestTimeout  = 30 * time.Second
+)
+
+// ErrPageGone is returned when a page no longer exists.
+var ErrPageGone = errors.New("web page no longer exists")
+
+type sourceProvider struct {
+	client   *http.Client
+	robots   *robotsCache
+	throttle *hostThrottle
+}
+
+// NewSourceProvider creates the web crawler provider. Its connections,
+// including redirects, are refused when policy does not allow their
+// address.
+func NewSourceProvider(policy domain.SourceNetworkPolicy) ingest.SourceProvider {
+	return NewSourceProviderWithClient(ingest.NewNetworkGuard(policy).HTTPClient(requestTimeout))
+}
+
+// NewSourceProviderWithClient creates the web crawler provider with a custom
+// HTTP client.
+func NewSourceProviderWithClient(client *http.Client) ingest.SourceProvider {
+	return &sourceProvider{
+		client:   client,
+		robots:   newRobotsCache(),
+		throttle: newHostThrottle(),
+	}
+}
+
+func (p *sourceProvider) Type() domain.SourceType {
+	return domain.SourceTypeWeb
+}
+
+func (p *sourceProvider) Capabilities() ingest.SourceProviderCapabilities {
+	return ingest.SourceProviderCapabilities{
+		SupportsList:     true,
+		SupportsDownload: true,
+		SupportsBrowse:   false,
+	}
+}
+
+func (p *sourceProvider) PrunesStaleRecords() bool {
+	return true
+}
+
+func (p *sourceProvider) ListFiles(
+	ctx context.Context,
+	_ string,
+	src domain.Source,
+) ([]ingest.SourceFile, error) {
+	c, err := p.newCrawler(src)
+	if err != nil {
+		return nil, err
+	}
+	return c.crawl(ctx)
+}
+
+func (p *sourceProvider) DownloadRecord(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) (string, *int, error) {
+	body, contentType, err := p.fetchRecord(ctx, rec, src)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if isHTML(contentType) {
+		title, text := ingest.HTMLToText(body)
+		if title != "" && !strings.HasPrefix(text, title) {
+			text = title + "\n\n" + text
+		}
+		return text, nil, nil
+	}
+
+	doc, err := ingest.ExtractText(ingest.FileMeta{
+		ID:       rec.ExternalID,
+		Name:     rec.Name,
+		MimeType: contentType,
+	}, body)
+	if err != nil {
+		return "", nil, err
+	}
+	return doc.Text, doc.PageCount, nil
+}
+
+func (p *sourceProvider) DownloadRecordContent(
+	ctx context.Context,
+	rec domain.Record,
+	src domain.Source,
+) ([]byte, error) {
+	body, _, err := p.fetchRecord(ctx, rec, src)
+	return body, err
+}
+
+func (p *sourceProvider) fetchRecord(ctx context.Context, rec domain.Record, src domain.Source) ([]byte, string, error) {
+	c, err := p.newCrawler(src)
+	if err != nil {
+		return nil, "", err
+	}
+	target := rec.ExternalURL
+	if target == "" {
+		target = rec.ExternalID
+	}
+	u, err := canonicalURL(target)
+	if err != nil {
+		return nil, "", fmt.Errorf("record %s has invalid url: %w", rec.ID, err)
+	}
+	if !c.robotsAllowed(ctx, u) {
+		return nil, "", fmt.Errorf("%s is disallowed by robots.txt", u)
+	}
+
+	resp, err := c.get(ctx, u)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		return nil, "", fmt.Errorf("%w: %s", ErrPageGone, u)
+	case resp.StatusCode != http.StatusOK:
+		return nil, "", &domain.StatusError{Op: "fetch " + u.String(), StatusCode: resp.StatusCode}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return nil, "", fmt.Errorf("read %s: %w", u, err)
+	}
+	return body, mediaType(resp.Header.Get("Content-Type")), nil
+}
+
+// crawler holds the state of one crawl of a web source.
+type crawler struct {
+	p        *sourceProvider
+	cfg      domain.WebConfig
+	agent    string
+	interval time.Duration
+	hosts    map[string]struct{}
+}
+
+func (p *sourceProvider) newCrawler(src domain.Source) (*crawler, error) {
+	var cfg domain.WebConfig
+	if err := json.Unmarshal(src.Config, &cfg); err != nil {
+		return nil, fmt.Errorf("decode web config: %w", err)
+	}
+	if cfg.MaxDepth == nil {
+		depth := defaultMaxDepth
+		cfg.MaxDepth = &depth
+	}
+	if cfg.MaxPages <= 0 {
+		cfg.MaxPages = defaultMaxPages
+	}
+	if cfg.RequestsPerSecond <= 0 {
+		cfg.RequestsPerSecond = defaultRPS
+	}
+
+	c := &crawler{
+		p:        p,
+		cfg:      cfg,
+		agent:    cfg.UserAgent,
+		interval: time.Duration(float64(time.Second) / cfg.RequestsPerSecond),
+		hosts:    make(map[string]struct{}),
+	}
+	if c.agent == "" {
+		c.agent = DefaultUserAgent
+	}
+
+	hosts := cfg.AllowedHosts
+	if len(hosts) == 0 {
+		hosts = append(hosts, cfg.SeedURLs...)
+		if cfg.SitemapURL != "" {
+			hosts = append(hosts, cfg.SitemapURL)
+		}
+		for i, raw := range hosts {
+			if u, err := canonicalURL(raw); err == nil {
+				hosts[i] = u.Host
+			}
+		}
+	}
+	for _, host := range hosts {
+		c.hosts[strings.ToLower(strings.TrimSpace(host))] = struct{}{}
+	}
+	return c, nil
+}
+
+type queued struct {
+	url   *url.URL
+	depth int
+}
+
+// crawl walks the site breadth-first from the seeds and sitemap. Pages that
+// return 404/410 are left out so the sync prunes them; other failures abort
+// the crawl rather than pruning pages that are only temporarily unreachable.
+func (c *crawler) crawl(ctx context.Context) ([]ingest.SourceFile, error) {
+	var queue []queued
+	enqueue := func(raw string, depth int) {
+		u, err := canonicalURL(raw)
+		if err == nil && c.inScope(u) {
+			queue = append(queue, queued{url: u, depth: depth})
+		}
+	}
+	for _, seed := range c.cfg.SeedURLs {
+		enqueue(seed, 0)
+	}
+	if c.cfg.SitemapURL != "" {
+		urls, err := c.sitemapURLs(ctx, c.cfg.SitemapURL, 0, c.cfg.MaxPages)
+		if err != nil {
+			return nil, err
+		}
+		for _, raw := range urls {
+			enqueue(raw, 0)
+		}
+	}
+
+	visited := make(map[string]struct{})
+	files := make([]ingest.SourceFile, 0)
+	for len(queue) > 0 && len(files) < c.cfg.MaxPages {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		next := queue[0]
+		queue = queue[1:]
+		if _, seen := visited[next.url.String()]; seen {
+			continue
+		}
+		visited[next.url.String()] = struct{}{}
+		if !c.robotsAllowed(ctx, next.url) {
+			continue
+		}
+
+		page, err := c.fetchPage(ctx, next.url)
+		if err != nil {
+			return nil, err
+		}
+		if page == nil {
+			continue
+		}
+		if page.url.String() != next.url.String() {
+			// Redirected: index the target once, under its own URL.
+			if _, seen := visited[page.url.String()]; seen || !c.inScope(page.url) {
+				continue
+			}
+			visited[page.url.String()] = struct{}{}
+		}
+
+		if page.index {
+			files = append(files, page.file)
+		}
+		if page.follow && next.depth < *c.cfg.MaxDepth {
+			for _, link := range page.links {
+				enqueue(link, next.depth+1)
+			}
+		}
+	}
+	return files, nil
+}
+
+type fetchedPage struct {
+	url    *url.URL
+	file   ingest.SourceFile
+	links  []string
+	index  bool
+	follow bool
+}
+
+// fetchPage fetches one URL. It returns nil for pages that should be skipped.
+func (c *crawler) fetchPage(ctx context.Context, u *url.URL) (*fetchedPage, error) {
+	resp, err := c.get(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("fetch %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		return nil, nil
+	default:
+		// Anything else, e.g. 401, 403 or 429, may be temporary; the retry
+		// classifier decides, and the page is not pruned.
+		return nil, &domain.StatusError{Op: "fetch " + u.String(), StatusCode: resp.StatusCode}
+	}
+
+	final, err := canonicalURL(resp.Request.URL.String())
+	if err != nil {
+		return nil, nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPageSize))
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", u, err)
+	}
+
+	contentType := mediaType(resp.Header.Get("Content-Type"))
+	page := &fetchedPage{url: final, index: true}
+	page.file = ingest.SourceFile{
+		ExternalID:  final.String(),
+		ExternalURL: final.String(),
+		ExternalRef: final.String(),
+		MimeType:    contentType,
+		FolderPath:  folderPath(final),
+	}
+	page.file.SourceVersion, page.file.SourceModifiedAt = pageVersion(resp.Header, body)
+
+	if !isHTML(contentType) {
+		page.file.Name = fileName(final)
+		return page, nil
+	}
+
+	page.file.MimeType = "text/html"
+	meta := parsePage(final, body)
+	page.links = meta.links
+	page.index = !meta.noIndex
+	page.follow = !meta.noFollow
+	page.file.Name = meta.title
+	if page.file.Name == "" {
+		page.file.Name = fileName(final)
+	}
+	return page, nil
+}
+
+func (c *crawler) get(ctx context.Context, u *url.URL) (*http.Response, error) {
+	if err := c.p.throttle.wait(ctx, u.Host, c.delay(ctx, u)); err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.agent)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml,*/*;q=0.8")
+	return c.p.client.Do(req)
+}
+
+// delay is the minimum gap between requests to u's host.
+func (c *crawler) delay(ctx context.Context, u *url.URL) time.Duration {
+	if crawlDelay := c.p.robots.rules(ctx, c.p.client, u, c.agent).crawlDelay; crawlDelay > c.interval {
+		return crawlDelay
+	}
+	return c.interval
+}
+
+func (c *crawler) robotsAllowed(ctx context.Context, u *url.URL) bool {
+	return c.p.robots.rules(ctx, c.p.client, u, c.agent).allowed(u)
+}
+
+func (c *crawler) inScope(u *url.URL) bool {
+	if _, ok := c.hosts[u.Host]; !ok {
+		return false
+	}
+	for _, prefix := range c.cfg.ExcludePaths {
+		if pathWithin(u.Path, prefix) {
+			return false
+		}
+	}
+	if len(c.cfg.ScopePaths) == 0 {
+		return true
+	}
+	for _, prefix := range c.cfg.ScopePaths {
+		if pathWithin(u.Path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func pathWithin(p, prefix string) bool {
+	prefix = strings.TrimSuffix(prefix, "/")
+	return prefix == "" || p == prefix || strings.HasPrefix(p, prefix+"/")
+}
+
+type pageMeta struct {
+	title    string
+	links    []string
+	noIndex  bool
+	noFollow bool
+}
+
+// parsePage extracts the title, outgoing links and robots meta directives.
+func parsePage(base *url.URL, body []byte) pageMeta {
+	var meta pageMeta
+	meta.title, _ = ingest.HTMLToText(body)
+
+	doc, err := html.Parse(bytes.NewReader(body))
+	if err != nil {
+		return meta
+	}
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.ElementNode {
+			switch n.DataAtom {
+			case atom.Base:
+				if href := attrValue(n, "href"); href != "" {
+					if u, err := base.Parse(href); err == nil {
+						base = u
+					}
+				}
+			case atom.Meta:
+				if strings.EqualFold(attrValue(n, "name"), "robots") {
+					content := strings.ToLower(attrValue(n, "content"))
+					meta.noIndex = meta.noIndex || strings.Contains(content, "noindex") || strings.Contains(content, "none")
+					meta.noFollow = meta.noFollow || strings.Contains(content, "nofollow") || strings.Contains(content, "none")
+				}
+			case atom.A:
+				href := attrValue(n, "href")
+				rel := strings.ToLower(attrValue(n, "rel"))
+				if href != "" && !strings.Contains(rel, "nofollow") {
+					if u, err := base.Parse(href); err == nil {
+						meta.links = append(meta.links, u.String())
+					}
+				}
+			}
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(doc)
+	return meta
+}
+
+func attrValue(n *html.Node, key string) string {
+	for _, a := range n.Attr {
+		if strings.EqualFold(a.Key, key) {
+			return strings.TrimSpace(a.Val)
+		}
+	}
+	return ""
+}
+
+// pageVersion prefers the ETag, then Last-Modified, then a content hash.
+func pageVersion(h http.Header, body []byte) (string, *time.Time) {
+	var modified *time.Time
+	if lm := h.Get("Last-Modified"); lm != "" {
+		if t, err := http.ParseTime(lm); err == nil {
+			t = t.UTC()
+			modified = &t
+		}
+	}
+	if etag := strings.TrimSpace(h.Get("ETag")); etag != "" {
+		return etag, modified
+	}
+	if modified != nil {
+		return modified.Format(time.RFC3339), modified
+	}
+	sum := sha256.Sum256(body)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// canonicalURL normalizes u so the same page always has the same ID.
+func canonicalURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	u.Scheme = strings.ToLower(u.Scheme)
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q is not an absolute http(s) url", raw)
+	}
+	u.Host = strings.ToLower(u.Host)
+	if (u.Scheme == "http" && u.Port() == "80") || (u.Scheme == "https" && u.Port() == "443") {
+		u.Host = u.Hostname()
+	}
+	u.Fragment = ""
+	u.RawFragment = ""
+	u.User = nil
+	if u.Path == "" {
+		u.Path = "/"
+		u.RawPath = ""
+	}
+	return u, nil
+}
+
+func folderPath(u *url.URL) string {
+	dir := path.Dir(strings.TrimSuffix(u.Path, "/"))
+	if dir == "." || dir == "/" {
+		return ""
+	}
+	return dir
+}
+
+func fileName(u *url.URL) string {
+	name := path.Base(strings.TrimSuffix(u.Path, "/"))
+	if name == "." || name == "/" || name == "" {
+		return u.Host
+	}
+	return name
+}
+
+func mediaType(contentType string) string {
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return strings.ToLower(strings.TrimSpace(contentType))
+	}
+	return mt
+}
+
+func isHTML(mediaType string) bool {
+	return mediaType == "text/html" || mediaType == "application/xhtml+xml"
+}
+
+// hostThrottle spaces requests to each host by at least the requested delay.
+// It is shared by all crawls and downloads of the provider.
+type hostThrottle struct {
+	mu   sync.Mutex
+	next map[string]time.Time
+}
+
+func newHostThrottle() *hostThrottle {
+	return &hostThrottle{next: make(map[string]time.Time)}
+}
+
+func (t *hostThrottle) wait(ctx context.Context, host string, delay time.Duration) error {
+	t.mu.Lock()
+	now := time.Now()
+	start := t.next[host]
+	if start.Before(now) {
+		start = now
+	}
+	t.next[host] = start.Add(delay)
+	t.mu.Unlock()
+
+	wait := time.Until(start)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,250 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package web_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/web"
+)
+
+// loopbackPolicy lets tests reach their httptest servers.
+var loopbackPolicy = domain.SourceNetworkPolicy{AllowedHosts: []string{"127.0.0.1"}}
+
+type testSite struct {
+	srv      *httptest.Server
+	removedC atomic.Bool
+	broken   atomic.Int32
+}
+
+func newTestSite(t *testing.T) *testSite {
+	t.Helper()
+	site := &testSite{}
+	page := func(w http.ResponseWriter, title, body string) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = io.WriteString(w, `<html><head><title>`+title+`</title></head><body>
+<nav><a href="/">Home</a> <a href="/docs/a">A</a></nav>
+<main>`+body+`</main><footer>Footer text</footer></body></html>`)
+	}
+	site.srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("User-Agent"), "CubeEmbedder/") {
+			t.Errorf("unexpected user agent %q", r.Header.Get("User-Agent"))
+		}
+		switch r.URL.Path {
+		case "/robots.txt":
+			_, _ = io.WriteString(w, "User-agent: *\nDisallow: /private\n")
+		case "/sitemap.xml":
+			w.Header().Set("Content-Type", "application/xml")
+			_, _ = io.WriteString(w, `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url><loc>`+site.srv.URL+`/docs/e</loc></url>
+</urlset>`)
+		case "/":
+			page(w, "Welcome", `<h1>Welcome</h1>
+<a href="/docs/a#intro">A</a> <a href="docs/b">B</a> <a href="/private/x">Private</a>
+<a href="https://elsewhere.example/">Elsewhere</a> <a rel="nofollow" href="/docs/hidden">Hidden</a>
+<a href="/old">Moved</a>`)
+		case "/old":
+			http.Redirect(w, r, "/docs/a", http.StatusMovedPermanently)
+		case "/docs/a":
+			w.Header().Set("ETag", `"a1"`)
+			page(w, "Page A", `<p>Alpha content.</p><a href="/docs/c">C</a>`)
+		case "/docs/b":
+			w.Header().Set("Content-Type", "text/html")
+			_, _ = io.WriteString(w, `<html><head><meta name="robots" content="noindex"></head>
+<body><a href="/docs/d">D</a></body></html>`)
+		case "/docs/c":
+			if site.removedC.Load() {
+				http.NotFound(w, r)
+				return
+			}
+			w.Header().Set("Last-Modified", "Tue, 12 May 2026 11:00:00 GMT")
+			page(w, "Page C", `<p>Gamma content.</p><a href="/docs/f">F</a>`)
+		case "/docs/d":
+			if status := int(site.broken.Load()); status != 0 {
+				http.Error(w, "boom", status)
+				return
+			}
+			page(w, "Page D", `<p>Delta content.</p>`)
+		case "/docs/e":
+			page(w, "Page E", `<p>Epsilon content.</p>`)
+		case "/docs/f":
+			page(w, "Page F", `<p>Too deep.</p>`)
+		default:
+			t.Errorf("unexpected request: %s", r.URL.Path)
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(site.srv.Close)
+	return site
+}
+
+func (s *testSite) source(t *testing.T) domain.Source {
+	t.Helper()
+	depth := 2
+	raw, err := json.Marshal(domain.WebConfig{
+		SeedURLs:          []string{s.srv.URL + "/"},
+		SitemapURL:        s.srv.URL + "/sitemap.xml",
+		MaxDepth:          &depth,
+		RequestsPerSecond: 1000,
+	})
+	if err != nil {
+		t.Fatalf("encode config: %v", err)
+	}
+	return domain.Source{ID: "src-1", Type: domain.SourceTypeWeb, Config: raw}
+}
+
+func filesByPath(t *testing.T, site *testSite, files []ingest.SourceFile) map[string]ingest.SourceFile {
+	t.Helper()
+	out := make(map[string]ingest.SourceFile, len(files))
+	for _, f := range files {
+		out[strings.TrimPrefix(f.ExternalID, site.srv.URL)] = f
+	}
+	return out
+}
+
+func TestWebSourceProviderCrawl(t *testing.T) {
+	site := newTestSite(t)
+	provider := web.NewSourceProvider(loopbackPolicy)
+	src := site.source(t)
+
+	files, err := provider.ListFiles(context.Background(), "user-1", src)
+	if err != nil {
+		t.Fatalf("ListFiles returned error: %v", err)
+	}
+	byPath := filesByPath(t, site, files)
+
+	got := make([]string, 0, len(byPath))
+	for p := range byPath {
+		got = append(got, p)
+	}
+	sort.Strings(got)
+	want := []string{"/", "/docs/a", "/docs/c", "/docs/d", "/docs/e"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("unexpected crawled pages %v, want %v", got, want)
+	}
+
+	a := byPath["/docs/a"]
+	if a.Name != "Page A" || a.MimeType != "text/html" || a.SourceVersion != `"a1"` {
+		t.Fatalf("unexpected page a: %+v", a)
+	}
+	if a.ExternalURL != site.srv.URL+"/docs/a" || a.FolderPath != "/docs" {
+		t.Fatalf("unexpected page a location: %+v", a)
+	}
+	c := byPath["/docs/c"]
+	if c.SourceVersion != "2026-05-12T11:00:00Z" || c.SourceModifiedAt == nil {
+		t.Fatalf("expected Last-Modified version for page c, got %+v", c)
+	}
+	if !strings.HasPrefix(byPath["/docs/e"].SourceVersion, "sha256:") {
+		t.Fatalf("expected content hash version for page e, got %q", byPath["/docs/e"].SourceVersion)
+	}
+
+	text, _, err := provider.DownloadRecord(context.Background(), domain.Record{
+		ID:          "rec-a",
+		ExternalID:  a.ExternalID,
+		ExternalURL: a.ExternalURL,
+		Name:        a.Name,
+		MimeType:    a.MimeType,
+	}, src)
+	if err != nil {
+		t.Fatalf("DownloadRecord returned error: %v", err)
+	}
+	if !strings.Contains(text, "Page A") || !strings.Contains(text, "Alpha content.") {
+		t.Fatalf("unexpected page text: %q", text)
+	}
+	if strings.Contains(text, "Home") || strings.Contains(text, "Footer text") {
+		t.Fatalf("expected boilerplate to be stripped: %q", text)
+	}
+}
+
+func TestWebSourceProviderDropsRemovedPages(t *testing.T) {
+	site := newTestSite(t)
+	provider := web.NewSourceProvider(loopbackPolicy)
+	src := site.source(t)
+
+	site.removedC.Store(true)
+	files, err := provider.ListFiles(context.Background(), "user-1", src)
+	if err != nil {
+		t.Fatalf("ListFiles returned error: %v", err)
+	}
+	if _, ok := filesByPath(t, site, files)["/docs/c"]; ok {
+		t.Fatal("expected removed page to be left out so the sync prunes it")
+	}
+
+	_, _, err = provider.DownloadRecord(context.Background(), domain.Record{
+		ID:         "rec-c",
+		ExternalID: site.srv.URL + "/docs/c",
+	}, src)
+	if err == nil {
+		t.Fatal("expected download of removed page to fail")
+	}
+}
+
+func TestWebSourceProviderFailsOnUnavailablePages(t *testing.T) {
+	for _, status := range []int{
+		http.StatusInternalServerError,
+		http.StatusUnauthorized,
+		http.StatusForbidden,
+		http.StatusTooManyRequests,
+	} {
+		site := newTestSite(t)
+		site.broken.Store(int32(status))
+
+		_, err := web.NewSourceProvider(loopbackPolicy).ListFiles(context.Background(), "user-1", site.source(t))
+		var statusErr *domain.StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != status {
+			t.Fatalf("status %d: expected the crawl to fail with its status instead of pruning the page, got %v", status, err)
+		}
+	}
+}
+
+func TestWebSourceProviderRefusesRedirectToLoopback(t *testing.T) {
+	var internalHits atomic.Int32
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			http.NotFound(w, r)
+		case "/internal":
+			internalHits.Add(1)
+			_, _ = io.WriteString(w, "internal data")
+		default:
+			http.Redirect(w, r, srv.URL+"/internal", http.StatusFound)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	// The seed is reached through an allowlisted name; the redirect target
+	// is the same server by loopback address, which the policy refuses.
+	port := srv.URL[strings.LastIndex(srv.URL, ":")+1:]
+	raw, err := json.Marshal(domain.WebConfig{
+		SeedURLs:          []string{"http://localhost:" + port + "/"},
+		AllowedHosts:      []string{"localhost:" + port, "127.0.0.1:" + port},
+		RequestsPerSecond: 1000,
+	})
+	if err != nil {
+		t.Fatalf("encode config: %v", err)
+	}
+	src := domain.Source{ID: "src-1", Type: domain.SourceTypeWeb, Config: raw}
+
+	provider := web.NewSourceProvider(domain.SourceNetworkPolicy{AllowedHosts: []string{"localhost"}})
+	_, err = provider.ListFiles(context.Background(), "user-1", src)
+	if !errors.Is(err, domain.ErrAddressNotAllowed) {
+		t.Fatalf("expected redirect to loopback to be refused, got %v", err)
+	}
+	if internalHits.Load() != 0 {
+		t.Fatal("expected the loopback address never to be requested")
+	}
+}
@@ -0,0 +1,244 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	robotsTTL     = time.Hour
+	maxRobotsSize = 512 << 10
+)
+
+type robotsRule struct {
+	allow   bool
+	length  int
+	pattern *regexp.Regexp
+}
+
+// robotsRules is the robots.txt group that applies to our user agent.
+type robotsRules struct {
+	rules      []robotsRule
+	crawlDelay time.Duration
+	disallowed bool
+}
+
+// allowed applies the longest matching rule; ties go to Allow.
+func (r robotsRules) allowed(u *url.URL) bool {
+	if r.disallowed {
+		return false
+	}
+	target := u.EscapedPath()
+	if target == "" {
+		target = "/"
+	}
+	if u.RawQuery != "" {
+		target += "?" + u.RawQuery
+	}
+
+	best := -1
+	allow := true
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(target) {
+			continue
+		}
+		if rule.length > best || (rule.length == best && rule.allow) {
+			best = rule.length
+			allow = rule.allow
+		}
+	}
+	return allow
+}
+
+// parseRobots returns the rules of the most specific group matching agent,
+// falling back to the "*" group.
+func parseRobots(body []byte, agent string) robotsRules {
+	agent = strings.ToLower(agent)
+
+	type group struct {
+		agents []string
+		rules  []robotsRule
+		delay  time.Duration
+	}
+	var (
+		groups  []*group
+		current *group
+		inRules bool
+	)
+	scanner := bufio.NewScanner(bytes.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if idx := strings.IndexByte(line, '#'); idx >= 0 {
+			line = line[:idx]
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.TrimSpace(value)
+
+		switch key {
+		case "user-agent":
+			if current == nil || inRules {
+				current = &group{}
+				groups = append(groups, current)
+				inRules = false
+			}
+			current.agents = append(current.agents, strings.ToLower(value))
+		case "allow", "disallow":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			if value == "" {
+				continue
+			}
+			if rule, ok := compileRobotsRule(value, key == "allow"); ok {
+				current.rules = append(current.rules, rule)
+			}
+		case "crawl-delay":
+			if current == nil {
+				continue
+			}
+			inRules = true
+			if secs, err := strconv.ParseFloat(value, 64); err == nil && secs > 0 {
+				current.delay = time.Duration(secs * float64(time.Second))
+			}
+		}
+	}
+
+	var (
+		match      *group
+		matchLen   = -1
+		wildcard   *group
+		foundGroup bool
+	)
+	for _, g := range groups {
+		for _, a := range g.agents {
+			if a == "*" {
+				if wildcard == nil {
+					wildcard = g
+				}
+				continue
+			}
+			if strings.Contains(agent, a) && len(a) > matchLen {
+				match, matchLen = g, len(a)
+				foundGroup = true
+			}
+		}
+	}
+	if !foundGroup {
+		match = wildcard
+	}
+	if match == nil {
+		return robotsRules{}
+	}
+	return robotsRules{rules: match.rules, crawlDelay: match.delay}
+}
+
+func compileRobotsRule(value string, allow bool) (robotsRule, bool) {
+	anchored := strings.HasSuffix(value, "$")
+	value = strings.TrimSuffix(value, "$")
+
+	var expr strings.Builder
+	expr.WriteString("^")
+	for i, part := range strings.Split(value, "*") {
+		if i > 0 {
+			expr.WriteString(".*")
+		}
+		expr.WriteString(regexp.QuoteMeta(part))
+	}
+	if anchored {
+		expr.WriteString("$")
+	}
+	pattern, err := regexp.Compile(expr.String())
+	if err != nil {
+		return robotsRule{}, false
+	}
+	return robotsRule{allow: allow, length: len(value), pattern: pattern}, true
+}
+
+type robotsEntry struct {
+	rules     robotsRules
+	fetchedAt time.Time
+}
+
+// robotsCache fetches and caches robots.txt per origin and user agent.
+type robotsCache struct {
+	mu      sync.Mutex
+	entries map[string]robotsEntry
+}
+
+func newRobotsCache() *robotsCache {
+	return &robotsCache{entries: make(map[string]robotsEntry)}
+}
+
+func (c *robotsCache) rules(ctx context.Context, client *http.Client, u *url.URL, agent string) robotsRules {
+	key := u.Scheme + "://" + u.Host + "|" + agent
+
+	c.mu.Lock()
+	entry, ok := c.entries[key]
+	c.mu.Unlock()
+	if ok && time.Since(entry.fetchedAt) < robotsTTL {
+		return entry.rules
+	}
+
+	rules := fetchRobots(ctx, client, u, agent)
+	if ctx.Err() != nil {
+		return rules
+	}
+
+	c.mu.Lock()
+	c.entries[key] = robotsEntry{rules: rules, fetchedAt: time.Now()}
+	c.mu.Unlock()
+	return rules
+}
+
+// fetchRobots follows the robots exclusion protocol: a missing robots.txt
+// allows everything, an unreachable one disallows everything.
+func fetchRobots(ctx context.Context, client *http.Client, u *url.URL, agent string) robotsRules {
+	robotsURL := url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, robotsURL.String(), http.NoBody)
+	if err != nil {
+		return robotsRules{disallowed: true}
+	}
+	req.Header.Set("User-Agent", agent)
+	resp, err := client.Do(req)
+	if err != nil {
+		return robotsRules{disallowed: true}
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 500:
+		return robotsRules{disallowed: true}
+	case resp.StatusCode >= 400:
+		return robotsRules{}
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRobotsSize))
+	if err != nil {
+		return robotsRules{disallowed: true}
+	}
+	return parseRobots(body, robotsToken(agent))
+}
+
+// robotsToken is the product token of a user agent, e.g. "cubeembedder" for
+// "CubeEmbedder/1.0 (+https://...)".
+func robotsToken(agent string) string {
+	token, _, _ := strings.Cut(agent, "/")
+	token, _, _ = strings.Cut(token, " ")
+	return strings.ToLower(strings.TrimSpace(token))
+}
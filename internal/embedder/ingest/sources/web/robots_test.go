@@ -0,0 +1,54 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestParseRobots(t *testing.T) {
+	robots := []byte(`
+# comment
+User-agent: *
+Disallow: /
+
+User-agent: OtherBot
+User-agent: CubeEmbedder
+Disallow: /private
+Allow: /private/public
+Disallow: /*.pdf$
+Crawl-delay: 1.5
+`)
+	rules := parseRobots(robots, robotsToken(DefaultUserAgent))
+	if rules.crawlDelay != 1500*time.Millisecond {
+		t.Fatalf("unexpected crawl delay %v", rules.crawlDelay)
+	}
+
+	tests := map[string]bool{
+		"/":                     true,
+		"/private":              false,
+		"/private/x":            false,
+		"/private/public/page":  true,
+		"/docs/report.pdf":      false,
+		"/docs/report.pdf?x=1":  true,
+		"/docs/report.pdf.html": true,
+	}
+	for path, want := range tests {
+		u, err := url.Parse("https://wiki.example" + path)
+		if err != nil {
+			t.Fatalf("parse %q: %v", path, err)
+		}
+		if got := rules.allowed(u); got != want {
+			t.Fatalf("allowed(%q) = %v, want %v", path, got, want)
+		}
+	}
+
+	other := parseRobots(robots, "somebot")
+	u, _ := url.Parse("https://wiki.example/docs")
+	if other.allowed(u) {
+		t.Fatal("expected wildcard group to disallow other agents")
+	}
+}
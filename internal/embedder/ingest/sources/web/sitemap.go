@@ -0,0 +1,91 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package web
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+const (
+	maxSitemapSize  = 50 << 20
+	maxSitemapDepth = 3
+)
+
+type sitemapDocument struct {
+	URLs []struct {
+		Loc string `xml:"loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+// sitemapURLs returns the page URLs listed by a sitemap or sitemap index,
+// following nested indexes up to maxSitemapDepth and stopping at limit.
+func (c *crawler) sitemapURLs(ctx context.Context, sitemapURL string, depth, limit int) ([]string, error) {
+	if depth > maxSitemapDepth || limit <= 0 {
+		return nil, nil
+	}
+	u, err := canonicalURL(sitemapURL)
+	if err != nil {
+		return nil, err
+	}
+	if !c.robotsAllowed(ctx, u) {
+		return nil, nil
+	}
+
+	resp, err := c.get(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("fetch sitemap %s: %w", u, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, &domain.StatusError{Op: "fetch sitemap " + u.String(), StatusCode: resp.StatusCode}
+	}
+
+	var body io.Reader = io.LimitReader(resp.Body, maxSitemapSize)
+	if strings.HasSuffix(u.Path, ".gz") || strings.Contains(resp.Header.Get("Content-Type"), "gzip") {
+		gz, err := gzip.NewReader(body)
+		if err != nil {
+			return nil, fmt.Errorf("decompress sitemap %s: %w", u, err)
+		}
+		defer gz.Close()
+		body = io.LimitReader(gz, maxSitemapSize)
+	}
+
+	var doc sitemapDocument
+	if err := xml.NewDecoder(body).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("decode sitemap %s: %w", u, err)
+	}
+
+	urls := make([]string, 0, len(doc.URLs))
+	for _, entry := range doc.URLs {
+		if len(urls) >= limit {
+			return urls, nil
+		}
+		if loc := strings.TrimSpace(entry.Loc); loc != "" {
+			urls = append(urls, loc)
+		}
+	}
+	for _, nested := range doc.Sitemaps {
+		loc := strings.TrimSpace(nested.Loc)
+		if loc == "" {
+			continue
+		}
+		more, err := c.sitemapURLs(ctx, loc, depth+1, limit-len(urls))
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, more...)
+	}
+	return urls, nil
+}
@@ -0,0 +1,136 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+// Package webhook serves documents pushed to webhook sources. The webhook
+// handler stores each document in object storage; like local uploads,
+// nothing is discovered by ListFiles and the provider only reads content
+// back for ingestion.
+package webhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	objstore "github.com/ultravioletrs/cube/internal/embedder/storage"
+)
+
+// Object is a pushed document as kept in object storage.
+type Object struct {
+	Metadata map[string]string `json:"metadata,omitempty"`
+	Content  []byte            `json:"content"`
+}
+
+// ObjectKey is where a document of a source is stored. Each document has a
+// single object that new versions overwrite.
+func ObjectKey(sourceID, documentID string) string {
+	sum := sha256.Sum256([]byte(documentID))
+	return path.Join("webhooks", sourceID, hex.EncodeToString(sum[:]))
+}
+
+type sourceProvider struct {
+	store objstore.Store
+}
+
+// NewSourceProvider creates the webhook provider reading documents from
+// store.
+func NewSourceProvider(store objstore.Store) ingest.SourceProvider {
+	return &sourceProvider{store: store}
+}
+
+func (p *sourceProvider) Type() domain.SourceType {
+	return domain.SourceTypeWebhook
+}
+
+func (p *sourceProvider) Capabilities() ingest.SourceProviderCapabilities {
+	return ingest.SourceProviderCapabilities{
+		SupportsDownload: true,
+	}
+}
+
+// PrunesStaleRecords is false: documents are only removed by deletion
+// notices, never because a sync did not see them.
+func (p *sourceProvider) PrunesStaleRecords() bool {
+	return false
+}
+
+// ListFiles returns no files: documents are pushed, not discovered.
+func (p *sourceProvider) ListFiles(
+	_ context.Context,
+	_ string,
+	_ domain.Source,
+) ([]ingest.SourceFile, error) {
+	return nil, nil
+}
+
+func (p *sourceProvider) DownloadRecord(
+	ctx context.Context,
+	rec domain.Record,
+	_ domain.Source,
+) (string, *int, error) {
+	obj, err := p.object(ctx, rec)
+	if err != nil {
+		return "", nil, err
+	}
+	doc, err := ingest.ExtractText(ingest.FileMeta{
+		ID:       rec.ExternalID,
+		Name:     rec.Name,
+		MimeType: rec.MimeType,
+	}, obj.Content)
+	if err != nil {
+		return "", nil, err
+	}
+	if len(obj.Metadata) == 0 {
+		return doc.Text, doc.PageCount, nil
+	}
+
+	keys := make([]string, 0, len(obj.Metadata))
+	for key := range obj.Metadata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var b strings.Builder
+	for _, key := range keys {
+		fmt.Fprintf(&b, "%s: %s\n", key, obj.Metadata[key])
+	}
+	b.WriteString("\n")
+	b.WriteString(doc.Text)
+	return b.String(), doc.PageCount, nil
+}
+
+func (p *sourceProvider) DownloadRecordContent(
+	ctx context.Context,
+	rec domain.Record,
+	_ domain.Source,
+) ([]byte, error) {
+	obj, err := p.object(ctx, rec)
+	if err != nil {
+		return nil, err
+	}
+	return obj.Content, nil
+}
+
+func (p *sourceProvider) object(ctx context.Context, rec domain.Record) (Object, error) {
+	if p.store == nil {
+		return Object{}, fmt.Errorf("object storage is not configured")
+	}
+	if rec.ExternalRef == "" {
+		return Object{}, fmt.Errorf("record %s is missing its object key", rec.ID)
+	}
+	raw, err := p.store.Get(ctx, rec.ExternalRef)
+	if err != nil {
+		return Object{}, err
+	}
+	var obj Object
+	if err := json.Unmarshal(raw, &obj); err != nil {
+		return Object{}, fmt.Errorf("decode webhook document: %w", err)
+	}
+	return obj, nil
+}
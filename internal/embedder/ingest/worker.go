@@ -2,7 +2,7 @@
 // SPDX-License-Identifier: Apache-2.0
 
 // Package ingest implements the background embedding pipeline.
-// It polls for queued records, downloads their content from the source,
+// It leases jobs for queued records, downloads their content from the source,
 // chunks and embeds the text, then stores chunks in the vector store.
 package ingest
 
@@ -10,6 +10,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"path"
 	"strings"
@@ -21,17 +22,23 @@ import (
 	"github.com/ultravioletrs/cube/internal/embedder/imageembedding"
 	embedmetrics "github.com/ultravioletrs/cube/internal/embedder/metrics"
 	"github.com/ultravioletrs/cube/internal/embedder/postgres"
+	objstore "github.com/ultravioletrs/cube/internal/embedder/storage"
 )
 
-// Worker polls for queued records and runs the embedding pipeline.
+// Worker leases ingest jobs and runs the embedding pipeline for their
+// records.
 type Worker struct {
 	records         domain.RecordRepository
+	jobs            domain.IngestJobRepository
 	sources         domain.SourceRepository
 	chunks          *postgres.ChunksRepository
 	embeddings      *embedding.Registry
 	imageEmbeddings *postgres.ImageEmbeddingsRepository
 	imageEmbedder   *imageembedding.Client
+	enricher        *Enricher
 	sourceProviders *SourceProviderRegistry
+	archiveStore    objstore.Store
+	archivePrefix   string
 	chunkSize       int
 	overlap         int
 	batchSize       int
@@ -39,8 +46,24 @@ type Worker struct {
 	embedBatchSize  int
 	recordTimeout   time.Duration
 	maxChunks       int
+	streamThreshold int64
 	pollInterval    time.Duration
 	trigger         chan struct{}
+
+	// owner identifies this worker's leases among all replicas.
+	owner         string
+	leaseDuration time.Duration
+	retryPolicies map[domain.IngestErrorClass]RetryPolicy
+	domainLimits  domain.IngestConcurrencyLimits
+	embedCache    embeddingCache
+	embedCacheTTL time.Duration
+	// cachePrunedAt is when Run last pruned the embedding cache.
+	cachePrunedAt time.Time
+	// queueReportedAt is when Run last refreshed the queue metrics.
+	queueReportedAt time.Time
+
+	mu     sync.Mutex
+	leases map[string]*ingestLease
 }
 
 const (
@@ -53,6 +76,7 @@ const (
 	stageExtracting = "extracting"
 	stageChunking   = "chunking"
 	stageEmbedding  = "embedding"
+	stageEnriching  = "enriching"
 )
 
 var errRecordCancelled = errors.New("record ingest cancelled")
@@ -66,8 +90,11 @@ func (w *Worker) setStage(ctx context.Context, rec domain.Record, stage string,
 }
 
 // NewWorker creates an ingestion worker. chunkSize and overlap are in words.
+// owner identifies the worker's leases and must differ between replicas.
 func NewWorker(
 	records domain.RecordRepository,
+	jobs domain.IngestJobRepository,
+	owner string,
 	sources domain.SourceRepository,
 	chunks *postgres.ChunksRepository,
 	embeddings *embedding.Registry,
@@ -76,6 +103,7 @@ func NewWorker(
 ) *Worker {
 	return &Worker{
 		records:         records,
+		jobs:            jobs,
 		sources:         sources,
 		chunks:          chunks,
 		embeddings:      embeddings,
@@ -87,12 +115,18 @@ func NewWorker(
 		embedBatchSize:  16,
 		recordTimeout:   15 * time.Minute,
 		maxChunks:       0,
+		streamThreshold: defaultStreamThreshold,
 		pollInterval:    10 * time.Second,
 		trigger:         make(chan struct{}, 1),
+		owner:           owner,
+		leaseDuration:   2 * time.Minute,
+		retryPolicies:   DefaultRetryPolicies(),
+		leases:          make(map[string]*ingestLease),
 	}
 }
 
-// SetBatchSize adjusts how many queued records are fetched each iteration.
+// SetBatchSize adjusts how many jobs are claimed at most each iteration. The
+// worker never claims more jobs than it can run at once.
 func (w *Worker) SetBatchSize(size int) {
 	if size > 0 {
 		w.batchSize = size
@@ -127,6 +161,15 @@ func (w *Worker) SetMaxChunks(n int) {
 	}
 }
 
+// SetStreamThreshold adjusts the file size above which PDFs and text files
+// are extracted, chunked and embedded as a stream instead of in memory. Zero
+// disables streaming.
+func (w *Worker) SetStreamThreshold(bytes int64) {
+	if bytes >= 0 {
+		w.streamThreshold = bytes
+	}
+}
+
 // SetPollInterval adjusts how often the worker checks for queued records.
 func (w *Worker) SetPollInterval(d time.Duration) {
 	if d > 0 {
@@ -134,24 +177,78 @@ func (w *Worker) SetPollInterval(d time.Duration) {
 	}
 }
 
+// SetLeaseDuration adjusts how long a claimed job stays leased without a
+// heartbeat. Heartbeats are sent every third of it; a worker that stops
+// sending them loses the job to another worker once it lapses.
+func (w *Worker) SetLeaseDuration(d time.Duration) {
+	if d > 0 {
+		w.leaseDuration = d
+	}
+}
+
+// SetRetryPolicy overrides the retry policy of one error class.
+func (w *Worker) SetRetryPolicy(class domain.IngestErrorClass, policy RetryPolicy) {
+	if policy.MaxAttempts > 0 {
+		w.retryPolicies[class] = policy
+	}
+}
+
+// SetDomainConcurrency caps how many jobs of one domain run at once across
+// all workers.
+func (w *Worker) SetDomainConcurrency(limits domain.IngestConcurrencyLimits) {
+	w.domainLimits = limits
+}
+
+// SetEmbeddingCache makes the worker reuse the embeddings of chunk texts it
+// has embedded before with the same profile and model. Entries unused for
+// ttl are pruned; a zero ttl keeps them.
+func (w *Worker) SetEmbeddingCache(cache *postgres.EmbeddingCache, ttl time.Duration) {
+	w.embedCache = cache
+	w.embedCacheTTL = ttl
+}
+
 // SetImageEmbedding enables optional visual embeddings for image records.
 func (w *Worker) SetImageEmbedding(repo *postgres.ImageEmbeddingsRepository, client *imageembedding.Client) {
 	w.imageEmbeddings = repo
 	w.imageEmbedder = client
 }
 
-// Run starts the worker poll loop. It blocks until ctx is cancelled.
+// SetEnricher enables the enrichment stage: after a text record is
+// embedded, enricher derives its summary and metadata, unless the record's
+// content is unchanged since it was last enriched.
+func (w *Worker) SetEnricher(enricher *Enricher) {
+	w.enricher = enricher
+}
+
+// SetArchiveStore enables archive expansion. Files unpacked from archives
+// are kept in store under keyPrefix and read back when their records are
+// ingested.
+func (w *Worker) SetArchiveStore(store objstore.Store, keyPrefix string) {
+	w.archiveStore = store
+	w.archivePrefix = strings.Trim(strings.TrimSpace(keyPrefix), "/")
+}
+
+// Run starts the worker poll loop. It blocks until ctx is cancelled and the
+// jobs it started have been released.
 func (w *Worker) Run(ctx context.Context) {
 	ticker := time.NewTicker(w.pollInterval)
 	defer ticker.Stop()
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	// A slot frees up whenever a job finishes; the buffer lets every running
+	// job report without blocking.
+	finished := make(chan struct{}, w.maxConcurrent)
+	running := 0
 	for {
+		running += w.processQueued(ctx, w.maxConcurrent-running, &wg, finished)
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			w.processQueued(ctx)
 		case <-w.trigger:
-			w.processQueued(ctx)
+		case <-finished:
+			running--
 		}
 	}
 }
@@ -165,33 +262,73 @@ func (w *Worker) Trigger() {
 	}
 }
 
-func (w *Worker) processQueued(ctx context.Context) {
-	records, err := w.records.ListQueued(ctx, w.batchSize)
-	if err != nil {
-		slog.Error("ingest: list queued records", "err", err)
-		return
+// processQueued claims up to free jobs and starts them, each sending on
+// finished when done. Jobs are claimed as slots free up rather than in
+// batches, so a long ingest does not hold back the domains queued behind it.
+// It returns how many jobs it started.
+func (w *Worker) processQueued(ctx context.Context, free int, wg *sync.WaitGroup, finished chan<- struct{}) int {
+	if free <= 0 {
+		return 0
+	}
+	if _, err := w.jobs.Enqueue(ctx); err != nil {
+		slog.Error("ingest: enqueue jobs", "err", err)
+		return 0
+	}
+	w.reportQueue(ctx)
+	w.pruneEmbeddingCache(ctx)
+
+	started := 0
+	for started < free && ctx.Err() == nil {
+		limit := min(w.batchSize, free-started)
+		jobs, err := w.jobs.Claim(ctx, w.owner, limit, w.leaseDuration, w.domainLimits)
+		if err != nil {
+			slog.Error("ingest: claim jobs", "err", err)
+			break
+		}
+		for _, job := range jobs {
+			embedmetrics.ObserveIngestQueueWait(job.DomainID, job.Priority.String(), time.Since(job.RunAfter))
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				w.processJob(ctx, job)
+				finished <- struct{}{}
+			}()
+		}
+		started += len(jobs)
+		if len(jobs) < limit {
+			break
+		}
 	}
-	if len(records) == 0 {
+	return started
+}
+
+// reportQueue refreshes the queue metrics at most once per poll interval.
+func (w *Worker) reportQueue(ctx context.Context) {
+	if time.Since(w.queueReportedAt) < w.pollInterval {
 		return
 	}
+	w.queueReportedAt = time.Now()
 
-	sem := make(chan struct{}, w.maxConcurrent)
-	var wg sync.WaitGroup
-
-	for _, rec := range records {
-		if ctx.Err() != nil {
-			break
+	stats, err := w.jobs.Stats(ctx)
+	if err != nil {
+		slog.Warn("ingest: queue stats", "err", err)
+		return
+	}
+	domains := make([]embedmetrics.IngestQueueDomain, 0, len(stats))
+	for _, st := range stats {
+		d := embedmetrics.IngestQueueDomain{
+			DomainID: st.DomainID,
+			Runnable: st.Runnable,
+			Waiting:  st.Waiting,
+			Leased:   st.Leased,
+			Dead:     st.Dead,
+		}
+		if st.OldestRunnable != nil {
+			d.OldestWait = time.Since(*st.OldestRunnable)
 		}
-		rec := rec
-		wg.Add(1)
-		sem <- struct{}{}
-		go func() {
-			defer wg.Done()
-			defer func() { <-sem }()
-			w.processRecord(ctx, rec)
-		}()
+		domains = append(domains, d)
 	}
-	wg.Wait()
+	embedmetrics.SetIngestQueue(domains)
 }
 
 func (w *Worker) processRecord(ctx context.Context, rec domain.Record) {
@@ -209,6 +346,11 @@ func (w *Worker) processRecord(ctx context.Context, rec domain.Record) {
 	}
 	defer cancel()
 
+	if rec.Format == domain.RecordFormatArchive && rec.ParentID == nil {
+		w.processArchiveRecord(recordCtx, ctx, rec, logger)
+		return
+	}
+
 	if rec.Format != domain.RecordFormatImage && w.shouldProbeRawImage(rec) {
 		if handled := w.processRawImageRecordIfDetected(recordCtx, ctx, rec, logger); handled {
 			return
@@ -226,10 +368,14 @@ func (w *Worker) processRecord(ctx context.Context, rec domain.Record) {
 	}
 
 	w.setStage(ctx, rec, stageExtracting, logger)
-	text, pageCount, err := w.downloadContent(recordCtx, rec)
+	text, pageCount, stream, err := w.downloadContent(recordCtx, rec)
 	if err != nil {
 		logger.Warn("ingest: download failed", "err", err)
-		_ = w.records.UpdateStatus(ctx, rec.ID, domain.RecordStatusFailed, err.Error())
+		w.fail(ctx, rec, err)
+		return
+	}
+	if stream != nil {
+		w.processStreamedRecord(recordCtx, ctx, rec, stream, logger)
 		return
 	}
 	if w.isCancelled(ctx, rec) {
@@ -240,33 +386,33 @@ func (w *Worker) processRecord(ctx context.Context, rec domain.Record) {
 	w.setStage(ctx, rec, stageChunking, logger)
 	chunks, plan := adaptiveChunk(text, w.chunkSize, w.overlap)
 	if len(chunks) == 0 {
-		_ = w.records.UpdateStatus(ctx, rec.ID, domain.RecordStatusFailed, "document produced zero chunks")
+		w.fail(ctx, rec, permanentf("document produced zero chunks"))
 		return
 	}
 	logger.Info("ingest: chunked document", "words", plan.Words, "chunk_size", plan.Size, "chunk_overlap", plan.Overlap, "chunks", len(chunks))
 	if w.maxChunks > 0 && len(chunks) > w.maxChunks {
-		err := fmt.Errorf("document produced %d chunks, above limit %d", len(chunks), w.maxChunks)
+		err := permanentf("document produced %d chunks, above limit %d", len(chunks), w.maxChunks)
 		logger.Warn("ingest: too many chunks", "chunks", len(chunks), "max_chunks", w.maxChunks)
-		_ = w.records.UpdateStatus(ctx, rec.ID, domain.RecordStatusFailed, err.Error())
+		w.fail(ctx, rec, err)
 		return
 	}
 
-	embedder, err := w.embeddings.ForRecord(rec)
+	profile, err := w.embeddings.ForRecord(rec)
 	if err != nil {
 		logger.Warn("ingest: select embedding model failed", "err", err)
-		_ = w.records.UpdateStatus(ctx, rec.ID, domain.RecordStatusFailed, err.Error())
+		w.fail(ctx, rec, permanent(err))
 		return
 	}
 
 	w.setStage(ctx, rec, stageEmbedding, logger)
-	indexedChunks, err := w.embedAndStoreBatched(recordCtx, ctx, rec, embedder, chunks)
+	indexedChunks, reusedChunks, err := w.embedAndStoreBatched(recordCtx, ctx, rec, profile, chunks)
 	if err != nil {
 		if errors.Is(err, errRecordCancelled) {
 			w.cleanupCancelledRecord(ctx, rec, logger)
 			return
 		}
 		logger.Warn("ingest: embed failed", "err", err)
-		_ = w.records.UpdateStatus(ctx, rec.ID, domain.RecordStatusFailed, err.Error())
+		w.fail(ctx, rec, err)
 		return
 	}
 	if w.isCancelled(ctx, rec) {
@@ -274,12 +420,16 @@ func (w *Worker) processRecord(ctx context.Context, rec domain.Record) {
 		return
 	}
 
+	contentHash := domain.ContentHash(text)
+	w.enrichRecord(recordCtx, ctx, rec, text, contentHash, logger)
+
 	_ = w.records.UpdateAfterIngest(ctx, rec.ID, domain.IngestResult{
-		ChunkCount: indexedChunks,
-		SizeBytes:  int64(len(text)),
-		PageCount:  pageCount,
+		ChunkCount:  indexedChunks,
+		SizeBytes:   int64(len(text)),
+		PageCount:   pageCount,
+		ContentHash: contentHash,
 	})
-	logger.Info("ingest: indexed", "chunks", indexedChunks)
+	logger.Info("ingest: indexed", "chunks", indexedChunks, "reused_chunks", reusedChunks)
 }
 
 func (w *Worker) processImageRecord(ctx, statusCtx context.Context, rec domain.Record, logger *slog.Logger) {
@@ -292,7 +442,7 @@ func (w *Worker) processImageRecord(ctx, statusCtx context.Context, rec domain.R
 	content, err := w.downloadRawContent(ctx, rec)
 	if err != nil {
 		logger.Warn("ingest: image download failed", "err", err)
-		_ = w.records.UpdateStatus(statusCtx, rec.ID, domain.RecordStatusFailed, err.Error())
+		w.fail(statusCtx, rec, err)
 		return
 	}
 	if w.isCancelled(ctx, rec) {
@@ -307,7 +457,7 @@ func (w *Worker) processImageRecord(ctx, statusCtx context.Context, rec domain.R
 	}, content)
 	if err != nil {
 		logger.Warn("ingest: image extraction failed", "err", err)
-		_ = w.records.UpdateStatus(statusCtx, rec.ID, domain.RecordStatusFailed, err.Error())
+		w.fail(statusCtx, rec, permanent(err))
 		return
 	}
 	if w.isCancelled(ctx, rec) {
@@ -383,35 +533,35 @@ func (w *Worker) processImageDocument(
 ) {
 	chunks, plan := adaptiveChunk(doc.Text, w.chunkSize, w.overlap)
 	if len(chunks) == 0 {
-		_ = w.records.UpdateStatus(statusCtx, rec.ID, domain.RecordStatusFailed, "image produced zero chunks")
+		w.fail(statusCtx, rec, permanentf("image produced zero chunks"))
 		return
 	}
 	logger.Info("ingest: chunked image text", "words", plan.Words, "chunk_size", plan.Size, "chunk_overlap", plan.Overlap, "chunks", len(chunks))
 	if w.maxChunks > 0 && len(chunks) > w.maxChunks {
-		err := fmt.Errorf("image produced %d chunks, above limit %d", len(chunks), w.maxChunks)
+		err := permanentf("image produced %d chunks, above limit %d", len(chunks), w.maxChunks)
 		logger.Warn("ingest: image too many chunks", "chunks", len(chunks), "max_chunks", w.maxChunks)
-		_ = w.records.UpdateStatus(statusCtx, rec.ID, domain.RecordStatusFailed, err.Error())
+		w.fail(statusCtx, rec, err)
 		return
 	}
 
 	// Text chunks from images are always embedded with the text profile. The
 	// visual signal is stored separately in image_embeddings when selected below.
-	embedder, err := w.embeddings.ForRecord(domain.Record{Format: domain.RecordFormatText})
+	profile, err := w.embeddings.ForRecord(domain.Record{Format: domain.RecordFormatText})
 	if err != nil {
 		logger.Warn("ingest: select text embedding model failed", "err", err)
-		_ = w.records.UpdateStatus(statusCtx, rec.ID, domain.RecordStatusFailed, err.Error())
+		w.fail(statusCtx, rec, permanent(err))
 		return
 	}
 
 	w.setStage(statusCtx, rec, stageEmbedding, logger)
-	indexedChunks, err := w.embedAndStoreBatched(ctx, statusCtx, rec, embedder, chunks)
+	indexedChunks, reusedChunks, err := w.embedAndStoreBatched(ctx, statusCtx, rec, profile, chunks)
 	if err != nil {
 		if errors.Is(err, errRecordCancelled) {
 			w.cleanupCancelledRecord(ctx, rec, logger)
 			return
 		}
 		logger.Warn("ingest: embed image text failed", "err", err)
-		_ = w.records.UpdateStatus(statusCtx, rec.ID, domain.RecordStatusFailed, err.Error())
+		w.fail(statusCtx, rec, err)
 		return
 	}
 	if w.isCancelled(ctx, rec) {
@@ -426,9 +576,9 @@ func (w *Worker) processImageDocument(
 	}
 	if doc.ImageMode != ImageIngestModeOCR {
 		if w.imageEmbeddings == nil || w.imageEmbedder == nil {
-			err := fmt.Errorf("visual image embedding is required for image ingest mode %q but is not configured", doc.ImageMode)
+			err := permanentf("visual image embedding is required for image ingest mode %q but is not configured", doc.ImageMode)
 			logger.Warn("ingest: visual image embedding failed", "err", err)
-			_ = w.records.UpdateStatus(statusCtx, rec.ID, domain.RecordStatusFailed, err.Error())
+			w.fail(statusCtx, rec, err)
 			return
 		}
 		if err := w.storeImageEmbeddingContentWithRetry(ctx, rec, content, logger); err != nil {
@@ -437,7 +587,7 @@ func (w *Worker) processImageDocument(
 				return
 			}
 			logger.Warn("ingest: visual image embedding failed", "err", err)
-			_ = w.records.UpdateStatus(statusCtx, rec.ID, domain.RecordStatusFailed, err.Error())
+			w.fail(statusCtx, rec, err)
 			return
 		}
 	}
@@ -453,8 +603,9 @@ func (w *Worker) processImageDocument(
 		Description: imageIngestDescription(doc),
 		Format:      &rec.Format,
 		MimeType:    rec.MimeType,
+		ContentHash: domain.ContentHash(doc.Text),
 	})
-	logger.Info("ingest: image indexed", "chunks", indexedChunks, "mode", doc.ImageMode, "ocr_chars", doc.OCRTextCharCount)
+	logger.Info("ingest: image indexed", "chunks", indexedChunks, "reused_chunks", reusedChunks, "mode", doc.ImageMode, "ocr_chars", doc.OCRTextCharCount)
 }
 
 func imageIngestDescription(doc ExtractedDocument) string {
@@ -494,76 +645,91 @@ func (w *Worker) embedAndStoreBatched(
 	ctx context.Context,
 	statusCtx context.Context,
 	rec domain.Record,
-	embedder embedding.Embedder,
+	profile embedding.Profile,
 	chunks []string,
-) (int, error) {
+) (indexed, reused int, err error) {
 	batchSize := w.embedBatchSize
 	if batchSize <= 0 {
 		batchSize = 16
 	}
 	if w.isCancelled(statusCtx, rec) {
-		return 0, errRecordCancelled
+		return 0, 0, errRecordCancelled
+	}
+	stored, err := w.chunks.ListStoredChunks(statusCtx, rec.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(stored) > 0 {
+		return w.embedChangedChunks(ctx, statusCtx, rec, profile, chunks, stored, batchSize)
 	}
 	if err := w.chunks.DeleteByRecord(statusCtx, rec.ID); err != nil {
-		return 0, err
+		return 0, 0, err
 	}
-	totalChunks := len(chunks)
-	indexedChunks := 0
-	if err := w.records.UpdateIngestProgress(statusCtx, rec.ID, 0, totalChunks); err != nil {
-		return 0, err
+
+	progress := domain.IngestProgress{TotalChunks: len(chunks)}
+	if err := w.records.UpdateIngestProgress(statusCtx, rec.ID, progress); err != nil {
+		return 0, 0, err
 	}
 
 	for i := 0; i < len(chunks); i += batchSize {
 		if w.isCancelled(statusCtx, rec) {
-			return 0, errRecordCancelled
-		}
-		end := i + batchSize
-		if end > len(chunks) {
-			end = len(chunks)
-		}
-
-		vecs, err := embedder.Embed(ctx, chunks[i:end])
-		if err != nil {
-			if !isContextLengthError(err) {
-				_ = w.chunks.DeleteByRecord(statusCtx, rec.ID)
-				return 0, err
-			}
-			added, err := w.embedAndStoreSplitFallback(ctx, statusCtx, rec, embedder, chunks[i:end], indexedChunks, &totalChunks)
-			if err != nil {
-				_ = w.chunks.DeleteByRecord(statusCtx, rec.ID)
-				return 0, err
-			}
-			indexedChunks += added
-			continue
+			return 0, 0, errRecordCancelled
 		}
-		if len(vecs) != end-i {
+		end := min(i+batchSize, len(chunks))
+		if err := w.appendChunkBatch(ctx, statusCtx, rec, profile, chunks[i:end], false, &progress); err != nil {
 			_ = w.chunks.DeleteByRecord(statusCtx, rec.ID)
-			return 0, fmt.Errorf("embed: got %d embeddings for %d chunks", len(vecs), end-i)
+			return 0, 0, err
 		}
+	}
+	return progress.IndexedChunks, 0, nil
+}
 
-		chunkObjs := make([]postgres.Chunk, len(vecs))
-		for j, vec := range vecs {
-			chunkObjs[j] = postgres.Chunk{Content: chunks[i+j], Embedding: vec}
-		}
-		if err := w.chunks.AppendChunks(ctx, rec.DomainID, rec.UserID, rec.ID, indexedChunks, chunkObjs); err != nil {
-			_ = w.chunks.DeleteByRecord(statusCtx, rec.ID)
-			return 0, err
+// appendChunkBatch embeds batch and appends it after the chunks indexed so
+// far, splitting chunks that exceed the model's context length. Staged
+// batches are stored next to the live chunks until they are swapped in.
+func (w *Worker) appendChunkBatch(
+	ctx context.Context,
+	statusCtx context.Context,
+	rec domain.Record,
+	profile embedding.Profile,
+	batch []string,
+	staged bool,
+	progress *domain.IngestProgress,
+) error {
+	vecs, err := w.embedCached(ctx, rec.DomainID, profile, batch)
+	if err != nil {
+		if !isContextLengthError(err) {
+			return err
 		}
-		indexedChunks += len(chunkObjs)
-		if err := w.records.UpdateIngestProgress(statusCtx, rec.ID, indexedChunks, totalChunks); err != nil {
-			_ = w.chunks.DeleteByRecord(statusCtx, rec.ID)
-			return 0, err
+		added, err := w.embedAndStoreSplitFallback(ctx, statusCtx, rec, profile, batch, staged, progress.IndexedChunks, &progress.TotalChunks)
+		if err != nil {
+			return err
 		}
+		progress.IndexedChunks += added
+		return nil
+	}
+	if len(vecs) != len(batch) {
+		return fmt.Errorf("embed: got %d embeddings for %d chunks", len(vecs), len(batch))
 	}
-	return indexedChunks, nil
+
+	chunkObjs := make([]postgres.Chunk, len(vecs))
+	for j, vec := range vecs {
+		chunkObjs[j] = postgres.Chunk{Content: batch[j], Embedding: vec, Profile: profile.Name, Model: profile.Model}
+	}
+	if err := w.appendChunks(ctx, rec, staged, progress.IndexedChunks, chunkObjs); err != nil {
+		return err
+	}
+	progress.IndexedChunks += len(chunkObjs)
+	return w.records.UpdateIngestProgress(statusCtx, rec.ID, *progress)
 }
 
 func (w *Worker) embedAndStoreSplitFallback(
 	ctx context.Context,
 	statusCtx context.Context,
 	rec domain.Record,
-	embedder embedding.Embedder,
+	profile embedding.Profile,
 	chunks []string,
+	staged bool,
 	startIndex int,
 	totalChunks *int,
 ) (int, error) {
@@ -572,7 +738,7 @@ func (w *Worker) embedAndStoreSplitFallback(
 		if w.isCancelled(statusCtx, rec) {
 			return 0, errRecordCancelled
 		}
-		parts, vecs, err := embedWithContextSplit(ctx, embedder, text)
+		parts, vecs, err := embedWithContextSplit(ctx, profile, text)
 		if err != nil {
 			return 0, err
 		}
@@ -582,19 +748,27 @@ func (w *Worker) embedAndStoreSplitFallback(
 		*totalChunks += len(parts) - 1
 		chunkObjs := make([]postgres.Chunk, len(parts))
 		for i, part := range parts {
-			chunkObjs[i] = postgres.Chunk{Content: part, Embedding: vecs[i]}
+			chunkObjs[i] = postgres.Chunk{Content: part, Embedding: vecs[i], Profile: profile.Name, Model: profile.Model}
 		}
-		if err := w.chunks.AppendChunks(ctx, rec.DomainID, rec.UserID, rec.ID, startIndex+indexed, chunkObjs); err != nil {
+		if err := w.appendChunks(ctx, rec, staged, startIndex+indexed, chunkObjs); err != nil {
 			return 0, err
 		}
 		indexed += len(chunkObjs)
-		if err := w.records.UpdateIngestProgress(statusCtx, rec.ID, startIndex+indexed, *totalChunks); err != nil {
+		if err := w.records.UpdateIngestProgress(statusCtx, rec.ID, domain.IngestProgress{IndexedChunks: startIndex + indexed, TotalChunks: *totalChunks}); err != nil {
 			return 0, err
 		}
 	}
 	return indexed, nil
 }
 
+// appendChunks stores chunks at startIndex, staging them when staged is set.
+func (w *Worker) appendChunks(ctx context.Context, rec domain.Record, staged bool, startIndex int, chunks []postgres.Chunk) error {
+	if staged {
+		return w.chunks.StageChunks(ctx, rec.DomainID, rec.UserID, rec.ID, startIndex, chunks)
+	}
+	return w.chunks.AppendChunks(ctx, rec.DomainID, rec.UserID, rec.ID, startIndex, chunks)
+}
+
 func embedWithContextSplit(ctx context.Context, embedder embedding.Embedder, text string) ([]string, [][]float32, error) {
 	vecs, err := embedder.Embed(ctx, []string{text})
 	if err == nil {
@@ -682,35 +856,69 @@ func (w *Worker) storeImageEmbeddingContent(ctx context.Context, rec domain.Reco
 
 // downloadContent fetches the raw text for a record.
 // For Drive sources it reads via the Drive API; for direct uploads it reads
-// from the configured object storage backend.
-func (w *Worker) downloadContent(ctx context.Context, rec domain.Record) (string, *int, error) {
+// from the configured object storage backend. Records unpacked from an
+// archive are read from the archive store. Files above the stream threshold
+// from providers that can stream are returned as a TextStream instead.
+func (w *Worker) downloadContent(ctx context.Context, rec domain.Record) (string, *int, *TextStream, error) {
 	if rec.SourceID == "" {
-		return "", nil, fmt.Errorf("record %s is missing source_id", rec.ID)
+		return "", nil, nil, permanentf("record %s is missing source_id", rec.ID)
+	}
+	if rec.ParentID != nil {
+		content, err := w.archiveEntryContent(ctx, rec)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		doc, err := ExtractText(FileMeta{ID: rec.ExternalID, Name: rec.Name, MimeType: rec.MimeType}, content)
+		if err != nil {
+			return "", nil, nil, permanent(err)
+		}
+		return doc.Text, doc.PageCount, nil, nil
 	}
 
 	src, err := w.sources.GetByID(ctx, rec.SourceID, rec.DomainID)
 	if err != nil {
-		return "", nil, err
+		return "", nil, nil, err
 	}
 
 	provider, ok := w.sourceProviders.Provider(src.Type)
 	if !ok {
-		return "", nil, fmt.Errorf("unsupported source type %q for ingestion", src.Type)
+		return "", nil, nil, permanentf("unsupported source type %q for ingestion", src.Type)
 	}
 	started := time.Now().UTC()
-	text, pageCount, err := provider.DownloadRecord(ctx, rec, src)
+	var (
+		text      string
+		pageCount *int
+		stream    *TextStream
+	)
+	if streamer, ok := provider.(StreamContentProvider); ok && w.streamThreshold > 0 {
+		var body io.ReadCloser
+		body, err = streamer.OpenRecordContent(ctx, rec, src)
+		switch {
+		case errors.Is(err, ErrNotStreamable):
+			text, pageCount, err = provider.DownloadRecord(ctx, rec, src)
+		case err == nil:
+			var doc ExtractedDocument
+			doc, stream, err = OpenDocument(ctx, FileMeta{ID: rec.ExternalID, Name: rec.Name, MimeType: rec.MimeType}, body, w.streamThreshold)
+			text, pageCount = doc.Text, doc.PageCount
+		}
+	} else {
+		text, pageCount, err = provider.DownloadRecord(ctx, rec, src)
+	}
 	embedmetrics.ObserveSourceDownload(
 		string(src.Type),
 		string(provider.Type()),
 		time.Since(started),
 		err,
 	)
-	return text, pageCount, err
+	return text, pageCount, stream, err
 }
 
 func (w *Worker) downloadRawContent(ctx context.Context, rec domain.Record) ([]byte, error) {
 	if rec.SourceID == "" {
-		return nil, fmt.Errorf("record %s is missing source_id", rec.ID)
+		return nil, permanentf("record %s is missing source_id", rec.ID)
+	}
+	if rec.ParentID != nil {
+		return w.archiveEntryContent(ctx, rec)
 	}
 
 	src, err := w.sources.GetByID(ctx, rec.SourceID, rec.DomainID)
@@ -720,11 +928,11 @@ func (w *Worker) downloadRawContent(ctx context.Context, rec domain.Record) ([]b
 
 	provider, ok := w.sourceProviders.Provider(src.Type)
 	if !ok {
-		return nil, fmt.Errorf("unsupported source type %q for raw image download", src.Type)
+		return nil, permanentf("unsupported source type %q for raw image download", src.Type)
 	}
 	rawProvider, ok := provider.(RawContentProvider)
 	if !ok {
-		return nil, fmt.Errorf("source type %q does not support raw content download", src.Type)
+		return nil, permanentf("source type %q does not support raw content download", src.Type)
 	}
 	return rawProvider.DownloadRecordContent(ctx, rec, src)
 }
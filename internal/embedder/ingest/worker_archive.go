@@ -0,0 +1,173 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"path"
+	"strings"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+// archiveEntrySeparator joins an archive's external ID and the path of a
+// file inside it, as in Java JAR URLs.
+const archiveEntrySeparator = "!/"
+
+// processArchiveRecord unpacks an archive or email into child records. The
+// children are queued like synced files; the archive itself holds no chunks
+// and is marked indexed once every child is recorded. Children that are no
+// longer in the archive are deleted.
+func (w *Worker) processArchiveRecord(ctx, statusCtx context.Context, rec domain.Record, logger *slog.Logger) {
+	if w.isCancelled(statusCtx, rec) {
+		w.cleanupCancelledRecord(statusCtx, rec, logger)
+		return
+	}
+	if w.archiveStore == nil {
+		w.fail(statusCtx, rec, permanentf("archive expansion requires object storage"))
+		return
+	}
+
+	w.setStage(statusCtx, rec, stageExtracting, logger)
+	content, err := w.downloadRawContent(ctx, rec)
+	if err != nil {
+		logger.Warn("ingest: archive download failed", "err", err)
+		w.fail(statusCtx, rec, err)
+		return
+	}
+	existing, err := w.archiveChildren(ctx, rec)
+	if err != nil {
+		logger.Warn("ingest: list archive children failed", "err", err)
+		w.fail(statusCtx, rec, err)
+		return
+	}
+
+	live := make(map[string]struct{})
+	liveKeys := make(map[string]struct{})
+	err = ExpandContainer(FileMeta{ID: rec.ExternalID, Name: rec.Name, MimeType: rec.MimeType}, content, func(e ContainerEntry) error {
+		child, err := w.storeArchiveEntry(ctx, rec, e)
+		if err != nil {
+			return err
+		}
+		if _, err := w.records.UpsertFromSource(ctx, child); err != nil {
+			return fmt.Errorf("record archive entry %q: %w", e.Path, err)
+		}
+		live[child.ExternalID] = struct{}{}
+		liveKeys[child.ExternalRef] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		logger.Warn("ingest: archive expansion failed", "err", err)
+		w.fail(statusCtx, rec, err)
+		return
+	}
+	if w.isCancelled(statusCtx, rec) {
+		w.cleanupCancelledRecord(statusCtx, rec, logger)
+		return
+	}
+
+	var stale []string
+	for externalID, child := range existing {
+		if _, ok := live[externalID]; ok {
+			continue
+		}
+		stale = append(stale, externalID)
+		if _, ok := liveKeys[child.ExternalRef]; !ok && child.ExternalRef != "" {
+			if err := w.archiveStore.Delete(statusCtx, child.ExternalRef); err != nil {
+				logger.Warn("ingest: delete archive entry content", "key", child.ExternalRef, "err", err)
+			}
+		}
+	}
+	if len(stale) > 0 {
+		if _, err := w.records.DeleteBySourceExternalIDs(statusCtx, rec.DomainID, rec.SourceID, stale); err != nil {
+			logger.Warn("ingest: delete removed archive entries", "err", err)
+		}
+	}
+
+	_ = w.records.UpdateAfterIngest(statusCtx, rec.ID, domain.IngestResult{
+		SizeBytes:   int64(len(content)),
+		Description: fmt.Sprintf("Archive expanded into %d documents", len(live)),
+	})
+	logger.Info("ingest: archive expanded", "documents", len(live), "removed", len(stale))
+	if len(live) > 0 {
+		w.Trigger()
+	}
+}
+
+// storeArchiveEntry saves an unpacked file under a content-addressed key and
+// returns the child record describing it.
+func (w *Worker) storeArchiveEntry(ctx context.Context, parent domain.Record, e ContainerEntry) (domain.Record, error) {
+	sum := sha256.Sum256(e.Content)
+	version := hex.EncodeToString(sum[:])
+	key := path.Join(archiveObjectPrefix(w.archivePrefix, parent.ID), version)
+	if err := w.archiveStore.Put(ctx, key, e.MimeType, int64(len(e.Content)), bytes.NewReader(e.Content)); err != nil {
+		return domain.Record{}, fmt.Errorf("store archive entry %q: %w", e.Path, err)
+	}
+
+	// Files inside the archive sit in a folder named after the archive,
+	// below the folder the archive itself is in.
+	var parentFolder string
+	if parent.FolderPath != nil {
+		parentFolder = *parent.FolderPath
+	}
+	folder := path.Join("/", parentFolder, parent.Name, path.Dir(e.Path))
+	name := path.Base(e.Path)
+	parentID := parent.ID
+	return domain.Record{
+		DomainID:         parent.DomainID,
+		UserID:           parent.UserID,
+		SourceID:         parent.SourceID,
+		Name:             name,
+		Format:           DetectRecordFormat(name, e.MimeType),
+		Status:           domain.RecordStatusQueued,
+		ExternalID:       parent.ExternalID + archiveEntrySeparator + e.Path,
+		ExternalURL:      parent.ExternalURL,
+		ExternalRef:      key,
+		MimeType:         e.MimeType,
+		FolderPath:       &folder,
+		ParentID:         &parentID,
+		SourceVersion:    version,
+		SourceModifiedAt: parent.SourceModifiedAt,
+	}, nil
+}
+
+// archiveChildren returns the records previously unpacked from rec, keyed
+// by external ID.
+func (w *Worker) archiveChildren(ctx context.Context, rec domain.Record) (map[string]domain.Record, error) {
+	const pageLimit = uint64(200)
+	filter := domain.RecordFilter{ParentID: &rec.ID}
+	children := make(map[string]domain.Record)
+	var offset uint64
+	for {
+		page, err := w.records.List(ctx, rec.DomainID, filter, domain.Page{Offset: offset, Limit: pageLimit})
+		if err != nil {
+			return nil, fmt.Errorf("list archive children: %w", err)
+		}
+		for _, child := range page.Records {
+			children[child.ExternalID] = child
+		}
+		offset += uint64(len(page.Records))
+		if offset >= page.Total || len(page.Records) == 0 {
+			return children, nil
+		}
+	}
+}
+
+// archiveEntryContent reads a file unpacked from an archive. The key is
+// kept in the record's external ref.
+func (w *Worker) archiveEntryContent(ctx context.Context, rec domain.Record) ([]byte, error) {
+	if w.archiveStore == nil {
+		return nil, fmt.Errorf("archive expansion requires object storage")
+	}
+	key := strings.TrimSpace(rec.ExternalRef)
+	if key == "" {
+		return nil, fmt.Errorf("record %s has no archive content", rec.ID)
+	}
+	return w.archiveStore.Get(ctx, key)
+}
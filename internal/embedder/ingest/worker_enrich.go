@@ -0,0 +1,43 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+// enrichRecord stores the enrichment of text on rec when an enricher is set
+// and the record was not already enriched from content with this hash.
+// Enrichment is best-effort: failures are logged and the record is still
+// indexed.
+func (w *Worker) enrichRecord(ctx, statusCtx context.Context, rec domain.Record, text, contentHash string, logger *slog.Logger) {
+	if w.enricher == nil || contentHash == "" {
+		return
+	}
+	if rec.Enrichment != nil && rec.Enrichment.ContentHash == contentHash {
+		return
+	}
+
+	w.setStage(statusCtx, rec, stageEnriching, logger)
+	enrichment, err := w.enricher.Enrich(ctx, rec.Name, text)
+	if err != nil {
+		logger.Warn("ingest: enrich failed", "err", err)
+		// The previous enrichment describes content the record no longer has.
+		if rec.Enrichment != nil {
+			if err := w.records.UpdateEnrichment(statusCtx, rec.ID, nil); err != nil {
+				logger.Warn("ingest: clear stale enrichment failed", "err", err)
+			}
+		}
+		return
+	}
+	enrichment.ContentHash = contentHash
+	if err := w.records.UpdateEnrichment(statusCtx, rec.ID, &enrichment); err != nil {
+		logger.Warn("ingest: store enrichment failed", "err", err)
+		return
+	}
+	logger.Info("ingest: enriched", "language", enrichment.Language, "keywords", len(enrichment.Keywords), "model", enrichment.Model)
+}
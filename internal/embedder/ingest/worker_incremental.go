@@ -0,0 +1,129 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/embedding"
+	"github.com/ultravioletrs/cube/internal/embedder/postgres"
+)
+
+// planChunkReuse matches a record's new chunk texts against its stored
+// chunks. A new chunk reuses a stored chunk with the same text hash and a
+// vector from profile's current model; each stored chunk is reused once.
+// It returns the write plan, with Chunk left empty for chunks still to be
+// embedded, and the positions of those chunks.
+func planChunkReuse(chunks []string, stored []postgres.StoredChunk, profile embedding.Profile) ([]postgres.ChunkWrite, []int) {
+	byHash := make(map[string][]string, len(stored))
+	for _, c := range stored {
+		if c.Profile == profile.Name && c.Model == profile.Model {
+			byHash[c.ContentHash] = append(byHash[c.ContentHash], c.ID)
+		}
+	}
+
+	writes := make([]postgres.ChunkWrite, len(chunks))
+	var missing []int
+	for i, text := range chunks {
+		hash := domain.ContentHash(text)
+		if ids := byHash[hash]; len(ids) > 0 {
+			writes[i].ReuseID = ids[0]
+			byHash[hash] = ids[1:]
+			continue
+		}
+		missing = append(missing, i)
+	}
+	return writes, missing
+}
+
+// embedChangedChunks re-ingests a record that already has chunks. Chunks
+// whose text is unchanged keep their rows and vectors; only new or edited
+// chunks are embedded. The new chunk list replaces the stored one in a
+// single transaction, so searches keep using the old chunks until then, and
+// a failed re-ingest leaves them in place.
+func (w *Worker) embedChangedChunks(
+	ctx context.Context,
+	statusCtx context.Context,
+	rec domain.Record,
+	profile embedding.Profile,
+	chunks []string,
+	stored []postgres.StoredChunk,
+	batchSize int,
+) (indexed, reused int, err error) {
+	writes, missing := planChunkReuse(chunks, stored, profile)
+	reused = len(chunks) - len(missing)
+	progress := domain.IngestProgress{IndexedChunks: reused, TotalChunks: len(chunks), ReusedChunks: reused}
+	if err := w.records.UpdateIngestProgress(statusCtx, rec.ID, progress); err != nil {
+		return 0, 0, err
+	}
+
+	// A chunk too long for the model's context is split, so one position may
+	// end up holding several chunks.
+	split := make(map[int][]postgres.Chunk)
+	for start := 0; start < len(missing); start += batchSize {
+		if w.isCancelled(statusCtx, rec) {
+			return 0, 0, errRecordCancelled
+		}
+		batch := missing[start:min(start+batchSize, len(missing))]
+		texts := make([]string, len(batch))
+		for j, i := range batch {
+			texts[j] = chunks[i]
+		}
+
+		vecs, err := w.embedCached(ctx, rec.DomainID, profile, texts)
+		switch {
+		case err == nil && len(vecs) != len(texts):
+			return 0, 0, fmt.Errorf("embed: got %d embeddings for %d chunks", len(vecs), len(texts))
+		case err == nil:
+			for j, i := range batch {
+				writes[i].Chunk = postgres.Chunk{Content: chunks[i], Embedding: vecs[j], Profile: profile.Name, Model: profile.Model}
+			}
+			progress.IndexedChunks += len(batch)
+		case isContextLengthError(err):
+			for _, i := range batch {
+				parts, partVecs, err := embedWithContextSplit(ctx, profile, chunks[i])
+				if err != nil {
+					return 0, 0, err
+				}
+				if len(parts) != len(partVecs) {
+					return 0, 0, fmt.Errorf("embed split: got %d embeddings for %d chunks", len(partVecs), len(parts))
+				}
+				for k, part := range parts {
+					split[i] = append(split[i], postgres.Chunk{Content: part, Embedding: partVecs[k], Profile: profile.Name, Model: profile.Model})
+				}
+				progress.TotalChunks += len(parts) - 1
+				progress.IndexedChunks += len(parts)
+			}
+		default:
+			return 0, 0, err
+		}
+
+		if err := w.records.UpdateIngestProgress(statusCtx, rec.ID, progress); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	if len(split) > 0 {
+		expanded := make([]postgres.ChunkWrite, 0, progress.TotalChunks)
+		for i, write := range writes {
+			if parts, ok := split[i]; ok {
+				for _, part := range parts {
+					expanded = append(expanded, postgres.ChunkWrite{Chunk: part})
+				}
+				continue
+			}
+			expanded = append(expanded, write)
+		}
+		writes = expanded
+	}
+	if w.isCancelled(statusCtx, rec) {
+		return 0, 0, errRecordCancelled
+	}
+	if err := w.chunks.ReplaceChunks(ctx, rec.DomainID, rec.UserID, rec.ID, writes); err != nil {
+		return 0, 0, err
+	}
+	return len(writes), reused, nil
+}
@@ -0,0 +1,40 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/embedding"
+	"github.com/ultravioletrs/cube/internal/embedder/postgres"
+)
+
+func TestPlanChunkReuse(t *testing.T) {
+	profile := embedding.Profile{Name: "text", Model: "ollama/nomic/768"}
+	stored := []postgres.StoredChunk{
+		{ID: "c0", ContentHash: domain.ContentHash("intro"), Profile: "text", Model: "ollama/nomic/768"},
+		{ID: "c1", ContentHash: domain.ContentHash("old paragraph"), Profile: "text", Model: "ollama/nomic/768"},
+		{ID: "c2", ContentHash: domain.ContentHash("boilerplate"), Profile: "text", Model: "ollama/nomic/768"},
+		{ID: "c3", ContentHash: domain.ContentHash("outro"), Profile: "text", Model: "ollama/nomic/768"},
+		// Embedded by the previous model: not reusable.
+		{ID: "c4", ContentHash: domain.ContentHash("appendix"), Profile: "text", Model: "ollama/old/384"},
+	}
+	chunks := []string{"intro", "new paragraph", "boilerplate", "boilerplate", "outro", "appendix"}
+
+	writes, missing := planChunkReuse(chunks, stored, profile)
+
+	reuse := make([]string, len(writes))
+	for i, w := range writes {
+		reuse[i] = w.ReuseID
+	}
+	// The repeated chunk reuses the one stored copy once and is embedded again.
+	if want := []string{"c0", "", "c2", "", "c3", ""}; !slices.Equal(reuse, want) {
+		t.Fatalf("reused ids = %v, want %v", reuse, want)
+	}
+	if want := []int{1, 3, 5}; !slices.Equal(missing, want) {
+		t.Fatalf("missing = %v, want %v", missing, want)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+// ingestLease is a job the worker is running, keyed by record ID while the
+// record's pipeline runs.
+type ingestLease struct {
+	job domain.IngestJob
+	// ctx ends when the worker stops or loses the lease; failures after that
+	// are not the record's fault and are not recorded.
+	ctx context.Context
+	// failed is set once fail has released the job.
+	failed bool
+	// lost is set, under Worker.mu, when a heartbeat finds the lease taken.
+	lost bool
+}
+
+// processJob runs one claimed job, renewing its lease until the record's
+// pipeline returns and then completing, retrying or dead-lettering the job.
+func (w *Worker) processJob(ctx context.Context, job domain.IngestJob) {
+	logger := slog.With("job_id", job.ID, "record_id", job.RecordID)
+
+	rec, err := w.records.GetByID(ctx, job.RecordID, job.DomainID)
+	if err != nil && !errors.Is(err, domain.ErrNotFound) {
+		// The lease lapses and the job is claimed again.
+		logger.Error("ingest: load job record", "err", err)
+		return
+	}
+	if err != nil || (rec.Status != domain.RecordStatusQueued && rec.Status != domain.RecordStatusProcessing) {
+		// Deleted, cancelled or already indexed since the job was queued.
+		w.completeJob(ctx, job, logger)
+		return
+	}
+	if policy := w.retryPolicy(job.ErrorClass); job.ErrorClass != "" && job.Attempts > policy.MaxAttempts {
+		// Reclaimed after its lease expired on every attempt, e.g. because
+		// the record crashes the worker.
+		w.deadLetter(ctx, rec, &ingestLease{job: job, ctx: ctx}, job.ErrorClass, job.LastError, logger)
+		return
+	}
+
+	leaseCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	lease := &ingestLease{job: job, ctx: leaseCtx}
+	w.mu.Lock()
+	w.leases[rec.ID] = lease
+	w.mu.Unlock()
+	defer func() {
+		w.mu.Lock()
+		delete(w.leases, rec.ID)
+		w.mu.Unlock()
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		w.renewLease(leaseCtx, lease, cancel, logger)
+	}()
+	w.processRecord(leaseCtx, rec)
+	cancel()
+	<-done
+
+	switch {
+	case lease.failed:
+	case ctx.Err() != nil:
+		// Shutting down: hand the job back so another replica resumes it
+		// without waiting for the lease to expire.
+		if err := w.jobs.Retry(context.WithoutCancel(ctx), job.ID, w.owner, time.Now(), domain.IngestErrorTransient, "worker stopped"); err != nil {
+			logger.Warn("ingest: release job", "err", err)
+		}
+	case w.leaseLost(lease):
+		logger.Warn("ingest: lease lost; another worker owns the job")
+	default:
+		w.completeJob(ctx, job, logger)
+	}
+}
+
+// renewLease extends the job's lease every third of its duration until ctx
+// ends. If the lease is lost it calls cancel, stopping the pipeline.
+func (w *Worker) renewLease(ctx context.Context, lease *ingestLease, cancel context.CancelFunc, logger *slog.Logger) {
+	ticker := time.NewTicker(w.leaseDuration / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		err := w.jobs.Heartbeat(ctx, lease.job.ID, w.owner, w.leaseDuration)
+		switch {
+		case err == nil:
+		case errors.Is(err, domain.ErrNotFound):
+			w.mu.Lock()
+			lease.lost = true
+			w.mu.Unlock()
+			cancel()
+			return
+		case ctx.Err() == nil:
+			logger.Warn("ingest: renew lease", "err", err)
+		}
+	}
+}
+
+func (w *Worker) leaseLost(lease *ingestLease) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return lease.lost
+}
+
+// fail records a failed ingest on the record's job: it is retried after the
+// backoff of its error class, or dead-lettered once the class's attempts are
+// used up, and the record is marked queued or failed to match.
+func (w *Worker) fail(ctx context.Context, rec domain.Record, err error) {
+	w.mu.Lock()
+	lease, ok := w.leases[rec.ID]
+	w.mu.Unlock()
+	if !ok {
+		_ = w.records.UpdateStatus(ctx, rec.ID, domain.RecordStatusFailed, err.Error())
+		return
+	}
+	if lease.ctx.Err() != nil {
+		return
+	}
+	lease.failed = true
+
+	logger := slog.With("job_id", lease.job.ID, "record_id", rec.ID)
+	class := classifyIngestError(err)
+	policy := w.retryPolicy(class)
+	if lease.job.Attempts < policy.MaxAttempts {
+		runAfter := time.Now().Add(policy.Backoff(lease.job.Attempts))
+		rerr := w.jobs.Retry(ctx, lease.job.ID, w.owner, runAfter, class, err.Error())
+		if rerr == nil {
+			_ = w.records.UpdateStatus(ctx, rec.ID, domain.RecordStatusQueued, err.Error())
+			logger.Info("ingest: retry scheduled", "class", class, "attempt", lease.job.Attempts,
+				"max_attempts", policy.MaxAttempts, "run_after", runAfter)
+			return
+		}
+		logger.Warn("ingest: schedule retry", "err", rerr)
+	}
+	w.deadLetter(ctx, rec, lease, class, err.Error(), logger)
+}
+
+func (w *Worker) deadLetter(ctx context.Context, rec domain.Record, lease *ingestLease, class domain.IngestErrorClass, msg string, logger *slog.Logger) {
+	lease.failed = true
+	if err := w.jobs.DeadLetter(ctx, lease.job.ID, w.owner, class, msg); err != nil {
+		logger.Warn("ingest: dead-letter job", "err", err)
+	}
+	_ = w.records.UpdateStatus(ctx, rec.ID, domain.RecordStatusFailed, msg)
+	logger.Warn("ingest: job dead-lettered", "class", class, "attempts", lease.job.Attempts)
+}
+
+func (w *Worker) completeJob(ctx context.Context, job domain.IngestJob, logger *slog.Logger) {
+	if err := w.jobs.Complete(ctx, job.ID, w.owner); err != nil {
+		logger.Warn("ingest: complete job", "err", err)
+	}
+}
+
+func (w *Worker) retryPolicy(class domain.IngestErrorClass) RetryPolicy {
+	if policy, ok := w.retryPolicies[class]; ok {
+		return policy
+	}
+	return w.retryPolicies[domain.IngestErrorUnknown]
+}
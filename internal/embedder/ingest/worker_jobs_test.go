@@ -0,0 +1,89 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+// claimQueueStub hands out jobs from a fixed queue and records each claim.
+type claimQueueStub struct {
+	ingestJobsStub
+	mu        sync.Mutex
+	queued    int
+	limits    []int
+	caps      domain.IngestConcurrencyLimits
+	completed int
+}
+
+func (s *claimQueueStub) Enqueue(context.Context) (int64, error) { return 0, nil }
+
+func (s *claimQueueStub) Stats(context.Context) ([]domain.IngestQueueStats, error) { return nil, nil }
+
+func (s *claimQueueStub) Claim(_ context.Context, _ string, limit int, _ time.Duration, caps domain.IngestConcurrencyLimits) ([]domain.IngestJob, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.limits = append(s.limits, limit)
+	s.caps = caps
+	n := min(limit, s.queued)
+	s.queued -= n
+	jobs := make([]domain.IngestJob, n)
+	for i := range jobs {
+		jobs[i] = domain.IngestJob{ID: fmt.Sprintf("job-%d", s.queued+i), RecordID: "rec", RunAfter: time.Now()}
+	}
+	return jobs, nil
+}
+
+func (s *claimQueueStub) Complete(context.Context, string, string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.completed++
+	return nil
+}
+
+func TestWorkerProcessQueuedFillsFreeSlots(t *testing.T) {
+	jobs := &claimQueueStub{queued: 7}
+	w := NewWorker(&recordStatusStub{}, jobs, "worker-1", nil, nil, nil, nil, 512, 0)
+	w.SetBatchSize(2)
+	w.SetMaxConcurrent(8)
+	limits := domain.IngestConcurrencyLimits{Default: 3, Domains: map[string]int{"d1": 1}}
+	w.SetDomainConcurrency(limits)
+
+	var wg sync.WaitGroup
+	finished := make(chan struct{}, 8)
+	started := w.processQueued(context.Background(), 5, &wg, finished)
+	wg.Wait()
+
+	if started != 5 {
+		t.Fatalf("started %d jobs, want 5", started)
+	}
+	if want := []int{2, 2, 1}; fmt.Sprint(jobs.limits) != fmt.Sprint(want) {
+		t.Fatalf("claim limits = %v, want %v", jobs.limits, want)
+	}
+	if jobs.caps.For("d1") != 1 || jobs.caps.For("d2") != 3 {
+		t.Fatalf("claim caps = %+v, want %+v", jobs.caps, limits)
+	}
+	if len(finished) != 5 || jobs.completed != 5 {
+		t.Fatalf("finished %d, completed %d, want 5 each", len(finished), jobs.completed)
+	}
+
+	// Two jobs are left; the short claim ends the pass.
+	jobs.limits = nil
+	if started := w.processQueued(context.Background(), 5, &wg, finished); started != 2 {
+		t.Fatalf("started %d jobs, want 2", started)
+	}
+	wg.Wait()
+	if want := []int{2, 2}; fmt.Sprint(jobs.limits) != fmt.Sprint(want) {
+		t.Fatalf("claim limits = %v, want %v", jobs.limits, want)
+	}
+	if started := w.processQueued(context.Background(), 0, &wg, finished); started != 0 {
+		t.Fatalf("started %d jobs without free slots", started)
+	}
+}
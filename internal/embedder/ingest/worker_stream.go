@@ -0,0 +1,169 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package ingest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/embedding"
+)
+
+// defaultStreamThreshold is the file size above which PDFs and text files
+// are ingested as a stream.
+const defaultStreamThreshold = 64 << 20
+
+// streamedDocument summarizes a document ingested from a TextStream.
+type streamedDocument struct {
+	chunks      int
+	sizeBytes   int64
+	contentHash string
+	// head is the start of the text, kept for enrichment.
+	head string
+}
+
+// processStreamedRecord chunks and embeds a document as its text is
+// extracted, so only one segment of text and one batch of chunks are held
+// in memory at a time.
+func (w *Worker) processStreamedRecord(ctx, statusCtx context.Context, rec domain.Record, stream *TextStream, logger *slog.Logger) {
+	defer stream.Close()
+
+	profile, err := w.embeddings.ForRecord(rec)
+	if err != nil {
+		logger.Warn("ingest: select embedding model failed", "err", err)
+		w.fail(statusCtx, rec, permanent(err))
+		return
+	}
+
+	w.setStage(statusCtx, rec, stageEmbedding, logger)
+	doc, err := w.embedStream(ctx, statusCtx, rec, profile, stream)
+	if err != nil {
+		if errors.Is(err, errRecordCancelled) {
+			w.cleanupCancelledRecord(statusCtx, rec, logger)
+			return
+		}
+		logger.Warn("ingest: streamed embed failed", "err", err)
+		w.fail(statusCtx, rec, err)
+		return
+	}
+	if w.isCancelled(statusCtx, rec) {
+		w.cleanupCancelledRecord(statusCtx, rec, logger)
+		return
+	}
+
+	w.enrichRecord(ctx, statusCtx, rec, doc.head, doc.contentHash, logger)
+
+	_ = w.records.UpdateAfterIngest(statusCtx, rec.ID, domain.IngestResult{
+		ChunkCount:  doc.chunks,
+		SizeBytes:   doc.sizeBytes,
+		PageCount:   stream.PageCount(),
+		ContentHash: doc.contentHash,
+	})
+	logger.Info("ingest: indexed", "chunks", doc.chunks, "streamed", true, "size_bytes", doc.sizeBytes)
+}
+
+// embedStream replaces the record's chunks with those of stream. Each batch
+// is staged as soon as it is embedded and the staged chunks replace the
+// live ones once the whole stream is stored, so searches keep finding the
+// previous version until then. Streamed documents are always embedded in
+// full: unchanged chunks are not reused, since that would mean holding the
+// new chunk set in memory.
+func (w *Worker) embedStream(
+	ctx context.Context,
+	statusCtx context.Context,
+	rec domain.Record,
+	profile embedding.Profile,
+	stream *TextStream,
+) (doc streamedDocument, err error) {
+	batchSize := w.embedBatchSize
+	if batchSize <= 0 {
+		batchSize = 16
+	}
+	if w.isCancelled(statusCtx, rec) {
+		return doc, errRecordCancelled
+	}
+	// Drop chunks staged by an earlier attempt that did not finish.
+	if err := w.chunks.DiscardStagedChunks(statusCtx, rec.ID); err != nil {
+		return doc, err
+	}
+	defer func() {
+		if err != nil {
+			_ = w.chunks.DiscardStagedChunks(statusCtx, rec.ID)
+		}
+	}()
+
+	// Streamed files are far above largeChunkWords, so they get the plan
+	// adaptiveChunk would pick for them.
+	chunker := newStreamChunker(
+		adaptiveChunkSize(veryLargeChunkWords, w.chunkSize),
+		adaptiveOverlap(veryLargeChunkWords, w.overlap),
+	)
+	hash := sha256.New()
+	var (
+		progress domain.IngestProgress
+		pending  []string
+		head     strings.Builder
+	)
+	store := func(all bool) error {
+		if w.maxChunks > 0 && progress.IndexedChunks+len(pending) > w.maxChunks {
+			return permanentf("document produced more than %d chunks", w.maxChunks)
+		}
+		for len(pending) >= batchSize || (all && len(pending) > 0) {
+			if w.isCancelled(statusCtx, rec) {
+				return errRecordCancelled
+			}
+			n := min(batchSize, len(pending))
+			progress.TotalChunks = progress.IndexedChunks + len(pending)
+			if err := w.appendChunkBatch(ctx, statusCtx, rec, profile, pending[:n], true, &progress); err != nil {
+				return err
+			}
+			pending = append(pending[:0], pending[n:]...)
+		}
+		return nil
+	}
+
+	for {
+		segment, err := stream.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return doc, err
+		}
+		doc.sizeBytes += int64(len(segment))
+		_, _ = io.WriteString(hash, segment)
+		if w.enricher != nil && head.Len() < w.enricher.maxInputChars*utf8.UTFMax {
+			head.WriteString(segment)
+		}
+		pending = append(pending, chunker.Add(segment)...)
+		if err := store(false); err != nil {
+			return doc, err
+		}
+	}
+	pending = append(pending, chunker.Flush()...)
+	if err := store(true); err != nil {
+		return doc, err
+	}
+	if progress.IndexedChunks == 0 {
+		return doc, permanentf("document produced zero chunks")
+	}
+	if w.isCancelled(statusCtx, rec) {
+		return doc, errRecordCancelled
+	}
+	if err := w.chunks.SwapStagedChunks(statusCtx, rec.ID); err != nil {
+		return doc, err
+	}
+
+	doc.chunks = progress.IndexedChunks
+	doc.contentHash = hex.EncodeToString(hash.Sum(nil))
+	doc.head = head.String()
+	return doc, nil
+}
@@ -0,0 +1,137 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+// Package language detects the language of text and maps languages to
+// PostgreSQL text search configurations.
+package language
+
+import (
+	"strings"
+	"unicode"
+)
+
+// SimpleConfig is the text search configuration for languages without a
+// stemmer: words are lower-cased but neither stemmed nor stop-worded.
+const SimpleConfig = "simple"
+
+// maxDetectWords bounds how much of a text Detect reads.
+const maxDetectWords = 1000
+
+// configs maps the detectable ISO 639-1 codes to the PostgreSQL text
+// search configurations of the same language.
+var configs = map[string]string{
+	"de": "german",
+	"en": "english",
+	"es": "spanish",
+	"fr": "french",
+	"it": "italian",
+	"nl": "dutch",
+	"pt": "portuguese",
+	"ru": "russian",
+	"sr": "serbian",
+	"sv": "swedish",
+}
+
+// stopwords are frequent function words of each language. Words shared by
+// several languages count for each of them.
+var stopwords = map[string]string{
+	"de": "der die das und ist nicht ein eine einer zu den dem des mit sich auf für von im sie es auch als wird werden wir ich oder aber bei nach aus wie sind noch nur dass über kann",
+	"en": "the and of to in is that for it with as was on are be this by not or from at which have has an but were they their will would can there been these we you he she its",
+	"es": "el la los las y es en de que un una por para con no se del al lo como más pero sus su está son este esta ha fue también entre cuando muy sin sobre ya porque",
+	"fr": "le la les et est de des une un du dans que qui pour pas sur au aux avec ce ces il elle nous vous sont par plus mais ou été être cette leur ont se sa son",
+	"it": "il la le gli di e è che un una per non con del della dei delle nel nella sono si da come anche più ma alla al questo questa hanno ha essere tra dopo ci loro",
+	"nl": "de het een en van is dat die in te niet op voor met zijn er aan ook als maar om door worden wordt bij nog naar dan uit wij ze hij deze heeft geen over",
+	"pt": "o a os as e é de do da dos das que um uma para com não em no na por se mais mas como foi são ao seu sua está também ou ser tem pelo pela isso",
+	"ru": "и в не на что с по это как он она они к из для а но за от так же был была было бы его её только уже или если мы вы я у о при также",
+	"sr": "i u je da se na za od su koji koja koje kao ali ili sa to nije biti bio bila što šta kako samo još već ne jer može ovo taj ta te iz po do prema kada " +
+		"и у је да се на за од су који која које као али или са то није бити био била што шта како само још већ не јер може ово из по до према када",
+	"sv": "och att det som en på är av för med till den har de inte om ett men var jag sig från vi så kan när vid eller efter under också hade mot skulle detta",
+}
+
+var stopwordLanguages = func() map[string][]string {
+	index := make(map[string][]string)
+	for lang, words := range stopwords {
+		for _, word := range strings.Fields(words) {
+			index[word] = append(index[word], lang)
+		}
+	}
+	return index
+}()
+
+// Detect returns the ISO 639-1 code of the language text is written in, or
+// "" when text is too short or too mixed to tell. Only the languages with
+// a text search configuration are detected.
+func Detect(text string) string {
+	var (
+		scores              = make(map[string]int, len(configs))
+		cyrillic, latin     int
+		serbianCyrillic     bool
+		russianOnlyCyrillic bool
+		words               int
+	)
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) }) {
+		for _, r := range word {
+			switch {
+			case unicode.Is(unicode.Cyrillic, r):
+				cyrillic++
+				switch r {
+				case 'ђ', 'ј', 'љ', 'њ', 'ћ', 'џ':
+					serbianCyrillic = true
+				case 'ы', 'э', 'ъ', 'ё', 'щ':
+					russianOnlyCyrillic = true
+				}
+			case unicode.Is(unicode.Latin, r):
+				latin++
+			}
+		}
+		for _, lang := range stopwordLanguages[word] {
+			scores[lang]++
+		}
+		if words++; words == maxDetectWords {
+			break
+		}
+	}
+
+	if cyrillic > latin {
+		switch {
+		case serbianCyrillic && !russianOnlyCyrillic:
+			return "sr"
+		case russianOnlyCyrillic && !serbianCyrillic:
+			return "ru"
+		}
+	}
+
+	best, bestScore, secondScore := "", 0, 0
+	for lang, score := range scores {
+		switch {
+		case score > bestScore || (score == bestScore && lang < best):
+			best, bestScore, secondScore = lang, score, max(bestScore, secondScore)
+		case score > secondScore:
+			secondScore = score
+		}
+	}
+	// Require a few hits and a clear lead over the runner-up.
+	if bestScore < 2 || bestScore*4 < secondScore*5 {
+		return ""
+	}
+	return best
+}
+
+// TextSearchConfig returns the text search configuration for the ISO 639-1
+// code, or SimpleConfig for languages without one.
+func TextSearchConfig(code string) string {
+	if cfg, ok := configs[strings.ToLower(strings.TrimSpace(code))]; ok {
+		return cfg
+	}
+	return SimpleConfig
+}
+
+// TextSearchConfigs returns every configuration TextSearchConfig can
+// return.
+func TextSearchConfigs() []string {
+	out := make([]string, 0, len(configs)+1)
+	for _, cfg := range configs {
+		out = append(out, cfg)
+	}
+	return append(out, SimpleConfig)
+}
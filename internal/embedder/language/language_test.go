@@ -0,0 +1,53 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package language
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	cases := []struct {
+		name string
+		text string
+		want string
+	}{
+		{"english", "The contract is signed by both parties and it will be renewed at the end of the year.", "en"},
+		{"german", "Der Vertrag wird von beiden Parteien unterzeichnet und ist nicht vor dem Ende des Jahres kündbar.", "de"},
+		{"serbian latin", "Ugovor je potpisan od strane obe strane i ne može se raskinuti pre kraja godine, jer je tako dogovoreno.", "sr"},
+		{"serbian cyrillic", "Уговор је потписан од стране обе стране и не може се раскинути пре краја године.", "sr"},
+		{"russian", "Договор подписан обеими сторонами и не может быть расторгнут до конца года.", "ru"},
+		{"french", "Le contrat est signé par les deux parties et il ne peut pas être résilié avant la fin de l'année.", "fr"},
+		{"too short", "Vertrag", ""},
+		{"empty", "", ""},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Detect(tc.text); got != tc.want {
+				t.Fatalf("Detect(%q) = %q, want %q", tc.text, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTextSearchConfig(t *testing.T) {
+	for code, want := range map[string]string{
+		"de":  "german",
+		" SR": "serbian",
+		"en":  "english",
+		"hu":  SimpleConfig,
+		"":    SimpleConfig,
+	} {
+		if got := TextSearchConfig(code); got != want {
+			t.Fatalf("TextSearchConfig(%q) = %q, want %q", code, got, want)
+		}
+	}
+	configs := TextSearchConfigs()
+	for _, want := range []string{"german", "serbian", SimpleConfig} {
+		if !slices.Contains(configs, want) {
+			t.Fatalf("TextSearchConfigs() = %q, missing %q", configs, want)
+		}
+	}
+}
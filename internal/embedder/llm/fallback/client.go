@@ -0,0 +1,201 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package fallback
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/llm"
+)
+
+const defaultBackoff = 250 * time.Millisecond
+
+// ProviderConfig describes one entry in the fallback chain.
+type ProviderConfig struct {
+	// Name identifies the provider in logs and debug events.
+	// Defaults to "<provider>:<model>".
+	Name     string `json:"name,omitempty"`
+	Provider string `json:"provider"`
+	BaseURL  string `json:"base_url"`
+	Model    string `json:"model"`
+	APIKey   string `json:"api_key,omitempty"`
+	// Timeout bounds a single attempt against this provider, including
+	// streaming. Zero means no per-attempt deadline.
+	Timeout Duration `json:"timeout,omitempty"`
+	// MaxRetries is the number of extra attempts made against this provider
+	// for retriable errors before moving on to the next one.
+	MaxRetries int `json:"max_retries,omitempty"`
+}
+
+// LLMConfig returns the llm.Config used to build this provider's client.
+func (p ProviderConfig) LLMConfig() llm.Config {
+	return llm.Config{
+		Provider: p.Provider,
+		BaseURL:  p.BaseURL,
+		Model:    p.Model,
+		APIKey:   p.APIKey,
+	}
+}
+
+// DisplayName returns Name, or "<provider>:<model>" when Name is empty.
+func (p ProviderConfig) DisplayName() string {
+	if p.Name != "" {
+		return p.Name
+	}
+	return p.Provider + ":" + p.Model
+}
+
+// Duration is a time.Duration that decodes from JSON strings such as "30s".
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	if string(b) == "null" {
+		return nil
+	}
+	if len(b) < 2 || b[0] != '"' || b[len(b)-1] != '"' {
+		return fmt.Errorf("duration must be a string, got %s", b)
+	}
+	parsed, err := time.ParseDuration(string(b[1 : len(b)-1]))
+	if err != nil {
+		return err
+	}
+	*d = Duration(parsed)
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + time.Duration(d).String() + `"`), nil
+}
+
+type provider struct {
+	cfg    ProviderConfig
+	client llm.Client
+}
+
+// Client is an llm.Client that tries an ordered list of providers.
+// Retriable errors are retried against the same provider and then the next
+// one, but only until the first token has been forwarded to the caller; once
+// a provider starts answering, its outcome is final.
+type Client struct {
+	providers []provider
+	backoff   time.Duration
+}
+
+// New builds a fallback chain from providers, constructing each client with
+// factory. The first entry is the primary provider.
+func New(factory llm.ClientFactory, providers []ProviderConfig) (*Client, error) {
+	if len(providers) == 0 {
+		return nil, errors.New("at least one llm provider is required")
+	}
+	c := &Client{backoff: defaultBackoff}
+	for _, p := range providers {
+		if p.Provider == "" || p.Model == "" {
+			return nil, fmt.Errorf("llm provider %q: provider and model are required", p.DisplayName())
+		}
+		c.providers = append(c.providers, provider{cfg: p, client: factory(p.LLMConfig())})
+	}
+	return c, nil
+}
+
+// SetBackoff sets the base delay between retries; it doubles per attempt.
+func (c *Client) SetBackoff(d time.Duration) {
+	if d >= 0 {
+		c.backoff = d
+	}
+}
+
+// StreamChat streams the first successful provider's answer to out.
+func (c *Client) StreamChat(ctx context.Context, messages []llm.Message, out chan<- string) error {
+	defer close(out)
+
+	attempts := 0
+	var lastErr error
+	for i, p := range c.providers {
+		for try := 0; try <= p.cfg.MaxRetries; try++ {
+			if try > 0 {
+				if err := sleep(ctx, c.backoff<<(try-1)); err != nil {
+					return err
+				}
+			}
+			attempts++
+			started, err := c.attempt(ctx, p, messages, out, attempts)
+			if err == nil {
+				if !started {
+					// An empty answer is still an answer.
+					c.served(ctx, p, attempts)
+				}
+				return nil
+			}
+			if started || ctx.Err() != nil {
+				return err
+			}
+			lastErr = err
+			if !llm.IsRetriable(err) {
+				slog.Warn("llm provider failed", "provider", p.cfg.DisplayName(), "attempt", attempts, "err", err)
+				break
+			}
+			slog.Warn("llm provider attempt failed", "provider", p.cfg.DisplayName(), "attempt", attempts, "retry", try < p.cfg.MaxRetries, "err", err)
+		}
+		if i < len(c.providers)-1 {
+			slog.Info("llm falling back to next provider", "from", p.cfg.DisplayName(), "to", c.providers[i+1].cfg.DisplayName())
+		}
+	}
+	return fmt.Errorf("all llm providers failed: %w", lastErr)
+}
+
+// attempt runs one provider call and forwards its tokens to out. started
+// reports whether at least one token reached the caller.
+func (c *Client) attempt(ctx context.Context, p provider, messages []llm.Message, out chan<- string, attempts int) (started bool, err error) {
+	attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+	if p.cfg.Timeout > 0 {
+		attemptCtx, cancel = context.WithTimeout(ctx, time.Duration(p.cfg.Timeout))
+	}
+	defer cancel()
+
+	tokens := make(chan string, 64)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.client.StreamChat(attemptCtx, messages, tokens)
+	}()
+
+	for tok := range tokens {
+		if !started {
+			started = true
+			c.served(ctx, p, attempts)
+		}
+		select {
+		case out <- tok:
+		case <-ctx.Done():
+			cancel()
+			for range tokens {
+			}
+			<-errCh
+			return started, ctx.Err()
+		}
+	}
+	return started, <-errCh
+}
+
+func (c *Client) served(ctx context.Context, p provider, attempts int) {
+	llm.ReportServed(ctx, llm.Served{Provider: p.cfg.DisplayName(), Model: p.cfg.Model, Attempts: attempts})
+	slog.Info("llm provider serving chat", "provider", p.cfg.DisplayName(), "model", p.cfg.Model, "attempts", attempts)
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package fallback
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/llm"
+)
+
+type scriptedLLM struct {
+	errs   []error // returned in order, one per call; nil means answer
+	tokens []string
+	calls  int
+}
+
+func (s *scriptedLLM) StreamChat(ctx context.Context, _ []llm.Message, out chan<- string) error {
+	defer close(out)
+	call := s.calls
+	s.calls++
+	if call < len(s.errs) && s.errs[call] != nil {
+		return s.errs[call]
+	}
+	for _, tok := range s.tokens {
+		select {
+		case out <- tok:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+type blockingLLM struct{}
+
+func (blockingLLM) StreamChat(ctx context.Context, _ []llm.Message, out chan<- string) error {
+	defer close(out)
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func newTestChain(t *testing.T, clients map[string]llm.Client, providers ...ProviderConfig) *Client {
+	t.Helper()
+	chain, err := New(func(cfg llm.Config) llm.Client { return clients[cfg.Model] }, providers)
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	chain.SetBackoff(0)
+	return chain
+}
+
+func collect(t *testing.T, ctx context.Context, c llm.Client) (string, error) {
+	t.Helper()
+	out := make(chan string, 64)
+	errCh := make(chan error, 1)
+	go func() { errCh <- c.StreamChat(ctx, []llm.Message{{Role: "user", Content: "hi"}}, out) }()
+	var b strings.Builder
+	for tok := range out {
+		b.WriteString(tok)
+	}
+	return b.String(), <-errCh
+}
+
+func TestStreamChatRetriesThenFallsBack(t *testing.T) {
+	primary := &scriptedLLM{errs: []error{
+		&llm.StatusError{Provider: "openai", StatusCode: http.StatusServiceUnavailable},
+		&llm.StatusError{Provider: "openai", StatusCode: http.StatusBadGateway},
+	}}
+	secondary := &scriptedLLM{tokens: []string{"hello", " world"}}
+	chain := newTestChain(t, map[string]llm.Client{"a": primary, "b": secondary},
+		ProviderConfig{Name: "vllm", Provider: "openai", Model: "a", MaxRetries: 1},
+		ProviderConfig{Provider: "ollama", Model: "b"},
+	)
+
+	ctx, report := llm.WithServedReport(context.Background())
+	got, err := collect(t, ctx, chain)
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+	if got != "hello world" {
+		t.Fatalf("answer = %q", got)
+	}
+	if primary.calls != 2 {
+		t.Fatalf("primary calls = %d, want 2", primary.calls)
+	}
+	served, ok := report.Get()
+	if !ok {
+		t.Fatal("expected served report")
+	}
+	if served.Provider != "ollama:b" || served.Attempts != 3 {
+		t.Fatalf("served = %+v", served)
+	}
+}
+
+func TestStreamChatSkipsRetriesForPermanentErrors(t *testing.T) {
+	primary := &scriptedLLM{errs: []error{&llm.StatusError{Provider: "openai", StatusCode: http.StatusUnauthorized}}}
+	secondary := &scriptedLLM{tokens: []string{"ok"}}
+	chain := newTestChain(t, map[string]llm.Client{"a": primary, "b": secondary},
+		ProviderConfig{Provider: "openai", Model: "a", MaxRetries: 3},
+		ProviderConfig{Provider: "ollama", Model: "b"},
+	)
+
+	if _, err := collect(t, context.Background(), chain); err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+	if primary.calls != 1 {
+		t.Fatalf("primary calls = %d, want 1", primary.calls)
+	}
+}
+
+func TestStreamChatAppliesPerProviderTimeout(t *testing.T) {
+	secondary := &scriptedLLM{tokens: []string{"late but fine"}}
+	chain := newTestChain(t, map[string]llm.Client{"a": blockingLLM{}, "b": secondary},
+		ProviderConfig{Provider: "ollama", Model: "a", Timeout: Duration(20 * time.Millisecond)},
+		ProviderConfig{Provider: "openai", Model: "b"},
+	)
+
+	got, err := collect(t, context.Background(), chain)
+	if err != nil {
+		t.Fatalf("StreamChat: %v", err)
+	}
+	if got != "late but fine" {
+		t.Fatalf("answer = %q", got)
+	}
+}
+
+func TestStreamChatReturnsLastErrorWhenAllProvidersFail(t *testing.T) {
+	boom := errors.New("boom")
+	chain := newTestChain(t, map[string]llm.Client{"a": &scriptedLLM{errs: []error{boom}}},
+		ProviderConfig{Provider: "ollama", Model: "a"},
+	)
+
+	_, err := collect(t, context.Background(), chain)
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected wrapped boom, got %v", err)
+	}
+}
+
+func TestProviderConfigDecodesDurationStrings(t *testing.T) {
+	var cfgs []ProviderConfig
+	raw := `[{"provider":"openai","base_url":"https://api.openai.com","model":"gpt-4o-mini","timeout":"45s","max_retries":2}]`
+	if err := json.Unmarshal([]byte(raw), &cfgs); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if time.Duration(cfgs[0].Timeout) != 45*time.Second || cfgs[0].MaxRetries != 2 {
+		t.Fatalf("decoded = %+v", cfgs[0])
+	}
+}
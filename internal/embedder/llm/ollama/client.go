@@ -96,7 +96,7 @@ func (c *Client) StreamChat(ctx context.Context, messages []llm.Message, out cha
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("ollama chat status %d", resp.StatusCode)
+		return &llm.StatusError{Provider: "ollama", StatusCode: resp.StatusCode}
 	}
 
 	scanner := bufio.NewScanner(resp.Body)
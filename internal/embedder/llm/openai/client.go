@@ -109,7 +109,7 @@ func (c *Client) StreamChat(ctx context.Context, messages []llm.Message, out cha
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("openai chat status %d", resp.StatusCode)
+		return &llm.StatusError{Provider: "openai", StatusCode: resp.StatusCode}
 	}
 
 	scanner := bufio.NewScanner(resp.Body)
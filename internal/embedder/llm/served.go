@@ -0,0 +1,54 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package llm
+
+import (
+	"context"
+	"sync"
+)
+
+// Served describes the provider that ultimately answered a chat request.
+type Served struct {
+	Provider string
+	Model    string
+	// Attempts counts every provider call made for the request, including
+	// the successful one.
+	Attempts int
+}
+
+// ServedReport collects the Served attribution for a single request.
+// Composite clients fill it in; callers read it after the stream starts.
+type ServedReport struct {
+	mu     sync.Mutex
+	served Served
+	set    bool
+}
+
+type servedReportKey struct{}
+
+// WithServedReport returns a context carrying a fresh ServedReport.
+func WithServedReport(ctx context.Context) (context.Context, *ServedReport) {
+	report := &ServedReport{}
+	return context.WithValue(ctx, servedReportKey{}, report), report
+}
+
+// ReportServed records which provider served the request carried by ctx.
+// It is a no-op when ctx has no ServedReport.
+func ReportServed(ctx context.Context, served Served) {
+	report, ok := ctx.Value(servedReportKey{}).(*ServedReport)
+	if !ok || report == nil {
+		return
+	}
+	report.mu.Lock()
+	defer report.mu.Unlock()
+	report.served = served
+	report.set = true
+}
+
+// Get returns the recorded attribution and whether one was reported.
+func (r *ServedReport) Get() (Served, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.served, r.set
+}
@@ -0,0 +1,93 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// IngestQueueDomain is one domain's share of the ingest queue.
+type IngestQueueDomain struct {
+	DomainID string
+	Runnable int64
+	Waiting  int64
+	Leased   int64
+	Dead     int64
+	// OldestWait is how long the oldest runnable job has been waiting.
+	OldestWait time.Duration
+}
+
+var (
+	ingestQueueWait = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "cube_embedder_ingest_queue_wait_seconds",
+			Help:    "Time ingest jobs waited to be claimed after becoming runnable, by domain and priority.",
+			Buckets: prometheus.ExponentialBuckets(1, 4, 8),
+		},
+		[]string{"domain_id", "priority"},
+	)
+	ingestQueue = &ingestQueueCollector{
+		jobs: prometheus.NewDesc(
+			"cube_embedder_ingest_queue_jobs",
+			"Ingest jobs by domain and state (runnable, waiting, leased, dead).",
+			[]string{"domain_id", "state"}, nil,
+		),
+		oldestWait: prometheus.NewDesc(
+			"cube_embedder_ingest_queue_oldest_wait_seconds",
+			"How long the oldest runnable ingest job of each domain has been waiting.",
+			[]string{"domain_id"}, nil,
+		),
+	}
+)
+
+func init() {
+	prometheus.MustRegister(ingestQueueWait, ingestQueue)
+}
+
+// ObserveIngestQueueWait captures how long a claimed job waited.
+func ObserveIngestQueueWait(domainID, priority string, wait time.Duration) {
+	ingestQueueWait.WithLabelValues(domainID, priority).Observe(max(wait, 0).Seconds())
+}
+
+// SetIngestQueue replaces the reported queue depth. Domains missing from
+// domains are no longer reported.
+func SetIngestQueue(domains []IngestQueueDomain) {
+	ingestQueue.mu.Lock()
+	defer ingestQueue.mu.Unlock()
+	ingestQueue.domains = domains
+}
+
+// ingestQueueCollector reports the latest queue snapshot, so domains whose
+// jobs all finished drop out instead of reporting stale depths.
+type ingestQueueCollector struct {
+	jobs       *prometheus.Desc
+	oldestWait *prometheus.Desc
+
+	mu      sync.Mutex
+	domains []IngestQueueDomain
+}
+
+func (c *ingestQueueCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.jobs
+	ch <- c.oldestWait
+}
+
+func (c *ingestQueueCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, d := range c.domains {
+		for state, n := range map[string]int64{
+			"runnable": d.Runnable,
+			"waiting":  d.Waiting,
+			"leased":   d.Leased,
+			"dead":     d.Dead,
+		} {
+			ch <- prometheus.MustNewConstMetric(c.jobs, prometheus.GaugeValue, float64(n), d.DomainID, state)
+		}
+		ch <- prometheus.MustNewConstMetric(c.oldestWait, prometheus.GaugeValue, d.OldestWait.Seconds(), d.DomainID)
+	}
+}
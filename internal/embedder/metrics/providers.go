@@ -48,6 +48,13 @@ var (
 		},
 		[]string{"source_type", "provider_type", "result"},
 	)
+	sourceNotifications = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "cube_embedder_source_notifications_total",
+			Help: "Total number of change notifications received by provider and result.",
+		},
+		[]string{"provider_type", "result"},
+	)
 )
 
 func init() {
@@ -57,6 +64,7 @@ func init() {
 		sourceSyncFiles,
 		sourceDownloadRuns,
 		sourceDownloadDuration,
+		sourceNotifications,
 	)
 }
 
@@ -99,6 +107,12 @@ func ObserveSourceDownload(sourceType, providerType string, duration time.Durati
 	sourceDownloadDuration.WithLabelValues(labels...).Observe(duration.Seconds())
 }
 
+// ObserveSourceNotification counts a change notification. result is
+// "queued", "ignored" or "rejected".
+func ObserveSourceNotification(providerType, result string) {
+	sourceNotifications.WithLabelValues(normalizeLabel(providerType), result).Inc()
+}
+
 func addSyncFiles(sourceType, providerType, kind string, n uint64) {
 	if n == 0 {
 		return
@@ -6,15 +6,20 @@ package postgres
 import (
 	"context"
 	"fmt"
+	"strconv"
 	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
 )
 
 // ChunksRepository writes text chunks and their embedding vectors to the
 // chunks table, linking them to a record by record_id.
 type ChunksRepository struct {
-	db *pgxpool.Pool
+	db        *pgxpool.Pool
+	index     VectorIndexConfig
+	languages domain.LanguageDefaults
 }
 
 // NewChunksRepository creates a ChunksRepository backed by pool.
@@ -26,6 +31,10 @@ func NewChunksRepository(db *pgxpool.Pool) *ChunksRepository {
 type Chunk struct {
 	Content   string
 	Embedding []float32
+	// Profile and Model identify the embedding profile and model that
+	// produced Embedding.
+	Profile string
+	Model   string
 }
 
 // DeleteByRecord removes all chunks for a record.
@@ -52,14 +61,8 @@ func (r *ChunksRepository) StoreChunks(ctx context.Context, domainID, userID, re
 	}
 
 	for i, c := range chunks {
-		vec := float32SliceToPGVector(c.Embedding)
-		_, err := tx.Exec(ctx,
-			`INSERT INTO chunks (domain_id, user_id, record_id, content, embedding, chunk_index)
-			 VALUES ($1, $2, $3, $4, $5::vector, $6)`,
-			domainID, userID, recordID, c.Content, vec, i,
-		)
-		if err != nil {
-			return fmt.Errorf("insert chunk %d: %w", i, err)
+		if err := r.insertChunk(ctx, tx, domainID, userID, recordID, i, c); err != nil {
+			return err
 		}
 	}
 
@@ -75,14 +78,8 @@ func (r *ChunksRepository) AppendChunks(ctx context.Context, domainID, userID, r
 	defer tx.Rollback(ctx)
 
 	for i, c := range chunks {
-		vec := float32SliceToPGVector(c.Embedding)
-		_, err := tx.Exec(ctx,
-			`INSERT INTO chunks (domain_id, user_id, record_id, content, embedding, chunk_index)
-			 VALUES ($1, $2, $3, $4, $5::vector, $6)`,
-			domainID, userID, recordID, c.Content, vec, startIndex+i,
-		)
-		if err != nil {
-			return fmt.Errorf("insert chunk %d: %w", startIndex+i, err)
+		if err := r.insertChunk(ctx, tx, domainID, userID, recordID, startIndex+i, c); err != nil {
+			return err
 		}
 	}
 
@@ -92,7 +89,9 @@ func (r *ChunksRepository) AppendChunks(ctx context.Context, domainID, userID, r
 // ChunkSearchResult is a retrieved chunk with the record metadata needed for
 // building citations.
 type ChunkSearchResult struct {
-	Content     string
+	Content string
+	// ContentHash is empty for chunks stored before hashes were recorded.
+	ContentHash string
 	RecordID    string
 	RecordName  string
 	ExternalURL string
@@ -100,49 +99,109 @@ type ChunkSearchResult struct {
 	Score       *float64
 }
 
-// SearchChunks performs a cosine-distance vector similarity search over the
-// domain's chunks.  If recordIDs is non-empty the search is
-// scoped to those records only.  Results are ordered by ascending distance
+// SearchChunks performs a vector similarity search over the domain's chunks
+// embedded by the profiles in queryVec.  If recordIDs is non-empty the search
+// is scoped to those records only.  Results are ordered by ascending distance
 // (most similar first).
-func (r *ChunksRepository) SearchChunks(ctx context.Context, domainID string, queryVec []float32, limit int, recordIDs []string) ([]ChunkSearchResult, error) {
-	vec := float32SliceToPGVector(queryVec)
-
-	var sb strings.Builder
-	args := []any{domainID, vec, limit} // $1 $2 $3
-
-	sb.WriteString(`
-		SELECT c.content, c.record_id, rec.name, COALESCE(rec.external_url, ''), c.chunk_index
-		FROM chunks c
-		JOIN records rec ON rec.id = c.record_id
-		WHERE c.domain_id = $1
-		  AND c.embedding IS NOT NULL`)
+func (r *ChunksRepository) SearchChunks(ctx context.Context, domainID string, queryVec ProfileVector, limit int, recordIDs []string) ([]ChunkSearchResult, error) {
+	if len(queryVec.Vector) == 0 || len(queryVec.Profiles) == 0 {
+		return nil, nil
+	}
+	args := []any{domainID, float32SliceToPGVector(queryVec.Vector), limit} // $1 .. $3
 
+	var recordIDFilter string
 	if len(recordIDs) > 0 {
 		ph := make([]string, len(recordIDs))
 		for i, id := range recordIDs {
 			args = append(args, id)
 			ph[i] = fmt.Sprintf("$%d", len(args))
 		}
-		sb.WriteString(fmt.Sprintf(" AND c.record_id IN (%s)", strings.Join(ph, ",")))
+		recordIDFilter = fmt.Sprintf(" AND c.record_id IN (%s)", strings.Join(ph, ","))
 	}
 
-	sb.WriteString(` ORDER BY c.embedding <-> $2::vector LIMIT $3`)
+	candidates := profileCandidates(queryVec, "$2", "$3", "c.domain_id = $1"+recordIDFilter,
+		"c.content, c.record_id, rec.name, COALESCE(rec.external_url, '') AS external_url, c.chunk_index")
+	query := `
+		SELECT c.content, c.record_id, c.name, c.external_url, c.chunk_index
+		FROM ` + candidates + `
+		ORDER BY c.distance
+		LIMIT $3`
 
-	rows, err := r.db.Query(ctx, sb.String(), args...)
+	var results []ChunkSearchResult
+	err := r.queryVectors(ctx, limit, query, args, func(rows pgx.Rows) error {
+		for rows.Next() {
+			var res ChunkSearchResult
+			if err := rows.Scan(&res.Content, &res.RecordID, &res.RecordName, &res.ExternalURL, &res.ChunkIndex); err != nil {
+				return fmt.Errorf("scan chunk: %w", err)
+			}
+			results = append(results, res)
+		}
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("search chunks: %w", err)
 	}
-	defer rows.Close()
+	return results, nil
+}
 
-	var results []ChunkSearchResult
-	for rows.Next() {
-		var res ChunkSearchResult
-		if err := rows.Scan(&res.Content, &res.RecordID, &res.RecordName, &res.ExternalURL, &res.ChunkIndex); err != nil {
-			return nil, fmt.Errorf("scan chunk: %w", err)
+// AdoptUnprofiledEmbeddings assigns chunks stored before embedding profiles
+// were recorded to profile, when their vectors have the profile's dimensions.
+// It works in batches so a large table is not locked in one statement, and
+// returns how many chunks were adopted and how many remain without a profile
+// because their dimensions differ; those are not searched until their records
+// are re-ingested.
+func (r *ChunksRepository) AdoptUnprofiledEmbeddings(ctx context.Context, profile string, dimensions int) (adopted, remaining int64, err error) {
+	const batchSize = 10000
+	for {
+		tag, err := r.db.Exec(ctx, `
+			UPDATE chunks SET embedding_profile = $1
+			WHERE id IN (
+				SELECT id FROM chunks
+				WHERE embedding_profile IS NULL
+				  AND embedding IS NOT NULL
+				  AND vector_dims(embedding) = $2
+				LIMIT $3
+			)`, profile, dimensions, batchSize)
+		if err != nil {
+			return adopted, 0, fmt.Errorf("adopt unprofiled embeddings: %w", err)
+		}
+		adopted += tag.RowsAffected()
+		if tag.RowsAffected() < batchSize {
+			break
+		}
+	}
+	if err := r.db.QueryRow(ctx,
+		`SELECT COUNT(*) FROM chunks WHERE embedding_profile IS NULL AND embedding IS NOT NULL`,
+	).Scan(&remaining); err != nil {
+		return adopted, 0, fmt.Errorf("count unprofiled embeddings: %w", err)
+	}
+	return adopted, remaining, nil
+}
+
+// LabelEmbeddingModels records model on the chunks of profile stored before
+// chunks recorded their model, when their vectors have the model's
+// dimensions, and returns how many were labelled.
+func (r *ChunksRepository) LabelEmbeddingModels(ctx context.Context, profile, model string, dimensions int) (int64, error) {
+	const batchSize = 10000
+	var labelled int64
+	for {
+		tag, err := r.db.Exec(ctx, `
+			UPDATE chunks SET embedding_model = $2
+			WHERE id IN (
+				SELECT id FROM chunks
+				WHERE embedding_profile = $1
+				  AND embedding_model IS NULL
+				  AND vector_dims(embedding) = $3
+				LIMIT $4
+			)`, profile, model, dimensions, batchSize)
+		if err != nil {
+			return labelled, fmt.Errorf("label embedding models: %w", err)
+		}
+		labelled += tag.RowsAffected()
+		if tag.RowsAffected() < batchSize {
+			return labelled, nil
 		}
-		results = append(results, res)
 	}
-	return results, rows.Err()
 }
 
 // float32SliceToPGVector formats a float32 slice as a pgvector literal string.
@@ -161,3 +220,25 @@ func float32SliceToPGVector(v []float32) string {
 	b.WriteByte(']')
 	return b.String()
 }
+
+// parsePGVector parses pgvector's text form, '[f1,f2,...]'.
+func parsePGVector(s string) ([]float32, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(s, "[") || !strings.HasSuffix(s, "]") {
+		return nil, fmt.Errorf("invalid vector %q", s)
+	}
+	s = s[1 : len(s)-1]
+	if s == "" {
+		return nil, nil
+	}
+	parts := strings.Split(s, ",")
+	v := make([]float32, len(parts))
+	for i, p := range parts {
+		f, err := strconv.ParseFloat(strings.TrimSpace(p), 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector element %q: %w", p, err)
+		}
+		v[i] = float32(f)
+	}
+	return v, nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+)
+
+// StoredChunk identifies an embedded chunk of a record by the hash of its
+// text and the model of its vector.
+type StoredChunk struct {
+	ID          string
+	ContentHash string
+	Profile     string
+	Model       string
+}
+
+// ChunkWrite is one position of a record's new chunk list: either a stored
+// chunk kept as it is, identified by ReuseID, or Chunk inserted anew.
+type ChunkWrite struct {
+	ReuseID string
+	Chunk   Chunk
+}
+
+// ListStoredChunks returns the embedded chunks of a record in order. Chunks
+// stored before their hashes were recorded are hashed on the fly.
+func (r *ChunksRepository) ListStoredChunks(ctx context.Context, recordID string) ([]StoredChunk, error) {
+	rows, err := r.db.Query(ctx, `
+		SELECT id,
+		       COALESCE(content_hash, encode(sha256(convert_to(content, 'UTF8')), 'hex')),
+		       COALESCE(embedding_profile, ''), COALESCE(embedding_model, '')
+		FROM chunks
+		WHERE record_id = $1 AND embedding IS NOT NULL
+		ORDER BY chunk_index`, recordID)
+	if err != nil {
+		return nil, fmt.Errorf("list stored chunks: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []StoredChunk
+	for rows.Next() {
+		var c StoredChunk
+		if err := rows.Scan(&c.ID, &c.ContentHash, &c.Profile, &c.Model); err != nil {
+			return nil, fmt.Errorf("scan stored chunk: %w", err)
+		}
+		chunks = append(chunks, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate stored chunks: %w", err)
+	}
+	return chunks, nil
+}
+
+// ReplaceChunks makes writes the record's chunk list in one transaction:
+// kept chunks move to their new positions, every other stored chunk is
+// deleted and new chunks are inserted. Searches see either the old list or
+// the new one.
+func (r *ChunksRepository) ReplaceChunks(ctx context.Context, domainID, userID, recordID string, writes []ChunkWrite) error {
+	keepIDs := make([]string, 0, len(writes))
+	keepIndexes := make([]int32, 0, len(writes))
+	for i, w := range writes {
+		if w.ReuseID != "" {
+			keepIDs = append(keepIDs, w.ReuseID)
+			keepIndexes = append(keepIndexes, int32(i))
+		}
+	}
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx,
+		`DELETE FROM chunks WHERE record_id = $1 AND NOT (id = ANY($2::uuid[]))`, recordID, keepIDs,
+	); err != nil {
+		return fmt.Errorf("delete replaced chunks: %w", err)
+	}
+	if len(keepIDs) > 0 {
+		tag, err := tx.Exec(ctx, `
+			UPDATE chunks c SET chunk_index = t.idx
+			FROM unnest($2::uuid[], $3::int[]) AS t(id, idx)
+			WHERE c.id = t.id AND c.record_id = $1`, recordID, keepIDs, keepIndexes)
+		if err != nil {
+			return fmt.Errorf("reindex kept chunks: %w", err)
+		}
+		if tag.RowsAffected() != int64(len(keepIDs)) {
+			return fmt.Errorf("reindex kept chunks: %d of %d chunks found", tag.RowsAffected(), len(keepIDs))
+		}
+	}
+	for i, w := range writes {
+		if w.ReuseID != "" {
+			continue
+		}
+		if err := r.insertChunk(ctx, tx, domainID, userID, recordID, i, w.Chunk); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,113 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/language"
+)
+
+// SetLanguageDefaults sets the language of chunks and queries whose language
+// cannot be detected.
+func (r *ChunksRepository) SetLanguageDefaults(defaults domain.LanguageDefaults) {
+	r.languages = defaults
+}
+
+// textLanguage returns the language of text in domainID: the detected one,
+// or the domain's default. It is empty when neither is known.
+func (r *ChunksRepository) textLanguage(domainID, text string) string {
+	if lang := language.Detect(text); lang != "" {
+		return lang
+	}
+	return r.languages.For(domainID)
+}
+
+// ftsConfigExpr resolves the text search configuration named by param,
+// falling back to simple when the server does not ship it (serbian needs
+// PostgreSQL 16).
+func ftsConfigExpr(param string) string {
+	return `COALESCE((SELECT oid::regconfig FROM pg_ts_config
+		WHERE cfgname = ` + param + ` AND cfgnamespace = 'pg_catalog'::regnamespace), 'simple'::regconfig)`
+}
+
+// insertChunk inserts c at index with its content hash, detected language
+// and the text search configuration of that language.
+func (r *ChunksRepository) insertChunk(ctx context.Context, tx pgx.Tx, domainID, userID, recordID string, index int, c Chunk) error {
+	return r.insertChunkFor(ctx, tx, "record_id", domainID, userID, recordID, index, c)
+}
+
+// insertChunkFor is insertChunk storing recordID in recordColumn, which is
+// record_id for live chunks and staged_record_id for staged ones.
+func (r *ChunksRepository) insertChunkFor(
+	ctx context.Context,
+	tx pgx.Tx,
+	recordColumn, domainID, userID, recordID string,
+	index int,
+	c Chunk,
+) error {
+	lang := r.textLanguage(domainID, c.Content)
+	if _, err := tx.Exec(ctx,
+		`INSERT INTO chunks (domain_id, user_id, `+recordColumn+`, content, embedding, chunk_index, embedding_profile, embedding_model, content_hash, language, fts_config)
+		 VALUES ($1, $2, $3, $4, $5::vector, $6, NULLIF($7, ''), NULLIF($8, ''), $9, $10, `+ftsConfigExpr("$11")+`)`,
+		domainID, userID, recordID, c.Content, float32SliceToPGVector(c.Embedding), index, c.Profile, c.Model,
+		domain.ContentHash(c.Content), lang, language.TextSearchConfig(lang),
+	); err != nil {
+		return fmt.Errorf("insert chunk %d: %w", index, err)
+	}
+	return nil
+}
+
+// BackfillChunkLanguages detects the language of chunks stored before
+// languages were recorded and re-indexes them with its text search
+// configuration. It works in batches so a large table is not locked in one
+// statement, and returns how many chunks were updated.
+func (r *ChunksRepository) BackfillChunkLanguages(ctx context.Context) (int64, error) {
+	const batchSize = 500
+	var updated int64
+	for {
+		rows, err := r.db.Query(ctx,
+			`SELECT id, domain_id, content FROM chunks WHERE language IS NULL LIMIT $1`, batchSize)
+		if err != nil {
+			return updated, fmt.Errorf("list chunks without language: %w", err)
+		}
+		var ids, langs, configs []string
+		for rows.Next() {
+			var id, domainID, content string
+			if err := rows.Scan(&id, &domainID, &content); err != nil {
+				rows.Close()
+				return updated, fmt.Errorf("scan chunk: %w", err)
+			}
+			lang := r.textLanguage(domainID, content)
+			ids = append(ids, id)
+			langs = append(langs, lang)
+			configs = append(configs, language.TextSearchConfig(lang))
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return updated, fmt.Errorf("list chunks without language: %w", err)
+		}
+		if len(ids) == 0 {
+			return updated, nil
+		}
+
+		tag, err := r.db.Exec(ctx, `
+			UPDATE chunks c
+			SET language = t.lang, fts_config = COALESCE(cfg.oid::regconfig, 'simple'::regconfig)
+			FROM unnest($1::uuid[], $2::text[], $3::text[]) AS t(id, lang, cfg_name)
+			LEFT JOIN pg_ts_config cfg
+			       ON cfg.cfgname = t.cfg_name AND cfg.cfgnamespace = 'pg_catalog'::regnamespace
+			WHERE c.id = t.id AND c.language IS NULL`, ids, langs, configs)
+		if err != nil {
+			return updated, fmt.Errorf("backfill chunk languages: %w", err)
+		}
+		updated += tag.RowsAffected()
+		if len(ids) < batchSize {
+			return updated, nil
+		}
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+// ErrReembedIncomplete is returned by CutOverEmbeddings when chunks in scope
+// still lack a staged vector for their profile's model, typically because
+// they were stored by a replica running the old configuration after the job
+// listed its work.
+var ErrReembedIncomplete = errors.New("chunks still need re-embedding")
+
+// StaleChunk is a chunk whose vector needs re-embedding, with the text to
+// embed.
+type StaleChunk struct {
+	ID      string
+	Content string
+}
+
+// CountStaleEmbeddings returns how many chunks of profile in scope have a
+// vector from a model other than model, and how many of those already have a
+// vector from model staged.
+func (r *ChunksRepository) CountStaleEmbeddings(ctx context.Context, scope domain.ReembedScope, profile, model string) (stale, staged int64, err error) {
+	filter, args := staleEmbeddingFilter(scope, profile, model)
+	if err := r.db.QueryRow(ctx,
+		`SELECT COUNT(*), COUNT(*) FILTER (WHERE c.pending_model = $3) FROM chunks c WHERE `+filter, args...,
+	).Scan(&stale, &staged); err != nil {
+		return 0, 0, fmt.Errorf("count stale embeddings: %w", err)
+	}
+	return stale, staged, nil
+}
+
+// ListStaleEmbeddings returns up to limit stale chunks of profile in scope
+// that have no vector staged for model yet.
+func (r *ChunksRepository) ListStaleEmbeddings(ctx context.Context, scope domain.ReembedScope, profile, model string, limit int) ([]StaleChunk, error) {
+	filter, args := staleEmbeddingFilter(scope, profile, model)
+	args = append(args, limit)
+	rows, err := r.db.Query(ctx, fmt.Sprintf(`
+		SELECT c.id, c.content FROM chunks c
+		WHERE %s AND c.pending_model IS DISTINCT FROM $3
+		ORDER BY c.id
+		LIMIT $%d`, filter, len(args)), args...)
+	if err != nil {
+		return nil, fmt.Errorf("list stale embeddings: %w", err)
+	}
+	defer rows.Close()
+
+	var chunks []StaleChunk
+	for rows.Next() {
+		var c StaleChunk
+		if err := rows.Scan(&c.ID, &c.Content); err != nil {
+			return nil, fmt.Errorf("scan stale embedding: %w", err)
+		}
+		chunks = append(chunks, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate stale embeddings: %w", err)
+	}
+	return chunks, nil
+}
+
+// StagePendingEmbeddings stores vectors from model next to the live vectors
+// of the chunks with the given IDs. Searches keep using the live vectors
+// until CutOverEmbeddings swaps them in.
+func (r *ChunksRepository) StagePendingEmbeddings(ctx context.Context, model string, ids []string, vectors [][]float32) error {
+	if len(ids) != len(vectors) {
+		return fmt.Errorf("stage pending embeddings: %d ids for %d vectors", len(ids), len(vectors))
+	}
+	literals := make([]string, len(vectors))
+	for i, v := range vectors {
+		literals[i] = float32SliceToPGVector(v)
+	}
+	if _, err := r.db.Exec(ctx, `
+		UPDATE chunks c SET pending_embedding = t.e::vector, pending_model = $1
+		FROM unnest($2::uuid[], $3::text[]) AS t(id, e)
+		WHERE c.id = t.id`, model, ids, literals,
+	); err != nil {
+		return fmt.Errorf("stage pending embeddings: %w", err)
+	}
+	return nil
+}
+
+// CutOverEmbeddings swaps the staged vectors into place for every chunk in
+// scope, moving each profile in targets to its model in one transaction, so
+// searches see either only the old vectors or only the new ones. It returns
+// ErrReembedIncomplete without changing anything when a stale chunk has no
+// staged vector, and otherwise the number of chunks moved.
+func (r *ChunksRepository) CutOverEmbeddings(ctx context.Context, scope domain.ReembedScope, targets map[string]string) (int64, error) {
+	profiles := make([]string, 0, len(targets))
+	for profile := range targets {
+		profiles = append(profiles, profile)
+	}
+	sort.Strings(profiles)
+
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	var moved int64
+	for _, profile := range profiles {
+		filter, args := staleEmbeddingFilter(scope, profile, targets[profile])
+		// Lock the rows being swapped so a concurrent re-ingest of the same
+		// record waits instead of interleaving with the swap.
+		var missing int64
+		if err := tx.QueryRow(ctx, `
+			SELECT COUNT(*) FROM (
+				SELECT c.pending_model FROM chunks c WHERE `+filter+` FOR UPDATE
+			) s WHERE s.pending_model IS DISTINCT FROM $3`, args...,
+		).Scan(&missing); err != nil {
+			return 0, fmt.Errorf("check staged embeddings: %w", err)
+		}
+		if missing > 0 {
+			return 0, fmt.Errorf("%w: %d chunks of profile %q", ErrReembedIncomplete, missing, profile)
+		}
+		tag, err := tx.Exec(ctx, `
+			UPDATE chunks c SET
+				embedding = c.pending_embedding,
+				embedding_model = c.pending_model,
+				pending_embedding = NULL,
+				pending_model = NULL
+			WHERE `+filter, args...)
+		if err != nil {
+			return 0, fmt.Errorf("cut over embeddings: %w", err)
+		}
+		moved += tag.RowsAffected()
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit cut-over: %w", err)
+	}
+	return moved, nil
+}
+
+// ClearPendingEmbeddings drops the vectors staged for chunks in scope when
+// their job is cancelled. A failed job keeps them, so a retry only embeds
+// what is left.
+func (r *ChunksRepository) ClearPendingEmbeddings(ctx context.Context, scope domain.ReembedScope) error {
+	filter, args := reembedScopeFilter(scope, nil)
+	if _, err := r.db.Exec(ctx, `
+		UPDATE chunks c SET pending_embedding = NULL, pending_model = NULL
+		WHERE c.pending_model IS NOT NULL AND `+filter, args...,
+	); err != nil {
+		return fmt.Errorf("clear pending embeddings: %w", err)
+	}
+	return nil
+}
+
+// staleEmbeddingFilter matches the chunks of profile in scope whose vector is
+// not from model. Its arguments always start with profile ($2) and model ($3).
+func staleEmbeddingFilter(scope domain.ReembedScope, profile, model string) (string, []any) {
+	filter, args := reembedScopeFilter(scope, []any{profile, model})
+	return `c.embedding_profile = $2 AND c.embedding_model IS DISTINCT FROM $3
+		AND c.embedding IS NOT NULL AND ` + filter, args
+}
+
+// reembedScopeFilter matches the chunks in scope, numbering its placeholders
+// after the domain ID ($1) and extra.
+func reembedScopeFilter(scope domain.ReembedScope, extra []any) (string, []any) {
+	args := append([]any{scope.DomainID}, extra...)
+	filter := "c.domain_id = $1"
+	if scope.Profile != "" {
+		args = append(args, scope.Profile)
+		filter += fmt.Sprintf(" AND c.embedding_profile = $%d", len(args))
+	}
+	var record string
+	if scope.SourceID != "" {
+		args = append(args, scope.SourceID)
+		record += fmt.Sprintf(" AND rec.source_id::text = $%d", len(args))
+	}
+	if scope.Format != "" {
+		args = append(args, string(scope.Format))
+		record += fmt.Sprintf(" AND rec.format = $%d", len(args))
+	}
+	if record != "" {
+		filter += " AND EXISTS (SELECT 1 FROM records rec WHERE rec.id = c.record_id" + record + ")"
+	}
+	return filter, args
+}
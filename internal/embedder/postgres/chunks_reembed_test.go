@@ -0,0 +1,35 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+func TestStaleEmbeddingFilter(t *testing.T) {
+	filter, args := staleEmbeddingFilter(domain.ReembedScope{
+		DomainID: "domain-1",
+		SourceID: "source-1",
+		Format:   domain.RecordFormatPDF,
+		Profile:  "text",
+	}, "text", "ollama/new/1024")
+
+	want := `c.embedding_profile = $2 AND c.embedding_model IS DISTINCT FROM $3
+		AND c.embedding IS NOT NULL AND c.domain_id = $1 AND c.embedding_profile = $4` +
+		` AND EXISTS (SELECT 1 FROM records rec WHERE rec.id = c.record_id AND rec.source_id::text = $5 AND rec.format = $6)`
+	if filter != want {
+		t.Fatalf("filter:\n%s\nwant:\n%s", filter, want)
+	}
+	if wantArgs := []any{"domain-1", "text", "ollama/new/1024", "text", "source-1", "pdf"}; !slices.Equal(args, wantArgs) {
+		t.Fatalf("args = %v, want %v", args, wantArgs)
+	}
+
+	filter, args = reembedScopeFilter(domain.ReembedScope{DomainID: "domain-1"}, nil)
+	if filter != "c.domain_id = $1" || len(args) != 1 {
+		t.Fatalf("unscoped filter = %q %v", filter, args)
+	}
+}
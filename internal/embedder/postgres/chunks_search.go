@@ -8,8 +8,10 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/language"
 )
 
 func (r *ChunksRepository) KeywordSearchChunks(
@@ -77,6 +79,10 @@ func (r *ChunksRepository) KeywordSearchChunks(
 		likeExprs = append(likeExprs,
 			"r.name ILIKE "+ph,
 			"COALESCE(r.description, '') ILIKE "+ph,
+			"COALESCE(r.enrichment->>'title', '') ILIKE "+ph,
+			"COALESCE(r.enrichment->>'summary', '') ILIKE "+ph,
+			"COALESCE(r.enrichment->>'keywords', '') ILIKE "+ph,
+			"COALESCE(r.enrichment->>'entities', '') ILIKE "+ph,
 			"COALESCE(r.external_ref, '') ILIKE "+ph,
 			"COALESCE(r.external_url, '') ILIKE "+ph,
 			"COALESCE(r.mime_type, '') ILIKE "+ph,
@@ -87,6 +93,10 @@ func (r *ChunksRepository) KeywordSearchChunks(
 		rankExprs = append(rankExprs,
 			"CASE WHEN r.name ILIKE "+ph+" THEN 4 ELSE 0 END",
 			"CASE WHEN COALESCE(r.description, '') ILIKE "+ph+" THEN 2 ELSE 0 END",
+			"CASE WHEN COALESCE(r.enrichment->>'title', '') ILIKE "+ph+" THEN 3 ELSE 0 END",
+			"CASE WHEN COALESCE(r.enrichment->>'summary', '') ILIKE "+ph+" THEN 2 ELSE 0 END",
+			"CASE WHEN COALESCE(r.enrichment->>'keywords', '') ILIKE "+ph+" THEN 3 ELSE 0 END",
+			"CASE WHEN COALESCE(r.enrichment->>'entities', '') ILIKE "+ph+" THEN 2 ELSE 0 END",
 			"CASE WHEN COALESCE(r.external_ref, '') ILIKE "+ph+" THEN 1 ELSE 0 END",
 			"CASE WHEN COALESCE(r.external_url, '') ILIKE "+ph+" THEN 1 ELSE 0 END",
 			"CASE WHEN COALESCE(r.mime_type, '') ILIKE "+ph+" THEN 1 ELSE 0 END",
@@ -158,16 +168,54 @@ func (r *ChunksRepository) KeywordSearchChunks(
 	return matches, nil
 }
 
+// ProfileVector is the query embedded by one embedding model. It is compared
+// only with chunks the listed profiles stored with that model.
+type ProfileVector struct {
+	Profiles []string
+	Model    string
+	Distance domain.VectorDistance
+	Vector   []float32
+}
+
+// profileCandidates returns a FROM item, aliased c, with the limitPH nearest
+// chunks of each profile in qv to the query vector in vecPH. Each profile is
+// searched on its own so the planner can use that profile's partial index.
+// filter may refer to the chunk as c and its record as rec; columns adds
+// output columns next to id and distance.
+func profileCandidates(qv ProfileVector, vecPH, limitPH, filter, columns string) string {
+	dims := len(qv.Vector)
+	dist := vectorDistanceExpr("c", qv.Distance, dims, vecPH)
+	if columns != "" {
+		columns = ", " + columns
+	}
+	branches := make([]string, len(qv.Profiles))
+	for i, profile := range qv.Profiles {
+		branches[i] = `(SELECT c.id` + columns + `, ` + dist + ` AS distance
+        FROM chunks c
+        JOIN records rec ON rec.id = c.record_id
+        WHERE ` + filter + `
+          AND ` + profileVectorFilter("c", profile, qv.Model, dims) + `
+        ORDER BY distance
+        LIMIT ` + limitPH + `)`
+	}
+	return "(\n        " + strings.Join(branches, "\n        UNION ALL\n        ") + "\n    ) c"
+}
+
 // HybridSearchChunks combines vector similarity and keyword search via
-// Reciprocal Rank Fusion (RRF).  When no meaningful keywords can be extracted
-// from q.Query the keyword CTE is omitted and the result is pure vector search:
+// Reciprocal Rank Fusion (RRF). Each query vector is ranked against the
+// chunks of its own profiles, so vectors of different models and sizes are
+// never compared, and every ranking contributes to the fused score:
 //
-//	SELECT v.id AS chunk_id, 1.0 / (60.0 + v.rank) AS score
-//	FROM vector_ranked v
+//	SELECT id AS chunk_id, SUM(score) AS score
+//	FROM (vector_ranked_0 ∪ vector_ranked_1 ∪ keyword_ranked ∪ metadata_ranked)
+//	GROUP BY id
+//
+// When no meaningful keywords can be extracted from q.Query the keyword and
+// metadata rankings are omitted and the result is pure vector search.
 func (r *ChunksRepository) HybridSearchChunks(
 	ctx context.Context,
 	domainID string,
-	queryVec []float32,
+	queryVecs []ProfileVector,
 	q domain.RetrievalQuery,
 ) ([]ChunkSearchResult, error) {
 	topK := q.TopK
@@ -180,13 +228,15 @@ func (r *ChunksRepository) HybridSearchChunks(
 	innerLimit := topK * 5
 
 	terms := searchTerms(q.Query)
-	vec := float32SliceToPGVector(queryVec)
+	if len(queryVecs) == 0 && len(terms) == 0 {
+		return nil, nil
+	}
 
-	// $1=domainID  $2=queryVec  $3=innerLimit
-	args := []any{domainID, vec, innerLimit}
-	next := 4
+	// $1=domainID  $2=innerLimit
+	args := []any{domainID, innerLimit}
+	next := 3
 
-	// Optional record-ID filter shared by both CTEs.
+	// Optional record-ID filter shared by every ranking.
 	var recordIDFilter string
 	if len(q.RecordIDs) > 0 {
 		phs := make([]string, 0, len(q.RecordIDs))
@@ -203,74 +253,75 @@ func (r *ChunksRepository) HybridSearchChunks(
 		}
 	}
 
-	var sb strings.Builder
+	var (
+		sb     strings.Builder
+		ctes   []string
+		ranked []string
+	)
 
-	// Vector CTE — always present.
-	sb.WriteString(`WITH vector_ranked AS (
-    SELECT c.id, ROW_NUMBER() OVER (ORDER BY c.embedding <-> $2::vector) AS rank
-    FROM chunks c
-    JOIN records rec ON rec.id = c.record_id
-    WHERE c.domain_id = $1 AND c.embedding IS NOT NULL AND rec.status = 'indexed'`)
-	sb.WriteString(recordIDFilter)
-	sb.WriteString(` LIMIT $3
+	// One vector ranking per embedding model.
+	for i, qv := range queryVecs {
+		if len(qv.Vector) == 0 || len(qv.Profiles) == 0 {
+			continue
+		}
+		vecPH := fmt.Sprintf("$%d", next)
+		next++
+		args = append(args, float32SliceToPGVector(qv.Vector))
+
+		name := fmt.Sprintf("vector_ranked_%d", i)
+		ctes = append(ctes, name+` AS (
+    SELECT id, ROW_NUMBER() OVER (ORDER BY distance) AS rank
+    FROM `+profileCandidates(qv, vecPH, "$2", "c.domain_id = $1 AND rec.status = 'indexed'"+recordIDFilter, "")+`
+    ORDER BY distance
+    LIMIT $2
 )`)
+		ranked = append(ranked, "SELECT id, 1.0 / (60.0 + rank::float) AS score FROM "+name)
+	}
 
-	if len(terms) == 0 {
-		// No meaningful words in query: degrade to pure vector scoring.
-		sb.WriteString(`,
-rrf AS (
-    SELECT id AS chunk_id, 1.0 / (60.0 + rank::float) AS score
-    FROM vector_ranked
-)`)
-	} else {
+	if len(terms) > 0 {
 		// Keyword CTE — BM25-ranked via PostgreSQL full-text search.
 		// websearch_to_tsquery with OR-joined terms gives recall even when a
-		// query term (e.g. "built") is absent from the document. The GIN index
-		// on to_tsvector('english', content) makes the @@ scan fast.
+		// query term (e.g. "built") is absent from the document. Each chunk is
+		// matched against the query parsed with the chunk's own text search
+		// configuration, so German and Serbian chunks are stemmed as German
+		// and Serbian; the GIN index on to_tsvector(fts_config, content)
+		// keeps the @@ scan fast. Chunks in the query's language, detected or
+		// the domain's default, rank higher.
 		orQuery := strings.Join(terms, " OR ")
 		queryPH := fmt.Sprintf("$%d", next)
-		next++
-		args = append(args, orQuery)
+		configsPH := fmt.Sprintf("$%d", next+1)
+		queryConfigPH := fmt.Sprintf("$%d", next+2)
+		next += 3
+		args = append(args, orQuery, language.TextSearchConfigs(),
+			language.TextSearchConfig(r.textLanguage(domainID, q.Query)))
 
-		sb.WriteString(`,
-keyword_ranked AS (
+		ctes = append(ctes, `keyword_ranked AS (
     SELECT id, ROW_NUMBER() OVER (ORDER BY fts_rank DESC, id) AS rank
     FROM (
         SELECT c.id,
-               ts_rank_cd(to_tsvector('english', c.content),
-                          websearch_to_tsquery('english', ` + queryPH + `)) AS fts_rank
-        FROM chunks c
+               ts_rank_cd(to_tsvector(c.fts_config, c.content), q.query)
+                 * CASE WHEN q.name = `+queryConfigPH+` THEN 1.5 ELSE 1.0 END AS fts_rank
+        FROM (
+            SELECT cfg.oid::regconfig AS config, cfg.cfgname::text AS name,
+                   websearch_to_tsquery(cfg.oid::regconfig, `+queryPH+`) AS query
+            FROM pg_ts_config cfg
+            WHERE cfg.cfgnamespace = 'pg_catalog'::regnamespace
+              AND cfg.cfgname = ANY(`+configsPH+`::text[])
+        ) q
+        JOIN chunks c ON c.fts_config = q.config
         JOIN records rec ON rec.id = c.record_id
         WHERE c.domain_id = $1
           AND rec.status = 'indexed'
-          AND to_tsvector('english', c.content) @@ websearch_to_tsquery('english', ` + queryPH + `)`)
-		sb.WriteString(recordIDFilter)
-		metadataTerms := metadataSearchTerms(terms)
-
-		sb.WriteString(`
+          AND to_tsvector(c.fts_config, c.content) @@ q.query`+recordIDFilter+`
         ORDER BY fts_rank DESC
-        LIMIT $3
+        LIMIT $2
     ) t
- )`)
-		if len(metadataTerms) == 0 {
-			sb.WriteString(`,
-rrf AS (
-    SELECT COALESCE(v.id, k.id) AS chunk_id,
-           COALESCE(1.0 / (60.0 + v.rank::float), 0.0)
-         + COALESCE(1.0 / (60.0 + k.rank::float), 0.0) AS score
-    FROM vector_ranked v
-    FULL OUTER JOIN keyword_ranked k ON k.id = v.id
 )`)
-		} else {
-			sb.WriteString(`,
-metadata_ranked AS (
-    SELECT id, ROW_NUMBER() OVER (ORDER BY metadata_score DESC, updated_at DESC, chunk_index ASC) AS rank
-    FROM (
-        SELECT c.id, rec.updated_at, c.chunk_index,
-               `)
+		ranked = append(ranked, "SELECT id, 1.0 / (60.0 + rank::float) AS score FROM keyword_ranked")
 
-			metadataRankExprs := make([]string, 0, len(metadataTerms)*8)
-			metadataLikeExprs := make([]string, 0, len(metadataTerms)*8)
+		if metadataTerms := metadataSearchTerms(terms); len(metadataTerms) > 0 {
+			metadataRankExprs := make([]string, 0, len(metadataTerms)*12)
+			metadataLikeExprs := make([]string, 0, len(metadataTerms)*12)
 			for _, term := range metadataTerms {
 				ph := fmt.Sprintf("$%d", next)
 				next++
@@ -278,6 +329,10 @@ metadata_ranked AS (
 				metadataRankExprs = append(metadataRankExprs,
 					"CASE WHEN lower(rec.name) LIKE "+ph+" THEN 4 ELSE 0 END",
 					"CASE WHEN lower(COALESCE(rec.description, '')) LIKE "+ph+" THEN 2 ELSE 0 END",
+					"CASE WHEN lower(COALESCE(rec.enrichment->>'title', '')) LIKE "+ph+" THEN 3 ELSE 0 END",
+					"CASE WHEN lower(COALESCE(rec.enrichment->>'summary', '')) LIKE "+ph+" THEN 2 ELSE 0 END",
+					"CASE WHEN lower(COALESCE(rec.enrichment->>'keywords', '')) LIKE "+ph+" THEN 3 ELSE 0 END",
+					"CASE WHEN lower(COALESCE(rec.enrichment->>'entities', '')) LIKE "+ph+" THEN 2 ELSE 0 END",
 					"CASE WHEN lower(COALESCE(rec.external_ref, '')) LIKE "+ph+" THEN 1 ELSE 0 END",
 					"CASE WHEN lower(COALESCE(rec.external_url, '')) LIKE "+ph+" THEN 1 ELSE 0 END",
 					"CASE WHEN lower(COALESCE(rec.mime_type, '')) LIKE "+ph+" THEN 1 ELSE 0 END",
@@ -288,6 +343,10 @@ metadata_ranked AS (
 				metadataLikeExprs = append(metadataLikeExprs,
 					"lower(rec.name) LIKE "+ph,
 					"lower(COALESCE(rec.description, '')) LIKE "+ph,
+					"lower(COALESCE(rec.enrichment->>'title', '')) LIKE "+ph,
+					"lower(COALESCE(rec.enrichment->>'summary', '')) LIKE "+ph,
+					"lower(COALESCE(rec.enrichment->>'keywords', '')) LIKE "+ph,
+					"lower(COALESCE(rec.enrichment->>'entities', '')) LIKE "+ph,
 					"lower(COALESCE(rec.external_ref, '')) LIKE "+ph,
 					"lower(COALESCE(rec.external_url, '')) LIKE "+ph,
 					"lower(COALESCE(rec.mime_type, '')) LIKE "+ph,
@@ -297,37 +356,43 @@ metadata_ranked AS (
 				)
 			}
 
-			sb.WriteString(strings.Join(metadataRankExprs, " + "))
-			sb.WriteString(` AS metadata_score
+			ctes = append(ctes, `metadata_ranked AS (
+    SELECT id, ROW_NUMBER() OVER (ORDER BY metadata_score DESC, updated_at DESC, chunk_index ASC) AS rank
+    FROM (
+        SELECT c.id, rec.updated_at, c.chunk_index,
+               `+strings.Join(metadataRankExprs, " + ")+` AS metadata_score
         FROM chunks c
         JOIN records rec ON rec.id = c.record_id
         LEFT JOIN sources s ON s.id = rec.source_id
         WHERE c.domain_id = $1
           AND rec.status = 'indexed'
-          AND (` + strings.Join(metadataLikeExprs, " OR ") + `)`)
-			sb.WriteString(recordIDFilter)
-			sb.WriteString(`
+          AND (`+strings.Join(metadataLikeExprs, " OR ")+`)`+recordIDFilter+`
         ORDER BY metadata_score DESC, rec.updated_at DESC, c.chunk_index ASC
-        LIMIT $3
+        LIMIT $2
     ) t
-),
-rrf AS (
-    SELECT COALESCE(v.id, k.id, m.id) AS chunk_id,
-           COALESCE(1.0 / (60.0 + v.rank::float), 0.0)
-         + COALESCE(1.0 / (60.0 + k.rank::float), 0.0)
-         + COALESCE(3.0 / (20.0 + m.rank::float), 0.0) AS score
-    FROM vector_ranked v
-    FULL OUTER JOIN keyword_ranked k ON k.id = v.id
-    FULL OUTER JOIN metadata_ranked m ON m.id = COALESCE(v.id, k.id)
 )`)
+			ranked = append(ranked, "SELECT id, 3.0 / (20.0 + rank::float) AS score FROM metadata_ranked")
 		}
 	}
+	if len(ranked) == 0 {
+		return nil, nil
+	}
+
+	ctes = append(ctes, `rrf AS (
+    SELECT id AS chunk_id, SUM(score) AS score
+    FROM (
+        `+strings.Join(ranked, "\n        UNION ALL\n        ")+`
+    ) ranked
+    GROUP BY id
+)`)
 
 	topKPH := fmt.Sprintf("$%d", next)
 	args = append(args, topK)
 
+	sb.WriteString("WITH ")
+	sb.WriteString(strings.Join(ctes, ",\n"))
 	sb.WriteString(`
-SELECT c.content, c.record_id, rec.name, COALESCE(rec.external_url, ''), c.chunk_index, rrf.score
+SELECT c.content, COALESCE(c.content_hash, ''), c.record_id, rec.name, COALESCE(rec.external_url, ''), c.chunk_index, rrf.score
 FROM rrf
 JOIN chunks c ON c.id = rrf.chunk_id
 JOIN records rec ON rec.id = c.record_id
@@ -335,28 +400,29 @@ ORDER BY rrf.score DESC
 LIMIT `)
 	sb.WriteString(topKPH)
 
-	rows, err := r.db.Query(ctx, sb.String(), args...)
-	if err != nil {
-		return nil, fmt.Errorf("hybrid search chunks: %w", err)
-	}
-	defer rows.Close()
-
 	var results []ChunkSearchResult
-	for rows.Next() {
-		var res ChunkSearchResult
-		var score float64
-		if err := rows.Scan(&res.Content, &res.RecordID, &res.RecordName, &res.ExternalURL, &res.ChunkIndex, &score); err != nil {
-			return nil, fmt.Errorf("scan chunk: %w", err)
+	err := r.queryVectors(ctx, innerLimit, sb.String(), args, func(rows pgx.Rows) error {
+		for rows.Next() {
+			var res ChunkSearchResult
+			var score float64
+			if err := rows.Scan(&res.Content, &res.ContentHash, &res.RecordID, &res.RecordName, &res.ExternalURL, &res.ChunkIndex, &score); err != nil {
+				return fmt.Errorf("scan chunk: %w", err)
+			}
+			res.Score = &score
+			results = append(results, res)
 		}
-		res.Score = &score
-		results = append(results, res)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("hybrid search chunks: %w", err)
 	}
-	return results, rows.Err()
+	return results, nil
 }
 
 // searchTerms returns the meaningful tokens in query, used only to decide
 // whether to include the FTS keyword CTE.  Stop-word removal and stemming are
-// handled by plainto_tsquery inside the SQL query itself.
+// handled by websearch_to_tsquery inside the SQL query itself, with the text
+// search configuration of each chunk's language.
 func searchTerms(query string) []string {
 	fields := strings.Fields(strings.TrimSpace(query))
 	if len(fields) == 0 {
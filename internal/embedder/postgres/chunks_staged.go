@@ -0,0 +1,59 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+)
+
+// StageChunks stores chunks for recordID next to its live chunks, starting
+// at startIndex. Staged chunks are not searched until SwapStagedChunks
+// replaces the live chunks with them.
+func (r *ChunksRepository) StageChunks(ctx context.Context, domainID, userID, recordID string, startIndex int, chunks []Chunk) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	for i, c := range chunks {
+		if err := r.insertChunkFor(ctx, tx, "staged_record_id", domainID, userID, recordID, startIndex+i, c); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// SwapStagedChunks replaces the live chunks of recordID with its staged
+// chunks in one transaction, so searches see either the old chunk set or
+// the new one.
+func (r *ChunksRepository) SwapStagedChunks(ctx context.Context, recordID string) error {
+	tx, err := r.db.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	if _, err := tx.Exec(ctx, `DELETE FROM chunks WHERE record_id = $1`, recordID); err != nil {
+		return fmt.Errorf("delete replaced chunks: %w", err)
+	}
+	if _, err := tx.Exec(ctx,
+		`UPDATE chunks SET record_id = staged_record_id, staged_record_id = NULL WHERE staged_record_id = $1`, recordID,
+	); err != nil {
+		return fmt.Errorf("swap staged chunks: %w", err)
+	}
+
+	return tx.Commit(ctx)
+}
+
+// DiscardStagedChunks removes the staged chunks of recordID, leaving its
+// live chunks in place.
+func (r *ChunksRepository) DiscardStagedChunks(ctx context.Context, recordID string) error {
+	if _, err := r.db.Exec(ctx, `DELETE FROM chunks WHERE staged_record_id = $1`, recordID); err != nil {
+		return fmt.Errorf("discard staged chunks: %w", err)
+	}
+	return nil
+}
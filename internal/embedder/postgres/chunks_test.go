@@ -0,0 +1,29 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestParsePGVectorRoundTrip(t *testing.T) {
+	want := []float32{0.25, -1.5, 3e-7, 12}
+	got, err := parsePGVector(float32SliceToPGVector(want))
+	if err != nil {
+		t.Fatalf("parsePGVector: %v", err)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("parsePGVector = %v, want %v", got, want)
+	}
+
+	if v, err := parsePGVector("[]"); err != nil || len(v) != 0 {
+		t.Fatalf("parsePGVector([]) = %v, %v", v, err)
+	}
+	for _, bad := range []string{"", "1,2", "[1,x]"} {
+		if _, err := parsePGVector(bad); err == nil {
+			t.Fatalf("parsePGVector(%q) succeeded", bad)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// EmbeddingCache stores the embeddings of chunk texts by domain, profile,
+// model and content hash. Entries are shared by the records of a domain but
+// never across domains, so cache hits reveal nothing about other tenants.
+type EmbeddingCache struct {
+	db *pgxpool.Pool
+}
+
+// NewEmbeddingCache creates an EmbeddingCache backed by pool.
+func NewEmbeddingCache(db *pgxpool.Pool) *EmbeddingCache {
+	return &EmbeddingCache{db: db}
+}
+
+// Lookup returns the cached embeddings of hashes, keyed by hash, and marks
+// them used. Hashes missing from the cache are missing from the result.
+func (c *EmbeddingCache) Lookup(
+	ctx context.Context,
+	domainID, profile, model string,
+	hashes []string,
+) (map[string][]float32, error) {
+	if len(hashes) == 0 {
+		return map[string][]float32{}, nil
+	}
+	rows, err := c.db.Query(ctx, `
+		UPDATE embedding_cache SET last_used_at = now()
+		WHERE domain_id = $1 AND profile = $2 AND model = $3 AND content_hash = ANY($4)
+		RETURNING content_hash, embedding::text`, domainID, profile, model, hashes)
+	if err != nil {
+		return nil, fmt.Errorf("lookup embedding cache: %w", err)
+	}
+	defer rows.Close()
+
+	out := make(map[string][]float32, len(hashes))
+	for rows.Next() {
+		var hash, literal string
+		if err := rows.Scan(&hash, &literal); err != nil {
+			return nil, fmt.Errorf("scan cached embedding: %w", err)
+		}
+		vec, err := parsePGVector(literal)
+		if err != nil {
+			return nil, fmt.Errorf("cached embedding %s: %w", hash, err)
+		}
+		out[hash] = vec
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate embedding cache: %w", err)
+	}
+	return out, nil
+}
+
+// Store caches embeddings[i] as the embedding of hashes[i]. Hashes must be
+// distinct.
+func (c *EmbeddingCache) Store(
+	ctx context.Context,
+	domainID, profile, model string,
+	hashes []string,
+	embeddings [][]float32,
+) error {
+	if len(hashes) != len(embeddings) {
+		return fmt.Errorf("store embedding cache: %d hashes for %d embeddings", len(hashes), len(embeddings))
+	}
+	if len(hashes) == 0 {
+		return nil
+	}
+	literals := make([]string, len(embeddings))
+	for i, vec := range embeddings {
+		literals[i] = float32SliceToPGVector(vec)
+	}
+	if _, err := c.db.Exec(ctx, `
+		INSERT INTO embedding_cache (domain_id, profile, model, content_hash, embedding)
+		SELECT $1, $2, $3, h, e::vector FROM unnest($4::text[], $5::text[]) AS t(h, e)
+		ON CONFLICT (domain_id, profile, model, content_hash) DO UPDATE SET last_used_at = now()`,
+		domainID, profile, model, hashes, literals,
+	); err != nil {
+		return fmt.Errorf("store embedding cache: %w", err)
+	}
+	return nil
+}
+
+// Prune deletes entries unused for longer than unusedFor and returns how
+// many it deleted.
+func (c *EmbeddingCache) Prune(ctx context.Context, unusedFor time.Duration) (int64, error) {
+	tag, err := c.db.Exec(ctx,
+		`DELETE FROM embedding_cache WHERE last_used_at < now() - make_interval(secs => $1)`, unusedFor.Seconds())
+	if err != nil {
+		return 0, fmt.Errorf("prune embedding cache: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
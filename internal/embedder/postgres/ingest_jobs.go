@@ -0,0 +1,389 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+// leaseExpiredError is recorded on jobs reclaimed from a worker that stopped
+// renewing its lease.
+const leaseExpiredError = "lease expired before ingest finished"
+
+type ingestJobsRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewIngestJobsRepository returns a PostgreSQL-backed IngestJobRepository.
+func NewIngestJobsRepository(pool *pgxpool.Pool) domain.IngestJobRepository {
+	return &ingestJobsRepo{pool: pool}
+}
+
+const ingestJobColumns = `id, record_id, domain_id, status, priority, attempts, run_after,
+	COALESCE(lease_owner, ''), lease_expires_at, heartbeat_at,
+	COALESCE(last_error, ''), COALESCE(error_class, ''), created_at, updated_at`
+
+func (r *ingestJobsRepo) Enqueue(ctx context.Context) (int64, error) {
+	// Jobs of records that are no longer waiting, e.g. cancelled while
+	// waiting to retry, are dropped first.
+	if _, err := r.pool.Exec(ctx, `
+		DELETE FROM ingest_jobs j USING records r
+		WHERE r.id = j.record_id AND j.status = 'queued'
+		  AND r.status NOT IN ('queued', 'processing')`,
+	); err != nil {
+		return 0, fmt.Errorf("drop stale ingest jobs: %w", err)
+	}
+
+	tag, err := r.pool.Exec(ctx, `
+		INSERT INTO ingest_jobs (record_id, domain_id, priority)
+		SELECT r.id, r.domain_id,
+			CASE WHEN s.config->>'kind' = 'direct_upload' THEN $1 ELSE $2 END
+		FROM records r JOIN sources s ON s.id = r.source_id
+		WHERE r.status = 'queued'
+		  AND NOT EXISTS (SELECT 1 FROM ingest_jobs j WHERE j.record_id = r.id AND j.status <> 'dead')
+		ON CONFLICT (record_id) DO UPDATE SET
+			status = 'queued',
+			priority = EXCLUDED.priority,
+			attempts = 0,
+			run_after = now(),
+			lease_owner = NULL,
+			lease_expires_at = NULL,
+			last_error = NULL,
+			error_class = NULL,
+			updated_at = now()
+		WHERE ingest_jobs.status = 'dead'`,
+		int(domain.IngestPriorityInteractive), int(domain.IngestPriorityBulk))
+	if err != nil {
+		return 0, fmt.Errorf("enqueue ingest jobs: %w", err)
+	}
+	return tag.RowsAffected(), nil
+}
+
+func (r *ingestJobsRepo) Claim(
+	ctx context.Context,
+	owner string,
+	limit int,
+	lease time.Duration,
+	limits domain.IngestConcurrencyLimits,
+) ([]domain.IngestJob, error) {
+	capDomains := make([]string, 0, len(limits.Domains))
+	caps := make([]int32, 0, len(limits.Domains))
+	for domainID, n := range limits.Domains {
+		capDomains = append(capDomains, domainID)
+		caps = append(caps, int32(n))
+	}
+
+	// Each domain offers at most limit jobs, ranked by priority then age.
+	// Ordering by rank plus the domain's running jobs deals the next job to
+	// the domain that has the fewest, so a domain syncing thousands of
+	// records only takes its share of workers. Caps count leases of every
+	// worker; concurrent claims may overshoot them briefly.
+	//
+	// SET expressions read the row as it was, so j.status tells reclaimed
+	// leases from queued jobs.
+	q := `
+		WITH running AS (
+			SELECT domain_id, COUNT(*) AS n FROM ingest_jobs
+			WHERE status = 'leased' AND lease_expires_at >= now()
+			GROUP BY domain_id
+		),
+		caps AS (
+			SELECT * FROM unnest($6::text[], $7::int[]) AS c(domain_id, max_running)
+		),
+		domains AS (
+			SELECT DISTINCT domain_id FROM ingest_jobs
+			WHERE (status = 'queued' AND run_after <= now())
+			   OR (status = 'leased' AND lease_expires_at < now())
+		),
+		candidates AS (
+			SELECT c.id, c.priority, c.run_after, c.rank + COALESCE(run.n, 0) AS turn,
+				COALESCE(run.n, 0) AS running, COALESCE(caps.max_running, $8) AS max_running, c.rank
+			FROM domains d
+			CROSS JOIN LATERAL (
+				SELECT id, priority, run_after,
+					row_number() OVER (ORDER BY priority DESC, run_after) AS rank
+				FROM ingest_jobs
+				WHERE domain_id = d.domain_id
+				  AND ((status = 'queued' AND run_after <= now())
+				    OR (status = 'leased' AND lease_expires_at < now()))
+				ORDER BY priority DESC, run_after
+				LIMIT $3
+			) c
+			LEFT JOIN running run ON run.domain_id = d.domain_id
+			LEFT JOIN caps ON caps.domain_id = d.domain_id
+		),
+		picked AS (
+			SELECT id FROM candidates
+			WHERE max_running <= 0 OR running + rank <= max_running
+			ORDER BY turn, priority DESC, run_after
+			LIMIT $3
+		)
+		UPDATE ingest_jobs j SET
+			status = 'leased',
+			lease_owner = $1,
+			lease_expires_at = now() + make_interval(secs => $2),
+			heartbeat_at = now(),
+			attempts = j.attempts + 1,
+			last_error = CASE WHEN j.status = 'leased' THEN $4 ELSE j.last_error END,
+			error_class = CASE WHEN j.status = 'leased' THEN $5 ELSE j.error_class END,
+			updated_at = now()
+		WHERE j.id IN (
+			SELECT id FROM ingest_jobs
+			WHERE id IN (SELECT id FROM picked)
+			  AND ((status = 'queued' AND run_after <= now())
+			    OR (status = 'leased' AND lease_expires_at < now()))
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + ingestJobColumns
+
+	rows, err := r.pool.Query(ctx, q, owner, lease.Seconds(), limit, leaseExpiredError,
+		string(domain.IngestErrorTransient), capDomains, caps, limits.Default)
+	if err != nil {
+		return nil, fmt.Errorf("claim ingest jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []domain.IngestJob
+	for rows.Next() {
+		job, err := scanIngestJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan ingest job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate ingest jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+func (r *ingestJobsRepo) Heartbeat(ctx context.Context, id, owner string, lease time.Duration) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE ingest_jobs SET
+			lease_expires_at = now() + make_interval(secs => $3),
+			heartbeat_at = now(),
+			updated_at = now()
+		WHERE id = $1 AND lease_owner = $2 AND status = 'leased'`, id, owner, lease.Seconds())
+	if err != nil {
+		return fmt.Errorf("renew ingest lease: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *ingestJobsRepo) Complete(ctx context.Context, id, owner string) error {
+	tag, err := r.pool.Exec(ctx,
+		`DELETE FROM ingest_jobs WHERE id = $1 AND lease_owner = $2 AND status = 'leased'`, id, owner)
+	if err != nil {
+		return fmt.Errorf("complete ingest job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *ingestJobsRepo) Retry(ctx context.Context, id, owner string, runAfter time.Time, class domain.IngestErrorClass, errMsg string) error {
+	return r.release(ctx, id, owner, domain.IngestJobQueued, runAfter, class, errMsg)
+}
+
+func (r *ingestJobsRepo) DeadLetter(ctx context.Context, id, owner string, class domain.IngestErrorClass, errMsg string) error {
+	return r.release(ctx, id, owner, domain.IngestJobDead, time.Now(), class, errMsg)
+}
+
+// release gives up owner's lease, leaving the job in status.
+func (r *ingestJobsRepo) release(
+	ctx context.Context,
+	id, owner string,
+	status domain.IngestJobStatus,
+	runAfter time.Time,
+	class domain.IngestErrorClass,
+	errMsg string,
+) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE ingest_jobs SET
+			status = $3,
+			run_after = $4,
+			error_class = NULLIF($5, ''),
+			last_error = NULLIF($6, ''),
+			lease_owner = NULL,
+			lease_expires_at = NULL,
+			updated_at = now()
+		WHERE id = $1 AND lease_owner = $2 AND status = 'leased'`,
+		id, owner, string(status), runAfter, string(class), errMsg)
+	if err != nil {
+		return fmt.Errorf("release ingest job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *ingestJobsRepo) Stats(ctx context.Context) ([]domain.IngestQueueStats, error) {
+	rows, err := r.pool.Query(ctx, `
+		SELECT domain_id,
+			COUNT(*) FILTER (WHERE status = 'queued' AND run_after <= now()),
+			COUNT(*) FILTER (WHERE status = 'queued' AND run_after > now()),
+			COUNT(*) FILTER (WHERE status = 'leased'),
+			COUNT(*) FILTER (WHERE status = 'dead'),
+			MIN(run_after) FILTER (WHERE status = 'queued' AND run_after <= now())
+		FROM ingest_jobs
+		GROUP BY domain_id`)
+	if err != nil {
+		return nil, fmt.Errorf("ingest queue stats: %w", err)
+	}
+	defer rows.Close()
+
+	var stats []domain.IngestQueueStats
+	for rows.Next() {
+		var (
+			st     domain.IngestQueueStats
+			oldest pgtype.Timestamptz
+		)
+		if err := rows.Scan(&st.DomainID, &st.Runnable, &st.Waiting, &st.Leased, &st.Dead, &oldest); err != nil {
+			return nil, fmt.Errorf("scan ingest queue stats: %w", err)
+		}
+		if oldest.Valid {
+			t := oldest.Time
+			st.OldestRunnable = &t
+		}
+		stats = append(stats, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate ingest queue stats: %w", err)
+	}
+	return stats, nil
+}
+
+func (r *ingestJobsRepo) Get(ctx context.Context, id, domainID string) (domain.IngestJob, error) {
+	q := `SELECT ` + ingestJobColumns + ` FROM ingest_jobs WHERE id = $1 AND domain_id = $2`
+
+	job, err := scanIngestJob(r.pool.QueryRow(ctx, q, id, domainID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidTextRepresentation(err) {
+			return domain.IngestJob{}, domain.ErrNotFound
+		}
+		return domain.IngestJob{}, fmt.Errorf("get ingest job: %w", err)
+	}
+	return job, nil
+}
+
+func (r *ingestJobsRepo) List(ctx context.Context, domainID string, f domain.IngestJobFilter, p domain.Page) (domain.IngestJobPage, error) {
+	where := `domain_id = $1`
+	args := []any{domainID}
+	if f.Status != nil {
+		args = append(args, string(*f.Status))
+		where += fmt.Sprintf(` AND status = $%d`, len(args))
+	}
+
+	var total uint64
+	if err := r.pool.QueryRow(ctx, `SELECT COUNT(*) FROM ingest_jobs WHERE `+where, args...).Scan(&total); err != nil {
+		return domain.IngestJobPage{}, fmt.Errorf("count ingest jobs: %w", err)
+	}
+
+	limit := p.Limit
+	if limit == 0 {
+		limit = 20
+	}
+	args = append(args, limit, p.Offset)
+	q := fmt.Sprintf(`SELECT %s FROM ingest_jobs WHERE %s ORDER BY updated_at DESC LIMIT $%d OFFSET $%d`,
+		ingestJobColumns, where, len(args)-1, len(args))
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return domain.IngestJobPage{}, fmt.Errorf("list ingest jobs: %w", err)
+	}
+	defer rows.Close()
+
+	page := domain.IngestJobPage{Total: total}
+	for rows.Next() {
+		job, err := scanIngestJob(rows)
+		if err != nil {
+			return domain.IngestJobPage{}, fmt.Errorf("scan ingest job: %w", err)
+		}
+		page.Jobs = append(page.Jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return domain.IngestJobPage{}, fmt.Errorf("iterate ingest jobs: %w", err)
+	}
+	return page, nil
+}
+
+func (r *ingestJobsRepo) Requeue(ctx context.Context, id, domainID string) (domain.IngestJob, error) {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return domain.IngestJob{}, fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	job, err := scanIngestJob(tx.QueryRow(ctx, `
+		UPDATE ingest_jobs SET
+			status = 'queued',
+			attempts = 0,
+			run_after = now(),
+			updated_at = now()
+		WHERE id = $1 AND domain_id = $2 AND status <> 'leased'
+		RETURNING `+ingestJobColumns, id, domainID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidTextRepresentation(err) {
+			if _, err := r.Get(ctx, id, domainID); err != nil {
+				return domain.IngestJob{}, err
+			}
+			return domain.IngestJob{}, domain.ErrConflict
+		}
+		return domain.IngestJob{}, fmt.Errorf("requeue ingest job: %w", err)
+	}
+	// The worker only ingests records that are waiting.
+	if _, err := tx.Exec(ctx, `
+		UPDATE records
+		SET status = 'queued', error = NULL, ingest_total_chunks = NULL, ingest_indexed_chunks = NULL, ingest_reused_chunks = NULL,
+		    ingest_stage = NULL, updated_at = now()
+		WHERE id = $1`, job.RecordID,
+	); err != nil {
+		return domain.IngestJob{}, fmt.Errorf("requeue record: %w", err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return domain.IngestJob{}, fmt.Errorf("commit requeue: %w", err)
+	}
+	return job, nil
+}
+
+func scanIngestJob(row interface {
+	Scan(dest ...any) error
+},
+) (domain.IngestJob, error) {
+	var (
+		job                     domain.IngestJob
+		status, class           string
+		leaseExpires, heartbeat pgtype.Timestamptz
+	)
+	if err := row.Scan(
+		&job.ID, &job.RecordID, &job.DomainID, &status, &job.Priority, &job.Attempts, &job.RunAfter,
+		&job.LeaseOwner, &leaseExpires, &heartbeat,
+		&job.LastError, &class, &job.CreatedAt, &job.UpdatedAt,
+	); err != nil {
+		return domain.IngestJob{}, err
+	}
+	job.Status = domain.IngestJobStatus(status)
+	job.ErrorClass = domain.IngestErrorClass(class)
+	if leaseExpires.Valid {
+		t := leaseExpires.Time
+		job.LeaseExpiresAt = &t
+	}
+	if heartbeat.Valid {
+		t := heartbeat.Time
+		job.HeartbeatAt = &t
+	}
+	return job, nil
+}
@@ -0,0 +1,177 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/secrets"
+)
+
+type modelProvidersRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewModelProvidersRepository returns a PostgreSQL-backed ModelProviderRepository.
+func NewModelProvidersRepository(pool *pgxpool.Pool) domain.ModelProviderRepository {
+	return &modelProvidersRepo{pool: pool}
+}
+
+const modelProviderColumns = `id, domain_id, user_id, name, provider, base_url, models, api_key_ciphertext, created_at, updated_at`
+
+func (r *modelProvidersRepo) Create(ctx context.Context, p domain.ModelProvider) (domain.ModelProvider, error) {
+	models := p.Models
+	if models == nil {
+		models = []string{}
+	}
+	q := `
+		INSERT INTO model_providers (id, domain_id, user_id, name, provider, base_url, models, api_key_ciphertext)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ` + modelProviderColumns
+
+	row := r.pool.QueryRow(ctx, q, p.ID, p.DomainID, p.UserID, p.Name, p.Provider, p.BaseURL, models, p.APIKeyCiphertext)
+	created, err := scanModelProvider(row)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ModelProvider{}, domain.ErrConflict
+		}
+		return domain.ModelProvider{}, fmt.Errorf("insert model provider: %w", err)
+	}
+	return created, nil
+}
+
+func (r *modelProvidersRepo) GetByID(ctx context.Context, id, domainID string) (domain.ModelProvider, error) {
+	q := `SELECT ` + modelProviderColumns + ` FROM model_providers WHERE id = $1 AND domain_id = $2`
+
+	p, err := scanModelProvider(r.pool.QueryRow(ctx, q, id, domainID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ModelProvider{}, domain.ErrNotFound
+		}
+		return domain.ModelProvider{}, fmt.Errorf("get model provider: %w", err)
+	}
+	return p, nil
+}
+
+func (r *modelProvidersRepo) List(ctx context.Context, domainID string) ([]domain.ModelProvider, error) {
+	if domainID == "" {
+		return nil, nil
+	}
+	q := `SELECT ` + modelProviderColumns + ` FROM model_providers WHERE domain_id = $1 ORDER BY name`
+
+	rows, err := r.pool.Query(ctx, q, domainID)
+	if err != nil {
+		return nil, fmt.Errorf("list model providers: %w", err)
+	}
+	defer rows.Close()
+
+	var providers []domain.ModelProvider
+	for rows.Next() {
+		p, err := scanModelProvider(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan model provider: %w", err)
+		}
+		providers = append(providers, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate model providers: %w", err)
+	}
+	return providers, nil
+}
+
+func (r *modelProvidersRepo) Delete(ctx context.Context, id, domainID string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM model_providers WHERE id = $1 AND domain_id = $2`, id, domainID)
+	if err != nil {
+		return fmt.Errorf("delete model provider: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+// ReencryptModelProviderKeys seals every stored API key under the
+// envelope's current KEK, bound to its provider. It returns the number of
+// rows rewritten.
+func ReencryptModelProviderKeys(ctx context.Context, pool *pgxpool.Pool, envelope *secrets.Envelope) (int, error) {
+	if envelope == nil {
+		return 0, secrets.ErrNotConfigured
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin re-encrypt: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx,
+		`SELECT id, api_key_ciphertext FROM model_providers WHERE api_key_ciphertext IS NOT NULL FOR UPDATE`)
+	if err != nil {
+		return 0, fmt.Errorf("list model provider keys: %w", err)
+	}
+	type pending struct {
+		id  string
+		key []byte
+	}
+	var updates []pending
+	for rows.Next() {
+		var (
+			id     string
+			sealed []byte
+		)
+		if err := rows.Scan(&id, &sealed); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan model provider key: %w", err)
+		}
+		if !envelope.NeedsRotation(sealed) {
+			continue
+		}
+		key, err := envelope.OpenValue(sealed, id)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("open model provider %s key: %w", id, err)
+		}
+		resealed, err := envelope.SealValue(key, id)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("re-encrypt model provider %s key: %w", id, err)
+		}
+		updates = append(updates, pending{id: id, key: resealed})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterate model provider keys: %w", err)
+	}
+
+	for _, u := range updates {
+		if _, err := tx.Exec(ctx,
+			`UPDATE model_providers SET api_key_ciphertext = $1, updated_at = now() WHERE id = $2`, u.key, u.id,
+		); err != nil {
+			return 0, fmt.Errorf("update model provider %s: %w", u.id, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit re-encrypt: %w", err)
+	}
+	return len(updates), nil
+}
+
+func scanModelProvider(row interface {
+	Scan(dest ...any) error
+},
+) (domain.ModelProvider, error) {
+	var p domain.ModelProvider
+	if err := row.Scan(
+		&p.ID, &p.DomainID, &p.UserID, &p.Name, &p.Provider, &p.BaseURL,
+		&p.Models, &p.APIKeyCiphertext, &p.CreatedAt, &p.UpdatedAt,
+	); err != nil {
+		return domain.ModelProvider{}, err
+	}
+	return p, nil
+}
@@ -5,6 +5,7 @@ package postgres
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
@@ -28,10 +29,10 @@ func (r *recordsRepo) GetByID(ctx context.Context, id, domainID string) (domain.
 	const q = `
 		SELECT r.id, r.domain_id, r.user_id, r.source_id, r.name, r.format, r.status,
 		       r.external_id, r.external_url, r.external_ref, r.mime_type,
-		       r.folder_path, r.folder_id,
+		       r.folder_path, r.folder_id, r.parent_id,
 		       r.description, r.chunk_count, r.size_bytes, r.page_count,
-		       r.ingest_total_chunks, r.ingest_indexed_chunks, r.ingest_stage,
-		       r.source_version, r.source_modified_at, r.error,
+		       r.ingest_total_chunks, r.ingest_indexed_chunks, r.ingest_reused_chunks, r.ingest_stage,
+		       r.source_version, r.source_modified_at, r.error, r.content_hash, r.enrichment,
 		       r.created_at, r.updated_at,
 		       s.id, s.name, s.source_type, s.status
 		FROM records r
@@ -83,6 +84,10 @@ func (r *recordsRepo) List(
 		args = append(args, *f.FolderPrefix+"/%")
 		conds = append(conds, fmt.Sprintf("(r.folder_path = $%d OR r.folder_path LIKE $%d)", len(args)-1, len(args)))
 	}
+	if f.ParentID != nil {
+		args = append(args, *f.ParentID)
+		conds = append(conds, fmt.Sprintf("r.parent_id = $%d", len(args)))
+	}
 
 	where := strings.Join(conds, " AND ")
 
@@ -94,10 +99,10 @@ func (r *recordsRepo) List(
 	q := fmt.Sprintf(`
 		SELECT r.id, r.domain_id, r.user_id, r.source_id, r.name, r.format, r.status,
 		       r.external_id, r.external_url, r.external_ref, r.mime_type,
-		       r.folder_path, r.folder_id,
+		       r.folder_path, r.folder_id, r.parent_id,
 		       r.description, r.chunk_count, r.size_bytes, r.page_count,
-		       r.ingest_total_chunks, r.ingest_indexed_chunks, r.ingest_stage,
-		       r.source_version, r.source_modified_at, r.error,
+		       r.ingest_total_chunks, r.ingest_indexed_chunks, r.ingest_reused_chunks, r.ingest_stage,
+		       r.source_version, r.source_modified_at, r.error, r.content_hash, r.enrichment,
 		       r.created_at, r.updated_at,
 		       s.id, s.name, s.source_type, s.status
 		FROM records r
@@ -153,16 +158,20 @@ func scanRecord(row interface {
 		mimeType            pgtype.Text
 		folderPath          pgtype.Text
 		folderID            pgtype.Text
+		parentID            pgtype.Text
 		description         pgtype.Text
 		chunkCount          pgtype.Int4
 		sizeBytes           pgtype.Int8
 		pageCount           pgtype.Int4
 		ingestTotalChunks   pgtype.Int4
 		ingestIndexedChunks pgtype.Int4
+		ingestReusedChunks  pgtype.Int4
 		ingestStage         pgtype.Text
 		sourceVersion       pgtype.Text
 		sourceModifiedAt    pgtype.Timestamptz
 		recError            pgtype.Text
+		contentHash         pgtype.Text
+		enrichment          []byte
 		linkID              pgtype.Text
 		linkName            pgtype.Text
 		linkType            pgtype.Text
@@ -171,10 +180,10 @@ func scanRecord(row interface {
 	if err := row.Scan(
 		&rec.ID, &rec.DomainID, &rec.UserID, &sourceID, &rec.Name, &format, &status,
 		&externalID, &externalURL, &externalRef, &mimeType,
-		&folderPath, &folderID,
+		&folderPath, &folderID, &parentID,
 		&description, &chunkCount, &sizeBytes, &pageCount,
-		&ingestTotalChunks, &ingestIndexedChunks, &ingestStage,
-		&sourceVersion, &sourceModifiedAt, &recError,
+		&ingestTotalChunks, &ingestIndexedChunks, &ingestReusedChunks, &ingestStage,
+		&sourceVersion, &sourceModifiedAt, &recError, &contentHash, &enrichment,
 		&rec.CreatedAt, &rec.UpdatedAt,
 		&linkID, &linkName, &linkType, &linkStatus,
 	); err != nil {
@@ -208,6 +217,10 @@ func scanRecord(row interface {
 		s := folderID.String
 		rec.FolderID = &s
 	}
+	if parentID.Valid {
+		s := parentID.String
+		rec.ParentID = &s
+	}
 	if description.Valid {
 		rec.Description = description.String
 	}
@@ -231,6 +244,10 @@ func scanRecord(row interface {
 		n := int(ingestIndexedChunks.Int32)
 		rec.IngestIndexedChunks = &n
 	}
+	if ingestReusedChunks.Valid {
+		n := int(ingestReusedChunks.Int32)
+		rec.IngestReusedChunks = &n
+	}
 	if ingestStage.Valid {
 		s := ingestStage.String
 		rec.IngestStage = &s
@@ -245,6 +262,16 @@ func scanRecord(row interface {
 	if recError.Valid {
 		rec.Error = &recError.String
 	}
+	if contentHash.Valid {
+		rec.ContentHash = contentHash.String
+	}
+	if len(enrichment) > 0 {
+		var e domain.RecordEnrichment
+		if err := json.Unmarshal(enrichment, &e); err != nil {
+			return domain.Record{}, fmt.Errorf("decode record enrichment: %w", err)
+		}
+		rec.Enrichment = &e
+	}
 	if linkID.Valid {
 		rec.Source = &domain.RecordSourceLink{
 			ID:     linkID.String,
@@ -261,15 +288,15 @@ func (r *recordsRepo) Create(ctx context.Context, rec domain.Record) (domain.Rec
 		INSERT INTO records
 			(domain_id, user_id, source_id, name, format, status,
 			 external_id, external_url, external_ref, mime_type,
-			 folder_path, folder_id,
+			 folder_path, folder_id, parent_id,
 			 source_version, source_modified_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		RETURNING id, domain_id, user_id, source_id, name, format, status,
 		          external_id, external_url, external_ref, mime_type,
-		          folder_path, folder_id,
+		          folder_path, folder_id, parent_id,
 		          description, chunk_count, size_bytes, page_count,
-		          ingest_total_chunks, ingest_indexed_chunks, ingest_stage,
-		          source_version, source_modified_at, error,
+		          ingest_total_chunks, ingest_indexed_chunks, ingest_reused_chunks, ingest_stage,
+		          source_version, source_modified_at, error, content_hash, enrichment,
 		          created_at, updated_at,
 		          NULL, NULL, NULL, NULL`
 
@@ -281,7 +308,7 @@ func (r *recordsRepo) Create(ctx context.Context, rec domain.Record) (domain.Rec
 	row := r.pool.QueryRow(ctx, q,
 		rec.DomainID, rec.UserID, rec.SourceID, rec.Name, string(rec.Format), string(rec.Status),
 		rec.ExternalID, rec.ExternalURL, rec.ExternalRef, rec.MimeType,
-		rec.FolderPath, rec.FolderID,
+		rec.FolderPath, rec.FolderID, rec.ParentID,
 		rec.SourceVersion, sourceModifiedAt,
 	)
 	created, err := scanRecord(row)
@@ -294,44 +321,11 @@ func (r *recordsRepo) Create(ctx context.Context, rec domain.Record) (domain.Rec
 	return created, nil
 }
 
-func (r *recordsRepo) ListQueued(ctx context.Context, limit int) ([]domain.Record, error) {
-	const q = `
-		SELECT r.id, r.domain_id, r.user_id, r.source_id, r.name, r.format, r.status,
-		       r.external_id, r.external_url, r.external_ref, r.mime_type,
-		       r.folder_path, r.folder_id,
-		       r.description, r.chunk_count, r.size_bytes, r.page_count,
-		       r.ingest_total_chunks, r.ingest_indexed_chunks, r.ingest_stage,
-		       r.source_version, r.source_modified_at, r.error,
-		       r.created_at, r.updated_at,
-		       s.id, s.name, s.source_type, s.status
-		FROM records r
-		LEFT JOIN sources s ON s.id = r.source_id
-		WHERE r.status = 'queued'
-		ORDER BY r.created_at
-		LIMIT $1`
-
-	rows, err := r.pool.Query(ctx, q, limit)
-	if err != nil {
-		return nil, fmt.Errorf("list queued: %w", err)
-	}
-	defer rows.Close()
-
-	var records []domain.Record
-	for rows.Next() {
-		rec, err := scanRecord(rows)
-		if err != nil {
-			return nil, fmt.Errorf("scan queued record: %w", err)
-		}
-		records = append(records, rec)
-	}
-	return records, rows.Err()
-}
-
 func (r *recordsRepo) UpdateStatus(ctx context.Context, id string, s domain.RecordStatus, errMsg string) error {
 	if errMsg != "" {
 		_, err := r.pool.Exec(ctx,
 			`UPDATE records
-			 SET status=$1, error=$2, ingest_total_chunks=NULL, ingest_indexed_chunks=NULL, updated_at=now()
+			 SET status=$1, error=$2, ingest_total_chunks=NULL, ingest_indexed_chunks=NULL, ingest_reused_chunks=NULL, updated_at=now()
 			 WHERE id=$3 AND status <> 'cancelled'`,
 			string(s), errMsg, id,
 		)
@@ -340,7 +334,7 @@ func (r *recordsRepo) UpdateStatus(ctx context.Context, id string, s domain.Reco
 	if s == domain.RecordStatusCancelled || s == domain.RecordStatusQueued {
 		_, err := r.pool.Exec(ctx,
 			`UPDATE records
-			 SET status=$1, error=NULL, ingest_total_chunks=NULL, ingest_indexed_chunks=NULL, ingest_stage=NULL, updated_at=now()
+			 SET status=$1, error=NULL, ingest_total_chunks=NULL, ingest_indexed_chunks=NULL, ingest_reused_chunks=NULL, ingest_stage=NULL, updated_at=now()
 			 WHERE id=$2`,
 			string(s), id,
 		)
@@ -348,23 +342,35 @@ func (r *recordsRepo) UpdateStatus(ctx context.Context, id string, s domain.Reco
 	}
 	_, err := r.pool.Exec(ctx,
 		`UPDATE records
-		 SET status=$1, error=NULL, ingest_total_chunks=NULL, ingest_indexed_chunks=NULL, updated_at=now()
+		 SET status=$1, error=NULL, ingest_total_chunks=NULL, ingest_indexed_chunks=NULL, ingest_reused_chunks=NULL, updated_at=now()
 		 WHERE id=$2 AND status <> 'cancelled'`,
 		string(s), id,
 	)
 	return err
 }
 
-func (r *recordsRepo) UpdateIngestProgress(ctx context.Context, id string, indexedChunks, totalChunks int) error {
+func (r *recordsRepo) UpdateIngestProgress(ctx context.Context, id string, p domain.IngestProgress) error {
 	_, err := r.pool.Exec(ctx,
 		`UPDATE records
-		 SET status='processing', ingest_indexed_chunks=$1, ingest_total_chunks=$2, updated_at=now()
-		 WHERE id=$3`,
-		indexedChunks, totalChunks, id,
+		 SET status='processing', ingest_indexed_chunks=$1, ingest_total_chunks=$2, ingest_reused_chunks=$3, updated_at=now()
+		 WHERE id=$4`,
+		p.IndexedChunks, p.TotalChunks, p.ReusedChunks, id,
 	)
 	return err
 }
 
+func (r *recordsRepo) UpdateEnrichment(ctx context.Context, id string, e *domain.RecordEnrichment) error {
+	var enrichment []byte
+	if e != nil {
+		var err error
+		if enrichment, err = json.Marshal(e); err != nil {
+			return fmt.Errorf("encode record enrichment: %w", err)
+		}
+	}
+	_, err := r.pool.Exec(ctx, `UPDATE records SET enrichment=$1, updated_at=now() WHERE id=$2`, enrichment, id)
+	return err
+}
+
 func (r *recordsRepo) UpdateIngestStage(ctx context.Context, id, stage string) error {
 	_, err := r.pool.Exec(ctx,
 		`UPDATE records
@@ -393,14 +399,16 @@ func (r *recordsRepo) UpdateAfterIngest(ctx context.Context, id string, res doma
 		     page_count=$3,
 		     ingest_total_chunks=NULL,
 		     ingest_indexed_chunks=NULL,
+		     ingest_reused_chunks=NULL,
 		     ingest_stage=NULL,
 		     description=COALESCE(NULLIF($4, ''), description),
 		     format=COALESCE(NULLIF($5, ''), format),
 		     mime_type=COALESCE(NULLIF($6, ''), mime_type),
+		     content_hash=NULLIF($8, ''),
 		     error=NULL,
 		     updated_at=now()
 		 WHERE id=$7 AND status <> 'cancelled'`,
-		res.ChunkCount, res.SizeBytes, pageCount, res.Description, format, res.MimeType, id,
+		res.ChunkCount, res.SizeBytes, pageCount, res.Description, format, res.MimeType, id, res.ContentHash,
 	)
 	return err
 }
@@ -415,10 +423,10 @@ func (r *recordsRepo) UpsertFromSource(ctx context.Context, rec domain.Record) (
 	const selectQ = `
 		SELECT r.id, r.domain_id, r.user_id, r.source_id, r.name, r.format, r.status,
 		       r.external_id, r.external_url, r.external_ref, r.mime_type,
-		       r.folder_path, r.folder_id,
+		       r.folder_path, r.folder_id, r.parent_id,
 		       r.description, r.chunk_count, r.size_bytes, r.page_count,
-		       r.ingest_total_chunks, r.ingest_indexed_chunks, r.ingest_stage,
-		       r.source_version, r.source_modified_at, r.error,
+		       r.ingest_total_chunks, r.ingest_indexed_chunks, r.ingest_reused_chunks, r.ingest_stage,
+		       r.source_version, r.source_modified_at, r.error, r.content_hash, r.enrichment,
 		       r.created_at, r.updated_at,
 		       s.id, s.name, s.source_type, s.status
 		FROM records r
@@ -470,15 +478,15 @@ func (r *recordsRepo) createInTx(ctx context.Context, tx pgx.Tx, rec domain.Reco
 		INSERT INTO records
 			(domain_id, user_id, source_id, name, format, status,
 			 external_id, external_url, external_ref, mime_type,
-			 folder_path, folder_id,
+			 folder_path, folder_id, parent_id,
 			 source_version, source_modified_at)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
 		RETURNING id, domain_id, user_id, source_id, name, format, status,
 		          external_id, external_url, external_ref, mime_type,
-		          folder_path, folder_id,
+		          folder_path, folder_id, parent_id,
 		          description, chunk_count, size_bytes, page_count,
-		          ingest_total_chunks, ingest_indexed_chunks, ingest_stage,
-		          source_version, source_modified_at, error,
+		          ingest_total_chunks, ingest_indexed_chunks, ingest_reused_chunks, ingest_stage,
+		          source_version, source_modified_at, error, content_hash, enrichment,
 		          created_at, updated_at,
 		          NULL, NULL, NULL, NULL`
 
@@ -490,7 +498,7 @@ func (r *recordsRepo) createInTx(ctx context.Context, tx pgx.Tx, rec domain.Reco
 	created, err := scanRecord(tx.QueryRow(ctx, q,
 		rec.DomainID, rec.UserID, rec.SourceID, rec.Name, string(rec.Format), string(rec.Status),
 		rec.ExternalID, rec.ExternalURL, rec.ExternalRef, rec.MimeType,
-		rec.FolderPath, rec.FolderID,
+		rec.FolderPath, rec.FolderID, rec.ParentID,
 		rec.SourceVersion, sourceModifiedAt,
 	))
 	if err != nil {
@@ -530,16 +538,18 @@ func (r *recordsRepo) updateFromSourceInTx(
 		    page_count = $11,
 		    folder_path = $12,
 		    folder_id = $13,
+		    parent_id = $14,
 		    ingest_total_chunks = NULL,
 		    ingest_indexed_chunks = NULL,
+		    ingest_reused_chunks = NULL,
 		    updated_at = now()
-		WHERE id = $14
+		WHERE id = $15
 		RETURNING id, domain_id, user_id, source_id, name, format, status,
 		          external_id, external_url, external_ref, mime_type,
-		          folder_path, folder_id,
+		          folder_path, folder_id, parent_id,
 		          description, chunk_count, size_bytes, page_count,
-		          ingest_total_chunks, ingest_indexed_chunks, ingest_stage,
-		          source_version, source_modified_at, error,
+		          ingest_total_chunks, ingest_indexed_chunks, ingest_reused_chunks, ingest_stage,
+		          source_version, source_modified_at, error, content_hash, enrichment,
 		          created_at, updated_at,
 		          NULL, NULL, NULL, NULL`
 
@@ -565,7 +575,7 @@ func (r *recordsRepo) updateFromSourceInTx(
 	updated, err := scanRecord(tx.QueryRow(ctx, baseQ,
 		rec.Name, string(rec.Format), rec.ExternalURL, rec.ExternalRef, rec.MimeType,
 		rec.SourceVersion, sourceModifiedAt, string(nextStatus),
-		chunkCount, sizeBytes, pageCount, rec.FolderPath, rec.FolderID, id,
+		chunkCount, sizeBytes, pageCount, rec.FolderPath, rec.FolderID, rec.ParentID, id,
 	))
 	if err != nil {
 		return domain.Record{}, fmt.Errorf("update record from source: %w", err)
@@ -634,6 +644,84 @@ func (r *recordsRepo) DeleteBySourceExternalIDs(
 	return int(tag.RowsAffected()), nil
 }
 
+func (r *recordsRepo) SourceVersions(
+	ctx context.Context,
+	domainID, sourceID string,
+	externalIDs []string,
+) (map[string]string, error) {
+	versions := make(map[string]string, len(externalIDs))
+	if len(externalIDs) == 0 {
+		return versions, nil
+	}
+	rows, err := r.pool.Query(ctx,
+		`SELECT external_id, COALESCE(source_version, '') FROM records
+		 WHERE domain_id = $1 AND source_id = $2 AND external_id = ANY($3)`,
+		domainID, sourceID, externalIDs,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("select source versions: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var externalID, version string
+		if err := rows.Scan(&externalID, &version); err != nil {
+			return nil, fmt.Errorf("scan source version: %w", err)
+		}
+		versions[externalID] = version
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate source versions: %w", err)
+	}
+	return versions, nil
+}
+
+func (r *recordsRepo) ArchiveIDs(
+	ctx context.Context,
+	domainID, sourceID string,
+	externalIDs []string,
+) ([]string, error) {
+	var roots []string
+	if externalIDs != nil {
+		for _, externalID := range externalIDs {
+			if externalID = strings.TrimSpace(externalID); externalID != "" {
+				roots = append(roots, externalID)
+			}
+		}
+		if len(roots) == 0 {
+			return nil, nil
+		}
+	}
+	rows, err := r.pool.Query(ctx,
+		`WITH RECURSIVE tree AS (
+		     SELECT id, format FROM records
+		     WHERE domain_id = $1 AND source_id = $2
+		       AND ($3::text[] IS NULL OR external_id = ANY($3))
+		     UNION
+		     SELECT c.id, c.format FROM records c JOIN tree t ON c.parent_id = t.id
+		 )
+		 SELECT t.id::text FROM tree t
+		 WHERE t.format = $4
+		   AND EXISTS (SELECT 1 FROM records c WHERE c.parent_id = t.id)`,
+		domainID, sourceID, roots, string(domain.RecordFormatArchive),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("select archive records: %w", err)
+	}
+	defer rows.Close()
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("scan archive record: %w", err)
+		}
+		ids = append(ids, id)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate archive records: %w", err)
+	}
+	return ids, nil
+}
+
 func (r *recordsRepo) Delete(ctx context.Context, id, domainID string) error {
 	tag, err := r.pool.Exec(ctx,
 		`DELETE FROM records WHERE id = $1 AND domain_id = $2`, id, domainID,
@@ -651,3 +739,9 @@ func (r *recordsRepo) Delete(ctx context.Context, id, domainID string) error {
 func isUniqueViolation(err error) bool {
 	return strings.Contains(err.Error(), "23505")
 }
+
+// isInvalidTextRepresentation checks for PostgreSQL invalid-input error
+// (code 22P02), e.g. a malformed UUID.
+func isInvalidTextRepresentation(err error) bool {
+	return strings.Contains(err.Error(), "22P02")
+}
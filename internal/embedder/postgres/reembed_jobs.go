@@ -0,0 +1,196 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+type reembedJobsRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewReembedJobsRepository returns a PostgreSQL-backed ReembedJobRepository.
+func NewReembedJobsRepository(pool *pgxpool.Pool) domain.ReembedJobRepository {
+	return &reembedJobsRepo{pool: pool}
+}
+
+const reembedJobColumns = `id, domain_id, user_id, source_id, record_format, profile, status,
+	total_chunks, embedded_chunks, COALESCE(error, ''), created_at, updated_at, started_at, completed_at`
+
+func (r *reembedJobsRepo) Create(ctx context.Context, job domain.ReembedJob) (domain.ReembedJob, error) {
+	q := `
+		INSERT INTO reembed_jobs (domain_id, user_id, source_id, record_format, profile)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING ` + reembedJobColumns
+
+	created, err := scanReembedJob(r.pool.QueryRow(ctx, q,
+		job.DomainID, job.UserID, job.SourceID, string(job.Format), job.Profile))
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.ReembedJob{}, domain.ErrConflict
+		}
+		return domain.ReembedJob{}, fmt.Errorf("insert reembed job: %w", err)
+	}
+	return created, nil
+}
+
+func (r *reembedJobsRepo) Get(ctx context.Context, id, domainID string) (domain.ReembedJob, error) {
+	q := `SELECT ` + reembedJobColumns + ` FROM reembed_jobs WHERE id = $1 AND domain_id = $2`
+
+	job, err := scanReembedJob(r.pool.QueryRow(ctx, q, id, domainID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidTextRepresentation(err) {
+			return domain.ReembedJob{}, domain.ErrNotFound
+		}
+		return domain.ReembedJob{}, fmt.Errorf("get reembed job: %w", err)
+	}
+	return job, nil
+}
+
+func (r *reembedJobsRepo) List(ctx context.Context, domainID string, limit int) ([]domain.ReembedJob, error) {
+	q := `SELECT ` + reembedJobColumns + ` FROM reembed_jobs WHERE domain_id = $1 ORDER BY created_at DESC LIMIT $2`
+
+	rows, err := r.pool.Query(ctx, q, domainID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("list reembed jobs: %w", err)
+	}
+	defer rows.Close()
+
+	var jobs []domain.ReembedJob
+	for rows.Next() {
+		job, err := scanReembedJob(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan reembed job: %w", err)
+		}
+		jobs = append(jobs, job)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate reembed jobs: %w", err)
+	}
+	return jobs, nil
+}
+
+func (r *reembedJobsRepo) ClaimNext(ctx context.Context, staleAfter time.Duration) (domain.ReembedJob, error) {
+	// SKIP LOCKED lets several embedder replicas poll the same table without
+	// claiming the same job.
+	q := `
+		UPDATE reembed_jobs SET
+			status = 'running',
+			started_at = COALESCE(started_at, now()),
+			updated_at = now()
+		WHERE id = (
+			SELECT id FROM reembed_jobs
+			WHERE status = 'queued'
+			   OR (status = 'running' AND updated_at < now() - make_interval(secs => $1))
+			ORDER BY created_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING ` + reembedJobColumns
+
+	job, err := scanReembedJob(r.pool.QueryRow(ctx, q, staleAfter.Seconds()))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.ReembedJob{}, domain.ErrNotFound
+		}
+		return domain.ReembedJob{}, fmt.Errorf("claim reembed job: %w", err)
+	}
+	return job, nil
+}
+
+func (r *reembedJobsRepo) UpdateProgress(ctx context.Context, id string, total, embedded int64) (domain.ReembedJobStatus, error) {
+	// A cancelled job keeps its status; the worker reads it back and stops.
+	var status string
+	err := r.pool.QueryRow(ctx, `
+		UPDATE reembed_jobs SET
+			total_chunks = CASE WHEN status = 'running' THEN $2 ELSE total_chunks END,
+			embedded_chunks = CASE WHEN status = 'running' THEN $3 ELSE embedded_chunks END,
+			updated_at = now()
+		WHERE id = $1
+		RETURNING status`, id, total, embedded,
+	).Scan(&status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", domain.ErrNotFound
+		}
+		return "", fmt.Errorf("update reembed job progress: %w", err)
+	}
+	return domain.ReembedJobStatus(status), nil
+}
+
+func (r *reembedJobsRepo) Finish(ctx context.Context, id string, status domain.ReembedJobStatus, errMsg string) error {
+	tag, err := r.pool.Exec(ctx, `
+		UPDATE reembed_jobs SET
+			status = $2,
+			error = NULLIF($3, ''),
+			embedded_chunks = CASE WHEN $2 = 'completed' THEN total_chunks ELSE embedded_chunks END,
+			updated_at = now(),
+			completed_at = now()
+		WHERE id = $1`, id, string(status), errMsg)
+	if err != nil {
+		return fmt.Errorf("finish reembed job: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *reembedJobsRepo) Cancel(ctx context.Context, id, domainID string) (domain.ReembedJob, error) {
+	q := `
+		UPDATE reembed_jobs SET status = 'cancelled', updated_at = now(), completed_at = now()
+		WHERE id = $1 AND domain_id = $2 AND status IN ('queued', 'running')
+		RETURNING ` + reembedJobColumns
+
+	job, err := scanReembedJob(r.pool.QueryRow(ctx, q, id, domainID))
+	if err == nil {
+		return job, nil
+	}
+	if !errors.Is(err, pgx.ErrNoRows) && !isInvalidTextRepresentation(err) {
+		return domain.ReembedJob{}, fmt.Errorf("cancel reembed job: %w", err)
+	}
+	// Nothing was cancelled: tell a finished job from a missing one.
+	if _, err := r.Get(ctx, id, domainID); err != nil {
+		return domain.ReembedJob{}, err
+	}
+	return domain.ReembedJob{}, domain.ErrConflict
+}
+
+func scanReembedJob(row interface {
+	Scan(dest ...any) error
+},
+) (domain.ReembedJob, error) {
+	var (
+		job                    domain.ReembedJob
+		format, status         string
+		startedAt, completedAt pgtype.Timestamptz
+	)
+	if err := row.Scan(
+		&job.ID, &job.DomainID, &job.UserID, &job.SourceID, &format, &job.Profile, &status,
+		&job.TotalChunks, &job.EmbeddedChunks, &job.Error, &job.CreatedAt, &job.UpdatedAt,
+		&startedAt, &completedAt,
+	); err != nil {
+		return domain.ReembedJob{}, err
+	}
+	job.Format = domain.RecordFormat(format)
+	job.Status = domain.ReembedJobStatus(status)
+	if startedAt.Valid {
+		t := startedAt.Time
+		job.StartedAt = &t
+	}
+	if completedAt.Valid {
+		t := completedAt.Time
+		job.CompletedAt = &t
+	}
+	return job, nil
+}
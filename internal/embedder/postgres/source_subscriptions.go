@@ -0,0 +1,199 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+type sourceSubscriptionsRepo struct {
+	pool *pgxpool.Pool
+}
+
+// NewSourceSubscriptionsRepository returns a PostgreSQL-backed
+// SourceSubscriptionRepository.
+func NewSourceSubscriptionsRepository(pool *pgxpool.Pool) domain.SourceSubscriptionRepository {
+	return &sourceSubscriptionsRepo{pool: pool}
+}
+
+const sourceSubscriptionColumns = `id, source_id, domain_id, provider, external_id, resource, client_state,
+	feed_cursor, expires_at, created_at, updated_at`
+
+func (r *sourceSubscriptionsRepo) Create(ctx context.Context, sub domain.SourceSubscription) (domain.SourceSubscription, error) {
+	q := `
+		INSERT INTO source_subscriptions (source_id, domain_id, provider, external_id, resource, client_state, feed_cursor, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING ` + sourceSubscriptionColumns
+
+	row := r.pool.QueryRow(ctx, q,
+		sub.SourceID, sub.DomainID, string(sub.Provider), sub.ExternalID, sub.Resource,
+		sub.ClientState, sub.Cursor, sub.ExpiresAt,
+	)
+	created, err := scanSourceSubscription(row)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return domain.SourceSubscription{}, domain.ErrConflict
+		}
+		return domain.SourceSubscription{}, fmt.Errorf("insert source subscription: %w", err)
+	}
+	return created, nil
+}
+
+func (r *sourceSubscriptionsRepo) GetByID(ctx context.Context, id string) (domain.SourceSubscription, error) {
+	q := `SELECT ` + sourceSubscriptionColumns + ` FROM source_subscriptions WHERE id = $1`
+
+	sub, err := scanSourceSubscription(r.pool.QueryRow(ctx, q, id))
+	if err != nil {
+		// Callback URLs carry arbitrary IDs; anything that is not a stored
+		// UUID is simply not found.
+		if errors.Is(err, pgx.ErrNoRows) || isInvalidTextRepresentation(err) {
+			return domain.SourceSubscription{}, domain.ErrNotFound
+		}
+		return domain.SourceSubscription{}, fmt.Errorf("get source subscription: %w", err)
+	}
+	return sub, nil
+}
+
+func (r *sourceSubscriptionsRepo) ListByProvider(ctx context.Context, provider domain.SourceType) ([]domain.SourceSubscription, error) {
+	q := `SELECT ` + sourceSubscriptionColumns + ` FROM source_subscriptions WHERE provider = $1 ORDER BY created_at`
+	return r.list(ctx, q, string(provider))
+}
+
+func (r *sourceSubscriptionsRepo) ListExpiring(ctx context.Context, before time.Time) ([]domain.SourceSubscription, error) {
+	q := `SELECT ` + sourceSubscriptionColumns + ` FROM source_subscriptions
+		WHERE expires_at IS NOT NULL AND expires_at < $1
+		ORDER BY expires_at`
+	return r.list(ctx, q, before)
+}
+
+func (r *sourceSubscriptionsRepo) list(ctx context.Context, q string, args ...any) ([]domain.SourceSubscription, error) {
+	rows, err := r.pool.Query(ctx, q, args...)
+	if err != nil {
+		return nil, fmt.Errorf("list source subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var subs []domain.SourceSubscription
+	for rows.Next() {
+		sub, err := scanSourceSubscription(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan source subscription: %w", err)
+		}
+		subs = append(subs, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate source subscriptions: %w", err)
+	}
+	return subs, nil
+}
+
+func (r *sourceSubscriptionsRepo) ListUnsubscribedSources(ctx context.Context, types []domain.SourceType) ([]domain.SourceRef, error) {
+	if len(types) == 0 {
+		return nil, nil
+	}
+	names := make([]string, 0, len(types))
+	for _, t := range types {
+		names = append(names, string(t))
+	}
+	const q = `
+		SELECT s.id, s.domain_id
+		FROM sources s
+		WHERE s.source_type = ANY($1) AND s.sync_enabled
+		  AND NOT EXISTS (SELECT 1 FROM source_subscriptions ss WHERE ss.source_id = s.id)
+		ORDER BY s.created_at`
+
+	rows, err := r.pool.Query(ctx, q, names)
+	if err != nil {
+		return nil, fmt.Errorf("list unsubscribed sources: %w", err)
+	}
+	defer rows.Close()
+
+	var refs []domain.SourceRef
+	for rows.Next() {
+		var ref domain.SourceRef
+		if err := rows.Scan(&ref.ID, &ref.DomainID); err != nil {
+			return nil, fmt.Errorf("scan unsubscribed source: %w", err)
+		}
+		refs = append(refs, ref)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("iterate unsubscribed sources: %w", err)
+	}
+	return refs, nil
+}
+
+func (r *sourceSubscriptionsRepo) Update(ctx context.Context, sub domain.SourceSubscription) (domain.SourceSubscription, error) {
+	q := `
+		UPDATE source_subscriptions
+		SET external_id = $1,
+		    resource = $2,
+		    expires_at = $3,
+		    updated_at = now()
+		WHERE id = $4
+		RETURNING ` + sourceSubscriptionColumns
+
+	row := r.pool.QueryRow(ctx, q, sub.ExternalID, sub.Resource, sub.ExpiresAt, sub.ID)
+	updated, err := scanSourceSubscription(row)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return domain.SourceSubscription{}, domain.ErrNotFound
+		}
+		return domain.SourceSubscription{}, fmt.Errorf("update source subscription: %w", err)
+	}
+	return updated, nil
+}
+
+func (r *sourceSubscriptionsRepo) UpdateCursor(ctx context.Context, id, cursor string) error {
+	tag, err := r.pool.Exec(ctx,
+		`UPDATE source_subscriptions SET feed_cursor = $1, updated_at = now() WHERE id = $2`, cursor, id)
+	if err != nil {
+		return fmt.Errorf("update source subscription cursor: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func (r *sourceSubscriptionsRepo) Delete(ctx context.Context, id string) error {
+	tag, err := r.pool.Exec(ctx, `DELETE FROM source_subscriptions WHERE id = $1`, id)
+	if err != nil {
+		return fmt.Errorf("delete source subscription: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return domain.ErrNotFound
+	}
+	return nil
+}
+
+func scanSourceSubscription(row interface {
+	Scan(dest ...any) error
+},
+) (domain.SourceSubscription, error) {
+	var (
+		sub       domain.SourceSubscription
+		provider  string
+		expiresAt pgtype.Timestamptz
+	)
+	if err := row.Scan(
+		&sub.ID, &sub.SourceID, &sub.DomainID, &provider, &sub.ExternalID, &sub.Resource,
+		&sub.ClientState, &sub.Cursor, &expiresAt, &sub.CreatedAt, &sub.UpdatedAt,
+	); err != nil {
+		return domain.SourceSubscription{}, err
+	}
+	sub.Provider = domain.SourceType(provider)
+	if expiresAt.Valid {
+		t := expiresAt.Time
+		sub.ExpiresAt = &t
+	}
+	return sub, nil
+}
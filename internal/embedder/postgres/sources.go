@@ -10,19 +10,25 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgtype"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/secrets"
 )
 
 type sourcesRepo struct {
-	pool *pgxpool.Pool
+	pool     *pgxpool.Pool
+	envelope *secrets.Envelope
 }
 
 // NewSourcesRepository returns a PostgreSQL-backed SourceRepository.
-func NewSourcesRepository(pool *pgxpool.Pool) domain.SourceRepository {
-	return &sourcesRepo{pool: pool}
+// Credential fields of source configs are envelope-encrypted with envelope
+// before they are written and decrypted transparently on read. A nil
+// envelope stores configs as given.
+func NewSourcesRepository(pool *pgxpool.Pool, envelope *secrets.Envelope) domain.SourceRepository {
+	return &sourcesRepo{pool: pool, envelope: envelope}
 }
 
 func (r *sourcesRepo) Create(ctx context.Context, s domain.Source) (domain.Source, error) {
@@ -30,18 +36,23 @@ func (r *sourcesRepo) Create(ctx context.Context, s domain.Source) (domain.Sourc
 	if cfg == nil {
 		cfg = json.RawMessage("{}")
 	}
+	// The ID is chosen here because credentials are sealed for it.
+	id := uuid.NewString()
+	stored, err := r.sealConfig(id, s.Type, cfg)
+	if err != nil {
+		return domain.Source{}, err
+	}
 
 	const q = `
-		INSERT INTO sources (domain_id, user_id, source_type, name, config, status, sync_enabled, auto_sync_interval)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-		RETURNING id, created_at, updated_at`
+		INSERT INTO sources (id, domain_id, user_id, source_type, name, config, status, sync_enabled, auto_sync_interval)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING created_at, updated_at`
 
-	var id string
 	var createdAt, updatedAt time.Time
-	err := r.pool.QueryRow(ctx, q,
-		s.DomainID, s.UserID, string(s.Type), s.Name, []byte(cfg),
+	err = r.pool.QueryRow(ctx, q,
+		id, s.DomainID, s.UserID, string(s.Type), s.Name, []byte(stored),
 		string(s.Status), s.SyncEnabled, s.AutoSyncInterval,
-	).Scan(&id, &createdAt, &updatedAt)
+	).Scan(&createdAt, &updatedAt)
 	if err != nil {
 		if isUniqueViolation(err) {
 			return domain.Source{}, domain.ErrConflict
@@ -72,7 +83,7 @@ func (r *sourcesRepo) GetByID(ctx context.Context, id, domainID string) (domain.
 		}
 		return domain.Source{}, fmt.Errorf("get source: %w", err)
 	}
-	return s, nil
+	return r.openSource(s)
 }
 
 func (r *sourcesRepo) List(ctx context.Context, domainID string, p domain.Page) (domain.SourcePage, error) {
@@ -106,6 +117,9 @@ func (r *sourcesRepo) List(ctx context.Context, domainID string, p domain.Page)
 		if err != nil {
 			return domain.SourcePage{}, fmt.Errorf("scan source: %w", err)
 		}
+		if s, err = r.openSource(s); err != nil {
+			return domain.SourcePage{}, err
+		}
 		sources = append(sources, s)
 	}
 	if err := rows.Err(); err != nil {
@@ -166,7 +180,7 @@ func (r *sourcesRepo) UpdateSyncResult(
 		}
 		return domain.Source{}, fmt.Errorf("update source sync result: %w", err)
 	}
-	return src, nil
+	return r.openSource(src)
 }
 
 func (r *sourcesRepo) UpdateConfig(
@@ -186,7 +200,25 @@ func (r *sourcesRepo) UpdateConfig(
 		          last_sync_at, last_sync_error, next_sync_at,
 		          created_at, updated_at`
 
-	row := r.pool.QueryRow(ctx, q, []byte(config), string(domain.SourceStatusActive), id, domainID)
+	stored := config
+	if r.envelope != nil {
+		var sourceType string
+		if err := r.pool.QueryRow(ctx,
+			`SELECT source_type FROM sources WHERE id = $1 AND domain_id = $2`, id, domainID,
+		).Scan(&sourceType); err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return domain.Source{}, domain.ErrNotFound
+			}
+			return domain.Source{}, fmt.Errorf("get source type: %w", err)
+		}
+		sealed, err := r.sealConfig(id, domain.SourceType(sourceType), config)
+		if err != nil {
+			return domain.Source{}, err
+		}
+		stored = sealed
+	}
+
+	row := r.pool.QueryRow(ctx, q, []byte(stored), string(domain.SourceStatusActive), id, domainID)
 	src, err := scanSource(row)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
@@ -194,7 +226,98 @@ func (r *sourcesRepo) UpdateConfig(
 		}
 		return domain.Source{}, fmt.Errorf("update source config: %w", err)
 	}
-	return src, nil
+	return r.openSource(src)
+}
+
+// ReencryptSourceConfigs seals the credential fields of every source config
+// under the envelope's current KEK. Plaintext configs are encrypted and
+// configs sealed under a previous KEK are rotated; it returns the number of
+// rows rewritten.
+func ReencryptSourceConfigs(ctx context.Context, pool *pgxpool.Pool, envelope *secrets.Envelope) (int, error) {
+	if envelope == nil {
+		return 0, secrets.ErrNotConfigured
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("begin re-encrypt: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `SELECT id, source_type, config FROM sources FOR UPDATE`)
+	if err != nil {
+		return 0, fmt.Errorf("list source configs: %w", err)
+	}
+	type pending struct {
+		id     string
+		config []byte
+	}
+	var updates []pending
+	for rows.Next() {
+		var (
+			id, sourceType string
+			config         []byte
+		)
+		if err := rows.Scan(&id, &sourceType, &config); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("scan source config: %w", err)
+		}
+		if secrets.IsSealed(config) && !envelope.NeedsRotation(config) {
+			continue
+		}
+		sealed, err := envelope.SealFields(config, domain.SourceCredentialFields(domain.SourceType(sourceType)), id)
+		if err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("re-encrypt source %s: %w", id, err)
+		}
+		if !secrets.IsSealed(sealed) && !secrets.IsSealed(config) {
+			continue
+		}
+		updates = append(updates, pending{id: id, config: sealed})
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("iterate source configs: %w", err)
+	}
+
+	for _, u := range updates {
+		if _, err := tx.Exec(ctx, `UPDATE sources SET config = $1 WHERE id = $2`, u.config, u.id); err != nil {
+			return 0, fmt.Errorf("update source %s: %w", u.id, err)
+		}
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, fmt.Errorf("commit re-encrypt: %w", err)
+	}
+	return len(updates), nil
+}
+
+// sealConfig encrypts the credential fields of source id's config for
+// storage.
+func (r *sourcesRepo) sealConfig(id string, t domain.SourceType, config json.RawMessage) (json.RawMessage, error) {
+	if r.envelope == nil {
+		return config, nil
+	}
+	sealed, err := r.envelope.SealFields(config, domain.SourceCredentialFields(t), id)
+	if err != nil {
+		return nil, fmt.Errorf("encrypt source credentials: %w", err)
+	}
+	return sealed, nil
+}
+
+// openSource decrypts the credential fields of a stored source config.
+func (r *sourcesRepo) openSource(s domain.Source) (domain.Source, error) {
+	if !secrets.IsSealed(s.Config) {
+		return s, nil
+	}
+	if r.envelope == nil {
+		return domain.Source{}, fmt.Errorf("decrypt source %s credentials: %w", s.ID, secrets.ErrNotConfigured)
+	}
+	config, err := r.envelope.OpenFields(s.Config, s.ID)
+	if err != nil {
+		return domain.Source{}, fmt.Errorf("decrypt source %s credentials: %w", s.ID, err)
+	}
+	s.Config = config
+	return s, nil
 }
 
 // scanSource scans a source row from either a pgx.Row or pgx.Rows.
@@ -0,0 +1,20 @@
+-- Copyright (c) Ultraviolet
+-- SPDX-License-Identifier: Apache-2.0
+
+-- Server-side model catalogue. API keys are sealed by the embedder before
+-- they reach the database; api_key_ciphertext is NULL for keyless providers.
+CREATE TABLE IF NOT EXISTS model_providers (
+    id                 UUID        PRIMARY KEY DEFAULT uuid_generate_v4(),
+    domain_id          TEXT        NOT NULL,
+    user_id            TEXT        NOT NULL,
+    name               TEXT        NOT NULL,
+    provider           TEXT        NOT NULL,
+    base_url           TEXT        NOT NULL DEFAULT '',
+    models             TEXT[]      NOT NULL DEFAULT '{}',
+    api_key_ciphertext BYTEA,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS model_providers_domain_name_idx
+    ON model_providers (domain_id, name);
@@ -0,0 +1,30 @@
+-- Copyright (c) Ultraviolet
+-- SPDX-License-Identifier: Apache-2.0
+
+-- Change-notification subscriptions registered with the systems behind
+-- sources. client_state is the token providers echo back with every
+-- notification; feed_cursor is the change-feed position targeted syncs resume
+-- from (a Graph delta link or a Drive page token).
+CREATE TABLE IF NOT EXISTS source_subscriptions (
+    id           UUID        PRIMARY KEY DEFAULT uuid_generate_v4(),
+    source_id    UUID        NOT NULL REFERENCES sources(id) ON DELETE CASCADE,
+    domain_id    TEXT        NOT NULL,
+    provider     TEXT        NOT NULL,
+    external_id  TEXT        NOT NULL DEFAULT '',
+    resource     TEXT        NOT NULL DEFAULT '',
+    client_state TEXT        NOT NULL,
+    feed_cursor  TEXT        NOT NULL DEFAULT '',
+    expires_at   TIMESTAMPTZ,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at   TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS source_subscriptions_source_idx
+    ON source_subscriptions (source_id);
+
+CREATE INDEX IF NOT EXISTS source_subscriptions_provider_resource_idx
+    ON source_subscriptions (provider, resource);
+
+CREATE INDEX IF NOT EXISTS source_subscriptions_expires_at_idx
+    ON source_subscriptions (expires_at)
+    WHERE expires_at IS NOT NULL;
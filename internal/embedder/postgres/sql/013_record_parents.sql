@@ -0,0 +1,10 @@
+-- Copyright (c) Ultraviolet
+-- SPDX-License-Identifier: Apache-2.0
+
+-- Records unpacked from an archive or email container point at the record
+-- they came from. Deleting the container deletes everything unpacked from it.
+ALTER TABLE records ADD COLUMN IF NOT EXISTS parent_id UUID REFERENCES records(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS records_parent_id_idx
+    ON records (parent_id)
+    WHERE parent_id IS NOT NULL;
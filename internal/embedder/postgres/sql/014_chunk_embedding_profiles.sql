@@ -0,0 +1,23 @@
+-- Copyright (c) Ultraviolet
+-- SPDX-License-Identifier: Apache-2.0
+
+-- Chunks record the embedding profile that produced their vector, so profiles
+-- with different models and dimensions can share the table. Vectors are only
+-- compared within one profile. Rows from before this migration have no
+-- profile; the embedder assigns them to the default profile at startup when
+-- their dimensions match it.
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS embedding_profile TEXT;
+
+-- Drop the fixed vector(768) size left by older schemas. Guarded so the
+-- table is not rewritten on every startup.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_attribute
+        WHERE attrelid = 'chunks'::regclass AND attname = 'embedding' AND atttypmod <> -1
+    ) THEN
+        ALTER TABLE chunks ALTER COLUMN embedding TYPE vector;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS chunks_domain_profile_idx ON chunks (domain_id, embedding_profile);
@@ -0,0 +1,38 @@
+-- Copyright (c) Ultraviolet
+-- SPDX-License-Identifier: Apache-2.0
+
+-- Chunks record the model that produced their vector, so changing a
+-- profile's model never mixes vectors from two models in one search. Rows
+-- from before this migration are labelled at startup.
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS embedding_model TEXT;
+
+-- Re-embedding jobs stage new vectors next to the live ones and swap them in
+-- one transaction when every chunk in the job's scope has been re-embedded.
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS pending_embedding vector;
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS pending_model TEXT;
+
+CREATE TABLE IF NOT EXISTS reembed_jobs (
+    id              UUID        PRIMARY KEY DEFAULT uuid_generate_v4(),
+    domain_id       TEXT        NOT NULL,
+    user_id         TEXT        NOT NULL,
+    -- Optional scope; empty means every source, format or profile.
+    source_id       TEXT        NOT NULL DEFAULT '',
+    record_format   TEXT        NOT NULL DEFAULT '',
+    profile         TEXT        NOT NULL DEFAULT '',
+    status          TEXT        NOT NULL DEFAULT 'queued',
+    total_chunks    BIGINT      NOT NULL DEFAULT 0,
+    embedded_chunks BIGINT      NOT NULL DEFAULT 0,
+    error           TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT now(),
+    started_at      TIMESTAMPTZ,
+    completed_at    TIMESTAMPTZ
+);
+
+CREATE INDEX IF NOT EXISTS reembed_jobs_domain_idx ON reembed_jobs (domain_id, created_at DESC);
+
+-- One job at a time per domain, so two jobs never stage vectors for the
+-- same chunks.
+CREATE UNIQUE INDEX IF NOT EXISTS reembed_jobs_active_domain_idx
+    ON reembed_jobs (domain_id)
+    WHERE status IN ('queued', 'running');
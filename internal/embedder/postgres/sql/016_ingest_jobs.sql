@@ -0,0 +1,34 @@
+-- Copyright (c) Ultraviolet
+-- SPDX-License-Identifier: Apache-2.0
+
+-- The ingest queue. Workers lease jobs with FOR UPDATE SKIP LOCKED and renew
+-- the lease while they work, so a job whose worker died is claimed again once
+-- its lease expires.
+CREATE TABLE IF NOT EXISTS ingest_jobs (
+    id               UUID        PRIMARY KEY DEFAULT uuid_generate_v4(),
+    record_id        UUID        NOT NULL UNIQUE REFERENCES records(id) ON DELETE CASCADE,
+    domain_id        TEXT        NOT NULL,
+    status           TEXT        NOT NULL DEFAULT 'queued',
+    attempts         INT         NOT NULL DEFAULT 0,
+    run_after        TIMESTAMPTZ NOT NULL DEFAULT now(),
+    lease_owner      TEXT,
+    lease_expires_at TIMESTAMPTZ,
+    heartbeat_at     TIMESTAMPTZ,
+    last_error       TEXT,
+    error_class      TEXT,
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS ingest_jobs_runnable_idx ON ingest_jobs (run_after) WHERE status = 'queued';
+CREATE INDEX IF NOT EXISTS ingest_jobs_lease_idx ON ingest_jobs (lease_expires_at) WHERE status = 'leased';
+CREATE INDEX IF NOT EXISTS ingest_jobs_domain_idx ON ingest_jobs (domain_id, status, updated_at DESC);
+
+-- Enqueue scans for queued records on every poll.
+CREATE INDEX IF NOT EXISTS records_queued_idx ON records (created_at) WHERE status = 'queued';
+
+-- Records left processing by a worker that stopped before jobs existed would
+-- otherwise never finish.
+INSERT INTO ingest_jobs (record_id, domain_id)
+SELECT id, domain_id FROM records WHERE status = 'processing'
+ON CONFLICT (record_id) DO NOTHING;
@@ -0,0 +1,20 @@
+-- Copyright (c) Ultraviolet
+-- SPDX-License-Identifier: Apache-2.0
+
+-- Direct uploads are claimed before records found by source syncs.
+ALTER TABLE ingest_jobs ADD COLUMN IF NOT EXISTS priority INT NOT NULL DEFAULT 0;
+
+-- Claim walks each domain's runnable jobs by priority, then age.
+CREATE INDEX IF NOT EXISTS ingest_jobs_fair_idx
+    ON ingest_jobs (domain_id, priority DESC, run_after) WHERE status = 'queued';
+
+-- Direct upload sources are recognized by config.kind alone; the few
+-- created before it was recorded are found by their name once.
+UPDATE sources SET config = config || '{"kind": "direct_upload"}'
+WHERE source_type = 'local_fs' AND name = 'Direct Uploads'
+  AND config->>'kind' IS DISTINCT FROM 'direct_upload';
+
+UPDATE ingest_jobs j SET priority = 100
+FROM records r JOIN sources s ON s.id = r.source_id
+WHERE r.id = j.record_id AND j.priority = 0 AND j.status = 'queued'
+  AND s.config->>'kind' = 'direct_upload';
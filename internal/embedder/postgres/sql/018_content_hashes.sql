@@ -0,0 +1,25 @@
+-- Copyright (c) Ultraviolet
+-- SPDX-License-Identifier: Apache-2.0
+
+-- SHA-256 of a record's extracted text and of each chunk's text, so the same
+-- document ingested through several records is recognised.
+ALTER TABLE records ADD COLUMN IF NOT EXISTS content_hash TEXT;
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS content_hash TEXT;
+
+CREATE INDEX IF NOT EXISTS records_content_hash_idx
+    ON records (domain_id, content_hash) WHERE content_hash IS NOT NULL;
+
+-- Embeddings of chunk texts by profile and model, so identical chunks reuse
+-- the cached vector instead of calling the model. 023 scopes entries to a
+-- domain.
+CREATE TABLE IF NOT EXISTS embedding_cache (
+    profile      TEXT        NOT NULL,
+    model        TEXT        NOT NULL,
+    content_hash TEXT        NOT NULL,
+    embedding    vector      NOT NULL,
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+    last_used_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (profile, model, content_hash)
+);
+
+CREATE INDEX IF NOT EXISTS embedding_cache_last_used_idx ON embedding_cache (last_used_at);
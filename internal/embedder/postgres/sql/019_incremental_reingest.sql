@@ -0,0 +1,6 @@
+-- Copyright (c) Ultraviolet
+-- SPDX-License-Identifier: Apache-2.0
+
+-- Re-ingesting a changed record keeps the chunks whose text did not change;
+-- their number is reported next to the ingest progress.
+ALTER TABLE records ADD COLUMN IF NOT EXISTS ingest_reused_chunks INT;
@@ -0,0 +1,6 @@
+-- Copyright (c) Ultraviolet
+-- SPDX-License-Identifier: Apache-2.0
+
+-- LLM-generated summary, title, language, entities and keywords of a
+-- record's text, with the content hash they were generated from.
+ALTER TABLE records ADD COLUMN IF NOT EXISTS enrichment JSONB;
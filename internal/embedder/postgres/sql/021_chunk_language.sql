@@ -0,0 +1,32 @@
+-- Copyright (c) Ultraviolet
+-- SPDX-License-Identifier: Apache-2.0
+
+-- Detected language of each chunk and the text search configuration its
+-- content is indexed with. Chunks stored before languages were detected keep
+-- the English configuration until the ingest worker backfills them.
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS language TEXT;
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS fts_config regconfig NOT NULL DEFAULT 'english';
+
+-- Replace the English-only GIN index from 004_fts_index.sql with one on each
+-- chunk's tsvector in its own configuration, which the hybrid keyword CTE
+-- matches against the query parsed with the chunk's configuration. The new
+-- index keeps the old name so 004's CREATE INDEX IF NOT EXISTS leaves it
+-- alone on later runs, and the English index is dropped only while it still
+-- has its old definition.
+DO $$
+BEGIN
+    IF EXISTS (
+        SELECT 1 FROM pg_indexes
+        WHERE schemaname = current_schema()
+          AND indexname = 'chunks_content_fts_idx'
+          AND indexdef NOT LIKE '%fts_config%'
+    ) THEN
+        DROP INDEX chunks_content_fts_idx;
+    END IF;
+END $$;
+
+CREATE INDEX IF NOT EXISTS chunks_content_fts_idx
+    ON chunks USING GIN (to_tsvector(fts_config, content));
+
+CREATE INDEX IF NOT EXISTS chunks_language_backfill_idx
+    ON chunks (id) WHERE language IS NULL;
@@ -0,0 +1,9 @@
+-- Copyright (c) Ultraviolet
+-- SPDX-License-Identifier: Apache-2.0
+
+-- Streamed re-ingests stage a record's new chunks with record_id unset, so
+-- searches, which join chunks to their records, keep returning the live
+-- chunks until the staged ones are swapped in.
+ALTER TABLE chunks ADD COLUMN IF NOT EXISTS staged_record_id UUID REFERENCES records(id) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS chunks_staged_record_id_idx ON chunks (staged_record_id) WHERE staged_record_id IS NOT NULL;
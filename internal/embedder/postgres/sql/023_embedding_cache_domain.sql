@@ -0,0 +1,20 @@
+-- Copyright (c) Ultraviolet
+-- SPDX-License-Identifier: Apache-2.0
+
+-- Scope cached embeddings to a domain, so one tenant's cache hits cannot
+-- reveal which texts another tenant has embedded. Existing entries have no
+-- domain and are dropped; they are rebuilt as chunks are embedded again.
+DO $$
+BEGIN
+    IF NOT EXISTS (
+        SELECT 1 FROM information_schema.columns
+        WHERE table_schema = current_schema()
+          AND table_name = 'embedding_cache' AND column_name = 'domain_id'
+    ) THEN
+        TRUNCATE embedding_cache;
+        ALTER TABLE embedding_cache DROP CONSTRAINT embedding_cache_pkey;
+        ALTER TABLE embedding_cache ADD COLUMN domain_id TEXT NOT NULL;
+        ALTER TABLE embedding_cache ADD PRIMARY KEY (domain_id, profile, model, content_hash);
+    END IF;
+END
+$$;
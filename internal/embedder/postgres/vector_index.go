@@ -0,0 +1,401 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+// Approximate nearest-neighbour index methods for chunk embeddings.
+const (
+	VectorIndexHNSW    = "hnsw"
+	VectorIndexIVFFlat = "ivfflat"
+	VectorIndexNone    = "none"
+)
+
+const (
+	// vectorIndexPrefix marks the chunk indexes managed by EnsureVectorIndexes.
+	vectorIndexPrefix = "chunks_ann_"
+	// maxIndexedDimensions is the largest vector pgvector's hnsw and ivfflat
+	// indexes accept. Profiles above it are searched exactly.
+	maxIndexedDimensions = 2000
+	// maxEfSearch is the largest hnsw.ef_search pgvector accepts.
+	maxEfSearch = 1000
+	// vectorIndexLockKey is the advisory lock that serializes
+	// EnsureVectorIndexes across replicas sharing a database.
+	vectorIndexLockKey int64 = 0x637562655f616e6e
+	// vectorIndexLockRetry is how often a replica waiting for another
+	// replica's index builds tries to take the lock again.
+	vectorIndexLockRetry = 5 * time.Second
+)
+
+// VectorIndexConfig controls the approximate nearest-neighbour indexes on
+// chunk embeddings and the per-query settings used to search them. Zero
+// values leave pgvector's defaults in place.
+type VectorIndexConfig struct {
+	// Method is hnsw, ivfflat or none.
+	Method string
+	// HNSWM and HNSWEfConstruction are the hnsw build parameters.
+	HNSWM              int
+	HNSWEfConstruction int
+	// IVFFlatLists is the number of ivfflat lists. ivfflat clusters the rows
+	// present when the index is built, so prefer hnsw for a growing table.
+	IVFFlatLists int
+	// EfSearch is the hnsw candidate list size. A query always asks for at
+	// least as many candidates as it ranks, so this only raises recall.
+	EfSearch int
+	// Probes is the number of ivfflat lists searched per query.
+	Probes int
+	// IterativeScan is off, relaxed_order or strict_order. Iterative scans
+	// keep reading the index when domain and record filters discard
+	// candidates, so small domains still get a full result list. It needs
+	// pgvector 0.8 and is turned off on older versions.
+	IterativeScan string
+}
+
+// VectorIndexSpec describes the vectors one embedding profile stores with
+// one model.
+type VectorIndexSpec struct {
+	Profile    string
+	Model      string
+	Dimensions int
+	Distance   domain.VectorDistance
+}
+
+// ConfigureVectorIndex validates cfg and applies it to later searches and to
+// EnsureVectorIndexes. Call it once at startup, before searching.
+func (r *ChunksRepository) ConfigureVectorIndex(ctx context.Context, cfg VectorIndexConfig) error {
+	switch cfg.Method {
+	case "":
+		cfg.Method = VectorIndexNone
+	case VectorIndexHNSW, VectorIndexIVFFlat, VectorIndexNone:
+	default:
+		return fmt.Errorf("unknown vector index method %q", cfg.Method)
+	}
+	switch cfg.IterativeScan {
+	case "", "off", "relaxed_order", "strict_order":
+	default:
+		return fmt.Errorf("unknown vector iterative scan mode %q", cfg.IterativeScan)
+	}
+	if cfg.EfSearch > maxEfSearch {
+		cfg.EfSearch = maxEfSearch
+	}
+
+	if cfg.IterativeScan == "off" {
+		cfg.IterativeScan = ""
+	}
+	if cfg.IterativeScan != "" && cfg.Method != VectorIndexNone {
+		var version string
+		if err := r.db.QueryRow(ctx,
+			`SELECT extversion FROM pg_extension WHERE extname = 'vector'`,
+		).Scan(&version); err != nil {
+			return fmt.Errorf("read pgvector version: %w", err)
+		}
+		if !pgvectorAtLeast(version, 0, 8) {
+			slog.Warn("pgvector does not support iterative index scans; filtered searches may return fewer results",
+				"pgvector_version", version)
+			cfg.IterativeScan = ""
+		}
+	}
+
+	r.index = cfg
+	return nil
+}
+
+// EnsureVectorIndexes creates one partial index per embedding profile and
+// model and drops managed indexes no spec needs any more, such as those left
+// behind when a profile changes model or distance. Each index covers only its
+// profile's rows of one model, cast to the model's fixed dimensions, so
+// profiles of different sizes can share the unsized embedding column.
+//
+// Indexes are built concurrently so ingest and search keep running; until
+// an index is ready its profile is searched exactly. An interrupted build
+// leaves an invalid index, which is rebuilt on the next call.
+//
+// The indexes are managed here rather than in migrations because they
+// follow the configured profiles and build parameters, and CREATE INDEX
+// CONCURRENTLY cannot run in a migration's transaction. Replicas starting
+// together take turns under an advisory lock, so none drops an index
+// another is still building.
+func (r *ChunksRepository) EnsureVectorIndexes(ctx context.Context, specs []VectorIndexSpec) error {
+	conn, err := r.db.Acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire connection: %w", err)
+	}
+	defer conn.Release()
+	if err := lockVectorIndexes(ctx, conn); err != nil {
+		return err
+	}
+	defer func() {
+		// Unlock on a fresh context, since ctx may be what ended the work.
+		if _, err := conn.Exec(context.Background(), `SELECT pg_advisory_unlock($1)`, vectorIndexLockKey); err != nil {
+			slog.Warn("unlock vector indexes", "err", err)
+		}
+	}()
+
+	want := make(map[string]VectorIndexSpec, len(specs))
+	if r.index.Method == VectorIndexHNSW || r.index.Method == VectorIndexIVFFlat {
+		for _, spec := range specs {
+			if spec.Dimensions <= 0 || spec.Dimensions > maxIndexedDimensions {
+				slog.Warn("embedding profile is searched without an index",
+					"profile", spec.Profile, "dimensions", spec.Dimensions, "max_indexed_dimensions", maxIndexedDimensions)
+				continue
+			}
+			want[r.vectorIndexName(spec)] = spec
+		}
+	}
+
+	// Builds still in progress are invalid too; they are left alone in case
+	// a replica without the lock, e.g. an older version, is running them.
+	rows, err := conn.Query(ctx, `
+		SELECT c.relname, i.indisvalid OR EXISTS (
+			SELECT 1 FROM pg_stat_progress_create_index p WHERE p.index_relid = i.indexrelid)
+		FROM pg_index i
+		JOIN pg_class c ON c.oid = i.indexrelid
+		WHERE i.indrelid = 'chunks'::regclass AND c.relname LIKE $1`, vectorIndexPrefix+"%")
+	if err != nil {
+		return fmt.Errorf("list vector indexes: %w", err)
+	}
+	existing := make(map[string]bool)
+	for rows.Next() {
+		var (
+			name  string
+			valid bool
+		)
+		if err := rows.Scan(&name, &valid); err != nil {
+			rows.Close()
+			return fmt.Errorf("scan vector index: %w", err)
+		}
+		existing[name] = valid
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("list vector indexes: %w", err)
+	}
+
+	for name, valid := range existing {
+		if _, ok := want[name]; ok && valid {
+			continue
+		}
+		if _, err := conn.Exec(ctx, `DROP INDEX CONCURRENTLY IF EXISTS `+pgx.Identifier{name}.Sanitize()); err != nil {
+			return fmt.Errorf("drop vector index %s: %w", name, err)
+		}
+		delete(existing, name)
+	}
+
+	for name, spec := range want {
+		if _, ok := existing[name]; ok {
+			continue
+		}
+		slog.Info("building vector index", "index", name, "profile", spec.Profile, "model", spec.Model,
+			"method", r.index.Method, "dimensions", spec.Dimensions, "distance", spec.Distance)
+		if _, err := conn.Exec(ctx, r.vectorIndexDDL(name, spec)); err != nil {
+			return fmt.Errorf("create vector index for profile %q: %w", spec.Profile, err)
+		}
+	}
+	return nil
+}
+
+// lockVectorIndexes takes the vector index advisory lock for conn's session,
+// waiting while another replica holds it. It polls rather than blocking in
+// pg_advisory_lock: a blocked call keeps a snapshot open, which the other
+// replica's CREATE INDEX CONCURRENTLY would wait for.
+func lockVectorIndexes(ctx context.Context, conn *pgxpool.Conn) error {
+	for {
+		var locked bool
+		if err := conn.QueryRow(ctx, `SELECT pg_try_advisory_lock($1)`, vectorIndexLockKey).Scan(&locked); err != nil {
+			return fmt.Errorf("lock vector indexes: %w", err)
+		}
+		if locked {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(vectorIndexLockRetry):
+		}
+	}
+}
+
+// vectorIndexName derives a stable index name from everything that shapes
+// the index, so a changed setting yields a new index rather than a stale one.
+func (r *ChunksRepository) vectorIndexName(spec VectorIndexSpec) string {
+	key := fmt.Sprintf("%s\x00%s\x00%d\x00%s\x00%s\x00%s",
+		spec.Profile, spec.Model, spec.Dimensions, distanceOrDefault(spec.Distance), r.index.Method, r.vectorIndexOptions())
+	sum := sha256.Sum256([]byte(key))
+	return vectorIndexPrefix + hex.EncodeToString(sum[:8])
+}
+
+func (r *ChunksRepository) vectorIndexDDL(name string, spec VectorIndexSpec) string {
+	ddl := fmt.Sprintf(`CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON chunks USING %s ((embedding::vector(%d)) %s)`,
+		pgx.Identifier{name}.Sanitize(), r.index.Method, spec.Dimensions, distanceOpClass(spec.Distance))
+	if opts := r.vectorIndexOptions(); opts != "" {
+		ddl += " WITH (" + opts + ")"
+	}
+	return ddl + " WHERE " + profileVectorFilter("", spec.Profile, spec.Model, spec.Dimensions)
+}
+
+func (r *ChunksRepository) vectorIndexOptions() string {
+	var opts []string
+	switch r.index.Method {
+	case VectorIndexHNSW:
+		if r.index.HNSWM > 0 {
+			opts = append(opts, "m = "+strconv.Itoa(r.index.HNSWM))
+		}
+		if r.index.HNSWEfConstruction > 0 {
+			opts = append(opts, "ef_construction = "+strconv.Itoa(r.index.HNSWEfConstruction))
+		}
+	case VectorIndexIVFFlat:
+		if r.index.IVFFlatLists > 0 {
+			opts = append(opts, "lists = "+strconv.Itoa(r.index.IVFFlatLists))
+		}
+	}
+	return strings.Join(opts, ", ")
+}
+
+// searchSettings returns the pgvector settings for a query that ranks up to
+// limit candidates per embedding space.
+func (r *ChunksRepository) searchSettings(limit int) [][2]string {
+	var settings [][2]string
+	switch r.index.Method {
+	case VectorIndexHNSW:
+		ef := min(max(r.index.EfSearch, limit), maxEfSearch)
+		settings = append(settings, [2]string{"hnsw.ef_search", strconv.Itoa(ef)})
+	case VectorIndexIVFFlat:
+		if r.index.Probes > 0 {
+			settings = append(settings, [2]string{"ivfflat.probes", strconv.Itoa(r.index.Probes)})
+		}
+	default:
+		return nil
+	}
+	if scan := r.index.IterativeScan; scan != "" {
+		settings = append(settings, [2]string{r.index.Method + ".iterative_scan", scan})
+	}
+	return settings
+}
+
+// queryVectors runs a vector search with the configured pgvector settings
+// applied for that query only, passing the rows to scan.
+func (r *ChunksRepository) queryVectors(ctx context.Context, limit int, query string, args []any, scan func(pgx.Rows) error) error {
+	settings := r.searchSettings(limit)
+	if len(settings) == 0 {
+		rows, err := r.db.Query(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+		if err := scan(rows); err != nil {
+			return err
+		}
+		return rows.Err()
+	}
+
+	tx, err := r.db.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly})
+	if err != nil {
+		return fmt.Errorf("begin tx: %w", err)
+	}
+	defer tx.Rollback(ctx)
+
+	exprs := make([]string, len(settings))
+	setArgs := make([]any, 0, 2*len(settings))
+	for i, s := range settings {
+		exprs[i] = fmt.Sprintf("set_config($%d, $%d, true)", 2*i+1, 2*i+2)
+		setArgs = append(setArgs, s[0], s[1])
+	}
+	if _, err := tx.Exec(ctx, "SELECT "+strings.Join(exprs, ", "), setArgs...); err != nil {
+		return fmt.Errorf("apply vector search settings: %w", err)
+	}
+
+	rows, err := tx.Query(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	if err := scan(rows); err != nil {
+		rows.Close()
+		return err
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// profileVectorFilter restricts alias's chunks to one profile's vectors from
+// model, of the given size. The values are written as literals so the
+// planner can match the partial index built with the same predicate.
+func profileVectorFilter(alias, profile, model string, dims int) string {
+	if alias != "" {
+		alias += "."
+	}
+	return fmt.Sprintf("%sembedding_profile = %s AND %sembedding_model = %s AND vector_dims(%sembedding) = %d",
+		alias, quoteLiteral(profile), alias, quoteLiteral(model), alias, dims)
+}
+
+// vectorDistanceExpr compares alias's embedding with the query vector in
+// placeholder, using the same expression as the profile's index.
+func vectorDistanceExpr(alias string, distance domain.VectorDistance, dims int, placeholder string) string {
+	return fmt.Sprintf("%s.embedding::vector(%d) %s %s::vector(%d)",
+		alias, dims, distanceOperator(distance), placeholder, dims)
+}
+
+func distanceOrDefault(d domain.VectorDistance) domain.VectorDistance {
+	if d == "" {
+		return domain.VectorDistanceL2
+	}
+	return d
+}
+
+func distanceOperator(d domain.VectorDistance) string {
+	switch d {
+	case domain.VectorDistanceCosine:
+		return "<=>"
+	case domain.VectorDistanceInnerProduct:
+		return "<#>"
+	default:
+		return "<->"
+	}
+}
+
+func distanceOpClass(d domain.VectorDistance) string {
+	switch d {
+	case domain.VectorDistanceCosine:
+		return "vector_cosine_ops"
+	case domain.VectorDistanceInnerProduct:
+		return "vector_ip_ops"
+	default:
+		return "vector_l2_ops"
+	}
+}
+
+// quoteLiteral quotes s as an SQL string literal.
+func quoteLiteral(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// pgvectorAtLeast reports whether an extension version such as "0.8.0" is at
+// least major.minor.
+func pgvectorAtLeast(version string, major, minor int) bool {
+	parts := strings.SplitN(version, ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+	gotMajor, err1 := strconv.Atoi(parts[0])
+	gotMinor, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	return gotMajor > major || (gotMajor == major && gotMinor >= minor)
+}
@@ -0,0 +1,302 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package postgres
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+func TestVectorIndexDDL(t *testing.T) {
+	r := &ChunksRepository{index: VectorIndexConfig{Method: VectorIndexHNSW, HNSWM: 16, HNSWEfConstruction: 64}}
+	spec := VectorIndexSpec{Profile: "o'mail", Model: "ollama/nomic-embed-text/768", Dimensions: 768, Distance: domain.VectorDistanceCosine}
+
+	name := r.vectorIndexName(spec)
+	if !strings.HasPrefix(name, vectorIndexPrefix) || len(name) > 63 {
+		t.Fatalf("unexpected index name %q", name)
+	}
+	want := `CREATE INDEX CONCURRENTLY IF NOT EXISTS "` + name + `" ON chunks USING hnsw ((embedding::vector(768)) vector_cosine_ops)` +
+		` WITH (m = 16, ef_construction = 64) WHERE embedding_profile = 'o''mail' AND embedding_model = 'ollama/nomic-embed-text/768'` +
+		` AND vector_dims(embedding) = 768`
+	if got := r.vectorIndexDDL(name, spec); got != want {
+		t.Fatalf("ddl:\n%s\nwant:\n%s", got, want)
+	}
+
+	// The query must repeat the index expression and predicate for the
+	// planner to use the partial index.
+	filter := profileVectorFilter("c", spec.Profile, spec.Model, spec.Dimensions)
+	if !strings.Contains(want, strings.ReplaceAll(filter, "c.", "")) {
+		t.Fatalf("query filter %q does not match index predicate", filter)
+	}
+	if got := vectorDistanceExpr("c", spec.Distance, 768, "$3"); got != "c.embedding::vector(768) <=> $3::vector(768)" {
+		t.Fatalf("distance expr = %q", got)
+	}
+
+	changed := spec
+	changed.Distance = domain.VectorDistanceInnerProduct
+	if r.vectorIndexName(changed) == name {
+		t.Fatal("expected a new index name when the distance changes")
+	}
+	changed = spec
+	changed.Model = "openai/text-embedding-3-small/768"
+	if r.vectorIndexName(changed) == name {
+		t.Fatal("expected a new index name when the model changes")
+	}
+	r.index.HNSWM = 32
+	if r.vectorIndexName(spec) == name {
+		t.Fatal("expected a new index name when build parameters change")
+	}
+}
+
+func TestVectorSearchSettings(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   VectorIndexConfig
+		limit int
+		want  [][2]string
+	}{
+		{name: "no index", cfg: VectorIndexConfig{Method: VectorIndexNone, EfSearch: 100}, limit: 50, want: nil},
+		{
+			name:  "hnsw ef_search",
+			cfg:   VectorIndexConfig{Method: VectorIndexHNSW, EfSearch: 100, IterativeScan: "relaxed_order"},
+			limit: 25,
+			want:  [][2]string{{"hnsw.ef_search", "100"}, {"hnsw.iterative_scan", "relaxed_order"}},
+		},
+		{
+			name:  "ef_search raised to limit",
+			cfg:   VectorIndexConfig{Method: VectorIndexHNSW, EfSearch: 40},
+			limit: 500,
+			want:  [][2]string{{"hnsw.ef_search", "500"}},
+		},
+		{
+			name:  "ef_search capped",
+			cfg:   VectorIndexConfig{Method: VectorIndexHNSW},
+			limit: 5000,
+			want:  [][2]string{{"hnsw.ef_search", "1000"}},
+		},
+		{
+			name:  "ivfflat probes",
+			cfg:   VectorIndexConfig{Method: VectorIndexIVFFlat, Probes: 10},
+			limit: 50,
+			want:  [][2]string{{"ivfflat.probes", "10"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &ChunksRepository{index: tc.cfg}
+			if got := r.searchSettings(tc.limit); !slices.Equal(got, tc.want) {
+				t.Fatalf("settings = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPGVectorAtLeast(t *testing.T) {
+	for version, want := range map[string]bool{"0.8.0": true, "0.10.1": true, "1.0": true, "0.7.4": false, "dev": false} {
+		if got := pgvectorAtLeast(version, 0, 8); got != want {
+			t.Errorf("pgvectorAtLeast(%q) = %v, want %v", version, got, want)
+		}
+	}
+}
+
+// BenchmarkVectorSearchRecall compares exact search with HNSW search at
+// several ef_search values, reporting recall@10 against brute force next to
+// the usual latency. It runs against EMBEDDER_TEST_DB_URL, in a scratch
+// schema that is dropped afterwards, and is skipped when that is unset.
+func BenchmarkVectorSearchRecall(b *testing.B) {
+	const (
+		rows    = 20000
+		dims    = 128
+		queries = 50
+		k       = 10
+		profile = "bench"
+		model   = "bench/random/128"
+	)
+	url := os.Getenv("EMBEDDER_TEST_DB_URL")
+	if url == "" {
+		b.Skip("EMBEDDER_TEST_DB_URL is not set")
+	}
+	ctx := context.Background()
+	pool, domainID := benchmarkSchema(ctx, b, url)
+
+	rng := rand.New(rand.NewPCG(1, 2))
+	vectors := make([][]float32, rows)
+	for i := range vectors {
+		vectors[i] = randomUnitVector(rng, dims)
+	}
+	queryVecs := make([][]float32, queries)
+	truth := make([][]int, queries)
+	for i := range queryVecs {
+		queryVecs[i] = randomUnitVector(rng, dims)
+		truth[i] = nearest(vectors, queryVecs[i], k)
+	}
+
+	var recordID string
+	if err := pool.QueryRow(ctx, `
+		WITH s AS (
+			INSERT INTO sources (domain_id, user_id, source_type, name) VALUES ($1, 'bench', 'local', 'bench') RETURNING id
+		)
+		INSERT INTO records (domain_id, user_id, source_id, name, format, status)
+		SELECT $1, 'bench', s.id, 'bench', 'text', 'indexed' FROM s RETURNING id`, domainID,
+	).Scan(&recordID); err != nil {
+		b.Fatalf("insert record: %v", err)
+	}
+	for start := 0; start < rows; start += 1000 {
+		end := min(start+1000, rows)
+		literals := make([]string, 0, end-start)
+		indexes := make([]int32, 0, end-start)
+		for i := start; i < end; i++ {
+			literals = append(literals, float32SliceToPGVector(vectors[i]))
+			indexes = append(indexes, int32(i))
+		}
+		if _, err := pool.Exec(ctx, `
+			INSERT INTO chunks (domain_id, user_id, record_id, content, embedding, chunk_index, embedding_profile, embedding_model)
+			SELECT $1, 'bench', $2, '', t.e::vector, t.i, $3, $4
+			FROM unnest($5::text[], $6::int[]) AS t(e, i)`,
+			domainID, recordID, profile, model, literals, indexes,
+		); err != nil {
+			b.Fatalf("insert chunks: %v", err)
+		}
+	}
+	if _, err := pool.Exec(ctx, `ANALYZE chunks`); err != nil {
+		b.Fatalf("analyze: %v", err)
+	}
+
+	repo := NewChunksRepository(pool)
+	run := func(name string, cfg VectorIndexConfig) {
+		if err := repo.ConfigureVectorIndex(ctx, cfg); err != nil {
+			b.Fatalf("configure vector index: %v", err)
+		}
+		b.Run(name, func(b *testing.B) {
+			var hits, total int
+			for i := 0; b.Loop(); i++ {
+				q := i % queries
+				results, err := repo.SearchChunks(ctx, domainID, ProfileVector{
+					Profiles: []string{profile},
+					Model:    model,
+					Distance: domain.VectorDistanceCosine,
+					Vector:   queryVecs[q],
+				}, k, nil)
+				if err != nil {
+					b.Fatalf("search: %v", err)
+				}
+				for _, res := range results {
+					if slices.Contains(truth[q], res.ChunkIndex) {
+						hits++
+					}
+				}
+				total += k
+			}
+			b.ReportMetric(float64(hits)/float64(total), "recall@10")
+		})
+	}
+
+	run("exact", VectorIndexConfig{Method: VectorIndexNone})
+
+	hnsw := VectorIndexConfig{Method: VectorIndexHNSW, HNSWM: 16, HNSWEfConstruction: 64}
+	if err := repo.ConfigureVectorIndex(ctx, hnsw); err != nil {
+		b.Fatalf("configure vector index: %v", err)
+	}
+	started := time.Now()
+	if err := repo.EnsureVectorIndexes(ctx, []VectorIndexSpec{{Profile: profile, Model: model, Dimensions: dims, Distance: domain.VectorDistanceCosine}}); err != nil {
+		b.Fatalf("build index: %v", err)
+	}
+	b.Logf("built hnsw index over %d rows in %s", rows, time.Since(started))
+
+	for _, ef := range []int{10, 40, 100, 200} {
+		cfg := hnsw
+		cfg.EfSearch = ef
+		run(fmt.Sprintf("hnsw/ef_search=%d", ef), cfg)
+	}
+}
+
+// benchmarkSchema migrates a fresh schema in the database at url and returns
+// a pool bound to it. The schema is dropped when the benchmark ends.
+func benchmarkSchema(ctx context.Context, b *testing.B, url string) (*pgxpool.Pool, string) {
+	b.Helper()
+	schema := fmt.Sprintf("bench_%d", time.Now().UnixNano())
+
+	admin, err := pgxpool.New(ctx, url)
+	if err != nil {
+		b.Fatalf("connect: %v", err)
+	}
+	b.Cleanup(admin.Close)
+	if _, err := admin.Exec(ctx, `CREATE SCHEMA `+schema); err != nil {
+		b.Fatalf("create schema: %v", err)
+	}
+	b.Cleanup(func() {
+		_, _ = admin.Exec(context.Background(), `DROP SCHEMA `+schema+` CASCADE`)
+	})
+
+	cfg, err := pgxpool.ParseConfig(url)
+	if err != nil {
+		b.Fatalf("parse url: %v", err)
+	}
+	cfg.ConnConfig.RuntimeParams["search_path"] = schema + ",public"
+	pool, err := pgxpool.NewWithConfig(ctx, cfg)
+	if err != nil {
+		b.Fatalf("connect: %v", err)
+	}
+	b.Cleanup(pool.Close)
+	if err := Migrate(ctx, pool); err != nil {
+		b.Fatalf("migrate: %v", err)
+	}
+	return pool, schema
+}
+
+func randomUnitVector(rng *rand.Rand, dims int) []float32 {
+	v := make([]float32, dims)
+	var norm float64
+	for i := range v {
+		x := rng.NormFloat64()
+		v[i] = float32(x)
+		norm += x * x
+	}
+	norm = math.Sqrt(norm)
+	for i := range v {
+		v[i] = float32(float64(v[i]) / norm)
+	}
+	return v
+}
+
+// nearest returns the indexes of the k vectors closest to q by cosine
+// distance, found by brute force.
+func nearest(vectors [][]float32, q []float32, k int) []int {
+	type scored struct {
+		index int
+		dot   float64
+	}
+	all := make([]scored, len(vectors))
+	for i, v := range vectors {
+		var dot float64
+		for j := range v {
+			dot += float64(v[j]) * float64(q[j])
+		}
+		all[i] = scored{index: i, dot: dot}
+	}
+	slices.SortFunc(all, func(a, b scored) int {
+		switch {
+		case a.dot > b.dot:
+			return -1
+		case a.dot < b.dot:
+			return 1
+		}
+		return 0
+	})
+	out := make([]int, k)
+	for i := range out {
+		out[i] = all[i].index
+	}
+	return out
+}
@@ -0,0 +1,329 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvelopeKey is the JSON object key that carries the wrapped data key of an
+// envelope-encrypted document.
+const EnvelopeKey = "_envelope"
+
+const (
+	// encryptedPrefix marks a field value sealed under the document's data
+	// key and bound to the field name and the document's owner.
+	encryptedPrefix = "enc:v2:"
+	// legacyEncryptedPrefix marks a field value bound only to the field
+	// name. Such values are opened but never written.
+	legacyEncryptedPrefix = "enc:v1:"
+	// valueField is the only field of documents sealed by SealValue.
+	valueField = "value"
+)
+
+var (
+	// ErrUnknownKEK is returned when a document was sealed under a key
+	// encryption key that is not configured.
+	ErrUnknownKEK = errors.New("unknown key encryption key")
+	// ErrMalformedEnvelope is returned when an encrypted document cannot be parsed.
+	ErrMalformedEnvelope = errors.New("malformed envelope")
+)
+
+// KEK is a named key-encryption key. Any Sealer works, so a key sealed to the
+// TEE can be used in place of the local file key.
+type KEK struct {
+	ID     string
+	Sealer Sealer
+}
+
+// LoadKEKFile reads a 32-byte key from path, either raw or base64-encoded.
+// The key ID is derived from the key so rotated files are told apart.
+func LoadKEKFile(path string) (KEK, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return KEK{}, fmt.Errorf("read kek file: %w", err)
+	}
+	key := data
+	if len(key) != 32 {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return KEK{}, fmt.Errorf("kek file %s is neither 32 raw bytes nor base64: %w", path, err)
+		}
+		key = decoded
+	}
+	sealer, err := NewAESGCM(key)
+	if err != nil {
+		return KEK{}, err
+	}
+	sum := sha256.Sum256(key)
+	return KEK{ID: hex.EncodeToString(sum[:8]), Sealer: sealer}, nil
+}
+
+// Envelope encrypts selected fields of a JSON object with a fresh data key
+// per document. The data key is wrapped by the current KEK and stored next to
+// the fields; previous KEKs are kept so older documents can still be opened
+// until they are re-encrypted.
+type Envelope struct {
+	current KEK
+	keks    map[string]KEK
+}
+
+type envelopeHeader struct {
+	KEK string `json:"kek"`
+	Key string `json:"key"`
+}
+
+// NewEnvelope returns an envelope that seals with current and opens documents
+// sealed under current or any of previous.
+func NewEnvelope(current KEK, previous ...KEK) (*Envelope, error) {
+	e := &Envelope{current: current, keks: make(map[string]KEK, len(previous)+1)}
+	for _, k := range append([]KEK{current}, previous...) {
+		if k.ID == "" || k.Sealer == nil {
+			return nil, errors.New("kek id and sealer are required")
+		}
+		if _, ok := e.keks[k.ID]; ok {
+			return nil, fmt.Errorf("duplicate kek %q", k.ID)
+		}
+		e.keks[k.ID] = k
+	}
+	return e, nil
+}
+
+// CurrentKEK returns the ID of the KEK new documents are sealed under.
+func (e *Envelope) CurrentKEK() string {
+	return e.current.ID
+}
+
+// SealFields encrypts the non-empty string values of fields in raw. Each value
+// is bound to its field name and to owner, the ID of the row the document is
+// stored in, so ciphertexts cannot be moved between fields or rows. Documents
+// that are already sealed are opened first, so sealing is also how they are
+// moved to the current KEK. A document without any of the fields is returned
+// unchanged.
+func (e *Envelope) SealFields(raw json.RawMessage, fields []string, owner string) (json.RawMessage, error) {
+	doc, err := e.open(raw, owner)
+	if err != nil {
+		return nil, err
+	}
+	if doc == nil {
+		return raw, nil
+	}
+
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, fmt.Errorf("generate data key: %w", err)
+	}
+	aead, err := newFieldAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	sealed := false
+	for _, field := range fields {
+		value, ok := doc[field]
+		if !ok {
+			continue
+		}
+		var plaintext string
+		if err := json.Unmarshal(value, &plaintext); err != nil || plaintext == "" {
+			continue
+		}
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, fmt.Errorf("generate nonce: %w", err)
+		}
+		ciphertext := aead.Seal(nonce, nonce, []byte(plaintext), fieldAAD(field, owner))
+		encoded, err := json.Marshal(encryptedPrefix + base64.StdEncoding.EncodeToString(ciphertext))
+		if err != nil {
+			return nil, err
+		}
+		doc[field] = encoded
+		sealed = true
+	}
+	if !sealed {
+		return marshalDocument(doc)
+	}
+
+	wrapped, err := e.current.Sealer.Seal(dek)
+	if err != nil {
+		return nil, fmt.Errorf("wrap data key: %w", err)
+	}
+	header, err := json.Marshal(envelopeHeader{
+		KEK: e.current.ID,
+		Key: base64.StdEncoding.EncodeToString(wrapped),
+	})
+	if err != nil {
+		return nil, err
+	}
+	doc[EnvelopeKey] = header
+	return marshalDocument(doc)
+}
+
+// OpenFields decrypts every sealed field in raw, which must have been sealed
+// for owner, and drops the envelope header. Documents without a header are
+// returned unchanged.
+func (e *Envelope) OpenFields(raw json.RawMessage, owner string) (json.RawMessage, error) {
+	if !IsSealed(raw) {
+		return raw, nil
+	}
+	doc, err := e.open(raw, owner)
+	if err != nil {
+		return nil, err
+	}
+	return marshalDocument(doc)
+}
+
+// SealValue encrypts a single secret, such as an API key, for owner. The
+// result is an envelope document that OpenValue reads back.
+func (e *Envelope) SealValue(plaintext, owner string) ([]byte, error) {
+	if plaintext == "" {
+		return nil, errors.New("secret is empty")
+	}
+	doc, err := json.Marshal(map[string]string{valueField: plaintext})
+	if err != nil {
+		return nil, err
+	}
+	return e.SealFields(doc, []string{valueField}, owner)
+}
+
+// OpenValue decrypts a secret sealed by SealValue for owner.
+func (e *Envelope) OpenValue(sealed []byte, owner string) (string, error) {
+	if !IsSealed(sealed) {
+		return "", fmt.Errorf("%w: missing header", ErrMalformedEnvelope)
+	}
+	opened, err := e.OpenFields(sealed, owner)
+	if err != nil {
+		return "", err
+	}
+	var doc map[string]string
+	if err := json.Unmarshal(opened, &doc); err != nil {
+		return "", fmt.Errorf("%w: %v", ErrMalformedEnvelope, err)
+	}
+	return doc[valueField], nil
+}
+
+// NeedsRotation reports whether raw is sealed under a KEK other than the
+// current one, or holds values not yet bound to their owner.
+func (e *Envelope) NeedsRotation(raw json.RawMessage) bool {
+	kek, ok := SealedWith(raw)
+	return ok && (kek != e.current.ID || bytes.Contains(raw, []byte(legacyEncryptedPrefix)))
+}
+
+// IsSealed reports whether raw carries an envelope header.
+func IsSealed(raw json.RawMessage) bool {
+	_, ok := SealedWith(raw)
+	return ok
+}
+
+// SealedWith returns the ID of the KEK that sealed raw.
+func SealedWith(raw json.RawMessage) (string, bool) {
+	if !bytes.Contains(raw, []byte(EnvelopeKey)) {
+		return "", false
+	}
+	var doc struct {
+		Envelope *envelopeHeader `json:"_envelope"`
+	}
+	if err := json.Unmarshal(raw, &doc); err != nil || doc.Envelope == nil {
+		return "", false
+	}
+	return doc.Envelope.KEK, true
+}
+
+// open parses raw and decrypts sealed fields in place. It returns nil for
+// documents that are not JSON objects.
+func (e *Envelope) open(raw json.RawMessage, owner string) (map[string]json.RawMessage, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &doc); err != nil || doc == nil {
+		return nil, nil
+	}
+	rawHeader, ok := doc[EnvelopeKey]
+	if !ok {
+		return doc, nil
+	}
+	delete(doc, EnvelopeKey)
+
+	var header envelopeHeader
+	if err := json.Unmarshal(rawHeader, &header); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEnvelope, err)
+	}
+	kek, ok := e.keks[header.KEK]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownKEK, header.KEK)
+	}
+	wrapped, err := base64.StdEncoding.DecodeString(header.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedEnvelope, err)
+	}
+	dek, err := kek.Sealer.Open(wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrap data key: %w", err)
+	}
+	aead, err := newFieldAEAD(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	for field, value := range doc {
+		var encoded string
+		if err := json.Unmarshal(value, &encoded); err != nil {
+			continue
+		}
+		var aad []byte
+		switch {
+		case strings.HasPrefix(encoded, encryptedPrefix):
+			encoded = strings.TrimPrefix(encoded, encryptedPrefix)
+			aad = fieldAAD(field, owner)
+		case strings.HasPrefix(encoded, legacyEncryptedPrefix):
+			encoded = strings.TrimPrefix(encoded, legacyEncryptedPrefix)
+			aad = []byte(field)
+		default:
+			continue
+		}
+		ciphertext, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(ciphertext) < aead.NonceSize() {
+			return nil, fmt.Errorf("%w: field %q", ErrMalformedEnvelope, field)
+		}
+		n := aead.NonceSize()
+		plaintext, err := aead.Open(nil, ciphertext[:n], ciphertext[n:], aad)
+		if err != nil {
+			return nil, fmt.Errorf("decrypt field %q: %w", field, err)
+		}
+		if doc[field], err = json.Marshal(string(plaintext)); err != nil {
+			return nil, err
+		}
+	}
+	return doc, nil
+}
+
+// fieldAAD binds a sealed value to its field and owner.
+func fieldAAD(field, owner string) []byte {
+	return []byte(field + "\x00" + owner)
+}
+
+func newFieldAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func marshalDocument(doc map[string]json.RawMessage) (json.RawMessage, error) {
+	out, err := json.Marshal(doc)
+	if err != nil {
+		return nil, fmt.Errorf("encode document: %w", err)
+	}
+	return out, nil
+}
@@ -0,0 +1,218 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testKEK(t *testing.T, b byte) KEK {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "kek")
+	if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{b}, 32))+"\n"), 0o600); err != nil {
+		t.Fatalf("write kek: %v", err)
+	}
+	kek, err := LoadKEKFile(path)
+	if err != nil {
+		t.Fatalf("load kek: %v", err)
+	}
+	return kek
+}
+
+func TestEnvelopeSealsOnlyCredentialFields(t *testing.T) {
+	env, err := NewEnvelope(testKEK(t, 1))
+	if err != nil {
+		t.Fatalf("new envelope: %v", err)
+	}
+
+	raw := json.RawMessage(`{"bucket":"docs","secret_access_key":"s3cr3t","session_token":""}`)
+	sealed, err := env.SealFields(raw, []string{"secret_access_key", "session_token"}, "src-1")
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("s3cr3t")) {
+		t.Fatal("sealed config contains plaintext secret")
+	}
+	if !bytes.Contains(sealed, []byte(`"bucket":"docs"`)) {
+		t.Fatalf("non-credential field was altered: %s", sealed)
+	}
+	if kek, ok := SealedWith(sealed); !ok || kek != env.CurrentKEK() {
+		t.Fatalf("expected config sealed with %q, got %q", env.CurrentKEK(), kek)
+	}
+
+	opened, err := env.OpenFields(sealed, "src-1")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	var got map[string]string
+	if err := json.Unmarshal(opened, &got); err != nil {
+		t.Fatalf("decode opened config: %v", err)
+	}
+	if got["secret_access_key"] != "s3cr3t" || got["bucket"] != "docs" {
+		t.Fatalf("unexpected opened config: %v", got)
+	}
+	if _, ok := got[EnvelopeKey]; ok {
+		t.Fatal("opened config still carries the envelope header")
+	}
+}
+
+func TestEnvelopeRejectsSwappedFields(t *testing.T) {
+	env, err := NewEnvelope(testKEK(t, 1))
+	if err != nil {
+		t.Fatalf("new envelope: %v", err)
+	}
+	sealed, err := env.SealFields(json.RawMessage(`{"access_token":"a","refresh_token":"r"}`), []string{"access_token", "refresh_token"}, "src-1")
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(sealed, &doc); err != nil {
+		t.Fatalf("decode sealed config: %v", err)
+	}
+	doc["access_token"], doc["refresh_token"] = doc["refresh_token"], doc["access_token"]
+	swapped, _ := json.Marshal(doc)
+	if _, err := env.OpenFields(swapped, "src-1"); err == nil {
+		t.Fatal("expected swapped ciphertexts to fail authentication")
+	}
+}
+
+func TestEnvelopeBindsValuesToTheirOwner(t *testing.T) {
+	env, err := NewEnvelope(testKEK(t, 1))
+	if err != nil {
+		t.Fatalf("new envelope: %v", err)
+	}
+	sealed, err := env.SealValue("sk-secret", "provider-1")
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("sk-secret")) {
+		t.Fatal("sealed value contains plaintext")
+	}
+	got, err := env.OpenValue(sealed, "provider-1")
+	if err != nil || got != "sk-secret" {
+		t.Fatalf("OpenValue = %q, %v", got, err)
+	}
+	if _, err := env.OpenValue(sealed, "provider-2"); err == nil {
+		t.Fatal("expected a value copied to another owner to fail authentication")
+	}
+	if _, err := env.OpenValue([]byte("raw"), "provider-1"); !errors.Is(err, ErrMalformedEnvelope) {
+		t.Fatalf("expected ErrMalformedEnvelope, got %v", err)
+	}
+}
+
+func TestEnvelopeOpensAndRotatesLegacyValues(t *testing.T) {
+	kek := testKEK(t, 1)
+	env, err := NewEnvelope(kek)
+	if err != nil {
+		t.Fatalf("new envelope: %v", err)
+	}
+
+	// Seal a field the way it was sealed before values were bound to their
+	// owner.
+	dek := bytes.Repeat([]byte{9}, 32)
+	aead, err := newFieldAEAD(dek)
+	if err != nil {
+		t.Fatalf("aead: %v", err)
+	}
+	nonce := make([]byte, aead.NonceSize())
+	ciphertext := aead.Seal(nonce, nonce, []byte("tok"), []byte("access_token"))
+	wrapped, err := kek.Sealer.Seal(dek)
+	if err != nil {
+		t.Fatalf("wrap: %v", err)
+	}
+	header, _ := json.Marshal(envelopeHeader{KEK: kek.ID, Key: base64.StdEncoding.EncodeToString(wrapped)})
+	value, _ := json.Marshal(legacyEncryptedPrefix + base64.StdEncoding.EncodeToString(ciphertext))
+	legacy, _ := json.Marshal(map[string]json.RawMessage{"access_token": value, EnvelopeKey: header})
+
+	if !env.NeedsRotation(legacy) {
+		t.Fatal("expected a legacy value to need rotation")
+	}
+	opened, err := env.OpenFields(legacy, "src-1")
+	if err != nil || !bytes.Contains(opened, []byte(`"access_token":"tok"`)) {
+		t.Fatalf("open legacy config = %s, %v", opened, err)
+	}
+	rotated, err := env.SealFields(legacy, []string{"access_token"}, "src-1")
+	if err != nil {
+		t.Fatalf("re-seal: %v", err)
+	}
+	if env.NeedsRotation(rotated) {
+		t.Fatal("expected re-sealed config to be bound to its owner")
+	}
+	if _, err := env.OpenFields(rotated, "src-2"); err == nil {
+		t.Fatal("expected a re-sealed config to be bound to its owner")
+	}
+}
+
+func TestEnvelopeRotation(t *testing.T) {
+	oldKEK := testKEK(t, 1)
+	newKEK := testKEK(t, 2)
+
+	oldEnv, err := NewEnvelope(oldKEK)
+	if err != nil {
+		t.Fatalf("new envelope: %v", err)
+	}
+	sealed, err := oldEnv.SealFields(json.RawMessage(`{"client_secret":"cs"}`), []string{"client_secret"}, "src-1")
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+
+	onlyNew, err := NewEnvelope(newKEK)
+	if err != nil {
+		t.Fatalf("new envelope: %v", err)
+	}
+	if _, err := onlyNew.OpenFields(sealed, "src-1"); !errors.Is(err, ErrUnknownKEK) {
+		t.Fatalf("expected ErrUnknownKEK, got %v", err)
+	}
+
+	rotating, err := NewEnvelope(newKEK, oldKEK)
+	if err != nil {
+		t.Fatalf("new envelope: %v", err)
+	}
+	if !rotating.NeedsRotation(sealed) {
+		t.Fatal("expected config sealed with the old kek to need rotation")
+	}
+	rotated, err := rotating.SealFields(sealed, []string{"client_secret"}, "src-1")
+	if err != nil {
+		t.Fatalf("re-seal: %v", err)
+	}
+	if rotating.NeedsRotation(rotated) {
+		t.Fatal("expected re-sealed config to use the current kek")
+	}
+	opened, err := onlyNew.OpenFields(rotated, "src-1")
+	if err != nil {
+		t.Fatalf("open rotated config: %v", err)
+	}
+	if !bytes.Contains(opened, []byte(`"client_secret":"cs"`)) {
+		t.Fatalf("unexpected rotated config: %s", opened)
+	}
+}
+
+func TestEnvelopePassesThroughPlainConfigs(t *testing.T) {
+	env, err := NewEnvelope(testKEK(t, 1))
+	if err != nil {
+		t.Fatalf("new envelope: %v", err)
+	}
+	raw := json.RawMessage(`{"folder_id":"root"}`)
+	opened, err := env.OpenFields(raw, "src-1")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if !bytes.Equal(opened, raw) {
+		t.Fatalf("plain config was altered: %s", opened)
+	}
+	sealed, err := env.SealFields(raw, []string{"access_token"}, "src-1")
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if IsSealed(sealed) {
+		t.Fatal("config without credentials should not carry an envelope")
+	}
+}
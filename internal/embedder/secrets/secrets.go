@@ -0,0 +1,65 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+// Package secrets encrypts credentials before they are persisted.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+)
+
+// ErrNotConfigured is returned when a secret must be sealed or opened but no
+// key encryption key was configured.
+var ErrNotConfigured = errors.New("secrets key is not configured")
+
+// Sealer encrypts and decrypts small secrets. Envelopes use it to wrap data
+// keys.
+type Sealer interface {
+	Seal(plaintext []byte) ([]byte, error)
+	Open(ciphertext []byte) ([]byte, error)
+}
+
+// AESGCM seals secrets with AES-256-GCM. Ciphertexts are nonce || sealed.
+type AESGCM struct {
+	aead cipher.AEAD
+}
+
+// NewAESGCM returns a sealer for a 32-byte key.
+func NewAESGCM(key []byte) (*AESGCM, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("secrets key must be 32 bytes, got %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &AESGCM{aead: aead}, nil
+}
+
+func (a *AESGCM) Seal(plaintext []byte) ([]byte, error) {
+	nonce := make([]byte, a.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("generate nonce: %w", err)
+	}
+	return a.aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+func (a *AESGCM) Open(ciphertext []byte) ([]byte, error) {
+	n := a.aead.NonceSize()
+	if len(ciphertext) < n {
+		return nil, errors.New("ciphertext too short")
+	}
+	plaintext, err := a.aead.Open(nil, ciphertext[:n], ciphertext[n:], nil)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt secret: %w", err)
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,42 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package secrets
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestAESGCMRoundTrip(t *testing.T) {
+	sealer, err := NewAESGCM(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatalf("new sealer: %v", err)
+	}
+
+	sealed, err := sealer.Seal([]byte("sk-secret"))
+	if err != nil {
+		t.Fatalf("seal: %v", err)
+	}
+	if bytes.Contains(sealed, []byte("sk-secret")) {
+		t.Fatal("ciphertext contains plaintext")
+	}
+	opened, err := sealer.Open(sealed)
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	if string(opened) != "sk-secret" {
+		t.Fatalf("opened = %q", opened)
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err := sealer.Open(sealed); err == nil {
+		t.Fatal("expected tampered ciphertext to fail")
+	}
+}
+
+func TestAESGCMRejectsShortKeys(t *testing.T) {
+	if _, err := NewAESGCM([]byte("short")); err == nil {
+		t.Fatal("expected short key to be rejected")
+	}
+}
@@ -23,6 +23,8 @@ type chatService struct {
 	defaultCfg    llm.Config
 	ollamaBaseURL string
 	factory       llm.ClientFactory // builds a client from a per-request config
+	models        domain.ModelProviderService
+	urlPolicy     domain.ModelURLPolicy
 }
 
 const ragSystemPrompt = `You are a retrieval-grounded assistant.
@@ -65,41 +67,24 @@ var conversationalMessages = map[string]struct{}{
 // streams the LLM response.  reranker may be nil to skip re-ranking.
 // factory is called to build a temporary client when the request overrides
 // the server-default model; it may be nil if per-request overrides are not needed.
-func NewChatService(retrieve domain.VectorRetrieveService, llmClient llm.Client, reranker llm.Reranker, topK int, defaultCfg llm.Config, ollamaBaseURL string, factory llm.ClientFactory) domain.ChatService {
+// models resolves catalogue references (ModelConfig.ProviderID) and may be nil;
+// urlPolicy restricts the base URLs ad-hoc overrides may point at.
+func NewChatService(retrieve domain.VectorRetrieveService, llmClient llm.Client, reranker llm.Reranker, topK int, defaultCfg llm.Config, ollamaBaseURL string, factory llm.ClientFactory, models domain.ModelProviderService, urlPolicy domain.ModelURLPolicy) domain.ChatService {
 	if topK <= 0 {
 		topK = 15
 	}
-	return &chatService{retrieve: retrieve, llm: llmClient, reranker: reranker, topK: topK, defaultCfg: defaultCfg, ollamaBaseURL: ollamaBaseURL, factory: factory}
+	if urlPolicy.OllamaBaseURL == "" {
+		urlPolicy.OllamaBaseURL = ollamaBaseURL
+	}
+	return &chatService{retrieve: retrieve, llm: llmClient, reranker: reranker, topK: topK, defaultCfg: defaultCfg, ollamaBaseURL: ollamaBaseURL, factory: factory, models: models, urlPolicy: urlPolicy}
 }
 
 func (s *chatService) Chat(ctx context.Context, domainID string, messages []domain.ChatMessage, recordIDs []string, modelCfg *domain.ModelConfig, debug bool) (<-chan domain.ChatEvent, error) {
-	// Build a per-request client if the caller overrides the model.
-	llmClient := s.llm
-	if modelCfg != nil && modelCfg.Model != "" && s.factory != nil {
-		baseURL := modelCfg.BaseURL
-		if baseURL == "" {
-			if strings.EqualFold(modelCfg.Provider, "ollama") || strings.EqualFold(modelCfg.Provider, "local") {
-				baseURL = s.ollamaBaseURL
-			} else {
-				baseURL = s.defaultCfg.BaseURL
-			}
-		}
-		provider := modelCfg.Provider
-		if provider == "" {
-			provider = s.defaultCfg.Provider
-		}
-		llmClient = s.factory(llm.Config{
-			Provider:    provider,
-			BaseURL:     baseURL,
-			Model:       modelCfg.Model,
-			APIKey:      modelCfg.APIKey,
-			Temperature: modelCfg.Temperature,
-			MaxTokens:   modelCfg.MaxTokens,
-		})
-		slog.Info("chat: using per-request model", "provider", modelCfg.Provider, "model", modelCfg.Model)
-	} else {
-		slog.Info("chat: using default model", "provider", s.defaultCfg.Provider, "model", s.defaultCfg.Model)
+	llmClient, served, err := s.clientFor(ctx, domainID, modelCfg)
+	if err != nil {
+		return nil, err
 	}
+
 	query := ""
 	for i := len(messages) - 1; i >= 0; i-- {
 		if messages[i].Role == "user" {
@@ -186,6 +171,16 @@ func (s *chatService) Chat(ctx context.Context, domainID string, messages []doma
 			ChunkIndex:  c.ChunkIndex,
 			Excerpt:     truncate(c.Content, 200),
 		})
+		// The same passage in other records is cited, not repeated.
+		for _, d := range c.Duplicates {
+			citations = append(citations, domain.Citation{
+				RecordID:    d.RecordID,
+				RecordName:  d.RecordName,
+				ExternalURL: d.ExternalURL,
+				ChunkIndex:  d.ChunkIndex,
+				Excerpt:     truncate(c.Content, 200),
+			})
+		}
 	}
 
 	// Assemble messages for the LLM.
@@ -247,16 +242,31 @@ func (s *chatService) Chat(ctx context.Context, domainID string, messages []doma
 			}
 		}
 
-		if debug {
+		// The debug event is held back until the model starts answering so it
+		// can report which provider served the request.
+		emitDebug := func(served *llm.Served) bool {
+			if !debug {
+				return true
+			}
 			debugData := buildChatDebug(query, s.topK, retrieveForQuery, skippedReason, recordIDs, chunks)
+			if served != nil {
+				debugData.Provider = served.Provider
+				debugData.Model = served.Model
+				debugData.Attempts = served.Attempts
+			}
+			debug = false
 			select {
 			case out <- domain.ChatEvent{Type: domain.ChatEventDebug, Debug: &debugData}:
+				return true
 			case <-ctx.Done():
-				return
+				return false
 			}
 		}
 
 		if noRelevantContext || weakContext {
+			if !emitDebug(nil) {
+				return
+			}
 			select {
 			case out <- domain.ChatEvent{Type: domain.ChatEventToken, Content: noRelevantContextResponse}:
 			case <-ctx.Done():
@@ -269,18 +279,34 @@ func (s *chatService) Chat(ctx context.Context, domainID string, messages []doma
 			return
 		}
 
+		streamCtx, report := llm.WithServedReport(ctx)
+		servedBy := func() *llm.Served {
+			if reported, ok := report.Get(); ok {
+				return &reported
+			}
+			return &served
+		}
+
 		tokenCh := make(chan string, 64)
 		errCh := make(chan error, 1)
 
 		go func() {
-			errCh <- llmClient.StreamChat(ctx, llmMessages, tokenCh)
+			errCh <- llmClient.StreamChat(streamCtx, llmMessages, tokenCh)
 		}()
 
 		for {
 			select {
 			case tok, ok := <-tokenCh:
 				if !ok {
-					if err := <-errCh; err != nil && ctx.Err() == nil {
+					err := <-errCh
+					if err == nil {
+						by := servedBy()
+						slog.Info("chat: answer served", "provider", by.Provider, "model", by.Model, "attempts", by.Attempts)
+					}
+					if !emitDebug(servedBy()) {
+						return
+					}
+					if err != nil && ctx.Err() == nil {
 						select {
 						case out <- domain.ChatEvent{Type: domain.ChatEventError, Error: err.Error()}:
 						case <-ctx.Done():
@@ -293,6 +319,9 @@ func (s *chatService) Chat(ctx context.Context, domainID string, messages []doma
 					}
 					return
 				}
+				if !emitDebug(servedBy()) {
+					return
+				}
 				select {
 				case out <- domain.ChatEvent{Type: domain.ChatEventToken, Content: tok}:
 				case <-ctx.Done():
@@ -307,6 +336,73 @@ func (s *chatService) Chat(ctx context.Context, domainID string, messages []doma
 	return out, nil
 }
 
+// clientFor returns the LLM client for a request: a catalogue provider when
+// modelCfg references one, an ad-hoc override when the caller supplies a
+// model, or the server default.
+func (s *chatService) clientFor(ctx context.Context, domainID string, modelCfg *domain.ModelConfig) (llm.Client, llm.Served, error) {
+	if modelCfg != nil && modelCfg.ProviderID != "" {
+		if s.models == nil || s.factory == nil {
+			return nil, llm.Served{}, fmt.Errorf("%w: model catalogue is not configured", domain.ErrModelNotAllowed)
+		}
+		p, err := s.models.Resolve(ctx, modelCfg.ProviderID, domainID)
+		if err != nil {
+			return nil, llm.Served{}, err
+		}
+		model := modelCfg.Model
+		if model == "" && len(p.Models) > 0 {
+			model = p.Models[0]
+		}
+		if !p.AllowsModel(model) {
+			return nil, llm.Served{}, fmt.Errorf("%w: %q is not offered by provider %q", domain.ErrModelNotAllowed, model, p.Name)
+		}
+		baseURL := p.BaseURL
+		if baseURL == "" && p.Provider == "ollama" {
+			baseURL = s.ollamaBaseURL
+		}
+		client := s.factory(llm.Config{
+			Provider:    p.Provider,
+			BaseURL:     baseURL,
+			Model:       model,
+			APIKey:      p.APIKey,
+			Temperature: modelCfg.Temperature,
+			MaxTokens:   modelCfg.MaxTokens,
+		})
+		slog.Info("chat: using catalogue model", "provider_id", p.ID, "provider", p.Name, "model", model)
+		return client, llm.Served{Provider: p.Name, Model: model, Attempts: 1}, nil
+	}
+
+	if modelCfg != nil && modelCfg.Model != "" && s.factory != nil {
+		if !s.urlPolicy.Allows(modelCfg.Provider, modelCfg.BaseURL) {
+			return nil, llm.Served{}, fmt.Errorf("%w: base_url is not allowed", domain.ErrModelNotAllowed)
+		}
+		baseURL := modelCfg.BaseURL
+		if baseURL == "" {
+			if strings.EqualFold(modelCfg.Provider, "ollama") || strings.EqualFold(modelCfg.Provider, "local") {
+				baseURL = s.ollamaBaseURL
+			} else {
+				baseURL = s.defaultCfg.BaseURL
+			}
+		}
+		provider := modelCfg.Provider
+		if provider == "" {
+			provider = s.defaultCfg.Provider
+		}
+		client := s.factory(llm.Config{
+			Provider:    provider,
+			BaseURL:     baseURL,
+			Model:       modelCfg.Model,
+			APIKey:      modelCfg.APIKey,
+			Temperature: modelCfg.Temperature,
+			MaxTokens:   modelCfg.MaxTokens,
+		})
+		slog.Info("chat: using per-request model", "provider", modelCfg.Provider, "model", modelCfg.Model)
+		return client, llm.Served{Provider: provider, Model: modelCfg.Model, Attempts: 1}, nil
+	}
+
+	slog.Info("chat: using default model", "provider", s.defaultCfg.Provider, "model", s.defaultCfg.Model)
+	return s.llm, llm.Served{Provider: s.defaultCfg.Provider, Model: s.defaultCfg.Model, Attempts: 1}, nil
+}
+
 func shouldRetrieve(query string) bool {
 	normalized := strings.ToLower(strings.TrimSpace(query))
 	normalized = strings.TrimFunc(normalized, func(r rune) bool {
@@ -61,7 +61,7 @@ func TestShouldRetrieveOnlySkipsClearConversationalMessages(t *testing.T) {
 func TestChatSkipsRetrievalForConversationalMessage(t *testing.T) {
 	retrieve := &chatRetrieveStub{}
 	client := &chatLLMStub{}
-	service := NewChatService(retrieve, client, nil, 8, llm.Config{}, "", nil)
+	service := NewChatService(retrieve, client, nil, 8, llm.Config{}, "", nil, nil, domain.ModelURLPolicy{})
 
 	events, err := service.Chat(context.Background(), "domain", []domain.ChatMessage{
 		{Role: "user", Content: "hello!"},
@@ -83,7 +83,7 @@ func TestChatSkipsRetrievalForConversationalMessage(t *testing.T) {
 func TestChatDoesNotCallLLMWhenRetrievalFindsNoChunks(t *testing.T) {
 	retrieve := &chatRetrieveStub{}
 	client := &chatLLMStub{}
-	service := NewChatService(retrieve, client, nil, 8, llm.Config{}, "", nil)
+	service := NewChatService(retrieve, client, nil, 8, llm.Config{}, "", nil, nil, domain.ModelURLPolicy{})
 
 	events, err := service.Chat(context.Background(), "domain", []domain.ChatMessage{
 		{Role: "user", Content: "record details"},
@@ -131,7 +131,7 @@ func TestChatDoesNotCallLLMWhenRetrievedChunksAreWeak(t *testing.T) {
 		Content:    "Unrelated retrieved content",
 	}}}
 	client := &chatLLMStub{}
-	service := NewChatService(retrieve, client, nil, 8, llm.Config{}, "", nil)
+	service := NewChatService(retrieve, client, nil, 8, llm.Config{}, "", nil, nil, domain.ModelURLPolicy{})
 
 	events, err := service.Chat(context.Background(), "domain", []domain.ChatMessage{
 		{Role: "user", Content: "specific external topic"},
@@ -184,7 +184,7 @@ func TestChatFiltersWeakChunksBeforeCallingLLM(t *testing.T) {
 		},
 	}}
 	client := &chatLLMStub{}
-	service := NewChatService(retrieve, client, nil, 8, llm.Config{}, "", nil)
+	service := NewChatService(retrieve, client, nil, 8, llm.Config{}, "", nil, nil, domain.ModelURLPolicy{})
 
 	events, err := service.Chat(context.Background(), "domain", []domain.ChatMessage{
 		{Role: "user", Content: "alpha topic"},
@@ -220,7 +220,7 @@ func TestChatEmitsRetrievalDebug(t *testing.T) {
 		Score:      &score,
 	}}}
 	client := &chatLLMStub{}
-	service := NewChatService(retrieve, client, nil, 5, llm.Config{}, "", nil)
+	service := NewChatService(retrieve, client, nil, 5, llm.Config{}, "", nil, nil, domain.ModelURLPolicy{})
 
 	events, err := service.Chat(context.Background(), "domain", []domain.ChatMessage{
 		{Role: "user", Content: "alpha details"},
@@ -296,7 +296,7 @@ func TestChatKeepsChunksWhenQueryHasNoMeaningfulTerms(t *testing.T) {
 		Content:    "Unrelated retrieved content",
 	}}}
 	client := &chatLLMStub{}
-	service := NewChatService(retrieve, client, nil, 8, llm.Config{}, "", nil)
+	service := NewChatService(retrieve, client, nil, 8, llm.Config{}, "", nil, nil, domain.ModelURLPolicy{})
 
 	// Query is entirely stopwords/short tokens: no lexical signal to filter on.
 	events, err := service.Chat(context.Background(), "domain", []domain.ChatMessage{
@@ -321,7 +321,7 @@ func TestChatSkipsGroundingForExplicitlyScopedRecords(t *testing.T) {
 		Content:    "Unrelated retrieved content",
 	}}}
 	client := &chatLLMStub{}
-	service := NewChatService(retrieve, client, nil, 8, llm.Config{}, "", nil)
+	service := NewChatService(retrieve, client, nil, 8, llm.Config{}, "", nil, nil, domain.ModelURLPolicy{})
 
 	// User scoped to record-1; grounding must not block the answer even when
 	// the query terms do not literally appear in the chunk.
@@ -338,3 +338,49 @@ func TestChatSkipsGroundingForExplicitlyScopedRecords(t *testing.T) {
 		t.Fatalf("expected LLM to be called for scoped records, got %d calls", client.calls)
 	}
 }
+
+type servedLLMStub struct{}
+
+func (servedLLMStub) StreamChat(ctx context.Context, _ []llm.Message, out chan<- string) error {
+	defer close(out)
+	llm.ReportServed(ctx, llm.Served{Provider: "fallback-ollama", Model: "llama3.2:3b", Attempts: 3})
+	out <- "answer"
+	return nil
+}
+
+func TestChatDebugReportsServingProvider(t *testing.T) {
+	retrieve := &chatRetrieveStub{chunks: []domain.VectorChunk{{
+		RecordID:   "record-1",
+		RecordName: "record_a.pdf",
+		Content:    "Example alpha retrieved chunk",
+	}}}
+	service := NewChatService(retrieve, servedLLMStub{}, nil, 5, llm.Config{Provider: "openai", Model: "gpt-4o"}, "", nil, nil, domain.ModelURLPolicy{})
+
+	events, err := service.Chat(context.Background(), "domain", []domain.ChatMessage{
+		{Role: "user", Content: "alpha details"},
+	}, nil, nil, true)
+	if err != nil {
+		t.Fatalf("chat failed: %v", err)
+	}
+
+	var debug *domain.ChatDebug
+	var sawTokenBeforeDebug bool
+	for ev := range events {
+		switch ev.Type {
+		case domain.ChatEventDebug:
+			debug = ev.Debug
+		case domain.ChatEventToken:
+			sawTokenBeforeDebug = sawTokenBeforeDebug || debug == nil
+		}
+	}
+
+	if debug == nil {
+		t.Fatal("expected debug event")
+	}
+	if sawTokenBeforeDebug {
+		t.Fatal("expected debug event before the first token")
+	}
+	if debug.Provider != "fallback-ollama" || debug.Model != "llama3.2:3b" || debug.Attempts != 3 {
+		t.Fatalf("unexpected served provider: %+v", *debug)
+	}
+}
@@ -0,0 +1,43 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+type ingestJobsService struct {
+	repo domain.IngestJobRepository
+}
+
+// NewIngestJobsService returns an IngestJobService backed by the given
+// repository.
+func NewIngestJobsService(repo domain.IngestJobRepository) domain.IngestJobService {
+	return &ingestJobsService{repo: repo}
+}
+
+func (s *ingestJobsService) Get(ctx context.Context, id, domainID string) (domain.IngestJob, error) {
+	return s.repo.Get(ctx, id, domainID)
+}
+
+func (s *ingestJobsService) List(
+	ctx context.Context,
+	domainID string,
+	f domain.IngestJobFilter,
+	p domain.Page,
+) (domain.IngestJobPage, error) {
+	if f.Status != nil && !f.Status.Valid() {
+		return domain.IngestJobPage{}, fmt.Errorf("invalid status %q", *f.Status)
+	}
+	return s.repo.List(ctx, domainID, f, p)
+}
+
+// Requeue resets a job's attempts and queues it and its record again. Dead
+// jobs are the usual target once the cause of the failure is fixed.
+func (s *ingestJobsService) Requeue(ctx context.Context, id, domainID string) (domain.IngestJob, error) {
+	return s.repo.Requeue(ctx, id, domainID)
+}
@@ -0,0 +1,120 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/secrets"
+)
+
+type modelProvidersService struct {
+	repo     domain.ModelProviderRepository
+	envelope *secrets.Envelope
+	policy   domain.ModelURLPolicy
+}
+
+// NewModelProvidersService returns a ModelProviderService that seals API keys
+// with envelope, bound to their provider, and only accepts base URLs
+// permitted by policy. Without an envelope providers cannot have API keys.
+func NewModelProvidersService(repo domain.ModelProviderRepository, envelope *secrets.Envelope, policy domain.ModelURLPolicy) domain.ModelProviderService {
+	return &modelProvidersService{repo: repo, envelope: envelope, policy: policy}
+}
+
+func (s *modelProvidersService) Create(ctx context.Context, p domain.ModelProvider) (domain.ModelProvider, error) {
+	if p.DomainID == "" {
+		return domain.ModelProvider{}, fmt.Errorf("domain_id is required")
+	}
+	p.Name = strings.TrimSpace(p.Name)
+	if p.Name == "" {
+		return domain.ModelProvider{}, fmt.Errorf("name is required")
+	}
+	p.Provider = strings.ToLower(strings.TrimSpace(p.Provider))
+	if p.Provider != "openai" && p.Provider != "ollama" {
+		return domain.ModelProvider{}, fmt.Errorf("unsupported provider %q", p.Provider)
+	}
+	p.BaseURL = strings.TrimRight(strings.TrimSpace(p.BaseURL), "/")
+	if p.Provider == "openai" && p.BaseURL == "" {
+		return domain.ModelProvider{}, fmt.Errorf("base_url is required for openai providers")
+	}
+	if !s.policy.Allows(p.Provider, p.BaseURL) {
+		return domain.ModelProvider{}, fmt.Errorf("base_url is not allowed")
+	}
+	p.Models = normalizeModelList(p.Models)
+	if len(p.Models) == 0 {
+		return domain.ModelProvider{}, fmt.Errorf("at least one model is required")
+	}
+
+	// The ID is chosen here because the API key is sealed for it.
+	p.ID = uuid.NewString()
+	apiKey := strings.TrimSpace(p.APIKey)
+	p.APIKey = ""
+	p.APIKeyCiphertext = nil
+	if apiKey != "" {
+		if s.envelope == nil {
+			return domain.ModelProvider{}, fmt.Errorf("seal api key: %w", secrets.ErrNotConfigured)
+		}
+		sealed, err := s.envelope.SealValue(apiKey, p.ID)
+		if err != nil {
+			return domain.ModelProvider{}, fmt.Errorf("seal api key: %w", err)
+		}
+		p.APIKeyCiphertext = sealed
+	} else if p.Provider == "openai" {
+		return domain.ModelProvider{}, fmt.Errorf("api_key is required for openai providers")
+	}
+
+	return s.repo.Create(ctx, p)
+}
+
+func (s *modelProvidersService) List(ctx context.Context, domainID string) ([]domain.ModelProvider, error) {
+	return s.repo.List(ctx, domainID)
+}
+
+func (s *modelProvidersService) Delete(ctx context.Context, id, domainID string) error {
+	return s.repo.Delete(ctx, id, domainID)
+}
+
+func (s *modelProvidersService) Resolve(ctx context.Context, id, domainID string) (domain.ModelProvider, error) {
+	p, err := s.repo.GetByID(ctx, id, domainID)
+	if err != nil {
+		return domain.ModelProvider{}, err
+	}
+	// Re-check on every use so tightening the allowlist takes effect for
+	// providers registered earlier.
+	if !s.policy.Allows(p.Provider, p.BaseURL) {
+		return domain.ModelProvider{}, fmt.Errorf("%w: provider base_url is no longer allowed", domain.ErrModelNotAllowed)
+	}
+	if p.HasAPIKey() {
+		if s.envelope == nil {
+			return domain.ModelProvider{}, fmt.Errorf("open api key: %w", secrets.ErrNotConfigured)
+		}
+		key, err := s.envelope.OpenValue(p.APIKeyCiphertext, p.ID)
+		if err != nil {
+			return domain.ModelProvider{}, fmt.Errorf("open api key: %w", err)
+		}
+		p.APIKey = key
+	}
+	return p, nil
+}
+
+func normalizeModelList(models []string) []string {
+	seen := make(map[string]struct{}, len(models))
+	out := make([]string, 0, len(models))
+	for _, m := range models {
+		m = strings.TrimSpace(m)
+		if m == "" {
+			continue
+		}
+		if _, ok := seen[m]; ok {
+			continue
+		}
+		seen[m] = struct{}{}
+		out = append(out, m)
+	}
+	return out
+}
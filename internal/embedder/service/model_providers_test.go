@@ -0,0 +1,204 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/llm"
+	"github.com/ultravioletrs/cube/internal/embedder/secrets"
+)
+
+type modelProvidersRepoStub struct {
+	providers map[string]domain.ModelProvider
+}
+
+func (r *modelProvidersRepoStub) Create(_ context.Context, p domain.ModelProvider) (domain.ModelProvider, error) {
+	if r.providers == nil {
+		r.providers = map[string]domain.ModelProvider{}
+	}
+	r.providers[p.ID] = p
+	return p, nil
+}
+
+func (r *modelProvidersRepoStub) GetByID(_ context.Context, id, domainID string) (domain.ModelProvider, error) {
+	p, ok := r.providers[id]
+	if !ok || p.DomainID != domainID {
+		return domain.ModelProvider{}, domain.ErrNotFound
+	}
+	return p, nil
+}
+
+func (r *modelProvidersRepoStub) List(context.Context, string) ([]domain.ModelProvider, error) {
+	return nil, nil
+}
+
+func (r *modelProvidersRepoStub) Delete(context.Context, string, string) error { return nil }
+
+func newTestModelProviders(t *testing.T) (domain.ModelProviderService, *modelProvidersRepoStub) {
+	t.Helper()
+	sealer, err := secrets.NewAESGCM(bytes.Repeat([]byte{1}, 32))
+	if err != nil {
+		t.Fatalf("sealer: %v", err)
+	}
+	envelope, err := secrets.NewEnvelope(secrets.KEK{ID: "test", Sealer: sealer})
+	if err != nil {
+		t.Fatalf("envelope: %v", err)
+	}
+	repo := &modelProvidersRepoStub{}
+	policy := domain.ModelURLPolicy{AllowedHosts: []string{"api.openai.com"}, OllamaBaseURL: "http://ollama:11434"}
+	return NewModelProvidersService(repo, envelope, policy), repo
+}
+
+func TestModelProvidersCreateSealsAPIKey(t *testing.T) {
+	svc, repo := newTestModelProviders(t)
+
+	created, err := svc.Create(context.Background(), domain.ModelProvider{
+		DomainID: "domain-1",
+		Name:     "OpenAI",
+		Provider: "openai",
+		BaseURL:  "https://api.openai.com/",
+		Models:   []string{"gpt-4o-mini", " gpt-4o-mini ", ""},
+		APIKey:   "sk-secret",
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	stored := repo.providers[created.ID]
+	if stored.APIKey != "" || !stored.HasAPIKey() || bytes.Contains(stored.APIKeyCiphertext, []byte("sk-secret")) {
+		t.Fatalf("expected only ciphertext to be stored: %+v", stored)
+	}
+	if stored.BaseURL != "https://api.openai.com" || len(stored.Models) != 1 {
+		t.Fatalf("expected normalized provider, got %+v", stored)
+	}
+
+	resolved, err := svc.Resolve(context.Background(), created.ID, "domain-1")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	if resolved.APIKey != "sk-secret" {
+		t.Fatalf("resolved key = %q", resolved.APIKey)
+	}
+	if _, err := svc.Resolve(context.Background(), created.ID, "domain-2"); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected other domains not to resolve the provider, got %v", err)
+	}
+
+	// A key copied onto another provider's row does not open.
+	other, err := svc.Create(context.Background(), domain.ModelProvider{
+		DomainID: "domain-1",
+		Name:     "Local",
+		Provider: "ollama",
+		BaseURL:  "http://ollama:11434",
+		Models:   []string{"llama3"},
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	copied := repo.providers[other.ID]
+	copied.APIKeyCiphertext = stored.APIKeyCiphertext
+	repo.providers[other.ID] = copied
+	if _, err := svc.Resolve(context.Background(), other.ID, "domain-1"); err == nil {
+		t.Fatal("expected a key moved to another provider to fail to open")
+	}
+}
+
+func TestModelProvidersRequireKEKForAPIKeys(t *testing.T) {
+	svc := NewModelProvidersService(&modelProvidersRepoStub{}, nil, domain.ModelURLPolicy{AllowedHosts: []string{"api.openai.com"}})
+	_, err := svc.Create(context.Background(), domain.ModelProvider{
+		DomainID: "domain-1",
+		Name:     "OpenAI",
+		Provider: "openai",
+		BaseURL:  "https://api.openai.com",
+		Models:   []string{"gpt-4o-mini"},
+		APIKey:   "sk-secret",
+	})
+	if !errors.Is(err, secrets.ErrNotConfigured) {
+		t.Fatalf("expected ErrNotConfigured, got %v", err)
+	}
+}
+
+func TestModelProvidersCreateRejectsDisallowedBaseURL(t *testing.T) {
+	svc, _ := newTestModelProviders(t)
+
+	for _, p := range []domain.ModelProvider{
+		{Name: "meta", Provider: "openai", BaseURL: "http://169.254.169.254", Models: []string{"m"}, APIKey: "k"},
+		{Name: "other", Provider: "openai", BaseURL: "https://example.com", Models: []string{"m"}, APIKey: "k"},
+		{Name: "ollama", Provider: "ollama", BaseURL: "http://localhost:11434", Models: []string{"m"}},
+	} {
+		p.DomainID = "domain-1"
+		if _, err := svc.Create(context.Background(), p); err == nil {
+			t.Fatalf("expected %q to be rejected", p.BaseURL)
+		}
+	}
+}
+
+type recordingFactory struct {
+	cfg llm.Config
+}
+
+func (f *recordingFactory) build(cfg llm.Config) llm.Client {
+	f.cfg = cfg
+	return &chatLLMStub{}
+}
+
+func TestChatResolvesCatalogueProvider(t *testing.T) {
+	models, _ := newTestModelProviders(t)
+	created, err := models.Create(context.Background(), domain.ModelProvider{
+		DomainID: "domain-1",
+		Name:     "OpenAI",
+		Provider: "openai",
+		BaseURL:  "https://api.openai.com",
+		Models:   []string{"gpt-4o-mini"},
+		APIKey:   "sk-secret",
+	})
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+
+	factory := &recordingFactory{}
+	svc := NewChatService(&chatRetrieveStub{}, &chatLLMStub{}, nil, 5, llm.Config{}, "http://ollama:11434", factory.build, models, domain.ModelURLPolicy{})
+	events, err := svc.Chat(context.Background(), "domain-1", []domain.ChatMessage{{Role: "user", Content: "hello"}}, nil, &domain.ModelConfig{
+		ProviderID: created.ID,
+		BaseURL:    "http://169.254.169.254",
+		APIKey:     "ignored",
+	}, false)
+	if err != nil {
+		t.Fatalf("chat: %v", err)
+	}
+	for range events {
+	}
+	if factory.cfg.BaseURL != "https://api.openai.com" || factory.cfg.APIKey != "sk-secret" || factory.cfg.Model != "gpt-4o-mini" {
+		t.Fatalf("unexpected client config: %+v", factory.cfg)
+	}
+
+	_, err = svc.Chat(context.Background(), "domain-1", []domain.ChatMessage{{Role: "user", Content: "hello"}}, nil, &domain.ModelConfig{
+		ProviderID: created.ID,
+		Model:      "gpt-4o",
+	}, false)
+	if !errors.Is(err, domain.ErrModelNotAllowed) {
+		t.Fatalf("expected model outside the catalogue to be rejected, got %v", err)
+	}
+}
+
+func TestChatRejectsUnlistedOverrideBaseURL(t *testing.T) {
+	factory := &recordingFactory{}
+	svc := NewChatService(&chatRetrieveStub{}, &chatLLMStub{}, nil, 5, llm.Config{}, "http://ollama:11434", factory.build, nil, domain.ModelURLPolicy{})
+
+	_, err := svc.Chat(context.Background(), "domain-1", []domain.ChatMessage{{Role: "user", Content: "hello"}}, nil, &domain.ModelConfig{
+		Provider: "openai",
+		BaseURL:  "http://169.254.169.254/latest",
+		Model:    "gpt-4o-mini",
+		APIKey:   "k",
+	}, false)
+	if !errors.Is(err, domain.ErrModelNotAllowed) {
+		t.Fatalf("expected ErrModelNotAllowed, got %v", err)
+	}
+	if factory.cfg.BaseURL != "" {
+		t.Fatal("expected no client to be built for a disallowed base URL")
+	}
+}
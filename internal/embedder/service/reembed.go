@@ -0,0 +1,275 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/embedding"
+	"github.com/ultravioletrs/cube/internal/embedder/postgres"
+)
+
+const (
+	// DefaultReembedPollInterval is how often the re-embedding worker looks
+	// for a queued job.
+	DefaultReembedPollInterval = 10 * time.Second
+
+	// reembedBatchSize is how many chunks are embedded per request.
+	reembedBatchSize = 16
+	// reembedStaleAfter is how long a running job may go without progress
+	// before another worker takes it over.
+	reembedStaleAfter = 10 * time.Minute
+	// reembedCutOverAttempts bounds how often a job re-embeds chunks that
+	// turned stale while it ran before giving up.
+	reembedCutOverAttempts = 3
+	// reembedListLimit caps how many jobs List returns.
+	reembedListLimit = 50
+)
+
+// reembedChunkStore is the part of the chunks repository re-embedding uses.
+type reembedChunkStore interface {
+	CountStaleEmbeddings(ctx context.Context, scope domain.ReembedScope, profile, model string) (stale, staged int64, err error)
+	ListStaleEmbeddings(ctx context.Context, scope domain.ReembedScope, profile, model string, limit int) ([]postgres.StaleChunk, error)
+	StagePendingEmbeddings(ctx context.Context, model string, ids []string, vectors [][]float32) error
+	CutOverEmbeddings(ctx context.Context, scope domain.ReembedScope, targets map[string]string) (int64, error)
+	ClearPendingEmbeddings(ctx context.Context, scope domain.ReembedScope) error
+}
+
+// reembedProfiles resolves profiles to their current models.
+type reembedProfiles interface {
+	ProfileNames() []string
+	Profile(name string) (embedding.Profile, error)
+}
+
+// ReembedManager runs re-embedding jobs: it re-embeds the stored text of
+// chunks whose vectors come from a model other than their profile's current
+// one, stages the new vectors and swaps them in once all are ready. Records
+// are not downloaded or extracted again.
+type ReembedManager struct {
+	jobs     domain.ReembedJobRepository
+	chunks   reembedChunkStore
+	profiles reembedProfiles
+	interval time.Duration
+}
+
+var _ domain.ReembedService = (*ReembedManager)(nil)
+
+// NewReembedManager creates a manager that embeds with the profiles in
+// registry.
+func NewReembedManager(jobs domain.ReembedJobRepository, chunks *postgres.ChunksRepository, registry *embedding.Registry) *ReembedManager {
+	return newReembedManager(jobs, chunks, registry)
+}
+
+func newReembedManager(jobs domain.ReembedJobRepository, chunks reembedChunkStore, profiles reembedProfiles) *ReembedManager {
+	return &ReembedManager{
+		jobs:     jobs,
+		chunks:   chunks,
+		profiles: profiles,
+		interval: DefaultReembedPollInterval,
+	}
+}
+
+// SetInterval overrides how often queued jobs are polled for.
+func (m *ReembedManager) SetInterval(d time.Duration) {
+	if d > 0 {
+		m.interval = d
+	}
+}
+
+// Start queues a job for the scope in job. It returns ErrConflict when the
+// domain already has an active job.
+func (m *ReembedManager) Start(ctx context.Context, job domain.ReembedJob) (domain.ReembedJob, error) {
+	if job.DomainID == "" {
+		return domain.ReembedJob{}, fmt.Errorf("domain_id is required")
+	}
+	if job.Profile != "" {
+		if _, err := m.profiles.Profile(job.Profile); err != nil {
+			return domain.ReembedJob{}, err
+		}
+	}
+	return m.jobs.Create(ctx, job)
+}
+
+func (m *ReembedManager) Get(ctx context.Context, id, domainID string) (domain.ReembedJob, error) {
+	return m.jobs.Get(ctx, id, domainID)
+}
+
+func (m *ReembedManager) List(ctx context.Context, domainID string) ([]domain.ReembedJob, error) {
+	return m.jobs.List(ctx, domainID, reembedListLimit)
+}
+
+// Cancel stops an active job. The worker notices at its next batch and
+// drops the vectors it staged, leaving the live ones untouched.
+func (m *ReembedManager) Cancel(ctx context.Context, id, domainID string) (domain.ReembedJob, error) {
+	return m.jobs.Cancel(ctx, id, domainID)
+}
+
+// Run processes queued jobs one at a time until ctx is cancelled.
+func (m *ReembedManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.RunPending(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunPending runs queued jobs until none is left.
+func (m *ReembedManager) RunPending(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := m.jobs.ClaimNext(ctx, reembedStaleAfter)
+		if errors.Is(err, domain.ErrNotFound) {
+			return
+		}
+		if err != nil {
+			slog.Error("reembed: claim job", "err", err)
+			return
+		}
+		m.runJob(ctx, job)
+	}
+}
+
+func (m *ReembedManager) runJob(ctx context.Context, job domain.ReembedJob) {
+	log := slog.With("job_id", job.ID, "domain_id", job.DomainID)
+	log.Info("reembed: job started", "source_id", job.SourceID, "format", job.Format, "profile", job.Profile)
+
+	status, err := m.reembed(ctx, job)
+	switch {
+	case ctx.Err() != nil:
+		// Shutting down; the job stays running and is resumed once it goes
+		// stale, reusing the vectors already staged.
+		return
+	case status == domain.ReembedJobCancelled:
+		if err := m.chunks.ClearPendingEmbeddings(context.WithoutCancel(ctx), job.ReembedScope); err != nil {
+			log.Warn("reembed: clear staged vectors", "err", err)
+		}
+		log.Info("reembed: job cancelled")
+		return
+	case err != nil:
+		log.Warn("reembed: job failed", "err", err)
+		if err := m.jobs.Finish(ctx, job.ID, domain.ReembedJobFailed, err.Error()); err != nil {
+			log.Error("reembed: finish job", "err", err)
+		}
+		return
+	}
+	if err := m.jobs.Finish(ctx, job.ID, domain.ReembedJobCompleted, ""); err != nil {
+		log.Error("reembed: finish job", "err", err)
+		return
+	}
+	log.Info("reembed: job completed")
+}
+
+// reembed stages new vectors for every stale chunk in the job's scope and
+// cuts over. Chunks a replica with the old configuration stores meanwhile
+// make the cut-over fail; those are embedded in another pass.
+func (m *ReembedManager) reembed(ctx context.Context, job domain.ReembedJob) (domain.ReembedJobStatus, error) {
+	targets, err := m.targets(job.ReembedScope)
+	if err != nil {
+		return "", err
+	}
+	for attempt := 1; ; attempt++ {
+		status, err := m.stage(ctx, job, targets)
+		if err != nil || status == domain.ReembedJobCancelled {
+			return status, err
+		}
+		models := make(map[string]string, len(targets))
+		for name, p := range targets {
+			models[name] = p.Model
+		}
+		moved, err := m.chunks.CutOverEmbeddings(ctx, job.ReembedScope, models)
+		if errors.Is(err, postgres.ErrReembedIncomplete) && attempt < reembedCutOverAttempts {
+			slog.Info("reembed: chunks changed during job, embedding the rest", "job_id", job.ID, "err", err)
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		slog.Info("reembed: cut over", "job_id", job.ID, "chunks", moved)
+		return domain.ReembedJobCompleted, nil
+	}
+}
+
+// targets returns the profiles the job covers, keyed by name.
+func (m *ReembedManager) targets(scope domain.ReembedScope) (map[string]embedding.Profile, error) {
+	names := m.profiles.ProfileNames()
+	if scope.Profile != "" {
+		names = []string{scope.Profile}
+	}
+	targets := make(map[string]embedding.Profile, len(names))
+	for _, name := range names {
+		p, err := m.profiles.Profile(name)
+		if err != nil {
+			return nil, err
+		}
+		targets[name] = p
+	}
+	return targets, nil
+}
+
+// stage embeds every stale chunk that has no staged vector yet, reporting
+// progress after each batch. It stops early when the job is cancelled.
+func (m *ReembedManager) stage(ctx context.Context, job domain.ReembedJob, targets map[string]embedding.Profile) (domain.ReembedJobStatus, error) {
+	var total, embedded int64
+	for _, p := range targets {
+		stale, staged, err := m.chunks.CountStaleEmbeddings(ctx, job.ReembedScope, p.Name, p.Model)
+		if err != nil {
+			return "", err
+		}
+		total += stale
+		embedded += staged
+	}
+	status, err := m.jobs.UpdateProgress(ctx, job.ID, total, embedded)
+	if err != nil || status == domain.ReembedJobCancelled {
+		return status, err
+	}
+
+	for _, p := range targets {
+		for {
+			if err := ctx.Err(); err != nil {
+				return "", err
+			}
+			chunks, err := m.chunks.ListStaleEmbeddings(ctx, job.ReembedScope, p.Name, p.Model, reembedBatchSize)
+			if err != nil {
+				return "", err
+			}
+			if len(chunks) == 0 {
+				break
+			}
+			ids := make([]string, len(chunks))
+			texts := make([]string, len(chunks))
+			for i, c := range chunks {
+				ids[i] = c.ID
+				texts[i] = c.Content
+			}
+			vectors, err := p.Embed(ctx, texts)
+			if err != nil {
+				return "", fmt.Errorf("embed with profile %q: %w", p.Name, err)
+			}
+			if len(vectors) != len(texts) {
+				return "", fmt.Errorf("embed with profile %q: got %d vectors for %d chunks", p.Name, len(vectors), len(texts))
+			}
+			if err := m.chunks.StagePendingEmbeddings(ctx, p.Model, ids, vectors); err != nil {
+				return "", err
+			}
+			embedded += int64(len(chunks))
+			// Chunks can turn stale while the job runs, so total grows with
+			// embedded rather than letting progress exceed it.
+			total = max(total, embedded)
+			status, err := m.jobs.UpdateProgress(ctx, job.ID, total, embedded)
+			if err != nil || status == domain.ReembedJobCancelled {
+				return status, err
+			}
+		}
+	}
+	return domain.ReembedJobRunning, nil
+}
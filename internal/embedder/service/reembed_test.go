@@ -0,0 +1,228 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/embedding"
+	"github.com/ultravioletrs/cube/internal/embedder/postgres"
+)
+
+func TestReembedManagerStagesAndCutsOver(t *testing.T) {
+	chunks := newReembedChunkStub(40, "ollama/old/768")
+	jobs := &reembedJobRepoStub{queued: []domain.ReembedJob{{ID: "job-1", ReembedScope: domain.ReembedScope{DomainID: "domain-1"}}}}
+	m := newReembedManager(jobs, chunks, reembedProfilesStub{})
+
+	m.RunPending(context.Background())
+
+	if jobs.finished["job-1"] != domain.ReembedJobCompleted {
+		t.Fatalf("expected job to complete, got %q (%s)", jobs.finished["job-1"], jobs.errMsg)
+	}
+	if jobs.total != 40 || jobs.embedded != 40 {
+		t.Fatalf("expected progress 40/40, got %d/%d", jobs.embedded, jobs.total)
+	}
+	for id, c := range chunks.rows {
+		if c.model != "ollama/new/3" || c.pending != "" {
+			t.Fatalf("chunk %s not cut over: %+v", id, c)
+		}
+	}
+	if chunks.cutOvers != 1 {
+		t.Fatalf("expected one cut-over, got %d", chunks.cutOvers)
+	}
+}
+
+func TestReembedManagerCancelKeepsLiveVectors(t *testing.T) {
+	chunks := newReembedChunkStub(40, "ollama/old/768")
+	jobs := &reembedJobRepoStub{
+		queued:       []domain.ReembedJob{{ID: "job-1", ReembedScope: domain.ReembedScope{DomainID: "domain-1"}}},
+		cancelAfter:  2,
+		statusAfterN: domain.ReembedJobCancelled,
+	}
+	m := newReembedManager(jobs, chunks, reembedProfilesStub{})
+
+	m.RunPending(context.Background())
+
+	if chunks.cutOvers != 0 {
+		t.Fatal("cancelled job cut over")
+	}
+	for id, c := range chunks.rows {
+		if c.model != "ollama/old/768" || c.pending != "" {
+			t.Fatalf("chunk %s changed by cancelled job: %+v", id, c)
+		}
+	}
+	if _, ok := jobs.finished["job-1"]; ok {
+		t.Fatal("cancelled job must keep its cancelled status")
+	}
+}
+
+func TestReembedManagerRejectsUnknownProfile(t *testing.T) {
+	m := newReembedManager(&reembedJobRepoStub{}, newReembedChunkStub(0, ""), reembedProfilesStub{})
+	_, err := m.Start(context.Background(), domain.ReembedJob{ReembedScope: domain.ReembedScope{DomainID: "domain-1", Profile: "missing"}})
+	if err == nil {
+		t.Fatal("expected unknown profile to be rejected")
+	}
+}
+
+type reembedProfilesStub struct{}
+
+func (reembedProfilesStub) ProfileNames() []string { return []string{"text"} }
+
+func (reembedProfilesStub) Profile(name string) (embedding.Profile, error) {
+	if name != "text" {
+		return embedding.Profile{}, fmt.Errorf("embedding profile %q is not configured", name)
+	}
+	return embedding.Profile{Name: "text", Model: "ollama/new/3", Embedder: fixedEmbedder{}}, nil
+}
+
+type fixedEmbedder struct{}
+
+func (fixedEmbedder) Embed(_ context.Context, texts []string) ([][]float32, error) {
+	out := make([][]float32, len(texts))
+	for i := range out {
+		out[i] = []float32{1, 0, 0}
+	}
+	return out, nil
+}
+
+func (fixedEmbedder) Dimensions() int { return 3 }
+
+type reembedChunkRow struct {
+	model   string
+	pending string
+}
+
+type reembedChunkStub struct {
+	rows     map[string]*reembedChunkRow
+	ids      []string
+	cutOvers int
+}
+
+func newReembedChunkStub(n int, model string) *reembedChunkStub {
+	s := &reembedChunkStub{rows: make(map[string]*reembedChunkRow)}
+	for i := range n {
+		id := fmt.Sprintf("chunk-%03d", i)
+		s.rows[id] = &reembedChunkRow{model: model}
+		s.ids = append(s.ids, id)
+	}
+	return s
+}
+
+func (s *reembedChunkStub) CountStaleEmbeddings(_ context.Context, _ domain.ReembedScope, _, model string) (int64, int64, error) {
+	var stale, staged int64
+	for _, r := range s.rows {
+		if r.model != model {
+			stale++
+			if r.pending == model {
+				staged++
+			}
+		}
+	}
+	return stale, staged, nil
+}
+
+func (s *reembedChunkStub) ListStaleEmbeddings(_ context.Context, _ domain.ReembedScope, _, model string, limit int) ([]postgres.StaleChunk, error) {
+	var out []postgres.StaleChunk
+	for _, id := range s.ids {
+		if r := s.rows[id]; r.model != model && r.pending != model && len(out) < limit {
+			out = append(out, postgres.StaleChunk{ID: id, Content: "text of " + id})
+		}
+	}
+	return out, nil
+}
+
+func (s *reembedChunkStub) StagePendingEmbeddings(_ context.Context, model string, ids []string, _ [][]float32) error {
+	for _, id := range ids {
+		s.rows[id].pending = model
+	}
+	return nil
+}
+
+func (s *reembedChunkStub) CutOverEmbeddings(_ context.Context, _ domain.ReembedScope, targets map[string]string) (int64, error) {
+	model := targets["text"]
+	for _, r := range s.rows {
+		if r.model != model && r.pending != model {
+			return 0, postgres.ErrReembedIncomplete
+		}
+	}
+	s.cutOvers++
+	var moved int64
+	for _, r := range s.rows {
+		if r.model != model {
+			r.model, r.pending = r.pending, ""
+			moved++
+		}
+	}
+	return moved, nil
+}
+
+func (s *reembedChunkStub) ClearPendingEmbeddings(context.Context, domain.ReembedScope) error {
+	for _, r := range s.rows {
+		r.pending = ""
+	}
+	return nil
+}
+
+type reembedJobRepoStub struct {
+	queued          []domain.ReembedJob
+	total, embedded int64
+	updates         int
+	// After cancelAfter progress updates, UpdateProgress reports
+	// statusAfterN, as if the job had been cancelled through the API.
+	cancelAfter  int
+	statusAfterN domain.ReembedJobStatus
+	finished     map[string]domain.ReembedJobStatus
+	errMsg       string
+}
+
+func (r *reembedJobRepoStub) Create(_ context.Context, job domain.ReembedJob) (domain.ReembedJob, error) {
+	job.ID = fmt.Sprintf("job-%d", len(r.queued)+1)
+	job.Status = domain.ReembedJobQueued
+	r.queued = append(r.queued, job)
+	return job, nil
+}
+
+func (r *reembedJobRepoStub) Get(context.Context, string, string) (domain.ReembedJob, error) {
+	return domain.ReembedJob{}, domain.ErrNotFound
+}
+
+func (r *reembedJobRepoStub) List(context.Context, string, int) ([]domain.ReembedJob, error) {
+	return nil, nil
+}
+
+func (r *reembedJobRepoStub) ClaimNext(context.Context, time.Duration) (domain.ReembedJob, error) {
+	if len(r.queued) == 0 {
+		return domain.ReembedJob{}, domain.ErrNotFound
+	}
+	job := r.queued[0]
+	r.queued = r.queued[1:]
+	job.Status = domain.ReembedJobRunning
+	return job, nil
+}
+
+func (r *reembedJobRepoStub) UpdateProgress(_ context.Context, _ string, total, embedded int64) (domain.ReembedJobStatus, error) {
+	r.updates++
+	if r.cancelAfter > 0 && r.updates > r.cancelAfter {
+		return r.statusAfterN, nil
+	}
+	r.total, r.embedded = total, embedded
+	return domain.ReembedJobRunning, nil
+}
+
+func (r *reembedJobRepoStub) Finish(_ context.Context, id string, status domain.ReembedJobStatus, errMsg string) error {
+	if r.finished == nil {
+		r.finished = make(map[string]domain.ReembedJobStatus)
+	}
+	r.finished[id] = status
+	r.errMsg = errMsg
+	return nil
+}
+
+func (r *reembedJobRepoStub) Cancel(context.Context, string, string) (domain.ReembedJob, error) {
+	return domain.ReembedJob{}, domain.ErrConflict
+}
@@ -0,0 +1,238 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	embedmetrics "github.com/ultravioletrs/cube/internal/embedder/metrics"
+)
+
+const (
+	// DefaultNotificationDebounce is how long notifications for a
+	// subscription are collected before its targeted sync runs.
+	DefaultNotificationDebounce = time.Second
+
+	// maxPendingChangeIDs bounds the item IDs kept per subscription between
+	// syncs. Past it providers fall back to a full sync anyway.
+	maxPendingChangeIDs = 5000
+)
+
+// NotificationService queues change notifications and runs a targeted sync
+// per subscription. Notifications arriving in a burst are coalesced into
+// one sync.
+type NotificationService struct {
+	subs      domain.SourceSubscriptionRepository
+	syncer    domain.SourceSyncService
+	providers *ingest.SourceProviderRegistry
+	trigger   func()
+	s3Token   string
+	debounce  time.Duration
+
+	mu      sync.Mutex
+	pending map[string]domain.SourceChangeHint
+	wake    chan struct{}
+}
+
+var _ domain.SourceNotificationService = (*NotificationService)(nil)
+
+// NewNotificationService creates the service behind notification callback
+// URLs. trigger wakes the ingest worker after a sync queued records.
+// s3Token authenticates S3 bucket events; without it they are rejected.
+func NewNotificationService(
+	subs domain.SourceSubscriptionRepository,
+	syncer domain.SourceSyncService,
+	providers *ingest.SourceProviderRegistry,
+	trigger func(),
+	s3Token string,
+) *NotificationService {
+	return &NotificationService{
+		subs:      subs,
+		syncer:    syncer,
+		providers: providers,
+		trigger:   trigger,
+		s3Token:   strings.TrimSpace(s3Token),
+		debounce:  DefaultNotificationDebounce,
+		pending:   make(map[string]domain.SourceChangeHint),
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// SetDebounce overrides how long notifications are collected before a sync.
+func (s *NotificationService) SetDebounce(d time.Duration) {
+	if d >= 0 {
+		s.debounce = d
+	}
+}
+
+func (s *NotificationService) Validate(ctx context.Context, subscriptionID string) error {
+	_, err := s.subs.GetByID(ctx, subscriptionID)
+	return err
+}
+
+func (s *NotificationService) Notify(ctx context.Context, subscriptionID string, n domain.ChangeNotification) error {
+	sub, err := s.subs.GetByID(ctx, subscriptionID)
+	if err != nil {
+		return err
+	}
+	notifier, ok := s.notifier(sub.Provider)
+	if !ok {
+		return domain.ErrNotFound
+	}
+	hint, changed, err := notifier.ParseNotification(sub, n)
+	if err != nil {
+		embedmetrics.ObserveSourceNotification(string(sub.Provider), "rejected")
+		return err
+	}
+	s.enqueue(sub, hint, changed)
+	return nil
+}
+
+func (s *NotificationService) NotifyProvider(ctx context.Context, provider domain.SourceType, n domain.ChangeNotification) error {
+	if provider != domain.SourceTypeS3 {
+		return domain.ErrNotFound
+	}
+	if !s.authorizeS3(n.Header) {
+		embedmetrics.ObserveSourceNotification(string(provider), "rejected")
+		return domain.ErrInvalidNotification
+	}
+	notifier, ok := s.notifier(provider)
+	if !ok {
+		return domain.ErrNotFound
+	}
+	subs, err := s.subs.ListByProvider(ctx, provider)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		hint, changed, err := notifier.ParseNotification(sub, n)
+		if err != nil {
+			embedmetrics.ObserveSourceNotification(string(provider), "rejected")
+			return err
+		}
+		s.enqueue(sub, hint, changed)
+	}
+	return nil
+}
+
+// authorizeS3 checks the shared S3 token, sent as a bearer token the way
+// MinIO webhook targets send their auth_token.
+func (s *NotificationService) authorizeS3(header http.Header) bool {
+	if s.s3Token == "" {
+		return false
+	}
+	auth := strings.TrimSpace(header.Get("Authorization"))
+	if len(auth) > len("Bearer ") && strings.EqualFold(auth[:len("Bearer ")], "Bearer ") {
+		auth = strings.TrimSpace(auth[len("Bearer "):])
+	}
+	return subtle.ConstantTimeCompare([]byte(auth), []byte(s.s3Token)) == 1
+}
+
+func (s *NotificationService) notifier(provider domain.SourceType) (ingest.ChangeNotifier, bool) {
+	p, ok := s.providers.Provider(provider)
+	if !ok {
+		return nil, false
+	}
+	notifier, ok := p.(ingest.ChangeNotifier)
+	return notifier, ok
+}
+
+func (s *NotificationService) enqueue(sub domain.SourceSubscription, hint domain.SourceChangeHint, changed bool) {
+	if !changed {
+		embedmetrics.ObserveSourceNotification(string(sub.Provider), "ignored")
+		return
+	}
+	embedmetrics.ObserveSourceNotification(string(sub.Provider), "queued")
+
+	s.mu.Lock()
+	merged := s.pending[sub.ID]
+	for _, id := range hint.ExternalIDs {
+		if len(merged.ExternalIDs) > maxPendingChangeIDs {
+			break
+		}
+		merged.ExternalIDs = append(merged.ExternalIDs, id)
+	}
+	s.pending[sub.ID] = merged
+	s.mu.Unlock()
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes queued notifications until ctx is cancelled.
+func (s *NotificationService) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		}
+		if s.debounce > 0 {
+			timer := time.NewTimer(s.debounce)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		s.processPending(ctx)
+	}
+}
+
+// processPending runs one targeted sync per subscription with queued
+// notifications and stores the subscriptions' new cursors.
+func (s *NotificationService) processPending(ctx context.Context) {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = make(map[string]domain.SourceChangeHint)
+	s.mu.Unlock()
+
+	ids := make([]string, 0, len(pending))
+	for id := range pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	var queued uint64
+	for _, id := range ids {
+		if ctx.Err() != nil {
+			return
+		}
+		// Reload the subscription so the sync resumes from the latest cursor.
+		sub, err := s.subs.GetByID(ctx, id)
+		if err != nil {
+			if !errors.Is(err, domain.ErrNotFound) {
+				slog.Error("notifications: load subscription", "subscription_id", id, "err", err)
+			}
+			continue
+		}
+		res, err := s.syncer.SyncChanges(ctx, sub, pending[id])
+		if err != nil {
+			slog.Error("notifications: sync changes", "subscription_id", id, "source_id", sub.SourceID, "err", err)
+			continue
+		}
+		if res.Cursor != "" && res.Cursor != sub.Cursor {
+			if err := s.subs.UpdateCursor(ctx, id, res.Cursor); err != nil && !errors.Is(err, domain.ErrNotFound) {
+				slog.Error("notifications: store cursor", "subscription_id", id, "err", err)
+			}
+		}
+		queued += res.Queued
+	}
+	if queued > 0 && s.trigger != nil {
+		s.trigger()
+	}
+}
@@ -0,0 +1,387 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+)
+
+func TestSourceSyncService_SyncChangesUpsertsChangedFiles(t *testing.T) {
+	sources := &sourceRepoSyncStub{source: notifierTestSource()}
+	records := &recordRepoSyncStub{}
+	provider := &notifierProviderStub{
+		staticSourceProvider: staticSourceProvider{providerType: domain.SourceTypeS3, prunesStale: true},
+		changes: ingest.SourceChanges{
+			Files:   []ingest.SourceFile{{ExternalID: "team/a.txt", Name: "a.txt", ExternalRef: "team/a.txt", SourceVersion: "v2"}},
+			Removed: []string{"team/gone.txt"},
+		},
+	}
+	svc := NewSourceSyncService(sources, records, ingest.NewSourceProviderRegistry(provider))
+
+	res, err := svc.SyncChanges(context.Background(), domain.SourceSubscription{
+		ID:       "sub-1",
+		SourceID: "src-1",
+		DomainID: "domain-1",
+		Cursor:   "c1",
+	}, domain.SourceChangeHint{ExternalIDs: []string{"team/a.txt", "team/gone.txt"}})
+	if err != nil {
+		t.Fatalf("SyncChanges returned error: %v", err)
+	}
+	if res.Discovered != 1 || res.Queued != 1 || res.Deleted != 1 {
+		t.Fatalf("unexpected result: %#v", res)
+	}
+	if len(records.upserts) != 1 || records.upserts[0].ExternalID != "team/a.txt" {
+		t.Fatalf("unexpected upserts: %#v", records.upserts)
+	}
+	if provider.listCalls != 0 {
+		t.Fatalf("targeted sync listed the whole source")
+	}
+	if sources.lastStatus != domain.SourceStatusActive {
+		t.Fatalf("expected active status, got %q", sources.lastStatus)
+	}
+}
+
+func TestSourceSyncService_SyncChangesFallsBackToFullSync(t *testing.T) {
+	sources := &sourceRepoSyncStub{source: notifierTestSource()}
+	records := &recordRepoSyncStub{}
+	provider := &notifierProviderStub{
+		staticSourceProvider: staticSourceProvider{
+			providerType: domain.SourceTypeS3,
+			files:        []ingest.SourceFile{{ExternalID: "a.txt", Name: "a.txt"}, {ExternalID: "b.txt", Name: "b.txt"}},
+		},
+		changesErr:  fmt.Errorf("%w: delta expired", ingest.ErrFullSyncRequired),
+		startCursor: "cursor-now",
+	}
+	svc := NewSourceSyncService(sources, records, ingest.NewSourceProviderRegistry(provider))
+
+	res, err := svc.SyncChanges(context.Background(), domain.SourceSubscription{
+		ID:       "sub-1",
+		SourceID: "src-1",
+		DomainID: "domain-1",
+		Cursor:   "cursor-old",
+	}, domain.SourceChangeHint{})
+	if err != nil {
+		t.Fatalf("SyncChanges returned error: %v", err)
+	}
+	if provider.listCalls != 1 || res.Discovered != 2 || len(records.upserts) != 2 {
+		t.Fatalf("expected a full sync, got result %#v after %d list calls", res, provider.listCalls)
+	}
+	if res.Cursor != "cursor-now" {
+		t.Fatalf("expected the cursor to move to the current position, got %q", res.Cursor)
+	}
+}
+
+func TestNotificationService_CoalescesNotificationsPerSubscription(t *testing.T) {
+	subs := newSubscriptionRepoStub()
+	subs.subs["sub-1"] = domain.SourceSubscription{
+		ID: "sub-1", SourceID: "src-1", DomainID: "domain-1",
+		Provider: domain.SourceTypeS3, ClientState: "token-1", Cursor: "c1",
+	}
+	provider := &notifierProviderStub{staticSourceProvider: staticSourceProvider{providerType: domain.SourceTypeS3}}
+	syncer := &syncChangesStub{result: domain.SourceSyncResult{Queued: 2, Cursor: "c2"}}
+	triggered := 0
+	svc := NewNotificationService(subs, syncer, ingest.NewSourceProviderRegistry(provider), func() { triggered++ }, "")
+
+	for _, ids := range []string{"a.txt", "b.txt,c.txt"} {
+		n := domain.ChangeNotification{Header: http.Header{"X-Token": {"token-1"}}, Body: []byte(ids)}
+		if err := svc.Notify(context.Background(), "sub-1", n); err != nil {
+			t.Fatalf("Notify returned error: %v", err)
+		}
+	}
+	svc.processPending(context.Background())
+
+	if len(syncer.calls) != 1 {
+		t.Fatalf("expected one coalesced sync, got %d", len(syncer.calls))
+	}
+	if want := []string{"a.txt", "b.txt", "c.txt"}; !reflect.DeepEqual(syncer.calls[0].ExternalIDs, want) {
+		t.Fatalf("hint = %v, want %v", syncer.calls[0].ExternalIDs, want)
+	}
+	if subs.subs["sub-1"].Cursor != "c2" {
+		t.Fatalf("expected the cursor to be stored, got %q", subs.subs["sub-1"].Cursor)
+	}
+	if triggered != 1 {
+		t.Fatalf("expected the worker to be triggered once, got %d", triggered)
+	}
+}
+
+func TestNotificationService_RejectsInvalidNotifications(t *testing.T) {
+	subs := newSubscriptionRepoStub()
+	subs.subs["sub-1"] = domain.SourceSubscription{ID: "sub-1", Provider: domain.SourceTypeS3, ClientState: "token-1"}
+	provider := &notifierProviderStub{staticSourceProvider: staticSourceProvider{providerType: domain.SourceTypeS3}}
+	svc := NewNotificationService(subs, &syncChangesStub{}, ingest.NewSourceProviderRegistry(provider), nil, "s3-token")
+
+	forged := domain.ChangeNotification{Header: http.Header{"X-Token": {"guess"}}, Body: []byte("a.txt")}
+	if err := svc.Notify(context.Background(), "sub-1", forged); !errors.Is(err, domain.ErrInvalidNotification) {
+		t.Fatalf("expected ErrInvalidNotification, got %v", err)
+	}
+	if err := svc.Notify(context.Background(), "missing", forged); !errors.Is(err, domain.ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+
+	unauthenticated := domain.ChangeNotification{Header: http.Header{"Authorization": {"Bearer wrong"}}}
+	if err := svc.NotifyProvider(context.Background(), domain.SourceTypeS3, unauthenticated); !errors.Is(err, domain.ErrInvalidNotification) {
+		t.Fatalf("expected ErrInvalidNotification for a wrong S3 token, got %v", err)
+	}
+	if len(svc.pending) != 0 {
+		t.Fatalf("rejected notifications were queued: %#v", svc.pending)
+	}
+}
+
+func TestSubscriptionManager_SubscribesAndRenews(t *testing.T) {
+	sources := &sourceRepoSyncStub{source: notifierTestSource()}
+	subs := newSubscriptionRepoStub()
+	subs.unsubscribed = []domain.SourceRef{{ID: "src-1", DomainID: "domain-1"}}
+	provider := &notifierProviderStub{
+		staticSourceProvider: staticSourceProvider{providerType: domain.SourceTypeS3},
+		startCursor:          "c0",
+	}
+	m := NewSubscriptionManager(sources, subs, ingest.NewSourceProviderRegistry(provider), "https://cube.example/")
+
+	m.Reconcile(context.Background())
+	if len(subs.subs) != 1 {
+		t.Fatalf("expected one subscription, got %#v", subs.subs)
+	}
+	var sub domain.SourceSubscription
+	for _, s := range subs.subs {
+		sub = s
+	}
+	if sub.ExternalID != "ext-"+sub.ID || sub.Cursor != "c0" || len(sub.ClientState) != 64 {
+		t.Fatalf("unexpected subscription: %#v", sub)
+	}
+	if provider.callbackURL != "https://cube.example/api/v1/notifications/s3" {
+		t.Fatalf("unexpected callback URL: %q", provider.callbackURL)
+	}
+
+	// A failed renewal drops the subscription so it is recreated.
+	subs.unsubscribed = nil
+	expiring := time.Now().Add(time.Hour)
+	sub.ExpiresAt = &expiring
+	subs.subs[sub.ID] = sub
+	provider.renewErr = errors.New("subscription expired")
+	m.Reconcile(context.Background())
+	if len(subs.subs) != 0 || provider.unsubscribed != 1 {
+		t.Fatalf("expected the subscription to be dropped, got %#v", subs.subs)
+	}
+}
+
+func TestSubscriptionManager_BacksOffFailedSubscriptions(t *testing.T) {
+	sources := &sourceRepoSyncStub{source: notifierTestSource()}
+	subs := newSubscriptionRepoStub()
+	subs.unsubscribed = []domain.SourceRef{{ID: "src-1", DomainID: "domain-1"}}
+	provider := &notifierProviderStub{
+		staticSourceProvider: staticSourceProvider{providerType: domain.SourceTypeS3},
+		subscribeErr:         errors.New("callback unreachable"),
+	}
+	m := NewSubscriptionManager(sources, subs, ingest.NewSourceProviderRegistry(provider), "https://cube.example")
+
+	m.Reconcile(context.Background())
+	m.Reconcile(context.Background())
+	if provider.subscribeCalls != 1 {
+		t.Fatalf("expected one attempt within the backoff, got %d", provider.subscribeCalls)
+	}
+	if len(subs.subs) != 0 {
+		t.Fatalf("failed subscription was kept: %#v", subs.subs)
+	}
+}
+
+func notifierTestSource() domain.Source {
+	return domain.Source{
+		ID:          "src-1",
+		DomainID:    "domain-1",
+		UserID:      "user-1",
+		Type:        domain.SourceTypeS3,
+		Name:        "Docs",
+		Status:      domain.SourceStatusActive,
+		SyncEnabled: true,
+	}
+}
+
+// notifierProviderStub reads changed IDs from comma-separated notification
+// bodies and authenticates them with an X-Token header.
+type notifierProviderStub struct {
+	staticSourceProvider
+	changes      ingest.SourceChanges
+	changesErr   error
+	startCursor  string
+	subscribeErr error
+	renewErr     error
+
+	listCalls      int
+	subscribeCalls int
+	unsubscribed   int
+	callbackURL    string
+}
+
+func (p *notifierProviderStub) ListFiles(ctx context.Context, userID string, src domain.Source) ([]ingest.SourceFile, error) {
+	p.listCalls++
+	return p.staticSourceProvider.ListFiles(ctx, userID, src)
+}
+
+func (p *notifierProviderStub) Subscribe(
+	_ context.Context,
+	_ domain.Source,
+	sub domain.SourceSubscription,
+	callbackURL string,
+) (domain.SourceSubscription, error) {
+	p.subscribeCalls++
+	p.callbackURL = callbackURL
+	if p.subscribeErr != nil {
+		return sub, p.subscribeErr
+	}
+	sub.ExternalID = "ext-" + sub.ID
+	sub.Cursor = p.startCursor
+	return sub, nil
+}
+
+func (p *notifierProviderStub) Renew(
+	_ context.Context,
+	_ domain.Source,
+	sub domain.SourceSubscription,
+	_ string,
+) (domain.SourceSubscription, error) {
+	return sub, p.renewErr
+}
+
+func (p *notifierProviderStub) Unsubscribe(context.Context, domain.Source, domain.SourceSubscription) error {
+	p.unsubscribed++
+	return nil
+}
+
+func (p *notifierProviderStub) ParseNotification(
+	sub domain.SourceSubscription,
+	n domain.ChangeNotification,
+) (domain.SourceChangeHint, bool, error) {
+	if n.Header.Get("X-Token") != sub.ClientState {
+		return domain.SourceChangeHint{}, false, domain.ErrInvalidNotification
+	}
+	var hint domain.SourceChangeHint
+	for _, id := range strings.Split(string(n.Body), ",") {
+		if id != "" {
+			hint.ExternalIDs = append(hint.ExternalIDs, id)
+		}
+	}
+	return hint, len(hint.ExternalIDs) > 0, nil
+}
+
+func (p *notifierProviderStub) Changes(
+	_ context.Context,
+	_ domain.Source,
+	sub domain.SourceSubscription,
+	_ domain.SourceChangeHint,
+) (ingest.SourceChanges, error) {
+	if sub.Cursor == "" {
+		return ingest.SourceChanges{Cursor: p.startCursor}, nil
+	}
+	return p.changes, p.changesErr
+}
+
+type syncChangesStub struct {
+	result domain.SourceSyncResult
+	calls  []domain.SourceChangeHint
+}
+
+func (s *syncChangesStub) Sync(context.Context, string, string) (domain.SourceSyncResult, error) {
+	return s.result, nil
+}
+
+func (s *syncChangesStub) SyncChanges(
+	_ context.Context,
+	_ domain.SourceSubscription,
+	hint domain.SourceChangeHint,
+) (domain.SourceSyncResult, error) {
+	s.calls = append(s.calls, hint)
+	return s.result, nil
+}
+
+type subscriptionRepoStub struct {
+	subs         map[string]domain.SourceSubscription
+	unsubscribed []domain.SourceRef
+	nextID       int
+}
+
+func newSubscriptionRepoStub() *subscriptionRepoStub {
+	return &subscriptionRepoStub{subs: make(map[string]domain.SourceSubscription)}
+}
+
+func (r *subscriptionRepoStub) Create(_ context.Context, sub domain.SourceSubscription) (domain.SourceSubscription, error) {
+	r.nextID++
+	sub.ID = fmt.Sprintf("sub-%d", r.nextID)
+	r.subs[sub.ID] = sub
+	return sub, nil
+}
+
+func (r *subscriptionRepoStub) GetByID(_ context.Context, id string) (domain.SourceSubscription, error) {
+	sub, ok := r.subs[id]
+	if !ok {
+		return domain.SourceSubscription{}, domain.ErrNotFound
+	}
+	return sub, nil
+}
+
+func (r *subscriptionRepoStub) ListByProvider(_ context.Context, provider domain.SourceType) ([]domain.SourceSubscription, error) {
+	var out []domain.SourceSubscription
+	for _, sub := range r.subs {
+		if sub.Provider == provider {
+			out = append(out, sub)
+		}
+	}
+	return out, nil
+}
+
+func (r *subscriptionRepoStub) ListExpiring(_ context.Context, before time.Time) ([]domain.SourceSubscription, error) {
+	var out []domain.SourceSubscription
+	for _, sub := range r.subs {
+		if sub.ExpiresAt != nil && sub.ExpiresAt.Before(before) {
+			out = append(out, sub)
+		}
+	}
+	return out, nil
+}
+
+func (r *subscriptionRepoStub) ListUnsubscribedSources(context.Context, []domain.SourceType) ([]domain.SourceRef, error) {
+	return r.unsubscribed, nil
+}
+
+func (r *subscriptionRepoStub) Update(_ context.Context, sub domain.SourceSubscription) (domain.SourceSubscription, error) {
+	stored, ok := r.subs[sub.ID]
+	if !ok {
+		return domain.SourceSubscription{}, domain.ErrNotFound
+	}
+	stored.ExternalID = sub.ExternalID
+	stored.Resource = sub.Resource
+	stored.ExpiresAt = sub.ExpiresAt
+	r.subs[sub.ID] = stored
+	return stored, nil
+}
+
+func (r *subscriptionRepoStub) UpdateCursor(_ context.Context, id, cursor string) error {
+	stored, ok := r.subs[id]
+	if !ok {
+		return domain.ErrNotFound
+	}
+	stored.Cursor = cursor
+	r.subs[id] = stored
+	return nil
+}
+
+func (r *subscriptionRepoStub) Delete(_ context.Context, id string) error {
+	if _, ok := r.subs[id]; !ok {
+		return domain.ErrNotFound
+	}
+	delete(r.subs, id)
+	return nil
+}
+
+var _ domain.SourceSubscriptionRepository = (*subscriptionRepoStub)(nil)
+var _ ingest.ChangeNotifier = (*notifierProviderStub)(nil)
@@ -0,0 +1,255 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+)
+
+const (
+	// DefaultSubscriptionReconcileInterval is how often subscriptions are
+	// created for new sources and renewed before they expire.
+	DefaultSubscriptionReconcileInterval = 10 * time.Minute
+
+	// subscriptionRenewWindow is how long before expiry a subscription is
+	// renewed.
+	subscriptionRenewWindow = 24 * time.Hour
+	// subscriptionRetryBackoff delays another attempt for a source whose
+	// subscription could not be created.
+	subscriptionRetryBackoff = time.Hour
+)
+
+// SubscriptionManager keeps a change-notification subscription registered
+// for every sync-enabled source whose provider supports one.
+type SubscriptionManager struct {
+	sources   domain.SourceRepository
+	subs      domain.SourceSubscriptionRepository
+	providers *ingest.SourceProviderRegistry
+	baseURL   string
+	interval  time.Duration
+	now       func() time.Time
+
+	// retryAfter holds sources whose subscription failed, keyed by source ID.
+	retryAfter map[string]time.Time
+}
+
+// NewSubscriptionManager creates a manager that registers callback URLs
+// under publicBaseURL, the externally reachable URL of the embedder.
+func NewSubscriptionManager(
+	sources domain.SourceRepository,
+	subs domain.SourceSubscriptionRepository,
+	providers *ingest.SourceProviderRegistry,
+	publicBaseURL string,
+) *SubscriptionManager {
+	return &SubscriptionManager{
+		sources:    sources,
+		subs:       subs,
+		providers:  providers,
+		baseURL:    strings.TrimRight(strings.TrimSpace(publicBaseURL), "/"),
+		interval:   DefaultSubscriptionReconcileInterval,
+		now:        time.Now,
+		retryAfter: make(map[string]time.Time),
+	}
+}
+
+// SetInterval overrides how often subscriptions are reconciled.
+func (m *SubscriptionManager) SetInterval(d time.Duration) {
+	if d > 0 {
+		m.interval = d
+	}
+}
+
+// Run reconciles subscriptions immediately and then every interval until
+// ctx is cancelled.
+func (m *SubscriptionManager) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+	for {
+		m.Reconcile(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reconcile subscribes sources that have no subscription and renews
+// subscriptions that are about to expire.
+func (m *SubscriptionManager) Reconcile(ctx context.Context) {
+	m.renewExpiring(ctx)
+	m.subscribeNew(ctx)
+}
+
+// CallbackURL returns the URL notifications for sub are delivered to.
+func (m *SubscriptionManager) CallbackURL(sub domain.SourceSubscription) string {
+	if sub.Provider == domain.SourceTypeS3 {
+		return m.baseURL + "/api/v1/notifications/s3"
+	}
+	return m.baseURL + "/api/v1/notifications/" + sub.ID
+}
+
+// notifierTypes returns the source types whose providers push change
+// notifications.
+func (m *SubscriptionManager) notifierTypes() []domain.SourceType {
+	var types []domain.SourceType
+	for _, t := range domain.SupportedSourceTypes() {
+		if _, ok := m.notifier(t); ok {
+			types = append(types, t)
+		}
+	}
+	return types
+}
+
+func (m *SubscriptionManager) notifier(t domain.SourceType) (ingest.ChangeNotifier, bool) {
+	p, ok := m.providers.Provider(t)
+	if !ok {
+		return nil, false
+	}
+	notifier, ok := p.(ingest.ChangeNotifier)
+	return notifier, ok
+}
+
+func (m *SubscriptionManager) subscribeNew(ctx context.Context) {
+	refs, err := m.subs.ListUnsubscribedSources(ctx, m.notifierTypes())
+	if err != nil {
+		slog.Error("subscriptions: list unsubscribed sources", "err", err)
+		return
+	}
+	now := m.now()
+	for _, ref := range refs {
+		if ctx.Err() != nil {
+			return
+		}
+		if until, ok := m.retryAfter[ref.ID]; ok && now.Before(until) {
+			continue
+		}
+		if err := m.subscribe(ctx, ref); err != nil {
+			slog.Warn("subscriptions: subscribe source", "source_id", ref.ID, "err", err)
+			m.retryAfter[ref.ID] = now.Add(subscriptionRetryBackoff)
+			continue
+		}
+		delete(m.retryAfter, ref.ID)
+	}
+}
+
+func (m *SubscriptionManager) subscribe(ctx context.Context, ref domain.SourceRef) error {
+	src, err := m.sources.GetByID(ctx, ref.ID, ref.DomainID)
+	if err != nil {
+		return err
+	}
+	provider, ok := m.providers.Provider(src.Type)
+	if !ok {
+		return fmt.Errorf("source type %q has no provider", src.Type)
+	}
+	notifier, ok := provider.(ingest.ChangeNotifier)
+	if !ok {
+		return fmt.Errorf("source type %q does not support change notifications", src.Type)
+	}
+	clientState, err := newClientState()
+	if err != nil {
+		return err
+	}
+
+	// The row is stored first: Graph validates the callback URL before it
+	// creates the subscription, and the URL must resolve by then.
+	sub, err := m.subs.Create(ctx, domain.SourceSubscription{
+		SourceID:    src.ID,
+		DomainID:    src.DomainID,
+		Provider:    provider.Type(),
+		ClientState: clientState,
+	})
+	if err != nil {
+		return err
+	}
+	registered, err := notifier.Subscribe(ctx, src, sub, m.CallbackURL(sub))
+	if err != nil {
+		m.deleteSubscription(ctx, sub.ID)
+		return err
+	}
+	if _, err := m.subs.Update(ctx, registered); err != nil {
+		_ = notifier.Unsubscribe(ctx, src, registered)
+		m.deleteSubscription(ctx, sub.ID)
+		return err
+	}
+	if err := m.subs.UpdateCursor(ctx, sub.ID, registered.Cursor); err != nil {
+		return err
+	}
+	slog.Info("subscriptions: subscribed source", "source_id", src.ID, "provider", provider.Type(), "subscription_id", sub.ID)
+	return nil
+}
+
+func (m *SubscriptionManager) renewExpiring(ctx context.Context) {
+	subs, err := m.subs.ListExpiring(ctx, m.now().Add(subscriptionRenewWindow))
+	if err != nil {
+		slog.Error("subscriptions: list expiring", "err", err)
+		return
+	}
+	for _, sub := range subs {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := m.renew(ctx, sub); err != nil {
+			// A fresh subscription is created on the next pass; until then
+			// the source is only picked up by scheduled syncs.
+			slog.Warn("subscriptions: renew", "subscription_id", sub.ID, "source_id", sub.SourceID, "err", err)
+		}
+	}
+}
+
+func (m *SubscriptionManager) renew(ctx context.Context, sub domain.SourceSubscription) error {
+	src, err := m.sources.GetByID(ctx, sub.SourceID, sub.DomainID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			m.deleteSubscription(ctx, sub.ID)
+			return nil
+		}
+		return err
+	}
+	notifier, ok := m.notifier(src.Type)
+	if !ok || !src.SyncEnabled {
+		if ok {
+			_ = notifier.Unsubscribe(ctx, src, sub)
+		}
+		m.deleteSubscription(ctx, sub.ID)
+		return nil
+	}
+
+	renewed, err := notifier.Renew(ctx, src, sub, m.CallbackURL(sub))
+	if err == nil {
+		_, err = m.subs.Update(ctx, renewed)
+		if err == nil {
+			return nil
+		}
+	}
+	_ = notifier.Unsubscribe(ctx, src, sub)
+	m.deleteSubscription(ctx, sub.ID)
+	return err
+}
+
+func (m *SubscriptionManager) deleteSubscription(ctx context.Context, id string) {
+	if err := m.subs.Delete(ctx, id); err != nil && !errors.Is(err, domain.ErrNotFound) {
+		slog.Error("subscriptions: delete", "subscription_id", id, "err", err)
+	}
+}
+
+// newClientState returns a random token providers echo back with every
+// notification.
+func newClientState() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate client state: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
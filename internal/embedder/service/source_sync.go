@@ -5,6 +5,7 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sort"
 	"strings"
@@ -87,24 +88,178 @@ func (s *sourceSyncService) Sync(ctx context.Context, id, domainID string) (res
 		Source:     src,
 		Discovered: uint64(len(files)),
 	}
-	liveExternalIDs := make(map[string]struct{}, len(files))
+	liveExternalIDs, err := s.upsertSourceFiles(ctx, src, files, &result)
+	if err != nil {
+		msg := err.Error()
+		updatedSource, markErr := s.sources.UpdateSyncResult(ctx, src.ID, src.DomainID, domain.SourceStatusError, now, &msg)
+		if markErr == nil {
+			src = updatedSource
+		}
+		return domain.SourceSyncResult{}, err
+	}
+
+	if provider.PrunesStaleRecords() {
+		toDelete, err := s.resolveStaleSourceExternalIDs(ctx, src.DomainID, src.ID, liveExternalIDs)
+		if err != nil {
+			msg := err.Error()
+			updatedSource, markErr := s.sources.UpdateSyncResult(ctx, src.ID, src.DomainID, domain.SourceStatusError, now, &msg)
+			if markErr == nil {
+				src = updatedSource
+			}
+			return domain.SourceSyncResult{}, err
+		}
+		deleted, err := s.records.DeleteBySourceExternalIDs(ctx, src.DomainID, src.ID, toDelete)
+		if err != nil {
+			msg := err.Error()
+			updatedSource, markErr := s.sources.UpdateSyncResult(ctx, src.ID, src.DomainID, domain.SourceStatusError, now, &msg)
+			if markErr == nil {
+				src = updatedSource
+			}
+			return domain.SourceSyncResult{}, err
+		}
+		result.Deleted = uint64(deleted)
+	}
+
+	updatedSource, updateErr := s.sources.UpdateSyncResult(ctx, src.ID, src.DomainID, domain.SourceStatusActive, now, nil)
+	if updateErr == nil {
+		result.Source = updatedSource
+	}
+
+	return result, nil
+}
+
+func (s *sourceSyncService) SyncChanges(
+	ctx context.Context,
+	sub domain.SourceSubscription,
+	hint domain.SourceChangeHint,
+) (res domain.SourceSyncResult, err error) {
+	src, err := s.sources.GetByID(ctx, sub.SourceID, sub.DomainID)
+	if err != nil {
+		return domain.SourceSyncResult{}, err
+	}
+	provider, ok := s.providers.Provider(src.Type)
+	if !ok {
+		return domain.SourceSyncResult{}, fmt.Errorf("source type %q does not support sync", src.Type)
+	}
+	notifier, ok := provider.(ingest.ChangeNotifier)
+	if !ok {
+		return domain.SourceSyncResult{}, fmt.Errorf("source type %q does not support change notifications", src.Type)
+	}
+
+	changes, err := notifier.Changes(ctx, src, sub, hint)
+	if errors.Is(err, ingest.ErrFullSyncRequired) {
+		return s.resync(ctx, notifier, src, sub)
+	}
+
+	syncStartedAt := time.Now().UTC()
+	defer func() {
+		embedmetrics.ObserveSourceSync(
+			string(src.Type),
+			string(provider.Type()),
+			time.Since(syncStartedAt),
+			err,
+		)
+		embedmetrics.AddSourceSyncFiles(
+			string(src.Type),
+			string(provider.Type()),
+			res.Discovered,
+			res.Queued,
+			res.Updated,
+			res.Unchanged,
+			res.Deleted,
+		)
+	}()
+
+	now := time.Now().UTC()
+	if err != nil {
+		msg := err.Error()
+		_, _ = s.sources.UpdateSyncResult(ctx, src.ID, src.DomainID, domain.SourceStatusError, now, &msg)
+		return domain.SourceSyncResult{}, err
+	}
 
+	result := domain.SourceSyncResult{
+		Source:     src,
+		Discovered: uint64(len(changes.Files)),
+		Cursor:     changes.Cursor,
+	}
+	if _, err := s.upsertSourceFiles(ctx, src, changes.Files, &result); err != nil {
+		msg := err.Error()
+		_, _ = s.sources.UpdateSyncResult(ctx, src.ID, src.DomainID, domain.SourceStatusError, now, &msg)
+		return domain.SourceSyncResult{}, err
+	}
+	// Providers that keep records of files that left the source keep them
+	// here too, matching a full sync.
+	if provider.PrunesStaleRecords() && len(changes.Removed) > 0 {
+		deleted, err := s.records.DeleteBySourceExternalIDs(ctx, src.DomainID, src.ID, changes.Removed)
+		if err != nil {
+			msg := err.Error()
+			_, _ = s.sources.UpdateSyncResult(ctx, src.ID, src.DomainID, domain.SourceStatusError, now, &msg)
+			return domain.SourceSyncResult{}, err
+		}
+		result.Deleted = uint64(deleted)
+	}
+
+	updatedSource, updateErr := s.sources.UpdateSyncResult(ctx, src.ID, src.DomainID, domain.SourceStatusActive, now, nil)
+	if updateErr == nil {
+		result.Source = updatedSource
+	}
+	return result, nil
+}
+
+// resync runs a full sync for a subscription whose change feed cannot be
+// resumed, then moves its cursor to the current position of the feed.
+func (s *sourceSyncService) resync(
+	ctx context.Context,
+	notifier ingest.ChangeNotifier,
+	src domain.Source,
+	sub domain.SourceSubscription,
+) (domain.SourceSyncResult, error) {
+	// Taking the position first means changes made during the full sync are
+	// read again rather than missed.
+	sub.Cursor = ""
+	changes, err := notifier.Changes(ctx, src, sub, domain.SourceChangeHint{})
+	if err != nil {
+		return domain.SourceSyncResult{}, err
+	}
+	result, err := s.Sync(ctx, src.ID, src.DomainID)
+	if err != nil {
+		return domain.SourceSyncResult{}, err
+	}
+	result.Cursor = changes.Cursor
+	return result, nil
+}
+
+// upsertSourceFiles queues records for files and tallies them into result.
+// It returns the external IDs it saw. Files listed with a parent get the
+// parent's record as theirs when the parent was listed before them.
+func (s *sourceSyncService) upsertSourceFiles(
+	ctx context.Context,
+	src domain.Source,
+	files []ingest.SourceFile,
+	result *domain.SourceSyncResult,
+) (map[string]struct{}, error) {
+	live := make(map[string]struct{}, len(files))
+	recordIDs := make(map[string]string, len(files))
 	for _, file := range files {
 		externalID := strings.TrimSpace(file.ExternalID)
 		if externalID == "" {
 			continue
 		}
-		liveExternalIDs[externalID] = struct{}{}
+		live[externalID] = struct{}{}
 		mimeType := ingest.NormalizeFileMIMEType(file.Name, file.MimeType)
 		mimeType = ingest.NormalizeFileMIMEType(externalID, mimeType)
 		mimeType = ingest.NormalizeFileMIMEType(file.ExternalRef, mimeType)
+		var parentID *string
+		if id, ok := recordIDs[strings.TrimSpace(file.ParentExternalID)]; ok {
+			parentID = &id
+		}
 
 		upsert, err := s.records.UpsertFromSource(ctx, domain.Record{
 			DomainID:         src.DomainID,
 			UserID:           src.UserID,
 			SourceID:         src.ID,
 			Name:             file.Name,
-			Format:           DetectRecordFormat(file.Name, mimeType),
+			Format:           ingest.DetectRecordFormat(file.Name, mimeType),
 			Status:           domain.RecordStatusQueued,
 			ExternalID:       externalID,
 			ExternalURL:      file.ExternalURL,
@@ -114,15 +269,12 @@ func (s *sourceSyncService) Sync(ctx context.Context, id, domainID string) (res
 			SourceModifiedAt: file.SourceModifiedAt,
 			FolderPath:       nonEmptyPtr(file.FolderPath),
 			FolderID:         nonEmptyPtr(file.FolderID),
+			ParentID:         parentID,
 		})
 		if err != nil {
-			msg := err.Error()
-			updatedSource, markErr := s.sources.UpdateSyncResult(ctx, src.ID, src.DomainID, domain.SourceStatusError, now, &msg)
-			if markErr == nil {
-				src = updatedSource
-			}
-			return domain.SourceSyncResult{}, err
+			return nil, err
 		}
+		recordIDs[externalID] = upsert.Record.ID
 
 		switch upsert.State {
 		case domain.RecordUpsertCreated:
@@ -134,35 +286,7 @@ func (s *sourceSyncService) Sync(ctx context.Context, id, domainID string) (res
 			result.Unchanged++
 		}
 	}
-
-	if provider.PrunesStaleRecords() {
-		toDelete, err := s.resolveStaleSourceExternalIDs(ctx, src.DomainID, src.ID, liveExternalIDs)
-		if err != nil {
-			msg := err.Error()
-			updatedSource, markErr := s.sources.UpdateSyncResult(ctx, src.ID, src.DomainID, domain.SourceStatusError, now, &msg)
-			if markErr == nil {
-				src = updatedSource
-			}
-			return domain.SourceSyncResult{}, err
-		}
-		deleted, err := s.records.DeleteBySourceExternalIDs(ctx, src.DomainID, src.ID, toDelete)
-		if err != nil {
-			msg := err.Error()
-			updatedSource, markErr := s.sources.UpdateSyncResult(ctx, src.ID, src.DomainID, domain.SourceStatusError, now, &msg)
-			if markErr == nil {
-				src = updatedSource
-			}
-			return domain.SourceSyncResult{}, err
-		}
-		result.Deleted = uint64(deleted)
-	}
-
-	updatedSource, updateErr := s.sources.UpdateSyncResult(ctx, src.ID, src.DomainID, domain.SourceStatusActive, now, nil)
-	if updateErr == nil {
-		result.Source = updatedSource
-	}
-
-	return result, nil
+	return live, nil
 }
 
 func (s *sourceSyncService) resolveStaleSourceExternalIDs(
@@ -175,6 +299,8 @@ func (s *sourceSyncService) resolveStaleSourceExternalIDs(
 	var offset uint64
 
 	stale := make([]string, 0)
+	var candidates []domain.Record
+	archives := make(map[string]struct{})
 	for {
 		page, err := s.records.List(ctx, domainID, filter, domain.Page{
 			Offset: offset,
@@ -184,6 +310,9 @@ func (s *sourceSyncService) resolveStaleSourceExternalIDs(
 			return nil, fmt.Errorf("list records by source for stale detection: %w", err)
 		}
 		for _, rec := range page.Records {
+			if rec.Format == domain.RecordFormatArchive {
+				archives[rec.ID] = struct{}{}
+			}
 			externalID := strings.TrimSpace(rec.ExternalID)
 			if externalID == "" {
 				continue
@@ -191,13 +320,23 @@ func (s *sourceSyncService) resolveStaleSourceExternalIDs(
 			if _, ok := live[externalID]; ok {
 				continue
 			}
-			stale = append(stale, externalID)
+			candidates = append(candidates, rec)
 		}
 		offset += uint64(len(page.Records))
 		if offset >= page.Total || len(page.Records) == 0 {
 			break
 		}
 	}
+	for _, rec := range candidates {
+		// Records unpacked from an archive are never listed by the
+		// provider; they go away with their archive.
+		if rec.ParentID != nil {
+			if _, ok := archives[*rec.ParentID]; ok {
+				continue
+			}
+		}
+		stale = append(stale, strings.TrimSpace(rec.ExternalID))
+	}
 
 	if len(stale) == 0 {
 		return nil, nil
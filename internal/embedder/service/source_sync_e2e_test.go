@@ -6,6 +6,7 @@ package service
 import (
 	"context"
 	"encoding/json"
+	"slices"
 	"testing"
 	"time"
 
@@ -163,6 +164,57 @@ func TestSourceSyncService_NormalizesMissingMIMEFromName(t *testing.T) {
 	}
 }
 
+func TestSourceSyncService_LinksAttachmentsToTheirParent(t *testing.T) {
+	source := domain.Source{
+		ID:       "src-wiki",
+		DomainID: "domain-1",
+		UserID:   "user-1",
+		Type:     domain.SourceTypeConfluence,
+		Name:     "Wiki",
+		Status:   domain.SourceStatusActive,
+	}
+	attachmentParent := "rec-page-1"
+	archiveParent := "rec-bundle"
+	records := &recordRepoSyncStub{existing: []domain.Record{
+		{ID: "rec-page-1", ExternalID: "page-1", Format: domain.RecordFormatText},
+		{ID: "rec-old", ExternalID: "att-old", Format: domain.RecordFormatPDF, ParentID: &attachmentParent},
+		{ID: "rec-bundle", ExternalID: "att-zip", Format: domain.RecordFormatArchive, ParentID: &attachmentParent},
+		{ID: "rec-entry", ExternalID: "att-zip!/a.txt", Format: domain.RecordFormatText, ParentID: &archiveParent},
+	}}
+	providers := ingest.NewSourceProviderRegistry(&staticSourceProvider{
+		providerType: domain.SourceTypeConfluence,
+		files: []ingest.SourceFile{
+			{ExternalID: "page-1", Name: "Page", MimeType: "text/plain", SourceVersion: "1"},
+			{ExternalID: "att-1", Name: "spec.pdf", SourceVersion: "1", ParentExternalID: "page-1"},
+			{ExternalID: "att-zip", Name: "bundle.zip", SourceVersion: "1", ParentExternalID: "page-1"},
+			{ExternalID: "att-2", Name: "orphan.pdf", SourceVersion: "1", ParentExternalID: "page-9"},
+		},
+		prunesStale: true,
+	})
+
+	svc := NewSourceSyncService(&sourceRepoSyncStub{source: source}, records, providers)
+	if _, err := svc.Sync(context.Background(), source.ID, source.DomainID); err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	parents := make(map[string]string)
+	for _, rec := range records.upserts {
+		if rec.ParentID != nil {
+			parents[rec.ExternalID] = *rec.ParentID
+		}
+	}
+	if parents["att-1"] != "rec-page-1" || parents["att-zip"] != "rec-page-1" {
+		t.Fatalf("attachment parents = %v, want rec-page-1", parents)
+	}
+	if _, ok := parents["att-2"]; ok {
+		t.Fatalf("attachment of an unlisted page got parent %q", parents["att-2"])
+	}
+	// Attachments that left their page are pruned; files unpacked from an
+	// archive are not.
+	if !slices.Equal(records.deleted, []string{"att-old"}) {
+		t.Fatalf("deleted = %v, want [att-old]", records.deleted)
+	}
+}
+
 type staticSourceProvider struct {
 	providerType domain.SourceType
 	files        []ingest.SourceFile
@@ -246,7 +298,9 @@ func (s *sourceRepoSyncStub) UpdateConfig(_ context.Context, id, domainID string
 }
 
 type recordRepoSyncStub struct {
-	upserts []domain.Record
+	upserts  []domain.Record
+	existing []domain.Record
+	deleted  []string
 }
 
 func (r *recordRepoSyncStub) Create(_ context.Context, rec domain.Record) (domain.Record, error) {
@@ -258,7 +312,7 @@ func (r *recordRepoSyncStub) GetByID(_ context.Context, _, _ string) (domain.Rec
 }
 
 func (r *recordRepoSyncStub) List(_ context.Context, _ string, _ domain.RecordFilter, _ domain.Page) (domain.RecordPage, error) {
-	return domain.RecordPage{Records: []domain.Record{}, Total: 0}, nil
+	return domain.RecordPage{Records: slices.Clone(r.existing), Total: uint64(len(r.existing))}, nil
 }
 
 func (r *recordRepoSyncStub) Delete(_ context.Context, _, _ string) error {
@@ -266,28 +320,44 @@ func (r *recordRepoSyncStub) Delete(_ context.Context, _, _ string) error {
 }
 
 func (r *recordRepoSyncStub) DeleteBySourceExternalIDs(_ context.Context, _, _ string, externalIDs []string) (int, error) {
+	r.deleted = append(r.deleted, externalIDs...)
 	return len(externalIDs), nil
 }
 
+func (r *recordRepoSyncStub) ArchiveIDs(context.Context, string, string, []string) ([]string, error) {
+	return nil, nil
+}
+
+func (r *recordRepoSyncStub) SourceVersions(_ context.Context, _, sourceID string, externalIDs []string) (map[string]string, error) {
+	versions := make(map[string]string)
+	for _, rec := range r.upserts {
+		if rec.SourceID == sourceID && slices.Contains(externalIDs, rec.ExternalID) {
+			versions[rec.ExternalID] = rec.SourceVersion
+		}
+	}
+	return versions, nil
+}
+
 func (r *recordRepoSyncStub) UpsertFromSource(_ context.Context, rec domain.Record) (domain.RecordUpsertResult, error) {
 	for _, existing := range r.upserts {
 		if existing.SourceID == rec.SourceID && existing.ExternalID == rec.ExternalID {
 			return domain.RecordUpsertResult{Record: rec, State: domain.RecordUpsertUnchanged}, nil
 		}
 	}
+	rec.ID = "rec-" + rec.ExternalID
 	r.upserts = append(r.upserts, rec)
 	return domain.RecordUpsertResult{Record: rec, State: domain.RecordUpsertCreated}, nil
 }
 
-func (r *recordRepoSyncStub) ListQueued(_ context.Context, _ int) ([]domain.Record, error) {
-	return nil, nil
+func (r *recordRepoSyncStub) UpdateStatus(_ context.Context, _ string, _ domain.RecordStatus, _ string) error {
+	return nil
 }
 
-func (r *recordRepoSyncStub) UpdateStatus(_ context.Context, _ string, _ domain.RecordStatus, _ string) error {
+func (r *recordRepoSyncStub) UpdateEnrichment(_ context.Context, _ string, _ *domain.RecordEnrichment) error {
 	return nil
 }
 
-func (r *recordRepoSyncStub) UpdateIngestProgress(_ context.Context, _ string, _, _ int) error {
+func (r *recordRepoSyncStub) UpdateIngestProgress(_ context.Context, _ string, _ domain.IngestProgress) error {
 	return nil
 }
 
@@ -5,23 +5,32 @@ package service
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"regexp"
 	"sort"
 	"strings"
+	"text/template"
 
 	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
 	"github.com/ultravioletrs/cube/internal/embedder/ingest/sourcepath"
+	gitsource "github.com/ultravioletrs/cube/internal/embedder/ingest/sources/git"
+	sqlsource "github.com/ultravioletrs/cube/internal/embedder/ingest/sources/sql"
 )
 
 type sourcesService struct {
-	repo domain.SourceRepository
+	repo  domain.SourceRepository
+	guard *ingest.NetworkGuard
 }
 
 // NewSourcesService returns a SourceService backed by the given repository.
-func NewSourcesService(repo domain.SourceRepository) domain.SourceService {
-	return &sourcesService{repo: repo}
+// SQL sources whose database host policy refuses are rejected.
+func NewSourcesService(repo domain.SourceRepository, policy domain.SourceNetworkPolicy) domain.SourceService {
+	return &sourcesService{repo: repo, guard: ingest.NewNetworkGuard(policy)}
 }
 
 func (s *sourcesService) Create(ctx context.Context, src domain.Source) (domain.Source, error) {
@@ -43,6 +52,9 @@ func (s *sourcesService) Create(ctx context.Context, src domain.Source) (domain.
 	if src.Config == nil {
 		src.Config = json.RawMessage("{}")
 	}
+	if err := rejectRedactedCredentials(src.Type, src.Config); err != nil {
+		return domain.Source{}, err
+	}
 	if src.Type == domain.SourceTypeGoogleDrive {
 		sanitized, err := sanitizeGoogleDriveConfig(src.Config)
 		if err != nil {
@@ -66,6 +78,54 @@ func (s *sourcesService) Create(ctx context.Context, src domain.Source) (domain.
 		}
 		src.Config = sanitized
 	}
+	if src.Type == domain.SourceTypeGit {
+		sanitized, err := sanitizeGitConfig(src.Config)
+		if err != nil {
+			return domain.Source{}, err
+		}
+		if err := s.checkGitHost(ctx, sanitized); err != nil {
+			return domain.Source{}, err
+		}
+		src.Config = sanitized
+	}
+	if src.Type == domain.SourceTypeConfluence {
+		sanitized, err := sanitizeConfluenceConfig(src.Config)
+		if err != nil {
+			return domain.Source{}, err
+		}
+		src.Config = sanitized
+	}
+	if src.Type == domain.SourceTypeIMAP {
+		sanitized, err := sanitizeIMAPConfig(src.Config)
+		if err != nil {
+			return domain.Source{}, err
+		}
+		src.Config = sanitized
+	}
+	if src.Type == domain.SourceTypeSQL {
+		sanitized, err := sanitizeSQLConfig(src.Config)
+		if err != nil {
+			return domain.Source{}, err
+		}
+		if err := s.checkSQLHost(ctx, sanitized); err != nil {
+			return domain.Source{}, err
+		}
+		src.Config = sanitized
+	}
+	if src.Type == domain.SourceTypeWebhook {
+		sanitized, err := sanitizeWebhookConfig(src.Config)
+		if err != nil {
+			return domain.Source{}, err
+		}
+		src.Config = sanitized
+	}
+	if src.Type == domain.SourceTypeWeb {
+		sanitized, err := sanitizeWebConfig(src.Config)
+		if err != nil {
+			return domain.Source{}, err
+		}
+		src.Config = sanitized
+	}
 	if src.Status == "" {
 		src.Status = domain.SourceStatusActive
 	}
@@ -104,19 +164,19 @@ func (s *sourcesService) UpdateGoogleDriveCredentials(
 		}
 	}
 
-	accessToken := normalizeOAuthValue(update.AccessToken)
+	accessToken := credentialUpdate(update.AccessToken)
 	if accessToken != "" {
 		cfg.AccessToken = accessToken
 	}
-	refreshToken := normalizeOAuthValue(update.RefreshToken)
+	refreshToken := credentialUpdate(update.RefreshToken)
 	if refreshToken != "" {
 		cfg.RefreshToken = refreshToken
 	}
-	clientID := normalizeOAuthValue(update.ClientID)
+	clientID := credentialUpdate(update.ClientID)
 	if clientID != "" {
 		cfg.ClientID = clientID
 	}
-	clientSecret := normalizeOAuthValue(update.ClientSecret)
+	clientSecret := credentialUpdate(update.ClientSecret)
 	if clientSecret != "" {
 		cfg.ClientSecret = clientSecret
 	}
@@ -162,6 +222,30 @@ func (s *sourcesService) UpdateGoogleDriveSelection(
 	return s.repo.UpdateConfig(ctx, id, domainID, raw)
 }
 
+func (s *sourcesService) RotateWebhookSecret(ctx context.Context, id, domainID string) (domain.Source, string, error) {
+	src, err := s.repo.GetByID(ctx, id, domainID)
+	if err != nil {
+		return domain.Source{}, "", err
+	}
+	if src.Type != domain.SourceTypeWebhook {
+		return domain.Source{}, "", fmt.Errorf("source type %q does not have a webhook secret", src.Type)
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return domain.Source{}, "", err
+	}
+	raw, err := json.Marshal(domain.WebhookConfig{Secret: secret})
+	if err != nil {
+		return domain.Source{}, "", fmt.Errorf("encode source config: %w", err)
+	}
+	updated, err := s.repo.UpdateConfig(ctx, id, domainID, raw)
+	if err != nil {
+		return domain.Source{}, "", err
+	}
+	return updated, secret, nil
+}
+
 func sanitizeGoogleDriveConfig(raw json.RawMessage) (json.RawMessage, error) {
 	var cfg domain.GoogleDriveConfig
 	if len(raw) > 0 {
@@ -186,6 +270,31 @@ func sanitizeGoogleDriveConfig(raw json.RawMessage) (json.RawMessage, error) {
 	return sanitized, nil
 }
 
+// credentialUpdate normalizes an updated credential. The redaction placeholder
+// echoed back from a source response means "keep the stored value".
+func credentialUpdate(value string) string {
+	value = normalizeOAuthValue(value)
+	if value == domain.RedactedSecret {
+		return ""
+	}
+	return value
+}
+
+// rejectRedactedCredentials refuses configs that carry the redaction
+// placeholder in place of a credential, which would otherwise be stored.
+func rejectRedactedCredentials(t domain.SourceType, raw json.RawMessage) error {
+	var doc map[string]any
+	if err := json.Unmarshal(raw, &doc); err != nil {
+		return nil
+	}
+	for _, field := range domain.SourceCredentialFields(t) {
+		if value, _ := doc[field].(string); strings.TrimSpace(value) == domain.RedactedSecret {
+			return fmt.Errorf("%s must be the credential value, not the redaction placeholder", field)
+		}
+	}
+	return nil
+}
+
 func normalizeOAuthValue(value string) string {
 	cleaned := strings.TrimSpace(value)
 	for {
@@ -382,3 +491,493 @@ func sanitizeMicrosoftConfig(raw json.RawMessage) (json.RawMessage, error) {
 	}
 	return sanitized, nil
 }
+
+const (
+	defaultWebMaxDepth = 3
+	defaultWebMaxPages = 500
+	maxWebMaxPages     = 20000
+	defaultWebRPS      = 2
+)
+
+func sanitizeWebConfig(raw json.RawMessage) (json.RawMessage, error) {
+	var cfg domain.WebConfig
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, fmt.Errorf("decode web config: %w", err)
+		}
+	}
+
+	seeds := make([]string, 0, len(cfg.SeedURLs))
+	hosts := make([]string, 0, len(cfg.AllowedHosts)+len(cfg.SeedURLs)+1)
+	for _, seed := range cfg.SeedURLs {
+		u, err := parseWebURL(seed)
+		if err != nil {
+			return nil, fmt.Errorf("invalid web seed_urls: %w", err)
+		}
+		seeds = append(seeds, u.String())
+		hosts = append(hosts, u.Host)
+	}
+	cfg.SeedURLs = seeds
+	if strings.TrimSpace(cfg.SitemapURL) != "" {
+		u, err := parseWebURL(cfg.SitemapURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid web sitemap_url: %w", err)
+		}
+		cfg.SitemapURL = u.String()
+		hosts = append(hosts, u.Host)
+	} else {
+		cfg.SitemapURL = ""
+	}
+	if len(cfg.SeedURLs) == 0 && cfg.SitemapURL == "" {
+		return nil, fmt.Errorf("web seed_urls or sitemap_url is required")
+	}
+
+	if len(cfg.AllowedHosts) > 0 {
+		hosts = hosts[:0]
+		for _, host := range cfg.AllowedHosts {
+			host = strings.ToLower(strings.TrimSpace(host))
+			if host == "" {
+				continue
+			}
+			if strings.ContainsAny(host, "/?#") {
+				return nil, fmt.Errorf("invalid web allowed_hosts entry %q: expected host[:port]", host)
+			}
+			hosts = append(hosts, host)
+		}
+	}
+	cfg.AllowedHosts = normalizeIDList(hosts)
+	cfg.ScopePaths = normalizeWebPaths(cfg.ScopePaths)
+	cfg.ExcludePaths = normalizeWebPaths(cfg.ExcludePaths)
+
+	depth := defaultWebMaxDepth
+	if cfg.MaxDepth != nil {
+		depth = *cfg.MaxDepth
+	}
+	if depth < 0 {
+		return nil, fmt.Errorf("web max_depth must not be negative")
+	}
+	cfg.MaxDepth = &depth
+	if cfg.MaxPages <= 0 {
+		cfg.MaxPages = defaultWebMaxPages
+	}
+	if cfg.MaxPages > maxWebMaxPages {
+		return nil, fmt.Errorf("web max_pages must be at most %d", maxWebMaxPages)
+	}
+	if cfg.RequestsPerSecond <= 0 {
+		cfg.RequestsPerSecond = defaultWebRPS
+	}
+	cfg.UserAgent = strings.TrimSpace(cfg.UserAgent)
+
+	sanitized, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("encode web config: %w", err)
+	}
+	return sanitized, nil
+}
+
+func parseWebURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%q must be an absolute http(s) URL", raw)
+	}
+	u.Host = strings.ToLower(u.Host)
+	u.Fragment = ""
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u, nil
+}
+
+func normalizeWebPaths(values []string) []string {
+	paths := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.Trim(strings.TrimSpace(value), "/")
+		if value == "" {
+			continue
+		}
+		paths = append(paths, "/"+value)
+	}
+	return normalizeIDList(paths)
+}
+
+const (
+	defaultGitMaxFileSize = 1 << 20
+	maxGitMaxFileSize     = 20 << 20
+)
+
+var (
+	scpLikeGitURL = regexp.MustCompile(`^[A-Za-z0-9._-]+@[A-Za-z0-9.-]+:[^/-][^\s]*$`)
+	gitBranchName = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+	gitForges     = map[string]struct{}{"github": {}, "gitlab": {}, "bitbucket": {}, "gitea": {}}
+)
+
+func sanitizeGitConfig(raw json.RawMessage) (json.RawMessage, error) {
+	var cfg domain.GitConfig
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, fmt.Errorf("decode git config: %w", err)
+		}
+	}
+
+	cfg.RepoURL = strings.TrimSpace(cfg.RepoURL)
+	if cfg.RepoURL == "" {
+		return nil, fmt.Errorf("git repo_url is required")
+	}
+	if !scpLikeGitURL.MatchString(cfg.RepoURL) {
+		u, err := url.Parse(cfg.RepoURL)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http" && u.Scheme != "ssh") {
+			return nil, fmt.Errorf("git repo_url must be an https://, ssh:// or user@host:path URL")
+		}
+		if u.User != nil {
+			if _, hasPassword := u.User.Password(); hasPassword {
+				return nil, fmt.Errorf("git repo_url must not embed a password; use token")
+			}
+		}
+	}
+
+	cfg.Branch = strings.TrimSpace(cfg.Branch)
+	if cfg.Branch != "" && (!gitBranchName.MatchString(cfg.Branch) ||
+		strings.HasPrefix(cfg.Branch, "-") || strings.Contains(cfg.Branch, "..")) {
+		return nil, fmt.Errorf("invalid git branch %q", cfg.Branch)
+	}
+	cfg.Include = normalizeGlobs(cfg.Include)
+	cfg.Exclude = normalizeGlobs(cfg.Exclude)
+	cfg.Username = strings.TrimSpace(cfg.Username)
+	cfg.Token = strings.TrimSpace(cfg.Token)
+	cfg.SSHKnownHosts = strings.TrimSpace(cfg.SSHKnownHosts)
+	if strings.TrimSpace(cfg.SSHPrivateKey) == "" {
+		cfg.SSHPrivateKey = ""
+	} else if !strings.HasSuffix(cfg.SSHPrivateKey, "\n") {
+		cfg.SSHPrivateKey += "\n"
+	}
+
+	cfg.WebURL = strings.TrimSuffix(strings.TrimSpace(cfg.WebURL), "/")
+	if cfg.WebURL != "" {
+		u, err := url.Parse(cfg.WebURL)
+		if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+			return nil, fmt.Errorf("git web_url must be an absolute http(s) URL")
+		}
+	}
+	cfg.Forge = strings.ToLower(strings.TrimSpace(cfg.Forge))
+	if _, ok := gitForges[cfg.Forge]; cfg.Forge != "" && !ok {
+		return nil, fmt.Errorf("git forge must be github, gitlab, bitbucket or gitea")
+	}
+
+	if cfg.MaxFileSize <= 0 {
+		cfg.MaxFileSize = defaultGitMaxFileSize
+	}
+	if cfg.MaxFileSize > maxGitMaxFileSize {
+		return nil, fmt.Errorf("git max_file_size must be at most %d bytes", maxGitMaxFileSize)
+	}
+
+	sanitized, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("encode git config: %w", err)
+	}
+	return sanitized, nil
+}
+
+func normalizeGlobs(values []string) []string {
+	globs := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "/")
+		if value != "" {
+			globs = append(globs, value)
+		}
+	}
+	if len(globs) == 0 {
+		return nil
+	}
+	return globs
+}
+
+var (
+	confluenceSpaceKey = regexp.MustCompile(`^~?[A-Za-z0-9_-]+$`)
+	confluencePageID   = regexp.MustCompile(`^[0-9]+$`)
+)
+
+func sanitizeConfluenceConfig(raw json.RawMessage) (json.RawMessage, error) {
+	var cfg domain.ConfluenceConfig
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, fmt.Errorf("decode confluence config: %w", err)
+		}
+	}
+
+	cfg.BaseURL = strings.TrimSuffix(strings.TrimSpace(cfg.BaseURL), "/")
+	u, err := url.Parse(cfg.BaseURL)
+	if err != nil || u.Host == "" || (u.Scheme != "https" && u.Scheme != "http") {
+		return nil, fmt.Errorf("confluence base_url must be an absolute http(s) URL")
+	}
+	if u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return nil, fmt.Errorf("confluence base_url must not carry credentials, a query or a fragment")
+	}
+
+	cfg.Email = strings.TrimSpace(cfg.Email)
+	cfg.APIToken = strings.TrimSpace(cfg.APIToken)
+	cfg.AccessToken = strings.TrimSpace(cfg.AccessToken)
+	switch {
+	case cfg.AccessToken != "" && cfg.APIToken != "":
+		return nil, fmt.Errorf("set either confluence access_token or email and api_token, not both")
+	case cfg.APIToken != "" && cfg.Email == "":
+		return nil, fmt.Errorf("confluence email is required with api_token")
+	case cfg.AccessToken == "" && cfg.APIToken == "":
+		return nil, fmt.Errorf("confluence access_token or email and api_token are required")
+	}
+
+	spaces := make([]string, 0, len(cfg.SpaceKeys))
+	for _, key := range cfg.SpaceKeys {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if !confluenceSpaceKey.MatchString(key) {
+			return nil, fmt.Errorf("invalid confluence space key %q", key)
+		}
+		spaces = append(spaces, key)
+	}
+	cfg.SpaceKeys = spaces
+
+	pages := make([]string, 0, len(cfg.PageIDs))
+	for _, id := range cfg.PageIDs {
+		id = strings.TrimSpace(id)
+		if id == "" {
+			continue
+		}
+		if !confluencePageID.MatchString(id) {
+			return nil, fmt.Errorf("invalid confluence page id %q", id)
+		}
+		pages = append(pages, id)
+	}
+	cfg.PageIDs = pages
+
+	sanitized, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("encode confluence config: %w", err)
+	}
+	return sanitized, nil
+}
+
+const (
+	defaultIMAPMaxAttachmentSize = 25 << 20
+	maxIMAPMaxAttachmentSize     = 100 << 20
+)
+
+var imapHost = regexp.MustCompile(`^[A-Za-z0-9.-]+$`)
+
+func sanitizeIMAPConfig(raw json.RawMessage) (json.RawMessage, error) {
+	var cfg domain.IMAPConfig
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, fmt.Errorf("decode imap config: %w", err)
+		}
+	}
+
+	cfg.Host = strings.ToLower(strings.TrimSpace(cfg.Host))
+	if cfg.Host == "" || !imapHost.MatchString(cfg.Host) {
+		return nil, fmt.Errorf("imap host must be a host name")
+	}
+	cfg.Security = strings.ToLower(strings.TrimSpace(cfg.Security))
+	switch cfg.Security {
+	case "":
+		cfg.Security = "tls"
+	case "tls", "starttls":
+	default:
+		// Plain-text IMAP would send the password in the clear.
+		return nil, fmt.Errorf("imap security must be tls or starttls")
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 993
+		if cfg.Security == "starttls" {
+			cfg.Port = 143
+		}
+	}
+	if cfg.Port < 1 || cfg.Port > 65535 {
+		return nil, fmt.Errorf("imap port must be between 1 and 65535")
+	}
+
+	cfg.Username = strings.TrimSpace(cfg.Username)
+	if cfg.Username == "" || cfg.Password == "" {
+		return nil, fmt.Errorf("imap username and password are required")
+	}
+
+	folders := make([]string, 0, len(cfg.Folders))
+	seen := make(map[string]struct{}, len(cfg.Folders))
+	for _, folder := range cfg.Folders {
+		folder = strings.TrimSpace(folder)
+		if folder == "" {
+			continue
+		}
+		if _, ok := seen[folder]; ok {
+			continue
+		}
+		seen[folder] = struct{}{}
+		folders = append(folders, folder)
+	}
+	if len(folders) == 0 {
+		folders = []string{"INBOX"}
+	}
+	cfg.Folders = folders
+
+	if cfg.SinceDays < 0 {
+		return nil, fmt.Errorf("imap since_days must not be negative")
+	}
+	if cfg.MaxAttachmentSize <= 0 {
+		cfg.MaxAttachmentSize = defaultIMAPMaxAttachmentSize
+	}
+	if cfg.MaxAttachmentSize > maxIMAPMaxAttachmentSize {
+		return nil, fmt.Errorf("imap max_attachment_size must be at most %d bytes", maxIMAPMaxAttachmentSize)
+	}
+
+	sanitized, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("encode imap config: %w", err)
+	}
+	return sanitized, nil
+}
+
+const (
+	maxSQLQueries     = 20
+	defaultSQLMaxRows = 100000
+	maxSQLMaxRows     = 1000000
+)
+
+var (
+	sqlQueryName  = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+	sqlColumnName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+)
+
+// checkSQLHost rejects SQL sources whose DSN connects to a host the network
+// policy refuses.
+func (s *sourcesService) checkSQLHost(ctx context.Context, raw json.RawMessage) error {
+	var cfg domain.SQLConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return fmt.Errorf("decode sql config: %w", err)
+	}
+	return sqlsource.CheckDSN(ctx, s.guard, cfg.Driver, cfg.DSN)
+}
+
+// checkGitHost rejects git sources whose remote is a host the network
+// policy refuses.
+func (s *sourcesService) checkGitHost(ctx context.Context, raw json.RawMessage) error {
+	var cfg domain.GitConfig
+	if err := json.Unmarshal(raw, &cfg); err != nil {
+		return fmt.Errorf("decode git config: %w", err)
+	}
+	return gitsource.CheckRemote(ctx, s.guard, cfg.RepoURL)
+}
+
+func sanitizeSQLConfig(raw json.RawMessage) (json.RawMessage, error) {
+	var cfg domain.SQLConfig
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, fmt.Errorf("decode sql config: %w", err)
+		}
+	}
+
+	cfg.Driver = strings.ToLower(strings.TrimSpace(cfg.Driver))
+	switch cfg.Driver {
+	case "postgres", "mysql":
+	default:
+		return nil, fmt.Errorf("sql driver must be postgres or mysql")
+	}
+	cfg.DSN = strings.TrimSpace(cfg.DSN)
+	if cfg.DSN == "" {
+		return nil, fmt.Errorf("sql dsn is required")
+	}
+	if len(cfg.Queries) == 0 || len(cfg.Queries) > maxSQLQueries {
+		return nil, fmt.Errorf("sql sources need between 1 and %d queries", maxSQLQueries)
+	}
+
+	names := make(map[string]struct{}, len(cfg.Queries))
+	for i := range cfg.Queries {
+		q := &cfg.Queries[i]
+		q.Name = strings.TrimSpace(q.Name)
+		if !sqlQueryName.MatchString(q.Name) {
+			return nil, fmt.Errorf("sql query name %q must use letters, digits, '-' or '_'", q.Name)
+		}
+		if _, ok := names[q.Name]; ok {
+			return nil, fmt.Errorf("duplicate sql query name %q", q.Name)
+		}
+		names[q.Name] = struct{}{}
+
+		// Rows are looked up by wrapping the query in a subquery, so it
+		// must be a single statement.
+		q.Query = strings.TrimSpace(strings.TrimRight(strings.TrimSpace(q.Query), ";"))
+		if q.Query == "" || strings.Contains(q.Query, ";") {
+			return nil, fmt.Errorf("sql query %q must be a single statement", q.Name)
+		}
+
+		q.IDColumn = strings.TrimSpace(q.IDColumn)
+		q.UpdatedAtColumn = strings.TrimSpace(q.UpdatedAtColumn)
+		q.TitleColumn = strings.TrimSpace(q.TitleColumn)
+		if !sqlColumnName.MatchString(q.IDColumn) {
+			return nil, fmt.Errorf("sql query %q needs an id_column", q.Name)
+		}
+		for _, col := range []string{q.UpdatedAtColumn, q.TitleColumn} {
+			if col != "" && !sqlColumnName.MatchString(col) {
+				return nil, fmt.Errorf("invalid column %q in sql query %q", col, q.Name)
+			}
+		}
+		for field, tmpl := range map[string]string{"content_template": q.ContentTemplate, "url_template": q.URLTemplate} {
+			if _, err := template.New(field).Parse(tmpl); err != nil {
+				return nil, fmt.Errorf("invalid %s in sql query %q: %w", field, q.Name, err)
+			}
+		}
+
+		if q.MaxRows == 0 {
+			q.MaxRows = defaultSQLMaxRows
+		}
+		if q.MaxRows < 0 || q.MaxRows > maxSQLMaxRows {
+			return nil, fmt.Errorf("sql max_rows must be between 1 and %d", maxSQLMaxRows)
+		}
+	}
+
+	sanitized, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("encode sql config: %w", err)
+	}
+	return sanitized, nil
+}
+
+const minWebhookSecretLength = 32
+
+// sanitizeWebhookConfig generates the signing secret unless the caller
+// brings one, e.g. to match a secret already configured in the sender.
+func sanitizeWebhookConfig(raw json.RawMessage) (json.RawMessage, error) {
+	var cfg domain.WebhookConfig
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			return nil, fmt.Errorf("decode webhook config: %w", err)
+		}
+	}
+
+	cfg.Secret = strings.TrimSpace(cfg.Secret)
+	switch {
+	case cfg.Secret == "":
+		secret, err := newWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		cfg.Secret = secret
+	case len(cfg.Secret) < minWebhookSecretLength:
+		return nil, fmt.Errorf("webhook secret must be at least %d characters", minWebhookSecretLength)
+	}
+
+	sanitized, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("encode webhook config: %w", err)
+	}
+	return sanitized, nil
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
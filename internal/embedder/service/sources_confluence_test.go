@@ -0,0 +1,56 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+func TestSanitizeConfluenceConfig(t *testing.T) {
+	t.Run("normalizes valid config", func(t *testing.T) {
+		raw, err := sanitizeConfluenceConfig(json.RawMessage(`{
+			"base_url":" https://acme.atlassian.net/wiki/ ",
+			"email":"bot@acme.com",
+			"api_token":"token",
+			"space_keys":["ENG"," ","~jdoe"],
+			"page_ids":[" 123 "]
+		}`))
+		if err != nil {
+			t.Fatalf("sanitizeConfluenceConfig returned error: %v", err)
+		}
+
+		var cfg domain.ConfluenceConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			t.Fatalf("decode sanitized config: %v", err)
+		}
+		if cfg.BaseURL != "https://acme.atlassian.net/wiki" {
+			t.Fatalf("unexpected base_url %q", cfg.BaseURL)
+		}
+		if len(cfg.SpaceKeys) != 2 || cfg.SpaceKeys[1] != "~jdoe" {
+			t.Fatalf("unexpected space_keys: %#v", cfg.SpaceKeys)
+		}
+		if len(cfg.PageIDs) != 1 || cfg.PageIDs[0] != "123" {
+			t.Fatalf("unexpected page_ids: %#v", cfg.PageIDs)
+		}
+	})
+
+	for name, cfg := range map[string]string{
+		"no base url":         `{"access_token":"pat"}`,
+		"relative base url":   `{"base_url":"/wiki","access_token":"pat"}`,
+		"no credentials":      `{"base_url":"https://wiki.example.com"}`,
+		"token without email": `{"base_url":"https://wiki.example.com","api_token":"t"}`,
+		"both credentials":    `{"base_url":"https://wiki.example.com","email":"a@b.c","api_token":"t","access_token":"pat"}`,
+		"cql in space key":    `{"base_url":"https://wiki.example.com","access_token":"pat","space_keys":["ENG\") or space = (\"HR"]}`,
+		"non numeric page":    `{"base_url":"https://wiki.example.com","access_token":"pat","page_ids":["12 or 1=1"]}`,
+	} {
+		t.Run("rejects "+name, func(t *testing.T) {
+			if _, err := sanitizeConfluenceConfig(json.RawMessage(cfg)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
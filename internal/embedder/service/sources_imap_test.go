@@ -0,0 +1,72 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+func TestSanitizeIMAPConfig(t *testing.T) {
+	t.Run("applies defaults", func(t *testing.T) {
+		raw, err := sanitizeIMAPConfig(json.RawMessage(`{
+			"host":" IMAP.Example.com ",
+			"username":" bot@example.com ",
+			"password":"secret",
+			"folders":["INBOX"," ","Support","INBOX"]
+		}`))
+		if err != nil {
+			t.Fatalf("sanitizeIMAPConfig returned error: %v", err)
+		}
+
+		var cfg domain.IMAPConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			t.Fatalf("decode sanitized config: %v", err)
+		}
+		if cfg.Host != "imap.example.com" || cfg.Security != "tls" || cfg.Port != 993 {
+			t.Fatalf("unexpected connection settings: %+v", cfg)
+		}
+		if cfg.Username != "bot@example.com" {
+			t.Fatalf("unexpected username %q", cfg.Username)
+		}
+		if len(cfg.Folders) != 2 || cfg.Folders[1] != "Support" {
+			t.Fatalf("unexpected folders: %#v", cfg.Folders)
+		}
+		if cfg.MaxAttachmentSize != defaultIMAPMaxAttachmentSize {
+			t.Fatalf("unexpected max_attachment_size %d", cfg.MaxAttachmentSize)
+		}
+	})
+
+	t.Run("starttls defaults to port 143 and INBOX", func(t *testing.T) {
+		raw, err := sanitizeIMAPConfig(json.RawMessage(`{"host":"mail.example.com","security":"starttls","username":"u","password":"p"}`))
+		if err != nil {
+			t.Fatalf("sanitizeIMAPConfig returned error: %v", err)
+		}
+		var cfg domain.IMAPConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			t.Fatalf("decode sanitized config: %v", err)
+		}
+		if cfg.Port != 143 || len(cfg.Folders) != 1 || cfg.Folders[0] != "INBOX" {
+			t.Fatalf("unexpected config: %+v", cfg)
+		}
+	})
+
+	for name, cfg := range map[string]string{
+		"no host":             `{"username":"u","password":"p"}`,
+		"host with port":      `{"host":"mail.example.com:993","username":"u","password":"p"}`,
+		"plain text":          `{"host":"mail.example.com","security":"none","username":"u","password":"p"}`,
+		"no password":         `{"host":"mail.example.com","username":"u"}`,
+		"bad port":            `{"host":"mail.example.com","port":70000,"username":"u","password":"p"}`,
+		"negative since_days": `{"host":"mail.example.com","username":"u","password":"p","since_days":-1}`,
+		"huge attachments":    `{"host":"mail.example.com","username":"u","password":"p","max_attachment_size":1000000000}`,
+	} {
+		t.Run("rejects "+name, func(t *testing.T) {
+			if _, err := sanitizeIMAPConfig(json.RawMessage(cfg)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
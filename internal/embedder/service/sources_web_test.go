@@ -0,0 +1,75 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+func TestSanitizeWebConfig(t *testing.T) {
+	t.Run("normalizes valid config", func(t *testing.T) {
+		raw, err := sanitizeWebConfig(json.RawMessage(`{
+			"seed_urls":[" https://Docs.Example.com/guides#top ","https://docs.example.com"],
+			"scope_paths":["guides/","/guides"," "],
+			"exclude_paths":["/guides/archive/"]
+		}`))
+		if err != nil {
+			t.Fatalf("sanitizeWebConfig returned error: %v", err)
+		}
+
+		var cfg domain.WebConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			t.Fatalf("decode sanitized config: %v", err)
+		}
+		if len(cfg.SeedURLs) != 2 || cfg.SeedURLs[0] != "https://docs.example.com/guides" || cfg.SeedURLs[1] != "https://docs.example.com/" {
+			t.Fatalf("unexpected seed_urls: %#v", cfg.SeedURLs)
+		}
+		if len(cfg.AllowedHosts) != 1 || cfg.AllowedHosts[0] != "docs.example.com" {
+			t.Fatalf("expected allowed_hosts to default to seed hosts, got %#v", cfg.AllowedHosts)
+		}
+		if len(cfg.ScopePaths) != 1 || cfg.ScopePaths[0] != "/guides" {
+			t.Fatalf("unexpected scope_paths: %#v", cfg.ScopePaths)
+		}
+		if len(cfg.ExcludePaths) != 1 || cfg.ExcludePaths[0] != "/guides/archive" {
+			t.Fatalf("unexpected exclude_paths: %#v", cfg.ExcludePaths)
+		}
+		if cfg.MaxDepth == nil || *cfg.MaxDepth != defaultWebMaxDepth {
+			t.Fatalf("expected default max_depth, got %#v", cfg.MaxDepth)
+		}
+		if cfg.MaxPages != defaultWebMaxPages || cfg.RequestsPerSecond != defaultWebRPS {
+			t.Fatalf("expected default limits, got max_pages=%d rps=%v", cfg.MaxPages, cfg.RequestsPerSecond)
+		}
+	})
+
+	t.Run("keeps explicit zero depth", func(t *testing.T) {
+		raw, err := sanitizeWebConfig(json.RawMessage(`{"sitemap_url":"https://docs.example.com/sitemap.xml","max_depth":0}`))
+		if err != nil {
+			t.Fatalf("sanitizeWebConfig returned error: %v", err)
+		}
+		var cfg domain.WebConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			t.Fatalf("decode sanitized config: %v", err)
+		}
+		if cfg.MaxDepth == nil || *cfg.MaxDepth != 0 {
+			t.Fatalf("expected max_depth 0, got %#v", cfg.MaxDepth)
+		}
+	})
+
+	for name, cfg := range map[string]string{
+		"no seeds":         `{}`,
+		"relative seed":    `{"seed_urls":["/docs"]}`,
+		"non http sitemap": `{"sitemap_url":"ftp://docs.example.com/sitemap.xml"}`,
+		"negative depth":   `{"seed_urls":["https://docs.example.com"],"max_depth":-1}`,
+		"host with path":   `{"seed_urls":["https://docs.example.com"],"allowed_hosts":["docs.example.com/x"]}`,
+	} {
+		t.Run("rejects "+name, func(t *testing.T) {
+			if _, err := sanitizeWebConfig(json.RawMessage(cfg)); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+)
+
+func TestSanitizeWebhookConfig(t *testing.T) {
+	t.Run("generates a secret", func(t *testing.T) {
+		raw, err := sanitizeWebhookConfig(nil)
+		if err != nil {
+			t.Fatalf("sanitizeWebhookConfig returned error: %v", err)
+		}
+		var cfg domain.WebhookConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			t.Fatalf("decode sanitized config: %v", err)
+		}
+		if len(cfg.Secret) != 64 {
+			t.Fatalf("expected a 32-byte hex secret, got %q", cfg.Secret)
+		}
+	})
+
+	t.Run("keeps a provided secret", func(t *testing.T) {
+		raw, err := sanitizeWebhookConfig(json.RawMessage(`{"secret":" ` + testWebhookSecret + ` "}`))
+		if err != nil {
+			t.Fatalf("sanitizeWebhookConfig returned error: %v", err)
+		}
+		var cfg domain.WebhookConfig
+		if err := json.Unmarshal(raw, &cfg); err != nil {
+			t.Fatalf("decode sanitized config: %v", err)
+		}
+		if cfg.Secret != testWebhookSecret {
+			t.Fatalf("unexpected secret %q", cfg.Secret)
+		}
+	})
+
+	t.Run("rejects a short secret", func(t *testing.T) {
+		if _, err := sanitizeWebhookConfig(json.RawMessage(`{"secret":"short"}`)); err == nil {
+			t.Fatal("expected error")
+		}
+	})
+}
+
+func TestRotateWebhookSecret(t *testing.T) {
+	repo := &sourceRepoSyncStub{source: domain.Source{
+		ID:       "src-1",
+		DomainID: "domain-1",
+		Type:     domain.SourceTypeWebhook,
+		Config:   json.RawMessage(`{"secret":"` + testWebhookSecret + `"}`),
+	}}
+	svc := NewSourcesService(repo, domain.SourceNetworkPolicy{})
+
+	src, secret, err := svc.RotateWebhookSecret(context.Background(), "src-1", "domain-1")
+	if err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+	var cfg domain.WebhookConfig
+	if err := json.Unmarshal(src.Config, &cfg); err != nil {
+		t.Fatalf("decode config: %v", err)
+	}
+	if secret == testWebhookSecret || cfg.Secret != secret {
+		t.Fatalf("expected a new stored secret, got %q (stored %q)", secret, cfg.Secret)
+	}
+
+	repo.source.Type = domain.SourceTypeGit
+	if _, _, err := svc.RotateWebhookSecret(context.Background(), "src-1", "domain-1"); err == nil {
+		t.Fatal("expected rotation of a non-webhook source to fail")
+	}
+}
@@ -6,6 +6,7 @@ package service
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"strings"
 
 	"github.com/ultravioletrs/cube/internal/embedder/domain"
@@ -50,20 +51,33 @@ func (s *vectorRetrieveService) Retrieve(ctx context.Context, domainID, query st
 		topK = 5
 	}
 
-	emb, err := s.embedder.ForRecord(domain.Record{Format: domain.RecordFormatText})
-	if err != nil {
-		return nil, fmt.Errorf("get embedder: %w", err)
+	// Each profile stores vectors in its own embedding space, so the query is
+	// embedded once per distinct model and the per-profile rankings are fused
+	// with the keyword ranking in one query.
+	groups := s.embedder.SearchGroups()
+	vectors := make([]postgres.ProfileVector, 0, len(groups))
+	var embedErr error
+	for _, g := range groups {
+		vecs, err := g.Embed(ctx, []string{query})
+		if err == nil && len(vecs) == 0 {
+			err = fmt.Errorf("embedder returned no vectors")
+		}
+		if err != nil {
+			slog.Warn("retrieve: embed query", "profiles", g.Profiles, "err", err)
+			embedErr = err
+			continue
+		}
+		vectors = append(vectors, postgres.ProfileVector{Profiles: g.Profiles, Model: g.Model, Distance: g.Distance, Vector: vecs[0]})
 	}
-
-	vecs, err := emb.Embed(ctx, []string{query})
-	if err != nil {
-		return nil, fmt.Errorf("embed query: %w", err)
+	if len(vectors) == 0 && embedErr != nil {
+		return nil, fmt.Errorf("embed query: %w", embedErr)
 	}
 
-	results, err := s.chunks.HybridSearchChunks(ctx, domainID, vecs[0], domain.RetrievalQuery{
+	// Over-fetch so topK results remain once duplicates are collapsed.
+	results, err := s.chunks.HybridSearchChunks(ctx, domainID, vectors, domain.RetrievalQuery{
 		Query:     query,
 		RecordIDs: recordIDs,
-		TopK:      topK,
+		TopK:      topK * duplicateOverfetch,
 	})
 	if err != nil {
 		return nil, fmt.Errorf("search chunks: %w", err)
@@ -78,21 +92,52 @@ func (s *vectorRetrieveService) Retrieve(ctx context.Context, domainID, query st
 		if err != nil {
 			return nil, fmt.Errorf("search image embeddings: %w", err)
 		}
-		results = interleaveChunkResults(results, imageResults, topK)
+		results = interleaveChunkResults(results, imageResults, topK*duplicateOverfetch)
 	}
 
-	chunks := make([]domain.VectorChunk, len(results))
-	for i, r := range results {
-		chunks[i] = domain.VectorChunk{
+	return collapseDuplicateChunks(results, topK), nil
+}
+
+// duplicateOverfetch is how many more chunks than requested are searched for,
+// leaving room for the duplicates collapseDuplicateChunks removes.
+const duplicateOverfetch = 2
+
+// collapseDuplicateChunks returns up to topK results with identical passages
+// merged: the best-ranked copy is kept and the other records holding it are
+// listed as its duplicates.
+func collapseDuplicateChunks(results []postgres.ChunkSearchResult, topK int) []domain.VectorChunk {
+	chunks := make([]domain.VectorChunk, 0, min(len(results), topK))
+	byHash := make(map[string]int, len(results))
+	for _, r := range results {
+		hash := r.ContentHash
+		if hash == "" {
+			hash = domain.ContentHash(r.Content)
+		}
+		if i, ok := byHash[hash]; ok {
+			if chunks[i].RecordID != r.RecordID {
+				chunks[i].Duplicates = append(chunks[i].Duplicates, domain.ChunkRef{
+					RecordID:    r.RecordID,
+					RecordName:  r.RecordName,
+					ExternalURL: r.ExternalURL,
+					ChunkIndex:  r.ChunkIndex,
+				})
+			}
+			continue
+		}
+		if len(chunks) == topK {
+			continue
+		}
+		byHash[hash] = len(chunks)
+		chunks = append(chunks, domain.VectorChunk{
 			RecordID:    r.RecordID,
 			RecordName:  r.RecordName,
 			ExternalURL: r.ExternalURL,
 			ChunkIndex:  r.ChunkIndex,
 			Content:     r.Content,
 			Score:       r.Score,
-		}
+		})
 	}
-	return chunks, nil
+	return chunks
 }
 
 func hasVisualIntent(query string) bool {
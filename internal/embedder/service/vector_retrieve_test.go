@@ -0,0 +1,35 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"testing"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/postgres"
+)
+
+func TestCollapseDuplicateChunks(t *testing.T) {
+	results := []postgres.ChunkSearchResult{
+		{Content: "quarterly revenue grew", ContentHash: domain.ContentHash("quarterly revenue grew"), RecordID: "drive", RecordName: "report.pdf"},
+		{Content: "unrelated", RecordID: "drive", RecordName: "report.pdf", ChunkIndex: 1},
+		// Stored before hashes were recorded; matched by content.
+		{Content: "quarterly revenue grew", RecordID: "sharepoint", RecordName: "report (1).pdf", ChunkIndex: 4},
+		{Content: "third", RecordID: "other", RecordName: "notes.txt"},
+		{Content: "unrelated", RecordID: "upload", RecordName: "copy.pdf", ChunkIndex: 1},
+	}
+
+	got := collapseDuplicateChunks(results, 2)
+	if len(got) != 2 {
+		t.Fatalf("got %d chunks, want 2", len(got))
+	}
+	if got[0].RecordID != "drive" || len(got[0].Duplicates) != 1 || got[0].Duplicates[0].RecordID != "sharepoint" ||
+		got[0].Duplicates[0].ChunkIndex != 4 {
+		t.Fatalf("first chunk = %+v, want drive with sharepoint duplicate", got[0])
+	}
+	// Duplicates of kept chunks are gathered even past topK.
+	if got[1].Content != "unrelated" || len(got[1].Duplicates) != 1 || got[1].Duplicates[0].RecordID != "upload" {
+		t.Fatalf("second chunk = %+v, want unrelated with upload duplicate", got[1])
+	}
+}
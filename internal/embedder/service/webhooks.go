@@ -0,0 +1,283 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/webhook"
+	objstore "github.com/ultravioletrs/cube/internal/embedder/storage"
+)
+
+const (
+	// WebhookSignatureTolerance bounds the clock skew between the sender and
+	// the embedder, and how long a captured request can be replayed.
+	WebhookSignatureTolerance = 5 * time.Minute
+
+	maxWebhookDocuments     = 500
+	maxWebhookDocumentBytes = 32 << 20
+	maxWebhookIDLength      = 1024
+)
+
+type webhookService struct {
+	sources domain.SourceRepository
+	records domain.RecordRepository
+	store   objstore.Store
+	now     func() time.Time
+}
+
+// NewWebhookService creates the service behind webhook ingest URLs.
+// Documents are kept in store until they are ingested and re-ingested.
+func NewWebhookService(
+	sources domain.SourceRepository,
+	records domain.RecordRepository,
+	store objstore.Store,
+) domain.WebhookService {
+	return &webhookService{sources: sources, records: records, store: store, now: time.Now}
+}
+
+// SignWebhook returns the signature header value for a webhook body sent
+// at timestamp (Unix seconds): "sha256=" followed by the hex HMAC-SHA256 of
+// "<timestamp>.<body>" keyed with the source secret.
+func SignWebhook(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func (s *webhookService) Lookup(ctx context.Context, id, domainID, timestamp string) (domain.Source, error) {
+	src, err := s.sources.GetByID(ctx, id, domainID)
+	if err != nil {
+		if errors.Is(err, domain.ErrNotFound) {
+			// Do not reveal which source IDs exist.
+			return domain.Source{}, domain.ErrInvalidSignature
+		}
+		return domain.Source{}, err
+	}
+	if src.Type != domain.SourceTypeWebhook {
+		return domain.Source{}, domain.ErrInvalidSignature
+	}
+	var cfg domain.WebhookConfig
+	if err := json.Unmarshal(src.Config, &cfg); err != nil || cfg.Secret == "" {
+		return domain.Source{}, domain.ErrInvalidSignature
+	}
+
+	sent, err := strconv.ParseInt(strings.TrimSpace(timestamp), 10, 64)
+	if err != nil {
+		return domain.Source{}, domain.ErrInvalidSignature
+	}
+	if skew := s.now().Sub(time.Unix(sent, 0)); skew > WebhookSignatureTolerance || skew < -WebhookSignatureTolerance {
+		return domain.Source{}, domain.ErrInvalidSignature
+	}
+	return src, nil
+}
+
+func (s *webhookService) Verify(src domain.Source, timestamp, signature string, body []byte) error {
+	var cfg domain.WebhookConfig
+	if err := json.Unmarshal(src.Config, &cfg); err != nil || cfg.Secret == "" {
+		return domain.ErrInvalidSignature
+	}
+	want := SignWebhook(cfg.Secret, strings.TrimSpace(timestamp), body)
+	if !hmac.Equal([]byte(want), []byte(strings.TrimSpace(signature))) {
+		return domain.ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *webhookService) Ingest(
+	ctx context.Context,
+	src domain.Source,
+	batch domain.WebhookBatch,
+) (domain.WebhookIngestResult, error) {
+	if s.store == nil {
+		return domain.WebhookIngestResult{}, fmt.Errorf("object storage is not configured")
+	}
+	if len(batch.Documents)+len(batch.Deleted) == 0 {
+		return domain.WebhookIngestResult{}, fmt.Errorf("%w: batch has no documents or deletions", domain.ErrInvalidWebhookBatch)
+	}
+	if len(batch.Documents) > maxWebhookDocuments || len(batch.Deleted) > maxWebhookDocuments {
+		return domain.WebhookIngestResult{}, fmt.Errorf("%w: batches are limited to %d documents and %d deletions",
+			domain.ErrInvalidWebhookBatch, maxWebhookDocuments, maxWebhookDocuments)
+	}
+
+	// Validate the whole batch first so a bad document does not leave it
+	// half applied.
+	records := make([]domain.Record, 0, len(batch.Documents))
+	objects := make([]webhook.Object, 0, len(batch.Documents))
+	seen := make(map[string]struct{}, len(batch.Documents))
+	for i, doc := range batch.Documents {
+		rec, obj, err := webhookRecord(src, doc)
+		if err != nil {
+			return domain.WebhookIngestResult{}, fmt.Errorf("%w: document %d: %w", domain.ErrInvalidWebhookBatch, i, err)
+		}
+		if _, ok := seen[rec.ExternalID]; ok {
+			return domain.WebhookIngestResult{}, fmt.Errorf("%w: document %d: duplicate id %q", domain.ErrInvalidWebhookBatch, i, rec.ExternalID)
+		}
+		seen[rec.ExternalID] = struct{}{}
+		records = append(records, rec)
+		objects = append(objects, obj)
+	}
+	deleted := make([]string, 0, len(batch.Deleted))
+	for _, id := range batch.Deleted {
+		id = strings.TrimSpace(id)
+		if id == "" || len(id) > maxWebhookIDLength {
+			return domain.WebhookIngestResult{}, fmt.Errorf("%w: invalid deleted id %q", domain.ErrInvalidWebhookBatch, id)
+		}
+		if _, ok := seen[id]; ok {
+			return domain.WebhookIngestResult{}, fmt.Errorf("%w: id %q is both upserted and deleted", domain.ErrInvalidWebhookBatch, id)
+		}
+		deleted = append(deleted, id)
+	}
+
+	ids := make([]string, len(records))
+	for i, rec := range records {
+		ids[i] = rec.ExternalID
+	}
+	versions, err := s.records.SourceVersions(ctx, src.DomainID, src.ID, ids)
+	if err != nil {
+		return domain.WebhookIngestResult{}, fmt.Errorf("read document versions: %w", err)
+	}
+
+	var res domain.WebhookIngestResult
+	for i, rec := range records {
+		// A resent version is a no-op; in particular a replayed request
+		// does not overwrite the stored document.
+		if version, ok := versions[rec.ExternalID]; ok && version == rec.SourceVersion {
+			res.Unchanged++
+			continue
+		}
+		// The object is written before the record is queued so the worker
+		// never sees a record without content.
+		raw, err := json.Marshal(objects[i])
+		if err != nil {
+			return res, fmt.Errorf("encode document %q: %w", rec.ExternalID, err)
+		}
+		if err := s.store.Put(ctx, rec.ExternalRef, "application/json", int64(len(raw)), bytes.NewReader(raw)); err != nil {
+			return res, fmt.Errorf("store document %q: %w", rec.ExternalID, err)
+		}
+		upsert, err := s.records.UpsertFromSource(ctx, rec)
+		if err != nil {
+			return res, fmt.Errorf("upsert document %q: %w", rec.ExternalID, err)
+		}
+		switch upsert.State {
+		case domain.RecordUpsertCreated:
+			res.Created++
+		case domain.RecordUpsertUpdated:
+			res.Updated++
+		case domain.RecordUpsertUnchanged:
+			res.Unchanged++
+		}
+	}
+
+	if len(deleted) > 0 {
+		n, err := s.records.DeleteBySourceExternalIDs(ctx, src.DomainID, src.ID, deleted)
+		if err != nil {
+			return res, fmt.Errorf("delete documents: %w", err)
+		}
+		res.Deleted = uint64(n)
+		for _, id := range deleted {
+			if err := s.store.Delete(ctx, webhook.ObjectKey(src.ID, id)); err != nil {
+				return res, fmt.Errorf("delete document %q: %w", id, err)
+			}
+		}
+	}
+
+	// Pushes count as syncs so the source shows when it last received data.
+	_, _ = s.sources.UpdateSyncResult(ctx, src.ID, src.DomainID, domain.SourceStatusActive, s.now().UTC(), nil)
+	return res, nil
+}
+
+func webhookRecord(src domain.Source, doc domain.WebhookDocument) (domain.Record, webhook.Object, error) {
+	id := strings.TrimSpace(doc.ID)
+	if id == "" || len(id) > maxWebhookIDLength {
+		return domain.Record{}, webhook.Object{}, fmt.Errorf("id is required and at most %d bytes", maxWebhookIDLength)
+	}
+
+	content := doc.Content
+	mimeType := strings.ToLower(strings.TrimSpace(doc.MimeType))
+	switch {
+	case doc.Text != "" && len(doc.Content) > 0:
+		return domain.Record{}, webhook.Object{}, fmt.Errorf("document %q sets both text and content", id)
+	case doc.Text != "":
+		content = []byte(doc.Text)
+		switch mimeType {
+		case "":
+			mimeType = "text/plain"
+		case "text/plain", "text/markdown", "text/html":
+		default:
+			return domain.Record{}, webhook.Object{}, fmt.Errorf("text of document %q must be text/plain, text/markdown or text/html", id)
+		}
+	case len(doc.Content) == 0:
+		return domain.Record{}, webhook.Object{}, fmt.Errorf("document %q has no text or content", id)
+	}
+	if len(content) > maxWebhookDocumentBytes {
+		return domain.Record{}, webhook.Object{}, fmt.Errorf("document %q exceeds %d bytes", id, maxWebhookDocumentBytes)
+	}
+
+	name := strings.TrimSpace(doc.Title)
+	if name == "" {
+		name = id
+	}
+	mimeType = ingest.NormalizeFileMIMEType(name, mimeType)
+	format := ingest.DetectRecordFormat(name, mimeType)
+	if format == domain.RecordFormatLink {
+		return domain.Record{}, webhook.Object{}, fmt.Errorf("content type %q of document %q is not supported", mimeType, id)
+	}
+
+	version := strings.TrimSpace(doc.Version)
+	if version == "" {
+		sum := sha256.New()
+		sum.Write(content)
+		for _, key := range sortedKeys(doc.Metadata) {
+			fmt.Fprintf(sum, "\x00%s\x00%s", key, doc.Metadata[key])
+		}
+		version = hex.EncodeToString(sum.Sum(nil))[:32]
+	}
+
+	folderPath := strings.TrimSpace(doc.FolderPath)
+	if folderPath != "" && !strings.HasPrefix(folderPath, "/") {
+		folderPath = "/" + folderPath
+	}
+
+	rec := domain.Record{
+		DomainID:         src.DomainID,
+		UserID:           src.UserID,
+		SourceID:         src.ID,
+		Name:             name,
+		Format:           format,
+		Status:           domain.RecordStatusQueued,
+		ExternalID:       id,
+		ExternalURL:      strings.TrimSpace(doc.URL),
+		ExternalRef:      webhook.ObjectKey(src.ID, id),
+		MimeType:         mimeType,
+		SourceVersion:    version,
+		SourceModifiedAt: doc.ModifiedAt,
+		FolderPath:       nonEmptyPtr(folderPath),
+	}
+	return rec, webhook.Object{Metadata: doc.Metadata, Content: content}, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,223 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ultravioletrs/cube/internal/embedder/domain"
+	"github.com/ultravioletrs/cube/internal/embedder/ingest/sources/webhook"
+	objstore "github.com/ultravioletrs/cube/internal/embedder/storage"
+)
+
+const testWebhookSecret = "0123456789abcdef0123456789abcdef"
+
+// webhookRecordStub tracks records by external ID and reports them
+// unchanged only when the version matches, like the Postgres repository.
+type webhookRecordStub struct {
+	recordRepoSyncStub
+	byID    map[string]domain.Record
+	deleted []string
+}
+
+func (r *webhookRecordStub) UpsertFromSource(_ context.Context, rec domain.Record) (domain.RecordUpsertResult, error) {
+	existing, ok := r.byID[rec.ExternalID]
+	r.byID[rec.ExternalID] = rec
+	switch {
+	case !ok:
+		return domain.RecordUpsertResult{Record: rec, State: domain.RecordUpsertCreated}, nil
+	case existing.SourceVersion != rec.SourceVersion:
+		return domain.RecordUpsertResult{Record: rec, State: domain.RecordUpsertUpdated}, nil
+	default:
+		return domain.RecordUpsertResult{Record: rec, State: domain.RecordUpsertUnchanged}, nil
+	}
+}
+
+func (r *webhookRecordStub) ArchiveIDs(context.Context, string, string, []string) ([]string, error) {
+	return nil, nil
+}
+
+func (r *webhookRecordStub) SourceVersions(_ context.Context, _, _ string, externalIDs []string) (map[string]string, error) {
+	versions := make(map[string]string)
+	for _, id := range externalIDs {
+		if rec, ok := r.byID[id]; ok {
+			versions[id] = rec.SourceVersion
+		}
+	}
+	return versions, nil
+}
+
+func (r *webhookRecordStub) DeleteBySourceExternalIDs(_ context.Context, _, _ string, externalIDs []string) (int, error) {
+	n := 0
+	for _, id := range externalIDs {
+		if _, ok := r.byID[id]; ok {
+			delete(r.byID, id)
+			n++
+		}
+	}
+	r.deleted = append(r.deleted, externalIDs...)
+	return n, nil
+}
+
+func newWebhookTestService(t *testing.T, now time.Time) (*webhookService, *sourceRepoSyncStub, *webhookRecordStub, objstore.Store) {
+	t.Helper()
+	store, err := objstore.NewStore(objstore.Config{Provider: objstore.ProviderLocal, LocalDir: t.TempDir()})
+	if err != nil {
+		t.Fatalf("create store: %v", err)
+	}
+	sources := &sourceRepoSyncStub{source: domain.Source{
+		ID:       "src-1",
+		DomainID: "domain-1",
+		UserID:   "user-1",
+		Type:     domain.SourceTypeWebhook,
+		Config:   []byte(`{"secret":"` + testWebhookSecret + `"}`),
+	}}
+	records := &webhookRecordStub{byID: make(map[string]domain.Record)}
+	svc := NewWebhookService(sources, records, store).(*webhookService)
+	svc.now = func() time.Time { return now }
+	return svc, sources, records, store
+}
+
+func TestWebhookServiceAuthenticate(t *testing.T) {
+	now := time.Unix(1_800_000_000, 0)
+	svc, _, _, _ := newWebhookTestService(t, now)
+	body := []byte(`{"documents":[]}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+
+	authenticate := func(id, domainID, ts, sig string) (domain.Source, error) {
+		src, err := svc.Lookup(context.Background(), id, domainID, ts)
+		if err != nil {
+			return domain.Source{}, err
+		}
+		return src, svc.Verify(src, ts, sig, body)
+	}
+
+	src, err := authenticate("src-1", "domain-1", ts, SignWebhook(testWebhookSecret, ts, body))
+	if err != nil || src.ID != "src-1" {
+		t.Fatalf("expected valid signature to authenticate, got %v", err)
+	}
+
+	stale := strconv.FormatInt(now.Add(-10*time.Minute).Unix(), 10)
+	for name, tc := range map[string]struct {
+		id, domainID, ts, sig string
+	}{
+		"wrong secret":     {"src-1", "domain-1", ts, SignWebhook("another-secret", ts, body)},
+		"tampered body":    {"src-1", "domain-1", ts, SignWebhook(testWebhookSecret, ts, []byte(`{}`))},
+		"stale timestamp":  {"src-1", "domain-1", stale, SignWebhook(testWebhookSecret, stale, body)},
+		"missing headers":  {"src-1", "domain-1", "", ""},
+		"unknown source":   {"src-2", "domain-1", ts, SignWebhook(testWebhookSecret, ts, body)},
+		"different domain": {"src-1", "domain-2", ts, SignWebhook(testWebhookSecret, ts, body)},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := authenticate(tc.id, tc.domainID, tc.ts, tc.sig)
+			if !errors.Is(err, domain.ErrInvalidSignature) {
+				t.Fatalf("expected ErrInvalidSignature, got %v", err)
+			}
+		})
+	}
+}
+
+func TestWebhookServiceIngest(t *testing.T) {
+	svc, sources, records, store := newWebhookTestService(t, time.Now())
+	ctx := context.Background()
+	src := sources.source
+
+	batch := domain.WebhookBatch{Documents: []domain.WebhookDocument{
+		{
+			ID:       "kb-1",
+			Title:    "Reset a password",
+			Text:     "Open settings and choose reset.",
+			URL:      "https://help.example.com/kb-1",
+			Metadata: map[string]string{"product": "portal"},
+			Version:  "3",
+		},
+		{ID: "notes.md", Content: []byte("# Notes\n\nShip it."), FolderPath: "team/notes"},
+	}}
+	res, err := svc.Ingest(ctx, src, batch)
+	if err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if res.Created != 2 {
+		t.Fatalf("expected 2 created, got %+v", res)
+	}
+	kb := records.byID["kb-1"]
+	if kb.Name != "Reset a password" || kb.SourceVersion != "3" || kb.Format != domain.RecordFormatText || kb.ExternalURL == "" {
+		t.Fatalf("unexpected record: %+v", kb)
+	}
+	notes := records.byID["notes.md"]
+	if notes.Format != domain.RecordFormatMD || notes.FolderPath == nil || *notes.FolderPath != "/team/notes" || notes.SourceVersion == "" {
+		t.Fatalf("unexpected record: %+v", notes)
+	}
+	if sources.source.LastSyncAt == nil {
+		t.Fatal("expected the push to be recorded as a sync")
+	}
+
+	text, _, err := webhook.NewSourceProvider(store).DownloadRecord(ctx, kb, src)
+	if err != nil {
+		t.Fatalf("download: %v", err)
+	}
+	if text != "product: portal\n\nOpen settings and choose reset." {
+		t.Fatalf("unexpected stored text %q", text)
+	}
+
+	res, err = svc.Ingest(ctx, src, batch)
+	if err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+	if res.Unchanged != 2 || res.Created+res.Updated != 0 {
+		t.Fatalf("expected a replay to change nothing, got %+v", res)
+	}
+
+	// A resent version does not overwrite the stored document.
+	resent := domain.WebhookBatch{Documents: []domain.WebhookDocument{{ID: "kb-1", Text: "Something else.", Version: "3"}}}
+	if res, err := svc.Ingest(ctx, src, resent); err != nil || res.Unchanged != 1 {
+		t.Fatalf("resend: %+v %v", res, err)
+	}
+	if text, _, err := webhook.NewSourceProvider(store).DownloadRecord(ctx, kb, src); err != nil || text != "product: portal\n\nOpen settings and choose reset." {
+		t.Fatalf("expected the stored document to be kept, got %q %v", text, err)
+	}
+
+	batch.Documents = batch.Documents[:1]
+	batch.Documents[0].Version = "4"
+	batch.Deleted = []string{"notes.md", "never-sent"}
+	res, err = svc.Ingest(ctx, src, batch)
+	if err != nil {
+		t.Fatalf("update: %v", err)
+	}
+	if res.Updated != 1 || res.Deleted != 1 {
+		t.Fatalf("expected 1 updated and 1 deleted, got %+v", res)
+	}
+	if _, err := store.Get(ctx, webhook.ObjectKey(src.ID, "notes.md")); err == nil {
+		t.Fatal("expected the deleted document's object to be removed")
+	}
+}
+
+func TestWebhookServiceIngestRejectsInvalidBatches(t *testing.T) {
+	svc, sources, records, _ := newWebhookTestService(t, time.Now())
+
+	for name, batch := range map[string]domain.WebhookBatch{
+		"empty":              {},
+		"missing id":         {Documents: []domain.WebhookDocument{{Text: "x"}}},
+		"no body":            {Documents: []domain.WebhookDocument{{ID: "a"}}},
+		"text and content":   {Documents: []domain.WebhookDocument{{ID: "a", Text: "x", Content: []byte("y")}}},
+		"binary text type":   {Documents: []domain.WebhookDocument{{ID: "a", Text: "x", MimeType: "application/pdf"}}},
+		"unsupported type":   {Documents: []domain.WebhookDocument{{ID: "a", Content: []byte("x"), MimeType: "application/octet-stream"}}},
+		"duplicate id":       {Documents: []domain.WebhookDocument{{ID: "a", Text: "x"}, {ID: "a", Text: "y"}}},
+		"upsert and delete":  {Documents: []domain.WebhookDocument{{ID: "a", Text: "x"}}, Deleted: []string{"a"}},
+		"valid then invalid": {Documents: []domain.WebhookDocument{{ID: "a", Text: "x"}, {ID: "b"}}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := svc.Ingest(context.Background(), sources.source, batch); !errors.Is(err, domain.ErrInvalidWebhookBatch) {
+				t.Fatalf("expected ErrInvalidWebhookBatch, got %v", err)
+			}
+			if len(records.byID) != 0 {
+				t.Fatalf("expected nothing to be written, got %+v", records.byID)
+			}
+		})
+	}
+}
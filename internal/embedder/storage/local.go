@@ -55,6 +55,14 @@ func (s *localStore) Get(_ context.Context, key string) ([]byte, error) {
 	return os.ReadFile(path)
 }
 
+func (s *localStore) Open(_ context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.resolve(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
 func (s *localStore) Delete(_ context.Context, key string) error {
 	path, err := s.resolve(key)
 	if err != nil {
@@ -66,6 +74,14 @@ func (s *localStore) Delete(_ context.Context, key string) error {
 	return nil
 }
 
+func (s *localStore) DeletePrefix(_ context.Context, prefix string) error {
+	dir, err := s.resolve(prefix)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(dir)
+}
+
 func (s *localStore) resolve(key string) (string, error) {
 	key = strings.TrimSpace(key)
 	if key == "" {
@@ -67,10 +67,48 @@ func (s *s3Store) Get(ctx context.Context, key string) ([]byte, error) {
 	return io.ReadAll(obj)
 }
 
+func (s *s3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+}
+
 func (s *s3Store) Delete(ctx context.Context, key string) error {
 	return s.client.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
 }
 
+func (s *s3Store) DeletePrefix(ctx context.Context, prefix string) error {
+	prefix = strings.TrimSuffix(strings.TrimSpace(prefix), "/")
+	if prefix == "" {
+		return fmt.Errorf("object key prefix is required")
+	}
+	var listErr error
+	objects := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objects)
+		for obj := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix + "/", Recursive: true}) {
+			if obj.Err != nil {
+				listErr = obj.Err
+				return
+			}
+			select {
+			case objects <- obj:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	// Drain every result so the listing goroutine is never left blocked.
+	var removeErr error
+	for res := range s.client.RemoveObjects(ctx, s.bucket, objects, minio.RemoveObjectsOptions{}) {
+		if res.Err != nil && removeErr == nil {
+			removeErr = res.Err
+		}
+	}
+	if removeErr != nil {
+		return removeErr
+	}
+	return listErr
+}
+
 func (s *s3Store) ensureBucket(ctx context.Context) error {
 	exists, err := s.client.BucketExists(ctx, s.bucket)
 	if err != nil {
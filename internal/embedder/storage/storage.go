@@ -19,7 +19,11 @@ const (
 type Store interface {
 	Put(ctx context.Context, key, contentType string, size int64, body io.Reader) error
 	Get(ctx context.Context, key string) ([]byte, error)
+	// Open streams an object; the caller closes the reader.
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
 	Delete(ctx context.Context, key string) error
+	// DeletePrefix removes every object whose key is below prefix.
+	DeletePrefix(ctx context.Context, prefix string) error
 }
 
 // Config describes how uploaded files are persisted.
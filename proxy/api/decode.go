@@ -13,13 +13,15 @@ import (
 
 	"github.com/go-chi/chi/v5"
 	"github.com/ultravioletrs/cube/internal/cubeauth"
+	"github.com/ultravioletrs/cube/proxy"
 	"github.com/ultravioletrs/cube/proxy/endpoint"
 	"github.com/ultravioletrs/cube/proxy/router"
 )
 
 var (
-	errInvalidRequestType = errors.New("invalid request type")
-	errRouteNameRequired  = errors.New("route name required")
+	errInvalidRequestType   = errors.New("invalid request type")
+	errRouteNameRequired    = errors.New("route name required")
+	errInvalidVerifyRequest = errors.New("invalid request body: expected {\"nonce\": \"<base64-32-bytes>\"}")
 )
 
 func decodeGetAttestationPolicyRequest(ctx context.Context, r *http.Request) (any, error) {
@@ -89,6 +91,63 @@ func encodeUpdateAttestationPolicyResponse(ctx context.Context, w http.ResponseW
 	return nil
 }
 
+func decodeVerifyAttestationRequest(ctx context.Context, r *http.Request) (any, error) {
+	session, err := decodeSession(ctx)
+	if err != nil {
+		return nil, errUnauthorized
+	}
+
+	if domainID := chi.URLParam(r, "domainID"); domainID != "" {
+		session.TenantID = domainID
+	}
+
+	var body struct {
+		Nonce []byte `json:"nonce"`
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		return nil, errInvalidVerifyRequest
+	}
+
+	return endpoint.VerifyAttestationRequest{
+		Session: &session,
+		Nonce:   body.Nonce,
+	}, nil
+}
+
+func encodeVerifyAttestationResponse(ctx context.Context, w http.ResponseWriter, response any) error {
+	resp, ok := response.(endpoint.VerifyAttestationResponse)
+	if !ok {
+		return errInvalidRequestType
+	}
+
+	if err := resp.Failed(); err != nil {
+		encodeVerifyAttestationError(ctx, err, w)
+
+		return nil
+	}
+
+	writeJSON(w, http.StatusOK, resp.Verdict)
+
+	return nil
+}
+
+// encodeVerifyAttestationError maps verification failures that happen before
+// a verdict can be produced. Policy mismatches are not errors; they are
+// reported in the verdict.
+func encodeVerifyAttestationError(ctx context.Context, err error, w http.ResponseWriter) {
+	switch {
+	case errors.Is(err, errInvalidVerifyRequest), errors.Is(err, proxy.ErrInvalidNonce):
+		writeJSON(w, http.StatusBadRequest, map[string]string{keyError: err.Error()})
+	case errors.Is(err, proxy.ErrAttestationPolicyMissing):
+		writeJSON(w, http.StatusConflict, map[string]string{keyError: err.Error()})
+	case errors.Is(err, proxy.ErrEvidenceUnavailable), errors.Is(err, proxy.ErrUnsupportedAttestation):
+		writeJSON(w, http.StatusBadGateway, map[string]string{keyError: err.Error()})
+	default:
+		encodeError(ctx, err, w)
+	}
+}
+
 func decodeCreateRouteRequest(ctx context.Context, r *http.Request) (any, error) {
 	session, err := decodeSession(ctx)
 	if err != nil {
@@ -100,6 +100,13 @@ func MakeHandler(
 			encodeGetAttestationPolicyResponse,
 		).ServeHTTP)
 
+		r.Post("/attestation/verify", kithttp.NewServer(
+			endpoints.VerifyAttestation,
+			decodeVerifyAttestationRequest,
+			encodeVerifyAttestationResponse,
+			kithttp.ServerErrorEncoder(encodeVerifyAttestationError),
+		).ServeHTTP)
+
 		// Proxy all other requests using the router
 		// When guardrails is enabled, /api/chat is routed to guardrails service via config.json
 		r.Handle("/*", makeProxyHandler(endpoints.ProxyRequest, proxyTransport, rter))
@@ -320,6 +327,9 @@ func serveReverseProxy(
 	prxy := &httputil.ReverseProxy{
 		Transport: transport,
 		Rewrite: func(req *httputil.ProxyRequest) {
+			// Upstreams such as the agent key rate limits by the caller.
+			req.SetXForwarded()
+
 			domainID := chi.URLParam(req.In, "domainID")
 			prepareProxyRequest(req.Out, target, rule, domainID, stripPrefix, session)
 		},
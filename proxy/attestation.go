@@ -0,0 +1,477 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha3"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/go-sev-guest/abi"
+	"github.com/google/go-sev-guest/proto/check"
+	"github.com/google/go-sev-guest/proto/sevsnp"
+	tdxabi "github.com/google/go-tdx-guest/abi"
+	"github.com/google/go-tdx-guest/proto/checkconfig"
+	"github.com/google/go-tdx-guest/proto/tdx"
+	"github.com/google/go-tpm-tools/proto/attest"
+	"github.com/ultravioletrs/cocos/pkg/attestation"
+	"github.com/ultravioletrs/cocos/pkg/attestation/azure"
+	cocostdx "github.com/ultravioletrs/cocos/pkg/attestation/tdx"
+	"github.com/ultravioletrs/cocos/pkg/attestation/vtpm"
+	"github.com/ultravioletrs/cube/agent"
+	"github.com/ultravioletrs/cube/internal/cubeauth"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// evidenceNonceSize is the length of verifier nonces accepted by the agent.
+const evidenceNonceSize = 32
+
+// maxEvidenceSize bounds the agent response read during verification.
+const maxEvidenceSize = 1 << 20
+
+var (
+	// ErrInvalidNonce indicates that the client nonce is not 32 bytes.
+	ErrInvalidNonce = fmt.Errorf("nonce must be %d bytes", evidenceNonceSize)
+	// ErrAttestationPolicyMissing indicates that no attestation policy is stored.
+	ErrAttestationPolicyMissing = errors.New("no attestation policy configured")
+	// ErrEvidenceUnavailable indicates that the agent did not return evidence.
+	ErrEvidenceUnavailable = errors.New("attestation evidence unavailable")
+	// ErrUnsupportedAttestation indicates an attestation type the proxy cannot verify.
+	ErrUnsupportedAttestation = errors.New("unsupported attestation type")
+)
+
+// PolicyMismatch is a policy field the evidence does not satisfy.
+type PolicyMismatch struct {
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+// AttestationVerdict is the outcome of verifying agent evidence against the
+// stored attestation policy.
+type AttestationVerdict struct {
+	Verified        bool      `json:"verified"`
+	AttestationType string    `json:"attestation_type"`
+	IssuedAt        time.Time `json:"issued_at"`
+	// NonceBound reports whether the quote commits to the client nonce.
+	NonceBound bool `json:"nonce_bound"`
+	// TLSKeyBound is set when the agent bound its evidence to a static TLS
+	// key and the proxy reached it over TLS.
+	TLSKeyBound   *bool                `json:"tls_key_bound,omitempty"`
+	Measurements  map[string]string    `json:"measurements"`
+	TCB           map[string]string    `json:"tcb"`
+	Mismatches    []PolicyMismatch     `json:"mismatches"`
+	VerifierError string               `json:"verifier_error,omitempty"`
+	Evidence      agent.EvidenceBundle `json:"evidence"`
+}
+
+// EvidenceVerifier cryptographically verifies raw evidence against a policy.
+type EvidenceVerifier func(attestationType string, policy, evidence, reportData, nonce []byte) error
+
+// VerifyAttestation implements Service.
+func (s *service) VerifyAttestation(
+	ctx context.Context, _ *cubeauth.Session, nonce []byte,
+) (*AttestationVerdict, error) {
+	if nonce == nil {
+		nonce = make([]byte, evidenceNonceSize)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, fmt.Errorf("failed to generate nonce: %w", err)
+		}
+	}
+
+	if len(nonce) != evidenceNonceSize {
+		return nil, ErrInvalidNonce
+	}
+
+	policy, err := s.repo.GetAttestationPolicy(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load attestation policy: %w", err)
+	}
+
+	if len(policy) == 0 {
+		return nil, ErrAttestationPolicyMissing
+	}
+
+	bundle, peerKeyHash, err := s.fetchEvidence(ctx, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	verdict, err := evaluateEvidence(bundle, nonce, policy)
+	if err != nil {
+		return nil, err
+	}
+
+	if bundle.TLSKeySHA256 != "" && peerKeyHash != "" {
+		bound := strings.EqualFold(bundle.TLSKeySHA256, peerKeyHash)
+		verdict.TLSKeyBound = &bound
+	}
+
+	if err := s.verifyEvidence(
+		bundle.AttestationType, policy, bundle.Evidence, bundle.ReportData, bundle.Nonce,
+	); err != nil {
+		verdict.VerifierError = err.Error()
+	}
+
+	verdict.Verified = verdict.NonceBound && len(verdict.Mismatches) == 0 && verdict.VerifierError == "" &&
+		(verdict.TLSKeyBound == nil || *verdict.TLSKeyBound)
+
+	return verdict, nil
+}
+
+// fetchEvidence requests fresh evidence for nonce from the agent. It also
+// returns the SHA-256 of the agent's TLS public key when the connection used
+// TLS.
+func (s *service) fetchEvidence(ctx context.Context, nonce []byte) (agent.EvidenceBundle, string, error) {
+	endpoint := strings.TrimRight(s.config.URL, "/") + "/attestation/evidence?nonce=" +
+		base64.RawURLEncoding.EncodeToString(nonce)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, http.NoBody)
+	if err != nil {
+		return agent.EvidenceBundle{}, "", fmt.Errorf("%w: %w", ErrEvidenceUnavailable, err)
+	}
+
+	client := &http.Client{Transport: s.transport, Timeout: s.config.Timeout}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return agent.EvidenceBundle{}, "", fmt.Errorf("%w: %w", ErrEvidenceUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxEvidenceSize))
+	if err != nil {
+		return agent.EvidenceBundle{}, "", fmt.Errorf("%w: %w", ErrEvidenceUnavailable, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return agent.EvidenceBundle{}, "", fmt.Errorf("%w: agent returned %d: %s",
+			ErrEvidenceUnavailable, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var bundle agent.EvidenceBundle
+	if err := json.Unmarshal(body, &bundle); err != nil {
+		return agent.EvidenceBundle{}, "", fmt.Errorf("%w: %w", ErrEvidenceUnavailable, err)
+	}
+
+	var peerKeyHash string
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		sum := sha256.Sum256(resp.TLS.PeerCertificates[0].RawSubjectPublicKeyInfo)
+		peerKeyHash = hex.EncodeToString(sum[:])
+	}
+
+	return bundle, peerKeyHash, nil
+}
+
+// evaluateEvidence checks the nonce binding and compares the quote with the
+// policy field by field. It does not check signatures; that is left to the
+// EvidenceVerifier.
+func evaluateEvidence(bundle agent.EvidenceBundle, nonce, policy []byte) (*AttestationVerdict, error) {
+	verdict := &AttestationVerdict{
+		AttestationType: bundle.AttestationType,
+		IssuedAt:        bundle.IssuedAt,
+		Measurements:    map[string]string{},
+		TCB:             map[string]string{},
+		Mismatches:      []PolicyMismatch{},
+		Evidence:        bundle,
+	}
+
+	keyHash, err := hex.DecodeString(bundle.TLSKeySHA256)
+	if err != nil {
+		return nil, fmt.Errorf("%w: invalid tls_key_sha256: %w", ErrEvidenceUnavailable, err)
+	}
+
+	expected := agent.EvidenceReportData(keyHash, nonce, bundle.IssuedAt)
+	bindingOK := bytes.Equal(bundle.Nonce, nonce) && bytes.Equal(bundle.ReportData, expected[:])
+
+	var quoteReportData []byte
+
+	switch bundle.AttestationType {
+	case "snp":
+		report, err := abi.ReportToProto(bundle.Evidence)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrEvidenceUnavailable, err)
+		}
+
+		quoteReportData = report.GetReportData()
+		policyCfg, err := snpPolicy(policy)
+		if err != nil {
+			return nil, err
+		}
+
+		describeSNP(verdict, report, policyCfg.Policy)
+	case "snpvtpm", "azure":
+		att := &attest.Attestation{}
+		if err := proto.Unmarshal(bundle.Evidence, att); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrEvidenceUnavailable, err)
+		}
+
+		report := att.GetSevSnpAttestation().GetReport()
+		if report == nil {
+			return nil, fmt.Errorf("%w: vTPM quote has no SEV-SNP report", ErrEvidenceUnavailable)
+		}
+
+		// The SEV-SNP report of a vTPM quote commits to the report data and
+		// the attestation key.
+		bound := sha3.Sum512(append(append([]byte{}, bundle.ReportData...), att.GetAkPub()...))
+		if bytes.Equal(report.GetReportData(), bound[:]) {
+			quoteReportData = bundle.ReportData
+		}
+
+		policyCfg, err := snpPolicy(policy)
+		if err != nil {
+			return nil, err
+		}
+
+		describeSNP(verdict, report, policyCfg.Policy)
+	case "tdx":
+		parsed, err := tdxabi.QuoteToProto(bundle.Evidence)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrEvidenceUnavailable, err)
+		}
+
+		quote, ok := parsed.(*tdx.QuoteV4)
+		if !ok {
+			return nil, fmt.Errorf("%w: unsupported TDX quote version", ErrEvidenceUnavailable)
+		}
+
+		quoteReportData = quote.GetTdQuoteBody().GetReportData()
+		policyCfg, err := tdxPolicy(policy)
+		if err != nil {
+			return nil, err
+		}
+
+		describeTDX(verdict, quote.GetTdQuoteBody(), policyCfg.GetPolicy().GetTdQuoteBodyPolicy())
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedAttestation, bundle.AttestationType)
+	}
+
+	verdict.NonceBound = bindingOK && bytes.Equal(quoteReportData, bundle.ReportData)
+
+	return verdict, nil
+}
+
+func describeSNP(v *AttestationVerdict, r *sevsnp.Report, p *check.Policy) {
+	v.Measurements["measurement"] = hex.EncodeToString(r.GetMeasurement())
+	v.Measurements["host_data"] = hex.EncodeToString(r.GetHostData())
+	v.Measurements["family_id"] = hex.EncodeToString(r.GetFamilyId())
+	v.Measurements["image_id"] = hex.EncodeToString(r.GetImageId())
+	v.Measurements["report_data"] = hex.EncodeToString(r.GetReportData())
+
+	v.TCB["current_tcb"] = tcbHex(r.GetCurrentTcb())
+	v.TCB["reported_tcb"] = tcbHex(r.GetReportedTcb())
+	v.TCB["committed_tcb"] = tcbHex(r.GetCommittedTcb())
+	v.TCB["launch_tcb"] = tcbHex(r.GetLaunchTcb())
+	v.TCB["guest_svn"] = strconv.FormatUint(uint64(r.GetGuestSvn()), 10)
+	v.TCB["vmpl"] = strconv.FormatUint(uint64(r.GetVmpl()), 10)
+	v.TCB["firmware"] = fmt.Sprintf("%d.%d build %d", r.GetCurrentMajor(), r.GetCurrentMinor(), r.GetCurrentBuild())
+
+	if p == nil {
+		return
+	}
+
+	v.compareBytes("measurement", p.GetMeasurement(), r.GetMeasurement())
+	v.compareBytes("host_data", p.GetHostData(), r.GetHostData())
+	v.compareBytes("family_id", p.GetFamilyId(), r.GetFamilyId())
+	v.compareBytes("image_id", p.GetImageId(), r.GetImageId())
+
+	if r.GetGuestSvn() < p.GetMinimumGuestSvn() {
+		v.mismatch("minimum_guest_svn",
+			">= "+strconv.FormatUint(uint64(p.GetMinimumGuestSvn()), 10),
+			strconv.FormatUint(uint64(r.GetGuestSvn()), 10))
+	}
+
+	if p.GetVmpl() != nil && p.GetVmpl().GetValue() != r.GetVmpl() {
+		v.mismatch("vmpl",
+			strconv.FormatUint(uint64(p.GetVmpl().GetValue()), 10),
+			strconv.FormatUint(uint64(r.GetVmpl()), 10))
+	}
+
+	if !tcbAtLeast(r.GetReportedTcb(), p.GetMinimumTcb()) {
+		v.mismatch("minimum_tcb", ">= "+tcbHex(p.GetMinimumTcb()), tcbHex(r.GetReportedTcb()))
+	}
+
+	if !tcbAtLeast(r.GetLaunchTcb(), p.GetMinimumLaunchTcb()) {
+		v.mismatch("minimum_launch_tcb", ">= "+tcbHex(p.GetMinimumLaunchTcb()), tcbHex(r.GetLaunchTcb()))
+	}
+}
+
+func describeTDX(v *AttestationVerdict, body *tdx.TDQuoteBody, p *checkconfig.TDQuoteBodyPolicy) {
+	v.Measurements["mr_td"] = hex.EncodeToString(body.GetMrTd())
+	v.Measurements["mr_seam"] = hex.EncodeToString(body.GetMrSeam())
+	v.Measurements["mr_config_id"] = hex.EncodeToString(body.GetMrConfigId())
+	v.Measurements["mr_owner"] = hex.EncodeToString(body.GetMrOwner())
+	v.Measurements["mr_owner_config"] = hex.EncodeToString(body.GetMrOwnerConfig())
+	v.Measurements["report_data"] = hex.EncodeToString(body.GetReportData())
+
+	for i, rtmr := range body.GetRtmrs() {
+		v.Measurements["rtmr"+strconv.Itoa(i)] = hex.EncodeToString(rtmr)
+	}
+
+	v.TCB["tee_tcb_svn"] = hex.EncodeToString(body.GetTeeTcbSvn())
+	v.TCB["td_attributes"] = hex.EncodeToString(body.GetTdAttributes())
+	v.TCB["xfam"] = hex.EncodeToString(body.GetXfam())
+
+	if p == nil {
+		return
+	}
+
+	if len(p.GetAnyMrTd()) > 0 {
+		matched := false
+
+		for _, mrTd := range p.GetAnyMrTd() {
+			if bytes.Equal(mrTd, body.GetMrTd()) {
+				matched = true
+
+				break
+			}
+		}
+
+		if !matched {
+			v.mismatch("any_mr_td", "one of the allowed MRTD values", hex.EncodeToString(body.GetMrTd()))
+		}
+	}
+
+	v.compareBytes("mr_td", p.GetMrTd(), body.GetMrTd())
+	v.compareBytes("mr_seam", p.GetMrSeam(), body.GetMrSeam())
+	v.compareBytes("mr_config_id", p.GetMrConfigId(), body.GetMrConfigId())
+	v.compareBytes("mr_owner", p.GetMrOwner(), body.GetMrOwner())
+	v.compareBytes("mr_owner_config", p.GetMrOwnerConfig(), body.GetMrOwnerConfig())
+	v.compareBytes("td_attributes", p.GetTdAttributes(), body.GetTdAttributes())
+	v.compareBytes("xfam", p.GetXfam(), body.GetXfam())
+
+	for i, want := range p.GetRtmrs() {
+		var got []byte
+		if i < len(body.GetRtmrs()) {
+			got = body.GetRtmrs()[i]
+		}
+
+		v.compareBytes("rtmr"+strconv.Itoa(i), want, got)
+	}
+
+	if minSvn := p.GetMinimumTeeTcbSvn(); len(minSvn) > 0 && !svnAtLeast(body.GetTeeTcbSvn(), minSvn) {
+		v.mismatch("minimum_tee_tcb_svn", ">= "+hex.EncodeToString(minSvn), hex.EncodeToString(body.GetTeeTcbSvn()))
+	}
+}
+
+// compareBytes records a mismatch when the policy sets field and the
+// evidence differs. Empty policy fields are not enforced.
+func (v *AttestationVerdict) compareBytes(field string, want, got []byte) {
+	if len(want) == 0 || bytes.Equal(want, got) {
+		return
+	}
+
+	v.mismatch(field, hex.EncodeToString(want), hex.EncodeToString(got))
+}
+
+func (v *AttestationVerdict) mismatch(field, expected, actual string) {
+	v.Mismatches = append(v.Mismatches, PolicyMismatch{Field: field, Expected: expected, Actual: actual})
+}
+
+func tcbHex(tcb uint64) string {
+	return fmt.Sprintf("0x%016x", tcb)
+}
+
+// tcbAtLeast compares SEV-SNP TCB versions component-wise; each byte holds
+// the security version of one firmware component.
+func tcbAtLeast(actual, minimum uint64) bool {
+	for shift := 0; shift < 64; shift += 8 {
+		if (actual>>shift)&0xff < (minimum>>shift)&0xff {
+			return false
+		}
+	}
+
+	return true
+}
+
+// svnAtLeast compares TDX TEE TCB SVNs byte by byte.
+func svnAtLeast(actual, minimum []byte) bool {
+	for i, m := range minimum {
+		if i >= len(actual) || actual[i] < m {
+			return false
+		}
+	}
+
+	return true
+}
+
+func snpPolicy(policy []byte) (*attestation.Config, error) {
+	cfg := &attestation.Config{
+		Config:    &check.Config{Policy: &check.Policy{}, RootOfTrust: &check.RootOfTrust{}},
+		PcrConfig: &attestation.PcrConfig{},
+	}
+
+	if err := vtpm.ReadPolicyFromByte(policy, cfg); err != nil {
+		return nil, fmt.Errorf("invalid attestation policy: %w", err)
+	}
+
+	return cfg, nil
+}
+
+func tdxPolicy(policy []byte) (*checkconfig.Config, error) {
+	cfg := &checkconfig.Config{}
+	if err := (protojson.UnmarshalOptions{DiscardUnknown: true}).Unmarshal(policy, cfg); err != nil {
+		return nil, fmt.Errorf("invalid attestation policy: %w", err)
+	}
+
+	if cfg.RootOfTrust == nil {
+		cfg.RootOfTrust = &checkconfig.RootOfTrust{}
+	}
+
+	if cfg.Policy == nil {
+		cfg.Policy = &checkconfig.Policy{}
+	}
+
+	if cfg.Policy.HeaderPolicy == nil {
+		cfg.Policy.HeaderPolicy = &checkconfig.HeaderPolicy{}
+	}
+
+	if cfg.Policy.TdQuoteBodyPolicy == nil {
+		cfg.Policy.TdQuoteBodyPolicy = &checkconfig.TDQuoteBodyPolicy{}
+	}
+
+	return cfg, nil
+}
+
+// cocosVerify verifies evidence signatures and policy with the cocos verifiers.
+func cocosVerify(attestationType string, policy, evidence, reportData, nonce []byte) error {
+	switch attestationType {
+	case "snp", "snpvtpm", "azure":
+		cfg, err := snpPolicy(policy)
+		if err != nil {
+			return err
+		}
+
+		switch attestationType {
+		case "snp":
+			return vtpm.NewVerifierWithPolicy(nil, io.Discard, cfg).VerifTeeAttestation(evidence, reportData)
+		case "snpvtpm":
+			return vtpm.NewVerifierWithPolicy(nil, io.Discard, cfg).VerifyAttestation(evidence, reportData, nonce)
+		default:
+			return azure.NewVerifierWithPolicy(io.Discard, cfg).VerifyAttestation(evidence, reportData, nonce)
+		}
+	case "tdx":
+		cfg, err := tdxPolicy(policy)
+		if err != nil {
+			return err
+		}
+
+		return cocostdx.NewVerifierWithPolicy(cfg).VerifyAttestation(evidence, reportData, nonce)
+	default:
+		return fmt.Errorf("%w: %q", ErrUnsupportedAttestation, attestationType)
+	}
+}
@@ -0,0 +1,236 @@
+// Copyright (c) Ultraviolet
+// SPDX-License-Identifier: Apache-2.0
+
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/go-sev-guest/abi"
+	tdxabi "github.com/google/go-tdx-guest/abi"
+	"github.com/google/go-tdx-guest/proto/tdx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ultravioletrs/cocos/pkg/clients"
+	"github.com/ultravioletrs/cube/agent"
+	"github.com/ultravioletrs/cube/internal/cubeauth"
+	"github.com/ultravioletrs/cube/proxy/router"
+)
+
+const (
+	snpMeasurement = "a03628e1ef7c0dad85cbbde70d5666c625a2cfee609f169eecd311b5d7e7c296c1b95619b08d26ca7cf77e97def98217"
+)
+
+type policyRepo struct {
+	Repository
+
+	policy []byte
+}
+
+func (r policyRepo) GetAttestationPolicy(context.Context) ([]byte, error) {
+	return r.policy, nil
+}
+
+// fakeAgent serves evidence built from a recorded quote whose report data is
+// rewritten to bind the requested nonce.
+func fakeAgent(t *testing.T, attestationType string, quote, origReportData []byte) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/attestation/evidence", r.URL.Path)
+
+		nonce, err := base64.RawURLEncoding.DecodeString(r.URL.Query().Get("nonce"))
+		require.NoError(t, err)
+
+		issuedAt := time.Now().UTC().Truncate(time.Second)
+		reportData := agent.EvidenceReportData(nil, nonce, issuedAt)
+
+		evidence := bytes.Replace(quote, origReportData, reportData[:], 1)
+
+		_ = json.NewEncoder(w).Encode(agent.EvidenceBundle{
+			AttestationType: attestationType,
+			IssuedAt:        issuedAt,
+			Nonce:           nonce,
+			ReportData:      reportData[:],
+			Evidence:        evidence,
+		})
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func newVerifyService(t *testing.T, agentURL string, policy []byte, verifyErr error) *service {
+	t.Helper()
+
+	svc, err := NewWithRouter(&clients.AttestedClientConfig{
+		StandardClientConfig: clients.StandardClientConfig{URL: agentURL, Timeout: 5 * time.Second},
+	}, policyRepo{policy: policy}, &router.Router{})
+	require.NoError(t, err)
+
+	s, ok := svc.(*service)
+	require.True(t, ok)
+
+	s.verifyEvidence = func(string, []byte, []byte, []byte, []byte) error { return verifyErr }
+
+	return s
+}
+
+func readFixture(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile("testdata/" + name)
+	require.NoError(t, err)
+
+	return data
+}
+
+func snpPolicyJSON(t *testing.T, measurement string, minimumGuestSvn int) []byte {
+	t.Helper()
+
+	m, err := hex.DecodeString(measurement)
+	require.NoError(t, err)
+
+	policy, err := json.Marshal(map[string]any{
+		"policy": map[string]any{
+			"measurement":       base64.StdEncoding.EncodeToString(m),
+			"minimum_guest_svn": minimumGuestSvn,
+		},
+		"root_of_trust": map[string]any{"product_line": "Milan"},
+	})
+	require.NoError(t, err)
+
+	return policy
+}
+
+func TestVerifyAttestationSNPFixture(t *testing.T) {
+	t.Parallel()
+
+	quote := readFixture(t, "snp_report.bin")
+	report, err := abi.ReportToProto(quote)
+	require.NoError(t, err)
+
+	srv := fakeAgent(t, "snp", quote, report.GetReportData())
+	svc := newVerifyService(t, srv.URL, snpPolicyJSON(t, snpMeasurement, 0), nil)
+
+	nonce := bytes.Repeat([]byte{0x42}, evidenceNonceSize)
+
+	verdict, err := svc.VerifyAttestation(context.Background(), &cubeauth.Session{}, nonce)
+	require.NoError(t, err)
+
+	assert.True(t, verdict.Verified)
+	assert.True(t, verdict.NonceBound)
+	assert.Nil(t, verdict.TLSKeyBound)
+	assert.Empty(t, verdict.Mismatches)
+	assert.Equal(t, "snp", verdict.AttestationType)
+	assert.Equal(t, snpMeasurement, verdict.Measurements["measurement"])
+	assert.Equal(t, "0xd50e000000000003", verdict.TCB["reported_tcb"])
+	assert.Equal(t, nonce, verdict.Evidence.Nonce)
+	assert.NotEmpty(t, verdict.Evidence.Evidence)
+}
+
+func TestVerifyAttestationReportsPolicyMismatches(t *testing.T) {
+	t.Parallel()
+
+	quote := readFixture(t, "snp_report.bin")
+	report, err := abi.ReportToProto(quote)
+	require.NoError(t, err)
+
+	srv := fakeAgent(t, "snp", quote, report.GetReportData())
+	wrong := hex.EncodeToString(bytes.Repeat([]byte{0x11}, 48))
+	svc := newVerifyService(t, srv.URL, snpPolicyJSON(t, wrong, 1000), errors.New("signature invalid"))
+
+	verdict, err := svc.VerifyAttestation(context.Background(), &cubeauth.Session{}, nil)
+	require.NoError(t, err)
+
+	assert.False(t, verdict.Verified)
+	assert.True(t, verdict.NonceBound)
+	assert.Equal(t, "signature invalid", verdict.VerifierError)
+
+	fields := make([]string, 0, len(verdict.Mismatches))
+	for _, m := range verdict.Mismatches {
+		fields = append(fields, m.Field)
+	}
+
+	assert.ElementsMatch(t, []string{"measurement", "minimum_guest_svn"}, fields)
+	assert.Equal(t, wrong, verdict.Mismatches[0].Expected)
+	assert.Equal(t, snpMeasurement, verdict.Mismatches[0].Actual)
+}
+
+func TestVerifyAttestationTDXFixture(t *testing.T) {
+	t.Parallel()
+
+	quote := readFixture(t, "tdx_quote.dat")
+	parsed, err := tdxabi.QuoteToProto(quote)
+	require.NoError(t, err)
+
+	body := parsed.(*tdx.QuoteV4).GetTdQuoteBody()
+
+	policy, err := json.Marshal(map[string]any{
+		"policy": map[string]any{
+			"td_quote_body_policy": map[string]any{
+				"mr_td":   base64.StdEncoding.EncodeToString(body.GetMrTd()),
+				"mr_seam": base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{0x01}, 48)),
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	srv := fakeAgent(t, "tdx", quote, body.GetReportData())
+	svc := newVerifyService(t, srv.URL, policy, nil)
+
+	verdict, err := svc.VerifyAttestation(context.Background(), &cubeauth.Session{}, nil)
+	require.NoError(t, err)
+
+	assert.False(t, verdict.Verified)
+	assert.True(t, verdict.NonceBound)
+	assert.Equal(t, hex.EncodeToString(body.GetMrTd()), verdict.Measurements["mr_td"])
+	assert.Contains(t, verdict.Measurements, "rtmr3")
+	assert.Equal(t, hex.EncodeToString(body.GetTeeTcbSvn()), verdict.TCB["tee_tcb_svn"])
+	require.Len(t, verdict.Mismatches, 1)
+	assert.Equal(t, "mr_seam", verdict.Mismatches[0].Field)
+}
+
+func TestVerifyAttestationDetectsUnboundEvidence(t *testing.T) {
+	t.Parallel()
+
+	quote := readFixture(t, "snp_report.bin")
+
+	// Serve the recorded quote untouched: its report data does not commit
+	// to the client nonce.
+	srv := fakeAgent(t, "snp", quote, []byte("not present in the quote"))
+	svc := newVerifyService(t, srv.URL, snpPolicyJSON(t, snpMeasurement, 0), nil)
+
+	verdict, err := svc.VerifyAttestation(context.Background(), &cubeauth.Session{}, nil)
+	require.NoError(t, err)
+
+	assert.False(t, verdict.NonceBound)
+	assert.False(t, verdict.Verified)
+}
+
+func TestVerifyAttestationErrors(t *testing.T) {
+	t.Parallel()
+
+	svc := newVerifyService(t, "http://127.0.0.1:1", nil, nil)
+
+	_, err := svc.VerifyAttestation(context.Background(), &cubeauth.Session{}, []byte("short"))
+	assert.ErrorIs(t, err, ErrInvalidNonce)
+
+	_, err = svc.VerifyAttestation(context.Background(), &cubeauth.Session{}, nil)
+	assert.ErrorIs(t, err, ErrAttestationPolicyMissing)
+
+	svc = newVerifyService(t, "http://127.0.0.1:1", []byte(`{}`), nil)
+
+	_, err = svc.VerifyAttestation(context.Background(), &cubeauth.Session{}, nil)
+	assert.ErrorIs(t, err, ErrEvidenceUnavailable)
+}
@@ -19,6 +19,7 @@ type Endpoints struct {
 	ProxyRequest            endpoint.Endpoint
 	GetAttestationPolicy    endpoint.Endpoint
 	UpdateAttestationPolicy endpoint.Endpoint
+	VerifyAttestation       endpoint.Endpoint
 	CreateRoute             endpoint.Endpoint
 	GetRoute                endpoint.Endpoint
 	UpdateRoute             endpoint.Endpoint
@@ -31,6 +32,7 @@ func MakeEndpoints(s proxy.Service) Endpoints {
 		ProxyRequest:            MakeProxyRequestEndpoint(s),
 		GetAttestationPolicy:    MakeGetAttestationPolicyEndpoint(s),
 		UpdateAttestationPolicy: MakeUpdateAttestationPolicyEndpoint(s),
+		VerifyAttestation:       MakeVerifyAttestationEndpoint(s),
 		CreateRoute:             MakeCreateRouteEndpoint(s),
 		GetRoute:                MakeGetRouteEndpoint(s),
 		UpdateRoute:             MakeUpdateRouteEndpoint(s),
@@ -118,6 +120,33 @@ func MakeUpdateAttestationPolicyEndpoint(s proxy.Service) endpoint.Endpoint {
 	}
 }
 
+type VerifyAttestationRequest struct {
+	Session *cubeauth.Session
+	Nonce   []byte
+}
+
+type VerifyAttestationResponse struct {
+	Verdict *proxy.AttestationVerdict
+	Err     error
+}
+
+func (r VerifyAttestationResponse) Failed() error {
+	return r.Err
+}
+
+func MakeVerifyAttestationEndpoint(s proxy.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request any) (any, error) {
+		req, ok := request.(VerifyAttestationRequest)
+		if !ok {
+			return VerifyAttestationResponse{Err: errInvalidRequestType}, nil
+		}
+
+		verdict, err := s.VerifyAttestation(ctx, req.Session, req.Nonce)
+
+		return VerifyAttestationResponse{Verdict: verdict, Err: err}, nil
+	}
+}
+
 type CreateRouteRequest struct {
 	Session *cubeauth.Session
 	Route   *router.RouteRule
@@ -73,6 +73,20 @@ func (am *authMiddleware) GetAttestationPolicy(ctx context.Context, session *cub
 	return am.next.GetAttestationPolicy(ctx, session)
 }
 
+func (am *authMiddleware) VerifyAttestation(
+	ctx context.Context, session *cubeauth.Session, nonce []byte,
+) (*proxy.AttestationVerdict, error) {
+	if session.TenantID == "" {
+		return nil, ErrAuthorization
+	}
+
+	if err := am.authorize(ctx, session, actionRead); err != nil {
+		return nil, err
+	}
+
+	return am.next.VerifyAttestation(ctx, session, nonce)
+}
+
 func (am *authMiddleware) UpdateAttestationPolicy(
 	ctx context.Context, session *cubeauth.Session, policy []byte,
 ) error {
@@ -50,6 +50,22 @@ func (l *loggingMiddleware) GetAttestationPolicy(
 	return l.svc.GetAttestationPolicy(ctx, session)
 }
 
+// VerifyAttestation implements proxy.Service.
+func (l *loggingMiddleware) VerifyAttestation(
+	ctx context.Context, session *cubeauth.Session, nonce []byte,
+) (verdict *proxy.AttestationVerdict, err error) {
+	defer func(begin time.Time) {
+		args := []any{"took", time.Since(begin), "error", err}
+		if verdict != nil {
+			args = append(args, "verified", verdict.Verified, "attestation_type", verdict.AttestationType)
+		}
+
+		l.logger.Info("VerifyAttestation", args...)
+	}(time.Now())
+
+	return l.svc.VerifyAttestation(ctx, session, nonce)
+}
+
 // UpdateAttestationPolicy implements proxy.Service.
 func (l *loggingMiddleware) UpdateAttestationPolicy(
 	ctx context.Context, session *cubeauth.Session, policy []byte,
@@ -47,6 +47,18 @@ func (m *metricsMiddleware) GetAttestationPolicy(ctx context.Context, session *c
 	return m.svc.GetAttestationPolicy(ctx, session)
 }
 
+// VerifyAttestation implements proxy.Service.
+func (m *metricsMiddleware) VerifyAttestation(
+	ctx context.Context, session *cubeauth.Session, nonce []byte,
+) (*proxy.AttestationVerdict, error) {
+	defer func(begin time.Time) {
+		m.counter.With("method", "verify_attestation").Add(1)
+		m.latency.With("method", "verify_attestation").Observe(time.Since(begin).Seconds())
+	}(time.Now())
+
+	return m.svc.VerifyAttestation(ctx, session, nonce)
+}
+
 // UpdateAttestationPolicy implements proxy.Service.
 func (m *metricsMiddleware) UpdateAttestationPolicy(
 	ctx context.Context, session *cubeauth.Session, policy []byte,
@@ -42,6 +42,16 @@ func (t *tracingMiddleware) GetAttestationPolicy(ctx context.Context, session *c
 	return t.svc.GetAttestationPolicy(ctx, session)
 }
 
+// VerifyAttestation implements proxy.Service.
+func (t *tracingMiddleware) VerifyAttestation(
+	ctx context.Context, session *cubeauth.Session, nonce []byte,
+) (*proxy.AttestationVerdict, error) {
+	ctx, span := t.tracer.Start(ctx, "VerifyAttestation")
+	defer span.End()
+
+	return t.svc.VerifyAttestation(ctx, session, nonce)
+}
+
 // UpdateAttestationPolicy implements proxy.Service.
 func (t *tracingMiddleware) UpdateAttestationPolicy(
 	ctx context.Context, session *cubeauth.Session, policy []byte,
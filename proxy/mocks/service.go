@@ -12,6 +12,7 @@ import (
 
 	mock "github.com/stretchr/testify/mock"
 	"github.com/ultravioletrs/cube/internal/cubeauth"
+	"github.com/ultravioletrs/cube/proxy"
 	"github.com/ultravioletrs/cube/proxy/router"
 )
 
@@ -656,3 +657,77 @@ func (_c *Service_UpdateRoute_Call) RunAndReturn(run func(ctx context.Context, s
 	_c.Call.Return(run)
 	return _c
 }
+
+// VerifyAttestation provides a mock function for the type Service
+func (_mock *Service) VerifyAttestation(ctx context.Context, session *cubeauth.Session, nonce []byte) (*proxy.AttestationVerdict, error) {
+	ret := _mock.Called(ctx, session, nonce)
+
+	if len(ret) == 0 {
+		panic("no return value specified for VerifyAttestation")
+	}
+
+	var r0 *proxy.AttestationVerdict
+	var r1 error
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *cubeauth.Session, []byte) (*proxy.AttestationVerdict, error)); ok {
+		return returnFunc(ctx, session, nonce)
+	}
+	if returnFunc, ok := ret.Get(0).(func(context.Context, *cubeauth.Session, []byte) *proxy.AttestationVerdict); ok {
+		r0 = returnFunc(ctx, session, nonce)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*proxy.AttestationVerdict)
+		}
+	}
+	if returnFunc, ok := ret.Get(1).(func(context.Context, *cubeauth.Session, []byte) error); ok {
+		r1 = returnFunc(ctx, session, nonce)
+	} else {
+		r1 = ret.Error(1)
+	}
+	return r0, r1
+}
+
+// Service_VerifyAttestation_Call is a *mock.Call that shadows Run/Return methods with type explicit version for method 'VerifyAttestation'
+type Service_VerifyAttestation_Call struct {
+	*mock.Call
+}
+
+// VerifyAttestation is a helper method to define mock.On call
+//   - ctx context.Context
+//   - session *cubeauth.Session
+//   - nonce []byte
+func (_e *Service_Expecter) VerifyAttestation(ctx interface{}, session interface{}, nonce interface{}) *Service_VerifyAttestation_Call {
+	return &Service_VerifyAttestation_Call{Call: _e.mock.On("VerifyAttestation", ctx, session, nonce)}
+}
+
+func (_c *Service_VerifyAttestation_Call) Run(run func(ctx context.Context, session *cubeauth.Session, nonce []byte)) *Service_VerifyAttestation_Call {
+	_c.Call.Run(func(args mock.Arguments) {
+		var arg0 context.Context
+		if args[0] != nil {
+			arg0 = args[0].(context.Context)
+		}
+		var arg1 *cubeauth.Session
+		if args[1] != nil {
+			arg1 = args[1].(*cubeauth.Session)
+		}
+		var arg2 []byte
+		if args[2] != nil {
+			arg2 = args[2].([]byte)
+		}
+		run(
+			arg0,
+			arg1,
+			arg2,
+		)
+	})
+	return _c
+}
+
+func (_c *Service_VerifyAttestation_Call) Return(attestationVerdict *proxy.AttestationVerdict, err error) *Service_VerifyAttestation_Call {
+	_c.Call.Return(attestationVerdict, err)
+	return _c
+}
+
+func (_c *Service_VerifyAttestation_Call) RunAndReturn(run func(ctx context.Context, session *cubeauth.Session, nonce []byte) (*proxy.AttestationVerdict, error)) *Service_VerifyAttestation_Call {
+	_c.Call.Return(run)
+	return _c
+}
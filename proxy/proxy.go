@@ -23,6 +23,10 @@ type Service interface {
 	Secure() string
 	UpdateAttestationPolicy(ctx context.Context, session *cubeauth.Session, policy []byte) error
 	GetAttestationPolicy(ctx context.Context, session *cubeauth.Session) ([]byte, error)
+	// VerifyAttestation fetches fresh agent evidence bound to nonce and
+	// verifies it against the stored attestation policy. A nil nonce is
+	// replaced with a random one.
+	VerifyAttestation(ctx context.Context, session *cubeauth.Session, nonce []byte) (*AttestationVerdict, error)
 	CreateRoute(ctx context.Context, session *cubeauth.Session, route *router.RouteRule) (*router.RouteRule, error)
 	UpdateRoute(ctx context.Context, session *cubeauth.Session, name string,
 		route *router.RouteRule) (*router.RouteRule, error)
@@ -15,11 +15,12 @@ import (
 )
 
 type service struct {
-	config    *clients.AttestedClientConfig
-	transport *http.Transport
-	secure    string
-	repo      Repository
-	router    *router.Router
+	config         *clients.AttestedClientConfig
+	transport      *http.Transport
+	secure         string
+	repo           Repository
+	router         *router.Router
+	verifyEvidence EvidenceVerifier
 }
 
 func New(config *clients.AttestedClientConfig, repo Repository) (Service, error) {
@@ -29,10 +30,11 @@ func New(config *clients.AttestedClientConfig, repo Repository) (Service, error)
 	}
 
 	return &service{
-		config:    config,
-		transport: client.Transport(),
-		secure:    client.Secure(),
-		repo:      repo,
+		config:         config,
+		transport:      client.Transport(),
+		secure:         client.Secure(),
+		repo:           repo,
+		verifyEvidence: cocosVerify,
 	}, nil
 }
 
@@ -44,11 +46,12 @@ func NewWithRouter(config *clients.AttestedClientConfig, repo Repository, rter *
 	}
 
 	return &service{
-		config:    config,
-		transport: client.Transport(),
-		secure:    client.Secure(),
-		repo:      repo,
-		router:    rter,
+		config:         config,
+		transport:      client.Transport(),
+		secure:         client.Secure(),
+		repo:           repo,
+		router:         rter,
+		verifyEvidence: cocosVerify,
 	}, nil
 }
 
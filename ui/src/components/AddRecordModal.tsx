@@ -10,12 +10,12 @@ interface Props {
 type RecordType = 'document' | 'image' | 'link'
 
 const RECORD_TYPES: { value: RecordType; label: string; description: string; accept: string }[] = [
-  { value: 'document', label: 'Document', description: 'PDF, TXT, Markdown, or DOCX', accept: '.pdf,.txt,.md,.docx' },
+  { value: 'document', label: 'Document', description: 'PDF, TXT, Markdown, Office files, archives or emails', accept: '.pdf,.txt,.md,.docx,.xlsx,.pptx,.odt,.ods,.rtf,.zip,.tar,.tgz,.gz,.eml,.msg' },
   { value: 'image',    label: 'Image',    description: 'PNG, JPG, WEBP, GIF, or SVG',  accept: 'image/*,.svg' },
   { value: 'link',     label: 'Link',     description: 'Index any web page by URL', accept: '' },
 ]
 
-const DOCUMENT_EXTENSIONS = ['pdf', 'txt', 'md', 'docx']
+const DOCUMENT_EXTENSIONS = ['pdf', 'txt', 'md', 'docx', 'xlsx', 'pptx', 'odt', 'ods', 'rtf', 'zip', 'tar', 'tgz', 'gz', 'eml', 'msg']
 const IMAGE_EXTENSIONS = ['png', 'jpg', 'jpeg', 'webp', 'gif', 'svg']
 
 const labelStyle: React.CSSProperties = {
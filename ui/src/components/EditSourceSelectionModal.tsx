@@ -70,6 +70,7 @@ export default function EditSourceSelectionModal({ authToken, source, onClose, o
     setFilesError('')
     try {
       const result = await browseGoogleDrive(authToken, {
+        sourceID: source.id,
         accessToken: source.accessToken,
         refreshToken: source.refreshToken,
         browseFolderID: folderID,
@@ -205,6 +206,7 @@ export default function EditSourceSelectionModal({ authToken, source, onClose, o
       }
       setPickerUploadProgress(65)
       const result = await browseGoogleDrive(authToken, {
+        sourceID: source.id,
         accessToken: source.accessToken,
         refreshToken: source.refreshToken,
         browseView: 'upload',
@@ -50,6 +50,9 @@ export async function listRecords(token: string, domainID: string): Promise<AppR
 
 // BackendModelConfig is the model override shape accepted by POST /api/v1/chat.
 export interface BackendModelConfig {
+  // provider_id references a server-side catalogue entry; when set the server
+  // supplies base_url and api_key itself.
+  provider_id?: string
   provider: string
   base_url: string
   model: string
@@ -172,6 +175,25 @@ export async function listOllamaModels(token: string, domainID: string): Promise
   return data.models ?? []
 }
 
+export interface CatalogueModel {
+  provider_id?: string
+  provider_name: string
+  provider: string
+  model: string
+}
+
+// listCatalogueModels fetches the domain's managed model catalogue merged with
+// the models installed on the server's Ollama.
+export async function listCatalogueModels(token: string, domainID: string): Promise<CatalogueModel[]> {
+  const res = await fetch(runtimePath('/api/v1/models', domainID), {
+    credentials: 'omit',
+    headers: authHeaders(token, domainID),
+  })
+  if (!res.ok) throw new Error(`listCatalogueModels: ${res.status}`)
+  const data = await res.json() as { models: CatalogueModel[] }
+  return data.models ?? []
+}
+
 export interface ModelConnectionResult {
   connected: boolean
   message: string
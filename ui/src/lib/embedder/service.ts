@@ -27,6 +27,7 @@ interface RecordDTO {
   mime_type?: string
   folder_path?: string | null
   folder_id?: string | null
+  parent_id?: string | null
 }
 
 interface SourceDTO {
@@ -318,8 +319,14 @@ function toRecordFormat(format: string): RecordFormat {
     case 'pdf':
     case 'md':
     case 'docx':
+    case 'xlsx':
+    case 'pptx':
+    case 'odt':
+    case 'ods':
+    case 'rtf':
     case 'code':
     case 'image':
+    case 'archive':
       return format
     default:
       return 'link'
@@ -351,6 +358,7 @@ function mapRecord(dto: RecordDTO): AppRecord {
     mimeType: dto.mime_type || undefined,
     folderPath: dto.folder_path ?? undefined,
     folderID: dto.folder_id ?? undefined,
+    parentID: dto.parent_id ?? undefined,
   }
 }
 
@@ -653,6 +661,9 @@ export async function exchangeGoogleOAuth(
 export async function browseGoogleDrive(
   token: string,
   opts: {
+    // sourceID lets the server use the stored credentials of an existing
+    // source, since source responses only carry redacted placeholders.
+    sourceID?: string
     accessToken: string
     refreshToken?: string
     clientID?: string
@@ -670,6 +681,7 @@ export async function browseGoogleDrive(
   const data = await apiJSON<GoogleDriveBrowseDTO>('/api/v1/sources/google/files', token, {
     method: 'POST',
     body: JSON.stringify({
+      source_id: opts.sourceID ?? '',
       access_token: opts.accessToken,
       refresh_token: opts.refreshToken ?? '',
       client_id: opts.clientID ?? '',
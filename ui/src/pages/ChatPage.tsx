@@ -585,6 +585,7 @@ function SourcesPanel({
                     <span>topK {debug.top_k}</span>
                     <span>{debug.retrieval_enabled ? 'retrieval on' : `retrieval skipped${debug.skipped_reason ? `: ${debug.skipped_reason}` : ''}`}</span>
                     {debug.record_ids && debug.record_ids.length > 0 && <span>scoped records {debug.record_ids.length}</span>}
+                    {debug.provider && <span>served by {debug.provider}{debug.attempts && debug.attempts > 1 ? ` after ${debug.attempts} attempts` : ''}</span>}
                   </div>
                 </div>
                 {debug.prompt_chunks.length === 0 ? (
@@ -31,11 +31,17 @@ const formatFilterOptions: Array<{ value: RecordFormat | 'all'; label: string }>
   { value: 'all', label: 'All formats' },
   { value: 'pdf', label: 'PDF' },
   { value: 'docx', label: 'DOCX' },
+  { value: 'xlsx', label: 'Excel' },
+  { value: 'pptx', label: 'PowerPoint' },
+  { value: 'odt', label: 'ODT' },
+  { value: 'ods', label: 'ODS' },
+  { value: 'rtf', label: 'RTF' },
   { value: 'md', label: 'Markdown' },
   { value: 'text', label: 'Text' },
   { value: 'code', label: 'Code' },
   { value: 'image', label: 'Image' },
   { value: 'link', label: 'Link' },
+  { value: 'archive', label: 'Archive' },
 ]
 
 interface RecordsRouteState {
@@ -63,7 +69,7 @@ function StatusBadge({ record }: { record: AppRecord }) {
 }
 
 function DocBadge({ format }: { format: string }) {
-  const colors: Record<string, string> = { pdf: '#ff6b6b', docx: '#4d9ef7', md: '#a78bfa' }
+  const colors: Record<string, string> = { pdf: '#ff6b6b', docx: '#4d9ef7', md: '#a78bfa', xlsx: '#34a853', pptx: '#f59e0b' }
   const color = colors[format] ?? '#9ca3af'
   return (
     <div style={{ width: '36px', height: '36px', borderRadius: '8px', background: color + '18', border: `1px solid ${color}30`, display: 'flex', alignItems: 'center', justifyContent: 'center', fontFamily: 'JetBrains Mono, monospace', fontSize: '9px', color, fontWeight: '700', letterSpacing: '0.05em', flexShrink: 0 }}>
@@ -105,6 +111,16 @@ function recordFormatLabel(format: RecordFormat): string {
   switch (format) {
     case 'docx':
       return 'DOCX'
+    case 'xlsx':
+      return 'Excel'
+    case 'pptx':
+      return 'PowerPoint'
+    case 'odt':
+      return 'ODT'
+    case 'ods':
+      return 'ODS'
+    case 'rtf':
+      return 'RTF'
     case 'md':
       return 'Markdown'
     case 'pdf':
@@ -117,6 +133,8 @@ function recordFormatLabel(format: RecordFormat): string {
       return 'Image'
     case 'link':
       return 'Link'
+    case 'archive':
+      return 'Archive'
     default:
       return String(format).toUpperCase()
   }
@@ -13,7 +13,20 @@ export interface ActiveWorkspace {
 
 export type ActiveDomain = ActiveWorkspace
 
-export type RecordFormat = 'text' | 'pdf' | 'md' | 'docx' | 'code' | 'image' | 'link'
+export type RecordFormat =
+  | 'text'
+  | 'pdf'
+  | 'md'
+  | 'docx'
+  | 'xlsx'
+  | 'pptx'
+  | 'odt'
+  | 'ods'
+  | 'rtf'
+  | 'code'
+  | 'image'
+  | 'link'
+  | 'archive'
 
 export interface MsgSource {
   id: string
@@ -41,6 +54,9 @@ export interface ChatDebug {
   skipped_reason?: string
   record_ids?: string[]
   prompt_chunks: ChatDebugChunk[]
+  provider?: string
+  model?: string
+  attempts?: number
 }
 
 export interface ChatMessage {
@@ -73,6 +89,7 @@ export interface AppRecord {
   mimeType?: string
   folderPath?: string
   folderID?: string
+  parentID?: string
 }
 
 export interface Conversation {